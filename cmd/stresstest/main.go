@@ -0,0 +1,203 @@
+// Stress test command running the tiler several times on the same input with a fault injecting storage. Every run
+// must either fail with an error or produce the same tiles of a clean reference run: runs completing with different
+// tiles reveal faults that went unnoticed and make the command exit with a non zero status.
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/pkg"
+	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager/std_algorithm_manager"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Summary of the tiles written by a run, used to compare it to the reference run
+type outputSummary struct {
+	tilesets int
+	contents int
+	points   int64
+}
+
+func main() {
+	input := flag.String("input", "", "Input las file or folder.")
+	output := flag.String("output", "", "Output folder, a subfolder is written for every run.")
+	srid := flag.Int("srid", 4326, "EPSG srid code of input points.")
+	runs := flag.Int("runs", 10, "Number of runs with fault injection.")
+	errorRate := flag.Float64("error-rate", 0.001, "Probability of every storage operation to fail.")
+	shortReadRate := flag.Float64("short-read-rate", 0.01, "Probability of every read to return fewer bytes than requested.")
+	latency := flag.Duration("latency", 0, "Maximum random latency added to every storage operation, e.g. 5ms.")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "Seed of the first run, incremented at every run.")
+	flag.Parse()
+
+	if *input == "" || *output == "" {
+		fmt.Println("input and output flags are required")
+		os.Exit(2)
+	}
+	tools.DisableLogger()
+
+	reference, err := runTiler(*input, path.Join(*output, "reference"), *srid, storage.NewOsStorage())
+	if err != nil {
+		fmt.Println("reference run failed:", err)
+		os.Exit(2)
+	}
+	fmt.Printf("reference: %d tilesets, %d contents, %d points\n", reference.tilesets, reference.contents, reference.points)
+
+	failed, unnoticed := 0, 0
+	for i := 0; i < *runs; i++ {
+		config := storage.FaultConfig{
+			ErrorRate:     *errorRate,
+			ShortReadRate: *shortReadRate,
+			Latency:       *latency,
+			Seed:          *seed + int64(i),
+		}
+		faultyStorage := storage.NewFaultyStorage(storage.NewOsStorage(), config)
+		summary, err := runTiler(*input, path.Join(*output, "run-"+strconv.Itoa(i+1)), *srid, faultyStorage)
+
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("run %d (seed %d): failed as expected: %s\n", i+1, config.Seed, err)
+		case *summary != *reference:
+			unnoticed++
+			fmt.Printf("run %d (seed %d): UNNOTICED FAULT, %d tilesets, %d contents, %d points\n",
+				i+1, config.Seed, summary.tilesets, summary.contents, summary.points)
+		default:
+			fmt.Printf("run %d (seed %d): completed with the reference output\n", i+1, config.Seed)
+		}
+	}
+
+	fmt.Printf("%d runs: %d failed, %d completed, %d unnoticed faults\n", *runs, failed, *runs-failed-unnoticed, unnoticed)
+	if unnoticed > 0 {
+		os.Exit(1)
+	}
+}
+
+// Tiles the input with the default settings and the given storage, returning the summary of the written tiles
+func runTiler(input string, output string, srid int, storage storage.Storage) (*outputSummary, error) {
+	if err := os.MkdirAll(output, 0777); err != nil {
+		return nil, err
+	}
+	inputInfo, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := tiler.TilerOptions{
+		Input:               input,
+		Output:              output,
+		Srid:                srid,
+		MaxNumPointsPerNode: 50000,
+		FolderProcessing:    inputInfo.IsDir(),
+		Silent:              true,
+		Algorithm:           tiler.Grid,
+		CellMaxSize:         5.0,
+		CellMinSize:         0.15,
+		RefineMode:          tiler.RefineModeAdd,
+		RootGeometricError:  1,
+		CoordinateFrame:     tiler.CoordinateFrameEcef,
+		OriginSnap:          tiler.OriginSnapNone,
+	}
+
+	err = pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(&opts), storage).RunTiler(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return summarizeOutput(output)
+}
+
+// Counts the tilesets, tile contents and points written in the given folder
+func summarizeOutput(folder string) (*outputSummary, error) {
+	summary := &outputSummary{}
+	err := filepath.Walk(folder, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		switch {
+		case info.Name() == "tileset.json":
+			summary.tilesets++
+		case strings.HasPrefix(info.Name(), "content"):
+			points, err := readPointsLength(filePath)
+			if err != nil {
+				return err
+			}
+			summary.contents++
+			summary.points += points
+		}
+		return nil
+	})
+
+	return summary, err
+}
+
+// Reads the number of points stored in a pnts file from its feature table, or in a glb file from its position accessors
+func readPointsLength(filePath string) (int64, error) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	if len(content) >= 4 && string(content[0:4]) == "glTF" {
+		return readGlbPointsLength(filePath, content)
+	}
+	if len(content) < 28 || string(content[0:4]) != "pnts" {
+		return 0, errors.New("invalid pnts file " + filePath)
+	}
+	featureTableLength := int(binary.LittleEndian.Uint32(content[12:16]))
+	if len(content) < 28+featureTableLength {
+		return 0, errors.New("truncated pnts file " + filePath)
+	}
+
+	var featureTable struct {
+		PointsLength int64 `json:"POINTS_LENGTH"`
+	}
+	if err := json.Unmarshal(content[28:28+featureTableLength], &featureTable); err != nil {
+		return 0, err
+	}
+	return featureTable.PointsLength, nil
+}
+
+// Reads the number of points stored in the point primitives of a glb file from the counts of their position accessors
+func readGlbPointsLength(filePath string, content []byte) (int64, error) {
+	if len(content) < 20 {
+		return 0, errors.New("invalid glb file " + filePath)
+	}
+	jsonLength := int(binary.LittleEndian.Uint32(content[12:16]))
+	if len(content) < 20+jsonLength {
+		return 0, errors.New("truncated glb file " + filePath)
+	}
+
+	var document struct {
+		Meshes []struct {
+			Primitives []struct {
+				Attributes map[string]int `json:"attributes"`
+			} `json:"primitives"`
+		} `json:"meshes"`
+		Accessors []struct {
+			Count int64 `json:"count"`
+		} `json:"accessors"`
+	}
+	if err := json.Unmarshal(content[20:20+jsonLength], &document); err != nil {
+		return 0, err
+	}
+	var points int64
+	for _, mesh := range document.Meshes {
+		for _, primitive := range mesh.Primitives {
+			if position, ok := primitive.Attributes["POSITION"]; ok && position >= 0 && position < len(document.Accessors) {
+				points += document.Accessors[position].Count
+			}
+		}
+	}
+	return points, nil
+}
@@ -1,8 +1,10 @@
 module github.com/mfbonfigli/gocesiumtiler
 
-go 1.15
+go 1.25
 
 require (
-	github.com/xeonx/geom v0.0.0-20151223130215-76a21efc1ce4 // indirect
+	github.com/klauspost/compress v1.20.1
 	github.com/xeonx/proj4 v0.0.0-20151223112312-c52078bad901
 )
+
+require github.com/xeonx/geom v0.0.0-20151223130215-76a21efc1ce4 // indirect
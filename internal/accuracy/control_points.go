@@ -0,0 +1,42 @@
+package accuracy
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+)
+
+// Number of columns of a control point CSV record: id, x, y, z, expected x, expected y, expected z
+const controlPointColumns = 7
+
+// A point whose coordinates in the srid of the input points are known along with the coordinates it is expected to be
+// transformed to
+type ControlPoint struct {
+	Id       string
+	Source   geometry.Coordinate
+	Expected geometry.Coordinate
+}
+
+// Loads the control points stored in a comma separated file whose records hold the id of the point, its coordinates in
+// the srid of the input points and its expected coordinates: id,x,y,z,expected_x,expected_y,expected_z.
+func LoadControlPoints(filePath string) ([]*ControlPoint, error) {
+	records, err := tools.ReadCsvFloatRecords(filePath, "control point", controlPointColumns, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*ControlPoint, 0, len(records))
+	for _, record := range records {
+		values := record.Values
+		points = append(points, &ControlPoint{
+			Id:       record.Text[0],
+			Source:   geometry.Coordinate{X: values[0], Y: values[1], Z: values[2]},
+			Expected: geometry.Coordinate{X: values[3], Y: values[4], Z: values[5]},
+		})
+	}
+
+	if len(points) == 0 {
+		return nil, errors.New("no control points found in " + filePath)
+	}
+	return points, nil
+}
@@ -0,0 +1,137 @@
+package accuracy
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+)
+
+// Name of the report file written in the output folder
+const ReportFileName = "control_points.json"
+
+// Difference between the coordinates a control point is transformed to and its expected ones, in meters, expressed
+// in the East-North-Up frame centered on the expected position
+type Residual struct {
+	Id         string  `json:"id"`
+	Longitude  float64 `json:"longitude"`
+	Latitude   float64 `json:"latitude"`
+	Height     float64 `json:"height"`
+	East       float64 `json:"east"`
+	North      float64 `json:"north"`
+	Up         float64 `json:"up"`
+	Horizontal float64 `json:"horizontal"`
+	Distance   float64 `json:"distance"`
+}
+
+// Aggregate statistics of the residuals of all the control points
+type Summary struct {
+	Count          int     `json:"count"`
+	MeanEast       float64 `json:"meanEast"`
+	MeanNorth      float64 `json:"meanNorth"`
+	MeanUp         float64 `json:"meanUp"`
+	RmseHorizontal float64 `json:"rmseHorizontal"`
+	RmseVertical   float64 `json:"rmseVertical"`
+	Rmse           float64 `json:"rmse"`
+	MaxHorizontal  float64 `json:"maxHorizontal"`
+	MaxVertical    float64 `json:"maxVertical"`
+	MaxDistance    float64 `json:"maxDistance"`
+}
+
+// QA report of the transformation of the control points
+type Report struct {
+	Srid         int         `json:"srid"`
+	ExpectedSrid int         `json:"expectedSrid"`
+	Summary      Summary     `json:"summary"`
+	Points       []*Residual `json:"points"`
+}
+
+// Transforms the control points through the same pipeline applied to the input points, converting their coordinates
+// from the given srid and correcting their elevation, and reports their residuals versus the expected coordinates,
+// expressed in the expected srid, e.g. 4326 for WGS84 ellipsoidal coordinates or 4978 for ECEF ones
+func Evaluate(points []*ControlPoint, srid int, expectedSrid int, converter converters.CoordinateConverter, corrector converters.ElevationCorrector) (*Report, error) {
+	report := &Report{Srid: srid, ExpectedSrid: expectedSrid}
+	for _, point := range points {
+		residual, err := computeResidual(point, srid, expectedSrid, converter, corrector)
+		if err != nil {
+			return nil, err
+		}
+		report.Points = append(report.Points, residual)
+	}
+	report.Summary = summarize(report.Points)
+	return report, nil
+}
+
+// Returns the report as indented JSON
+func (r *Report) ToJson() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+func computeResidual(point *ControlPoint, srid int, expectedSrid int, converter converters.CoordinateConverter, corrector converters.ElevationCorrector) (*Residual, error) {
+	wgs84Coords, err := converter.ConvertCoordinateSrid(srid, 4326, point.Source)
+	if err != nil {
+		return nil, err
+	}
+	transformed := geometry.Coordinate{
+		X: point.Source.X,
+		Y: point.Source.Y,
+		Z: corrector.CorrectElevation(wgs84Coords.X, wgs84Coords.Y, wgs84Coords.Z),
+	}
+
+	geographic, err := converter.ConvertCoordinateSrid(srid, 4326, transformed)
+	if err != nil {
+		return nil, err
+	}
+	ecef, err := converter.ConvertToWGS84Cartesian(transformed, srid)
+	if err != nil {
+		return nil, err
+	}
+	expectedEcef, err := converter.ConvertToWGS84Cartesian(point.Expected, expectedSrid)
+	if err != nil {
+		return nil, err
+	}
+
+	// residuals are small, so the frame can be oriented using the transformed point longitude and latitude
+	enu := geometry.NewLocalFrame(expectedEcef, geographic.X, geographic.Y).FromEcef(ecef)
+	horizontal := math.Sqrt(enu.X*enu.X + enu.Y*enu.Y)
+
+	return &Residual{
+		Id:         point.Id,
+		Longitude:  geographic.X,
+		Latitude:   geographic.Y,
+		Height:     geographic.Z,
+		East:       enu.X,
+		North:      enu.Y,
+		Up:         enu.Z,
+		Horizontal: horizontal,
+		Distance:   math.Sqrt(horizontal*horizontal + enu.Z*enu.Z),
+	}, nil
+}
+
+func summarize(residuals []*Residual) Summary {
+	summary := Summary{Count: len(residuals)}
+	if len(residuals) == 0 {
+		return summary
+	}
+
+	var sumSquaredHorizontal, sumSquaredVertical float64
+	for _, residual := range residuals {
+		summary.MeanEast += residual.East
+		summary.MeanNorth += residual.North
+		summary.MeanUp += residual.Up
+		sumSquaredHorizontal += residual.Horizontal * residual.Horizontal
+		sumSquaredVertical += residual.Up * residual.Up
+		summary.MaxHorizontal = math.Max(summary.MaxHorizontal, residual.Horizontal)
+		summary.MaxVertical = math.Max(summary.MaxVertical, math.Abs(residual.Up))
+		summary.MaxDistance = math.Max(summary.MaxDistance, residual.Distance)
+	}
+
+	n := float64(len(residuals))
+	summary.MeanEast /= n
+	summary.MeanNorth /= n
+	summary.MeanUp /= n
+	summary.RmseHorizontal = math.Sqrt(sumSquaredHorizontal / n)
+	summary.RmseVertical = math.Sqrt(sumSquaredVertical / n)
+	summary.Rmse = math.Sqrt((sumSquaredHorizontal + sumSquaredVertical) / n)
+	return summary
+}
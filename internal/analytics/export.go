@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"math"
+	"path"
+	"strconv"
+)
+
+// Name of the folder of the Parquet export, written in the tileset folder
+const FolderName = "parquet"
+
+// Name of the Parquet file written in the partition of every tile
+const partFileName = "points.parquet"
+
+// Exports the points of the given built tree as a Parquet dataset partitioned by tile, writing the points of every
+// tile in the tile=<key>/points.parquet file of the given folder, following the Hive partitioning layout read by
+// DuckDB and Spark. The key of a tile is r followed by the octants leading to it from the root, i.e. its Morton
+// path, so that keys sharing a prefix locate nested cells. Every point is written once, in the tile storing it, with
+// its EPSG:4326 coordinates, its level in the tree and the given supplementary attributes, if any.
+func ExportTree(root octree.INode, converter converters.CoordinateConverter, attributeNames []string, folder string, storage storage.Storage, cancellation *cancellation.Token) error {
+	var export func(node octree.INode, key string, level int) error
+	export = func(node octree.INode, key string, level int) error {
+		if err := cancellation.Err(); err != nil {
+			return err
+		}
+		if points := node.GetPoints(); len(points) > 0 {
+			columns, err := getTileColumns(node, converter, attributeNames, level)
+			if err != nil {
+				return err
+			}
+			partition := path.Join(folder, "tile="+key)
+			if err := storage.MkdirAll(partition, 0777); err != nil {
+				return err
+			}
+			if err := storage.WriteFile(path.Join(partition, partFileName), EncodeParquet(columns), 0666); err != nil {
+				return err
+			}
+		}
+		for octant, child := range node.GetChildren() {
+			if child != nil && child.TotalNumberOfPoints() > 0 {
+				if err := export(child, key+strconv.Itoa(octant), level+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return export(root, "r", 0)
+}
+
+// Returns the columns of the parquet table holding the points stored by the given node
+func getTileColumns(node octree.INode, converter converters.CoordinateConverter, attributeNames []string, level int) ([]*Column, error) {
+	points := node.GetPoints()
+	newColumn := func(name string, physicalType int32, optional bool) *Column {
+		return &Column{Name: name, PhysicalType: physicalType, Optional: optional, Values: make([]float64, len(points))}
+	}
+	longitude, latitude, height := newColumn("longitude", TypeDouble, false), newColumn("latitude", TypeDouble, false), newColumn("height", TypeDouble, false)
+	r, g, b := newColumn("r", TypeInt32, false), newColumn("g", TypeInt32, false), newColumn("b", TypeInt32, false)
+	intensity, classification := newColumn("intensity", TypeInt32, false), newColumn("classification", TypeInt32, false)
+	levels := newColumn("level", TypeInt32, false)
+	columns := []*Column{longitude, latitude, height, r, g, b, intensity, classification, levels}
+	attributes := make([]*Column, len(attributeNames))
+	for j, name := range attributeNames {
+		attributes[j] = newColumn(name, TypeFloat, true)
+		columns = append(columns, attributes[j])
+	}
+
+	for i, point := range points {
+		coordinate, err := converter.ConvertCoordinateSrid(node.GetInternalSrid(), 4326, geometry.Coordinate{X: point.X, Y: point.Y, Z: point.Z})
+		if err != nil {
+			return nil, err
+		}
+		longitude.Values[i], latitude.Values[i], height.Values[i] = coordinate.X, coordinate.Y, coordinate.Z
+		r.Values[i], g.Values[i], b.Values[i] = float64(point.R), float64(point.G), float64(point.B)
+		intensity.Values[i], classification.Values[i] = float64(point.Intensity), float64(point.Classification)
+		levels.Values[i] = float64(level)
+		// points without a sidecar record get null attributes
+		for j, column := range attributes {
+			column.Values[i] = math.NaN()
+			if j < len(point.Attributes) {
+				column.Values[i] = float64(point.Attributes[j])
+			}
+		}
+	}
+	return columns, nil
+}
@@ -0,0 +1,185 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/klauspost/compress/snappy"
+	"math"
+)
+
+// Physical types of the Parquet columns
+const (
+	TypeInt32  = 1
+	TypeFloat  = 4
+	TypeDouble = 5
+)
+
+// Parquet identifiers of the plain and RLE encodings and of the snappy codec
+const (
+	encodingPlain = 0
+	encodingRle   = 3
+	codecSnappy   = 1
+)
+
+// Max number of values of a data page, whose sizes and number of values are 32 bit integers in the page headers
+const maxPageValues = 1 << 20
+
+// Column of a Parquet table, whose values are stored as float64 whatever their physical type. The null values of
+// optional columns are NaN.
+type Column struct {
+	Name         string
+	PhysicalType int32
+	Optional     bool
+	Values       []float64
+}
+
+// Encodes the given columns, all holding the same number of values, in a Parquet file with a single row group. Values
+// are plainly encoded in snappy compressed pages of at most maxPageValues values.
+func EncodeParquet(columns []*Column) []byte {
+	numRows := 0
+	if len(columns) > 0 {
+		numRows = len(columns[0].Values)
+	}
+
+	file := bytes.NewBufferString("PAR1")
+	offsets := make([]int64, len(columns))
+	sizes := make([][2]int, len(columns))
+	for i, column := range columns {
+		offsets[i] = int64(file.Len())
+		// the empty columns are written as a single empty page
+		for first := 0; first == 0 || first < numRows; first += maxPageValues {
+			last := first + maxPageValues
+			if last > numRows {
+				last = numRows
+			}
+			values := column.Values[first:last]
+			page := encodePage(column, values)
+			compressed := snappy.Encode(nil, page)
+
+			header := &thriftWriter{}
+			header.begin()
+			header.i32(1, 0)
+			header.i32(2, int32(len(page)))
+			header.i32(3, int32(len(compressed)))
+			header.structField(5)
+			header.i32(1, int32(len(values)))
+			header.i32(2, encodingPlain)
+			header.i32(3, encodingRle)
+			header.i32(4, encodingRle)
+			header.end()
+			header.end()
+
+			file.Write(header.out.Bytes())
+			file.Write(compressed)
+			sizes[i][0] += header.out.Len() + len(page)
+			sizes[i][1] += header.out.Len() + len(compressed)
+		}
+	}
+
+	footer := &thriftWriter{}
+	footer.begin()
+	footer.i32(1, 1)
+	footer.list(2, compactStruct, len(columns)+1)
+	footer.begin()
+	footer.string(4, "schema")
+	footer.i32(5, int32(len(columns)))
+	footer.end()
+	for _, column := range columns {
+		footer.begin()
+		footer.i32(1, column.PhysicalType)
+		if column.Optional {
+			footer.i32(3, 1)
+		} else {
+			footer.i32(3, 0)
+		}
+		footer.string(4, column.Name)
+		footer.end()
+	}
+	footer.i64(3, int64(numRows))
+
+	footer.list(4, compactStruct, 1)
+	footer.begin()
+	footer.list(1, compactStruct, len(columns))
+	totalSize := 0
+	for i, column := range columns {
+		footer.begin()
+		footer.i64(2, offsets[i])
+		footer.structField(3)
+		footer.i32(1, column.PhysicalType)
+		footer.list(2, compactI32, 2)
+		footer.writeInt(encodingPlain)
+		footer.writeInt(encodingRle)
+		footer.list(3, compactBinary, 1)
+		footer.writeString(column.Name)
+		footer.i32(4, codecSnappy)
+		footer.i64(5, int64(numRows))
+		footer.i64(6, int64(sizes[i][0]))
+		footer.i64(7, int64(sizes[i][1]))
+		footer.i64(9, offsets[i])
+		footer.end()
+		footer.end()
+		totalSize += sizes[i][0]
+	}
+	footer.i64(2, int64(totalSize))
+	footer.i64(3, int64(numRows))
+	footer.end()
+	footer.string(6, "gocesiumtiler")
+	footer.end()
+
+	file.Write(footer.out.Bytes())
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(footer.out.Len()))
+	file.Write(length)
+	file.WriteString("PAR1")
+	return file.Bytes()
+}
+
+// Encodes the uncompressed content of a data page of the given values of a column, the definition levels of the
+// optional columns followed by their non null values
+func encodePage(column *Column, values []float64) []byte {
+	page := new(bytes.Buffer)
+	if column.Optional {
+		levels := make([]bool, len(values))
+		for i, value := range values {
+			levels[i] = !math.IsNaN(value)
+		}
+		packed := bitPack(levels)
+		length := make([]byte, 4)
+		binary.LittleEndian.PutUint32(length, uint32(len(packed)))
+		page.Write(length)
+		page.Write(packed)
+	}
+
+	value := make([]byte, 8)
+	for _, v := range values {
+		if column.Optional && math.IsNaN(v) {
+			continue
+		}
+		switch column.PhysicalType {
+		case TypeInt32:
+			binary.LittleEndian.PutUint32(value, uint32(int32(v)))
+			page.Write(value[:4])
+		case TypeFloat:
+			binary.LittleEndian.PutUint32(value, math.Float32bits(float32(v)))
+			page.Write(value[:4])
+		default:
+			binary.LittleEndian.PutUint64(value, math.Float64bits(v))
+			page.Write(value)
+		}
+	}
+	return page.Bytes()
+}
+
+// Encodes the given 1 bit values as a single bit-packed run of the RLE / bit-packing hybrid encoding
+func bitPack(values []bool) []byte {
+	groups := (len(values) + 7) / 8
+	header := make([]byte, binary.MaxVarintLen64)
+	out := header[:binary.PutUvarint(header, uint64(groups<<1|1))]
+	packed := make([]byte, groups)
+	for i, value := range values {
+		if value {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(out, packed...)
+}
@@ -0,0 +1,100 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Types of the fields of the thrift compact protocol
+const (
+	compactTrue   = 1
+	compactFalse  = 2
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// Serializes structs with the thrift compact protocol, in which Parquet stores its metadata. Structs are opened by
+// begin and closed by end, their fields being written in increasing id order.
+type thriftWriter struct {
+	out     bytes.Buffer
+	lastIds []int16
+}
+
+func (w *thriftWriter) begin() {
+	w.lastIds = append(w.lastIds, 0)
+}
+
+func (w *thriftWriter) end() {
+	w.out.WriteByte(0)
+	w.lastIds = w.lastIds[:len(w.lastIds)-1]
+}
+
+// Writes the header of a field of the current struct, whose value has to be written next
+func (w *thriftWriter) field(id int16, fieldType byte) {
+	last := len(w.lastIds) - 1
+	if delta := id - w.lastIds[last]; delta > 0 && delta <= 15 {
+		w.out.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		w.out.WriteByte(fieldType)
+		w.writeInt(int64(id))
+	}
+	w.lastIds[last] = id
+}
+
+func (w *thriftWriter) i32(id int16, value int32) {
+	w.field(id, compactI32)
+	w.writeInt(int64(value))
+}
+
+func (w *thriftWriter) i64(id int16, value int64) {
+	w.field(id, compactI64)
+	w.writeInt(value)
+}
+
+func (w *thriftWriter) bool(id int16, value bool) {
+	if value {
+		w.field(id, compactTrue)
+	} else {
+		w.field(id, compactFalse)
+	}
+}
+
+func (w *thriftWriter) string(id int16, value string) {
+	w.field(id, compactBinary)
+	w.writeString(value)
+}
+
+// Opens a struct field, to be closed by end
+func (w *thriftWriter) structField(id int16) {
+	w.field(id, compactStruct)
+	w.begin()
+}
+
+// Writes the header of a list field, followed by the given number of elements of the given type
+func (w *thriftWriter) list(id int16, elementType byte, size int) {
+	w.field(id, compactList)
+	if size < 15 {
+		w.out.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		w.out.WriteByte(0xf0 | elementType)
+		w.writeVarint(uint64(size))
+	}
+}
+
+// Writes a zigzag encoded integer
+func (w *thriftWriter) writeInt(value int64) {
+	w.writeVarint(uint64(value<<1 ^ value>>63))
+}
+
+func (w *thriftWriter) writeString(value string) {
+	w.writeVarint(uint64(len(value)))
+	w.out.WriteString(value)
+}
+
+func (w *thriftWriter) writeVarint(value uint64) {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	w.out.Write(buffer[:binary.PutUvarint(buffer, value)])
+}
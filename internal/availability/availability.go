@@ -0,0 +1,194 @@
+package availability
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"io"
+	"sort"
+)
+
+// Name of the availability file written in the tileset folder
+const FileName = "availability.bin"
+
+// Magic number opening the availability files
+var magic = []byte("QAVL")
+
+// Version of the availability file format
+const version = 1
+
+// Max number of levels, whose quadkeys fit in 64 bits
+const maxLevels = 32
+
+// Availability of the cells of a quadtree subdividing the 2D extent of the root of a tree, marking at every level the
+// cells whose tiles contain points. Cells are indexed by their quadkey at their level, the base 4 number whose digits
+// are the quadrants containing the cell from the root, each being x + 2y with x growing eastwards and y northwards.
+type Availability struct {
+	Srid   int
+	Bounds [4]float64 // Xmin, Xmax, Ymin, Ymax of the root in the tree srid
+	// sorted quadkeys of the available cells of every level
+	levels [][]uint64
+}
+
+// Computes the availability of the cells of the given built tree, projecting its nodes on the XY plane so that a cell
+// is available if any of the nodes stacked on it contains points
+func Compute(root octree.INode) *Availability {
+	box := root.GetBoundingBox()
+	availability := &Availability{
+		Srid:   root.GetInternalSrid(),
+		Bounds: [4]float64{box.Xmin, box.Xmax, box.Ymin, box.Ymax},
+	}
+	if root.TotalNumberOfPoints() == 0 {
+		return availability
+	}
+
+	var cells []map[uint64]bool
+	var visit func(node octree.INode, level int, quadkey uint64)
+	visit = func(node octree.INode, level int, quadkey uint64) {
+		if len(cells) == level {
+			cells = append(cells, make(map[uint64]bool))
+		}
+		cells[level][quadkey] = true
+		if level+1 == maxLevels {
+			return
+		}
+		for octant, child := range node.GetChildren() {
+			if child != nil && child.TotalNumberOfPoints() > 0 {
+				// the quadrant of an octant is given by its x and y bits
+				visit(child, level+1, quadkey*4+uint64(octant&3))
+			}
+		}
+	}
+	visit(root, 0, 0)
+
+	for _, levelCells := range cells {
+		quadkeys := make([]uint64, 0, len(levelCells))
+		for quadkey := range levelCells {
+			quadkeys = append(quadkeys, quadkey)
+		}
+		sort.Slice(quadkeys, func(i, j int) bool { return quadkeys[i] < quadkeys[j] })
+		availability.levels = append(availability.levels, quadkeys)
+	}
+	return availability
+}
+
+// Returns the number of levels of the quadtree
+func (a *Availability) Levels() int {
+	return len(a.levels)
+}
+
+// Returns the number of available cells at the given level
+func (a *Availability) AvailableCells(level int) int {
+	if level < 0 || level >= len(a.levels) {
+		return 0
+	}
+	return len(a.levels[level])
+}
+
+// Returns true if the cell of the given level in the given column and row, counted from the south west corner of the
+// root, is available
+func (a *Availability) IsAvailable(level int, x uint32, y uint32) bool {
+	if level < 0 || level >= len(a.levels) || x>>uint(level) != 0 || y>>uint(level) != 0 {
+		return false
+	}
+	return a.HasQuadkey(level, toQuadkey(level, x, y))
+}
+
+// Returns true if the cell with the given quadkey at the given level is available
+func (a *Availability) HasQuadkey(level int, quadkey uint64) bool {
+	if level < 0 || level >= len(a.levels) {
+		return false
+	}
+	quadkeys := a.levels[level]
+	i := sort.Search(len(quadkeys), func(i int) bool { return quadkeys[i] >= quadkey })
+	return i < len(quadkeys) && quadkeys[i] == quadkey
+}
+
+// Interleaves the bits of the given column and row into the quadkey of the cell at the given level
+func toQuadkey(level int, x uint32, y uint32) uint64 {
+	var quadkey uint64
+	for bit := level - 1; bit >= 0; bit-- {
+		quadkey = quadkey*4 + uint64((x>>uint(bit))&1) + 2*uint64((y>>uint(bit))&1)
+	}
+	return quadkey
+}
+
+// Encodes the availability in its compact binary form: a header with the magic number, the format version, the
+// number of levels, the srid and the bounds of the root, little endian, followed for every level but the last by 4 bits
+// per available cell, in quadkey order and packed two per byte from the lowest bits, marking its available children
+func (a *Availability) ToBytes() []byte {
+	buffer := bytes.NewBuffer(nil)
+	buffer.Write(magic)
+	buffer.WriteByte(version)
+	_ = binary.Write(buffer, binary.LittleEndian, uint16(len(a.levels)))
+	_ = binary.Write(buffer, binary.LittleEndian, int32(a.Srid))
+	_ = binary.Write(buffer, binary.LittleEndian, a.Bounds)
+
+	for level := 0; level+1 < len(a.levels); level++ {
+		masks := make([]byte, (len(a.levels[level])+1)/2)
+		for i, quadkey := range a.levels[level] {
+			var mask byte
+			for quadrant := uint64(0); quadrant < 4; quadrant++ {
+				if a.HasQuadkey(level+1, quadkey*4+quadrant) {
+					mask |= 1 << quadrant
+				}
+			}
+			masks[i/2] |= mask << uint(4*(i%2))
+		}
+		buffer.Write(masks)
+	}
+	return buffer.Bytes()
+}
+
+// Decodes an availability encoded by ToBytes
+func Parse(data []byte) (*Availability, error) {
+	reader := bytes.NewReader(data)
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil || !bytes.Equal(header[:len(magic)], magic) {
+		return nil, errors.New("not an availability file")
+	}
+	if header[len(magic)] != version {
+		return nil, errors.New("unsupported availability file version")
+	}
+
+	var levels uint16
+	var srid int32
+	availability := &Availability{}
+	if err := binary.Read(reader, binary.LittleEndian, &levels); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &srid); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &availability.Bounds); err != nil {
+		return nil, err
+	}
+	availability.Srid = int(srid)
+	if levels == 0 {
+		return availability, nil
+	}
+	if levels > maxLevels {
+		return nil, errors.New("too many levels in availability file")
+	}
+
+	availability.levels = [][]uint64{{0}}
+	for level := 0; level+1 < int(levels); level++ {
+		cells := availability.levels[level]
+		masks := make([]byte, (len(cells)+1)/2)
+		if _, err := io.ReadFull(reader, masks); err != nil {
+			return nil, errors.New("truncated availability file")
+		}
+		var children []uint64
+		for i, quadkey := range cells {
+			mask := masks[i/2] >> uint(4*(i%2))
+			for quadrant := uint64(0); quadrant < 4; quadrant++ {
+				if mask&(1<<quadrant) != 0 {
+					children = append(children, quadkey*4+quadrant)
+				}
+			}
+		}
+		availability.levels = append(availability.levels, children)
+	}
+	return availability, nil
+}
@@ -0,0 +1,41 @@
+package cancellation
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// Number of iterations of the long loops, e.g. over the points of a file, between two polls of the cancellation
+// token, so that a cancel request takes effect within a few milliseconds
+const CheckInterval = 4096
+
+// Error returned by the stages of a job stopped by a cancel request
+var ErrCancelled = errors.New("job cancelled")
+
+// Cancel request shared by the stages of a tiling job, which poll it inside their long loops. A nil token is never
+// cancelled, so that stages can poll it unconditionally.
+type Token struct {
+	cancelled int32
+}
+
+func NewToken() *Token {
+	return &Token{}
+}
+
+// Requests the cancellation of the job, safe to call from any goroutine and more than once
+func (t *Token) Cancel() {
+	atomic.StoreInt32(&t.cancelled, 1)
+}
+
+// Returns true if the cancellation of the job has been requested
+func (t *Token) IsCancelled() bool {
+	return t != nil && atomic.LoadInt32(&t.cancelled) == 1
+}
+
+// Returns ErrCancelled if the cancellation of the job has been requested, nil otherwise
+func (t *Token) Err() error {
+	if t.IsCancelled() {
+		return ErrCancelled
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package checkpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Name of the checkpoint file written in the output folder
+const FileName = "checkpoint.json"
+
+// An input file whose tileset has been started or completed, identified by its path, size and modification time so
+// that a file changed since is tiled again
+type Entry struct {
+	File      string     `json:"file"`
+	Size      int64      `json:"size"`
+	Modified  time.Time  `json:"modified"`
+	Tileset   string     `json:"tileset"`
+	Started   time.Time  `json:"started"`
+	Completed *time.Time `json:"completed,omitempty"`
+}
+
+// Record of the progress of a tiling job, saved in its output folder before and after every input file so that an
+// interrupted job can be resumed skipping the files already tiled. The job options are fingerprinted, as the files
+// tiled with other options cannot be kept.
+type Checkpoint struct {
+	Options string   `json:"options"`
+	Files   []*Entry `json:"files"`
+	byFile  map[string]*Entry
+}
+
+// Loads the checkpoint stored at the given path, returning an empty checkpoint of the job with the given options if
+// the file does not exist. Returns false if the checkpoint has been saved by a job with other options.
+func Load(storage storage.Storage, filePath string, opts *tiler.TilerOptions) (*Checkpoint, bool, error) {
+	checkpoint := &Checkpoint{Options: Fingerprint(opts), Files: []*Entry{}, byFile: make(map[string]*Entry)}
+	file, err := storage.Open(filePath)
+	if os.IsNotExist(err) {
+		return checkpoint, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = file.Close() }()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, false, err
+	}
+	var saved Checkpoint
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return nil, false, err
+	}
+	if saved.Options != checkpoint.Options {
+		return checkpoint, false, nil
+	}
+	for _, entry := range saved.Files {
+		checkpoint.Files = append(checkpoint.Files, entry)
+		checkpoint.byFile[entry.File] = entry
+	}
+	return checkpoint, true, nil
+}
+
+// Returns the hex encoded SHA-256 hash of the given options, ignoring the ones that do not change the tilesets, e.g.
+// the number of goroutines, so that they can be changed when resuming a job
+func Fingerprint(opts *tiler.TilerOptions) string {
+	fingerprinted := *opts
+	fingerprinted.Resume = false
+	fingerprinted.Silent = false
+	fingerprinted.Tui = false
+	fingerprinted.StallTimeout = 0
+	fingerprinted.ReadWorkers = 0
+	fingerprinted.ConvertWorkers = 0
+	fingerprinted.InsertWorkers = 0
+	fingerprinted.WriteWorkers = 0
+	fingerprinted.WriterConcurrency = 0
+	fingerprinted.MaxOpenFiles = 0
+	content, _ := json.Marshal(&fingerprinted)
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// Returns the entry of the given file if it has been completed and it has not changed since, nil otherwise
+func (c *Checkpoint) GetCompleted(file string, info os.FileInfo) *Entry {
+	entry, ok := c.byFile[file]
+	if !ok || entry.Completed == nil || entry.Size != info.Size() || !entry.Modified.Equal(info.ModTime().UTC()) {
+		return nil
+	}
+	return entry
+}
+
+// Returns the entry of the given file if it has been started but not completed, nil otherwise
+func (c *Checkpoint) GetInterrupted(file string) *Entry {
+	entry, ok := c.byFile[file]
+	if !ok || entry.Completed != nil {
+		return nil
+	}
+	return entry
+}
+
+// Records that the tiling of the given file in the given tileset has started
+func (c *Checkpoint) Start(file string, info os.FileInfo, tileset string) {
+	entry := &Entry{File: file, Size: info.Size(), Modified: info.ModTime().UTC(), Tileset: tileset, Started: time.Now().UTC()}
+	if previous, ok := c.byFile[file]; ok {
+		*previous = *entry
+		return
+	}
+	c.Files = append(c.Files, entry)
+	c.byFile[file] = entry
+}
+
+// Records that the tileset of the given started file is complete
+func (c *Checkpoint) Complete(file string) {
+	if entry, ok := c.byFile[file]; ok {
+		completed := time.Now().UTC()
+		entry.Completed = &completed
+	}
+}
+
+// Writes the checkpoint at the given path
+func (c *Checkpoint) Save(storage storage.Storage, filePath string) error {
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(filePath, content, 0666)
+}
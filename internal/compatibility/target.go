@@ -0,0 +1,163 @@
+package compatibility
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"strconv"
+	"strings"
+)
+
+// Clients the tilesets can be generated for
+const (
+	CesiumJs     = "cesium"
+	CesiumUnreal = "unreal"
+	CesiumUnity  = "unity"
+)
+
+// Other names of the clients, e.g. the one of the plugin rather than of the engine
+var clientAliases = map[string]string{
+	"cesiumjs":      CesiumJs,
+	"unreal-plugin": CesiumUnreal,
+	"cesium-unreal": CesiumUnreal,
+	"unity-plugin":  CesiumUnity,
+	"cesium-unity":  CesiumUnity,
+}
+
+// Release of a client, identified by its major and minor version
+type Target struct {
+	Client string
+	Major  int
+	Minor  int
+}
+
+// Parses a target given as <client>@<version>, e.g. cesium@1.115 or unreal-plugin@2.x. A wildcard or missing minor
+// version stands for the first release of the major version, so that only what all its releases support is used.
+func ParseTarget(value string) (*Target, error) {
+	tokens := strings.Split(strings.ToLower(strings.TrimSpace(value)), "@")
+	if len(tokens) != 2 {
+		return nil, errors.New("target should be written as <client>@<version>, e.g. cesium@1.115")
+	}
+	client := tokens[0]
+	if alias, ok := clientAliases[client]; ok {
+		client = alias
+	}
+	if client != CesiumJs && client != CesiumUnreal && client != CesiumUnity {
+		return nil, errors.New("unknown target client " + tokens[0] + ", supported clients are cesium, unreal and unity")
+	}
+
+	numbers := strings.Split(strings.TrimPrefix(tokens[1], "v"), ".")
+	target := &Target{Client: client}
+	var err error
+	if target.Major, err = strconv.Atoi(numbers[0]); err != nil || target.Major < 0 {
+		return nil, errors.New("invalid target version " + tokens[1])
+	}
+	if len(numbers) > 1 && numbers[1] != "x" && numbers[1] != "*" {
+		if target.Minor, err = strconv.Atoi(numbers[1]); err != nil || target.Minor < 0 {
+			return nil, errors.New("invalid target version " + tokens[1])
+		}
+	}
+	return target, nil
+}
+
+func (t *Target) String() string {
+	return t.Client + "@" + strconv.Itoa(t.Major) + "." + strconv.Itoa(t.Minor)
+}
+
+// Returns true if the target is the given release of its client or a later one
+func (t *Target) isAtLeast(release [2]int) bool {
+	return t.Major > release[0] || t.Major == release[0] && t.Minor >= release[1]
+}
+
+// A feature of the tilesets that not all the clients support, with the first release of every client supporting it
+// and how it is turned off for the clients that do not
+type feature struct {
+	name    string
+	since   map[string][2]int
+	used    func(opts *tiler.TilerOptions) bool
+	disable func(opts *tiler.TilerOptions)
+}
+
+// Point clouds, i.e. the pnts contents, which the clients that do not render them cannot do without
+var pointClouds = feature{
+	name:  "point clouds",
+	since: map[string][2]int{CesiumJs: {1, 0}, CesiumUnreal: {1, 21}, CesiumUnity: {1, 1}},
+}
+
+// Features of the tilesets, from the release notes of the clients. They are checked in order, as disabling the 3D
+// Tiles 1.1 contents also disables the features that only the 1.1 tilesets have.
+var features = []feature{
+	{
+		name:  "3D Tiles 1.1 glb contents with EXT_mesh_features and EXT_structural_metadata",
+		since: map[string][2]int{CesiumJs: {1, 97}, CesiumUnreal: {2, 0}, CesiumUnity: {1, 7}},
+		used: func(opts *tiler.TilerOptions) bool {
+			return opts.TilesetVersion == tiler.TilesetVersion11
+		},
+		disable: func(opts *tiler.TilerOptions) {
+			opts.TilesetVersion = tiler.TilesetVersion10
+			if opts.ContentExtension == ".glb" {
+				opts.ContentExtension = ".pnts"
+			}
+		},
+	},
+	{
+		name:  "implicit tiling",
+		since: map[string][2]int{CesiumJs: {1, 97}, CesiumUnreal: {2, 0}, CesiumUnity: {1, 7}},
+		used: func(opts *tiler.TilerOptions) bool {
+			return opts.ImplicitTiling
+		},
+		disable: func(opts *tiler.TilerOptions) {
+			opts.ImplicitTiling = false
+		},
+	},
+	{
+		name:  "tile metadata",
+		since: map[string][2]int{CesiumJs: {1, 97}, CesiumUnreal: {2, 0}, CesiumUnity: {1, 7}},
+		used: func(opts *tiler.TilerOptions) bool {
+			return opts.TileMetadata
+		},
+		disable: func(opts *tiler.TilerOptions) {
+			opts.TileMetadata = false
+		},
+	},
+	{
+		name:  "3DTILES_draco_point_compression",
+		since: map[string][2]int{CesiumJs: {1, 44}, CesiumUnreal: {1, 21}, CesiumUnity: {1, 1}},
+		used: func(opts *tiler.TilerOptions) bool {
+			return opts.DracoCompression
+		},
+		disable: func(opts *tiler.TilerOptions) {
+			opts.DracoCompression = false
+		},
+	},
+	{
+		name:  "cmpt composite contents",
+		since: map[string][2]int{CesiumJs: {1, 0}, CesiumUnreal: {1, 21}, CesiumUnity: {1, 1}},
+		used: func(opts *tiler.TilerOptions) bool {
+			return opts.BundleThreshold > 0 && opts.TilesetVersion == tiler.TilesetVersion10
+		},
+		disable: func(opts *tiler.TilerOptions) {
+			opts.BundleThreshold = 0
+		},
+	},
+}
+
+// Turns off the features of the given options that the given target does not support, returning a warning for every
+// feature turned off. Returns an error if the target cannot render point clouds at all.
+func Restrict(opts *tiler.TilerOptions, target *Target) ([]string, error) {
+	if !target.isAtLeast(pointClouds.since[target.Client]) {
+		return nil, errors.New(target.String() + " cannot render point clouds, supported since " + getRelease(target.Client, pointClouds))
+	}
+	var warnings []string
+	for _, f := range features {
+		if f.used(opts) && !target.isAtLeast(f.since[target.Client]) {
+			f.disable(opts)
+			warnings = append(warnings, target.String()+" does not support "+f.name+", supported since "+getRelease(target.Client, f)+", disabling it")
+		}
+	}
+	return warnings, nil
+}
+
+func getRelease(client string, f feature) string {
+	release := f.since[client]
+	return client + "@" + strconv.Itoa(release[0]) + "." + strconv.Itoa(release[1])
+}
@@ -0,0 +1,59 @@
+package compression
+
+import (
+	"bytes"
+	"github.com/klauspost/compress/zstd"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Magic number opening every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Decodes the tile contents compressed with zstd, with or without any of the dictionaries it has been created with
+type Decoder struct {
+	decoder *zstd.Decoder
+}
+
+// Creates a decoder able to decode the tile contents compressed with the given dictionaries or without dictionary
+func NewDecoder(dictionaries ...[]byte) (*Decoder, error) {
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderDicts(dictionaries...))
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{decoder: decoder}, nil
+}
+
+// Creates a decoder with all the dictionaries stored in the given folder and its subfolders
+func LoadDecoder(folder string) (*Decoder, error) {
+	var dictionaries [][]byte
+	err := filepath.Walk(folder, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() != DictionaryFileName {
+			return err
+		}
+		dictionary, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		dictionaries = append(dictionaries, dictionary)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoder(dictionaries...)
+}
+
+// Returns true if the given data is compressed with zstd
+func IsCompressed(data []byte) bool {
+	return bytes.HasPrefix(data, zstdMagic)
+}
+
+// Returns the given data decompressed, or unchanged if not compressed with zstd
+func (d *Decoder) Decode(data []byte) ([]byte, error) {
+	if !IsCompressed(data) {
+		return data, nil
+	}
+	return d.decoder.DecodeAll(data, nil)
+}
@@ -0,0 +1,84 @@
+package compression
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Serves the files of a folder decoding the zstd compressed tile contents, so that the tilesets can be loaded by
+// clients unaware of the compression and of the dictionaries
+type Handler struct {
+	files   http.FileSystem
+	decoder *Decoder
+}
+
+// Creates a handler serving the files of the given folder, decoded with the given decoder
+func NewHandler(folder string, decoder *Decoder) *Handler {
+	return &Handler{
+		files:   http.Dir(folder),
+		decoder: decoder,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	file, err := h.files.Open(path.Clean("/" + r.URL.Path))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, err := ioutil.ReadAll(file)
+	if err == nil {
+		content, err = h.decoder.Decode(content)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// tilesets are usually loaded by viewers hosted elsewhere
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", getContentType(info.Name()))
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(content)
+	}
+}
+
+func getContentType(fileName string) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".json":
+		return "application/json"
+	case ".glb":
+		return "model/gltf-binary"
+	case ".gltf":
+		return "model/gltf+json"
+	case ".png":
+		return "image/png"
+	case ".geojson":
+		return "application/geo+json"
+	case ".kml":
+		return "application/vnd.google-earth.kml+xml"
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".js":
+		return "text/javascript; charset=utf-8"
+	case ".css":
+		return "text/css; charset=utf-8"
+	default:
+		return "application/octet-stream"
+	}
+}
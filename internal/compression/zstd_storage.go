@@ -0,0 +1,135 @@
+package compression
+
+import (
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"os"
+	"sync"
+)
+
+// Name of the file storing the dictionary shared by the tiles of a tileset, written in the tileset folder
+const DictionaryFileName = "tiles.dict"
+
+// Number of tile contents sampled to train the dictionary of a tileset
+const dictionarySamples = 64
+
+// Max size of the trained dictionaries, the default of the zstd command line tool
+const dictionarySize = 112640
+
+// Min length of the sequences indexed while training the dictionaries
+const dictionaryHashBytes = 6
+
+// A tile content held until the dictionary it is compressed with is trained
+type pendingFile struct {
+	filePath string
+	data     []byte
+	perm     os.FileMode
+}
+
+// Decorates a storage compressing with zstd the tile content files written to it, leaving any other file untouched.
+// If a dictionary path is given the first tile contents are held in memory and used as samples to train a dictionary,
+// written at that path, that is then used to compress all the tile contents. Flush must be called once all the tiles
+// have been written to train the dictionary and write the held contents if fewer than the samples were written.
+type ZstdStorage struct {
+	storage.Storage
+	isContent      func(filePath string) bool
+	dictionaryPath string
+	encoder        *zstd.Encoder
+	pending        []*pendingFile
+	sync.Mutex
+}
+
+// Wraps the given storage compressing the files whose path is recognized as a tile content by the given function. No
+// dictionary is trained if the dictionary path is empty.
+func NewZstdStorage(inner storage.Storage, isContent func(filePath string) bool, dictionaryPath string) (*ZstdStorage, error) {
+	s := &ZstdStorage{
+		Storage:        inner,
+		isContent:      isContent,
+		dictionaryPath: dictionaryPath,
+	}
+	if dictionaryPath == "" {
+		encoder, err := newEncoder()
+		if err != nil {
+			return nil, err
+		}
+		s.encoder = encoder
+	}
+	return s, nil
+}
+
+func (s *ZstdStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	if !s.isContent(filePath) {
+		return s.Storage.WriteFile(filePath, data, perm)
+	}
+
+	s.Lock()
+	if s.encoder == nil {
+		// the written data is not reused by the consumers so it can be held without copying it
+		s.pending = append(s.pending, &pendingFile{filePath: filePath, data: data, perm: perm})
+		var err error
+		if len(s.pending) >= dictionarySamples {
+			err = s.trainAndWritePending()
+		}
+		s.Unlock()
+		return err
+	}
+	encoder := s.encoder
+	s.Unlock()
+
+	return s.Storage.WriteFile(filePath, encoder.EncodeAll(data, nil), perm)
+}
+
+// Trains the dictionary with the held tile contents, if not yet done, and writes them
+func (s *ZstdStorage) Flush() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.encoder != nil {
+		return nil
+	}
+	return s.trainAndWritePending()
+}
+
+// Trains the dictionary on the held tile contents and writes them compressed. If the samples are not enough to train
+// a dictionary the tiles are compressed without it.
+func (s *ZstdStorage) trainAndWritePending() error {
+	samples := make([][]byte, len(s.pending))
+	for i, file := range s.pending {
+		samples[i] = file.data
+	}
+
+	var err error
+	dictionary, trainErr := trainDictionary(samples)
+	if trainErr != nil {
+		tools.LogOutput("> zstd dictionary not trained, compressing tiles without it:", trainErr.Error())
+		s.encoder, err = newEncoder()
+	} else {
+		if err := s.Storage.WriteFile(s.dictionaryPath, dictionary, 0666); err != nil {
+			return err
+		}
+		s.encoder, err = newEncoder(zstd.WithEncoderDict(dictionary))
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, file := range s.pending {
+		if err := s.Storage.WriteFile(file.filePath, s.encoder.EncodeAll(file.data, nil), file.perm); err != nil {
+			return err
+		}
+	}
+	s.pending = nil
+	return nil
+}
+
+func trainDictionary(samples [][]byte) ([]byte, error) {
+	return dict.BuildZstdDict(samples, dict.Options{
+		MaxDictSize: dictionarySize,
+		HashBytes:   dictionaryHashBytes,
+	})
+}
+
+func newEncoder(opts ...zstd.EOption) (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil, append([]zstd.EOption{zstd.WithEncoderLevel(zstd.SpeedBetterCompression)}, opts...)...)
+}
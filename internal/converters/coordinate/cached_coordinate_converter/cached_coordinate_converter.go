@@ -0,0 +1,138 @@
+package cached_coordinate_converter
+
+import (
+	"container/list"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Srid of the geocentric coordinates, whose conversions depend on all the three axes and are never cached
+const geocentricSrid = 4978
+
+// Number of independently locked parts of the cache, so that concurrent conversions do not contend for a single lock
+const cacheShards = 16
+
+// Key of a cached conversion, made of the srids and of the quantized horizontal source coordinates
+type conversionKey struct {
+	sourceSrid int
+	targetSrid int
+	x          int64
+	y          int64
+}
+
+// Result of a cached conversion: the converted horizontal coordinates and the vertical shift of the conversion
+type conversion struct {
+	key    conversionKey
+	x      float64
+	y      float64
+	zShift float64
+}
+
+// Least recently used conversions of a part of the cache
+type cacheShard struct {
+	capacity     int
+	entries      map[conversionKey]*list.Element
+	recentlyUsed *list.List
+	sync.Mutex
+}
+
+// Decorates a coordinate converter caching the results of the conversions between non geocentric srids, keyed by the
+// horizontal source coordinates quantized to a given step. Points sharing the same horizontal position, like the ones
+// of vertical structures and exact duplicates, are then converted once: the cached horizontal coordinates are reused
+// and the vertical shift of the cached conversion is applied to their own height.
+type CachedCoordinateConverter struct {
+	converters.CoordinateConverter
+	quantum float64
+	shards  [cacheShards]*cacheShard
+	hits    int64
+	misses  int64
+}
+
+// Wraps the given converter with a cache of at most the given number of conversions, keyed by the source coordinates
+// quantized to the given step expressed in units of the source srid
+func NewCachedCoordinateConverter(converter converters.CoordinateConverter, capacity int, quantum float64) *CachedCoordinateConverter {
+	cc := &CachedCoordinateConverter{
+		CoordinateConverter: converter,
+		quantum:             quantum,
+	}
+	shardCapacity := int(math.Max(1, math.Ceil(float64(capacity)/cacheShards)))
+	for i := range cc.shards {
+		cc.shards[i] = &cacheShard{
+			capacity:     shardCapacity,
+			entries:      make(map[conversionKey]*list.Element),
+			recentlyUsed: list.New(),
+		}
+	}
+	return cc
+}
+
+func (cc *CachedCoordinateConverter) ConvertCoordinateSrid(sourceSrid int, targetSrid int, coord geometry.Coordinate) (geometry.Coordinate, error) {
+	if sourceSrid == targetSrid || sourceSrid == geocentricSrid || targetSrid == geocentricSrid {
+		return cc.CoordinateConverter.ConvertCoordinateSrid(sourceSrid, targetSrid, coord)
+	}
+
+	key := conversionKey{
+		sourceSrid: sourceSrid,
+		targetSrid: targetSrid,
+		x:          int64(math.Round(coord.X / cc.quantum)),
+		y:          int64(math.Round(coord.Y / cc.quantum)),
+	}
+	shard := cc.shards[getShardIndex(key)]
+	if cached, ok := shard.get(key); ok {
+		atomic.AddInt64(&cc.hits, 1)
+		return geometry.Coordinate{X: cached.x, Y: cached.y, Z: coord.Z + cached.zShift}, nil
+	}
+
+	atomic.AddInt64(&cc.misses, 1)
+	converted, err := cc.CoordinateConverter.ConvertCoordinateSrid(sourceSrid, targetSrid, coord)
+	if err != nil {
+		return converted, err
+	}
+	shard.put(&conversion{key: key, x: converted.X, y: converted.Y, zShift: converted.Z - coord.Z})
+	return converted, nil
+}
+
+// Returns the fraction of the cacheable conversions served by the cache
+func (cc *CachedCoordinateConverter) HitRate() float64 {
+	hits := atomic.LoadInt64(&cc.hits)
+	total := hits + atomic.LoadInt64(&cc.misses)
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+func getShardIndex(key conversionKey) int {
+	hash := uint64(key.x)*0x9e3779b97f4a7c15 ^ uint64(key.y)*0xc2b2ae3d27d4eb4f
+	return int((hash >> 32) % cacheShards)
+}
+
+func (s *cacheShard) get(key conversionKey) (*conversion, bool) {
+	s.Lock()
+	defer s.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.recentlyUsed.MoveToFront(element)
+	return element.Value.(*conversion), true
+}
+
+func (s *cacheShard) put(entry *conversion) {
+	s.Lock()
+	defer s.Unlock()
+	if element, ok := s.entries[entry.key]; ok {
+		element.Value = entry
+		s.recentlyUsed.MoveToFront(element)
+		return
+	}
+	s.entries[entry.key] = s.recentlyUsed.PushFront(entry)
+	if s.recentlyUsed.Len() > s.capacity {
+		oldest := s.recentlyUsed.Back()
+		s.recentlyUsed.Remove(oldest)
+		delete(s.entries, oldest.Value.(*conversion).key)
+	}
+}
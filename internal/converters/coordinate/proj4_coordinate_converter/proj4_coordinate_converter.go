@@ -21,22 +21,69 @@ type proj4CoordinateConverter struct {
 	EpsgDatabase map[int]*epsgProjection
 }
 
+func init() {
+	converters.RegisterCoordinateConverter(converters.DefaultCoordinateConverterBackend, NewProj4CoordinateConverterWithDefinitions)
+}
+
 func NewProj4CoordinateConverter() converters.CoordinateConverter {
+	cc, err := newProj4CoordinateConverter()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cc
+}
+
+func newProj4CoordinateConverter() (*proj4CoordinateConverter, error) {
 	exPath := tools.GetRootFolder()
 
+	// Initialization of EPSG Proj4 database
+	epsgDatabase, err := loadEPSGProjectionDatabase(path.Join(exPath, "assets", "epsg_projections.txt"))
+	if err != nil {
+		return nil, err
+	}
+
 	// Set path for retrieving projection assets data
 	proj.SetFinder([]string{path.Join(exPath, "assets", "share")})
 
-	// Initialization of EPSG Proj4 database
-	file := path.Join(exPath, "assets", "epsg_projections.txt")
-
 	return &proj4CoordinateConverter{
-		EpsgDatabase: *loadEPSGProjectionDatabase(file),
+		EpsgDatabase: *epsgDatabase,
+	}, nil
+}
+
+// Instantiates a converter whose EPSG database is extended with the given PROJ.4 definitions, keyed by srid. Returns an
+// error if a definition is not a PROJ.4 string, e.g. a WKT, or cannot be initialized by PROJ.4.
+func NewProj4CoordinateConverterWithDefinitions(definitions map[int]string) (converters.CoordinateConverter, error) {
+	cc, err := newProj4CoordinateConverter()
+	if err != nil {
+		return nil, err
+	}
+	for code, definition := range definitions {
+		definition = strings.TrimSpace(definition)
+		if !strings.HasPrefix(definition, "+") {
+			return nil, errors.New("the proj4 backend only supports PROJ.4 definitions, e.g. +proj=utm +zone=33 +datum=WGS84, WKT and compound coordinate systems require the proj backend")
+		}
+		projection, err := proj.InitPlus(definition)
+		if err != nil {
+			return nil, errors.New("invalid PROJ.4 definition " + definition + ": " + err.Error())
+		}
+		if previous, ok := cc.EpsgDatabase[code]; ok && previous.Projection != nil {
+			previous.Projection.Close()
+		}
+		cc.EpsgDatabase[code] = &epsgProjection{
+			EpsgCode:    code,
+			Description: "custom",
+			Proj4:       definition,
+			Projection:  projection,
+		}
 	}
+	return cc, nil
 }
 
-func loadEPSGProjectionDatabase(databasePath string) *map[int]*epsgProjection {
-	file := tools.OpenFileOrFail(databasePath)
+func loadEPSGProjectionDatabase(databasePath string) (*map[int]*epsgProjection, error) {
+	file, err := converters.OpenResource("EPSG projection database", databasePath, converters.AssetsHint)
+	if err != nil {
+		return nil, err
+	}
 	defer func() { _ = file.Close() }()
 
 	var epsgDatabase = make(map[int]*epsgProjection)
@@ -50,10 +97,10 @@ func loadEPSGProjectionDatabase(databasePath string) *map[int]*epsgProjection {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+		return nil, &converters.ResourceError{Resource: "EPSG projection database", Path: databasePath, Hint: converters.AssetsHint, Err: err}
 	}
 
-	return &epsgDatabase
+	return &epsgDatabase, nil
 }
 
 func parseEPSGProjectionDatabaseRecord(databaseRecord string) (int, *epsgProjection) {
@@ -0,0 +1,8 @@
+// Package proj_coordinate_converter implements a coordinate converter backend on the PROJ library, version 6.2 or
+// later, which accepts any coordinate reference system known by the PROJ database or described by a WKT, PROJJSON or
+// PROJ string, including compound systems whose vertical component converts the heights along with the coordinates.
+//
+// The backend links the system PROJ library through cgo, hence it is only compiled with the proj build tag, e.g.
+// go build -tags proj, and registers itself as the proj coordinate converter backend. Builds without the tag do not
+// depend on the library and only provide the bundled proj4 backend.
+package proj_coordinate_converter
@@ -0,0 +1,202 @@
+//go:build proj
+// +build proj
+
+package proj_coordinate_converter
+
+/*
+#cgo LDFLAGS: -lproj
+#include <math.h>
+#include <stdlib.h>
+#include <proj.h>
+
+// Transforms the given coordinates in place, returning the PROJ error code of the transformation, 0 if none
+static int transform(PJ *transformation, double *x, double *y, double *z) {
+	PJ_COORD coordinate = proj_coord(*x, *y, *z, HUGE_VAL);
+	proj_errno_reset(transformation);
+	coordinate = proj_trans(transformation, PJ_FWD, coordinate);
+	*x = coordinate.xyz.x;
+	*y = coordinate.xyz.y;
+	*z = coordinate.xyz.z;
+	return proj_errno(transformation);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"strconv"
+	"sync"
+	"unsafe"
+)
+
+// Name the backend is registered with
+const BackendName = "proj"
+
+const toRadians = math.Pi / 180
+
+// Srids of the WGS84 geographic and geocentric coordinates the converted points are expressed in
+const (
+	wgs84Srid           = 4326
+	wgs84GeographicSrid = 4979
+	geocentricSrid      = 4978
+)
+
+// Source and target srid of a transformation
+type transformationKey struct {
+	sourceSrid int
+	targetSrid int
+}
+
+// Coordinate converter transforming the coordinates with the PROJ library. The transformations between every pair of
+// srids are created on first use and kept until the converter is cleaned up. PROJ objects cannot be shared by
+// concurrent conversions, hence the conversions are serialized.
+type projCoordinateConverter struct {
+	context         *C.PJ_CONTEXT
+	definitions     map[int]string
+	transformations map[transformationKey]*C.PJ
+	sync.Mutex
+}
+
+func init() {
+	converters.RegisterCoordinateConverter(BackendName, NewProjCoordinateConverter)
+}
+
+// Instantiates a converter using the given definitions, keyed by srid, in place of the EPSG codes of the PROJ
+// database. A definition can be anything accepted by PROJ, e.g. a WKT, a PROJJSON, a PROJ string or a compound code
+// like EPSG:32633+5773. Returns an error if a definition is not understood by PROJ.
+func NewProjCoordinateConverter(definitions map[int]string) (converters.CoordinateConverter, error) {
+	cc := &projCoordinateConverter{
+		context:         C.proj_context_create(),
+		definitions:     make(map[int]string),
+		transformations: make(map[transformationKey]*C.PJ),
+	}
+	for code, definition := range definitions {
+		crs, err := cc.createCrs(definition)
+		if err != nil {
+			cc.Cleanup()
+			return nil, err
+		}
+		C.proj_destroy(crs)
+		cc.definitions[code] = definition
+	}
+	return cc, nil
+}
+
+// Converts the given coordinate from the given source Srid to the given target srid
+func (cc *projCoordinateConverter) ConvertCoordinateSrid(sourceSrid int, targetSrid int, coord geometry.Coordinate) (geometry.Coordinate, error) {
+	if sourceSrid == targetSrid {
+		return coord, nil
+	}
+
+	cc.Lock()
+	defer cc.Unlock()
+	transformation, err := cc.getTransformation(sourceSrid, targetSrid)
+	if err != nil {
+		return coord, err
+	}
+	x, y, z := C.double(coord.X), C.double(coord.Y), C.double(coord.Z)
+	if code := C.transform(transformation, &x, &y, &z); code != 0 {
+		return coord, errors.New("unable to convert the coordinate from srid " + strconv.Itoa(sourceSrid) + " to srid " + strconv.Itoa(targetSrid) + ": " + C.GoString(C.proj_errno_string(code)))
+	}
+	return geometry.Coordinate{X: float64(x), Y: float64(y), Z: float64(z)}, nil
+}
+
+// Converts the generic bounding box bounds values from the given input srid to a EPSG:4326 srid (in radians).
+// Z values are left unchanged
+func (cc *projCoordinateConverter) Convert2DBoundingboxToWGS84Region(bbox *geometry.BoundingBox, srid int) (*geometry.BoundingBox, error) {
+	lowerCorner, err := cc.ConvertCoordinateSrid(srid, wgs84Srid, geometry.Coordinate{X: bbox.Xmin, Y: bbox.Ymin})
+	if err != nil {
+		return nil, err
+	}
+	upperCorner, err := cc.ConvertCoordinateSrid(srid, wgs84Srid, geometry.Coordinate{X: bbox.Xmax, Y: bbox.Ymax})
+	if err != nil {
+		return nil, err
+	}
+
+	return geometry.NewBoundingBox(lowerCorner.X*toRadians, lowerCorner.Y*toRadians, upperCorner.X*toRadians, upperCorner.Y*toRadians, bbox.Zmin, bbox.Zmax), nil
+}
+
+// Converts the input coordinate from the given srid to EPSG:4978 srid
+func (cc *projCoordinateConverter) ConvertToWGS84Cartesian(coord geometry.Coordinate, sourceSrid int) (geometry.Coordinate, error) {
+	return cc.ConvertCoordinateSrid(sourceSrid, geocentricSrid, coord)
+}
+
+// Releases all the PROJ objects of the converter
+func (cc *projCoordinateConverter) Cleanup() {
+	cc.Lock()
+	defer cc.Unlock()
+	for key, transformation := range cc.transformations {
+		C.proj_destroy(transformation)
+		delete(cc.transformations, key)
+	}
+	if cc.context != nil {
+		C.proj_context_destroy(cc.context)
+		cc.context = nil
+	}
+}
+
+// Returns the transformation between the given srids, creating it on first use. Coordinates in longitude and latitude
+// order for the geographic systems, whatever their axis order in the PROJ database.
+func (cc *projCoordinateConverter) getTransformation(sourceSrid int, targetSrid int) (*C.PJ, error) {
+	key := transformationKey{sourceSrid: sourceSrid, targetSrid: targetSrid}
+	if transformation, ok := cc.transformations[key]; ok {
+		return transformation, nil
+	}
+	if cc.context == nil {
+		return nil, errors.New("the converter has been cleaned up")
+	}
+
+	source, err := cc.createCrs(cc.getDefinition(sourceSrid))
+	if err != nil {
+		return nil, err
+	}
+	defer C.proj_destroy(source)
+	target, err := cc.createCrs(cc.getDefinition(targetSrid))
+	if err != nil {
+		return nil, err
+	}
+	defer C.proj_destroy(target)
+
+	transformation := C.proj_create_crs_to_crs_from_pj(cc.context, source, target, nil, nil)
+	if transformation == nil {
+		return nil, errors.New("unable to create the transformation from srid " + strconv.Itoa(sourceSrid) + " to srid " + strconv.Itoa(targetSrid) + ": " + cc.getError())
+	}
+	normalized := C.proj_normalize_for_visualization(cc.context, transformation)
+	C.proj_destroy(transformation)
+	if normalized == nil {
+		return nil, errors.New("unable to normalize the transformation from srid " + strconv.Itoa(sourceSrid) + " to srid " + strconv.Itoa(targetSrid) + ": " + cc.getError())
+	}
+	cc.transformations[key] = normalized
+	return normalized, nil
+}
+
+// Returns the definition of the given srid. EPSG:4326 is replaced by its 3D counterpart, so that the heights of the
+// compound systems are converted to the ellipsoidal heights expected in WGS84 coordinates.
+func (cc *projCoordinateConverter) getDefinition(srid int) string {
+	if definition, ok := cc.definitions[srid]; ok {
+		return definition
+	}
+	if srid == wgs84Srid {
+		srid = wgs84GeographicSrid
+	}
+	return "EPSG:" + strconv.Itoa(srid)
+}
+
+// Creates the coordinate reference system of the given definition, to be destroyed by the caller
+func (cc *projCoordinateConverter) createCrs(definition string) (*C.PJ, error) {
+	cDefinition := C.CString(definition)
+	defer C.free(unsafe.Pointer(cDefinition))
+	crs := C.proj_create(cc.context, cDefinition)
+	if crs == nil {
+		return nil, errors.New("invalid coordinate reference system " + definition + ": " + cc.getError())
+	}
+	return crs, nil
+}
+
+// Returns the message of the last error of the PROJ context
+func (cc *projCoordinateConverter) getError() string {
+	return C.GoString(C.proj_errno_string(C.proj_context_errno(cc.context)))
+}
@@ -1,7 +1,11 @@
 package converters
 
 import (
+	"errors"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type CoordinateConverter interface {
@@ -10,3 +14,50 @@ type CoordinateConverter interface {
 	ConvertToWGS84Cartesian(coord geometry.Coordinate, sourceSrid int) (geometry.Coordinate, error)
 	Cleanup()
 }
+
+// Name of the coordinate converter backend used when none is given, the bundled PROJ.4 library
+const DefaultCoordinateConverterBackend = "proj4"
+
+// Instantiates a coordinate converter backend. The given definitions, keyed by srid, override or add to the ones of
+// the EPSG codes known by the backend, e.g. to describe a custom or compound coordinate reference system.
+type CoordinateConverterFactory func(definitions map[int]string) (CoordinateConverter, error)
+
+var coordinateConverterBackends = struct {
+	factories map[string]CoordinateConverterFactory
+	sync.RWMutex
+}{factories: make(map[string]CoordinateConverterFactory)}
+
+// Makes the coordinate converter backend instantiated by the given factory available under the given name. Backends
+// register themselves when their package is imported, hence optional backends are only available in the builds
+// compiling them.
+func RegisterCoordinateConverter(name string, factory CoordinateConverterFactory) {
+	coordinateConverterBackends.Lock()
+	defer coordinateConverterBackends.Unlock()
+	coordinateConverterBackends.factories[strings.ToLower(name)] = factory
+}
+
+// Instantiates the coordinate converter backend registered with the given name, the default one if empty
+func NewCoordinateConverter(name string, definitions map[int]string) (CoordinateConverter, error) {
+	if name == "" {
+		name = DefaultCoordinateConverterBackend
+	}
+	coordinateConverterBackends.RLock()
+	factory, ok := coordinateConverterBackends.factories[strings.ToLower(name)]
+	coordinateConverterBackends.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown coordinate converter backend " + name + ", available backends: " + strings.Join(GetCoordinateConverterBackends(), ", "))
+	}
+	return factory(definitions)
+}
+
+// Returns the sorted names of the registered coordinate converter backends
+func GetCoordinateConverterBackends() []string {
+	coordinateConverterBackends.RLock()
+	defer coordinateConverterBackends.RUnlock()
+	var names []string
+	for name := range coordinateConverterBackends.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
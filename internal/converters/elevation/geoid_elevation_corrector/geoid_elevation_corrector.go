@@ -23,6 +23,15 @@ func NewGeoidElevationCorrector(srid int, ellipsoidToGeoidOffsetCalculator conve
 	}
 }
 
+// Instantiates a corrector computing the offset at every point, suitable for the offset calculators that are cheap to
+// evaluate, like the geoid grids
+func NewPointwiseGeoidElevationCorrector(srid int, ellipsoidToGeoidOffsetCalculator converters.EllipsoidToGeoidOffsetCalculator) converters.ElevationCorrector {
+	return &GeoidElevationCorrector{
+		srid:             srid,
+		offsetCalculator: ellipsoidToGeoidOffsetCalculator,
+	}
+}
+
 func (c *GeoidElevationCorrector) CorrectElevation(lon, lat, z float64) float64 {
 	zfix, err := c.offsetCalculator.GetEllipsoidToGeoidOffset(lon, lat, c.srid)
 	if err != nil {
@@ -0,0 +1,25 @@
+package raster_elevation_corrector
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/terrain"
+)
+
+// Corrects the elevations by the values of a grid of corrections in EPSG:4326 coordinates, e.g. a local datum
+// adjustment. Points outside of the grid or on its no data cells are left unchanged.
+type RasterElevationCorrector struct {
+	corrections *terrain.Dem
+}
+
+func NewRasterElevationCorrector(corrections *terrain.Dem) converters.ElevationCorrector {
+	return &RasterElevationCorrector{
+		corrections: corrections,
+	}
+}
+
+func (c *RasterElevationCorrector) CorrectElevation(lon, lat, z float64) float64 {
+	if correction, ok := c.corrections.HeightAt(lon, lat); ok {
+		return z + correction
+	}
+	return z
+}
@@ -2,10 +2,10 @@ package gh_offset_calculator
 
 import (
 	"bufio"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
 	"log"
 	"math"
-	"os"
 	"path"
 	"strconv"
 	"strings"
@@ -18,6 +18,9 @@ const sqrt17 = 4.1231056256176605498214098559741
 const sqrt21 = 4.5825756949558400065880471937280
 const defaultOrder = 180
 
+// Description of the coefficients file of the gravitational model in the errors reading it
+const gravitationalModelResource = "EGM84 gravitational model coefficients"
+
 type egm struct {
 	wgs84                    bool
 	nmax                     int
@@ -64,25 +67,34 @@ func newEarthGraviationalModel(nmax int, wgs84 bool) *egm {
 	model.snmGeopCoef = make([]float64, geopCoefLength)
 	model.as = make([]float64, nmax+1)
 
-	exPath := tools.GetRootFolder()
-
 	// Loading Earth Gravitational Model data
-	err := model.load(path.Join(exPath, "assets", "egm180.nor"))
+	err := model.load(GetGravitationalModelPath())
 	if err != nil {
-		log.Fatal("error loading gravitational model data", err)
+		log.Fatal("error loading gravitational model data: ", err)
 	}
 
 	return &model
 }
 
+// Returns the path of the spherical harmonic coefficients of the gravitational model, in the assets folder
+func GetGravitationalModelPath() string {
+	return path.Join(tools.GetRootFolder(), "assets", "egm180.nor")
+}
+
+// Checks that the coefficients of the gravitational model can be read, returning an error describing where they are
+// expected otherwise
+func CheckGravitationalModel() error {
+	return converters.CheckResource(gravitationalModelResource, GetGravitationalModelPath(), converters.AssetsHint)
+}
+
 func locatingArray(n int) int {
 	return ((n + 1) * n) >> 1
 }
 
 func (egm *egm) load(filename string) error {
-	file, err := os.Open(filename)
+	file, err := converters.OpenResource(gravitationalModelResource, filename, converters.AssetsHint)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer file.Close()
 	scanner := bufio.NewScanner(file)
@@ -122,7 +134,7 @@ func (egm *egm) load(filename string) error {
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+		return &converters.ResourceError{Resource: gravitationalModelResource, Path: filename, Hint: converters.AssetsHint, Err: err}
 	}
 	egm.initialize()
 	return nil
@@ -0,0 +1,157 @@
+package grid_offset_calculator
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Undulations below this value mark the cells without data of the GTX grids
+const gtxNoData = -88
+
+// Size of the headers of the GTX and NGS binary grids
+const (
+	gtxHeaderSize = 40
+	ngsHeaderSize = 44
+)
+
+// A regular grid of geoid undulations, i.e. the heights of the geoid above the ellipsoid, in EPSG:4326 coordinates.
+// The undulations are stored by row from south to north, every row from west to east, and refer to the centers of the
+// cells.
+type geoidGrid struct {
+	lonMin      float64
+	latMin      float64
+	lonStep     float64
+	latStep     float64
+	columns     int
+	rows        int
+	undulations []float32
+}
+
+// Loads the geoid grid stored at the given path, either a GTX grid (.gtx), as distributed by PROJ, or an NGS binary
+// grid (.bin), as distributed by the US National Geodetic Survey. The given hint tells how to provide the grid if it
+// cannot be read.
+func loadGeoidGrid(filePath string, hint string) (*geoidGrid, error) {
+	content, err := converters.ReadResource(geoidGridResource, filePath, hint)
+	if err != nil {
+		return nil, err
+	}
+	var grid *geoidGrid
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".gtx":
+		grid, err = parseGtxGrid(content)
+	case ".bin":
+		grid, err = parseNgsGrid(content)
+	default:
+		return nil, errors.New("unsupported geoid grid " + filePath + ", grids should be GTX (.gtx) or NGS binary (.bin) files")
+	}
+	if err != nil {
+		return nil, errors.New(err.Error() + " in " + filePath)
+	}
+	return grid, nil
+}
+
+// Parses a GTX grid, made of a big endian header holding the latitude and longitude of the south west cell, the
+// latitude and longitude steps and the number of rows and columns, followed by the big endian float32 undulations
+func parseGtxGrid(content []byte) (*geoidGrid, error) {
+	if len(content) < gtxHeaderSize {
+		return nil, errors.New("truncated gtx grid header")
+	}
+	order := binary.BigEndian
+	grid := &geoidGrid{
+		latMin:  math.Float64frombits(order.Uint64(content[0:8])),
+		lonMin:  math.Float64frombits(order.Uint64(content[8:16])),
+		latStep: math.Float64frombits(order.Uint64(content[16:24])),
+		lonStep: math.Float64frombits(order.Uint64(content[24:32])),
+		rows:    int(int32(order.Uint32(content[32:36]))),
+		columns: int(int32(order.Uint32(content[36:40]))),
+	}
+	return grid, grid.readUndulations(content[gtxHeaderSize:], order)
+}
+
+// Parses an NGS binary grid, made of a header holding the latitude and longitude of the south west cell, the latitude
+// and longitude steps, the number of rows and columns and the kind of the values, 1 for float32, followed by the float32
+// undulations. The byte order of the file is detected from the kind of the values.
+func parseNgsGrid(content []byte) (*geoidGrid, error) {
+	if len(content) < ngsHeaderSize {
+		return nil, errors.New("truncated ngs grid header")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if int32(order.Uint32(content[40:44])) != 1 {
+		order = binary.BigEndian
+	}
+	if int32(order.Uint32(content[40:44])) != 1 {
+		return nil, errors.New("unsupported ngs grid values, only float32 grids are supported")
+	}
+	grid := &geoidGrid{
+		latMin:  math.Float64frombits(order.Uint64(content[0:8])),
+		lonMin:  math.Float64frombits(order.Uint64(content[8:16])),
+		latStep: math.Float64frombits(order.Uint64(content[16:24])),
+		lonStep: math.Float64frombits(order.Uint64(content[24:32])),
+		rows:    int(int32(order.Uint32(content[32:36]))),
+		columns: int(int32(order.Uint32(content[36:40]))),
+	}
+	return grid, grid.readUndulations(content[ngsHeaderSize:], order)
+}
+
+func (g *geoidGrid) readUndulations(content []byte, order binary.ByteOrder) error {
+	if g.rows <= 0 || g.columns <= 0 || g.latStep <= 0 || g.lonStep <= 0 {
+		return errors.New("invalid geoid grid size")
+	}
+	if len(content) < 4*g.rows*g.columns {
+		return errors.New("the number of undulations does not match the grid size")
+	}
+	g.undulations = make([]float32, g.rows*g.columns)
+	for i := range g.undulations {
+		g.undulations[i] = math.Float32frombits(order.Uint32(content[4*i:]))
+	}
+	return nil
+}
+
+// Returns true if the columns of the grid span the whole globe, the last column being followed by the first one
+func (g *geoidGrid) isGlobal() bool {
+	return float64(g.columns)*g.lonStep >= 360-g.lonStep/2
+}
+
+// Returns the undulation at the given EPSG:4326 point bilinearly interpolated between the four closest cells, and
+// false if the point is outside of the grid or any of the cells has no data
+func (g *geoidGrid) undulationAt(lon float64, lat float64) (float64, bool) {
+	// grids may use either the -180..180 or the 0..360 longitude range
+	x := math.Mod(lon-g.lonMin, 360)
+	if x < 0 {
+		x += 360
+	}
+	x /= g.lonStep
+	y := (lat - g.latMin) / g.latStep
+
+	col, row := int(math.Floor(x)), int(math.Floor(y))
+	nextCol, nextRow := col+1, row+1
+	if g.isGlobal() {
+		col, nextCol = col%g.columns, nextCol%g.columns
+	} else if col == g.columns-1 && x-float64(col) < 1e-9 {
+		nextCol = col
+	}
+	if row == g.rows-1 && y-float64(row) < 1e-9 {
+		nextRow = row
+	}
+	if row < 0 || nextRow >= g.rows || col < 0 || nextCol >= g.columns {
+		return 0, false
+	}
+
+	corners := [4]float32{
+		g.undulations[row*g.columns+col], g.undulations[row*g.columns+nextCol],
+		g.undulations[nextRow*g.columns+col], g.undulations[nextRow*g.columns+nextCol],
+	}
+	for _, corner := range corners {
+		if corner < gtxNoData || math.IsNaN(float64(corner)) {
+			return 0, false
+		}
+	}
+	u, v := x-math.Floor(x), y-math.Floor(y)
+	south := float64(corners[0])*(1-u) + float64(corners[1])*u
+	north := float64(corners[2])*(1-u) + float64(corners[3])*u
+	return south*(1-v) + north*v, true
+}
@@ -0,0 +1,90 @@
+package grid_offset_calculator
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Description of the geoid grids in the errors reading them
+const geoidGridResource = "geoid grid"
+
+// Name of the geoid model computed from the bundled spherical harmonic coefficients of the WGS84 Earth Gravitational
+// Model of 1984, used when no other model is given
+const DefaultGeoidModel = "EGM84"
+
+// Files of the grids of the named geoid models, searched in the assets/geoids folder, i.e. the EGM96 15' and EGM2008
+// 2.5' grids distributed by PROJ and the GEOID18 grid of the conterminous United States distributed by the NGS
+var ModelGrids = map[string]string{
+	"EGM96":   "egm96_15.gtx",
+	"EGM2008": "egm08_25.gtx",
+	"GEOID18": "g2018u0.bin",
+}
+
+// Returns true if the given geoid model is the default one computed from the bundled coefficients
+func IsDefaultGeoidModel(model string) bool {
+	return model == "" || strings.EqualFold(model, DefaultGeoidModel)
+}
+
+// Returns the path of the grid of the given geoid model, either one of the named models of ModelGrids, whose grids
+// are looked for in the assets/geoids folder, or the path of a geoid grid file
+func GetModelGridPath(model string) string {
+	if file, ok := ModelGrids[strings.ToUpper(model)]; ok {
+		return path.Join(tools.GetRootFolder(), "assets", "geoids", file)
+	}
+	return model
+}
+
+// Checks that the grid of the given geoid model can be read, returning an error describing where it is expected and
+// how to provide it otherwise
+func CheckModelGrid(model string) error {
+	return converters.CheckResource(geoidGridResource, GetModelGridPath(model), getModelGridHint(model))
+}
+
+// Returns how to provide the grid of the given geoid model if it cannot be read
+func getModelGridHint(model string) string {
+	if file, ok := ModelGrids[strings.ToUpper(model)]; ok {
+		return "copy the " + file + " grid into the assets/geoids folder or give the path of a grid file as geoid model, " + converters.AssetsHint
+	}
+	return "the geoid model should be one of EGM84, EGM96, EGM2008 or GEOID18 or the path of a GTX (.gtx) or NGS binary (.bin) grid"
+}
+
+// Computes the ellipsoid to geoid offset by bilinear interpolation of a grid of geoid undulations. Unlike the
+// spherical harmonic model, the offset is cheap enough to be computed at every point.
+type GridOffsetCalculator struct {
+	grid                *geoidGrid
+	coordinateConverter converters.CoordinateConverter
+}
+
+// Loads the grid of the given geoid model, either a named model or the path of a GTX or NGS binary grid file
+func NewGridOffsetCalculator(model string, coordinateConverter converters.CoordinateConverter) (converters.EllipsoidToGeoidOffsetCalculator, error) {
+	grid, err := loadGeoidGrid(GetModelGridPath(model), getModelGridHint(model))
+	if err != nil {
+		return nil, err
+	}
+	return &GridOffsetCalculator{
+		grid:                grid,
+		coordinateConverter: coordinateConverter,
+	}, nil
+}
+
+func (gc *GridOffsetCalculator) GetEllipsoidToGeoidOffset(lon, lat float64, sourceSrid int) (float64, error) {
+	if sourceSrid != 4326 {
+		coordinateInEPSG4326, err := gc.coordinateConverter.ConvertCoordinateSrid(sourceSrid, 4326, geometry.Coordinate{X: lon, Y: lat, Z: math.NaN()})
+		if err != nil {
+			return 0, err
+		}
+		lon, lat = coordinateInEPSG4326.X, coordinateInEPSG4326.Y
+	}
+
+	undulation, ok := gc.grid.undulationAt(lon, lat)
+	if !ok {
+		return 0, errors.New("the point " + strconv.FormatFloat(lon, 'f', -1, 64) + ", " + strconv.FormatFloat(lat, 'f', -1, 64) + " is outside of the geoid grid")
+	}
+	return undulation, nil
+}
@@ -0,0 +1,126 @@
+package converters
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Number of attempts to read a resource file of the converters, retried after a delay doubling at every transient
+// failure, e.g. of a network file system
+const resourceAttempts = 3
+
+// Delay before the first retry of a resource file failed to read
+const resourceRetryDelay = 100 * time.Millisecond
+
+// Hint of the errors reading the files of the assets folder
+const AssetsHint = "the assets folder is looked for next to the executable, set the GOCESIUMTILER_WORKDIR environment variable to the folder containing it to override its location"
+
+// Error reading a resource file of the converters, describing the resource, the path where it was expected and how to
+// provide it
+type ResourceError struct {
+	Resource string
+	Path     string
+	Hint     string
+	Err      error
+}
+
+func (e *ResourceError) Error() string {
+	cause := e.Err
+	if pathError, ok := cause.(*os.PathError); ok {
+		// the path is already part of the message
+		cause = pathError.Err
+	}
+	message := "cannot read the " + e.Resource + " expected at " + e.Path + ": " + cause.Error()
+	if e.Hint != "" {
+		message += ", " + e.Hint
+	}
+	return message
+}
+
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// Returns true if the given file system error may not occur again if retried, e.g. an interrupted call or an i/o
+// error of a network file system, as opposed to a missing file or a denied permission
+func IsTransientError(err error) bool {
+	for _, transient := range []error{syscall.EINTR, syscall.EAGAIN, syscall.EBUSY, syscall.EIO, syscall.ESTALE, syscall.ETIMEDOUT, syscall.EMFILE, syscall.ENFILE} {
+		if errors.Is(err, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// Opens the given resource file, retrying the transient failures. Returns a ResourceError with the given hint if it
+// cannot be opened.
+func OpenResource(resource string, filePath string, hint string) (*os.File, error) {
+	var file *os.File
+	err := retryResource(resource, filePath, hint, func() (err error) {
+		file, err = os.Open(filePath)
+		return err
+	})
+	return file, err
+}
+
+// Reads the given resource file, retrying the transient failures. Returns a ResourceError with the given hint if it
+// cannot be read.
+func ReadResource(resource string, filePath string, hint string) ([]byte, error) {
+	var content []byte
+	err := retryResource(resource, filePath, hint, func() (err error) {
+		content, err = ioutil.ReadFile(filePath)
+		return err
+	})
+	return content, err
+}
+
+// Checks that the given resource file can be opened and is not empty, so that a missing resource is reported before
+// the job starts rather than when the first point is converted
+func CheckResource(resource string, filePath string, hint string) error {
+	file, err := OpenResource(resource, filePath, hint)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err == nil && info.IsDir() {
+		err = errors.New("it is a directory")
+	} else if err == nil && info.Size() == 0 {
+		err = errors.New("the file is empty")
+	}
+	if err != nil {
+		return &ResourceError{Resource: resource, Path: filePath, Hint: hint, Err: err}
+	}
+	return nil
+}
+
+// Receives the messages logged by the package, routed to tools.LogOutput by the tools package, which the package
+// cannot import, so that they follow -silent and the log listener
+var logOutput = func(message string) { log.Println(message) }
+
+// Sets the function receiving the messages logged by the package
+func SetLogOutput(output func(message string)) {
+	logOutput = output
+}
+
+// Runs the given read of the given resource file, retrying the transient failures with an exponential backoff
+func retryResource(resource string, filePath string, hint string, read func() error) error {
+	delay := resourceRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := read()
+		if err == nil {
+			return nil
+		}
+		if !IsTransientError(err) || attempt == resourceAttempts {
+			return &ResourceError{Resource: resource, Path: filePath, Hint: hint, Err: err}
+		}
+		logOutput(fmt.Sprintf("Retrying to read the %s after error: %s", resource, err))
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
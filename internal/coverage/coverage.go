@@ -0,0 +1,108 @@
+package coverage
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+)
+
+// A closed sequence of longitude, latitude pairs in degrees, whose last position repeats the first one
+type Ring [][2]float64
+
+// A polygon made of an outer ring, counter clockwise, followed by its holes, clockwise
+type Polygon []Ring
+
+// Area covered by the nodes of a tree level, as the union of their bounding boxes
+type LevelCoverage struct {
+	Depth int
+	Nodes int
+	Cells []Polygon
+}
+
+// Coverage of a tileset: the footprint of its points and the area covered by every level of the tree
+type Coverage struct {
+	Footprint []Polygon
+	Points    int64
+	Levels    []LevelCoverage
+}
+
+// Computes the coverage of the given built tree, expressing it in EPSG:4326 coordinates
+func ComputeCoverage(root octree.INode, converter converters.CoordinateConverter) (*Coverage, error) {
+	srid := root.GetInternalSrid()
+	footprint, err := computeFootprint(root)
+	if err != nil {
+		return nil, err
+	}
+
+	coverage := &Coverage{Points: root.TotalNumberOfPoints()}
+	for _, polygon := range footprint {
+		converted, err := convertPolygon(polygon, srid, converter)
+		if err != nil {
+			return nil, err
+		}
+		coverage.Footprint = append(coverage.Footprint, converted)
+	}
+
+	for depth, level := 0, []octree.INode{root}; len(level) > 0; depth++ {
+		levelCoverage := LevelCoverage{Depth: depth, Nodes: len(level)}
+		// nodes stacked on top of each other share the same cell
+		cells := make(map[[4]float64]bool)
+		for _, node := range level {
+			box := node.GetBoundingBox()
+			key := [4]float64{box.Xmin, box.Xmax, box.Ymin, box.Ymax}
+			if cells[key] {
+				continue
+			}
+			cells[key] = true
+			cell, err := convertPolygon(boxToPolygon(box), srid, converter)
+			if err != nil {
+				return nil, err
+			}
+			levelCoverage.Cells = append(levelCoverage.Cells, cell)
+		}
+		coverage.Levels = append(coverage.Levels, levelCoverage)
+		level = getChildrenWithPoints(level)
+	}
+
+	return coverage, nil
+}
+
+// Returns the children containing points of all the given nodes
+func getChildrenWithPoints(nodes []octree.INode) []octree.INode {
+	var children []octree.INode
+	for _, node := range nodes {
+		for _, child := range node.GetChildren() {
+			if child != nil && child.TotalNumberOfPoints() > 0 {
+				children = append(children, child)
+			}
+		}
+	}
+	return children
+}
+
+// Returns the counter clockwise polygon of the 2D extent of the given bounding box
+func boxToPolygon(box *geometry.BoundingBox) Polygon {
+	return Polygon{Ring{
+		{box.Xmin, box.Ymin}, {box.Xmax, box.Ymin}, {box.Xmax, box.Ymax}, {box.Xmin, box.Ymax}, {box.Xmin, box.Ymin},
+	}}
+}
+
+// Converts the positions of the given polygon, expressed in the given srid, to EPSG:4326 coordinates
+func convertPolygon(polygon Polygon, srid int, converter converters.CoordinateConverter) (Polygon, error) {
+	if srid == 4326 {
+		return polygon, nil
+	}
+
+	converted := make(Polygon, len(polygon))
+	for i, ring := range polygon {
+		converted[i] = make(Ring, len(ring))
+		for j, position := range ring {
+			coordinate, err := converter.ConvertCoordinateSrid(srid, 4326, geometry.Coordinate{X: position[0], Y: position[1]})
+			if err != nil {
+				return nil, err
+			}
+			converted[i][j] = [2]float64{coordinate.X, coordinate.Y}
+		}
+	}
+	return converted, nil
+}
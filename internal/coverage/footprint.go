@@ -0,0 +1,316 @@
+package coverage
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"math"
+)
+
+// Maximum number of cells along the longest side of the grid the footprint is traced on
+const maxGridCells = 1024
+
+// Grid of cells covering the 2D extent of a tree, marking the cells containing points
+type coverageGrid struct {
+	x0, y0   float64
+	cellSize float64
+	width    int
+	height   int
+	covered  []bool
+}
+
+// Integer position of a grid vertex
+type vertex struct {
+	i, j int
+}
+
+// Computes the footprint of the points of the tree in the tree srid, tracing the outline of the cells covered by the
+// leaves bounding boxes and by the points retained by the inner nodes. The grid resolution is the size of the smallest
+// leaf, limited to maxGridCells cells along the longest side of the tree.
+func computeFootprint(root octree.INode) ([]Polygon, error) {
+	rootBox := root.GetBoundingBox()
+	longestSide := math.Max(rootBox.Xmax-rootBox.Xmin, rootBox.Ymax-rootBox.Ymin)
+	if longestSide <= 0 {
+		return []Polygon{boxToPolygon(rootBox)}, nil
+	}
+
+	leaves, inner := splitLeaves(root)
+	cellSize := math.Inf(1)
+	for _, leaf := range leaves {
+		box := leaf.GetBoundingBox()
+		if size := math.Max(box.Xmax-box.Xmin, box.Ymax-box.Ymin); size > 0 {
+			cellSize = math.Min(cellSize, size)
+		}
+	}
+	cellSize = math.Max(math.Min(cellSize, longestSide), longestSide/maxGridCells)
+
+	grid := newCoverageGrid(rootBox, cellSize)
+	for _, leaf := range leaves {
+		grid.markBox(leaf.GetBoundingBox())
+	}
+	for _, node := range inner {
+		for _, point := range node.GetPoints() {
+			grid.markCell(grid.column(point.X), grid.row(point.Y))
+		}
+	}
+
+	return grid.trace(rootBox), nil
+}
+
+// Splits the nodes containing points in leaves, i.e. nodes without children containing points, and inner nodes
+func splitLeaves(root octree.INode) ([]octree.INode, []octree.INode) {
+	var leaves, inner []octree.INode
+	for level := []octree.INode{root}; len(level) > 0; {
+		var nextLevel []octree.INode
+		for _, node := range level {
+			children := getChildrenWithPoints([]octree.INode{node})
+			if len(children) == 0 {
+				leaves = append(leaves, node)
+			} else {
+				inner = append(inner, node)
+			}
+			nextLevel = append(nextLevel, children...)
+		}
+		level = nextLevel
+	}
+	return leaves, inner
+}
+
+func newCoverageGrid(box *geometry.BoundingBox, cellSize float64) *coverageGrid {
+	width := int(math.Max(1, math.Ceil((box.Xmax-box.Xmin)/cellSize)))
+	height := int(math.Max(1, math.Ceil((box.Ymax-box.Ymin)/cellSize)))
+	return &coverageGrid{
+		x0:       box.Xmin,
+		y0:       box.Ymin,
+		cellSize: cellSize,
+		width:    width,
+		height:   height,
+		covered:  make([]bool, width*height),
+	}
+}
+
+func (g *coverageGrid) column(x float64) int {
+	return clamp(int(math.Floor((x-g.x0)/g.cellSize)), g.width)
+}
+
+func (g *coverageGrid) row(y float64) int {
+	return clamp(int(math.Floor((y-g.y0)/g.cellSize)), g.height)
+}
+
+func (g *coverageGrid) markCell(column int, row int) {
+	g.covered[row*g.width+column] = true
+}
+
+// Marks all the cells overlapping the given bounding box, at least one cell if the box is degenerate
+func (g *coverageGrid) markBox(box *geometry.BoundingBox) {
+	minColumn, minRow := g.column(box.Xmin), g.row(box.Ymin)
+	maxColumn := clamp(int(math.Ceil((box.Xmax-g.x0)/g.cellSize))-1, g.width)
+	maxRow := clamp(int(math.Ceil((box.Ymax-g.y0)/g.cellSize))-1, g.height)
+	maxColumn, maxRow = maxInt(minColumn, maxColumn), maxInt(minRow, maxRow)
+	for row := minRow; row <= maxRow; row++ {
+		for column := minColumn; column <= maxColumn; column++ {
+			g.markCell(column, row)
+		}
+	}
+}
+
+func (g *coverageGrid) isCovered(column int, row int) bool {
+	if column < 0 || row < 0 || column >= g.width || row >= g.height {
+		return false
+	}
+	return g.covered[row*g.width+column]
+}
+
+// Traces the outlines of the covered cells, returning polygons whose coordinates are clamped to the given box
+func (g *coverageGrid) trace(box *geometry.BoundingBox) []Polygon {
+	var outers, holes [][]vertex
+	for _, ring := range g.traceRings() {
+		if ringArea(ring) > 0 {
+			outers = append(outers, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	polygons := make([]Polygon, len(outers))
+	for i, outer := range outers {
+		polygons[i] = Polygon{g.toRing(outer, box)}
+	}
+	for _, hole := range holes {
+		if i := findEnclosingRing(hole, outers); i >= 0 {
+			polygons[i] = append(polygons[i], g.toRing(hole, box))
+		}
+	}
+	return polygons
+}
+
+// Traces the boundaries between covered and uncovered cells as rings leaving the covered cells on their left, so that
+// outer rings are counter clockwise and holes are clockwise. Cells touching only by a corner are kept apart and rings
+// are split where they touch themselves, so that every returned ring is simple.
+func (g *coverageGrid) traceRings() [][]vertex {
+	outgoing := make(map[vertex][]vertex)
+	var starts []vertex
+	addEdge := func(from vertex, to vertex) {
+		if _, ok := outgoing[from]; !ok {
+			starts = append(starts, from)
+		}
+		outgoing[from] = append(outgoing[from], to)
+	}
+	for row := 0; row < g.height; row++ {
+		for column := 0; column < g.width; column++ {
+			if !g.isCovered(column, row) {
+				continue
+			}
+			if !g.isCovered(column, row-1) {
+				addEdge(vertex{column, row}, vertex{column + 1, row})
+			}
+			if !g.isCovered(column+1, row) {
+				addEdge(vertex{column + 1, row}, vertex{column + 1, row + 1})
+			}
+			if !g.isCovered(column, row+1) {
+				addEdge(vertex{column + 1, row + 1}, vertex{column, row + 1})
+			}
+			if !g.isCovered(column-1, row) {
+				addEdge(vertex{column, row + 1}, vertex{column, row})
+			}
+		}
+	}
+
+	var rings [][]vertex
+	for _, start := range starts {
+		for len(outgoing[start]) > 0 {
+			ring := []vertex{start}
+			positions := map[vertex]int{start: 0}
+			previous, current := start, takeEdge(outgoing, start, nil)
+			for {
+				if k, visited := positions[current]; visited {
+					// the vertices walked since the last visit of the current vertex form a closed ring
+					loop := append([]vertex{}, ring[k:]...)
+					rings = append(rings, removeCollinear(loop))
+					for _, v := range loop[1:] {
+						delete(positions, v)
+					}
+					ring = ring[:k+1]
+					if k == 0 {
+						break
+					}
+				} else {
+					positions[current] = len(ring)
+					ring = append(ring, current)
+				}
+				direction := vertex{current.i - previous.i, current.j - previous.j}
+				previous, current = current, takeEdge(outgoing, current, &direction)
+			}
+		}
+	}
+	return rings
+}
+
+// Removes and returns the end of an edge leaving the given vertex, preferring left turns, then straight moves and
+// then right turns with respect to the given incoming direction
+func takeEdge(outgoing map[vertex][]vertex, from vertex, direction *vertex) vertex {
+	edges := outgoing[from]
+	chosen := 0
+	if direction != nil && len(edges) > 1 {
+		preferences := []vertex{
+			{-direction.j, direction.i},
+			*direction,
+			{direction.j, -direction.i},
+		}
+	search:
+		for _, preferred := range preferences {
+			for k, to := range edges {
+				if to.i-from.i == preferred.i && to.j-from.j == preferred.j {
+					chosen = k
+					break search
+				}
+			}
+		}
+	}
+
+	to := edges[chosen]
+	outgoing[from] = append(edges[:chosen], edges[chosen+1:]...)
+	return to
+}
+
+// Removes the vertices lying on the straight line between their neighbours
+func removeCollinear(ring []vertex) []vertex {
+	var simplified []vertex
+	n := len(ring)
+	for k, current := range ring {
+		previous, next := ring[(k+n-1)%n], ring[(k+1)%n]
+		if (current.i-previous.i)*(next.j-current.j) != (current.j-previous.j)*(next.i-current.i) {
+			simplified = append(simplified, current)
+		}
+	}
+	return simplified
+}
+
+// Returns twice the signed area of the ring, positive if counter clockwise
+func ringArea(ring []vertex) int {
+	area := 0
+	for k, current := range ring {
+		next := ring[(k+1)%len(ring)]
+		area += current.i*next.j - next.i*current.j
+	}
+	return area
+}
+
+// Returns the index of the smallest ring enclosing the given hole, -1 if none
+func findEnclosingRing(hole []vertex, rings [][]vertex) int {
+	// the center of the covered cell on the left of the first edge of the hole
+	direction := vertex{hole[1].i - hole[0].i, hole[1].j - hole[0].j}
+	length := math.Max(math.Abs(float64(direction.i)), math.Abs(float64(direction.j)))
+	x := float64(hole[0].i) + float64(direction.i)/length*0.5 - float64(direction.j)/length*0.5
+	y := float64(hole[0].j) + float64(direction.j)/length*0.5 + float64(direction.i)/length*0.5
+
+	enclosing, smallestArea := -1, 0
+	for k, ring := range rings {
+		if area := ringArea(ring); containsPoint(ring, x, y) && (enclosing < 0 || area < smallestArea) {
+			enclosing, smallestArea = k, area
+		}
+	}
+	return enclosing
+}
+
+// Ray casting point in ring test
+func containsPoint(ring []vertex, x float64, y float64) bool {
+	inside := false
+	for k, current := range ring {
+		previous := ring[(k+len(ring)-1)%len(ring)]
+		xi, yi := float64(current.i), float64(current.j)
+		xj, yj := float64(previous.i), float64(previous.j)
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Converts a ring of grid vertices to a closed ring of coordinates
+func (g *coverageGrid) toRing(vertices []vertex, box *geometry.BoundingBox) Ring {
+	ring := make(Ring, 0, len(vertices)+1)
+	for _, v := range append(vertices, vertices[0]) {
+		ring = append(ring, [2]float64{
+			math.Min(box.Xmax, g.x0+float64(v.i)*g.cellSize),
+			math.Min(box.Ymax, g.y0+float64(v.j)*g.cellSize),
+		})
+	}
+	return ring
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func clamp(index int, size int) int {
+	if index < 0 {
+		return 0
+	}
+	if index >= size {
+		return size - 1
+	}
+	return index
+}
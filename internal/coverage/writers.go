@@ -0,0 +1,115 @@
+package coverage
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+type geoJsonFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJsonFeature `json:"features"`
+}
+
+type geoJsonFeature struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   geoJsonGeometry        `json:"geometry"`
+}
+
+type geoJsonGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []Polygon `json:"coordinates"`
+}
+
+// Encodes the coverage as a GeoJSON feature collection made of the footprint feature, followed by a feature per level
+func (c *Coverage) ToGeoJson() ([]byte, error) {
+	collection := geoJsonFeatureCollection{
+		Type: "FeatureCollection",
+		Features: []geoJsonFeature{{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"name": "footprint", "points": c.Points},
+			Geometry:   geoJsonGeometry{Type: "MultiPolygon", Coordinates: nonNil(c.Footprint)},
+		}},
+	}
+	for _, level := range c.Levels {
+		collection.Features = append(collection.Features, geoJsonFeature{
+			Type:       "Feature",
+			Properties: map[string]interface{}{"name": "level " + strconv.Itoa(level.Depth), "depth": level.Depth, "nodes": level.Nodes},
+			Geometry:   geoJsonGeometry{Type: "MultiPolygon", Coordinates: nonNil(level.Cells)},
+		})
+	}
+
+	return json.MarshalIndent(collection, "", "\t")
+}
+
+// GeoJSON requires the coordinates member to be an array, even if empty
+func nonNil(polygons []Polygon) []Polygon {
+	if polygons == nil {
+		return []Polygon{}
+	}
+	return polygons
+}
+
+type kmlDocument struct {
+	XMLName    xml.Name     `xml:"kml"`
+	Xmlns      string       `xml:"xmlns,attr"`
+	Name       string       `xml:"Document>name"`
+	Elements   []kmlElement `xml:"Document>Placemark"`
+	LevelsName string       `xml:"Document>Folder>name"`
+	Levels     []kmlElement `xml:"Document>Folder>Placemark"`
+}
+
+type kmlElement struct {
+	Name     string       `xml:"name"`
+	Polygons []kmlPolygon `xml:"MultiGeometry>Polygon"`
+}
+
+type kmlPolygon struct {
+	Outer string   `xml:"outerBoundaryIs>LinearRing>coordinates"`
+	Inner []string `xml:"innerBoundaryIs>LinearRing>coordinates"`
+}
+
+// Encodes the coverage as a KML document made of the footprint placemark and a folder with a placemark per level
+func (c *Coverage) ToKml(name string) ([]byte, error) {
+	document := kmlDocument{
+		Xmlns:      "http://www.opengis.net/kml/2.2",
+		Name:       name,
+		Elements:   []kmlElement{{Name: "footprint", Polygons: toKmlPolygons(c.Footprint)}},
+		LevelsName: "levels",
+	}
+	for _, level := range c.Levels {
+		document.Levels = append(document.Levels, kmlElement{
+			Name:     "level " + strconv.Itoa(level.Depth),
+			Polygons: toKmlPolygons(level.Cells),
+		})
+	}
+
+	content, err := xml.MarshalIndent(document, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+func toKmlPolygons(polygons []Polygon) []kmlPolygon {
+	var converted []kmlPolygon
+	for _, polygon := range polygons {
+		kmlPolygon := kmlPolygon{Outer: toKmlCoordinates(polygon[0])}
+		for _, hole := range polygon[1:] {
+			kmlPolygon.Inner = append(kmlPolygon.Inner, toKmlCoordinates(hole))
+		}
+		converted = append(converted, kmlPolygon)
+	}
+	return converted
+}
+
+// Formats the ring as a space separated list of lon,lat tuples
+func toKmlCoordinates(ring Ring) string {
+	tuples := make([]string, len(ring))
+	for i, position := range ring {
+		tuples[i] = strconv.FormatFloat(position[0], 'f', -1, 64) + "," + strconv.FormatFloat(position[1], 'f', -1, 64)
+	}
+	return strings.Join(tuples, " ")
+}
@@ -0,0 +1,34 @@
+package data
+
+import "strconv"
+
+// Names of the ASPRS standard point classes of the LAS specification
+var classificationNames = map[uint8]string{
+	0:  "created",
+	1:  "unclassified",
+	2:  "ground",
+	3:  "low_vegetation",
+	4:  "medium_vegetation",
+	5:  "high_vegetation",
+	6:  "buildings",
+	7:  "low_noise",
+	8:  "key_points",
+	9:  "water",
+	10: "rail",
+	11: "road_surface",
+	12: "overlap",
+	13: "wire_guard",
+	14: "wire_conductor",
+	15: "transmission_towers",
+	16: "wire_connectors",
+	17: "bridge_deck",
+	18: "high_noise",
+}
+
+// Returns the name of the given ASPRS point class, class_<code> for the classes without a standard name
+func GetClassificationName(classification uint8) string {
+	if name, ok := classificationNames[classification]; ok {
+		return name
+	}
+	return "class_" + strconv.Itoa(int(classification))
+}
@@ -1,7 +1,7 @@
 package data
 
 // Contains data of a Point Cloud Point, namely X,Y,Z coords,
-// R,G,B color components, Intensity and Classification
+// R,G,B color components, Intensity, Classification and the optional supplementary attributes
 type Point struct {
 	X              float64
 	Y              float64
@@ -11,6 +11,8 @@ type Point struct {
 	B              uint8
 	Intensity      uint8
 	Classification uint8
+	// supplementary attributes joined from a sidecar table, nil if none
+	Attributes []float32
 }
 
 // Builds a new Point from the given coordinates, colors, intensity and classification values
@@ -0,0 +1,40 @@
+package data
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Size of a spooled point without its attributes: x, y and z as float64, r, g, b, intensity, classification and the
+// number of attributes as uint8, each attribute following as float32, all little endian
+const SpooledPointSize = 3*8 + 6
+
+// Appends the spooled encoding of the given point to the buffer
+func EncodePoint(buffer []byte, point *Point) []byte {
+	for _, value := range []float64{point.X, point.Y, point.Z} {
+		buffer = binary.LittleEndian.AppendUint64(buffer, math.Float64bits(value))
+	}
+	buffer = append(buffer, point.R, point.G, point.B, point.Intensity, point.Classification, uint8(len(point.Attributes)))
+	for _, attribute := range point.Attributes {
+		buffer = binary.LittleEndian.AppendUint32(buffer, math.Float32bits(attribute))
+	}
+	return buffer
+}
+
+// Decodes the spooled point at the start of the given content returning it along with its size
+func DecodePoint(content []byte) (*Point, int) {
+	point := NewPoint(
+		math.Float64frombits(binary.LittleEndian.Uint64(content[0:])),
+		math.Float64frombits(binary.LittleEndian.Uint64(content[8:])),
+		math.Float64frombits(binary.LittleEndian.Uint64(content[16:])),
+		content[24], content[25], content[26], content[27], content[28],
+	)
+	attributes := int(content[29])
+	if attributes > 0 {
+		point.Attributes = make([]float32, attributes)
+		for i := range point.Attributes {
+			point.Attributes[i] = math.Float32frombits(binary.LittleEndian.Uint32(content[SpooledPointSize+4*i:]))
+		}
+	}
+	return point, SpooledPointSize + 4*attributes
+}
@@ -0,0 +1,284 @@
+package draco
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Decodes a Draco point cloud encoded with the sequential encoding method, holding unsigned byte and float attributes
+// either stored losslessly or quantized, whose integer values are predicted by difference or not predicted and
+// entropy coded with the raw scheme or stored uncompressed, as written by Encode
+func Decode(content []byte) (*PointCloud, error) {
+	reader := &byteReader{content: content}
+	header, err := reader.read(11)
+	if err != nil || string(header[0:5]) != "DRACO" {
+		return nil, errors.New("not a draco bitstream")
+	}
+	if header[5] != versionMajor || header[6] > versionMinor+1 {
+		return nil, errors.New("unsupported draco bitstream version")
+	}
+	if header[7] != pointCloudGeometry || header[8] != sequentialEncoding {
+		return nil, errors.New("only sequentially encoded draco point clouds are supported")
+	}
+	if binary.LittleEndian.Uint16(header[9:11]) != 0 {
+		return nil, errors.New("draco metadata is not supported")
+	}
+	numPoints, err := reader.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if numPoints > uint32(math.MaxInt32) {
+		return nil, errors.New("invalid number of draco points")
+	}
+	decoders, err := reader.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if decoders != 1 {
+		return nil, errors.New("unsupported number of draco attribute decoders")
+	}
+
+	count, err := reader.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 || count > uint64(reader.remaining()) {
+		return nil, errors.New("invalid number of draco attributes")
+	}
+	cloud := &PointCloud{NumPoints: int(numPoints), Attributes: make([]*Attribute, count)}
+	dataTypes := make([]uint8, count)
+	for i := range cloud.Attributes {
+		descriptor, err := reader.read(4)
+		if err != nil {
+			return nil, err
+		}
+		uniqueId, err := reader.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if descriptor[2] == 0 {
+			return nil, errors.New("invalid number of draco attribute components")
+		}
+		dataTypes[i] = descriptor[1]
+		cloud.Attributes[i] = &Attribute{
+			Type:       AttributeType(descriptor[0]),
+			Components: int(descriptor[2]),
+			Normalized: descriptor[3] > 0,
+			UniqueId:   int(uniqueId),
+		}
+	}
+	encoders, err := reader.read(len(cloud.Attributes))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]int32, len(cloud.Attributes))
+	for i, attribute := range cloud.Attributes {
+		length := cloud.NumPoints * attribute.Components
+		switch {
+		case encoders[i] == genericAttributeEncoder && dataTypes[i] == dataTypeFloat32:
+			raw, err := reader.read(length * 4)
+			if err != nil {
+				return nil, err
+			}
+			attribute.Floats = make([]float32, length)
+			for j := range attribute.Floats {
+				attribute.Floats[j] = math.Float32frombits(binary.LittleEndian.Uint32(raw[j*4:]))
+			}
+		case encoders[i] == integerAttributeEncoder && dataTypes[i] == dataTypeUint8,
+			encoders[i] == quantizationAttributeEncoder && dataTypes[i] == dataTypeFloat32:
+			if values[i], err = decodeIntegerValues(reader, length, attribute.Components); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New("unsupported draco attribute encoding")
+		}
+	}
+
+	for i, attribute := range cloud.Attributes {
+		if encoders[i] == integerAttributeEncoder {
+			attribute.Bytes = make([]uint8, len(values[i]))
+			for j, value := range values[i] {
+				attribute.Bytes[j] = uint8(value)
+			}
+		} else if encoders[i] == quantizationAttributeEncoder {
+			if err := dequantize(reader, attribute, values[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return cloud, nil
+}
+
+// Decodes the given number of integer values, predicted by difference from the previous point or not predicted
+func decodeIntegerValues(reader *byteReader, length int, components int) ([]int32, error) {
+	prediction, err := reader.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if int8(prediction) != predictionNone {
+		if int8(prediction) != predictionDifference {
+			return nil, errors.New("unsupported draco prediction scheme")
+		}
+		transform, err := reader.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if transform != predictionTransformWrap {
+			return nil, errors.New("unsupported draco prediction transform")
+		}
+	}
+
+	compressed, err := reader.readByte()
+	if err != nil {
+		return nil, err
+	}
+	var symbols []uint32
+	if compressed > 0 {
+		if symbols, err = decodeSymbols(reader, length); err != nil {
+			return nil, err
+		}
+	} else {
+		size, err := reader.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 || size > 4 {
+			return nil, errors.New("invalid size of the draco values")
+		}
+		raw, err := reader.read(length * int(size))
+		if err != nil {
+			return nil, err
+		}
+		symbols = make([]uint32, length)
+		for i := range symbols {
+			for b := 0; b < int(size); b++ {
+				symbols[i] |= uint32(raw[i*int(size)+b]) << (8 * b)
+			}
+		}
+	}
+	values := make([]int32, length)
+	for i, symbol := range symbols {
+		values[i] = fromSymbol(symbol)
+	}
+	if int8(prediction) == predictionNone {
+		return values, nil
+	}
+
+	minimum, err := reader.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	maximum, err := reader.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if int32(minimum) > int32(maximum) {
+		return nil, errors.New("invalid draco wrap range")
+	}
+	wrap := &wrapTransform{minimum: int64(int32(minimum)), maximum: int64(int32(maximum))}
+	for i := range values {
+		prediction := int32(0)
+		if i >= components {
+			prediction = values[i-components]
+		}
+		values[i] = wrap.apply(prediction, values[i])
+	}
+	return values, nil
+}
+
+// Wraps the values predicted from the corrections in the range of the original values
+type wrapTransform struct {
+	minimum, maximum int64
+}
+
+func (w *wrapTransform) apply(prediction int32, correction int32) int32 {
+	clamped := int64(prediction)
+	if clamped < w.minimum {
+		clamped = w.minimum
+	} else if clamped > w.maximum {
+		clamped = w.maximum
+	}
+	value := clamped + int64(correction)
+	if value > w.maximum {
+		value -= w.maximum - w.minimum + 1
+	} else if value < w.minimum {
+		value += w.maximum - w.minimum + 1
+	}
+	return int32(value)
+}
+
+// Reads the quantization parameters of the attribute and converts its quantized values back to floats
+func dequantize(reader *byteReader, attribute *Attribute, values []int32) error {
+	minimums := make([]float32, attribute.Components)
+	for c := range minimums {
+		minimum, err := reader.readUint32()
+		if err != nil {
+			return err
+		}
+		minimums[c] = math.Float32frombits(minimum)
+	}
+	extent, err := reader.readUint32()
+	if err != nil {
+		return err
+	}
+	bits, err := reader.readByte()
+	if err != nil {
+		return err
+	}
+	if bits < 1 || bits > 30 {
+		return errors.New("invalid draco quantization bits")
+	}
+	attribute.QuantizationBits = int(bits)
+	delta := math.Float32frombits(extent) / float32(int32(1)<<bits-1)
+	attribute.Floats = make([]float32, len(values))
+	for i, value := range values {
+		attribute.Floats[i] = float32(value)*delta + minimums[i%attribute.Components]
+	}
+	return nil
+}
+
+// Reads the little endian values of a Draco bitstream
+type byteReader struct {
+	content []byte
+	offset  int
+}
+
+func (r *byteReader) remaining() int {
+	return len(r.content) - r.offset
+}
+
+func (r *byteReader) read(length int) ([]byte, error) {
+	if length < 0 || length > r.remaining() {
+		return nil, errors.New("truncated draco bitstream")
+	}
+	bytes := r.content[r.offset : r.offset+length]
+	r.offset += length
+	return bytes, nil
+}
+
+func (r *byteReader) readByte() (uint8, error) {
+	bytes, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return bytes[0], nil
+}
+
+func (r *byteReader) readUint32() (uint32, error) {
+	bytes, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(bytes), nil
+}
+
+func (r *byteReader) readUvarint() (uint64, error) {
+	value, length := binary.Uvarint(r.content[r.offset:])
+	if length <= 0 {
+		return 0, errors.New("truncated draco bitstream")
+	}
+	r.offset += length
+	return value, nil
+}
@@ -0,0 +1,98 @@
+package draco
+
+// Version of the Draco bitstream written, decoded by all the Draco libraries since the 1.3 release
+const (
+	versionMajor = 2
+	versionMinor = 2
+)
+
+// Geometry type and encoding method of the point clouds encoded sequentially
+const (
+	pointCloudGeometry = 0
+	sequentialEncoding = 0
+)
+
+// Semantic of an attribute of a Draco point cloud
+type AttributeType uint8
+
+const (
+	Position AttributeType = 0
+	Color    AttributeType = 2
+	Generic  AttributeType = 4
+)
+
+// Data types of the attribute values
+const (
+	dataTypeUint8   = 2
+	dataTypeFloat32 = 9
+)
+
+// Encoders of the attribute values
+const (
+	genericAttributeEncoder      = 0
+	integerAttributeEncoder      = 1
+	quantizationAttributeEncoder = 2
+)
+
+// Prediction schemes and transforms of the integer attribute values
+const (
+	predictionNone          = -2
+	predictionDifference    = 0
+	predictionTransformWrap = 1
+)
+
+// Entropy coding schemes of the integer attribute values
+const (
+	symbolCodingTagged = 0
+	symbolCodingRaw    = 1
+)
+
+// Max bit length of the symbols of the raw coding scheme, larger values are stored uncompressed
+const maxRawSymbolBitLength = 18
+
+// An attribute of the points of a Draco point cloud, holding either float or unsigned byte values
+type Attribute struct {
+	Type       AttributeType
+	Components int
+	// True if the unsigned byte values are normalized to the [0, 1] range, as the colors are
+	Normalized bool
+	// Values of the float attributes, Components values per point
+	Floats []float32
+	// Values of the unsigned byte attributes, Components values per point
+	Bytes []uint8
+	// Number of bits the float values are quantized to, stored losslessly if 0
+	QuantizationBits int
+	// Id referencing the attribute, its index in the point cloud
+	UniqueId int
+}
+
+// A decoded Draco point cloud
+type PointCloud struct {
+	NumPoints  int
+	Attributes []*Attribute
+}
+
+// Returns the attribute of the given unique id, nil if missing
+func (p *PointCloud) GetAttribute(uniqueId int) *Attribute {
+	for _, attribute := range p.Attributes {
+		if attribute.UniqueId == uniqueId {
+			return attribute
+		}
+	}
+	return nil
+}
+
+// Converts a signed integer to the unsigned symbol coding it, alternating positive and negative values
+func toSymbol(value int32) uint32 {
+	if value >= 0 {
+		return uint32(value) << 1
+	}
+	return uint32(-(value+1))<<1 | 1
+}
+
+func fromSymbol(symbol uint32) int32 {
+	if symbol&1 == 0 {
+		return int32(symbol >> 1)
+	}
+	return -int32(symbol>>1) - 1
+}
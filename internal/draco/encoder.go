@@ -0,0 +1,240 @@
+package draco
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// Encodes the attributes of the given number of points in a Draco point cloud with the sequential encoding method.
+// The unsigned byte attributes are encoded as integers and the float ones either quantized to their number of bits
+// or stored losslessly. The integer values are predicted from the ones of the previous point and entropy coded, the
+// points being first reordered along a Morton curve of their positions so that consecutive points lie close to each other.
+// The unique ids of the attributes are set to their indexes.
+func Encode(numPoints int, attributes []*Attribute) ([]byte, error) {
+	if len(attributes) == 0 || len(attributes) > math.MaxUint8 {
+		return nil, errors.New("unsupported number of draco attributes")
+	}
+	for i, attribute := range attributes {
+		if attribute.Components <= 0 || attribute.Components > math.MaxUint8 {
+			return nil, errors.New("unsupported number of draco attribute components")
+		}
+		if attribute.Floats == nil && len(attribute.Bytes) != numPoints*attribute.Components ||
+			attribute.Floats != nil && len(attribute.Floats) != numPoints*attribute.Components {
+			return nil, errors.New("draco attribute values do not match the number of points")
+		}
+		if attribute.QuantizationBits < 0 || attribute.QuantizationBits > 30 {
+			return nil, errors.New("draco quantization bits must be between 1 and 30")
+		}
+		attribute.UniqueId = i
+	}
+	order := getMortonOrder(numPoints, attributes)
+
+	buffer := append([]byte("DRACO"), versionMajor, versionMinor, pointCloudGeometry, sequentialEncoding, 0, 0)
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(numPoints))
+	buffer = append(buffer, 1)
+	buffer = binary.AppendUvarint(buffer, uint64(len(attributes)))
+	for _, attribute := range attributes {
+		dataType := uint8(dataTypeUint8)
+		if attribute.Floats != nil {
+			dataType = dataTypeFloat32
+		}
+		normalized := uint8(0)
+		if attribute.Normalized {
+			normalized = 1
+		}
+		buffer = append(buffer, uint8(attribute.Type), dataType, uint8(attribute.Components), normalized)
+		buffer = binary.AppendUvarint(buffer, uint64(attribute.UniqueId))
+	}
+	for _, attribute := range attributes {
+		buffer = append(buffer, getAttributeEncoder(attribute))
+	}
+
+	var transforms []byte
+	for _, attribute := range attributes {
+		switch getAttributeEncoder(attribute) {
+		case genericAttributeEncoder:
+			for _, point := range order {
+				for _, value := range attribute.Floats[point*attribute.Components : (point+1)*attribute.Components] {
+					buffer = binary.LittleEndian.AppendUint32(buffer, math.Float32bits(value))
+				}
+			}
+		case integerAttributeEncoder:
+			values := make([]int32, 0, len(attribute.Bytes))
+			for _, point := range order {
+				for _, value := range attribute.Bytes[point*attribute.Components : (point+1)*attribute.Components] {
+					values = append(values, int32(value))
+				}
+			}
+			buffer = encodeIntegerValues(buffer, values, attribute.Components)
+		case quantizationAttributeEncoder:
+			var values []int32
+			values, transforms = quantize(attribute, order, transforms)
+			buffer = encodeIntegerValues(buffer, values, attribute.Components)
+		}
+	}
+	return append(buffer, transforms...), nil
+}
+
+func getAttributeEncoder(attribute *Attribute) uint8 {
+	if attribute.Floats == nil {
+		return integerAttributeEncoder
+	}
+	if attribute.QuantizationBits > 0 {
+		return quantizationAttributeEncoder
+	}
+	return genericAttributeEncoder
+}
+
+// Quantizes the float values of the attribute in the given point order, relatively to their minimum per component and
+// to the largest extent of the components, appending the parameters of the quantization to the transforms
+func quantize(attribute *Attribute, order []int, transforms []byte) ([]int32, []byte) {
+	components := attribute.Components
+	minimums := make([]float32, components)
+	maximums := make([]float32, components)
+	for c := 0; c < components; c++ {
+		minimums[c], maximums[c] = float32(math.Inf(1)), float32(math.Inf(-1))
+	}
+	for i, value := range attribute.Floats {
+		c := i % components
+		minimums[c] = float32(math.Min(float64(minimums[c]), float64(value)))
+		maximums[c] = float32(math.Max(float64(maximums[c]), float64(value)))
+	}
+	extent := float32(0)
+	for c := 0; c < components; c++ {
+		if len(order) == 0 {
+			minimums[c] = 0
+		} else if maximums[c]-minimums[c] > extent {
+			extent = maximums[c] - minimums[c]
+		}
+	}
+	if extent == 0 {
+		extent = 1
+	}
+
+	inverseDelta := float32(int32(1)<<attribute.QuantizationBits-1) / extent
+	values := make([]int32, 0, len(attribute.Floats))
+	for _, point := range order {
+		for c := 0; c < components; c++ {
+			value := (attribute.Floats[point*components+c] - minimums[c]) * inverseDelta
+			values = append(values, int32(math.Floor(float64(value+0.5))))
+		}
+	}
+
+	for _, minimum := range minimums {
+		transforms = binary.LittleEndian.AppendUint32(transforms, math.Float32bits(minimum))
+	}
+	transforms = binary.LittleEndian.AppendUint32(transforms, math.Float32bits(extent))
+	return values, append(transforms, uint8(attribute.QuantizationBits))
+}
+
+// Encodes the integer values predicting each one from the value of the same component of the previous point, the
+// corrections wrapped in the range of the values and entropy coded
+func encodeIntegerValues(buffer []byte, values []int32, components int) []byte {
+	prediction := int8(predictionNone)
+	if len(values) == 0 {
+		return append(buffer, uint8(prediction), 1)
+	}
+	prediction = predictionDifference
+	buffer = append(buffer, uint8(prediction), predictionTransformWrap)
+
+	minimum, maximum := values[0], values[0]
+	for _, value := range values {
+		if value < minimum {
+			minimum = value
+		}
+		if value > maximum {
+			maximum = value
+		}
+	}
+	maxDifference := int64(maximum) - int64(minimum) + 1
+	maxCorrection := maxDifference / 2
+	minCorrection := -maxCorrection
+	if maxDifference%2 == 0 {
+		maxCorrection--
+	}
+	firstPrediction := int32(0)
+	if firstPrediction < minimum {
+		firstPrediction = minimum
+	} else if firstPrediction > maximum {
+		firstPrediction = maximum
+	}
+
+	symbols := make([]uint32, len(values))
+	maxSymbol := uint32(0)
+	for i, value := range values {
+		prediction := firstPrediction
+		if i >= components {
+			prediction = values[i-components]
+		}
+		correction := int64(value) - int64(prediction)
+		if correction < minCorrection {
+			correction += maxDifference
+		} else if correction > maxCorrection {
+			correction -= maxDifference
+		}
+		symbols[i] = toSymbol(int32(correction))
+		if symbols[i] > maxSymbol {
+			maxSymbol = symbols[i]
+		}
+	}
+
+	if maxSymbol < 1<<maxRawSymbolBitLength {
+		buffer = append(buffer, 1)
+		buffer = encodeSymbols(buffer, symbols)
+	} else {
+		buffer = append(buffer, 0, 4)
+		for _, symbol := range symbols {
+			buffer = binary.LittleEndian.AppendUint32(buffer, symbol)
+		}
+	}
+	buffer = binary.LittleEndian.AppendUint32(buffer, uint32(minimum))
+	return binary.LittleEndian.AppendUint32(buffer, uint32(maximum))
+}
+
+// Returns the indexes of the points sorted along a Morton curve of the positions, in the original order if there are
+// no float position attribute
+func getMortonOrder(numPoints int, attributes []*Attribute) []int {
+	order := make([]int, numPoints)
+	for i := range order {
+		order[i] = i
+	}
+	var positions *Attribute
+	for _, attribute := range attributes {
+		if attribute.Type == Position && attribute.Floats != nil && attribute.Components == 3 {
+			positions = attribute
+			break
+		}
+	}
+	if positions == nil || numPoints == 0 {
+		return order
+	}
+
+	var minimums, maximums [3]float64
+	for c := 0; c < 3; c++ {
+		minimums[c], maximums[c] = math.Inf(1), math.Inf(-1)
+	}
+	for i, value := range positions.Floats {
+		minimums[i%3] = math.Min(minimums[i%3], float64(value))
+		maximums[i%3] = math.Max(maximums[i%3], float64(value))
+	}
+	codes := make([]uint64, numPoints)
+	for i := range codes {
+		var cell [3]uint64
+		for c := 0; c < 3; c++ {
+			if extent := maximums[c] - minimums[c]; extent > 0 {
+				cell[c] = uint64((float64(positions.Floats[i*3+c]) - minimums[c]) / extent * (1<<21 - 1))
+			}
+		}
+		for bit := 0; bit < 21; bit++ {
+			for c := 0; c < 3; c++ {
+				codes[i] |= (cell[c] >> bit & 1) << (3*bit + c)
+			}
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return codes[order[i]] < codes[order[j]]
+	})
+	return order
+}
@@ -0,0 +1,294 @@
+package draco
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// Base of the renormalization of the rANS coder state, which is written and read one byte at a time
+const ransIoBase = 256
+
+// Returns the number of precision bits of the probabilities of the symbols of the given bit length, as chosen by the
+// Draco decoders
+func ransPrecisionBits(symbolBitLength int) int {
+	bits := 3 * symbolBitLength / 2
+	if bits < 12 {
+		return 12
+	}
+	if bits > 20 {
+		return 20
+	}
+	return bits
+}
+
+// Returns the number of bits needed to represent the given value
+func bitLength(value uint32) int {
+	length := 0
+	for ; value > 0; value >>= 1 {
+		length++
+	}
+	return length
+}
+
+// Encodes the given symbols with the raw rANS coding scheme, appending them to the buffer
+func encodeSymbols(buffer []byte, symbols []uint32) []byte {
+	if len(symbols) == 0 {
+		return buffer
+	}
+	maxSymbol := uint32(0)
+	for _, symbol := range symbols {
+		if symbol > maxSymbol {
+			maxSymbol = symbol
+		}
+	}
+	frequencies := make([]uint64, maxSymbol+1)
+	unique := uint32(0)
+	for _, symbol := range symbols {
+		if frequencies[symbol] == 0 {
+			unique++
+		}
+		frequencies[symbol]++
+	}
+	length := bitLength(unique)
+	if length > maxRawSymbolBitLength {
+		length = maxRawSymbolBitLength
+	}
+	precision := uint32(1) << ransPrecisionBits(length)
+	probabilities := normalizeProbabilities(frequencies, uint64(len(symbols)), precision)
+
+	buffer = append(buffer, symbolCodingRaw, uint8(length))
+	buffer = encodeProbabilities(buffer, probabilities)
+
+	cumulated := make([]uint32, len(probabilities))
+	for i := 1; i < len(probabilities); i++ {
+		cumulated[i] = cumulated[i-1] + probabilities[i-1]
+	}
+	base := uint64(precision) * 4
+	state := base
+	var data []byte
+	for i := len(symbols) - 1; i >= 0; i-- {
+		probability := uint64(probabilities[symbols[i]])
+		for state >= base/uint64(precision)*ransIoBase*probability {
+			data = append(data, uint8(state%ransIoBase))
+			state /= ransIoBase
+		}
+		state = (state/probability)*uint64(precision) + state%probability + uint64(cumulated[symbols[i]])
+	}
+	data = writeRansState(data, uint32(state-base))
+
+	buffer = binary.AppendUvarint(buffer, uint64(len(data)))
+	return append(buffer, data...)
+}
+
+// Scales the frequencies of the symbols to probabilities summing to the given precision, keeping every used symbol
+// encodable with a probability of at least one
+func normalizeProbabilities(frequencies []uint64, total uint64, precision uint32) []uint32 {
+	probabilities := make([]uint32, len(frequencies))
+	sum := int64(0)
+	var used []int
+	for symbol, frequency := range frequencies {
+		if frequency == 0 {
+			continue
+		}
+		probability := uint32((frequency*uint64(precision) + total/2) / total)
+		if probability == 0 {
+			probability = 1
+		}
+		probabilities[symbol] = probability
+		sum += int64(probability)
+		used = append(used, symbol)
+	}
+	sort.SliceStable(used, func(i, j int) bool {
+		return probabilities[used[i]] > probabilities[used[j]]
+	})
+	for difference := int64(precision) - sum; difference != 0; {
+		changed := false
+		for _, symbol := range used {
+			if difference > 0 {
+				probabilities[symbol]++
+				difference--
+				changed = true
+			} else if difference < 0 && probabilities[symbol] > 1 {
+				probabilities[symbol]--
+				difference++
+				changed = true
+			}
+			if difference == 0 {
+				break
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return probabilities
+}
+
+// Appends the probability table, writing each probability over one to four bytes whose first one holds the count of the
+// extra bytes in its two lowest bits, and the runs of unused symbols as single bytes
+func encodeProbabilities(buffer []byte, probabilities []uint32) []byte {
+	buffer = binary.AppendUvarint(buffer, uint64(len(probabilities)))
+	for i := 0; i < len(probabilities); i++ {
+		probability := probabilities[i]
+		if probability == 0 {
+			run := 0
+			for run < 63 && i+run+1 < len(probabilities) && probabilities[i+run+1] == 0 {
+				run++
+			}
+			buffer = append(buffer, uint8(run<<2|3))
+			i += run
+			continue
+		}
+		extraBytes := 0
+		for probability >= 1<<(6+8*extraBytes) {
+			extraBytes++
+		}
+		buffer = append(buffer, uint8(probability<<2)|uint8(extraBytes))
+		for b := 0; b < extraBytes; b++ {
+			buffer = append(buffer, uint8(probability>>(8*(b+1)-2)))
+		}
+	}
+	return buffer
+}
+
+// Appends the final state of the coder, written over one to four bytes tagged by the two highest bits of the last one
+func writeRansState(data []byte, state uint32) []byte {
+	switch {
+	case state < 1<<6:
+		return append(data, uint8(state))
+	case state < 1<<14:
+		return binary.LittleEndian.AppendUint16(data, uint16(0x01<<14|state))
+	case state < 1<<22:
+		value := 0x02<<22 | state
+		return append(data, uint8(value), uint8(value>>8), uint8(value>>16))
+	default:
+		return binary.LittleEndian.AppendUint32(data, 0x03<<30|state)
+	}
+}
+
+// Decodes the given number of symbols coded with the raw rANS coding scheme
+func decodeSymbols(reader *byteReader, count int) ([]uint32, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	scheme, err := reader.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if scheme == symbolCodingTagged {
+		return nil, errors.New("tagged symbol coding is not supported")
+	}
+	if scheme != symbolCodingRaw {
+		return nil, errors.New("unknown symbol coding scheme")
+	}
+	length, err := reader.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 || length > maxRawSymbolBitLength {
+		return nil, errors.New("invalid symbol bit length")
+	}
+	precision := uint32(1) << ransPrecisionBits(int(length))
+	probabilities, err := decodeProbabilities(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := make([]uint32, precision)
+	cumulated := make([]uint32, len(probabilities))
+	sum := uint32(0)
+	for symbol, probability := range probabilities {
+		cumulated[symbol] = sum
+		if sum+probability > precision {
+			return nil, errors.New("invalid symbol probabilities")
+		}
+		for j := sum; j < sum+probability; j++ {
+			lookup[j] = uint32(symbol)
+		}
+		sum += probability
+	}
+	if sum != precision {
+		return nil, errors.New("invalid symbol probabilities")
+	}
+
+	size, err := reader.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	data, err := reader.read(int(size))
+	if err != nil {
+		return nil, err
+	}
+	offset, state, err := readRansState(data)
+	if err != nil {
+		return nil, err
+	}
+	base := uint64(precision) * 4
+	state += base
+	symbols := make([]uint32, count)
+	for i := range symbols {
+		for state < base && offset > 0 {
+			offset--
+			state = state*ransIoBase + uint64(data[offset])
+		}
+		remainder := uint32(state % uint64(precision))
+		symbol := lookup[remainder]
+		state = (state/uint64(precision))*uint64(probabilities[symbol]) + uint64(remainder-cumulated[symbol])
+		symbols[i] = symbol
+	}
+	return symbols, nil
+}
+
+func decodeProbabilities(reader *byteReader) ([]uint32, error) {
+	count, err := reader.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(reader.remaining())*64 {
+		return nil, errors.New("invalid number of symbols")
+	}
+	probabilities := make([]uint32, count)
+	for i := 0; i < len(probabilities); i++ {
+		first, err := reader.readByte()
+		if err != nil {
+			return nil, err
+		}
+		extraBytes := int(first & 3)
+		if extraBytes == 3 {
+			i += int(first >> 2)
+			if i >= len(probabilities) {
+				return nil, errors.New("invalid symbol probabilities")
+			}
+			continue
+		}
+		probability := uint32(first >> 2)
+		for b := 0; b < extraBytes; b++ {
+			extra, err := reader.readByte()
+			if err != nil {
+				return nil, err
+			}
+			probability |= uint32(extra) << (8*(b+1) - 2)
+		}
+		probabilities[i] = probability
+	}
+	return probabilities, nil
+}
+
+// Reads the final state of the coder from the end of the data, returning the offset of the bytes preceding it
+func readRansState(data []byte) (int, uint64, error) {
+	if len(data) == 0 {
+		return 0, 0, errors.New("empty symbol data")
+	}
+	last := len(data) - 1
+	size := int(data[last]>>6) + 1
+	if size > len(data) {
+		return 0, 0, errors.New("truncated symbol data")
+	}
+	state := uint64(0)
+	for i := size - 1; i >= 0; i-- {
+		state = state<<8 | uint64(data[len(data)-size+i])
+	}
+	state &= 1<<(8*size-2) - 1
+	return len(data) - size, state, nil
+}
@@ -80,4 +80,21 @@ func (b *BoundingBox) distance(lat1, lat2, lon1, lon2, el1, el2 float64) float64
 	height := el1 - el2
 	distance = distance*distance + height*height
 	return math.Sqrt(distance)
-}
\ No newline at end of file
+}
+
+// Returns a copy of the given bounds, in xMin, xMax, yMin, yMax, zMin, zMax order, where every side shorter than the
+// given minimum size is symmetrically extended to it. This avoids zero volume boxes when all the points are identical,
+// collinear or coplanar.
+func ExtendDegenerateBounds(bounds []float64, minSide float64) []float64 {
+	extended := make([]float64, 6)
+	copy(extended, bounds)
+	for axis := 0; axis < 3; axis++ {
+		min, max := extended[2*axis], extended[2*axis+1]
+		if max-min < minSide {
+			mid := (min + max) / 2
+			extended[2*axis] = mid - minSide/2
+			extended[2*axis+1] = mid + minSide/2
+		}
+	}
+	return extended
+}
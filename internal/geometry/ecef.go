@@ -0,0 +1,24 @@
+package geometry
+
+import "math"
+
+// WGS84 ellipsoid parameters
+const wgs84SemiMajorAxis = 6378137.0
+const wgs84EccentricitySquared = 6.69437999014e-3
+
+// Converts ECEF coordinates to WGS84 longitude and latitude, in degrees, and ellipsoidal height
+func EcefToGeographic(x, y, z float64) (float64, float64, float64) {
+	p := math.Sqrt(x*x + y*y)
+	lon := math.Atan2(y, x)
+	lat := math.Atan2(z, p*(1-wgs84EccentricitySquared))
+
+	var height float64
+	for i := 0; i < 5; i++ {
+		sinLat := math.Sin(lat)
+		n := wgs84SemiMajorAxis / math.Sqrt(1-wgs84EccentricitySquared*sinLat*sinLat)
+		height = p/math.Cos(lat) - n
+		lat = math.Atan2(z, p*(1-wgs84EccentricitySquared*n/(n+height)))
+	}
+
+	return lon * 180 / math.Pi, lat * 180 / math.Pi, height
+}
@@ -0,0 +1,50 @@
+package geometry
+
+import (
+	"math"
+)
+
+// Models a local East-North-Up cartesian frame tangent to the WGS84 ellipsoid at a given origin
+type LocalFrame struct {
+	origin Coordinate
+	east   [3]float64
+	north  [3]float64
+	up     [3]float64
+}
+
+// Builds the East-North-Up frame having its origin in the given ECEF coordinate. Longitude and latitude
+// of the origin must be expressed in degrees.
+func NewLocalFrame(ecefOrigin Coordinate, lon float64, lat float64) *LocalFrame {
+	sinLon, cosLon := math.Sin(lon*toRadians), math.Cos(lon*toRadians)
+	sinLat, cosLat := math.Sin(lat*toRadians), math.Cos(lat*toRadians)
+
+	return &LocalFrame{
+		origin: ecefOrigin,
+		east:   [3]float64{-sinLon, cosLon, 0},
+		north:  [3]float64{-sinLat * cosLon, -sinLat * sinLon, cosLat},
+		up:     [3]float64{cosLat * cosLon, cosLat * sinLon, sinLat},
+	}
+}
+
+// Expresses the given ECEF coordinate in the local frame
+func (f *LocalFrame) FromEcef(coord Coordinate) Coordinate {
+	dx := coord.X - f.origin.X
+	dy := coord.Y - f.origin.Y
+	dz := coord.Z - f.origin.Z
+
+	return Coordinate{
+		X: f.east[0]*dx + f.east[1]*dy + f.east[2]*dz,
+		Y: f.north[0]*dx + f.north[1]*dy + f.north[2]*dz,
+		Z: f.up[0]*dx + f.up[1]*dy + f.up[2]*dz,
+	}
+}
+
+// Returns the 4x4 matrix, in column-major order, that transforms local coordinates into ECEF ones
+func (f *LocalFrame) GetTransform() []float64 {
+	return []float64{
+		f.east[0], f.east[1], f.east[2], 0,
+		f.north[0], f.north[1], f.north[2], 0,
+		f.up[0], f.up[1], f.up[2], 0,
+		f.origin.X, f.origin.Y, f.origin.Z, 1,
+	}
+}
@@ -0,0 +1,127 @@
+package geovolumes
+
+import (
+	"encoding/json"
+	"path"
+)
+
+// Folder of the output directory where the documents are written
+const Folder = "geovolumes"
+
+// CRS of the bounding volumes: longitude, latitude and ellipsoidal height
+const crs84h = "http://www.opengis.net/def/crs/OGC/0/CRS84h"
+
+// Media type of the 3D Tiles tilesets
+const tilesetMediaType = "application/json+3dtiles"
+
+// Conformance classes of the OGC API 3D GeoVolumes implemented by the documents
+var conformsTo = []string{
+	"http://www.opengis.net/spec/ogcapi-common-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-common-2/1.0/conf/collections",
+	"http://www.opengis.net/spec/ogcapi-geovolumes-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-geovolumes-1/1.0/conf/3dtiles",
+}
+
+type Link struct {
+	Rel   string `json:"rel"`
+	Href  string `json:"href"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+type LandingPage struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Links       []Link `json:"links"`
+}
+
+type Conformance struct {
+	ConformsTo []string `json:"conformsTo"`
+}
+
+type Extent struct {
+	Spatial struct {
+		Bbox [][]float64 `json:"bbox"`
+		Crs  string      `json:"crs"`
+	} `json:"spatial"`
+}
+
+// 3D container collection, whose content is a 3D Tiles tileset
+type Collection struct {
+	Id             string `json:"id"`
+	Title          string `json:"title"`
+	CollectionType string `json:"collectionType"`
+	Extent         Extent `json:"extent"`
+	Links          []Link `json:"links"`
+	Content        []Link `json:"content"`
+}
+
+type Collections struct {
+	Collections []*Collection `json:"collections"`
+	Links       []Link        `json:"links"`
+}
+
+// Static OGC API 3D GeoVolumes documents describing the tilesets of the output folder
+type Api struct {
+	title       string
+	collections []*Collection
+}
+
+func NewApi(title string) *Api {
+	return &Api{title: title}
+}
+
+// Adds the collection of the tileset stored in the folder of the output directory with the given name. Bounds are
+// the west, south, east and north longitudes and latitudes in degrees followed by the min and max heights.
+func (a *Api) AddCollection(name string, bounds [6]float64) {
+	collection := &Collection{
+		Id:             name,
+		Title:          name,
+		CollectionType: "3d-container",
+		Links:          []Link{{Rel: "self", Href: "collections.json", Type: "application/json"}},
+		Content: []Link{{
+			Rel:   "original",
+			Href:  path.Join("..", name, "tileset.json"),
+			Type:  tilesetMediaType,
+			Title: name + " tileset",
+		}},
+	}
+	collection.Extent.Spatial.Bbox = [][]float64{{bounds[0], bounds[1], bounds[4], bounds[2], bounds[3], bounds[5]}}
+	collection.Extent.Spatial.Crs = crs84h
+	a.collections = append(a.collections, collection)
+}
+
+// Returns the documents to write in the geovolumes folder, by file name: the landing page, the conformance
+// declaration and the collections list
+func (a *Api) Documents() (map[string][]byte, error) {
+	collections := a.collections
+	if collections == nil {
+		collections = []*Collection{}
+	}
+	documents := map[string]interface{}{
+		"index.json": LandingPage{
+			Title:       a.title,
+			Description: "3D Tiles point cloud tilesets generated by gocesiumtiler",
+			Links: []Link{
+				{Rel: "self", Href: "index.json", Type: "application/json", Title: "This document"},
+				{Rel: "conformance", Href: "conformance.json", Type: "application/json", Title: "Conformance classes"},
+				{Rel: "data", Href: "collections.json", Type: "application/json", Title: "3D containers"},
+			},
+		},
+		"conformance.json": Conformance{ConformsTo: conformsTo},
+		"collections.json": Collections{
+			Collections: collections,
+			Links:       []Link{{Rel: "self", Href: "collections.json", Type: "application/json"}},
+		},
+	}
+
+	encoded := make(map[string][]byte)
+	for name, document := range documents {
+		content, err := json.MarshalIndent(document, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		encoded[name] = content
+	}
+	return encoded, nil
+}
@@ -0,0 +1,302 @@
+package ghosts
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Number of candidate planes evaluated to extract every surface
+const ransacIterations = 500
+
+// Max number of voxels the candidate planes are scored against
+const ransacSampleSize = 20000
+
+// Max number of planar surfaces checked for reflections
+const maxSurfaces = 16
+
+// Min area, in squared units of the srid of the points, of the planar surfaces checked for reflections
+const minSurfaceArea = 2.0
+
+// Min number of voxels mirrored by the opening of a surface for it to be considered reflective
+const minGhostVoxels = 10
+
+// Index of a cubic voxel of the point cloud
+type voxelKey struct {
+	x, y, z int64
+}
+
+// Number of points falling in every voxel of a point cloud
+type voxelGrid struct {
+	size   float64
+	counts map[voxelKey]int
+}
+
+func (g *voxelGrid) getKey(coordinate geometry.Coordinate) voxelKey {
+	return voxelKey{
+		x: int64(math.Floor(coordinate.X / g.size)),
+		y: int64(math.Floor(coordinate.Y / g.size)),
+		z: int64(math.Floor(coordinate.Z / g.size)),
+	}
+}
+
+func (g *voxelGrid) getCenter(key voxelKey) geometry.Coordinate {
+	return geometry.Coordinate{
+		X: (float64(key.x) + 0.5) * g.size,
+		Y: (float64(key.y) + 0.5) * g.size,
+		Z: (float64(key.z) + 0.5) * g.size,
+	}
+}
+
+// Returns true if the voxel of the given position or any of its neighbors holds points, tolerating the errors of
+// the fitted planes and of the discretization
+func (g *voxelGrid) isOccupiedNear(coordinate geometry.Coordinate) bool {
+	key := g.getKey(coordinate)
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dz := int64(-1); dz <= 1; dz++ {
+				if g.counts[voxelKey{key.x + dx, key.y + dy, key.z + dz}] > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// A planar surface of the point cloud, with the footprint of its points in its own 2D frame
+type surface struct {
+	normal     geometry.Coordinate
+	offset     float64
+	u          geometry.Coordinate
+	w          geometry.Coordinate
+	support    map[[2]int64]bool
+	uMin, uMax float64
+	wMin, wMax float64
+}
+
+// Returns the signed distance of the given position from the plane of the surface
+func (s *surface) distance(coordinate geometry.Coordinate) float64 {
+	return dot(s.normal, coordinate) - s.offset
+}
+
+// Detects the ghost points of a point cloud: the ones mirroring, through an opening of a planar surface, the points
+// on its other side, which record more returns than their reflections
+type ghostDetector struct {
+	grid     *voxelGrid
+	keys     []voxelKey
+	maxDepth float64
+	random   *rand.Rand
+}
+
+func newGhostDetector(coordinates []geometry.Coordinate, voxelSize float64, maxDepth float64) *ghostDetector {
+	grid := &voxelGrid{size: voxelSize, counts: make(map[voxelKey]int)}
+	for _, coordinate := range coordinates {
+		grid.counts[grid.getKey(coordinate)]++
+	}
+
+	// voxels are sorted so that the fitted planes, and then the detected ghosts, do not depend on the map order
+	keys := make([]voxelKey, 0, len(grid.counts))
+	for key := range grid.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].x != keys[j].x {
+			return keys[i].x < keys[j].x
+		}
+		if keys[i].y != keys[j].y {
+			return keys[i].y < keys[j].y
+		}
+		return keys[i].z < keys[j].z
+	})
+
+	return &ghostDetector{
+		grid:     grid,
+		keys:     keys,
+		maxDepth: maxDepth,
+		random:   rand.New(rand.NewSource(1)),
+	}
+}
+
+// Returns the voxels holding ghost points and the number of reflective surfaces they have been found behind
+func (d *ghostDetector) findGhostVoxels() (map[voxelKey]bool, int) {
+	ghostVoxels := make(map[voxelKey]bool)
+	reflectiveSurfaces := 0
+	for _, s := range d.findSurfaces() {
+		if ghosts := d.findMirroredVoxels(s); len(ghosts) > 0 {
+			reflectiveSurfaces++
+			for _, key := range ghosts {
+				ghostVoxels[key] = true
+			}
+		}
+	}
+	return ghostVoxels, reflectiveSurfaces
+}
+
+// Extracts with RANSAC the largest planar surfaces of the voxels, each one from the voxels not belonging to the
+// previous ones
+func (d *ghostDetector) findSurfaces() []*surface {
+	size := d.grid.size
+	minVoxels := int(minSurfaceArea / (size * size))
+	remaining := make([]geometry.Coordinate, len(d.keys))
+	for i, key := range d.keys {
+		remaining[i] = d.grid.getCenter(key)
+	}
+
+	var surfaces []*surface
+	for len(surfaces) < maxSurfaces && len(remaining) >= minVoxels && len(remaining) >= 3 {
+		sample := remaining
+		if len(sample) > ransacSampleSize {
+			sample = make([]geometry.Coordinate, ransacSampleSize)
+			for i := range sample {
+				sample[i] = remaining[d.random.Intn(len(remaining))]
+			}
+		}
+
+		var best *surface
+		bestScore := 0
+		for i := 0; i < ransacIterations; i++ {
+			candidate := newSurface(
+				remaining[d.random.Intn(len(remaining))],
+				remaining[d.random.Intn(len(remaining))],
+				remaining[d.random.Intn(len(remaining))],
+			)
+			if candidate == nil {
+				continue
+			}
+			score := 0
+			for _, center := range sample {
+				if math.Abs(candidate.distance(center)) <= size {
+					score++
+				}
+			}
+			if score > bestScore {
+				best, bestScore = candidate, score
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		var inliers, outliers []geometry.Coordinate
+		for _, center := range remaining {
+			if math.Abs(best.distance(center)) <= size {
+				inliers = append(inliers, center)
+			} else {
+				outliers = append(outliers, center)
+			}
+		}
+		if len(inliers) < minVoxels {
+			break
+		}
+		best.setSupport(inliers, size)
+		surfaces = append(surfaces, best)
+		remaining = outliers
+	}
+	return surfaces
+}
+
+// Returns the voxels on the side of the surface recording less points whose mirror image through an opening of the
+// surface, i.e. a region of its footprint without points like a glass pane, is occupied. Returns nil if the surface
+// does not mirror enough voxels to be considered reflective.
+func (d *ghostDetector) findMirroredVoxels(s *surface) []voxelKey {
+	size := d.grid.size
+	var mirrored [2][]voxelKey
+	var points [2]int
+	for _, key := range d.keys {
+		center := d.grid.getCenter(key)
+		distance := s.distance(center)
+		if math.Abs(distance) <= size || math.Abs(distance) > d.maxDepth {
+			continue
+		}
+
+		projection := add(center, scale(s.normal, -distance))
+		u, w := dot(projection, s.u), dot(projection, s.w)
+		if u < s.uMin || u > s.uMax || w < s.wMin || w > s.wMax || s.support[getSupportCell(u, w, size)] {
+			continue
+		}
+		if !d.grid.isOccupiedNear(add(center, scale(s.normal, -2*distance))) {
+			continue
+		}
+
+		side := 0
+		if distance > 0 {
+			side = 1
+		}
+		mirrored[side] = append(mirrored[side], key)
+		points[side] += d.grid.counts[key]
+	}
+
+	// reflections are recorded with less returns than the surfaces they mirror
+	ghostSide := 0
+	if points[1] < points[0] {
+		ghostSide = 1
+	}
+	if points[ghostSide] == points[1-ghostSide] || len(mirrored[ghostSide]) < minGhostVoxels {
+		return nil
+	}
+	return mirrored[ghostSide]
+}
+
+// Returns the surface of the plane through the given positions, nil if they are collinear
+func newSurface(a geometry.Coordinate, b geometry.Coordinate, c geometry.Coordinate) *surface {
+	normal := cross(add(b, scale(a, -1)), add(c, scale(a, -1)))
+	length := math.Sqrt(dot(normal, normal))
+	if length < 1e-12 {
+		return nil
+	}
+	normal = scale(normal, 1/length)
+
+	// the in-plane axes are built from the world axis least aligned with the normal
+	axis := geometry.Coordinate{X: 1}
+	if math.Abs(normal.X) > math.Abs(normal.Y) && math.Abs(normal.X) > math.Abs(normal.Z) {
+		axis = geometry.Coordinate{Y: 1}
+	}
+	u := cross(normal, axis)
+	u = scale(u, 1/math.Sqrt(dot(u, u)))
+
+	return &surface{
+		normal: normal,
+		offset: dot(normal, a),
+		u:      u,
+		w:      cross(normal, u),
+	}
+}
+
+// Records the footprint of the given voxels of the surface, and its extent, in the 2D frame of the surface
+func (s *surface) setSupport(inliers []geometry.Coordinate, size float64) {
+	s.support = make(map[[2]int64]bool)
+	s.uMin, s.wMin = math.Inf(1), math.Inf(1)
+	s.uMax, s.wMax = math.Inf(-1), math.Inf(-1)
+	for _, center := range inliers {
+		u, w := dot(center, s.u), dot(center, s.w)
+		s.support[getSupportCell(u, w, size)] = true
+		s.uMin, s.uMax = math.Min(s.uMin, u), math.Max(s.uMax, u)
+		s.wMin, s.wMax = math.Min(s.wMin, w), math.Max(s.wMax, w)
+	}
+}
+
+func getSupportCell(u float64, w float64, size float64) [2]int64 {
+	return [2]int64{int64(math.Floor(u / size)), int64(math.Floor(w / size))}
+}
+
+func dot(a geometry.Coordinate, b geometry.Coordinate) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+func cross(a geometry.Coordinate, b geometry.Coordinate) geometry.Coordinate {
+	return geometry.Coordinate{
+		X: a.Y*b.Z - a.Z*b.Y,
+		Y: a.Z*b.X - a.X*b.Z,
+		Z: a.X*b.Y - a.Y*b.X,
+	}
+}
+
+func add(a geometry.Coordinate, b geometry.Coordinate) geometry.Coordinate {
+	return geometry.Coordinate{X: a.X + b.X, Y: a.Y + b.Y, Z: a.Z + b.Z}
+}
+
+func scale(a geometry.Coordinate, factor float64) geometry.Coordinate {
+	return geometry.Coordinate{X: a.X * factor, Y: a.Y * factor, Z: a.Z * factor}
+}
@@ -0,0 +1,97 @@
+package ghosts
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"sync"
+)
+
+// Raw data of a point buffered by a ghost filter tree
+type bufferedPoint struct {
+	coordinate     geometry.Coordinate
+	r              uint8
+	g              uint8
+	b              uint8
+	intensity      uint8
+	classification uint8
+	srid           int
+	attributes     []float32
+}
+
+// Decorates a tree removing the mirrored ghost points that terrestrial scanners record behind reflective surfaces like
+// windows and mirrors. The added points are buffered until the tree is built, then the ones not detected as ghosts
+// are added to the wrapped tree, so a ghost filter tree can be used to load a single point cloud.
+type ghostFilterTree struct {
+	octree.ITree
+	voxelSize float64
+	maxDepth  float64
+	workers   int
+	points    []*bufferedPoint
+	sync.Mutex
+}
+
+// Wraps the given tree so that the ghost points lying up to the given depth behind the reflective surfaces are
+// detected on voxels of the given size, both expressed in units of the srid of the points, and discarded. The kept
+// points are added to the wrapped tree by the given number of goroutines, one per CPU if 0.
+func NewGhostFilterTree(tree octree.ITree, voxelSize float64, maxDepth float64, workers int) octree.ITree {
+	return &ghostFilterTree{
+		ITree:     tree,
+		voxelSize: voxelSize,
+		maxDepth:  maxDepth,
+		workers:   tiler.WorkersOrNumCPU(workers),
+	}
+}
+
+func (t *ghostFilterTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	point := &bufferedPoint{*coordinate, r, g, b, intensity, classification, srid, attributes}
+	t.Lock()
+	t.points = append(t.points, point)
+	t.Unlock()
+}
+
+// Removes the ghost points from the buffered ones and builds the wrapped tree with the remaining ones
+func (t *ghostFilterTree) Build() error {
+	coordinates := make([]geometry.Coordinate, len(t.points))
+	for i, point := range t.points {
+		coordinates[i] = point.coordinate
+	}
+	detector := newGhostDetector(coordinates, t.voxelSize, t.maxDepth)
+	ghostVoxels, surfaces := detector.findGhostVoxels()
+
+	kept := t.points[:0]
+	for _, point := range t.points {
+		if !ghostVoxels[detector.grid.getKey(point.coordinate)] {
+			kept = append(kept, point)
+		}
+	}
+	if removed := len(t.points) - len(kept); removed > 0 {
+		tools.LogOutput("> removed", removed, "mirrored ghost points behind", surfaces, "reflective surfaces")
+	}
+	t.points = nil
+
+	t.addPoints(kept)
+	return t.ITree.Build()
+}
+
+// Adds the given points to the wrapped tree splitting them among the workers
+func (t *ghostFilterTree) addPoints(points []*bufferedPoint) {
+	chunkSize := (len(points) + t.workers - 1) / t.workers
+
+	var waitGroup sync.WaitGroup
+	for start := 0; start < len(points); start += chunkSize {
+		end := start + chunkSize
+		if end > len(points) {
+			end = len(points)
+		}
+		waitGroup.Add(1)
+		go func(chunk []*bufferedPoint) {
+			defer waitGroup.Done()
+			for _, p := range chunk {
+				t.ITree.AddPoint(&p.coordinate, p.r, p.g, p.b, p.intensity, p.classification, p.srid, p.attributes)
+			}
+		}(points[start:end])
+	}
+	waitGroup.Wait()
+}
@@ -0,0 +1,68 @@
+package io
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+)
+
+// Length of the header of the composite tiles
+const cmptHeaderLength = 16
+
+// Returns a composite tile concatenating the given inner tiles, whose byte lengths have to be multiples of 8 so that
+// every inner tile starts on an 8-byte boundary
+func generateCmpt(tiles [][]byte) ([]byte, error) {
+	byteLength := cmptHeaderLength
+	for _, tile := range tiles {
+		byteLength += len(tile)
+	}
+	encoder := tools.NewBinaryEncoder(binary.LittleEndian, byteLength)
+	encoder.WriteString("cmpt")
+	encoder.WriteUint32(1)
+	encoder.WriteLength32(byteLength)
+	encoder.WriteLength32(len(tiles))
+	for _, tile := range tiles {
+		encoder.RequireAlignment(8, "inner tile of the composite tile")
+		encoder.WriteBytes(tile)
+	}
+	encoder.RequireAlignment(8, "end of the composite tile")
+	return encoder.Bytes()
+}
+
+// Returns true if the given tile content is a composite tile
+func isCmpt(content []byte) bool {
+	return len(content) >= 4 && string(content[0:4]) == "cmpt"
+}
+
+// Reads the points of the pnts and glb inner tiles of a composite tile, as written by the tiler
+func readCmpt(content []byte) ([]pntsPoint, error) {
+	if len(content) < cmptHeaderLength || !isCmpt(content) {
+		return nil, errors.New("not a cmpt file")
+	}
+	tilesLength := int(binary.LittleEndian.Uint32(content[12:16]))
+	var points []pntsPoint
+	offset := cmptHeaderLength
+	for i := 0; i < tilesLength; i++ {
+		if offset+12 > len(content) {
+			return nil, errors.New("truncated cmpt file")
+		}
+		byteLength := int(binary.LittleEndian.Uint32(content[offset+8 : offset+12]))
+		if byteLength < 12 || offset+byteLength > len(content) {
+			return nil, errors.New("truncated cmpt file")
+		}
+		tile := content[offset : offset+byteLength]
+		readContent := readPnts
+		if isGlb(tile) {
+			readContent = readGlb
+		} else if isCmpt(tile) {
+			readContent = readCmpt
+		}
+		tilePoints, err := readContent(tile)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, tilePoints...)
+		offset += byteLength
+	}
+	return points, nil
+}
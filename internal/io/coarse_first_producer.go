@@ -0,0 +1,79 @@
+package io
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"path"
+	"strconv"
+	"sync"
+)
+
+// Submits the tiles of the tree level by level, starting from the root, waiting for all the tiles of a level to be
+// written before submitting the next one, so that a partially written or uploaded tileset is already viewable at its
+// coarse levels while the deeper ones are being written. The written levels are recorded in the manifest.
+type CoarseFirstProducer struct {
+	*StandardProducer
+	manifest *WriteManifest
+}
+
+// A node waiting to be submitted and the folders of its tile
+type pendingTile struct {
+	node           octree.INode
+	basePath       string
+	thumbnailsPath string
+}
+
+func NewCoarseFirstProducer(basepath string, subfolder string, options *tiler.TilerOptions, manifest *WriteManifest) Producer {
+	return &CoarseFirstProducer{
+		StandardProducer: NewStandardProducer(basepath, subfolder, options).(*StandardProducer),
+		manifest:         manifest,
+	}
+}
+
+// Parses a tree node breadth first and submits WorkUnits the the provided workchannel. Should be called only on the
+// tree root node. Closes the channel when all work is submitted.
+func (p *CoarseFirstProducer) Produce(work chan *WorkUnit, wg *sync.WaitGroup, node octree.INode) {
+	level := []pendingTile{{node: node, basePath: p.basePath, thumbnailsPath: p.thumbnailsPath}}
+	for len(level) > 0 && !p.isCancelled() {
+		var written sync.WaitGroup
+		var next []pendingTile
+		tiles := 0
+		for _, tile := range level {
+			if tile.node.NumberOfPoints() > 0 {
+				written.Add(1)
+				work <- &WorkUnit{
+					Node:          tile.node,
+					BasePath:      tile.basePath,
+					RootPath:      p.basePath,
+					Opts:          p.options,
+					ThumbnailPath: tile.thumbnailsPath,
+					Written:       &written,
+				}
+				tiles++
+			}
+			for i, child := range tile.node.GetChildren() {
+				if child != nil && child.IsInitialized() {
+					childThumbnailsPath := ""
+					if tile.thumbnailsPath != "" {
+						childThumbnailsPath = path.Join(tile.thumbnailsPath, strconv.Itoa(i))
+					}
+					next = append(next, pendingTile{node: child, basePath: path.Join(tile.basePath, strconv.Itoa(i)), thumbnailsPath: childThumbnailsPath})
+				}
+			}
+		}
+		written.Wait()
+		if p.isCancelled() {
+			break
+		}
+		if p.manifest != nil {
+			p.manifest.LevelWritten(tiles)
+		}
+		level = next
+	}
+	close(work)
+	wg.Done()
+}
+
+func (p *CoarseFirstProducer) isCancelled() bool {
+	return p.options != nil && p.options.Cancellation.IsCancelled()
+}
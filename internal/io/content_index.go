@@ -0,0 +1,127 @@
+package io
+
+import (
+	"crypto/sha256"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// Index of the paths of the tile contents of a tileset, shared by the consumers writing it. Contents are named after
+// the given uri template, if any, and when deduplicating only the first of several byte-identical contents, detected
+// by their SHA-256 checksum, is written and the tiles of the others point to it. Directories can be sharded so that
+// they do not exceed a max number of entries. As the tileset.json files can reference a content only once its path is
+// known, they are written after all the contents of the tileset.
+type ContentIndex struct {
+	deduplicate bool
+	template    *UriTemplate
+	shards      *directoryShards
+	byChecksum  map[[sha256.Size]byte]string
+	// paths the contents are written at, by the default path of their tile content file
+	locations map[string]string
+	// paths of the written contents
+	contents   map[string]bool
+	duplicates int
+	tilesets   []*WorkUnit
+	sync.Mutex
+}
+
+// Creates an index naming the contents after the given uri template, or with the default content file name if nil,
+// and writing byte-identical contents only once if deduplicate is true
+func NewContentIndex(deduplicate bool, template *UriTemplate) *ContentIndex {
+	return &ContentIndex{
+		deduplicate: deduplicate,
+		template:    template,
+		byChecksum:  make(map[[sha256.Size]byte]string),
+		locations:   make(map[string]string),
+		contents:    make(map[string]bool),
+	}
+}
+
+// Creates an index like NewContentIndex that also spreads the entries of the directories of the tileset in shard
+// folders so that none of them holds more than the given number of entries, that must not be lower than
+// MinDirectoryEntries
+func NewShardedContentIndex(deduplicate bool, template *UriTemplate, maxDirectoryEntries int) *ContentIndex {
+	index := NewContentIndex(deduplicate, template)
+	index.shards = newDirectoryShards(maxDirectoryEntries)
+	return index
+}
+
+// Registers the content of the tile of the given work unit, whose default path is given, returning the path it has to
+// be written at and false if an identical content has already been written at that path
+func (i *ContentIndex) add(workUnit *WorkUnit, defaultPath string, content []byte) (string, bool) {
+	checksum := sha256.Sum256(content)
+	relativePath, err := filepath.Rel(workUnit.RootPath, defaultPath)
+	if err != nil {
+		relativePath = getContentFileName(workUnit.Opts)
+	}
+	if i.template != nil {
+		tilePath, err := filepath.Rel(workUnit.RootPath, workUnit.BasePath)
+		if err != nil {
+			tilePath = "."
+		}
+		relativePath = i.template.expand(tilePath, checksum[:])
+	}
+
+	i.Lock()
+	defer i.Unlock()
+	if i.deduplicate {
+		if original, ok := i.byChecksum[checksum]; ok {
+			i.locations[defaultPath] = original
+			i.duplicates++
+			return original, false
+		}
+	}
+
+	filePath := defaultPath
+	if i.shards != nil {
+		filePath = i.shards.place(workUnit.RootPath, filepath.ToSlash(relativePath))
+	} else if i.template != nil {
+		filePath = path.Join(workUnit.RootPath, relativePath)
+	}
+	if i.deduplicate {
+		i.byChecksum[checksum] = filePath
+	}
+	if filePath != defaultPath {
+		i.locations[defaultPath] = filePath
+	}
+	i.contents[filePath] = true
+	return filePath, true
+}
+
+// Returns the uri, relative to the given folder, of the content written for the tile whose default content uri is
+// given
+func (i *ContentIndex) resolve(folder string, uri string) string {
+	i.Lock()
+	location, ok := i.locations[path.Join(folder, uri)]
+	i.Unlock()
+	if !ok {
+		return uri
+	}
+	relative, err := filepath.Rel(folder, location)
+	if err != nil {
+		return uri
+	}
+	return filepath.ToSlash(relative)
+}
+
+// Returns true if a tile content has been registered at the given path
+func (i *ContentIndex) IsContent(filePath string) bool {
+	i.Lock()
+	defer i.Unlock()
+	return i.contents[path.Clean(filePath)]
+}
+
+// Holds the given work unit until the tileset.json files are written
+func (i *ContentIndex) deferTileset(workUnit *WorkUnit) {
+	i.Lock()
+	i.tilesets = append(i.tilesets, workUnit)
+	i.Unlock()
+}
+
+// Returns the number of tile contents not written as identical to another one
+func (i *ContentIndex) Duplicates() int {
+	i.Lock()
+	defer i.Unlock()
+	return i.duplicates
+}
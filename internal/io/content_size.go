@@ -0,0 +1,46 @@
+package io
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+)
+
+// Max size in bytes of a tile content, whose pnts, glb and cmpt headers store its byte length as a 32 bit unsigned
+// integer
+const MaxContentSize = math.MaxUint32
+
+// Approximate size in bytes of the entry of a tile in the tileset.json files
+const tilesetEntrySize = 450
+
+// Approximate size in bytes of the headers of the pnts and glb tile contents, whose JSON describes the layout of the points
+const (
+	pntsHeaderSize = 300
+	glbHeaderSize  = 1500
+)
+
+// Returns the estimated size in bytes of a point in the tile contents written with the given options, storing the
+// given number of supplementary attributes
+func EstimatePointSize(opts *tiler.TilerOptions, attributes int) int64 {
+	if opts.TilesetVersion == tiler.TilesetVersion11 {
+		// positions, colors padded to 4 bytes, feature ids, intensities and classifications
+		return int64(12 + 4 + 4 + 2 + 4*attributes)
+	}
+	size := int64(12 + 3)
+	if opts.Alpha != "" && opts.Alpha != tiler.AlphaNone {
+		size++
+	}
+	if opts.BatchTable == tiler.BatchTableJson {
+		// values written as comma separated decimal numbers
+		return size + 8 + 12*int64(attributes)
+	}
+	return size + 2 + 4*int64(attributes)
+}
+
+// Returns the estimated size in bytes of the headers of a tile content written with the given options and of its entry
+// in the tileset.json files
+func EstimateTileOverhead(opts *tiler.TilerOptions) int64 {
+	if opts.TilesetVersion == tiler.TilesetVersion11 {
+		return glbHeaderSize + tilesetEntrySize
+	}
+	return pntsHeaderSize + tilesetEntrySize
+}
@@ -0,0 +1,184 @@
+package io
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Default extension of the binary tile content files
+const defaultContentExtension = ".pnts"
+
+// Default extension of the glb tile contents of the 3D Tiles 1.1 tilesets
+const glbContentExtension = ".glb"
+
+// Extension of the composite tile contents bundling leaf tiles of the 3D Tiles 1.0 tilesets
+const cmptContentExtension = ".cmpt"
+
+// Content type declared for binary tile contents, Cesium does not require a more specific one
+const binaryContentType = "application/octet-stream"
+
+// Content type of the glb tile contents
+const glbContentType = "model/gltf-binary"
+
+// Content type of the tileset.json files
+const jsonContentType = "application/json"
+
+// Returns the name of the tile content file according to the output settings in the given options
+func getContentFileName(opts *tiler.TilerOptions) string {
+	if opts.ExtensionlessContent {
+		return "content"
+	}
+
+	return "content" + getContentExtension(opts)
+}
+
+// Returns the name of the tile content files written with the given options
+func GetContentFileName(opts *tiler.TilerOptions) string {
+	return getContentFileName(opts)
+}
+
+// Returns the name of the composite tile content files bundling leaf tiles of the 3D Tiles 1.0 tilesets, that is the
+// name of the other content files for the 3D Tiles 1.1 ones, whose bundles are glb files
+func GetBundleFileName(opts *tiler.TilerOptions) string {
+	if opts.ExtensionlessContent || opts.TilesetVersion == tiler.TilesetVersion11 {
+		return getContentFileName(opts)
+	}
+	return "content" + cmptContentExtension
+}
+
+// Returns the name of the content file of the given tile
+func getTileContentFileName(node octree.INode, opts *tiler.TilerOptions) string {
+	if _, ok := node.(octree.BundleNode); ok {
+		return GetBundleFileName(opts)
+	}
+	return getContentFileName(opts)
+}
+
+// Returns the extension to use for tile content files, always including the leading dot
+func getContentExtension(opts *tiler.TilerOptions) string {
+	extension := strings.TrimSpace(opts.ContentExtension)
+	if extension == "" && opts.TilesetVersion == tiler.TilesetVersion11 {
+		return glbContentExtension
+	} else if extension == "" {
+		return defaultContentExtension
+	}
+	if !strings.HasPrefix(extension, ".") {
+		extension = "." + extension
+	}
+
+	return extension
+}
+
+// Returns the absolute url of the tile content at the given path, made of the given base url followed by the path of the
+// content relative to the given output folder
+func getAbsoluteContentUrl(baseUrl string, outputFolder string, contentPath string) (string, error) {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		return "", err
+	}
+	// without the trailing slash the last element of the base path would be replaced by the content path
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	relative, err := filepath.Rel(outputFolder, contentPath)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(&url.URL{Path: filepath.ToSlash(relative)}).String(), nil
+}
+
+// Returns the content type of the tile contents written with the given options
+func getBinaryContentType(opts *tiler.TilerOptions) string {
+	if opts.TilesetVersion == tiler.TilesetVersion11 {
+		return glbContentType
+	}
+	return binaryContentType
+}
+
+// Generates the tileset content object pointing to the given uri. Extensionless contents explicitly declare their
+// content type in the extras as hosts cannot infer it from the file name
+func getContent(uri string, opts *tiler.TilerOptions) Content {
+	content := Content{Url: uri}
+	if opts.ExtensionlessContent {
+		content.Extras = map[string]string{"contentType": getBinaryContentType(opts)}
+	}
+
+	return content
+}
+
+// Writes in the given folder configuration snippets for nginx, Apache and IIS that map the tileset files to the correct
+// content types, for servers that don't know the .pnts or .glb extensions or don't serve extensionless files properly
+func WriteHostConfigFiles(folder string, opts *tiler.TilerOptions) error {
+	extension, contentType := getContentExtension(opts), getBinaryContentType(opts)
+
+	files := map[string]string{
+		"nginx-mime.conf": generateNginxConfig(extension, contentType, opts.ExtensionlessContent),
+		".htaccess":       generateApacheConfig(extension, contentType, opts.ExtensionlessContent),
+		"web.config":      generateIisConfig(extension, contentType, opts.ExtensionlessContent),
+	}
+
+	for name, content := range files {
+		err := ioutil.WriteFile(path.Join(folder, name), []byte(content), 0666)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func generateNginxConfig(extension string, contentType string, extensionless bool) string {
+	sb := "# include this file in the server or location block that serves the tileset\n"
+	sb += "types {\n"
+	sb += "    " + contentType + " " + strings.TrimPrefix(extension, ".") + ";\n"
+	sb += "    " + jsonContentType + " json;\n"
+	sb += "}\n"
+	if extensionless {
+		sb += "location ~ /content$ {\n"
+		sb += "    default_type " + contentType + ";\n"
+		sb += "}\n"
+	}
+
+	return sb
+}
+
+func generateApacheConfig(extension string, contentType string, extensionless bool) string {
+	sb := "AddType " + contentType + " " + extension + "\n"
+	sb += "AddType " + jsonContentType + " .json\n"
+	if extensionless {
+		sb += "<FilesMatch \"^content$\">\n"
+		sb += "    ForceType " + contentType + "\n"
+		sb += "</FilesMatch>\n"
+	}
+
+	return sb
+}
+
+func generateIisConfig(extension string, contentType string, extensionless bool) string {
+	mimeMaps := map[string]string{extension: contentType, ".json": jsonContentType}
+	if extensionless {
+		// IIS maps extensionless files with the "." extension
+		mimeMaps["."] = contentType
+	}
+
+	sb := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"
+	sb += "<configuration>\n"
+	sb += "  <system.webServer>\n"
+	sb += "    <staticContent>\n"
+	for _, ext := range []string{extension, ".json", "."} {
+		if mimeType, ok := mimeMaps[ext]; ok {
+			sb += "      <remove fileExtension=\"" + ext + "\" />\n"
+			sb += "      <mimeMap fileExtension=\"" + ext + "\" mimeType=\"" + mimeType + "\" />\n"
+		}
+	}
+	sb += "    </staticContent>\n"
+	sb += "  </system.webServer>\n"
+	sb += "</configuration>\n"
+
+	return sb
+}
@@ -0,0 +1,101 @@
+package io
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Name of the folders holding the entries of a directory exceeding its capacity
+const shardFolderName = "_shard"
+
+// Entries reserved in the octant folders of a tileset, for the nested octant folders, the tileset.json file and the
+// files written next to the root tileset
+const reservedOctantFolderEntries = 16
+
+// Min number of entries per directory that can be configured, leaving room for the reserved ones
+const MinDirectoryEntries = 2 * reservedOctantFolderEntries
+
+// Spreads the entries of the directories of a tileset in nested shard folders, so that none of them holds more than
+// the given number of entries. The first entries of a directory are held directly while the following ones are
+// moved to a chain of shard folders, each holding full trees of shard folders of increasing depth, so that the number
+// of nested folders grows with the logarithm of the number of entries.
+type directoryShards struct {
+	maxEntries int
+	// actual paths of the directories, by their path before sharding
+	directories map[string]string
+	// number of entries of the directories, by their path before sharding
+	entries map[string]int
+}
+
+func newDirectoryShards(maxEntries int) *directoryShards {
+	return &directoryShards{
+		maxEntries:  maxEntries,
+		directories: make(map[string]string),
+		entries:     make(map[string]int),
+	}
+}
+
+// Returns the path the file at the given path, relative to the given root folder, has to be written at. Not safe
+// for concurrent use.
+func (s *directoryShards) place(rootPath string, relativePath string) string {
+	elements := strings.Split(relativePath, "/")
+	directory := "."
+	actualDirectory := "."
+	for _, element := range elements {
+		entry := path.Join(directory, element)
+		actualEntry, ok := s.directories[entry]
+		if !ok {
+			index := s.entries[directory]
+			s.entries[directory]++
+			shards := getShardPath(index, s.getCapacity(actualDirectory), s.maxEntries)
+			actualEntry = path.Join(append(append([]string{actualDirectory}, shards...), element)...)
+			s.directories[entry] = actualEntry
+		}
+		directory, actualDirectory = entry, actualEntry
+	}
+	return path.Join(rootPath, actualDirectory)
+}
+
+// Returns the number of entries the given directory, relative to the tileset root, can hold besides the ones
+// written outside of the index
+func (s *directoryShards) getCapacity(directory string) int {
+	if directory == "." {
+		return s.maxEntries - reservedOctantFolderEntries
+	}
+	for _, element := range strings.Split(directory, "/") {
+		if len(element) != 1 || element[0] < '0' || element[0] > '7' {
+			return s.maxEntries
+		}
+	}
+	return s.maxEntries - reservedOctantFolderEntries
+}
+
+// Returns the shard folders holding the entry with the given index of a directory with the given capacity.
+// The directory holds its first entries and a shard folder, which holds fanout-1 trees of shard folders with fanout
+// entries each and another shard folder holding fanout-1 deeper trees, and so on.
+func getShardPath(index int, capacity int, fanout int) []string {
+	if index < capacity-1 {
+		return nil
+	}
+	index -= capacity - 1
+
+	var shards []string
+	size := fanout
+	for depth := 1; ; depth++ {
+		shards = append(shards, shardFolderName)
+		if index < (fanout-1)*size {
+			shards = append(shards, strconv.Itoa(index/size))
+			// the last digit is the position of the entry in the deepest folder of the tree
+			position := (index % size) / fanout
+			digits := make([]string, depth-1)
+			for i := depth - 2; i >= 0; i-- {
+				digits[i] = strconv.Itoa(position % fanout)
+				position /= fanout
+			}
+			return append(shards, digits...)
+		}
+		index -= (fanout - 1) * size
+		size *= fanout
+	}
+}
@@ -0,0 +1,171 @@
+package io
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/draco"
+	"strings"
+)
+
+// Name of the 3D Tiles extension of the pnts contents whose points are compressed with Draco
+const dracoPointCompressionExtension = "3DTILES_draco_point_compression"
+
+// Properties of a pnts feature or batch table compressed with Draco, mapped to the unique ids of the Draco attributes
+type dracoPointCompression struct {
+	Properties map[string]int `json:"properties"`
+	ByteOffset int            `json:"byteOffset"`
+	ByteLength int            `json:"byteLength"`
+}
+
+// Returns the JSON header and the binary body of the feature table and the JSON header of the batch table of a pnts
+// content whose points are compressed with Draco, the positions and supplementary attributes being quantized to the
+// given number of bits and the colors and intensities reduced to them
+func generateDracoTables(data *intermediateData, center []float64, quantization map[string]int) ([]byte, []byte, []byte, error) {
+	positions := make([]float32, len(data.coords))
+	for i, coord := range data.coords {
+		positions[i] = float32(coord)
+	}
+	attributes := []*draco.Attribute{{Type: draco.Position, Components: 3, Floats: positions, QuantizationBits: quantization["POSITION"]}}
+	featureTableProperties := map[string]int{"POSITION": 0}
+	featureTable := map[string]interface{}{
+		"POINTS_LENGTH": data.numPoints,
+		"RTC_CENTER":    center,
+		"POSITION":      pntsProperty{},
+	}
+	if data.colorComponents > 0 {
+		colorSemantic := "RGB"
+		if data.colorComponents == 4 {
+			colorSemantic = "RGBA"
+		}
+		featureTableProperties[colorSemantic] = len(attributes)
+		featureTable[colorSemantic] = pntsProperty{}
+		attributes = append(attributes, &draco.Attribute{Type: draco.Color, Components: data.colorComponents, Normalized: true, Bytes: reduceBits(data.colors, quantization["COLOR"])})
+	}
+
+	batchTableProperties := map[string]int{"INTENSITY": len(attributes), "CLASSIFICATION": len(attributes) + 1}
+	batchTable := map[string]interface{}{
+		"INTENSITY":      dracoBatchTableProperty{ComponentType: "UNSIGNED_BYTE", Type: "SCALAR"},
+		"CLASSIFICATION": dracoBatchTableProperty{ComponentType: "UNSIGNED_BYTE", Type: "SCALAR"},
+	}
+	attributes = append(attributes,
+		&draco.Attribute{Type: draco.Generic, Components: 1, Bytes: reduceBits(data.intensities, quantization["INTENSITY"])},
+		&draco.Attribute{Type: draco.Generic, Components: 1, Bytes: data.classifications},
+	)
+	for j, name := range data.attributeNames {
+		batchTableProperties[name] = len(attributes)
+		batchTable[name] = dracoBatchTableProperty{ComponentType: "FLOAT", Type: "SCALAR"}
+		attributes = append(attributes, &draco.Attribute{Type: draco.Generic, Components: 1, Floats: data.attributes[j], QuantizationBits: quantization[name]})
+	}
+
+	compressed, err := draco.Encode(data.numPoints, attributes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	featureTable["extensions"] = map[string]interface{}{
+		dracoPointCompressionExtension: dracoPointCompression{Properties: featureTableProperties, ByteLength: len(compressed)},
+	}
+	batchTable["extensions"] = map[string]interface{}{
+		dracoPointCompressionExtension: map[string]interface{}{"properties": batchTableProperties},
+	}
+	featureTableJson, err := marshalPaddedJson(featureTable)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	batchTableJson, err := marshalPaddedJson(batchTable)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return featureTableJson, compressed, batchTableJson, nil
+}
+
+// Declaration of a batch table property compressed with Draco, whose values are not stored in the binary body
+type dracoBatchTableProperty struct {
+	ByteOffset    int    `json:"byteOffset"`
+	ComponentType string `json:"componentType"`
+	Type          string `json:"type"`
+}
+
+// Returns the JSON encoding of the value padded with spaces to a multiple of 4 bytes
+func marshalPaddedJson(value interface{}) ([]byte, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if padding := len(content) % 4; padding != 0 {
+		content = append(content, strings.Repeat(" ", 4-padding)...)
+	}
+	return content, nil
+}
+
+// Keeps the given number of most significant bits of the values, replacing the dropped ones by the middle of the range
+// they span. All the bits are kept if the number is 0 or at least 8.
+func reduceBits(values []uint8, bits int) []uint8 {
+	if bits <= 0 || bits >= 8 {
+		return values
+	}
+	shift := uint(8 - bits)
+	reduced := make([]uint8, len(values))
+	for i, value := range values {
+		reduced[i] = value>>shift<<shift | 1<<(shift-1)
+	}
+	return reduced
+}
+
+// Returns the points of a pnts content compressed with Draco, whose feature table and batch table properties are mapped
+// to the attributes of the Draco point cloud stored in the binary body of the feature table
+func readDracoPnts(featureTable *pntsFeatureTable, batchTable *pntsBatchTable, featureTableBinary []byte) ([]pntsPoint, error) {
+	extension := featureTable.Extensions.Draco
+	if extension.ByteOffset < 0 || extension.ByteLength < 0 || extension.ByteOffset+extension.ByteLength > len(featureTableBinary) {
+		return nil, errDracoPnts
+	}
+	cloud, err := draco.Decode(featureTableBinary[extension.ByteOffset : extension.ByteOffset+extension.ByteLength])
+	if err != nil {
+		return nil, err
+	}
+	getAttribute := func(properties map[string]int, name string, floats bool, components int) *draco.Attribute {
+		uniqueId, ok := properties[name]
+		if !ok {
+			return nil
+		}
+		attribute := cloud.GetAttribute(uniqueId)
+		if attribute == nil || attribute.Components != components || (attribute.Floats != nil) != floats {
+			return nil
+		}
+		return attribute
+	}
+	positions := getAttribute(extension.Properties, "POSITION", true, 3)
+	if positions == nil {
+		return nil, errDracoPnts
+	}
+	colors, colorComponents := getAttribute(extension.Properties, "RGB", false, 3), 3
+	if colors == nil {
+		colors, colorComponents = getAttribute(extension.Properties, "RGBA", false, 4), 4
+	}
+	var intensities, classifications *draco.Attribute
+	if batchTable.Extensions.Draco != nil {
+		intensities = getAttribute(batchTable.Extensions.Draco.Properties, "INTENSITY", false, 1)
+		classifications = getAttribute(batchTable.Extensions.Draco.Properties, "CLASSIFICATION", false, 1)
+	}
+
+	var center [3]float64
+	if len(featureTable.RtcCenter) == 3 {
+		copy(center[:], featureTable.RtcCenter)
+	}
+	points := make([]pntsPoint, cloud.NumPoints)
+	for i := range points {
+		point := &points[i]
+		point.X = center[0] + float64(positions.Floats[i*3])
+		point.Y = center[1] + float64(positions.Floats[i*3+1])
+		point.Z = center[2] + float64(positions.Floats[i*3+2])
+		if colors != nil {
+			color := colors.Bytes[i*colorComponents:]
+			point.R, point.G, point.B = color[0], color[1], color[2]
+		}
+		if intensities != nil {
+			point.Intensity = intensities.Bytes[i]
+		}
+		if classifications != nil {
+			point.Classification = classifications.Bytes[i]
+		}
+	}
+	return points, nil
+}
@@ -0,0 +1,298 @@
+package io
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"math"
+)
+
+// Magic, version, header length and chunk types of the glb container
+const (
+	glbMagic        = "glTF"
+	glbVersion      = 2
+	glbHeaderLength = 12
+	glbJsonChunk    = 0x4E4F534A
+	glbBinChunk     = 0x004E4942
+)
+
+// glTF constants of the point and line primitives, accessor component types and buffer view targets
+const (
+	gltfModePoints         = 0
+	gltfModeLines          = 1
+	gltfUnsignedByte       = 5121
+	gltfUnsignedInt        = 5125
+	gltfFloat              = 5126
+	gltfArrayBuffer        = 34962
+	gltfElementArrayBuffer = 34963
+	meshFeaturesExt        = "EXT_mesh_features"
+	structuralMetadataExt  = "EXT_structural_metadata"
+)
+
+// Class of the properties of the points in the property table of the glb contents
+const pointPropertiesClass = "point"
+
+// Supported subset of a glTF document, holding a single mesh with a point primitive
+type gltfDocument struct {
+	Asset          gltfAsset               `json:"asset"`
+	ExtensionsUsed []string                `json:"extensionsUsed,omitempty"`
+	Extensions     *gltfDocumentExtensions `json:"extensions,omitempty"`
+	Scene          int                     `json:"scene"`
+	Scenes         []gltfScene             `json:"scenes"`
+	Nodes          []gltfNode              `json:"nodes"`
+	Meshes         []gltfMesh              `json:"meshes,omitempty"`
+	Accessors      []gltfAccessor          `json:"accessors,omitempty"`
+	BufferViews    []gltfBufferView        `json:"bufferViews,omitempty"`
+	Buffers        []gltfBuffer            `json:"buffers,omitempty"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfDocumentExtensions struct {
+	StructuralMetadata *structuralMetadata `json:"EXT_structural_metadata,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Mesh        *int      `json:"mesh,omitempty"`
+	Translation []float64 `json:"translation,omitempty"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int           `json:"attributes"`
+	Indices    *int                     `json:"indices,omitempty"`
+	Mode       int                      `json:"mode"`
+	Extensions *gltfPrimitiveExtensions `json:"extensions,omitempty"`
+}
+
+type gltfPrimitiveExtensions struct {
+	MeshFeatures *meshFeatures `json:"EXT_mesh_features,omitempty"`
+}
+
+type meshFeatures struct {
+	FeatureIds []featureId `json:"featureIds"`
+}
+
+// Feature ids of the vertices, stored in the _FEATURE_ID_<attribute> vertex attribute and indexing the rows of the
+// property table
+type featureId struct {
+	FeatureCount  int `json:"featureCount"`
+	Attribute     int `json:"attribute"`
+	PropertyTable int `json:"propertyTable"`
+}
+
+type structuralMetadata struct {
+	Schema         *Schema         `json:"schema"`
+	PropertyTables []propertyTable `json:"propertyTables"`
+}
+
+type propertyTable struct {
+	Class      string                           `json:"class"`
+	Count      int                              `json:"count"`
+	Properties map[string]propertyTableProperty `json:"properties"`
+}
+
+// Property of a property table, whose values are stored in the given buffer view
+type propertyTableProperty struct {
+	Values int `json:"values"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ByteOffset    int       `json:"byteOffset,omitempty"`
+	ComponentType int       `json:"componentType"`
+	Normalized    bool      `json:"normalized,omitempty"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride,omitempty"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+// Builds the binary chunk of a glb content, of a single buffer holding all the buffer views
+type glbBuilder struct {
+	document *gltfDocument
+	binary   *tools.BinaryEncoder
+}
+
+// Starts a buffer view, aligned on an 8-byte boundary as required by the property tables, returning its byte offset.
+// The data of the view is then written by the binary encoder.
+func (b *glbBuilder) beginBufferView() int {
+	b.binary.Pad(8, 0)
+	return b.binary.Len()
+}
+
+// Ends the buffer view started at the given byte offset, returning its index. As every vertex attribute element has
+// to be aligned on a 4-byte boundary, the views of the vertex attributes have to end on one too.
+func (b *glbBuilder) endBufferView(byteOffset int, byteStride int, target int) int {
+	if target == gltfArrayBuffer {
+		b.binary.RequireAlignment(4, "vertex attribute buffer view")
+	}
+	b.document.BufferViews = append(b.document.BufferViews, gltfBufferView{ByteOffset: byteOffset, ByteLength: b.binary.Len() - byteOffset, ByteStride: byteStride, Target: target})
+	return len(b.document.BufferViews) - 1
+}
+
+// Appends a buffer view holding the given data, returning its index
+func (b *glbBuilder) addBufferView(data []byte, byteStride int, target int) int {
+	byteOffset := b.beginBufferView()
+	b.binary.WriteBytes(data)
+	return b.endBufferView(byteOffset, byteStride, target)
+}
+
+// Appends an accessor of the given buffer view, returning its index
+func (b *glbBuilder) addAccessor(accessor gltfAccessor) int {
+	b.document.Accessors = append(b.document.Accessors, accessor)
+	return len(b.document.Accessors) - 1
+}
+
+// Generates the glb content of a 3D Tiles 1.1 tile holding the given points, whose coordinates are relative to the given
+// center. The points are stored as a point primitive whose vertices have a feature id indexing the row of their
+// intensity, classification and supplementary attributes in the property table. As glTF is y-up, the coordinates are
+// rotated by -90 degrees around the x axis, which the 3D Tiles runtimes revert.
+func generateGlb(data *intermediateData, center []float64) ([]byte, error) {
+	document := &gltfDocument{
+		Asset:  gltfAsset{Version: "2.0", Generator: "gocesiumtiler"},
+		Scenes: []gltfScene{{Nodes: []int{0}}},
+		Nodes:  []gltfNode{{Translation: []float64{center[0], center[2], -center[1]}}},
+	}
+	if data.numPoints == 0 {
+		document.Nodes[0].Translation = nil
+		return encodeGlb(document, nil)
+	}
+	builder := &glbBuilder{document: document, binary: tools.NewBinaryEncoder(binary.LittleEndian, data.numPoints*(16+4+2+4*len(data.attributeNames))+64)}
+
+	positions := builder.beginBufferView()
+	min := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for i := 0; i < data.numPoints; i++ {
+		yUp := []float32{float32(data.coords[i*3]), float32(data.coords[i*3+2]), float32(-data.coords[i*3+1])}
+		builder.binary.WriteFloat32s(yUp)
+		for j, value := range yUp {
+			min[j] = math.Min(min[j], float64(value))
+			max[j] = math.Max(max[j], float64(value))
+		}
+	}
+	attributes := map[string]int{
+		"POSITION": builder.addAccessor(gltfAccessor{
+			BufferView:    builder.endBufferView(positions, 0, gltfArrayBuffer),
+			ComponentType: gltfFloat,
+			Count:         data.numPoints,
+			Type:          "VEC3",
+			Min:           min,
+			Max:           max,
+		}),
+	}
+
+	if data.colorComponents > 0 {
+		// every vertex attribute element has to be aligned on a 4-byte boundary, so RGB colors are padded to 4 bytes
+		colors := builder.beginBufferView()
+		for i := 0; i < data.numPoints; i++ {
+			builder.binary.WriteBytes(data.colors[i*data.colorComponents : (i+1)*data.colorComponents])
+			builder.binary.Pad(4, 0)
+		}
+		colorType := "VEC3"
+		if data.colorComponents == 4 {
+			colorType = "VEC4"
+		}
+		attributes["COLOR_0"] = builder.addAccessor(gltfAccessor{
+			BufferView:    builder.endBufferView(colors, 4, gltfArrayBuffer),
+			ComponentType: gltfUnsignedByte,
+			Normalized:    true,
+			Count:         data.numPoints,
+			Type:          colorType,
+		})
+	}
+
+	featureIds := builder.beginBufferView()
+	for i := 0; i < data.numPoints; i++ {
+		builder.binary.WriteFloat32s([]float32{float32(i)})
+	}
+	attributes["_FEATURE_ID_0"] = builder.addAccessor(gltfAccessor{
+		BufferView:    builder.endBufferView(featureIds, 0, gltfArrayBuffer),
+		ComponentType: gltfFloat,
+		Count:         data.numPoints,
+		Type:          "SCALAR",
+	})
+
+	class := SchemaClass{Properties: map[string]ClassProperty{
+		"INTENSITY":      {Type: "SCALAR", ComponentType: "UINT8"},
+		"CLASSIFICATION": {Type: "SCALAR", ComponentType: "UINT8"},
+	}}
+	table := propertyTable{Class: pointPropertiesClass, Count: data.numPoints, Properties: map[string]propertyTableProperty{
+		"INTENSITY":      {Values: builder.addBufferView(data.intensities, 0, 0)},
+		"CLASSIFICATION": {Values: builder.addBufferView(data.classifications, 0, 0)},
+	}}
+	for j, name := range data.attributeNames {
+		class.Properties[name] = ClassProperty{Type: "SCALAR", ComponentType: "FLOAT32"}
+		values := builder.beginBufferView()
+		builder.binary.WriteFloat32s(data.attributes[j])
+		table.Properties[name] = propertyTableProperty{Values: builder.endBufferView(values, 0, 0)}
+	}
+
+	mesh := 0
+	document.Nodes[0].Mesh = &mesh
+	document.Meshes = []gltfMesh{{Primitives: []gltfPrimitive{{
+		Attributes: attributes,
+		Mode:       gltfModePoints,
+		Extensions: &gltfPrimitiveExtensions{MeshFeatures: &meshFeatures{FeatureIds: []featureId{{FeatureCount: data.numPoints}}}},
+	}}}}
+	document.ExtensionsUsed = []string{meshFeaturesExt, structuralMetadataExt}
+	document.Extensions = &gltfDocumentExtensions{StructuralMetadata: &structuralMetadata{
+		Schema:         &Schema{Id: tileStatsSchemaId, Classes: map[string]SchemaClass{pointPropertiesClass: class}},
+		PropertyTables: []propertyTable{table},
+	}}
+	buffer, err := builder.binary.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	document.Buffers = []gltfBuffer{{ByteLength: len(buffer)}}
+	return encodeGlb(document, buffer)
+}
+
+// Returns the glb container of the given document and binary buffer, whose chunks are padded to 4-byte boundaries
+func encodeGlb(document *gltfDocument, buffer []byte) ([]byte, error) {
+	jsonChunk, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := tools.NewBinaryEncoder(binary.LittleEndian, glbHeaderLength+16+len(jsonChunk)+len(buffer)+6)
+	encoder.WriteString(glbMagic)
+	encoder.WriteUint32(glbVersion)
+	length := encoder.Reserve(4)
+	writeChunk := func(chunk []byte, chunkType uint32, padding byte) {
+		chunkLength := encoder.Reserve(4)
+		encoder.WriteUint32(chunkType)
+		start := encoder.Len()
+		encoder.WriteBytes(chunk)
+		encoder.Pad(4, padding)
+		encoder.PutLength32(chunkLength, encoder.Len()-start)
+	}
+	writeChunk(jsonChunk, glbJsonChunk, ' ')
+	if len(buffer) > 0 {
+		writeChunk(buffer, glbBinChunk, 0)
+	}
+	encoder.PutLength32(length, encoder.Len())
+	return encoder.Bytes()
+}
@@ -0,0 +1,157 @@
+package io
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+// Reads the points of a glb file holding a point primitive with float positions, optional normalized unsigned byte
+// colors, whose alpha is ignored, and the optional intensity and classification properties of the property table
+// indexed by its feature ids, as written by the tiler. The coordinates are translated by the translation of the node
+// and rotated back from the y-up frame of glTF to the z-up frame of the tileset.
+func readGlb(content []byte) ([]pntsPoint, error) {
+	if !isGlb(content) || len(content) < glbHeaderLength+8 {
+		return nil, errors.New("not a glb file")
+	}
+	if int(binary.LittleEndian.Uint32(content[8:12])) > len(content) {
+		return nil, errors.New("truncated glb file")
+	}
+	jsonLength := int(binary.LittleEndian.Uint32(content[12:16]))
+	if binary.LittleEndian.Uint32(content[16:20]) != glbJsonChunk || glbHeaderLength+8+jsonLength > len(content) {
+		return nil, errors.New("glb file without a valid JSON chunk")
+	}
+	var document gltfDocument
+	if err := json.Unmarshal(content[glbHeaderLength+8:glbHeaderLength+8+jsonLength], &document); err != nil {
+		return nil, err
+	}
+	var buffer []byte
+	if offset := glbHeaderLength + 8 + jsonLength; offset+8 <= len(content) {
+		binLength := int(binary.LittleEndian.Uint32(content[offset : offset+4]))
+		if binary.LittleEndian.Uint32(content[offset+4:offset+8]) == glbBinChunk && offset+8+binLength <= len(content) {
+			buffer = content[offset+8 : offset+8+binLength]
+		}
+	}
+
+	var points []pntsPoint
+	for _, node := range document.Nodes {
+		if node.Mesh == nil || *node.Mesh < 0 || *node.Mesh >= len(document.Meshes) {
+			continue
+		}
+		var translation [3]float64
+		if len(node.Translation) == 3 {
+			copy(translation[:], node.Translation)
+		}
+		for _, primitive := range document.Meshes[*node.Mesh].Primitives {
+			if primitive.Mode != gltfModePoints {
+				continue
+			}
+			primitivePoints, err := readGlbPrimitive(&document, &primitive, translation, buffer)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, primitivePoints...)
+		}
+	}
+	return points, nil
+}
+
+// Returns true if the given content starts with the magic of the glb files
+func isGlb(content []byte) bool {
+	return len(content) >= 4 && string(content[0:4]) == glbMagic
+}
+
+func readGlbPrimitive(document *gltfDocument, primitive *gltfPrimitive, translation [3]float64, buffer []byte) ([]pntsPoint, error) {
+	position, ok := primitive.Attributes["POSITION"]
+	if !ok {
+		return nil, errors.New("glb point primitive without positions")
+	}
+	positions, numPoints, stride := getAccessorBytes(document, position, gltfFloat, 12, buffer)
+	if positions == nil {
+		return nil, errors.New("glb point primitive without valid float positions")
+	}
+	var colors []byte
+	colorStride := 0
+	if color, ok := primitive.Attributes["COLOR_0"]; ok {
+		components := 3
+		if document.Accessors[color].Type == "VEC4" {
+			components = 4
+		}
+		colors, _, colorStride = getAccessorBytes(document, color, gltfUnsignedByte, components, buffer)
+	}
+	var featureIds []byte
+	featureIdStride := 0
+	if featureId, ok := primitive.Attributes["_FEATURE_ID_0"]; ok {
+		featureIds, _, featureIdStride = getAccessorBytes(document, featureId, gltfFloat, 4, buffer)
+	}
+	intensities, classifications := getPropertyTableBytes(document, "INTENSITY", buffer), getPropertyTableBytes(document, "CLASSIFICATION", buffer)
+
+	points := make([]pntsPoint, numPoints)
+	for i := range points {
+		point := &points[i]
+		x := translation[0] + float64(math.Float32frombits(binary.LittleEndian.Uint32(positions[i*stride:])))
+		y := translation[1] + float64(math.Float32frombits(binary.LittleEndian.Uint32(positions[i*stride+4:])))
+		z := translation[2] + float64(math.Float32frombits(binary.LittleEndian.Uint32(positions[i*stride+8:])))
+		point.X, point.Y, point.Z = x, -z, y
+		if colors != nil {
+			color := colors[i*colorStride:]
+			point.R, point.G, point.B = color[0], color[1], color[2]
+		}
+		row := i
+		if featureIds != nil {
+			row = int(math.Float32frombits(binary.LittleEndian.Uint32(featureIds[i*featureIdStride:])))
+		}
+		if row >= 0 && row < len(intensities) {
+			point.Intensity = intensities[row]
+		}
+		if row >= 0 && row < len(classifications) {
+			point.Classification = classifications[row]
+		}
+	}
+	return points, nil
+}
+
+// Returns the bytes of the elements of the given accessor, whose component type has to be the given one, together with
+// their number and the stride between them. Returns nil if the accessor does not fit in the given buffer.
+func getAccessorBytes(document *gltfDocument, index int, componentType int, elementLength int, buffer []byte) ([]byte, int, int) {
+	if index < 0 || index >= len(document.Accessors) {
+		return nil, 0, 0
+	}
+	accessor := document.Accessors[index]
+	if accessor.ComponentType != componentType || accessor.BufferView < 0 || accessor.BufferView >= len(document.BufferViews) {
+		return nil, 0, 0
+	}
+	view := document.BufferViews[accessor.BufferView]
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = elementLength
+	}
+	start := view.ByteOffset + accessor.ByteOffset
+	if accessor.Count == 0 {
+		return []byte{}, 0, stride
+	}
+	end := start + (accessor.Count-1)*stride + elementLength
+	if start < 0 || end > view.ByteOffset+view.ByteLength || end > len(buffer) {
+		return nil, 0, 0
+	}
+	return buffer[start:end], accessor.Count, stride
+}
+
+// Returns the values of the given unsigned byte property of the first property table, nil if it is missing or does not
+// fit in the given buffer
+func getPropertyTableBytes(document *gltfDocument, name string, buffer []byte) []byte {
+	if document.Extensions == nil || document.Extensions.StructuralMetadata == nil || len(document.Extensions.StructuralMetadata.PropertyTables) == 0 {
+		return nil
+	}
+	table := document.Extensions.StructuralMetadata.PropertyTables[0]
+	property, ok := table.Properties[name]
+	if !ok || property.Values < 0 || property.Values >= len(document.BufferViews) {
+		return nil
+	}
+	view := document.BufferViews[property.Values]
+	if view.ByteOffset < 0 || view.ByteOffset+table.Count > len(buffer) || table.Count > view.ByteLength {
+		return nil
+	}
+	return buffer[view.ByteOffset : view.ByteOffset+table.Count]
+}
@@ -0,0 +1,56 @@
+package io
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"path"
+	"sync"
+)
+
+// Submits the tiles of the tree to be written as an implicit tileset, the content of every tile being written in the
+// folder named after its implicit coordinates. Only the root tileset.json file is written by the consumers, the tiles
+// being declared by the subtree files written by WriteSubtrees once the tree is exported.
+type ImplicitProducer struct {
+	*StandardProducer
+}
+
+func NewImplicitProducer(basepath string, subfolder string, options *tiler.TilerOptions) Producer {
+	return &ImplicitProducer{
+		StandardProducer: NewStandardProducer(basepath, subfolder, options).(*StandardProducer),
+	}
+}
+
+// Parses a tree node and submits WorkUnits the the provided workchannel. Should be called only on the tree root node.
+// Closes the channel when all work is submitted.
+func (p *ImplicitProducer) Produce(work chan *WorkUnit, wg *sync.WaitGroup, node octree.INode) {
+	p.produceImplicit(implicitTile{}, node, work)
+	close(work)
+	wg.Done()
+}
+
+func (p *ImplicitProducer) produceImplicit(tile implicitTile, node octree.INode, work chan *WorkUnit) {
+	if p.options != nil && p.options.Cancellation.IsCancelled() {
+		return
+	}
+
+	if node.NumberOfPoints() > 0 {
+		tilePath := tile.expand("{level}/{x}/{y}/{z}")
+		thumbnailsPath := ""
+		if p.thumbnailsPath != "" {
+			thumbnailsPath = path.Join(p.thumbnailsPath, implicitContentFolder, tilePath)
+		}
+		work <- &WorkUnit{
+			Node:          node,
+			BasePath:      path.Join(p.basePath, implicitContentFolder, tilePath),
+			RootPath:      p.basePath,
+			Opts:          p.options,
+			ThumbnailPath: thumbnailsPath,
+		}
+	}
+
+	for octant, child := range node.GetChildren() {
+		if child != nil && child.IsInitialized() {
+			p.produceImplicit(tile.child(octant), child, work)
+		}
+	}
+}
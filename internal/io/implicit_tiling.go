@@ -0,0 +1,325 @@
+package io
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Folder of the implicit tilesets where the tile contents are written, in a folder per tile named after its coordinates
+const implicitContentFolder = "content"
+
+// Folder of the implicit tilesets where the subtree files are written
+const subtreesFolder = "subtrees"
+
+// Subdivision scheme of the implicit tilesets, whose tiles are split in octants like the nodes of the trees
+const implicitSubdivisionScheme = "OCTREE"
+
+// Length of the header of the subtree files
+const subtreeHeaderLength = 24
+
+// Coordinates of a tile of an implicit octree: its level and its indexes along the x, y and z axes at that level
+type implicitTile struct {
+	level int
+	x     int
+	y     int
+	z     int
+}
+
+// Returns the coordinates of the child in the given octant of the tile, the octants being numbered like the children
+// of the tree nodes: the first bit of the index selects the upper x half, the second the upper y half, the third the
+// upper z half
+func (t implicitTile) child(octant int) implicitTile {
+	return implicitTile{level: t.level + 1, x: 2*t.x + octant&1, y: 2*t.y + (octant>>1)&1, z: 2*t.z + (octant>>2)&1}
+}
+
+// Returns the given uri template with the coordinates of the tile in place of the level, x, y and z placeholders
+func (t implicitTile) expand(template string) string {
+	return strings.NewReplacer(
+		"{level}", strconv.Itoa(t.level),
+		"{x}", strconv.Itoa(t.x),
+		"{y}", strconv.Itoa(t.y),
+		"{z}", strconv.Itoa(t.z),
+	).Replace(template)
+}
+
+// Returns the coordinates of the root of the subtree of the given number of levels holding the tile
+func (t implicitTile) getSubtreeRoot(subtreeLevels int) implicitTile {
+	shift := t.level % subtreeLevels
+	return implicitTile{level: t.level - shift, x: t.x >> shift, y: t.y >> shift, z: t.z >> shift}
+}
+
+// Returns the index of the bit of the availability bitstreams of the subtree rooted at the given tile recording the
+// availability of the tile, the tiles being ordered level by level and in Morton order within every level. The index
+// in the child subtree availability is returned for the tiles right below the subtree.
+func (t implicitTile) getSubtreeIndex(root implicitTile, subtreeLevels int) int {
+	depth := t.level - root.level
+	morton := 0
+	for bit := 0; bit < depth; bit++ {
+		morton |= ((t.x-root.x<<depth)>>bit&1)<<(3*bit) | ((t.y-root.y<<depth)>>bit&1)<<(3*bit+1) | ((t.z-root.z<<depth)>>bit&1)<<(3*bit+2)
+	}
+	if depth == subtreeLevels {
+		return morton
+	}
+	return (1<<(3*depth)-1)/7 + morton
+}
+
+// Returns the number of tiles of the subtrees of the given number of levels
+func getSubtreeTiles(subtreeLevels int) int {
+	return (1<<(3*subtreeLevels) - 1) / 7
+}
+
+// Returns the template of the uris of the tile contents of the implicit tilesets, relative to the tileset folder
+func getImplicitContentUri(opts *tiler.TilerOptions) string {
+	return implicitContentFolder + "/{level}/{x}/{y}/{z}/" + getContentFileName(opts)
+}
+
+// Returns the template of the uris of the subtree files of the implicit tilesets, relative to the tileset folder
+func getSubtreeUri() string {
+	return subtreesFolder + "/{level}/{x}/{y}/{z}.subtree"
+}
+
+// Turns the given root of a tileset into the implicit root of the tree of the given node, whose descendants are
+// declared by the subtree files rather than as children. The geometric error of the implicit tiles halves at every
+// level, hence it is derived from the one of the first level of the tree.
+func setImplicitTiling(root *Root, node octree.INode, opts *tiler.TilerOptions) {
+	root.Children = nil
+	root.Content = getContent(getImplicitContentUri(opts), opts)
+	for _, child := range node.GetChildren() {
+		if child != nil && child.TotalNumberOfPoints() > 0 {
+			root.GeometricError = 2 * child.ComputeGeometricError()
+			break
+		}
+	}
+	root.ImplicitTiling = &ImplicitTiling{
+		SubdivisionScheme: implicitSubdivisionScheme,
+		SubtreeLevels:     opts.SubtreeLevels,
+		AvailableLevels:   getAvailableLevels(node),
+		Subtrees:          ImplicitSubtrees{Url: getSubtreeUri()},
+	}
+}
+
+// Returns the number of levels of the tree of the given node holding points
+func getAvailableLevels(node octree.INode) int {
+	levels := 0
+	for _, child := range node.GetChildren() {
+		if child != nil && child.TotalNumberOfPoints() > 0 {
+			if childLevels := getAvailableLevels(child); childLevels > levels {
+				levels = childLevels
+			}
+		}
+	}
+	return levels + 1
+}
+
+// Availability of the tiles, contents or child subtrees of a subtree, either constant or given by a bitstream
+type subtreeAvailability struct {
+	Bitstream *int `json:"bitstream,omitempty"`
+	Constant  *int `json:"constant,omitempty"`
+}
+
+type subtreeBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+type subtreeBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+}
+
+type subtreeJson struct {
+	Buffers                  []subtreeBuffer       `json:"buffers,omitempty"`
+	BufferViews              []subtreeBufferView   `json:"bufferViews,omitempty"`
+	TileAvailability         subtreeAvailability   `json:"tileAvailability"`
+	ContentAvailability      []subtreeAvailability `json:"contentAvailability"`
+	ChildSubtreeAvailability subtreeAvailability   `json:"childSubtreeAvailability"`
+}
+
+// Availability bits of a subtree, a bit per tile for the tiles and the contents and a bit per tile of the level right
+// below the subtree for the child subtrees
+type subtree struct {
+	tiles    []bool
+	contents []bool
+	children []bool
+}
+
+// A node of the tree to be written as the root of a subtree
+type pendingSubtree struct {
+	node octree.INode
+	tile implicitTile
+}
+
+// Writes in the given tileset folder the subtree files declaring the tiles of the tree of the given root node, every
+// subtree spanning the number of levels given by the options
+func WriteSubtrees(storage storage.Storage, folder string, root octree.INode, opts *tiler.TilerOptions) error {
+	pending := []pendingSubtree{{node: root}}
+	for len(pending) > 0 {
+		next := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if err := opts.Cancellation.Err(); err != nil {
+			return err
+		}
+
+		tree := &subtree{
+			tiles:    make([]bool, getSubtreeTiles(opts.SubtreeLevels)),
+			contents: make([]bool, getSubtreeTiles(opts.SubtreeLevels)),
+			children: make([]bool, 1<<(3*opts.SubtreeLevels)),
+		}
+		pending = append(pending, tree.fill(next.node, next.tile, next.tile, opts.SubtreeLevels)...)
+
+		filePath := path.Join(folder, next.tile.expand(getSubtreeUri()))
+		if err := storage.MkdirAll(path.Dir(filePath), 0777); err != nil {
+			return err
+		}
+		content, err := tree.generateSubtree()
+		if err != nil {
+			return err
+		}
+		if err := storage.WriteFile(filePath, content, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Records the availability of the given node, at the given tile of the subtree rooted at the given tile, and of its
+// descendants, returning the nodes right below the subtree that root the child subtrees
+func (s *subtree) fill(node octree.INode, tile implicitTile, root implicitTile, subtreeLevels int) []pendingSubtree {
+	if node.TotalNumberOfPoints() == 0 {
+		return nil
+	}
+	index := tile.getSubtreeIndex(root, subtreeLevels)
+	if tile.level-root.level == subtreeLevels {
+		s.children[index] = true
+		return []pendingSubtree{{node: node, tile: tile}}
+	}
+
+	s.tiles[index] = true
+	s.contents[index] = node.NumberOfPoints() > 0
+	var children []pendingSubtree
+	for octant, child := range node.GetChildren() {
+		if child != nil {
+			children = append(children, s.fill(child, tile.child(octant), root, subtreeLevels)...)
+		}
+	}
+	return children
+}
+
+// Generates the binary subtree file, storing the availabilities that are not constant as bitstreams of its buffer
+func (s *subtree) generateSubtree() ([]byte, error) {
+	buffer := tools.NewBinaryEncoder(binary.LittleEndian, 0)
+	var header subtreeJson
+	availability := func(bits []bool) subtreeAvailability {
+		available := 0
+		for _, bit := range bits {
+			if bit {
+				available++
+			}
+		}
+		if available == 0 || available == len(bits) {
+			constant := available / len(bits)
+			return subtreeAvailability{Constant: &constant}
+		}
+
+		// the buffer views are aligned to 8 bytes, the bits of the bitstreams being stored from the least significant
+		buffer.Pad(8, 0)
+		bitstream := make([]byte, (len(bits)+7)/8)
+		for i, bit := range bits {
+			if bit {
+				bitstream[i/8] |= 1 << (i % 8)
+			}
+		}
+		view := len(header.BufferViews)
+		header.BufferViews = append(header.BufferViews, subtreeBufferView{ByteOffset: buffer.Len(), ByteLength: len(bitstream)})
+		buffer.WriteBytes(bitstream)
+		return subtreeAvailability{Bitstream: &view}
+	}
+	header.TileAvailability = availability(s.tiles)
+	header.ContentAvailability = []subtreeAvailability{availability(s.contents)}
+	header.ChildSubtreeAvailability = availability(s.children)
+	if buffer.Len() > 0 {
+		header.Buffers = []subtreeBuffer{{ByteLength: buffer.Len()}}
+	}
+	buffer.Pad(8, 0)
+	bufferBytes, err := buffer.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	jsonBytes, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// the JSON and the binary chunks both end on 8-byte boundaries, the JSON one being padded with spaces
+	content := tools.NewBinaryEncoder(binary.LittleEndian, subtreeHeaderLength+len(jsonBytes)+7+len(bufferBytes))
+	content.WriteString("subt")
+	content.WriteUint32(1)
+	jsonLength := content.Reserve(8)
+	content.WriteUint64(uint64(len(bufferBytes)))
+	content.WriteBytes(jsonBytes)
+	content.Pad(8, ' ')
+	content.PutUint64(jsonLength, uint64(content.Len()-subtreeHeaderLength))
+	content.WriteBytes(bufferBytes)
+	return content.Bytes()
+}
+
+// Reads a subtree file of the given number of levels with an internal buffer, as written by the tiler
+func readSubtree(content []byte, subtreeLevels int) (*subtree, error) {
+	if len(content) < subtreeHeaderLength || string(content[0:4]) != "subt" {
+		return nil, errors.New("not a subtree file")
+	}
+	jsonLength := binary.LittleEndian.Uint64(content[8:16])
+	binaryLength := binary.LittleEndian.Uint64(content[16:24])
+	if uint64(len(content)) < subtreeHeaderLength+jsonLength+binaryLength {
+		return nil, errors.New("truncated subtree file")
+	}
+	var header subtreeJson
+	if err := json.Unmarshal(content[subtreeHeaderLength:subtreeHeaderLength+jsonLength], &header); err != nil {
+		return nil, errors.New("invalid subtree file: " + err.Error())
+	}
+	buffer := content[subtreeHeaderLength+jsonLength : subtreeHeaderLength+jsonLength+binaryLength]
+
+	availability := func(a subtreeAvailability, length int) ([]bool, error) {
+		bits := make([]bool, length)
+		if a.Constant != nil {
+			for i := range bits {
+				bits[i] = *a.Constant == 1
+			}
+			return bits, nil
+		}
+		if a.Bitstream == nil || *a.Bitstream < 0 || *a.Bitstream >= len(header.BufferViews) {
+			return nil, errors.New("invalid subtree availability")
+		}
+		view := header.BufferViews[*a.Bitstream]
+		if view.Buffer != 0 || view.ByteOffset < 0 || view.ByteOffset+(length+7)/8 > len(buffer) {
+			return nil, errors.New("subtree availability outside of the internal buffer")
+		}
+		for i := range bits {
+			bits[i] = buffer[view.ByteOffset+i/8]&(1<<(i%8)) != 0
+		}
+		return bits, nil
+	}
+	tiles, err := availability(header.TileAvailability, getSubtreeTiles(subtreeLevels))
+	if err != nil {
+		return nil, err
+	}
+	contents := make([]bool, len(tiles))
+	if len(header.ContentAvailability) > 0 {
+		if contents, err = availability(header.ContentAvailability[0], len(tiles)); err != nil {
+			return nil, err
+		}
+	}
+	children, err := availability(header.ChildSubtreeAvailability, 1<<(3*subtreeLevels))
+	if err != nil {
+		return nil, err
+	}
+	return &subtree{tiles: tiles, contents: contents, children: children}, nil
+}
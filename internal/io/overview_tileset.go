@@ -0,0 +1,73 @@
+package io
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+)
+
+// A tileset referenced by an overview tileset, e.g. the one holding the points of a classification
+type OverviewLayer struct {
+	Name string
+	// uri of the tileset.json file of the layer, relative to the overview tileset
+	Uri  string
+	Root octree.INode
+}
+
+// Root of an overview tileset, which has no content of its own
+type overviewRoot struct {
+	Children       []Child        `json:"children"`
+	BoundingVolume BoundingVolume `json:"boundingVolume"`
+	GeometricError float64        `json:"geometricError"`
+	Refine         string         `json:"refine"`
+}
+
+type overviewTileset struct {
+	Asset          Asset        `json:"asset"`
+	GeometricError float64      `json:"geometricError"`
+	Root           overviewRoot `json:"root"`
+}
+
+// Generates the tileset.json content of a tileset referencing the given layers as external tilesets, whose root
+// bounds the ones of all the layers. Every child is named after its layer in its content extras, so that viewers can
+// load the layers selectively.
+func GenerateOverviewTileset(layers []OverviewLayer, converter converters.CoordinateConverter, refineMode tiler.RefineMode) ([]byte, error) {
+	root := overviewRoot{
+		BoundingVolume: BoundingVolume{Region: []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(-1)}},
+		Refine:         refineMode.String(),
+		Children:       []Child{},
+	}
+	for _, layer := range layers {
+		region, err := layer.Root.GetBoundingBoxRegion(converter)
+		if err != nil {
+			return nil, err
+		}
+		// regions are ordered as west, south, east, north, min height, max height
+		bounds := region.GetAsArray()
+		union := root.BoundingVolume.Region
+		for _, i := range []int{0, 1, 4} {
+			union[i] = math.Min(union[i], bounds[i])
+		}
+		for _, i := range []int{2, 3, 5} {
+			union[i] = math.Max(union[i], bounds[i])
+		}
+
+		geometricError := layer.Root.ComputeGeometricError()
+		root.GeometricError = math.Max(root.GeometricError, geometricError)
+		root.Children = append(root.Children, Child{
+			Content:        Content{Url: layer.Uri, Extras: map[string]string{"layer": layer.Name}},
+			BoundingVolume: BoundingVolume{Region: bounds},
+			GeometricError: geometricError,
+			Refine:         refineMode.String(),
+		})
+	}
+
+	tileset := overviewTileset{
+		Asset:          Asset{Version: "1.0"},
+		GeometricError: root.GeometricError,
+		Root:           root,
+	}
+	return json.MarshalIndent(tileset, "", "\t")
+}
@@ -0,0 +1,166 @@
+package io
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+// Length of the header of the pnts files
+const pntsHeaderLength = 28
+
+var errDracoPnts = errors.New("pnts file without valid draco compressed positions")
+
+// Binary body reference of a pnts feature or batch table property
+type pntsProperty struct {
+	ByteOffset int `json:"byteOffset"`
+}
+
+type pntsFeatureTable struct {
+	PointsLength int           `json:"POINTS_LENGTH"`
+	RtcCenter    []float64     `json:"RTC_CENTER"`
+	Position     *pntsProperty `json:"POSITION"`
+	Rgb          *pntsProperty `json:"RGB"`
+	Rgba         *pntsProperty `json:"RGBA"`
+	Extensions   struct {
+		Draco *dracoPointCompression `json:"3DTILES_draco_point_compression"`
+	} `json:"extensions"`
+}
+
+// Batch table properties, each one either a reference to the binary body or a JSON array of values
+type pntsBatchTable struct {
+	Intensity      json.RawMessage `json:"INTENSITY"`
+	Classification json.RawMessage `json:"CLASSIFICATION"`
+	Extensions     struct {
+		Draco *dracoPointCompression `json:"3DTILES_draco_point_compression"`
+	} `json:"extensions"`
+}
+
+// A point read from a pnts or glb tile content, whose coordinates are expressed in the frame of the tileset
+type pntsPoint struct {
+	X, Y, Z                   float64
+	R, G, B                   uint8
+	Intensity, Classification uint8
+}
+
+// Reads the points of a pnts file with float positions, optional RTC center and RGB or RGBA colors, whose alpha is
+// ignored, and the optional intensity and classification batch table properties, as written by the tiler, either
+// stored in the binary bodies or compressed with Draco
+func readPnts(content []byte) ([]pntsPoint, error) {
+	if len(content) < pntsHeaderLength || string(content[0:4]) != "pnts" {
+		return nil, errors.New("not a pnts file")
+	}
+	featureTableJsonLength := int(binary.LittleEndian.Uint32(content[12:16]))
+	featureTableBinaryLength := int(binary.LittleEndian.Uint32(content[16:20]))
+	batchTableJsonLength := int(binary.LittleEndian.Uint32(content[20:24]))
+	batchTableBinaryLength := int(binary.LittleEndian.Uint32(content[24:28]))
+	if pntsHeaderLength+featureTableJsonLength+featureTableBinaryLength+batchTableJsonLength+batchTableBinaryLength > len(content) {
+		return nil, errors.New("truncated pnts file")
+	}
+
+	offset := pntsHeaderLength
+	var featureTable pntsFeatureTable
+	if err := json.Unmarshal(content[offset:offset+featureTableJsonLength], &featureTable); err != nil {
+		return nil, err
+	}
+	offset += featureTableJsonLength
+	featureTableBinary := content[offset : offset+featureTableBinaryLength]
+	offset += featureTableBinaryLength
+
+	var batchTable pntsBatchTable
+	if batchTableJsonLength > 0 {
+		if err := json.Unmarshal(content[offset:offset+batchTableJsonLength], &batchTable); err != nil {
+			return nil, err
+		}
+	}
+	offset += batchTableJsonLength
+	batchTableBinary := content[offset : offset+batchTableBinaryLength]
+
+	if featureTable.Extensions.Draco != nil {
+		return readDracoPnts(&featureTable, &batchTable, featureTableBinary)
+	}
+
+	numPoints := featureTable.PointsLength
+	if featureTable.Position == nil || !fitsIn(featureTable.Position, numPoints*12, featureTableBinary) {
+		return nil, errors.New("pnts file without valid float positions")
+	}
+	var center [3]float64
+	if len(featureTable.RtcCenter) == 3 {
+		copy(center[:], featureTable.RtcCenter)
+	}
+	colors, colorComponents := getPntsBytes(featureTable.Rgb, numPoints*3, featureTableBinary), 3
+	if featureTable.Rgba != nil {
+		colors, colorComponents = getPntsBytes(featureTable.Rgba, numPoints*4, featureTableBinary), 4
+	}
+	intensities, err := getBatchTableBytes(batchTable.Intensity, numPoints, batchTableBinary)
+	if err != nil {
+		return nil, err
+	}
+	classifications, err := getBatchTableBytes(batchTable.Classification, numPoints, batchTableBinary)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]pntsPoint, numPoints)
+	positions := featureTableBinary[featureTable.Position.ByteOffset:]
+	for i := range points {
+		point := &points[i]
+		point.X = center[0] + float64(math.Float32frombits(binary.LittleEndian.Uint32(positions[i*12:])))
+		point.Y = center[1] + float64(math.Float32frombits(binary.LittleEndian.Uint32(positions[i*12+4:])))
+		point.Z = center[2] + float64(math.Float32frombits(binary.LittleEndian.Uint32(positions[i*12+8:])))
+		if colors != nil {
+			color := colors[i*colorComponents:]
+			point.R, point.G, point.B = color[0], color[1], color[2]
+		}
+		if intensities != nil {
+			point.Intensity = intensities[i]
+		}
+		if classifications != nil {
+			point.Classification = classifications[i]
+		}
+	}
+	return points, nil
+}
+
+// Returns true if the given number of bytes of the property fit in the given binary body
+func fitsIn(property *pntsProperty, length int, body []byte) bool {
+	return property.ByteOffset >= 0 && property.ByteOffset+length <= len(body)
+}
+
+// Returns the given number of byte values of the given batch table property, stored either as a JSON array or in
+// the binary body. Returns nil if the property is missing or holds less values.
+func getBatchTableBytes(property json.RawMessage, length int, body []byte) ([]byte, error) {
+	if len(property) == 0 {
+		return nil, nil
+	}
+	if property[0] == '[' {
+		var values []int
+		if err := json.Unmarshal(property, &values); err != nil {
+			return nil, err
+		}
+		if len(values) < length {
+			return nil, nil
+		}
+		bytes := make([]byte, length)
+		for i := range bytes {
+			bytes[i] = uint8(values[i])
+		}
+		return bytes, nil
+	}
+
+	var reference pntsProperty
+	if err := json.Unmarshal(property, &reference); err != nil {
+		return nil, err
+	}
+	return getPntsBytes(&reference, length, body), nil
+}
+
+// Returns the given number of bytes of the given property, nil if the property is missing or does not fit in the
+// given binary body
+func getPntsBytes(property *pntsProperty, length int, body []byte) []byte {
+	if property == nil || !fitsIn(property, length, body) {
+		return nil
+	}
+	return body[property.ByteOffset : property.ByteOffset+length]
+}
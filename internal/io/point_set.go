@@ -0,0 +1,72 @@
+package io
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+)
+
+// Size of the cells, in degrees horizontally and meters vertically, of the hash of a point set, about a millimeter
+const (
+	pointSetCellSize   = 1e-8
+	pointSetHeightSize = 1e-3
+)
+
+// Max difference, in degrees horizontally and meters vertically, between the copies of the same point in the contents
+// of a tile and of its children, as every content quantizes the points in its own frame
+const (
+	pointSetTolerance       = 1e-9
+	pointSetHeightTolerance = 1e-4
+)
+
+// Cell of the hash of a point set, along with the attributes of the points that every copy keeps exactly
+type pointSetKey struct {
+	x, y, z                            int64
+	r, g, b, intensity, classification uint8
+}
+
+// Points of a tile of a mounted tileset hashed so that their copies in the contents of its children, as written by
+// the REPLACE refine mode, can be found
+type PointSet map[pointSetKey]bool
+
+// Returns a set of the given points
+func NewPointSet(points []*data.Point) PointSet {
+	set := make(PointSet, len(points))
+	for _, point := range points {
+		set.Add(point)
+	}
+	return set
+}
+
+// Adds the given point to all the cells within the tolerance of its position, usually one
+func (s PointSet) Add(point *data.Point) {
+	key := pointSetKey{r: point.R, g: point.G, b: point.B, intensity: point.Intensity, classification: point.Classification}
+	for _, x := range getPointSetCells(point.X, pointSetTolerance, pointSetCellSize) {
+		for _, y := range getPointSetCells(point.Y, pointSetTolerance, pointSetCellSize) {
+			for _, z := range getPointSetCells(point.Z, pointSetHeightTolerance, pointSetHeightSize) {
+				key.x, key.y, key.z = x, y, z
+				s[key] = true
+			}
+		}
+	}
+}
+
+// Returns true if a point within the tolerance of the given one is in the set, false if the set is nil
+func (s PointSet) Contains(point *data.Point) bool {
+	if s == nil {
+		return false
+	}
+	key := pointSetKey{
+		x: int64(math.Floor(point.X / pointSetCellSize)), y: int64(math.Floor(point.Y / pointSetCellSize)), z: int64(math.Floor(point.Z / pointSetHeightSize)),
+		r: point.R, g: point.G, b: point.B, intensity: point.Intensity, classification: point.Classification,
+	}
+	return s[key]
+}
+
+// Returns the cells of the given size spanned by the given value plus or minus the given tolerance
+func getPointSetCells(value float64, tolerance float64, size float64) []int64 {
+	min, max := int64(math.Floor((value-tolerance)/size)), int64(math.Floor((value+tolerance)/size))
+	if min == max {
+		return []int64{min}
+	}
+	return []int64{min, max}
+}
@@ -1,34 +1,56 @@
 package io
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/progress"
+	"github.com/mfbonfigli/gocesiumtiler/internal/raster"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
-	"io/ioutil"
+	"math"
 	"path"
 	"strconv"
 	"strings"
 	"sync"
 )
 
+// Name of the thumbnail files of the tiles
+const thumbnailFileName = "thumbnail.png"
+
+// Name of the tileset files
+const tilesetFileName = "tileset.json"
+
 type StandardConsumer struct {
 	coordinateConverter converters.CoordinateConverter
 	refineMode          tiler.RefineMode
+	storage             storage.Storage
+	contentIndex        *ContentIndex
 }
 
-func NewStandardConsumer(coordinateConverter converters.CoordinateConverter, refineMode tiler.RefineMode) *StandardConsumer {
+func NewStandardConsumer(coordinateConverter converters.CoordinateConverter, refineMode tiler.RefineMode, storage storage.Storage) *StandardConsumer {
 	return &StandardConsumer{
 		coordinateConverter: coordinateConverter,
 		refineMode:          refineMode,
+		storage:             storage,
 	}
 }
 
+// Creates a consumer that writes the tile contents at the paths assigned by the given index, which defers the
+// tileset.json files until WriteDeferredTilesets is called
+func NewIndexedConsumer(coordinateConverter converters.CoordinateConverter, refineMode tiler.RefineMode, storage storage.Storage, contentIndex *ContentIndex) *StandardConsumer {
+	consumer := NewStandardConsumer(coordinateConverter, refineMode, storage)
+	consumer.contentIndex = contentIndex
+	return consumer
+}
+
 // struct used to store data in an intermediate format
 type intermediateData struct {
 	coords          []float64
@@ -36,6 +58,11 @@ type intermediateData struct {
 	intensities     []uint8
 	classifications []uint8
 	numPoints       int
+	// number of color components of every point, 4 if the colors hold the alpha channel
+	colorComponents int
+	// names and values of the supplementary attributes of the points, one list of values per attribute
+	attributeNames []string
+	attributes     [][]float32
 }
 
 // Continually consumes WorkUnits submitted to a work channel producing corresponding content.pnts files and tileset.json files
@@ -50,13 +77,25 @@ func (c *StandardConsumer) Consume(workchan chan *WorkUnit, errchan chan error,
 			break
 		}
 
-		// do work
-		err := c.doWork(work)
+		// do work, unless the job has been cancelled
+		var err error
+		if work.Opts != nil && work.Opts.Cancellation.IsCancelled() {
+			err = cancellation.ErrCancelled
+		} else {
+			err = c.doWork(work)
+		}
+		work.done()
 
-		// if there were errors during work send in error channel and quit
+		// if there were errors during work, or the job was cancelled, send in error channel and quit, draining the
+		// work channel so that the producer is never blocked by consumers that stopped working
 		if err != nil {
 			errchan <- err
-			fmt.Println("exception in c worker")
+			if err != cancellation.ErrCancelled {
+				fmt.Println("exception in c worker")
+			}
+			for work := range workchan {
+				work.done()
+			}
 			break
 		}
 	}
@@ -67,58 +106,89 @@ func (c *StandardConsumer) Consume(workchan chan *WorkUnit, errchan chan error,
 
 // Takes a workunit and writes the corresponding content.pnts and tileset.json files
 func (c *StandardConsumer) doWork(workUnit *WorkUnit) error {
+	// work units without options are processed with the default settings
+	if workUnit.Opts == nil {
+		workUnit.Opts = &tiler.TilerOptions{}
+	}
+
 	// writes the content.pnts file
 	err := c.writeBinaryPntsFile(*workUnit)
 	if err != nil {
 		return err
 	}
-	if !workUnit.Node.IsLeaf() || workUnit.Node.IsRoot() {
-		// if the node has children also writes the tileset.json file
-		err := c.writeTilesetJsonFile(*workUnit)
-		if err != nil {
+	if workUnit.ThumbnailPath != "" {
+		if err := c.writeThumbnailFile(*workUnit); err != nil {
 			return err
 		}
 	}
+	if workUnit.Opts.ImplicitTiling {
+		// the tiles of implicit tilesets are declared by the subtree files, only the root tileset.json file is written
+		if workUnit.Node.IsRoot() {
+			rootUnit := *workUnit
+			rootUnit.BasePath = workUnit.RootPath
+			if err := c.writeTilesetJsonFile(rootUnit); err != nil {
+				return err
+			}
+		}
+	} else if !workUnit.Node.IsLeaf() || workUnit.Node.IsRoot() {
+		// if the node has children also writes the tileset.json file, once all the contents are named if indexed
+		if c.contentIndex != nil {
+			c.contentIndex.deferTileset(workUnit)
+		} else if err := c.writeTilesetJsonFile(*workUnit); err != nil {
+			return err
+		}
+	}
+	workUnit.Opts.Progress.Report(progress.Event{Type: progress.TileWritten, Tile: workUnit.BasePath})
 	return nil
 }
 
-// Writes a content.pnts binary files from the given WorkUnit
+// Writes a content.pnts binary files from the given WorkUnit, or a content.glb one for 3D Tiles 1.1 tilesets. The
+// bundles of leaf tiles of 3D Tiles 1.0 tilesets are written as content.cmpt composite tiles.
 func (c *StandardConsumer) writeBinaryPntsFile(workUnit WorkUnit) error {
 	parentFolder := workUnit.BasePath
 	node := workUnit.Node
 
-	// Create base folder if it does not exist
-	err := tools.CreateDirectoryIfDoesNotExist(parentFolder)
+	localFrame, err := c.getLocalFrame(workUnit)
 	if err != nil {
 		return err
 	}
 
-	intermediatePointData, err := c.generateIntermediateDataForPnts(node)
-	if err != nil {
+	var outputByte []byte
+	if bundle, ok := node.(octree.BundleNode); ok && workUnit.Opts.TilesetVersion != tiler.TilesetVersion11 {
+		// every bundled tile is an inner tile of the composite, whose byte length must account for its batch table
+		tiles := make([][]byte, 0, len(bundle.GetBundledNodes()))
+		for _, bundled := range bundle.GetBundledNodes() {
+			tile, err := c.generateTileContent(bundled, localFrame, workUnit.Opts, true)
+			if err != nil {
+				return err
+			}
+			tiles = append(tiles, tile)
+		}
+		if outputByte, err = generateCmpt(tiles); err != nil {
+			return err
+		}
+	} else if outputByte, err = c.generateTileContent(node, localFrame, workUnit.Opts, !workUnit.Opts.LegacyTables); err != nil {
 		return err
 	}
+	// the byte length of a larger content would overflow its header
+	if int64(len(outputByte)) > MaxContentSize {
+		return fmt.Errorf("the content of the tile holding %d points takes %s, more than the %s the tile formats can describe", node.NumberOfPoints(), tiler.FormatByteSize(int64(len(outputByte))), tiler.FormatByteSize(MaxContentSize))
+	}
 
-	// Evaluating average X, Y, Z to express coords relative to tile center
-	averageXYZ := c.computeAverageXYZ(intermediatePointData)
-
-	// Normalizing coordinates relative to average
-	c.subtractXYZFromIntermediateDataCoords(intermediatePointData, averageXYZ)
-
-	// Coordinate bytes
-	positionBytes := tools.ConvertTruncateFloat64ToFloat32ByteArray(intermediatePointData.coords)
-
-	// Feature table
-	featureTableBytes, featureTableLen := c.generateFeatureTable(averageXYZ[0], averageXYZ[1], averageXYZ[2], intermediatePointData.numPoints)
-
-	// Batch table
-	batchTableBytes, batchTableLen := c.generateBatchTable(intermediatePointData.numPoints)
-
-	// Appending binary content to slice
-	outputByte := c.generatePntsByteArray(intermediatePointData, positionBytes, featureTableBytes, featureTableLen, batchTableBytes, batchTableLen)
+	// Write binary content to file, unless an identical one has already been written
+	pntsFilePath := path.Join(parentFolder, getTileContentFileName(node, workUnit.Opts))
+	if c.contentIndex != nil {
+		var write bool
+		if pntsFilePath, write = c.contentIndex.add(&workUnit, pntsFilePath, outputByte); !write {
+			return nil
+		}
+	}
 
-	// Write binary content to file
-	pntsFilePath := path.Join(parentFolder, "content.pnts")
-	err = ioutil.WriteFile(pntsFilePath, outputByte, 0777)
+	// Create base folder if it does not exist
+	if err := c.storage.MkdirAll(path.Dir(pntsFilePath), 0777); err != nil {
+		return err
+	}
+	err = c.storage.WriteFile(pntsFilePath, outputByte, 0777)
 
 	if err != nil {
 		return err
@@ -127,20 +197,64 @@ func (c *StandardConsumer) writeBinaryPntsFile(workUnit WorkUnit) error {
 	return nil
 }
 
-func (c *StandardConsumer) generateIntermediateDataForPnts(node octree.INode) (*intermediateData, error) {
-	points := node.GetPoints()
+// Returns the pnts content of the points of the given node, with its tables aligned to 8 bytes unless the legacy
+// layout is requested, or the glb one for 3D Tiles 1.1 tilesets
+func (c *StandardConsumer) generateTileContent(node octree.INode, localFrame *geometry.LocalFrame, opts *tiler.TilerOptions, align bool) ([]byte, error) {
+	intermediatePointData, err := c.generateIntermediateDataForPnts(node, localFrame, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	if c.refineMode == tiler.RefineModeReplace {
-		points = appendParentPoints(node, points)
+	// Evaluating average X, Y, Z to express coords relative to tile center
+	averageXYZ := c.computeAverageXYZ(intermediatePointData)
+
+	// Normalizing coordinates relative to average
+	c.subtractXYZFromIntermediateDataCoords(intermediatePointData, averageXYZ)
+
+	if opts.TilesetVersion == tiler.TilesetVersion11 {
+		// 3D Tiles 1.1 tilesets store the points as glTF point primitives
+		return generateGlb(intermediatePointData, averageXYZ)
+	}
+
+	var featureTableBytes, featureTableBinary, batchTableBytes, batchTableBinary []byte
+	if opts.DracoCompression {
+		// the compressed points form the binary body of the feature table, the batch table has no binary body
+		featureTableBytes, featureTableBinary, batchTableBytes, err = generateDracoTables(intermediatePointData, averageXYZ, opts.DracoQuantization)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Feature table, holding the positions followed by the colors
+		featureTableBytes, _ = c.generateFeatureTable(averageXYZ[0], averageXYZ[1], averageXYZ[2], intermediatePointData.numPoints, intermediatePointData.colorComponents)
+		body := tools.NewBinaryEncoder(binary.LittleEndian, len(intermediatePointData.coords)*4+len(intermediatePointData.colors))
+		body.WriteFloat64sAsFloat32s(intermediatePointData.coords)
+		body.WriteBytes(intermediatePointData.colors)
+		featureTableBinary, _ = body.Bytes()
+
+		// Batch table
+		batchTableBytes, batchTableBinary = c.generateBatchTable(intermediatePointData, opts.BatchTable)
 	}
 
+	return encodePnts(featureTableBytes, featureTableBinary, batchTableBytes, batchTableBinary, align)
+}
+
+func (c *StandardConsumer) generateIntermediateDataForPnts(node octree.INode, localFrame *geometry.LocalFrame, opts *tiler.TilerOptions) (*intermediateData, error) {
+	points := c.getTilePoints(node)
 	numPoints := len(points)
+	colorComponents := getColorComponents(opts)
+	grayscale := opts.ColorsInvalid && opts.InvalidColors == tiler.InvalidColorsIntensity
 	intermediateData := intermediateData{
 		coords:          make([]float64, numPoints*3),
-		colors:          make([]uint8, numPoints*3),
+		colors:          make([]uint8, numPoints*colorComponents),
 		intensities:     make([]uint8, numPoints),
 		classifications: make([]uint8, numPoints),
 		numPoints:       numPoints,
+		colorComponents: colorComponents,
+		attributeNames:  opts.AttributeNames,
+		attributes:      make([][]float32, len(opts.AttributeNames)),
+	}
+	for j := range intermediateData.attributes {
+		intermediateData.attributes[j] = make([]float32, numPoints)
 	}
 
 	// Decomposing tile data properties in separate sublists for coords, colors, intensities and classifications
@@ -161,21 +275,86 @@ func (c *StandardConsumer) generateIntermediateDataForPnts(node octree.INode) (*
 			return nil, err
 		}
 
+		// Eventually express the coords in the local frame
+		if localFrame != nil {
+			outCrd = localFrame.FromEcef(outCrd)
+		}
+
 		intermediateData.coords[i*3] = outCrd.X
 		intermediateData.coords[i*3+1] = outCrd.Y
 		intermediateData.coords[i*3+2] = outCrd.Z
 
-		intermediateData.colors[i*3] = point.R
-		intermediateData.colors[i*3+1] = point.G
-		intermediateData.colors[i*3+2] = point.B
+		if grayscale {
+			intermediateData.colors[i*colorComponents] = point.Intensity
+			intermediateData.colors[i*colorComponents+1] = point.Intensity
+			intermediateData.colors[i*colorComponents+2] = point.Intensity
+		} else if colorComponents > 0 {
+			intermediateData.colors[i*colorComponents] = point.R
+			intermediateData.colors[i*colorComponents+1] = point.G
+			intermediateData.colors[i*colorComponents+2] = point.B
+		}
+		if colorComponents == 4 {
+			intermediateData.colors[i*4+3] = getIntensityAlpha(point.Intensity, opts.AlphaMin)
+		}
 
 		intermediateData.intensities[i] = point.Intensity
 		intermediateData.classifications[i] = point.Classification
+		// points without a sidecar record keep null attributes
+		for j := 0; j < len(intermediateData.attributes) && j < len(point.Attributes); j++ {
+			intermediateData.attributes[j][i] = point.Attributes[j]
+		}
 	}
 
 	return &intermediateData, nil
 }
 
+// Returns the local East-North-Up frame centered on the tree root where point coordinates should be expressed in,
+// or nil if the points have to be stored as ECEF coordinates
+func (c *StandardConsumer) getLocalFrame(workUnit WorkUnit) (*geometry.LocalFrame, error) {
+	if workUnit.Opts == nil || workUnit.Opts.CoordinateFrame != tiler.CoordinateFrameLocal {
+		return nil, nil
+	}
+
+	root := workUnit.Node
+	for root.GetParent() != nil {
+		root = root.GetParent()
+	}
+	// the frame is centered on the bulk of the point cloud rather than on the box enclosing its outliers too
+	if overflow, ok := root.(octree.OverflowNode); ok {
+		root = overflow.GetCoreNode()
+	}
+
+	boundingBox := root.GetBoundingBox()
+	origin := geometry.Coordinate{
+		X: boundingBox.Xmid,
+		Y: boundingBox.Ymid,
+		Z: boundingBox.Zmin,
+	}
+
+	ecefOrigin, err := c.coordinateConverter.ConvertToWGS84Cartesian(origin, root.GetInternalSrid())
+	if err != nil {
+		return nil, err
+	}
+
+	wgs84Origin, err := c.coordinateConverter.ConvertCoordinateSrid(root.GetInternalSrid(), 4326, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	return geometry.NewLocalFrame(ecefOrigin, wgs84Origin.X, wgs84Origin.Y), nil
+}
+
+// Returns the points written in the content of the tile of the given node, without altering the points of the node
+func (c *StandardConsumer) getTilePoints(node octree.INode) []*data.Point {
+	points := node.GetPoints()
+	if c.refineMode == tiler.RefineModeReplace {
+		// the points of a node can be read by several consumers at the same time: they are copied so that the parent
+		// points are never appended to a shared slice
+		points = appendParentPoints(node, append([]*data.Point{}, points...))
+	}
+	return points
+}
+
 func appendParentPoints(node octree.INode, points []*data.Point) []*data.Point {
 	parent := node.GetParent()
 	boundingBox := node.GetBoundingBox()
@@ -200,36 +379,88 @@ func appendParentPoints(node octree.INode, points []*data.Point) []*data.Point {
 	return points
 }
 
-func (c *StandardConsumer) generateFeatureTable(avgX float64, avgY float64, avgZ float64, numPoints int) ([]byte, int) {
-	featureTableStr := c.generateFeatureTableJsonContent(avgX, avgY, avgZ, numPoints, 0)
+// Returns the number of color components written per point: 4 for RGBA, 3 for RGB and 0 if the colors are omitted
+// because they have been detected as invalid
+func getColorComponents(opts *tiler.TilerOptions) int {
+	if opts.ColorsInvalid && opts.InvalidColors == tiler.InvalidColorsOmit {
+		return 0
+	}
+	if opts.Alpha == tiler.AlphaIntensity {
+		return 4
+	}
+	return 3
+}
+
+func (c *StandardConsumer) generateFeatureTable(avgX float64, avgY float64, avgZ float64, numPoints int, colorComponents int) ([]byte, int) {
+	colorSemantic := "RGB"
+	if colorComponents == 4 {
+		colorSemantic = "RGBA"
+	} else if colorComponents == 0 {
+		colorSemantic = ""
+	}
+	featureTableStr := c.generateFeatureTableJsonContent(avgX, avgY, avgZ, numPoints, colorSemantic, 0)
 	featureTableLen := len(featureTableStr)
 	return []byte(featureTableStr), featureTableLen
 }
 
-func (c *StandardConsumer) generateBatchTable(numPoints int) ([]byte, int) {
-	batchTableStr := c.generateBatchTableJsonContent(numPoints, 0)
-	batchTableLen := len(batchTableStr)
-	return []byte(batchTableStr), batchTableLen
+// Returns the JSON header and the binary body of the batch table, the binary body is empty if the properties are
+// encoded as JSON arrays
+func (c *StandardConsumer) generateBatchTable(intermediateData *intermediateData, encoding tiler.BatchTableEncoding) ([]byte, []byte) {
+	if encoding == tiler.BatchTableJson {
+		return []byte(c.generateBatchTableJsonArrays(intermediateData)), nil
+	}
+	batchTableStr := c.generateBatchTableJsonContent(intermediateData.numPoints, intermediateData.attributeNames, 0)
+	body := tools.NewBinaryEncoder(binary.LittleEndian, getAttributesByteOffset(intermediateData.numPoints)+len(intermediateData.attributes)*intermediateData.numPoints*4)
+	body.WriteBytes(intermediateData.intensities)
+	body.WriteBytes(intermediateData.classifications)
+	if len(intermediateData.attributes) > 0 {
+		// float attributes have to start on a 4-byte boundary
+		body.Pad(4, 0)
+		for _, values := range intermediateData.attributes {
+			body.WriteFloat32s(values)
+		}
+	}
+	batchTableBinary, _ := body.Bytes()
+	return []byte(batchTableStr), batchTableBinary
 }
 
-func (c *StandardConsumer) generatePntsByteArray(intermediateData *intermediateData, positionBytes []byte, featureTableBytes []byte, featureTableLen int, batchTableBytes []byte, batchTableLen int) []byte {
-	outputByte := make([]byte, 0)
-	outputByte = append(outputByte, []byte("pnts")...)                 // magic
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(1)...) // version number
-	byteLength := 28 + featureTableLen + len(positionBytes) + len(intermediateData.colors)
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(byteLength)...)
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(featureTableLen)...)                                                         // feature table length
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(len(positionBytes)+len(intermediateData.colors))...)                         // feature table binary length
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(batchTableLen)...)                                                           // batch table length
-	outputByte = append(outputByte, tools.ConvertIntToByteArray(len(intermediateData.intensities)+len(intermediateData.classifications))...) // batch table binary length
-	outputByte = append(outputByte, featureTableBytes...)                                                                                    // feature table
-	outputByte = append(outputByte, positionBytes...)                                                                                        // positions array
-	outputByte = append(outputByte, intermediateData.colors...)                                                                              // colors array
-	outputByte = append(outputByte, batchTableBytes...)                                                                                      // batch table
-	outputByte = append(outputByte, intermediateData.intensities...)                                                                         // intensities array
-	outputByte = append(outputByte, intermediateData.classifications...)
+// Returns the byte offset of the supplementary attributes in the binary body of a batch table of the given number of
+// points, following the intensities and the classifications
+func getAttributesByteOffset(pointNumber int) int {
+	return (pointNumber*2 + 3) / 4 * 4
+}
 
-	return outputByte
+// Returns the content of a pnts file holding the given tables. If align is set the content is laid out as required by
+// the 3D Tiles specification: the JSON headers are padded with spaces and the binary bodies with zeros so that every
+// section starts and ends on an 8-byte boundary. Otherwise the tables are written unpadded, as by the earlier versions
+// of the tiler. The byte length of the header is the one of the whole content either way.
+func encodePnts(featureTableBytes []byte, featureTableBinary []byte, batchTableBytes []byte, batchTableBinary []byte, align bool) ([]byte, error) {
+	sections := []struct {
+		bytes   []byte
+		padding byte
+	}{
+		{featureTableBytes, ' '}, // feature table
+		{featureTableBinary, 0},  // positions and colors arrays
+		{batchTableBytes, ' '},   // batch table
+		{batchTableBinary, 0},    // intensities, classifications and attributes arrays
+	}
+
+	encoder := tools.NewBinaryEncoder(binary.LittleEndian, pntsHeaderLength+len(featureTableBytes)+len(featureTableBinary)+len(batchTableBytes)+len(batchTableBinary)+32)
+	encoder.WriteString("pnts") // magic
+	encoder.WriteUint32(1)      // version number
+	byteLength := encoder.Reserve(4)
+	lengths := encoder.Reserve(4 * len(sections))
+	for i, section := range sections {
+		start := encoder.Len()
+		encoder.WriteBytes(section.bytes)
+		if align {
+			encoder.Pad(8, section.padding)
+		}
+		encoder.PutLength32(lengths+i*4, encoder.Len()-start)
+	}
+
+	encoder.PutLength32(byteLength, encoder.Len())
+	return encoder.Bytes()
 }
 
 func (c *StandardConsumer) computeAverageXYZ(intermediatePointData *intermediateData) []float64 {
@@ -256,55 +487,123 @@ func (c *StandardConsumer) subtractXYZFromIntermediateDataCoords(intermediatePoi
 }
 
 // Generates the json representation of the feature table
-func (c *StandardConsumer) generateFeatureTableJsonContent(x, y, z float64, pointNo int, spaceNo int) string {
+func (c *StandardConsumer) generateFeatureTableJsonContent(x, y, z float64, pointNo int, colorSemantic string, spaceNo int) string {
 	sb := ""
 	sb += "{\"POINTS_LENGTH\":" + strconv.Itoa(pointNo) + ","
 	sb += "\"RTC_CENTER\":[" + fmt.Sprintf("%f", x) + strings.Repeat("0", spaceNo)
 	sb += "," + fmt.Sprintf("%f", y) + "," + fmt.Sprintf("%f", z) + "],"
-	sb += "\"POSITION\":" + "{\"byteOffset\":" + "0" + "},"
-	sb += "\"RGB\":" + "{\"byteOffset\":" + strconv.Itoa(pointNo*12) + "}}"
+	sb += "\"POSITION\":" + "{\"byteOffset\":" + "0" + "}"
+	if colorSemantic != "" {
+		sb += ",\"" + colorSemantic + "\":" + "{\"byteOffset\":" + strconv.Itoa(pointNo*12) + "}"
+	}
+	sb += "}"
 	headerByteLength := len([]byte(sb))
 	paddingSize := headerByteLength % 4
 	if paddingSize != 0 {
-		return c.generateFeatureTableJsonContent(x, y, z, pointNo, 4-paddingSize)
+		return c.generateFeatureTableJsonContent(x, y, z, pointNo, colorSemantic, 4-paddingSize)
 	}
 	return sb
 }
 
+// Generates the json representation of the batch table holding the properties as JSON arrays
+func (c *StandardConsumer) generateBatchTableJsonArrays(intermediateData *intermediateData) string {
+	var sb strings.Builder
+	writeArray := func(name string, values []uint8) {
+		sb.WriteString("\"" + name + "\":[")
+		for i, value := range values {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(strconv.Itoa(int(value)))
+		}
+		sb.WriteByte(']')
+	}
+	sb.WriteByte('{')
+	writeArray("INTENSITY", intermediateData.intensities)
+	sb.WriteByte(',')
+	writeArray("CLASSIFICATION", intermediateData.classifications)
+	for j, name := range intermediateData.attributeNames {
+		sb.WriteString(",\"" + name + "\":[")
+		for i, value := range intermediateData.attributes[j] {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(strconv.FormatFloat(float64(value), 'g', -1, 32))
+		}
+		sb.WriteByte(']')
+	}
+	sb.WriteByte('}')
+	for sb.Len()%4 != 0 {
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+// Returns the alpha of a point with the given intensity, scaled linearly from the given min alpha in the [0, 1] range
+// for null intensities to opaque for the max intensity
+func getIntensityAlpha(intensity uint8, minAlpha float64) uint8 {
+	return uint8(math.Round((minAlpha + (1-minAlpha)*float64(intensity)/255) * 255))
+}
+
 // Generates the json representation of the batch table
-func (c *StandardConsumer) generateBatchTableJsonContent(pointNumber, spaceNumber int) string {
+func (c *StandardConsumer) generateBatchTableJsonContent(pointNumber int, attributeNames []string, spaceNumber int) string {
 	sb := ""
 	sb += "{\"INTENSITY\":" + "{\"byteOffset\":" + "0" + ", \"componentType\":\"UNSIGNED_BYTE\", \"type\":\"SCALAR\"},"
-	sb += "\"CLASSIFICATION\":" + "{\"byteOffset\":" + strconv.Itoa(pointNumber) + ", \"componentType\":\"UNSIGNED_BYTE\", \"type\":\"SCALAR\"}}"
+	sb += "\"CLASSIFICATION\":" + "{\"byteOffset\":" + strconv.Itoa(pointNumber) + ", \"componentType\":\"UNSIGNED_BYTE\", \"type\":\"SCALAR\"}"
+	for j, name := range attributeNames {
+		byteOffset := getAttributesByteOffset(pointNumber) + j*pointNumber*4
+		sb += ",\"" + name + "\":" + "{\"byteOffset\":" + strconv.Itoa(byteOffset) + ", \"componentType\":\"FLOAT\", \"type\":\"SCALAR\"}"
+	}
+	sb += "}"
 	sb += strings.Repeat(" ", spaceNumber)
 	headerByteLength := len([]byte(sb))
 	paddingSize := headerByteLength % 4
 	if paddingSize != 0 {
-		return c.generateBatchTableJsonContent(pointNumber, 4-paddingSize)
+		return c.generateBatchTableJsonContent(pointNumber, attributeNames, 4-paddingSize)
 	}
 	return sb
 }
 
+// Writes the top-down thumbnail of the points of the tile of the given WorkUnit in its thumbnail folder
+func (c *StandardConsumer) writeThumbnailFile(workUnit WorkUnit) error {
+	if err := c.storage.MkdirAll(workUnit.ThumbnailPath, 0777); err != nil {
+		return err
+	}
+
+	rasterizer := raster.NewRasterizer(workUnit.Opts.ThumbnailSize)
+	pngData, err := rasterizer.RenderPng(c.getTilePoints(workUnit.Node), workUnit.Node.GetBoundingBox())
+	if err != nil {
+		return err
+	}
+
+	return c.storage.WriteFile(path.Join(workUnit.ThumbnailPath, thumbnailFileName), pngData, 0666)
+}
+
 // Writes the tileset.json file for the given WorkUnit
 func (c *StandardConsumer) writeTilesetJsonFile(workUnit WorkUnit) error {
 	parentFolder := workUnit.BasePath
 	node := workUnit.Node
 
 	// Create base folder if it does not exist
-	err := tools.CreateDirectoryIfDoesNotExist(parentFolder)
+	err := c.storage.MkdirAll(parentFolder, 0777)
 	if err != nil {
 		return err
 	}
 
 	// tileset.json file
-	file := path.Join(parentFolder, "tileset.json")
-	jsonData, err := c.generateTilesetJson(node)
+	file := path.Join(parentFolder, tilesetFileName)
+	localFrame, err := c.getLocalFrame(workUnit)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := c.generateTilesetJson(node, workUnit.Opts, localFrame, parentFolder)
 	if err != nil {
 		return err
 	}
 
 	// Writes the tileset.json binary content to the given file
-	err = ioutil.WriteFile(file, jsonData, 0666)
+	err = c.storage.WriteFile(file, jsonData, 0666)
 	if err != nil {
 		return err
 	}
@@ -312,16 +611,91 @@ func (c *StandardConsumer) writeTilesetJsonFile(workUnit WorkUnit) error {
 	return nil
 }
 
-// Generates the tileset.json content for the given tree node
-func (c *StandardConsumer) generateTilesetJson(node octree.INode) ([]byte, error) {
+// Writes the tileset.json files deferred by the content index of the consumer
+func (c *StandardConsumer) WriteDeferredTilesets() error {
+	for _, workUnit := range c.contentIndex.tilesets {
+		if err := c.writeTilesetJsonFile(*workUnit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Generates the tileset.json content for the given tree node, to be written in the given folder
+func (c *StandardConsumer) generateTilesetJson(node octree.INode, opts *tiler.TilerOptions, localFrame *geometry.LocalFrame, folder string) ([]byte, error) {
 	if !node.IsLeaf() || node.IsRoot() {
-		root, err := c.generateTilesetRoot(node)
+		root, err := c.generateTilesetRoot(node, opts)
 		if err != nil {
 			return nil, err
 		}
 
+		// the transform to ECEF is only declared once by the tree root, nested tilesets inherit it
+		if localFrame != nil && node.IsRoot() {
+			root.Transform = localFrame.GetTransform()
+		}
+		if opts.ImplicitTiling {
+			setImplicitTiling(root, node, opts)
+		}
+
 		tileset := *c.generateTileset(node, root)
 
+		tileset.Root.Content.Url, err = c.getContentUri(folder, tileset.Root.Content.Url, opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range tileset.Root.Children {
+			// nested tilesets are hosted together with the tileset referencing them
+			if path.Base(tileset.Root.Children[i].Content.Url) == tilesetFileName {
+				continue
+			}
+			tileset.Root.Children[i].Content.Url, err = c.getContentUri(folder, tileset.Root.Children[i].Content.Url, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// every tileset declares the schema of the metadata of its tiles, as external tilesets do not inherit it
+		// implicit tiling is part of the 3D Tiles 1.1 specification, which still supports the pnts contents
+		if opts.TilesetVersion == tiler.TilesetVersion11 || opts.ImplicitTiling {
+			tileset.Asset.Version = tiler.TilesetVersion11.String()
+		}
+		if opts.TileMetadata {
+			tileset.Asset.Version = metadataAssetVersion
+			tileset.Schema = generateTileStatsSchema()
+		}
+		// the contents cannot be decoded without the extension, which every tileset referencing them declares
+		if opts.DracoCompression {
+			tileset.ExtensionsUsed = []string{dracoPointCompressionExtension}
+			tileset.ExtensionsRequired = []string{dracoPointCompressionExtension}
+		}
+
+		// the terrain offset is a property of the whole point cloud, hence it is recorded only by the tree root
+		if opts.TerrainFile != "" && node.IsRoot() {
+			tileset.Asset.Extras = map[string]interface{}{"terrainOffset": opts.TerrainOffset}
+		}
+		if opts.License != "" && node.IsRoot() {
+			if tileset.Asset.Extras == nil {
+				tileset.Asset.Extras = make(map[string]interface{})
+			}
+			license := map[string]string{"name": opts.License}
+			if opts.LicenseUrl != "" {
+				license["url"] = opts.LicenseUrl
+			}
+			tileset.Asset.Extras["license"] = license
+		}
+		if opts.RunExtras != nil && node.IsRoot() {
+			if tileset.Asset.Extras == nil {
+				tileset.Asset.Extras = make(map[string]interface{})
+			}
+			tileset.Asset.Extras["run"] = opts.RunExtras
+		}
+		if opts.ScanExtras != nil && node.IsRoot() {
+			if tileset.Asset.Extras == nil {
+				tileset.Asset.Extras = make(map[string]interface{})
+			}
+			tileset.Asset.Extras["scans"] = opts.ScanExtras
+		}
+
 		// Outputting a formatted json file
 		e, err := json.MarshalIndent(tileset, "", "\t")
 		if err != nil {
@@ -334,25 +708,41 @@ func (c *StandardConsumer) generateTilesetJson(node octree.INode) ([]byte, error
 	return nil, errors.New("this node is a leaf, cannot create a tileset json for it")
 }
 
-func (c *StandardConsumer) generateTilesetRoot(node octree.INode) (*Root, error) {
+// Returns the uri of the tile content whose default uri, relative to the given tileset folder, is given. Absolute urls
+// are returned if a content base url is set.
+func (c *StandardConsumer) getContentUri(folder string, uri string, opts *tiler.TilerOptions) (string, error) {
+	// tile contents may be written at other paths, e.g. pointing to the identical content written for another tile
+	if c.contentIndex != nil {
+		uri = c.contentIndex.resolve(folder, uri)
+	}
+	if opts.ContentBaseUrl == "" {
+		return uri, nil
+	}
+	return getAbsoluteContentUrl(opts.ContentBaseUrl, opts.Output, path.Join(folder, uri))
+}
+
+func (c *StandardConsumer) generateTilesetRoot(node octree.INode, opts *tiler.TilerOptions) (*Root, error) {
 	reg, err := node.GetBoundingBoxRegion(c.coordinateConverter)
 
 	if err != nil {
 		return nil, err
 	}
 
-	children, err := c.generateTilesetChildren(node)
+	children, err := c.generateTilesetChildren(node, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	root := Root{
-		Content:        Content{"content.pnts"},
+		Content:        getContent(getContentFileName(opts), opts),
 		BoundingVolume: BoundingVolume{reg.GetAsArray()},
 		GeometricError: node.ComputeGeometricError(),
 		Refine:         c.refineMode.String(),
 		Children:       children,
 	}
+	if opts.TileMetadata {
+		root.Metadata = c.generateTileMetadata(node)
+	}
 
 	return &root, nil
 }
@@ -366,11 +756,11 @@ func (c *StandardConsumer) generateTileset(node octree.INode, root *Root) *Tiles
 	return &tileset
 }
 
-func (c *StandardConsumer) generateTilesetChildren(node octree.INode) ([]Child, error) {
+func (c *StandardConsumer) generateTilesetChildren(node octree.INode, opts *tiler.TilerOptions) ([]Child, error) {
 	var children []Child
 	for i, child := range node.GetChildren() {
 		if c.nodeContainsPoints(child) {
-			childJson, err := c.generateTilesetChild(child, i)
+			childJson, err := c.generateTilesetChild(child, i, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -384,14 +774,13 @@ func (c *StandardConsumer) nodeContainsPoints(node octree.INode) bool {
 	return node != nil && node.TotalNumberOfPoints() > 0
 }
 
-func (c *StandardConsumer) generateTilesetChild(child octree.INode, childIndex int) (*Child, error) {
+func (c *StandardConsumer) generateTilesetChild(child octree.INode, childIndex int, opts *tiler.TilerOptions) (*Child, error) {
 	childJson := Child{}
-	filename := "tileset.json"
-	if child.IsLeaf() {
-		filename = "content.pnts"
-	}
 	childJson.Content = Content{
-		Url: strconv.Itoa(childIndex) + "/" + filename,
+		Url: strconv.Itoa(childIndex) + "/" + tilesetFileName,
+	}
+	if child.IsLeaf() {
+		childJson.Content = getContent(strconv.Itoa(childIndex)+"/"+getTileContentFileName(child, opts), opts)
 	}
 	reg, err := child.GetBoundingBoxRegion(c.coordinateConverter)
 	if err != nil {
@@ -402,5 +791,8 @@ func (c *StandardConsumer) generateTilesetChild(child octree.INode, childIndex i
 	}
 	childJson.GeometricError = child.ComputeGeometricError()
 	childJson.Refine = c.refineMode.String()
+	if opts.TileMetadata {
+		childJson.Metadata = c.generateTileMetadata(child)
+	}
 	return &childJson, nil
 }
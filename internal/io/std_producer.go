@@ -8,41 +8,60 @@ import (
 	"sync"
 )
 
+// Folder of the output directory where the tile thumbnails are written, mirroring the structure of the tilesets
+const thumbnailsFolder = "thumbnails"
+
 type StandardProducer struct {
-	basePath string
-	options  *tiler.TilerOptions
+	basePath       string
+	thumbnailsPath string
+	options        *tiler.TilerOptions
 }
 
 func NewStandardProducer(basepath string, subfolder string, options *tiler.TilerOptions) Producer {
-	return &StandardProducer{
+	producer := &StandardProducer{
 		basePath: path.Join(basepath, subfolder),
 		options:  options,
 	}
+	if options != nil && options.ThumbnailSize > 0 {
+		producer.thumbnailsPath = path.Join(basepath, thumbnailsFolder, subfolder)
+	}
+	return producer
 }
 
 // Parses a tree node and submits WorkUnits the the provided workchannel. Should be called only on the tree root node.
 // Closes the channel when all work is submitted.
 func (p *StandardProducer) Produce(work chan *WorkUnit, wg *sync.WaitGroup, node octree.INode) {
-	p.produce(p.basePath, node, work, wg)
+	p.produce(p.basePath, p.thumbnailsPath, node, work, wg)
 	close(work)
 	wg.Done()
 }
 
-// Parses a tree node and submits WorkUnits the the provided workchannel.
-func (p *StandardProducer) produce(basePath string, node octree.INode, work chan *WorkUnit, wg *sync.WaitGroup) {
+// Parses a tree node and submits WorkUnits the the provided workchannel. Thumbnails are not produced if the
+// thumbnails path is empty. No more work is submitted once the job is cancelled.
+func (p *StandardProducer) produce(basePath string, thumbnailsPath string, node octree.INode, work chan *WorkUnit, wg *sync.WaitGroup) {
+	if p.options != nil && p.options.Cancellation.IsCancelled() {
+		return
+	}
+
 	// if node contains points (it should always be the case), then submit work
 	if node.NumberOfPoints() > 0 {
 		work <- &WorkUnit{
-			Node:     node,
-			BasePath: basePath,
-			Opts:     p.options,
+			Node:          node,
+			BasePath:      basePath,
+			RootPath:      p.basePath,
+			Opts:          p.options,
+			ThumbnailPath: thumbnailsPath,
 		}
 	}
 
 	// iterate all non nil children and recursively submit all work units
 	for i, child := range node.GetChildren() {
 		if child != nil && child.IsInitialized() {
-			p.produce(path.Join(basePath, strconv.Itoa(i)), child, work, wg)
+			childThumbnailsPath := ""
+			if thumbnailsPath != "" {
+				childThumbnailsPath = path.Join(thumbnailsPath, strconv.Itoa(i))
+			}
+			p.produce(path.Join(basePath, strconv.Itoa(i)), childThumbnailsPath, child, work, wg)
 		}
 	}
 }
@@ -0,0 +1,116 @@
+package io
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/stats"
+)
+
+// Id of the metadata schema declared by the tilesets and class of the metadata attached to every tile
+const (
+	tileStatsSchemaId = "gocesiumtiler"
+	tileStatsClass    = "tileStats"
+)
+
+// Version of the 3D Tiles specification introducing the metadata of tilesets and tiles
+const metadataAssetVersion = "1.1"
+
+// 3D Tiles metadata schema, describing the classes of the metadata entities of a tileset
+type Schema struct {
+	Id      string                 `json:"id"`
+	Classes map[string]SchemaClass `json:"classes"`
+}
+
+type SchemaClass struct {
+	Name        string                   `json:"name,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Properties  map[string]ClassProperty `json:"properties"`
+}
+
+type ClassProperty struct {
+	Description   string `json:"description,omitempty"`
+	Type          string `json:"type"`
+	ComponentType string `json:"componentType,omitempty"`
+	Array         bool   `json:"array,omitempty"`
+	Semantic      string `json:"semantic,omitempty"`
+}
+
+// Metadata entity attached to a tile, holding the values of the properties of its class
+type TileMetadata struct {
+	Class      string                 `json:"class"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Generates the schema of the aggregate statistics attached to every tile, which are expressed in the units of the
+// tileset bounding regions, i.e. elevations are meters above the ellipsoid
+func generateTileStatsSchema() *Schema {
+	return &Schema{
+		Id: tileStatsSchemaId,
+		Classes: map[string]SchemaClass{
+			tileStatsClass: {
+				Name:        "Tile statistics",
+				Description: "Aggregate statistics of the points stored in the tile content",
+				Properties: map[string]ClassProperty{
+					"pointCount": {
+						Description:   "Number of points of the tile content",
+						Type:          "SCALAR",
+						ComponentType: "UINT32",
+					},
+					"minElevation": {
+						Description:   "Minimum elevation of the points",
+						Type:          "SCALAR",
+						ComponentType: "FLOAT64",
+						Semantic:      "TILE_MINIMUM_HEIGHT",
+					},
+					"maxElevation": {
+						Description:   "Maximum elevation of the points",
+						Type:          "SCALAR",
+						ComponentType: "FLOAT64",
+						Semantic:      "TILE_MAXIMUM_HEIGHT",
+					},
+					"meanElevation": {
+						Description:   "Mean elevation of the points",
+						Type:          "SCALAR",
+						ComponentType: "FLOAT64",
+					},
+					"classifications": {
+						Description:   "Classifications of the points, in ascending order",
+						Type:          "SCALAR",
+						ComponentType: "UINT8",
+						Array:         true,
+					},
+					"classificationCounts": {
+						Description:   "Number of points of each of the classifications",
+						Type:          "SCALAR",
+						ComponentType: "UINT32",
+						Array:         true,
+					},
+				},
+			},
+		},
+	}
+}
+
+// Computes the metadata of the tile of the given node from the points written in its content
+func (c *StandardConsumer) generateTileMetadata(node octree.INode) *TileMetadata {
+	tileStats := stats.ComputeTileStats(c.getTilePoints(node))
+
+	// classifications are stored as ints as byte slices would be encoded as base64 strings
+	classifications := make([]int, len(tileStats.Classes))
+	counts := make([]uint32, len(tileStats.Classes))
+	for i, class := range tileStats.Classes {
+		classifications[i] = int(class.Classification)
+		counts[i] = class.Count
+	}
+
+	return &TileMetadata{
+		Class: tileStatsClass,
+		Properties: map[string]interface{}{
+			"pointCount":           tileStats.PointCount,
+			"minElevation":         tileStats.MinElevation,
+			"maxElevation":         tileStats.MaxElevation,
+			"meanElevation":        tileStats.MeanElevation,
+			"classifications":      classifications,
+			"classificationCounts": counts,
+		},
+	}
+}
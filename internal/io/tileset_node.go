@@ -0,0 +1,406 @@
+package io
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// State shared by the nodes of a mounted tileset
+type mountedTileset struct {
+	storage storage.Storage
+	decode  func(content []byte) ([]byte, error)
+	err     error
+	sync.Mutex
+	// implicit tiling of the tileset and folder of its tileset.json file, nil if the tiles are declared explicitly
+	implicit       *ImplicitTiling
+	implicitFolder string
+	contentUri     string
+	subtrees       map[implicitTile]*subtree
+	subtreesLock   sync.Mutex
+	// true if the root tile is refined with the REPLACE mode, its tiles repeating the points of their ancestors
+	replace bool
+}
+
+// Read-only node of a tileset generated by the tiler, mounted so that it can be traversed like a built tree. The
+// tileset.json files of the nested tilesets and the tile contents are loaded the first time the children or the points
+// of a node are accessed, and the points are converted to EPSG:4326 coordinates with ellipsoidal heights. The points
+// of the tilesets generated with the REPLACE refine mode also include the ones of the ancestors contained in the tile.
+// As INode methods cannot fail, loading errors leave the nodes empty and are reported by Err.
+type TilesetNode struct {
+	mount          *mountedTileset
+	parent         *TilesetNode
+	region         []float64
+	geometricError float64
+	// transform of the points to ECEF coordinates, declared by the root tileset
+	transform []float64
+	// path of the nested tileset declaring the content and the children of the tile, empty if declared by the parent
+	tilesetPath string
+	// coordinates of the tile in the implicit tiling of the tileset, nil if declared explicitly
+	tile *implicitTile
+	// folder of the tileset declaring the content and the children of the tile
+	folder      string
+	contentUri  string
+	declared    []Child
+	tilesetOnce sync.Once
+	children    [8]octree.INode
+	childOnce   sync.Once
+	points      []*data.Point
+	pointsOnce  sync.Once
+	total       int64
+	totalOnce   sync.Once
+}
+
+// Mounts the tileset whose tileset.json file is at the given path, reading its files from the given storage and
+// decoding the tile contents with the given function, if not nil, e.g. to decompress them
+func MountTileset(tilesetPath string, storage storage.Storage, decode func(content []byte) ([]byte, error)) (*TilesetNode, error) {
+	mount := &mountedTileset{storage: storage, decode: decode}
+	tileset, err := mount.readTileset(tilesetPath)
+	if err != nil {
+		return nil, err
+	}
+	mount.replace = tileset.Root.Refine == "REPLACE"
+	if len(tileset.Root.BoundingVolume.Region) != 6 {
+		return nil, errors.New("tileset root without bounding region")
+	}
+
+	root := &TilesetNode{
+		mount:          mount,
+		region:         tileset.Root.BoundingVolume.Region,
+		geometricError: tileset.Root.GeometricError,
+		transform:      tileset.Root.Transform,
+	}
+	root.setTileset(path.Dir(tilesetPath), tileset.Root.Content.Url, tileset.Root.Children)
+	if implicit := tileset.Root.ImplicitTiling; implicit != nil {
+		if implicit.SubdivisionScheme != implicitSubdivisionScheme || implicit.SubtreeLevels < 1 {
+			return nil, errors.New("unsupported implicit tiling in " + tilesetPath)
+		}
+		mount.implicit, mount.implicitFolder, mount.contentUri = implicit, root.folder, root.contentUri
+		mount.subtrees = make(map[implicitTile]*subtree)
+		root.setImplicitTile(implicitTile{})
+	}
+	return root, nil
+}
+
+// Returns the first error raised loading the tileset files
+func (n *TilesetNode) Err() error {
+	n.mount.Lock()
+	defer n.mount.Unlock()
+	return n.mount.err
+}
+
+// Returns true if the tileset is refined with the REPLACE mode, the points of its tiles including the ones of their
+// ancestors
+func (n *TilesetNode) IsReplaceRefined() bool {
+	return n.mount.replace
+}
+
+func (n *TilesetNode) AddDataPoint(element *data.Point) {}
+
+func (n *TilesetNode) GetInternalSrid() int {
+	return 4326
+}
+
+func (n *TilesetNode) IsRoot() bool {
+	return n.parent == nil
+}
+
+// Returns the bounding region declared by the tileset, in the same order used by the coordinate converters
+func (n *TilesetNode) GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error) {
+	return geometry.NewBoundingBox(n.region[0], n.region[1], n.region[2], n.region[3], n.region[4], n.region[5]), nil
+}
+
+func (n *TilesetNode) GetChildren() [8]octree.INode {
+	n.childOnce.Do(func() {
+		if n.tile != nil {
+			n.loadImplicitChildren()
+			return
+		}
+		n.loadTileset()
+		for _, child := range n.declared {
+			if len(child.BoundingVolume.Region) != 6 {
+				n.mount.fail(errors.New("tile without bounding region in " + n.folder))
+				continue
+			}
+			node := &TilesetNode{
+				mount:          n.mount,
+				parent:         n,
+				region:         child.BoundingVolume.Region,
+				geometricError: child.GeometricError,
+				transform:      n.transform,
+			}
+			if path.Base(child.Content.Url) == tilesetFileName {
+				node.tilesetPath = path.Join(n.folder, child.Content.Url)
+			} else {
+				node.setTileset(n.folder, child.Content.Url, nil)
+			}
+			n.placeChild(node, child.Content.Url)
+		}
+	})
+	return n.children
+}
+
+// Places the given child in the octant its uri starts with, as in the default layout, or in the first free one
+func (n *TilesetNode) placeChild(child *TilesetNode, uri string) {
+	octant, err := strconv.Atoi(strings.SplitN(uri, "/", 2)[0])
+	if err == nil && octant >= 0 && octant < 8 && n.children[octant] == nil {
+		n.children[octant] = child
+		return
+	}
+	for i := range n.children {
+		if n.children[i] == nil {
+			n.children[i] = child
+			return
+		}
+	}
+	n.mount.fail(errors.New("more than 8 children declared in " + n.folder))
+}
+
+func (n *TilesetNode) GetPoints() []*data.Point {
+	n.pointsOnce.Do(func() {
+		n.loadTileset()
+		if n.contentUri == "" {
+			return
+		}
+		points, err := n.loadPoints()
+		if err != nil {
+			n.mount.fail(err)
+			return
+		}
+		n.points = points
+	})
+	return n.points
+}
+
+func (n *TilesetNode) TotalNumberOfPoints() int64 {
+	n.totalOnce.Do(func() {
+		n.total = n.NumberOfPoints()
+		for _, child := range n.GetChildren() {
+			if child != nil {
+				n.total += child.TotalNumberOfPoints()
+			}
+		}
+	})
+	return n.total
+}
+
+func (n *TilesetNode) NumberOfPoints() int64 {
+	return int64(len(n.GetPoints()))
+}
+
+func (n *TilesetNode) IsLeaf() bool {
+	if n.tile != nil {
+		for _, child := range n.GetChildren() {
+			if child != nil {
+				return false
+			}
+		}
+		return true
+	}
+	n.loadTileset()
+	return len(n.declared) == 0
+}
+
+func (n *TilesetNode) IsInitialized() bool {
+	return true
+}
+
+func (n *TilesetNode) ComputeGeometricError() float64 {
+	return n.geometricError
+}
+
+func (n *TilesetNode) GetParent() octree.INode {
+	if n.parent == nil {
+		return nil
+	}
+	return n.parent
+}
+
+// Returns the bounding box of the node in EPSG:4326 coordinates
+func (n *TilesetNode) GetBoundingBox() *geometry.BoundingBox {
+	toDegrees := 180 / math.Pi
+	return geometry.NewBoundingBox(n.region[0]*toDegrees, n.region[2]*toDegrees, n.region[1]*toDegrees, n.region[3]*toDegrees, n.region[4], n.region[5])
+}
+
+// Records the content and the children declared for the tile by the tileset in the given folder
+func (n *TilesetNode) setTileset(folder string, contentUri string, children []Child) {
+	n.folder = folder
+	n.contentUri = contentUri
+	n.declared = children
+}
+
+// Loads the nested tileset declaring the content and the children of the tile, if any
+func (n *TilesetNode) loadTileset() {
+	n.tilesetOnce.Do(func() {
+		if n.tilesetPath == "" {
+			return
+		}
+		tileset, err := n.mount.readTileset(n.tilesetPath)
+		if err != nil {
+			n.mount.fail(err)
+			return
+		}
+		n.setTileset(path.Dir(n.tilesetPath), tileset.Root.Content.Url, tileset.Root.Children)
+	})
+}
+
+// Records the coordinates of the tile in the implicit tiling and the uri of its content, if available
+func (n *TilesetNode) setImplicitTile(tile implicitTile) {
+	n.tile = &tile
+	n.folder = n.mount.implicitFolder
+	n.contentUri = ""
+	if _, content := n.mount.getImplicitAvailability(tile); content {
+		n.contentUri = tile.expand(n.mount.contentUri)
+	}
+}
+
+// Loads the available children of the implicit tile, whose regions are the octants of its region and whose geometric
+// error is half of its own
+func (n *TilesetNode) loadImplicitChildren() {
+	for octant := range n.children {
+		tile := n.tile.child(octant)
+		if available, _ := n.mount.getImplicitAvailability(tile); !available {
+			continue
+		}
+		region := make([]float64, 6)
+		copy(region, n.region)
+		// the region is split along the longitude, the latitude and the height, ordered as the axes of the octants
+		for axis, bounds := range [][2]int{{0, 2}, {1, 3}, {4, 5}} {
+			mid := (n.region[bounds[0]] + n.region[bounds[1]]) / 2
+			if octant>>axis&1 == 1 {
+				region[bounds[0]] = mid
+			} else {
+				region[bounds[1]] = mid
+			}
+		}
+		child := &TilesetNode{
+			mount:          n.mount,
+			parent:         n,
+			region:         region,
+			geometricError: n.geometricError / 2,
+			transform:      n.transform,
+		}
+		child.setImplicitTile(tile)
+		n.children[octant] = child
+	}
+}
+
+// Returns whether the given implicit tile and its content are available, reading the subtree holding the tile
+func (m *mountedTileset) getImplicitAvailability(tile implicitTile) (bool, bool) {
+	root := tile.getSubtreeRoot(m.implicit.SubtreeLevels)
+	subtree := m.getSubtree(root)
+	if subtree == nil {
+		return false, false
+	}
+	index := tile.getSubtreeIndex(root, m.implicit.SubtreeLevels)
+	return subtree.tiles[index], subtree.contents[index]
+}
+
+// Returns the subtree rooted at the given tile, nil if it is not available, reading it the first time it is requested
+func (m *mountedTileset) getSubtree(root implicitTile) *subtree {
+	// the availability of the subtrees is declared by their parent subtree
+	if root.level > 0 {
+		parentRoot := implicitTile{level: root.level - 1, x: root.x >> 1, y: root.y >> 1, z: root.z >> 1}.getSubtreeRoot(m.implicit.SubtreeLevels)
+		parent := m.getSubtree(parentRoot)
+		if parent == nil || !parent.children[root.getSubtreeIndex(parentRoot, m.implicit.SubtreeLevels)] {
+			return nil
+		}
+	}
+
+	m.subtreesLock.Lock()
+	defer m.subtreesLock.Unlock()
+	if tree, ok := m.subtrees[root]; ok {
+		return tree
+	}
+	content, err := m.readFile(path.Join(m.implicitFolder, root.expand(m.implicit.Subtrees.Url)))
+	var tree *subtree
+	if err == nil {
+		tree, err = readSubtree(content, m.implicit.SubtreeLevels)
+	}
+	if err != nil {
+		m.fail(err)
+	}
+	m.subtrees[root] = tree
+	return tree
+}
+
+// Reads the points of the tile content, converting them to EPSG:4326 coordinates
+func (n *TilesetNode) loadPoints() ([]*data.Point, error) {
+	if strings.Contains(n.contentUri, "://") {
+		return nil, errors.New("cannot load the tile content at the absolute url " + n.contentUri)
+	}
+	content, err := n.mount.readFile(path.Join(n.folder, n.contentUri))
+	if err != nil {
+		return nil, err
+	}
+	if n.mount.decode != nil {
+		if content, err = n.mount.decode(content); err != nil {
+			return nil, err
+		}
+	}
+	readContent := readPnts
+	if isGlb(content) {
+		readContent = readGlb
+	} else if isCmpt(content) {
+		readContent = readCmpt
+	}
+	pntsPoints, err := readContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*data.Point, len(pntsPoints))
+	for i, point := range pntsPoints {
+		x, y, z := point.X, point.Y, point.Z
+		if len(n.transform) == 16 {
+			t := n.transform
+			x, y, z = t[0]*point.X+t[4]*point.Y+t[8]*point.Z+t[12],
+				t[1]*point.X+t[5]*point.Y+t[9]*point.Z+t[13],
+				t[2]*point.X+t[6]*point.Y+t[10]*point.Z+t[14]
+		}
+		lon, lat, height := geometry.EcefToGeographic(x, y, z)
+		points[i] = data.NewPoint(lon, lat, height, point.R, point.G, point.B, point.Intensity, point.Classification)
+	}
+	return points, nil
+}
+
+// Reads the tileset.json file at the given path
+func (m *mountedTileset) readTileset(tilesetPath string) (*Tileset, error) {
+	content, err := m.readFile(tilesetPath)
+	if err != nil {
+		return nil, err
+	}
+	var tileset Tileset
+	if err := json.Unmarshal(content, &tileset); err != nil {
+		return nil, errors.New("invalid tileset " + tilesetPath + ": " + err.Error())
+	}
+	return &tileset, nil
+}
+
+func (m *mountedTileset) readFile(filePath string) ([]byte, error) {
+	file, err := m.storage.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return ioutil.ReadAll(file)
+}
+
+// Records the given error unless another one has already been recorded
+func (m *mountedTileset) fail(err error) {
+	m.Lock()
+	if m.err == nil {
+		m.err = err
+	}
+	m.Unlock()
+}
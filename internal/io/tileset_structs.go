@@ -1,11 +1,13 @@
 package io
 
 type Asset struct {
-	Version string `json:"version"`
+	Version string                 `json:"version"`
+	Extras  map[string]interface{} `json:"extras,omitempty"`
 }
 
 type Content struct {
-	Url string `json:"uri"`
+	Url    string            `json:"uri"`
+	Extras map[string]string `json:"extras,omitempty"`
 }
 
 type BoundingVolume struct {
@@ -17,18 +19,36 @@ type Child struct {
 	BoundingVolume BoundingVolume `json:"boundingVolume"`
 	GeometricError float64        `json:"geometricError"`
 	Refine         string         `json:"refine"`
+	Metadata       *TileMetadata  `json:"metadata,omitempty"`
 }
 
 type Root struct {
-	Children       []Child        `json:"children"`
-	Content        Content        `json:"content"`
-	BoundingVolume BoundingVolume `json:"boundingVolume"`
-	GeometricError float64        `json:"geometricError"`
-	Refine         string         `json:"refine"`
+	Transform      []float64       `json:"transform,omitempty"`
+	Children       []Child         `json:"children,omitempty"`
+	Content        Content         `json:"content"`
+	BoundingVolume BoundingVolume  `json:"boundingVolume"`
+	GeometricError float64         `json:"geometricError"`
+	Refine         string          `json:"refine"`
+	Metadata       *TileMetadata   `json:"metadata,omitempty"`
+	ImplicitTiling *ImplicitTiling `json:"implicitTiling,omitempty"`
+}
+
+type ImplicitTiling struct {
+	SubdivisionScheme string           `json:"subdivisionScheme"`
+	SubtreeLevels     int              `json:"subtreeLevels"`
+	AvailableLevels   int              `json:"availableLevels"`
+	Subtrees          ImplicitSubtrees `json:"subtrees"`
+}
+
+type ImplicitSubtrees struct {
+	Url string `json:"uri"`
 }
 
 type Tileset struct {
-	Asset          Asset   `json:"asset"`
-	GeometricError float64 `json:"geometricError"`
-	Root           Root    `json:"root"`
+	Asset              Asset    `json:"asset"`
+	ExtensionsUsed     []string `json:"extensionsUsed,omitempty"`
+	ExtensionsRequired []string `json:"extensionsRequired,omitempty"`
+	Schema             *Schema  `json:"schema,omitempty"`
+	GeometricError     float64  `json:"geometricError"`
+	Root               Root     `json:"root"`
 }
@@ -0,0 +1,89 @@
+package io
+
+import (
+	"encoding/hex"
+	"errors"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Number of hex digits of the content checksum used by the {hash} placeholder
+const hashPlaceholderLength = 32
+
+// Matches the placeholders of a uri template
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// Placeholders supported by the uri templates
+var placeholders = map[string]bool{
+	"{level}": true,
+	"{x}":     true,
+	"{y}":     true,
+	"{z}":     true,
+	"{path}":  true,
+	"{hash}":  true,
+}
+
+// Template of the path of the tile content files relative to the tileset root folder, e.g. {level}/{x}/{y}/{z}.pnts.
+// The supported placeholders are {level}, the depth of the tile, {x}, {y} and {z}, the indices of the tile along the
+// axes at its level, {path}, the octant indices from the root as a folder path, and {hash}, the checksum of the
+// content of the tile.
+type UriTemplate struct {
+	template string
+}
+
+// Parses the given uri template, returning an error if it contains unknown placeholders or could generate the same
+// path for different tiles
+func ParseUriTemplate(template string) (*UriTemplate, error) {
+	if template == "" || path.IsAbs(template) || strings.Contains(template, "\\") {
+		return nil, errors.New("uri template should be a relative path using / as separator")
+	}
+	for _, element := range strings.Split(template, "/") {
+		if element == ".." {
+			return nil, errors.New("uri template cannot point outside the tileset folder")
+		}
+	}
+
+	found := make(map[string]bool)
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		if !placeholders[placeholder] {
+			return nil, errors.New("unknown uri template placeholder " + placeholder)
+		}
+		found[placeholder] = true
+	}
+	if !found["{hash}"] && !found["{path}"] && !(found["{level}"] && found["{x}"] && found["{y}"] && found["{z}"]) {
+		return nil, errors.New("uri template should contain {hash}, {path} or all of {level}, {x}, {y} and {z}")
+	}
+
+	return &UriTemplate{template: template}, nil
+}
+
+// Returns the path relative to the tileset root of the content of the tile with the given octant path, e.g. 3/1/5 or
+// . for the root, and the given content checksum
+func (t *UriTemplate) expand(tilePath string, checksum []byte) string {
+	var level, x, y, z int
+	octants := ""
+	if tilePath != "." && tilePath != "" {
+		octants = filepath.ToSlash(tilePath)
+		for _, element := range strings.Split(octants, "/") {
+			octant, _ := strconv.Atoi(element)
+			level++
+			x = 2*x + octant&1
+			y = 2*y + (octant>>1)&1
+			z = 2*z + (octant>>2)&1
+		}
+	}
+
+	replacer := strings.NewReplacer(
+		"{level}", strconv.Itoa(level),
+		"{x}", strconv.Itoa(x),
+		"{y}", strconv.Itoa(y),
+		"{z}", strconv.Itoa(z),
+		"{path}", octants,
+		"{hash}", hex.EncodeToString(checksum)[:hashPlaceholderLength],
+	)
+	// the empty {path} of the root could make the path absolute
+	return strings.TrimPrefix(path.Clean(replacer.Replace(t.template)), "/")
+}
@@ -0,0 +1,219 @@
+package io
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"math"
+	"strconv"
+)
+
+// Name of the folder of the wireframe tileset written in the tileset folder
+const WireframeFolder = "wireframe"
+
+// Colors of the boxes of the nodes by their depth, cycled through past the last one
+var wireframeColors = [][3]uint8{
+	{255, 255, 255},
+	{255, 64, 64},
+	{255, 160, 0},
+	{255, 255, 0},
+	{64, 255, 64},
+	{0, 200, 255},
+	{64, 96, 255},
+	{200, 64, 255},
+}
+
+// Tile of a wireframe tileset, whose only child is the tile of the next level
+type wireframeTile struct {
+	Content        Content         `json:"content"`
+	BoundingVolume BoundingVolume  `json:"boundingVolume"`
+	GeometricError float64         `json:"geometricError"`
+	Refine         string          `json:"refine"`
+	Children       []wireframeTile `json:"children,omitempty"`
+}
+
+type wireframeTileset struct {
+	Asset          Asset         `json:"asset"`
+	GeometricError float64       `json:"geometricError"`
+	Root           wireframeTile `json:"root"`
+}
+
+// Companion tileset drawing the bounding boxes of the nodes of a tree, to inspect its structure in the viewers
+type WireframeTileset struct {
+	// content of the tileset.json file
+	Tileset []byte
+	// glb contents of the levels of the tree, from the root one, named by GetWireframeLevelFileName
+	Levels [][]byte
+}
+
+// Returns the name of the glb content of the given level of a wireframe tileset
+func GetWireframeLevelFileName(depth int) string {
+	return "level_" + strconv.Itoa(depth) + ".glb"
+}
+
+// Generates the wireframe tileset of the tree of the given root, made of a tile per level holding the edges of the
+// boxes of the nodes as a glTF line primitive, colored by the depth of the nodes. The tile of a level refines the one of
+// the previous level, replacing it once its geometric error, the largest of the ones of the nodes of the level, is
+// exceeded as for the point tiles, and also holds the leaves of the previous levels, so that every level draws the nodes
+// shown by the point tileset when all its tiles are refined down to the level.
+func GenerateWireframeTileset(root octree.INode, converter converters.CoordinateConverter) (*WireframeTileset, error) {
+	region, err := root.GetBoundingBoxRegion(converter)
+	if err != nil {
+		return nil, err
+	}
+	box := root.GetBoundingBox()
+	center, err := converter.ConvertToWGS84Cartesian(geometry.Coordinate{X: box.Xmid, Y: box.Ymid, Z: box.Zmid}, root.GetInternalSrid())
+	if err != nil {
+		return nil, err
+	}
+
+	wireframe := &WireframeTileset{}
+	var tiles []wireframeTile
+	var leaves []octree.INode
+	var leafDepths []int
+	for depth, level := 0, []octree.INode{root}; len(level) > 0; depth++ {
+		nodes, depths := append([]octree.INode{}, leaves...), append([]int{}, leafDepths...)
+		geometricError := 0.0
+		var next []octree.INode
+		for _, node := range level {
+			nodes, depths = append(nodes, node), append(depths, depth)
+			geometricError = math.Max(geometricError, node.ComputeGeometricError())
+			leaf := true
+			for _, child := range node.GetChildren() {
+				if child != nil {
+					next, leaf = append(next, child), false
+				}
+			}
+			if leaf {
+				leaves, leafDepths = append(leaves, node), append(leafDepths, depth)
+			}
+		}
+		content, err := generateWireframeGlb(nodes, depths, root.GetInternalSrid(), center, converter)
+		if err != nil {
+			return nil, err
+		}
+		wireframe.Levels = append(wireframe.Levels, content)
+		tiles = append(tiles, wireframeTile{
+			Content:        Content{Url: GetWireframeLevelFileName(depth), Extras: map[string]string{"level": strconv.Itoa(depth)}},
+			BoundingVolume: BoundingVolume{Region: region.GetAsArray()},
+			GeometricError: geometricError,
+			Refine:         "REPLACE",
+		})
+		level = next
+	}
+	for i := len(tiles) - 2; i >= 0; i-- {
+		tiles[i].Children = []wireframeTile{tiles[i+1]}
+	}
+
+	tileset := wireframeTileset{
+		Asset:          Asset{Version: "1.1"},
+		GeometricError: tiles[0].GeometricError,
+		Root:           tiles[0],
+	}
+	if wireframe.Tileset, err = json.MarshalIndent(tileset, "", "\t"); err != nil {
+		return nil, err
+	}
+	return wireframe, nil
+}
+
+// Generates the glb content drawing the 12 edges of the boxes of the given nodes, whose vertices are relative to the
+// given ECEF center and colored by the depth of their node
+func generateWireframeGlb(nodes []octree.INode, depths []int, srid int, center geometry.Coordinate, converter converters.CoordinateConverter) ([]byte, error) {
+	document := &gltfDocument{
+		Asset:  gltfAsset{Version: "2.0", Generator: "gocesiumtiler"},
+		Scenes: []gltfScene{{Nodes: []int{0}}},
+		Nodes:  []gltfNode{{Translation: []float64{center.X, center.Z, -center.Y}}},
+	}
+	vertices := 8 * len(nodes)
+	builder := &glbBuilder{document: document, binary: tools.NewBinaryEncoder(binary.LittleEndian, vertices*(12+4)+len(nodes)*24*4+64)}
+
+	positions := builder.beginBufferView()
+	min := []float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	max := []float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, node := range nodes {
+		box := node.GetBoundingBox()
+		// the corner of index i takes the max x if its bit 0 is set, the max y if its bit 1 is, the max z if its bit 2 is
+		for corner := 0; corner < 8; corner++ {
+			coordinate := geometry.Coordinate{X: box.Xmin, Y: box.Ymin, Z: box.Zmin}
+			if corner&1 != 0 {
+				coordinate.X = box.Xmax
+			}
+			if corner&2 != 0 {
+				coordinate.Y = box.Ymax
+			}
+			if corner&4 != 0 {
+				coordinate.Z = box.Zmax
+			}
+			ecef, err := converter.ConvertToWGS84Cartesian(coordinate, srid)
+			if err != nil {
+				return nil, err
+			}
+			// as glTF is y-up, the coordinates are rotated by -90 degrees around the x axis as the ones of the points
+			yUp := []float32{float32(ecef.X - center.X), float32(ecef.Z - center.Z), float32(center.Y - ecef.Y)}
+			builder.binary.WriteFloat32s(yUp)
+			for j, value := range yUp {
+				min[j] = math.Min(min[j], float64(value))
+				max[j] = math.Max(max[j], float64(value))
+			}
+		}
+	}
+	attributes := map[string]int{
+		"POSITION": builder.addAccessor(gltfAccessor{
+			BufferView:    builder.endBufferView(positions, 0, gltfArrayBuffer),
+			ComponentType: gltfFloat,
+			Count:         vertices,
+			Type:          "VEC3",
+			Min:           min,
+			Max:           max,
+		}),
+	}
+
+	// every vertex attribute element has to be aligned on a 4-byte boundary, so RGB colors are padded to 4 bytes
+	colors := builder.beginBufferView()
+	for _, depth := range depths {
+		color := wireframeColors[depth%len(wireframeColors)]
+		for corner := 0; corner < 8; corner++ {
+			builder.binary.WriteBytes(color[:])
+			builder.binary.Pad(4, 0)
+		}
+	}
+	attributes["COLOR_0"] = builder.addAccessor(gltfAccessor{
+		BufferView:    builder.endBufferView(colors, 4, gltfArrayBuffer),
+		ComponentType: gltfUnsignedByte,
+		Normalized:    true,
+		Count:         vertices,
+		Type:          "VEC3",
+	})
+
+	// the edges join the corners differing by a single coordinate
+	edges := builder.beginBufferView()
+	for i := range nodes {
+		for corner := 0; corner < 8; corner++ {
+			for _, bit := range []int{1, 2, 4} {
+				if corner&bit == 0 {
+					builder.binary.WriteUint32(uint32(8*i + corner))
+					builder.binary.WriteUint32(uint32(8*i + (corner | bit)))
+				}
+			}
+		}
+	}
+	indices := builder.addAccessor(gltfAccessor{
+		BufferView:    builder.endBufferView(edges, 0, gltfElementArrayBuffer),
+		ComponentType: gltfUnsignedInt,
+		Count:         24 * len(nodes),
+		Type:          "SCALAR",
+	})
+
+	mesh := 0
+	document.Nodes[0].Mesh = &mesh
+	document.Meshes = []gltfMesh{{Primitives: []gltfPrimitive{{Attributes: attributes, Indices: &indices, Mode: gltfModeLines}}}}
+	buffer, err := builder.binary.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	document.Buffers = []gltfBuffer{{ByteLength: len(buffer)}}
+	return encodeGlb(document, buffer)
+}
@@ -3,6 +3,7 @@ package io
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"sync"
 )
 
 // Contains the minimal data needed to produce a single 3d tile, i.e. a binary content.pnts file and a tileset.json file
@@ -10,4 +11,17 @@ type WorkUnit struct {
 	Node     octree.INode
 	Opts     *tiler.TilerOptions
 	BasePath string
+	// Folder of the root tile of the tileset the tile belongs to
+	RootPath string
+	// Folder where the thumbnail of the tile has to be written, none is written if empty
+	ThumbnailPath string
+	// Signalled once the consumer is done with the tile, nil if the producer does not wait for the tiles to be written
+	Written *sync.WaitGroup
+}
+
+// Signals that the consumer is done with the tile, whether it has been written or not
+func (w *WorkUnit) done() {
+	if w.Written != nil {
+		w.Written.Done()
+	}
 }
@@ -0,0 +1,65 @@
+package io
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"path"
+	"sync"
+	"time"
+)
+
+// Name of the manifest written in the folder of the tilesets written coarse first
+const WriteManifestFileName = "manifest.json"
+
+// Records the progress of the writing of a tileset written coarse first: the number of levels of its tree whose tiles
+// have all been written, from the root down, and whether the tileset is complete. A partially written or uploaded
+// tileset can be viewed down to its written levels. The manifest is rewritten every time a level is written.
+type WriteManifest struct {
+	Tileset       string    `json:"tileset"`
+	WrittenLevels int       `json:"writtenLevels"`
+	WrittenTiles  int       `json:"writtenTiles"`
+	Complete      bool      `json:"complete"`
+	Updated       time.Time `json:"updated"`
+	storage       storage.Storage
+	filePath      string
+	err           error
+	sync.Mutex
+}
+
+// Returns the manifest of the tileset written in the given folder, written with the given storage
+func NewWriteManifest(storage storage.Storage, folder string) *WriteManifest {
+	return &WriteManifest{
+		Tileset:  tilesetFileName,
+		storage:  storage,
+		filePath: path.Join(folder, WriteManifestFileName),
+	}
+}
+
+// Records that all the given number of tiles of the next level of the tree have been written
+func (m *WriteManifest) LevelWritten(tiles int) {
+	m.Lock()
+	defer m.Unlock()
+	m.WrittenLevels++
+	m.WrittenTiles += tiles
+	m.save()
+}
+
+// Records that the tileset is complete, returning the first error met writing the manifest if any
+func (m *WriteManifest) MarkComplete() error {
+	m.Lock()
+	defer m.Unlock()
+	m.Complete = true
+	m.save()
+	return m.err
+}
+
+func (m *WriteManifest) save() {
+	m.Updated = time.Now().UTC()
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err == nil {
+		err = m.storage.WriteFile(m.filePath, content, 0666)
+	}
+	if err != nil && m.err == nil {
+		m.err = err
+	}
+}
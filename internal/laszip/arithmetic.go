@@ -0,0 +1,342 @@
+package laszip
+
+// Port of the adaptive arithmetic coder of LASzip, itself derived from the coder of Amir Said
+
+const (
+	// Min length of the coding interval, the interval is renormalized once shorter
+	minLength = 0x01000000
+	// Max length of the coding interval
+	maxLength = 0xFFFFFFFF
+	// Length bits discarded when coding with bit models
+	bitModelLengthShift = 13
+	bitModelMaxCount    = 1 << bitModelLengthShift
+	// Length bits discarded when coding with symbol models
+	symbolModelLengthShift = 15
+	symbolModelMaxCount    = 1 << symbolModelLengthShift
+)
+
+// Adaptive probability model of a binary symbol
+type bitModel struct {
+	bit0Count       uint32
+	bitCount        uint32
+	bit0Prob        uint32
+	updateCycle     uint32
+	bitsUntilUpdate uint32
+}
+
+func newBitModel() *bitModel {
+	m := &bitModel{}
+	m.bit0Count = 1
+	m.bitCount = 2
+	m.bit0Prob = 1 << (bitModelLengthShift - 1)
+	m.updateCycle = 4
+	m.bitsUntilUpdate = 4
+	return m
+}
+
+func (m *bitModel) update() {
+	if m.bitCount += m.updateCycle; m.bitCount > bitModelMaxCount {
+		m.bitCount = (m.bitCount + 1) >> 1
+		m.bit0Count = (m.bit0Count + 1) >> 1
+		if m.bit0Count == m.bitCount {
+			m.bitCount++
+		}
+	}
+	scale := 0x80000000 / m.bitCount
+	m.bit0Prob = (m.bit0Count * scale) >> (31 - bitModelLengthShift)
+	m.updateCycle = (5 * m.updateCycle) >> 2
+	if m.updateCycle > 64 {
+		m.updateCycle = 64
+	}
+	m.bitsUntilUpdate = m.updateCycle
+}
+
+// Adaptive probability model of a symbol taking one of a given number of values
+type symbolModel struct {
+	symbols            uint32
+	lastSymbol         uint32
+	distribution       []uint32
+	symbolCount        []uint32
+	totalCount         uint32
+	updateCycle        uint32
+	symbolsUntilUpdate uint32
+}
+
+func newSymbolModel(symbols uint32) *symbolModel {
+	m := &symbolModel{
+		symbols:      symbols,
+		lastSymbol:   symbols - 1,
+		distribution: make([]uint32, symbols),
+		symbolCount:  make([]uint32, symbols),
+		updateCycle:  symbols,
+	}
+	for i := range m.symbolCount {
+		m.symbolCount[i] = 1
+	}
+	m.update()
+	m.updateCycle = (symbols + 6) >> 1
+	m.symbolsUntilUpdate = m.updateCycle
+	return m
+}
+
+func (m *symbolModel) update() {
+	if m.totalCount += m.updateCycle; m.totalCount > symbolModelMaxCount {
+		m.totalCount = 0
+		for i := range m.symbolCount {
+			m.symbolCount[i] = (m.symbolCount[i] + 1) >> 1
+			m.totalCount += m.symbolCount[i]
+		}
+	}
+	var sum uint32
+	scale := 0x80000000 / m.totalCount
+	for i := range m.distribution {
+		m.distribution[i] = (scale * sum) >> (31 - symbolModelLengthShift)
+		sum += m.symbolCount[i]
+	}
+	m.updateCycle = (5 * m.updateCycle) >> 2
+	if maxCycle := (m.symbols + 6) << 3; m.updateCycle > maxCycle {
+		m.updateCycle = maxCycle
+	}
+	m.symbolsUntilUpdate = m.updateCycle
+}
+
+// Arithmetic decoder reading the bytes of a chunk
+type decoder struct {
+	data     []byte
+	position int
+	value    uint32
+	length   uint32
+}
+
+func newDecoder(data []byte) *decoder {
+	d := &decoder{data: data, length: maxLength}
+	for i := 0; i < 4; i++ {
+		d.value = d.value<<8 | d.getByte()
+	}
+	return d
+}
+
+// Returns the next byte of the chunk, zero past its end
+func (d *decoder) getByte() uint32 {
+	d.position++
+	if d.position > len(d.data) {
+		return 0
+	}
+	return uint32(d.data[d.position-1])
+}
+
+// Returns true if the decoder read past the end of the chunk, which only happens if the chunk is corrupted
+func (d *decoder) overrun() bool {
+	return d.position > len(d.data)
+}
+
+func (d *decoder) renormalize() {
+	for {
+		d.value = d.value<<8 | d.getByte()
+		if d.length <<= 8; d.length >= minLength {
+			return
+		}
+	}
+}
+
+func (d *decoder) decodeBit(m *bitModel) uint32 {
+	x := m.bit0Prob * (d.length >> bitModelLengthShift)
+	var bit uint32
+	if d.value < x {
+		d.length = x
+		m.bit0Count++
+	} else {
+		bit = 1
+		d.value -= x
+		d.length -= x
+	}
+	if d.length < minLength {
+		d.renormalize()
+	}
+	if m.bitsUntilUpdate--; m.bitsUntilUpdate == 0 {
+		m.update()
+	}
+	return bit
+}
+
+func (d *decoder) decodeSymbol(m *symbolModel) uint32 {
+	var symbol, x uint32
+	y := d.length
+	d.length >>= symbolModelLengthShift
+	// bisection of the distribution, looking for the last symbol whose interval starts before the value
+	n := m.symbols
+	k := n >> 1
+	for {
+		if z := d.length * m.distribution[k]; z > d.value {
+			n = k
+			y = z
+		} else {
+			symbol = k
+			x = z
+		}
+		if k = (symbol + n) >> 1; k == symbol {
+			break
+		}
+	}
+	d.value -= x
+	d.length = y - x
+	if d.length < minLength {
+		d.renormalize()
+	}
+	m.symbolCount[symbol]++
+	if m.symbolsUntilUpdate--; m.symbolsUntilUpdate == 0 {
+		m.update()
+	}
+	return symbol
+}
+
+// Reads the given number of raw bits, up to 32
+func (d *decoder) readBits(bits uint32) uint32 {
+	if bits > 19 {
+		low := d.readShort()
+		return d.readBits(bits-16)<<16 | low
+	}
+	d.length >>= bits
+	symbol := d.value / d.length
+	d.value -= d.length * symbol
+	if d.length < minLength {
+		d.renormalize()
+	}
+	return symbol
+}
+
+func (d *decoder) readShort() uint32 {
+	return d.readBits(16)
+}
+
+func (d *decoder) readInt() uint32 {
+	low := d.readShort()
+	return d.readShort()<<16 | low
+}
+
+// Arithmetic encoder writing the bytes of a chunk, the counterpart of the decoder
+type encoder struct {
+	buffer []byte
+	base   uint32
+	length uint32
+}
+
+func newEncoder() *encoder {
+	return &encoder{length: maxLength}
+}
+
+func (e *encoder) propagateCarry() {
+	for i := len(e.buffer) - 1; i >= 0; i-- {
+		if e.buffer[i] != 0xFF {
+			e.buffer[i]++
+			return
+		}
+		e.buffer[i] = 0
+	}
+}
+
+func (e *encoder) renormalize() {
+	for {
+		e.buffer = append(e.buffer, byte(e.base>>24))
+		e.base <<= 8
+		if e.length <<= 8; e.length >= minLength {
+			return
+		}
+	}
+}
+
+func (e *encoder) encodeBit(m *bitModel, bit uint32) {
+	x := m.bit0Prob * (e.length >> bitModelLengthShift)
+	if bit == 0 {
+		e.length = x
+		m.bit0Count++
+	} else {
+		initBase := e.base
+		e.base += x
+		e.length -= x
+		if initBase > e.base {
+			e.propagateCarry()
+		}
+	}
+	if e.length < minLength {
+		e.renormalize()
+	}
+	if m.bitsUntilUpdate--; m.bitsUntilUpdate == 0 {
+		m.update()
+	}
+}
+
+func (e *encoder) encodeSymbol(m *symbolModel, symbol uint32) {
+	initBase := e.base
+	if symbol == m.lastSymbol {
+		x := m.distribution[symbol] * (e.length >> symbolModelLengthShift)
+		e.base += x
+		e.length -= x
+	} else {
+		e.length >>= symbolModelLengthShift
+		x := m.distribution[symbol] * e.length
+		e.base += x
+		e.length = m.distribution[symbol+1]*e.length - x
+	}
+	if initBase > e.base {
+		e.propagateCarry()
+	}
+	if e.length < minLength {
+		e.renormalize()
+	}
+	m.symbolCount[symbol]++
+	if m.symbolsUntilUpdate--; m.symbolsUntilUpdate == 0 {
+		m.update()
+	}
+}
+
+// Writes the given number of raw bits, up to 32
+func (e *encoder) writeBits(bits uint32, value uint32) {
+	if bits > 19 {
+		e.writeShort(value & 0xFFFF)
+		value >>= 16
+		bits -= 16
+	}
+	initBase := e.base
+	e.length >>= bits
+	e.base += value * e.length
+	if initBase > e.base {
+		e.propagateCarry()
+	}
+	if e.length < minLength {
+		e.renormalize()
+	}
+}
+
+func (e *encoder) writeShort(value uint32) {
+	e.writeBits(16, value)
+}
+
+func (e *encoder) writeInt(value uint32) {
+	e.writeShort(value & 0xFFFF)
+	e.writeShort(value >> 16)
+}
+
+// Flushes the state of the encoder, returning all the bytes of the chunk. The bytes written match the ones read
+// ahead by the decoder, so that the chunk ends exactly where its decoder stops reading.
+func (e *encoder) done() []byte {
+	initBase := e.base
+	anotherByte := true
+	if e.length > 2*minLength {
+		e.base += minLength
+		e.length = minLength >> 1
+	} else {
+		e.base += minLength >> 1
+		e.length = minLength >> 9
+		anotherByte = false
+	}
+	if initBase > e.base {
+		e.propagateCarry()
+	}
+	e.renormalize()
+	e.buffer = append(e.buffer, 0, 0)
+	if anotherByte {
+		e.buffer = append(e.buffer, 0)
+	}
+	return e.buffer
+}
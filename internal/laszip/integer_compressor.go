@@ -0,0 +1,135 @@
+package laszip
+
+import "math"
+
+// Codes integers as corrections of a prediction, the magnitude of every correction being coded with the model of
+// the context it has been computed in
+type integerCompressor struct {
+	bits      uint32
+	bitsHigh  uint32
+	corrRange uint32
+	corrMin   int32
+	corrMax   int32
+	// models of the number of bits of the corrections, one per context
+	mBits []*symbolModel
+	// model of the zero and one corrections
+	mCorrector0 *bitModel
+	// models of the corrections of every bit length, indexed from 1
+	mCorrector []*symbolModel
+	// bit length of the last correction
+	k uint32
+}
+
+// Builds a compressor of integers of the given number of bits, 32 included, coded in the given number of contexts.
+// The bits exceeding 8 of the longest corrections are coded raw.
+func newIntegerCompressor(bits uint32, contexts int) *integerCompressor {
+	c := &integerCompressor{bits: bits, bitsHigh: 8}
+	if bits > 0 && bits < 32 {
+		c.corrRange = 1 << bits
+		c.corrMin = -int32(c.corrRange / 2)
+		c.corrMax = c.corrMin + int32(c.corrRange-1)
+	} else {
+		c.bits = 32
+		c.corrMin = math.MinInt32
+		c.corrMax = math.MaxInt32
+	}
+
+	c.mBits = make([]*symbolModel, contexts)
+	for i := range c.mBits {
+		c.mBits[i] = newSymbolModel(c.bits + 1)
+	}
+	c.mCorrector0 = newBitModel()
+	c.mCorrector = make([]*symbolModel, c.bits+1)
+	for i := uint32(1); i <= c.bits; i++ {
+		if i <= c.bitsHigh {
+			c.mCorrector[i] = newSymbolModel(1 << i)
+		} else {
+			c.mCorrector[i] = newSymbolModel(1 << c.bitsHigh)
+		}
+	}
+	return c
+}
+
+// Returns the bit length of the last correction, used by the callers to pick the context of related values
+func (c *integerCompressor) getK() uint32 {
+	return c.k
+}
+
+func (c *integerCompressor) decompress(d *decoder, prediction int32, context int) int32 {
+	real := prediction + c.readCorrector(d, c.mBits[context])
+	if real < 0 {
+		real += int32(c.corrRange)
+	} else if uint32(real) >= c.corrRange {
+		real -= int32(c.corrRange)
+	}
+	return real
+}
+
+func (c *integerCompressor) readCorrector(d *decoder, mBits *symbolModel) int32 {
+	c.k = d.decodeSymbol(mBits)
+	if c.k == 0 {
+		return int32(d.decodeBit(c.mCorrector0))
+	}
+	if c.k >= 32 {
+		return c.corrMin
+	}
+
+	var corr int32
+	if c.k <= c.bitsHigh {
+		corr = int32(d.decodeSymbol(c.mCorrector[c.k]))
+	} else {
+		rawBits := c.k - c.bitsHigh
+		corr = int32(d.decodeSymbol(c.mCorrector[c.k]))
+		corr = corr<<rawBits | int32(d.readBits(rawBits))
+	}
+	// the corrections of k bits are either in [-(2^k - 1), -(2^(k-1))] or in [2^(k-1) + 1, 2^k]
+	if corr >= 1<<(c.k-1) {
+		return corr + 1
+	}
+	return corr - (1<<c.k - 1)
+}
+
+func (c *integerCompressor) compress(e *encoder, prediction int32, real int32, context int) {
+	corr := real - prediction
+	if corr < c.corrMin {
+		corr += int32(c.corrRange)
+	} else if corr > c.corrMax {
+		corr -= int32(c.corrRange)
+	}
+	c.writeCorrector(e, corr, c.mBits[context])
+}
+
+func (c *integerCompressor) writeCorrector(e *encoder, corr int32, mBits *symbolModel) {
+	var magnitude uint32
+	if corr <= 0 {
+		magnitude = uint32(-corr)
+	} else {
+		magnitude = uint32(corr - 1)
+	}
+	c.k = 0
+	for magnitude != 0 {
+		magnitude >>= 1
+		c.k++
+	}
+	e.encodeSymbol(mBits, c.k)
+
+	if c.k == 0 {
+		e.encodeBit(c.mCorrector0, uint32(corr))
+		return
+	}
+	if c.k >= 32 {
+		return
+	}
+	if corr < 0 {
+		corr += 1<<c.k - 1
+	} else {
+		corr--
+	}
+	if c.k <= c.bitsHigh {
+		e.encodeSymbol(c.mCorrector[c.k], uint32(corr))
+	} else {
+		rawBits := c.k - c.bitsHigh
+		e.encodeSymbol(c.mCorrector[c.k], uint32(corr>>rawBits))
+		e.writeBits(rawBits, uint32(corr)&(1<<rawBits-1))
+	}
+}
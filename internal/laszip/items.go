@@ -0,0 +1,660 @@
+package laszip
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Ports of the version 2 item compressors of LASzip, each one coding a field group of the point records as a
+// difference from the last point of the chunk
+
+// Codes a part of every point record of a chunk. The record of the first point of the chunk is stored raw, and
+// initializes the coder.
+type itemCoder interface {
+	init(item []byte)
+	read(d *decoder, item []byte)
+	write(e *encoder, item []byte)
+}
+
+// Index of the prediction of the points in the return map of their number of returns and return number
+var numberReturnMap = [8][8]uint8{
+	{15, 14, 13, 12, 11, 10, 9, 8},
+	{14, 0, 1, 3, 6, 10, 10, 9},
+	{13, 1, 2, 4, 7, 11, 11, 10},
+	{12, 3, 4, 5, 8, 12, 12, 11},
+	{11, 6, 7, 8, 9, 13, 13, 12},
+	{10, 10, 11, 12, 13, 14, 14, 13},
+	{9, 10, 11, 12, 13, 14, 15, 14},
+	{8, 9, 10, 11, 12, 13, 14, 15},
+}
+
+// Index of the height prediction of the points in the level map of their number of returns and return number
+var numberReturnLevel = [8][8]uint8{
+	{0, 1, 2, 3, 4, 5, 6, 7},
+	{1, 0, 1, 2, 3, 4, 5, 6},
+	{2, 1, 0, 1, 2, 3, 4, 5},
+	{3, 2, 1, 0, 1, 2, 3, 4},
+	{4, 3, 2, 1, 0, 1, 2, 3},
+	{5, 4, 3, 2, 1, 0, 1, 2},
+	{6, 5, 4, 3, 2, 1, 0, 1},
+	{7, 6, 5, 4, 3, 2, 1, 0},
+}
+
+// Median of the last five values added
+type median5 struct {
+	values [5]int32
+	high   bool
+}
+
+func newMedian5() median5 {
+	return median5{high: true}
+}
+
+func (m *median5) add(v int32) {
+	values := &m.values
+	if m.high {
+		if v < values[2] {
+			values[4] = values[3]
+			values[3] = values[2]
+			if v < values[0] {
+				values[2] = values[1]
+				values[1] = values[0]
+				values[0] = v
+			} else if v < values[1] {
+				values[2] = values[1]
+				values[1] = v
+			} else {
+				values[2] = v
+			}
+		} else {
+			if v < values[3] {
+				values[4] = values[3]
+				values[3] = v
+			} else {
+				values[4] = v
+			}
+			m.high = false
+		}
+	} else {
+		if values[2] < v {
+			values[0] = values[1]
+			values[1] = values[2]
+			if values[4] < v {
+				values[2] = values[3]
+				values[3] = values[4]
+				values[4] = v
+			} else if values[3] < v {
+				values[2] = values[3]
+				values[3] = v
+			} else {
+				values[2] = v
+			}
+		} else {
+			if values[1] < v {
+				values[0] = values[1]
+				values[1] = v
+			} else {
+				values[0] = v
+			}
+			m.high = true
+		}
+	}
+}
+
+func (m *median5) get() int32 {
+	return m.values[2]
+}
+
+// Codes the 20 bytes of the fields shared by the point data record formats 0 to 5
+type point10Coder struct {
+	last          [20]byte
+	lastIntensity [16]uint16
+	lastXDiff     [16]median5
+	lastYDiff     [16]median5
+	lastHeight    [8]int32
+
+	changedValues  *symbolModel
+	scanAngleRank  [2]*symbolModel
+	bitByte        [256]*symbolModel
+	classification [256]*symbolModel
+	userData       [256]*symbolModel
+	intensity      *integerCompressor
+	pointSourceId  *integerCompressor
+	dx             *integerCompressor
+	dy             *integerCompressor
+	z              *integerCompressor
+}
+
+func newPoint10Coder() *point10Coder {
+	c := &point10Coder{
+		changedValues: newSymbolModel(64),
+		scanAngleRank: [2]*symbolModel{newSymbolModel(256), newSymbolModel(256)},
+		intensity:     newIntegerCompressor(16, 4),
+		pointSourceId: newIntegerCompressor(16, 1),
+		dx:            newIntegerCompressor(32, 2),
+		dy:            newIntegerCompressor(32, 22),
+		z:             newIntegerCompressor(32, 20),
+	}
+	for i := range c.lastXDiff {
+		c.lastXDiff[i] = newMedian5()
+		c.lastYDiff[i] = newMedian5()
+	}
+	return c
+}
+
+func (c *point10Coder) init(item []byte) {
+	copy(c.last[:], item)
+	// the intensity is predicted from the last one of the points of the same return, all zero at the start
+	c.last[12], c.last[13] = 0, 0
+}
+
+// Returns the model of the given byte lazily created in the given set, as only a few values are expected
+func getByteModel(models *[256]*symbolModel, value byte) *symbolModel {
+	if models[value] == nil {
+		models[value] = newSymbolModel(256)
+	}
+	return models[value]
+}
+
+// Returns the indexes of the predictions of the given return bit field
+func getReturnIndexes(returns byte) (n uint32, m uint8, l uint8) {
+	r := returns & 7
+	n = uint32(returns>>3) & 7
+	return n, numberReturnMap[n][r], numberReturnLevel[n][r]
+}
+
+func (c *point10Coder) read(d *decoder, item []byte) {
+	last := c.last[:]
+	changed := d.decodeSymbol(c.changedValues)
+	if changed&32 != 0 {
+		last[14] = byte(d.decodeSymbol(getByteModel(&c.bitByte, last[14])))
+	}
+	n, m, l := getReturnIndexes(last[14])
+	if changed != 0 {
+		if changed&16 != 0 {
+			context := int(m)
+			if context > 3 {
+				context = 3
+			}
+			c.lastIntensity[m] = uint16(c.intensity.decompress(d, int32(c.lastIntensity[m]), context))
+		}
+		binary.LittleEndian.PutUint16(last[12:14], c.lastIntensity[m])
+		if changed&8 != 0 {
+			last[15] = byte(d.decodeSymbol(getByteModel(&c.classification, last[15])))
+		}
+		if changed&4 != 0 {
+			last[16] = byte(d.decodeSymbol(c.scanAngleRank[last[14]>>6&1])) + last[16]
+		}
+		if changed&2 != 0 {
+			last[17] = byte(d.decodeSymbol(getByteModel(&c.userData, last[17])))
+		}
+		if changed&1 != 0 {
+			pointSourceId := c.pointSourceId.decompress(d, int32(binary.LittleEndian.Uint16(last[18:20])), 0)
+			binary.LittleEndian.PutUint16(last[18:20], uint16(pointSourceId))
+		}
+	}
+
+	single := 0
+	if n == 1 {
+		single = 1
+	}
+	diff := c.dx.decompress(d, c.lastXDiff[m].get(), single)
+	putInt32(last[0:4], getInt32(last[0:4])+diff)
+	c.lastXDiff[m].add(diff)
+
+	diff = c.dy.decompress(d, c.lastYDiff[m].get(), single+getBitsContext(c.dx.getK(), 20))
+	putInt32(last[4:8], getInt32(last[4:8])+diff)
+	c.lastYDiff[m].add(diff)
+
+	c.lastHeight[l] = c.z.decompress(d, c.lastHeight[l], single+getBitsContext((c.dx.getK()+c.dy.getK())/2, 18))
+	putInt32(last[8:12], c.lastHeight[l])
+
+	copy(item, last)
+}
+
+func (c *point10Coder) write(e *encoder, item []byte) {
+	last := c.last[:]
+	n, m, l := getReturnIndexes(item[14])
+	intensity := binary.LittleEndian.Uint16(item[12:14])
+	var changed uint32
+	for bit, different := range []bool{
+		!equalBytes(last[18:20], item[18:20]),
+		last[17] != item[17],
+		last[16] != item[16],
+		last[15] != item[15],
+		c.lastIntensity[m] != intensity,
+		last[14] != item[14],
+	} {
+		if different {
+			changed |= 1 << uint(bit)
+		}
+	}
+	e.encodeSymbol(c.changedValues, changed)
+	if changed&32 != 0 {
+		e.encodeSymbol(getByteModel(&c.bitByte, last[14]), uint32(item[14]))
+	}
+	if changed&16 != 0 {
+		context := int(m)
+		if context > 3 {
+			context = 3
+		}
+		c.intensity.compress(e, int32(c.lastIntensity[m]), int32(intensity), context)
+		c.lastIntensity[m] = intensity
+	}
+	if changed&8 != 0 {
+		e.encodeSymbol(getByteModel(&c.classification, last[15]), uint32(item[15]))
+	}
+	if changed&4 != 0 {
+		e.encodeSymbol(c.scanAngleRank[item[14]>>6&1], uint32(item[16]-last[16]))
+	}
+	if changed&2 != 0 {
+		e.encodeSymbol(getByteModel(&c.userData, last[17]), uint32(item[17]))
+	}
+	if changed&1 != 0 {
+		c.pointSourceId.compress(e, int32(binary.LittleEndian.Uint16(last[18:20])), int32(binary.LittleEndian.Uint16(item[18:20])), 0)
+	}
+
+	single := 0
+	if n == 1 {
+		single = 1
+	}
+	diff := getInt32(item[0:4]) - getInt32(last[0:4])
+	c.dx.compress(e, c.lastXDiff[m].get(), diff, single)
+	c.lastXDiff[m].add(diff)
+
+	diff = getInt32(item[4:8]) - getInt32(last[4:8])
+	c.dy.compress(e, c.lastYDiff[m].get(), diff, single+getBitsContext(c.dx.getK(), 20))
+	c.lastYDiff[m].add(diff)
+
+	z := getInt32(item[8:12])
+	c.z.compress(e, c.lastHeight[l], z, single+getBitsContext((c.dx.getK()+c.dy.getK())/2, 18))
+	c.lastHeight[l] = z
+
+	copy(last, item)
+}
+
+// Returns the context of a value coded after one whose correction had the given bit length, capped to the given max
+func getBitsContext(k uint32, max uint32) int {
+	if k < max {
+		return int(k &^ 1)
+	}
+	return int(max)
+}
+
+const (
+	gpsTimeMulti          = 500
+	gpsTimeMultiMinus     = -10
+	gpsTimeMultiUnchanged = gpsTimeMulti - gpsTimeMultiMinus + 1
+	gpsTimeMultiCodeFull  = gpsTimeMulti - gpsTimeMultiMinus + 2
+	gpsTimeMultiTotal     = gpsTimeMulti - gpsTimeMultiMinus + 6
+)
+
+// Codes the GPS time of the point data record formats 1, 3, 4 and 5, tracking up to four interleaved time sequences
+// and coding the bits of the doubles as integers
+type gpsTime11Coder struct {
+	last              int
+	next              int
+	lastGpsTime       [4]int64
+	lastGpsTimeDiff   [4]int32
+	multiExtremeCount [4]int32
+	gpsTimeMulti      *symbolModel
+	gpsTime0Diff      *symbolModel
+	gpsTimeCompressor *integerCompressor
+}
+
+func newGpsTime11Coder() *gpsTime11Coder {
+	return &gpsTime11Coder{
+		gpsTimeMulti:      newSymbolModel(gpsTimeMultiTotal),
+		gpsTime0Diff:      newSymbolModel(6),
+		gpsTimeCompressor: newIntegerCompressor(32, 9),
+	}
+}
+
+func (c *gpsTime11Coder) init(item []byte) {
+	c.lastGpsTime[0] = int64(binary.LittleEndian.Uint64(item))
+}
+
+func (c *gpsTime11Coder) read(d *decoder, item []byte) {
+	c.readSequence(d)
+	binary.LittleEndian.PutUint64(item, uint64(c.lastGpsTime[c.last]))
+}
+
+func (c *gpsTime11Coder) readSequence(d *decoder) {
+	if c.lastGpsTimeDiff[c.last] == 0 {
+		switch multi := d.decodeSymbol(c.gpsTime0Diff); {
+		case multi == 1:
+			c.lastGpsTimeDiff[c.last] = c.gpsTimeCompressor.decompress(d, 0, 0)
+			c.lastGpsTime[c.last] += int64(c.lastGpsTimeDiff[c.last])
+			c.multiExtremeCount[c.last] = 0
+		case multi == 2:
+			c.readFullGpsTime(d)
+		case multi > 2:
+			c.last = (c.last + int(multi) - 2) & 3
+			c.readSequence(d)
+		}
+		return
+	}
+
+	multi := int32(d.decodeSymbol(c.gpsTimeMulti))
+	switch {
+	case multi == 1:
+		c.lastGpsTime[c.last] += int64(c.gpsTimeCompressor.decompress(d, c.lastGpsTimeDiff[c.last], 1))
+		c.multiExtremeCount[c.last] = 0
+	case multi < gpsTimeMultiUnchanged:
+		var diff int32
+		lastDiff := c.lastGpsTimeDiff[c.last]
+		switch {
+		case multi == 0:
+			diff = c.gpsTimeCompressor.decompress(d, 0, 7)
+			c.countExtremeDiff(diff)
+		case multi < gpsTimeMulti:
+			context := 2
+			if multi >= 10 {
+				context = 3
+			}
+			diff = c.gpsTimeCompressor.decompress(d, multi*lastDiff, context)
+		case multi == gpsTimeMulti:
+			diff = c.gpsTimeCompressor.decompress(d, gpsTimeMulti*lastDiff, 4)
+			c.countExtremeDiff(diff)
+		default:
+			if multi = gpsTimeMulti - multi; multi > gpsTimeMultiMinus {
+				diff = c.gpsTimeCompressor.decompress(d, multi*lastDiff, 5)
+			} else {
+				diff = c.gpsTimeCompressor.decompress(d, gpsTimeMultiMinus*lastDiff, 6)
+				c.countExtremeDiff(diff)
+			}
+		}
+		c.lastGpsTime[c.last] += int64(diff)
+	case multi == gpsTimeMultiCodeFull:
+		c.readFullGpsTime(d)
+	case multi > gpsTimeMultiCodeFull:
+		c.last = (c.last + int(multi) - gpsTimeMultiCodeFull) & 3
+		c.readSequence(d)
+	}
+}
+
+// Starts a new sequence with a GPS time too far from the last one to be coded as a difference
+func (c *gpsTime11Coder) readFullGpsTime(d *decoder) {
+	c.next = (c.next + 1) & 3
+	high := c.gpsTimeCompressor.decompress(d, int32(uint64(c.lastGpsTime[c.last])>>32), 8)
+	c.lastGpsTime[c.next] = int64(uint64(uint32(high))<<32 | uint64(d.readInt()))
+	c.last = c.next
+	c.lastGpsTimeDiff[c.last] = 0
+	c.multiExtremeCount[c.last] = 0
+}
+
+// Counts a difference far from the expected multiple of the last one, taking it as the new reference difference
+// once they repeat
+func (c *gpsTime11Coder) countExtremeDiff(diff int32) {
+	c.multiExtremeCount[c.last]++
+	if c.multiExtremeCount[c.last] > 3 {
+		c.lastGpsTimeDiff[c.last] = diff
+		c.multiExtremeCount[c.last] = 0
+	}
+}
+
+func (c *gpsTime11Coder) write(e *encoder, item []byte) {
+	gpsTime := int64(binary.LittleEndian.Uint64(item))
+	if c.lastGpsTimeDiff[c.last] == 0 {
+		if gpsTime == c.lastGpsTime[c.last] {
+			e.encodeSymbol(c.gpsTime0Diff, 0)
+			return
+		}
+		if diff, ok := getGpsTimeDiff(gpsTime, c.lastGpsTime[c.last]); ok {
+			e.encodeSymbol(c.gpsTime0Diff, 1)
+			c.gpsTimeCompressor.compress(e, 0, diff, 0)
+			c.lastGpsTimeDiff[c.last] = diff
+			c.multiExtremeCount[c.last] = 0
+		} else if other := c.getOtherSequence(gpsTime); other > 0 {
+			e.encodeSymbol(c.gpsTime0Diff, uint32(other+2))
+			c.last = (c.last + other) & 3
+			c.write(e, item)
+			return
+		} else {
+			e.encodeSymbol(c.gpsTime0Diff, 2)
+			c.writeFullGpsTime(e, gpsTime)
+		}
+		c.lastGpsTime[c.last] = gpsTime
+		return
+	}
+
+	if gpsTime == c.lastGpsTime[c.last] {
+		e.encodeSymbol(c.gpsTimeMulti, gpsTimeMultiUnchanged)
+		return
+	}
+	diff, ok := getGpsTimeDiff(gpsTime, c.lastGpsTime[c.last])
+	if !ok {
+		if other := c.getOtherSequence(gpsTime); other > 0 {
+			e.encodeSymbol(c.gpsTimeMulti, uint32(gpsTimeMultiCodeFull+other))
+			c.last = (c.last + other) & 3
+			c.write(e, item)
+			return
+		}
+		e.encodeSymbol(c.gpsTimeMulti, gpsTimeMultiCodeFull)
+		c.writeFullGpsTime(e, gpsTime)
+		c.lastGpsTime[c.last] = gpsTime
+		return
+	}
+
+	lastDiff := c.lastGpsTimeDiff[c.last]
+	multi := quantize(float32(diff) / float32(lastDiff))
+	switch {
+	case multi == 1:
+		e.encodeSymbol(c.gpsTimeMulti, 1)
+		c.gpsTimeCompressor.compress(e, lastDiff, diff, 1)
+		c.multiExtremeCount[c.last] = 0
+	case multi > 0 && multi < gpsTimeMulti:
+		e.encodeSymbol(c.gpsTimeMulti, uint32(multi))
+		context := 2
+		if multi >= 10 {
+			context = 3
+		}
+		c.gpsTimeCompressor.compress(e, multi*lastDiff, diff, context)
+	case multi > 0:
+		e.encodeSymbol(c.gpsTimeMulti, gpsTimeMulti)
+		c.gpsTimeCompressor.compress(e, gpsTimeMulti*lastDiff, diff, 4)
+		c.countExtremeDiff(diff)
+	case multi < 0 && multi > gpsTimeMultiMinus:
+		e.encodeSymbol(c.gpsTimeMulti, uint32(gpsTimeMulti-multi))
+		c.gpsTimeCompressor.compress(e, multi*lastDiff, diff, 5)
+	case multi < 0:
+		e.encodeSymbol(c.gpsTimeMulti, gpsTimeMulti-gpsTimeMultiMinus)
+		c.gpsTimeCompressor.compress(e, gpsTimeMultiMinus*lastDiff, diff, 6)
+		c.countExtremeDiff(diff)
+	default:
+		e.encodeSymbol(c.gpsTimeMulti, 0)
+		c.gpsTimeCompressor.compress(e, 0, diff, 7)
+		c.countExtremeDiff(diff)
+	}
+	c.lastGpsTime[c.last] = gpsTime
+}
+
+func (c *gpsTime11Coder) writeFullGpsTime(e *encoder, gpsTime int64) {
+	c.gpsTimeCompressor.compress(e, int32(uint64(c.lastGpsTime[c.last])>>32), int32(uint64(gpsTime)>>32), 8)
+	e.writeInt(uint32(gpsTime))
+	c.next = (c.next + 1) & 3
+	c.last = c.next
+	c.lastGpsTimeDiff[c.last] = 0
+	c.multiExtremeCount[c.last] = 0
+}
+
+// Returns the offset, from 1 to 3, of the other sequence the given GPS time can be coded as a difference in, 0 if none
+func (c *gpsTime11Coder) getOtherSequence(gpsTime int64) int {
+	for i := 1; i < 4; i++ {
+		if _, ok := getGpsTimeDiff(gpsTime, c.lastGpsTime[(c.last+i)&3]); ok {
+			return i
+		}
+	}
+	return 0
+}
+
+// Returns the difference of the integers sharing the bits of the given GPS times, false if it exceeds 32 bits
+func getGpsTimeDiff(gpsTime int64, lastGpsTime int64) (int32, bool) {
+	diff := gpsTime - lastGpsTime
+	return int32(diff), diff == int64(int32(diff))
+}
+
+func quantize(value float32) int32 {
+	if value >= 0 {
+		return int32(value + 0.5)
+	}
+	return int32(value - 0.5)
+}
+
+// Codes the colors of the point data record formats 2, 3 and 5, predicting the green and blue channels from the
+// change of the red one
+type rgb12Coder struct {
+	last     [3]uint16
+	byteUsed *symbolModel
+	rgbDiff  [6]*symbolModel
+}
+
+func newRgb12Coder() *rgb12Coder {
+	c := &rgb12Coder{byteUsed: newSymbolModel(128)}
+	for i := range c.rgbDiff {
+		c.rgbDiff[i] = newSymbolModel(256)
+	}
+	return c
+}
+
+func (c *rgb12Coder) init(item []byte) {
+	for i := range c.last {
+		c.last[i] = binary.LittleEndian.Uint16(item[2*i:])
+	}
+}
+
+func (c *rgb12Coder) read(d *decoder, item []byte) {
+	last := &c.last
+	var rgb [3]uint16
+	used := d.decodeSymbol(c.byteUsed)
+	// every channel is made of a low byte and a high byte, each one coded only if changed
+	decodeByte := func(bit uint, model int, prediction int32, lastByte uint16) uint16 {
+		if used&(1<<bit) == 0 {
+			return lastByte
+		}
+		return uint16(byte(d.decodeSymbol(c.rgbDiff[model])) + byte(prediction))
+	}
+	rgb[0] = decodeByte(0, 0, int32(last[0]&0xFF), last[0]&0xFF)
+	rgb[0] |= decodeByte(1, 1, int32(last[0]>>8), last[0]>>8) << 8
+	if used&(1<<6) != 0 {
+		diff := int32(rgb[0]&0xFF) - int32(last[0]&0xFF)
+		rgb[1] = decodeByte(2, 2, clampByte(diff+int32(last[1]&0xFF)), last[1]&0xFF)
+		diff = (diff + int32(rgb[1]&0xFF) - int32(last[1]&0xFF)) / 2
+		rgb[2] = decodeByte(4, 4, clampByte(diff+int32(last[2]&0xFF)), last[2]&0xFF)
+		diff = int32(rgb[0]>>8) - int32(last[0]>>8)
+		rgb[1] |= decodeByte(3, 3, clampByte(diff+int32(last[1]>>8)), last[1]>>8) << 8
+		diff = (diff + int32(rgb[1]>>8) - int32(last[1]>>8)) / 2
+		rgb[2] |= decodeByte(5, 5, clampByte(diff+int32(last[2]>>8)), last[2]>>8) << 8
+	} else {
+		rgb[1], rgb[2] = rgb[0], rgb[0]
+	}
+	for i := range rgb {
+		binary.LittleEndian.PutUint16(item[2*i:], rgb[i])
+	}
+	c.last = rgb
+}
+
+func (c *rgb12Coder) write(e *encoder, item []byte) {
+	last := &c.last
+	var rgb [3]uint16
+	for i := range rgb {
+		rgb[i] = binary.LittleEndian.Uint16(item[2*i:])
+	}
+	var used uint32
+	for i, different := range []bool{
+		last[0]&0xFF != rgb[0]&0xFF,
+		last[0]&0xFF00 != rgb[0]&0xFF00,
+		last[1]&0xFF != rgb[1]&0xFF,
+		last[1]&0xFF00 != rgb[1]&0xFF00,
+		last[2]&0xFF != rgb[2]&0xFF,
+		last[2]&0xFF00 != rgb[2]&0xFF00,
+		rgb[0] != rgb[1] || rgb[0] != rgb[2],
+	} {
+		if different {
+			used |= 1 << uint(i)
+		}
+	}
+	e.encodeSymbol(c.byteUsed, used)
+	encodeByte := func(bit uint, model int, value uint16, prediction int32) {
+		if used&(1<<bit) != 0 {
+			e.encodeSymbol(c.rgbDiff[model], uint32(byte(int32(value)-prediction)))
+		}
+	}
+	var diffLow, diffHigh int32
+	if used&1 != 0 {
+		diffLow = int32(rgb[0]&0xFF) - int32(last[0]&0xFF)
+	}
+	if used&2 != 0 {
+		diffHigh = int32(rgb[0]>>8) - int32(last[0]>>8)
+	}
+	encodeByte(0, 0, rgb[0]&0xFF, int32(last[0]&0xFF))
+	encodeByte(1, 1, rgb[0]>>8, int32(last[0]>>8))
+	if used&(1<<6) != 0 {
+		encodeByte(2, 2, rgb[1]&0xFF, clampByte(diffLow+int32(last[1]&0xFF)))
+		diffLow = (diffLow + int32(rgb[1]&0xFF) - int32(last[1]&0xFF)) / 2
+		encodeByte(4, 4, rgb[2]&0xFF, clampByte(diffLow+int32(last[2]&0xFF)))
+		encodeByte(3, 3, rgb[1]>>8, clampByte(diffHigh+int32(last[1]>>8)))
+		diffHigh = (diffHigh + int32(rgb[1]>>8) - int32(last[1]>>8)) / 2
+		encodeByte(5, 5, rgb[2]>>8, clampByte(diffHigh+int32(last[2]>>8)))
+	}
+	c.last = rgb
+}
+
+func clampByte(value int32) int32 {
+	if value < 0 {
+		return 0
+	}
+	if value > math.MaxUint8 {
+		return math.MaxUint8
+	}
+	return value
+}
+
+// Codes the extra bytes of the point records, every byte as a difference from the same byte of the last point
+type byteCoder struct {
+	last   []byte
+	models []*symbolModel
+}
+
+func newByteCoder(size int) *byteCoder {
+	c := &byteCoder{last: make([]byte, size), models: make([]*symbolModel, size)}
+	for i := range c.models {
+		c.models[i] = newSymbolModel(256)
+	}
+	return c
+}
+
+func (c *byteCoder) init(item []byte) {
+	copy(c.last, item)
+}
+
+func (c *byteCoder) read(d *decoder, item []byte) {
+	for i, model := range c.models {
+		c.last[i] += byte(d.decodeSymbol(model))
+	}
+	copy(item, c.last)
+}
+
+func (c *byteCoder) write(e *encoder, item []byte) {
+	for i, model := range c.models {
+		e.encodeSymbol(model, uint32(item[i]-c.last[i]))
+	}
+	copy(c.last, item)
+}
+
+func getInt32(data []byte) int32 {
+	return int32(binary.LittleEndian.Uint32(data))
+}
+
+func putInt32(data []byte, value int32) {
+	binary.LittleEndian.PutUint32(data, uint32(value))
+}
+
+func equalBytes(a []byte, b []byte) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
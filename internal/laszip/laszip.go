@@ -0,0 +1,311 @@
+package laszip
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// Max bytes of the chunk table read per chunk, the compressed sizes taking at most 9 bytes each
+const maxChunkTableEntrySize = 20
+
+// Position and number of points of a chunk
+type chunk struct {
+	start  int64
+	end    int64
+	points int
+	// index of the first point of the chunk
+	first int
+}
+
+// Decompresses the given number of point records of the given length of a LAZ file, whose compressed points start at
+// the given offset, returning them as stored in a LAS file. The chunks are decompressed in parallel by the given
+// number of goroutines, one per CPU if not positive, until the given cancellation token, if any, is cancelled.
+func Decompress(file io.ReaderAt, offset int64, numberOfPoints int, recordLength int, vlr *Vlr, workers int, token *cancellation.Token) ([]byte, error) {
+	records := make([]byte, 0)
+	// all the chunks are decompressed in a single batch, whose records are not reused
+	err := DecompressBatches(file, offset, numberOfPoints, recordLength, vlr, workers, 0, token, func(first int, batch []byte) error {
+		records = batch
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Decompresses the point records like Decompress, passing them in order to the given function in batches of
+// consecutive chunks, together with the index of the first point of the batch. Every batch holds the chunks needed to
+// reach the given number of points, all the points if not positive, so that only the records of one batch are held in
+// memory at a time. The records of a batch are overwritten by the next one once the function returns, and an error
+// returned by the function stops the decompression.
+func DecompressBatches(file io.ReaderAt, offset int64, numberOfPoints int, recordLength int, vlr *Vlr, workers int, batchSize int, token *cancellation.Token, consume func(first int, records []byte) error) error {
+	return decompressBatches(file, offset, numberOfPoints, recordLength, vlr, workers, batchSize, token, consume, nil)
+}
+
+// Decompresses the point records like DecompressBatches, skipping the chunks that cannot be decompressed rather than
+// failing: the index of the first point and the number of points of every skipped chunk are passed to the given
+// function along with the error, and the decompression resumes at the next chunk, found through the chunk table. The
+// records of the chunks around the skipped ones are passed in separate calls of the consume function.
+func DecompressBatchesSkipping(file io.ReaderAt, offset int64, numberOfPoints int, recordLength int, vlr *Vlr, workers int, batchSize int, token *cancellation.Token, consume func(first int, records []byte) error, skip func(first int, points int, err error)) error {
+	return decompressBatches(file, offset, numberOfPoints, recordLength, vlr, workers, batchSize, token, consume, skip)
+}
+
+func decompressBatches(file io.ReaderAt, offset int64, numberOfPoints int, recordLength int, vlr *Vlr, workers int, batchSize int, token *cancellation.Token, consume func(first int, records []byte) error, skip func(first int, points int, err error)) error {
+	if err := vlr.validate(recordLength); err != nil {
+		return err
+	}
+	if numberOfPoints == 0 {
+		return nil
+	}
+	chunks, err := readChunks(file, offset, numberOfPoints, vlr)
+	if err != nil {
+		return err
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var records []byte
+	for start := 0; start < len(chunks); {
+		end := start + 1
+		for end < len(chunks) && (batchSize <= 0 || chunks[end].first-chunks[start].first < batchSize) {
+			end++
+		}
+		length := (chunks[end-1].first + chunks[end-1].points - chunks[start].first) * recordLength
+		if cap(records) < length {
+			records = make([]byte, length)
+		}
+		records = records[:length]
+		errs, err := decompressBatch(file, chunks, start, end, records, recordLength, vlr, workers, token)
+		if err != nil {
+			return err
+		}
+		if err := token.Err(); err != nil {
+			return err
+		}
+		// the runs of consecutive chunks decompressed are consumed between the skipped ones
+		run := start
+		for i := start; i <= end; i++ {
+			if i < end && errs[i-start] == nil {
+				continue
+			}
+			if i < end && skip == nil {
+				return errs[i-start]
+			}
+			if i > run {
+				runRecords := records[(chunks[run].first-chunks[start].first)*recordLength : (chunks[i-1].first+chunks[i-1].points-chunks[start].first)*recordLength]
+				if err := consume(chunks[run].first, runRecords); err != nil {
+					return err
+				}
+			}
+			if i < end {
+				skip(chunks[i].first, chunks[i].points, errs[i-start])
+			}
+			run = i + 1
+		}
+		start = end
+	}
+	return nil
+}
+
+// Decompresses the chunks from the given start index to the given end index, excluded, in the given records with the
+// given number of goroutines, returning the error of every chunk that cannot be decompressed, nil for the other ones
+func decompressBatch(file io.ReaderAt, chunks []chunk, start int, end int, records []byte, recordLength int, vlr *Vlr, workers int, token *cancellation.Token) ([]error, error) {
+	base, first := chunks[start].start, chunks[start].first
+	data := make([]byte, chunks[end-1].end-base)
+	if _, err := file.ReadAt(data, base); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	indexes := make(chan int, end-start)
+	for i := start; i < end; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	errs := make([]error, end-start)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				if token.IsCancelled() {
+					return
+				}
+				c := chunks[index]
+				chunkRecords := records[(c.first-first)*recordLength : (c.first-first+c.points)*recordLength]
+				if err := decompressChunk(data[c.start-base:c.end-base], chunkRecords, recordLength, vlr); err != nil {
+					errs[index-start] = errors.New("LAZ chunk " + strconv.Itoa(index) + " cannot be decompressed: " + err.Error())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errs, nil
+}
+
+// Returns the chunks the points are stored in, read from the chunk table
+func readChunks(file io.ReaderAt, offset int64, numberOfPoints int, vlr *Vlr) ([]chunk, error) {
+	if vlr.ChunkSize == 0 {
+		return nil, errors.New("the LAZ chunk size is zero")
+	}
+	buffer := make([]byte, 8)
+	if _, err := file.ReadAt(buffer, offset); err != nil {
+		return nil, errors.New("cannot read the position of the LAZ chunk table: " + err.Error())
+	}
+	tableStart := int64(binary.LittleEndian.Uint64(buffer))
+	chunksStart := offset + 8
+	if tableStart == -1 {
+		// written at the end of the file by the compressors writing to streams that cannot be sought
+		seeker, ok := file.(io.Seeker)
+		if !ok {
+			return nil, errors.New("the position of the LAZ chunk table is stored at the end of the file, which cannot be sought")
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.ReadAt(buffer, end-8); err != nil {
+			return nil, errors.New("cannot read the position of the LAZ chunk table: " + err.Error())
+		}
+		tableStart = int64(binary.LittleEndian.Uint64(buffer))
+	}
+	if tableStart <= chunksStart {
+		return nil, errors.New("the LAZ file has no chunk table")
+	}
+
+	if _, err := file.ReadAt(buffer, tableStart); err != nil {
+		return nil, errors.New("cannot read the LAZ chunk table: " + err.Error())
+	}
+	if version := binary.LittleEndian.Uint32(buffer[0:4]); version != 0 {
+		return nil, errors.New("LAZ chunk table version " + strconv.Itoa(int(version)) + " is not supported")
+	}
+	numberOfChunks := int(binary.LittleEndian.Uint32(buffer[4:8]))
+	if numberOfChunks == 0 || numberOfChunks > numberOfPoints {
+		return nil, errors.New("the LAZ chunk table lists " + strconv.Itoa(numberOfChunks) + " chunks for " + strconv.Itoa(numberOfPoints) + " points")
+	}
+	table := make([]byte, numberOfChunks*maxChunkTableEntrySize)
+	n, err := file.ReadAt(table, tableStart+8)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	d := newDecoder(table[:n])
+	compressor := newIntegerCompressor(32, 2)
+	chunks := make([]chunk, numberOfChunks)
+	var lastPoints, lastBytes int32
+	start, first := chunksStart, 0
+	for i := range chunks {
+		points := int(vlr.ChunkSize)
+		if vlr.ChunkSize == variableChunkSize {
+			lastPoints = compressor.decompress(d, lastPoints, 0)
+			points = int(uint32(lastPoints))
+		} else if remaining := numberOfPoints - first; remaining < points {
+			points = remaining
+		}
+		lastBytes = compressor.decompress(d, lastBytes, 1)
+		chunks[i] = chunk{start: start, end: start + int64(uint32(lastBytes)), points: points, first: first}
+		start, first = chunks[i].end, first+points
+	}
+	if d.overrun() {
+		return nil, errors.New("the LAZ chunk table is truncated")
+	}
+	if first != numberOfPoints || start > tableStart {
+		return nil, errors.New("the LAZ chunk table does not match the " + strconv.Itoa(numberOfPoints) + " points of the file")
+	}
+	return chunks, nil
+}
+
+// Decompresses the given chunk in the given records
+func decompressChunk(data []byte, records []byte, recordLength int, vlr *Vlr) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if len(data) < recordLength {
+		return errors.New("the chunk is truncated")
+	}
+	// the first point is stored raw
+	copy(records[:recordLength], data)
+	coders, offsets := vlr.newCoders()
+	for i, coder := range coders {
+		coder.init(records[offsets[i]:offsets[i+1]])
+	}
+
+	d := newDecoder(data[recordLength:])
+	for start := recordLength; start < len(records); start += recordLength {
+		record := records[start : start+recordLength]
+		for i, coder := range coders {
+			coder.read(d, record[offsets[i]:offsets[i+1]])
+		}
+	}
+	if d.overrun() {
+		return errors.New("the chunk is truncated")
+	}
+	return nil
+}
+
+// Compresses the given point records as stored in a LAZ file whose points start at the given offset, with the chunk
+// table following the chunks
+func Compress(records []byte, offset int64, vlr *Vlr) ([]byte, error) {
+	recordLength := vlr.getRecordLength()
+	if err := vlr.validate(recordLength); err != nil {
+		return nil, err
+	}
+	if vlr.ChunkSize == 0 || vlr.ChunkSize == variableChunkSize {
+		return nil, errors.New("LAZ compression requires a fixed chunk size")
+	}
+	if len(records)%recordLength != 0 {
+		return nil, errors.New("the records are not a multiple of the point record length " + strconv.Itoa(recordLength))
+	}
+
+	data := make([]byte, 8)
+	var chunkBytes []int32
+	chunkLength := int(vlr.ChunkSize) * recordLength
+	for start := 0; start < len(records); start += chunkLength {
+		end := start + chunkLength
+		if end > len(records) {
+			end = len(records)
+		}
+		compressed := compressChunk(records[start:end], recordLength, vlr)
+		data = append(data, compressed...)
+		chunkBytes = append(chunkBytes, int32(len(compressed)))
+	}
+	binary.LittleEndian.PutUint64(data[0:8], uint64(offset+int64(len(data))))
+
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(chunkBytes)))
+	data = append(data, header...)
+	if len(chunkBytes) > 0 {
+		e := newEncoder()
+		compressor := newIntegerCompressor(32, 2)
+		var last int32
+		for _, bytes := range chunkBytes {
+			compressor.compress(e, last, bytes, 1)
+			last = bytes
+		}
+		data = append(data, e.done()...)
+	}
+	return data, nil
+}
+
+func compressChunk(records []byte, recordLength int, vlr *Vlr) []byte {
+	data := append([]byte{}, records[:recordLength]...)
+	coders, offsets := vlr.newCoders()
+	for i, coder := range coders {
+		coder.init(records[offsets[i]:offsets[i+1]])
+	}
+
+	e := newEncoder()
+	for start := recordLength; start < len(records); start += recordLength {
+		record := records[start : start+recordLength]
+		for i, coder := range coders {
+			coder.write(e, record[offsets[i]:offsets[i+1]])
+		}
+	}
+	return append(data, e.done()...)
+}
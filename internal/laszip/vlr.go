@@ -0,0 +1,182 @@
+package laszip
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// User id and record id of the VLR describing how the points of a LAZ file are compressed
+const (
+	VlrUserId   = "laszip encoded"
+	VlrRecordId = 22204
+)
+
+// Compressor storing the points in chunks coded independently, the only one written by LASzip since version 2
+const compressorPointwiseChunked = 2
+
+// Chunk size marking files whose chunks have variable point counts, stored in the chunk table
+const variableChunkSize = math.MaxUint32
+
+// Number of points per chunk written by LASzip by default
+const DefaultChunkSize = 50000
+
+// Types of the items the point records are made of
+const (
+	itemByte      = 0
+	itemPoint10   = 6
+	itemGpsTime11 = 7
+	itemRgb12     = 8
+)
+
+// Version of the item compressors supported
+const itemVersion = 2
+
+// A field group of the point records, coded by its own compressor
+type Item struct {
+	Type    uint16
+	Size    uint16
+	Version uint16
+}
+
+// Content of the LASzip VLR
+type Vlr struct {
+	Compressor   uint16
+	Coder        uint16
+	VersionMajor uint8
+	VersionMinor uint8
+	Revision     uint16
+	Options      uint32
+	ChunkSize    uint32
+	Items        []Item
+}
+
+// Decodes the LASzip VLR from the given data
+func ParseVlr(data []byte) (*Vlr, error) {
+	if len(data) < 34 {
+		return nil, errors.New("the LASzip VLR is too short")
+	}
+	vlr := &Vlr{
+		Compressor:   binary.LittleEndian.Uint16(data[0:2]),
+		Coder:        binary.LittleEndian.Uint16(data[2:4]),
+		VersionMajor: data[4],
+		VersionMinor: data[5],
+		Revision:     binary.LittleEndian.Uint16(data[6:8]),
+		Options:      binary.LittleEndian.Uint32(data[8:12]),
+		ChunkSize:    binary.LittleEndian.Uint32(data[12:16]),
+	}
+	numberOfItems := int(binary.LittleEndian.Uint16(data[32:34]))
+	if len(data) < 34+6*numberOfItems {
+		return nil, errors.New("the LASzip VLR is too short for its " + strconv.Itoa(numberOfItems) + " items")
+	}
+	for i := 0; i < numberOfItems; i++ {
+		item := data[34+6*i:]
+		vlr.Items = append(vlr.Items, Item{
+			Type:    binary.LittleEndian.Uint16(item[0:2]),
+			Size:    binary.LittleEndian.Uint16(item[2:4]),
+			Version: binary.LittleEndian.Uint16(item[4:6]),
+		})
+	}
+	return vlr, nil
+}
+
+// Builds the VLR of the compression of the records of the given point data record format and length with the given
+// number of points per chunk
+func NewVlr(formatId byte, recordLength int, chunkSize uint32) (*Vlr, error) {
+	if formatId > 3 {
+		return nil, errors.New("LAZ compression of the point data record format " + strconv.Itoa(int(formatId)) + " is not supported")
+	}
+	items := []Item{{Type: itemPoint10, Size: 20, Version: itemVersion}}
+	if formatId == 1 || formatId == 3 {
+		items = append(items, Item{Type: itemGpsTime11, Size: 8, Version: itemVersion})
+	}
+	if formatId == 2 || formatId == 3 {
+		items = append(items, Item{Type: itemRgb12, Size: 6, Version: itemVersion})
+	}
+	size := 0
+	for _, item := range items {
+		size += int(item.Size)
+	}
+	if recordLength < size {
+		return nil, errors.New("point record length " + strconv.Itoa(recordLength) + " too short for LAZ compression")
+	}
+	if recordLength > size {
+		items = append(items, Item{Type: itemByte, Size: uint16(recordLength - size), Version: itemVersion})
+	}
+	return &Vlr{Compressor: compressorPointwiseChunked, VersionMajor: 2, VersionMinor: 2, ChunkSize: chunkSize, Items: items}, nil
+}
+
+// Encodes the VLR as stored in the LAZ files
+func (v *Vlr) Bytes() []byte {
+	data := make([]byte, 34+6*len(v.Items))
+	binary.LittleEndian.PutUint16(data[0:2], v.Compressor)
+	binary.LittleEndian.PutUint16(data[2:4], v.Coder)
+	data[4] = v.VersionMajor
+	data[5] = v.VersionMinor
+	binary.LittleEndian.PutUint16(data[6:8], v.Revision)
+	binary.LittleEndian.PutUint32(data[8:12], v.Options)
+	binary.LittleEndian.PutUint32(data[12:16], v.ChunkSize)
+	// no special EVLRs
+	binary.LittleEndian.PutUint64(data[16:24], math.MaxUint64)
+	binary.LittleEndian.PutUint64(data[24:32], math.MaxUint64)
+	binary.LittleEndian.PutUint16(data[32:34], uint16(len(v.Items)))
+	for i, item := range v.Items {
+		binary.LittleEndian.PutUint16(data[34+6*i:], item.Type)
+		binary.LittleEndian.PutUint16(data[36+6*i:], item.Size)
+		binary.LittleEndian.PutUint16(data[38+6*i:], item.Version)
+	}
+	return data
+}
+
+// Returns the bytes of the point records described by the items
+func (v *Vlr) getRecordLength() int {
+	length := 0
+	for _, item := range v.Items {
+		length += int(item.Size)
+	}
+	return length
+}
+
+// Checks that the points can be decompressed, i.e. that they are stored in chunks by supported item compressors
+func (v *Vlr) validate(recordLength int) error {
+	if v.Compressor != compressorPointwiseChunked {
+		return errors.New("LAZ compressor " + strconv.Itoa(int(v.Compressor)) + " is not supported, only the pointwise chunked one of the point data record formats 0 to 3 is")
+	}
+	if v.Coder != 0 {
+		return errors.New("LAZ coder " + strconv.Itoa(int(v.Coder)) + " is not supported")
+	}
+	for _, item := range v.Items {
+		switch {
+		case item.Version != itemVersion:
+			return errors.New("LAZ item version " + strconv.Itoa(int(item.Version)) + " is not supported")
+		case item.Type == itemPoint10 && item.Size == 20, item.Type == itemGpsTime11 && item.Size == 8, item.Type == itemRgb12 && item.Size == 6, item.Type == itemByte && item.Size > 0:
+		default:
+			return errors.New("LAZ item type " + strconv.Itoa(int(item.Type)) + " of size " + strconv.Itoa(int(item.Size)) + " is not supported")
+		}
+	}
+	if v.getRecordLength() != recordLength {
+		return errors.New("the LAZ items do not match the point record length " + strconv.Itoa(recordLength))
+	}
+	return nil
+}
+
+// Returns the coders of the items of a chunk, along with the offsets of the items in the records
+func (v *Vlr) newCoders() ([]itemCoder, []int) {
+	coders := make([]itemCoder, len(v.Items))
+	offsets := make([]int, len(v.Items)+1)
+	for i, item := range v.Items {
+		switch item.Type {
+		case itemPoint10:
+			coders[i] = newPoint10Coder()
+		case itemGpsTime11:
+			coders[i] = newGpsTime11Coder()
+		case itemRgb12:
+			coders[i] = newRgb12Coder()
+		default:
+			coders[i] = newByteCoder(int(item.Size))
+		}
+		offsets[i+1] = offsets[i] + int(item.Size)
+	}
+	return coders, offsets
+}
@@ -0,0 +1,119 @@
+package ledger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Name of the ledger file written in the output folder
+const FileName = "ledger.json"
+
+// An input file whose tileset has been written in the output folder
+type Entry struct {
+	Hash      string    `json:"hash"`
+	File      string    `json:"file"`
+	Tileset   string    `json:"tileset"`
+	Processed time.Time `json:"processed"`
+}
+
+// An input file skipped because its content matches the one of an already processed file
+type Duplicate struct {
+	Hash        string `json:"hash"`
+	File        string `json:"file"`
+	DuplicateOf string `json:"duplicateOf"`
+	Tileset     string `json:"tileset"`
+}
+
+// Record of the input files processed in an output folder, identified by the SHA-256 hash of their content. It
+// survives across runs so that inputs already tiled, even under a different name, are not processed again. The
+// skipped duplicates are reported for the last run only.
+type Ledger struct {
+	Entries []*Entry     `json:"entries"`
+	Skipped []*Duplicate `json:"skipped"`
+	byHash  map[string]*Entry
+}
+
+// Loads the ledger stored at the given path, returning an empty ledger if the file does not exist
+func Load(storage storage.Storage, filePath string) (*Ledger, error) {
+	ledger := &Ledger{Entries: []*Entry{}, Skipped: []*Duplicate{}, byHash: make(map[string]*Entry)}
+	file, err := storage.Open(filePath)
+	if os.IsNotExist(err) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, ledger); err != nil {
+		return nil, err
+	}
+
+	// duplicates are reported for the current run only
+	ledger.Skipped = []*Duplicate{}
+	for _, entry := range ledger.Entries {
+		ledger.byHash[entry.Hash] = entry
+	}
+	return ledger, nil
+}
+
+// Returns the hex encoded SHA-256 hash of the content of the given file
+func HashFile(storage storage.Storage, filePath string) (string, error) {
+	file, err := storage.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Returns the entry of the processed file with the given content hash, if any
+func (l *Ledger) Lookup(hash string) (*Entry, bool) {
+	entry, ok := l.byHash[hash]
+	return entry, ok
+}
+
+// Records that the file with the given content hash has been written as the given tileset
+func (l *Ledger) Record(hash string, file string, tileset string) {
+	entry := &Entry{Hash: hash, File: file, Tileset: tileset, Processed: time.Now().UTC()}
+	if previous, ok := l.byHash[hash]; ok {
+		*previous = *entry
+		return
+	}
+	l.Entries = append(l.Entries, entry)
+	l.byHash[hash] = entry
+}
+
+// Records that the given file has been skipped being a duplicate of the given processed one
+func (l *Ledger) Skip(file string, original *Entry) {
+	l.Skipped = append(l.Skipped, &Duplicate{
+		Hash:        original.Hash,
+		File:        file,
+		DuplicateOf: original.File,
+		Tileset:     original.Tileset,
+	})
+}
+
+// Writes the ledger at the given path
+func (l *Ledger) Save(storage storage.Storage, filePath string) error {
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(filePath, content, 0666)
+}
@@ -0,0 +1,201 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Retention of the points of a level of the tree decided by the size budget
+type LevelAllocation struct {
+	// Depth of the level, 0 for the root
+	Depth int
+	// Number of tiles of the level holding points
+	Tiles int
+	// Number of points of the level in the built tree
+	Points int64
+	// Number of points of the level kept
+	Retained int64
+	// Fraction of the points of the level to keep allocated by the budget
+	Fraction float64
+	// Estimated size in bytes of the tile contents of the level
+	Bytes int64
+}
+
+// Decorates a tree thinning the points of its levels so that the estimated size of the tile contents fits in the given
+// budget, estimated from the size of every point and a fixed overhead per tile. The budget left by the overhead of the
+// tiles is shared between the levels max-min fairly: the levels fitting in an equal share are kept whole and what they
+// leave is shared between the other ones, which are thinned to the same size. The coarse levels, holding few points,
+// are hence kept whole while the most populated ones are decimated. Every tile keeps at least one of its points, so that the
+// hierarchy of tiles is left intact. The tree is thinned the first time its root is requested once built.
+type BudgetedTree struct {
+	ITree
+	budget      int64
+	pointSize   int64
+	tileSize    int64
+	root        INode
+	allocations []LevelAllocation
+	once        sync.Once
+}
+
+// Wraps the given tree so that its built root node is thinned to fit in the given budget in bytes, given the estimated
+// size in bytes of every point and of the overhead of every tile
+func NewBudgetedTree(tree ITree, budget int64, pointSize int64, tileSize int64) *BudgetedTree {
+	return &BudgetedTree{
+		ITree:     tree,
+		budget:    budget,
+		pointSize: pointSize,
+		tileSize:  tileSize,
+	}
+}
+
+func (t *BudgetedTree) GetRootNode() INode {
+	root := t.ITree.GetRootNode()
+	if root == nil || !t.ITree.IsBuilt() {
+		return root
+	}
+	t.once.Do(func() {
+		t.thin(root)
+	})
+	return t.root
+}
+
+// Returns the retention of the points of every level of the built tree, starting from the root
+func (t *BudgetedTree) GetAllocations() []LevelAllocation {
+	t.GetRootNode()
+	return t.allocations
+}
+
+// Returns the budget in bytes the tree is thinned to fit in
+func (t *BudgetedTree) GetBudget() int64 {
+	return t.budget
+}
+
+func (t *BudgetedTree) thin(root INode) {
+	var levels [][]INode
+	for level := []INode{root}; len(level) > 0; {
+		levels = append(levels, level)
+		var next []INode
+		for _, node := range level {
+			for _, child := range node.GetChildren() {
+				if child != nil && child.TotalNumberOfPoints() > 0 {
+					next = append(next, child)
+				}
+			}
+		}
+		level = next
+	}
+
+	t.allocations = make([]LevelAllocation, len(levels))
+	available := t.budget
+	for depth, level := range levels {
+		allocation := &t.allocations[depth]
+		allocation.Depth = depth
+		for _, node := range level {
+			if node.NumberOfPoints() > 0 {
+				allocation.Tiles++
+			}
+			allocation.Points += node.NumberOfPoints()
+		}
+		available -= int64(allocation.Tiles) * t.tileSize
+	}
+	t.allocateFractions(available)
+
+	random := rand.New(rand.NewSource(1))
+	t.root = newBudgetedNode(root, nil, 0, t.allocations, random)
+	for depth := range t.allocations {
+		allocation := &t.allocations[depth]
+		allocation.Bytes = int64(allocation.Tiles)*t.tileSize + allocation.Retained*t.pointSize
+	}
+}
+
+// Shares the given number of bytes between the points of the levels max-min fairly
+func (t *BudgetedTree) allocateFractions(available int64) {
+	order := make([]int, len(t.allocations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return t.allocations[order[i]].Points < t.allocations[order[j]].Points
+	})
+
+	remaining := math.Max(float64(available), 0)
+	for i, depth := range order {
+		allocation := &t.allocations[depth]
+		size := float64(allocation.Points * t.pointSize)
+		share := remaining / float64(len(order)-i)
+		if size <= share {
+			allocation.Fraction = 1
+			remaining -= size
+		} else {
+			allocation.Fraction = share / size
+			remaining -= share
+		}
+	}
+}
+
+// A node keeping the fraction of its points allocated to its level
+type budgetedNode struct {
+	INode
+	parent   INode
+	children [8]INode
+	points   []*data.Point
+	total    int64
+}
+
+func newBudgetedNode(node INode, parent INode, depth int, allocations []LevelAllocation, random *rand.Rand) *budgetedNode {
+	budgeted := &budgetedNode{
+		INode:  node,
+		parent: parent,
+		points: samplePoints(node.GetPoints(), allocations[depth].Fraction, random),
+	}
+	allocations[depth].Retained += int64(len(budgeted.points))
+	budgeted.total = int64(len(budgeted.points))
+	for i, child := range node.GetChildren() {
+		if child != nil && child.TotalNumberOfPoints() > 0 {
+			budgeted.children[i] = newBudgetedNode(child, budgeted, depth+1, allocations, random)
+			budgeted.total += budgeted.children[i].TotalNumberOfPoints()
+		}
+	}
+	return budgeted
+}
+
+// Returns a uniform random sample of the given fraction of the points, of at least one point if there are any
+func samplePoints(points []*data.Point, fraction float64, random *rand.Rand) []*data.Point {
+	count := int(fraction * float64(len(points)))
+	if count >= len(points) {
+		return points
+	}
+	if count < 1 {
+		count = 1
+	}
+	sample := make([]*data.Point, len(points))
+	copy(sample, points)
+	for i := 0; i < count; i++ {
+		j := i + random.Intn(len(sample)-i)
+		sample[i], sample[j] = sample[j], sample[i]
+	}
+	return sample[:count]
+}
+
+func (n *budgetedNode) GetParent() INode {
+	return n.parent
+}
+
+func (n *budgetedNode) GetChildren() [8]INode {
+	return n.children
+}
+
+func (n *budgetedNode) GetPoints() []*data.Point {
+	return n.points
+}
+
+func (n *budgetedNode) NumberOfPoints() int64 {
+	return int64(len(n.points))
+}
+
+func (n *budgetedNode) TotalNumberOfPoints() int64 {
+	return n.total
+}
@@ -0,0 +1,189 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"sync"
+)
+
+// Decorates a tree bundling the sibling leaf tiles whose estimated content size does not exceed the given threshold
+// into single tiles, so that dense shallow regions are loaded with fewer requests. The estimated size of a bundle, sum of
+// the ones of its tiles, does not exceed the given max size, and bundles hold at least two tiles. A bundle takes the
+// place of its first tile, spanning the bounding volumes of all of them. The tree is bundled the first time its root is
+// requested once built.
+type bundledTree struct {
+	ITree
+	threshold int64
+	maxSize   int64
+	pointSize int64
+	tileSize  int64
+	root      INode
+	once      sync.Once
+}
+
+// Wraps the given tree so that the small sibling leaf tiles of its built root node are bundled, given the estimated
+// size in bytes of every point and of the overhead of every tile
+func NewBundledTree(tree ITree, threshold int64, maxSize int64, pointSize int64, tileSize int64) ITree {
+	return &bundledTree{
+		ITree:     tree,
+		threshold: threshold,
+		maxSize:   maxSize,
+		pointSize: pointSize,
+		tileSize:  tileSize,
+	}
+}
+
+func (t *bundledTree) GetRootNode() INode {
+	root := t.ITree.GetRootNode()
+	if root == nil || !t.ITree.IsBuilt() {
+		return root
+	}
+	t.once.Do(func() {
+		t.root = t.newBundlingNode(root, nil)
+	})
+	return t.root
+}
+
+// A node whose small leaf children may have been bundled
+type bundlingNode struct {
+	INode
+	parent   INode
+	children [8]INode
+}
+
+func (t *bundledTree) newBundlingNode(node INode, parent INode) *bundlingNode {
+	bundling := &bundlingNode{
+		INode:  node,
+		parent: parent,
+	}
+	var group []int
+	var groupSize int64
+	flush := func() {
+		if len(group) >= 2 {
+			nodes := make([]INode, len(group))
+			for j, i := range group {
+				nodes[j] = bundling.children[i]
+				bundling.children[i] = nil
+			}
+			bundling.children[group[0]] = newLeafBundle(nodes, bundling)
+		}
+		group, groupSize = nil, 0
+	}
+	for i, child := range node.GetChildren() {
+		if child == nil {
+			continue
+		}
+		bundling.children[i] = t.newBundlingNode(child, bundling)
+		if child.TotalNumberOfPoints() == 0 || !child.IsLeaf() {
+			continue
+		}
+		size := t.tileSize + child.NumberOfPoints()*t.pointSize
+		if size > t.threshold {
+			continue
+		}
+		if len(group) > 0 && groupSize+size > t.maxSize {
+			flush()
+		}
+		group = append(group, i)
+		groupSize += size
+	}
+	flush()
+	return bundling
+}
+
+func (n *bundlingNode) GetParent() INode {
+	return n.parent
+}
+
+func (n *bundlingNode) GetChildren() [8]INode {
+	return n.children
+}
+
+// A leaf tile holding the points of the bundled sibling leaf tiles
+type leafBundle struct {
+	INode
+	parent      INode
+	nodes       []INode
+	points      []*data.Point
+	boundingBox *geometry.BoundingBox
+}
+
+func newLeafBundle(nodes []INode, parent INode) *leafBundle {
+	bundle := &leafBundle{
+		INode:  nodes[0],
+		parent: parent,
+		nodes:  nodes,
+	}
+	boxes := make([]*geometry.BoundingBox, len(nodes))
+	for i, node := range nodes {
+		bundle.points = append(bundle.points, node.GetPoints()...)
+		boxes[i] = node.GetBoundingBox()
+	}
+	bundle.boundingBox = getBoundingBoxUnion(boxes)
+	return bundle
+}
+
+// Returns the smallest bounding box enclosing all the given ones
+func getBoundingBoxUnion(boxes []*geometry.BoundingBox) *geometry.BoundingBox {
+	union := *boxes[0]
+	for _, box := range boxes[1:] {
+		union.Xmin, union.Xmax = math.Min(union.Xmin, box.Xmin), math.Max(union.Xmax, box.Xmax)
+		union.Ymin, union.Ymax = math.Min(union.Ymin, box.Ymin), math.Max(union.Ymax, box.Ymax)
+		union.Zmin, union.Zmax = math.Min(union.Zmin, box.Zmin), math.Max(union.Zmax, box.Zmax)
+	}
+	return geometry.NewBoundingBox(union.Xmin, union.Xmax, union.Ymin, union.Ymax, union.Zmin, union.Zmax)
+}
+
+func (b *leafBundle) GetBundledNodes() []INode {
+	return b.nodes
+}
+
+func (b *leafBundle) GetParent() INode {
+	return b.parent
+}
+
+func (b *leafBundle) GetChildren() [8]INode {
+	return [8]INode{}
+}
+
+func (b *leafBundle) GetPoints() []*data.Point {
+	return b.points
+}
+
+func (b *leafBundle) NumberOfPoints() int64 {
+	return int64(len(b.points))
+}
+
+func (b *leafBundle) TotalNumberOfPoints() int64 {
+	return int64(len(b.points))
+}
+
+func (b *leafBundle) IsLeaf() bool {
+	return true
+}
+
+func (b *leafBundle) GetBoundingBox() *geometry.BoundingBox {
+	return b.boundingBox
+}
+
+func (b *leafBundle) GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error) {
+	boxes := make([]*geometry.BoundingBox, len(b.nodes))
+	for i, node := range b.nodes {
+		region, err := node.GetBoundingBoxRegion(converter)
+		if err != nil {
+			return nil, err
+		}
+		boxes[i] = region
+	}
+	return getBoundingBoxUnion(boxes), nil
+}
+
+func (b *leafBundle) ComputeGeometricError() float64 {
+	geometricError := 0.0
+	for _, node := range b.nodes {
+		geometricError = math.Max(geometricError, node.ComputeGeometricError())
+	}
+	return geometricError
+}
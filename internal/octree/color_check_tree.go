@@ -0,0 +1,64 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"sync"
+)
+
+// Max value of the color channels of a point cloud whose colors are considered too dark to be valid, as happens when
+// 8-bit colors are stored in the 16-bit fields of a LAS file and scaled down to 8 bits again
+const maxDarkColorValue = 3
+
+// Decorates a tree recording the range of the colors of the points added to it, to detect the point clouds whose
+// colors are constant or clearly invalid and would only waste space in the tiles
+type ColorCheckTree struct {
+	ITree
+	points int64
+	min    [3]uint8
+	max    [3]uint8
+	sync.Mutex
+}
+
+// Wraps the given tree checking the colors of the points added to it
+func NewColorCheckTree(tree ITree) *ColorCheckTree {
+	return &ColorCheckTree{
+		ITree: tree,
+		min:   [3]uint8{255, 255, 255},
+	}
+}
+
+func (t *ColorCheckTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	t.Lock()
+	t.points++
+	for i, value := range [3]uint8{r, g, b} {
+		if value < t.min[i] {
+			t.min[i] = value
+		}
+		if value > t.max[i] {
+			t.max[i] = value
+		}
+	}
+	t.Unlock()
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+}
+
+// Returns why the colors of the points added to the tree are invalid: "zero" if all of them are black, "constant" if
+// they all have the same color and "dark" if no channel goes above a few units. Returns an empty string if the colors
+// look valid or no points have been added.
+func (t *ColorCheckTree) GetColorDefect() string {
+	t.Lock()
+	defer t.Unlock()
+	if t.points == 0 {
+		return ""
+	}
+	if t.max == [3]uint8{} {
+		return "zero"
+	}
+	if t.min == t.max {
+		return "constant"
+	}
+	if t.max[0] <= maxDarkColorValue && t.max[1] <= maxDarkColorValue && t.max[2] <= maxDarkColorValue {
+		return "dark"
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"sync"
+)
+
+// Number of points buffered for every worker of a concurrent tree
+const pointsBufferPerWorker = 1024
+
+// Raw data of a point added to a concurrent tree
+type rawPoint struct {
+	coordinate     *geometry.Coordinate
+	r              uint8
+	g              uint8
+	b              uint8
+	intensity      uint8
+	classification uint8
+	srid           int
+	attributes     []float32
+}
+
+// Decorates a tree adding points to it from a pool of workers, so that the coordinate conversion performed by the
+// wrapped tree on insertion runs with its own concurrency rather than in the goroutines of the readers. The pool is
+// drained and stopped when the tree is built, so a concurrent tree can be used to load a single point cloud.
+type concurrentTree struct {
+	ITree
+	points    chan *rawPoint
+	waitGroup sync.WaitGroup
+}
+
+// Wraps the given tree so that the added points are inserted by the given number of workers
+func NewConcurrentTree(tree ITree, workers int) ITree {
+	t := &concurrentTree{
+		ITree:  tree,
+		points: make(chan *rawPoint, workers*pointsBufferPerWorker),
+	}
+	for i := 0; i < workers; i++ {
+		t.waitGroup.Add(1)
+		go t.insertPoints()
+	}
+	return t
+}
+
+func (t *concurrentTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	t.points <- &rawPoint{coordinate, r, g, b, intensity, classification, srid, attributes}
+}
+
+// Waits for all the added points to be inserted and builds the wrapped tree
+func (t *concurrentTree) Build() error {
+	close(t.points)
+	t.waitGroup.Wait()
+	return t.ITree.Build()
+}
+
+func (t *concurrentTree) insertPoints() {
+	defer t.waitGroup.Done()
+	for p := range t.points {
+		t.ITree.AddPoint(p.coordinate, p.r, p.g, p.b, p.intensity, p.classification, p.srid, p.attributes)
+	}
+}
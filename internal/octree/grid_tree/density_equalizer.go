@@ -0,0 +1,80 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Semi-major axis in meters of the WGS84 ellipsoid, the radius of the internal Mercator projection
+const mercatorRadius = 6378137.0
+
+// Thread safe sampler capping the density of the points to a uniform target. The space is divided in cells of a fixed
+// size in meters, columns spanning all the heights if the target is a number of points per square meter or cubes if it
+// is a number of points per cubic meter, and every cell keeps a uniform random sample of at most the target density
+// times its area or volume points, so that the dense areas, e.g. of terrestrial scans merged with aerial ones, are
+// thinned to the density of the sparse ones while the cells below the target keep all their points.
+type densityEqualizer struct {
+	cells  map[gridIndex]*reservoir
+	quota  int
+	size   float64
+	height float64
+	volume bool
+	random *rand.Rand
+	sync.Mutex
+}
+
+// Instances an equalizer capping the density of the points to the given target in cells of the given size in meters,
+// whose size in the internal Mercator coordinates is the one at the given Mercator northing
+func newDensityEqualizer(target float64, cellSize float64, mode tiler.DensityMode, northing float64) *densityEqualizer {
+	// the Mercator projection stretches the distances by the inverse of the cosine of the latitude
+	latitude := 2*math.Atan(math.Exp(northing/mercatorRadius)) - math.Pi/2
+	equalizer := &densityEqualizer{
+		cells:  make(map[gridIndex]*reservoir),
+		quota:  int(math.Floor(target * cellSize * cellSize)),
+		size:   cellSize / math.Cos(latitude),
+		height: cellSize,
+		volume: mode == tiler.DensityVolume,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if equalizer.volume {
+		equalizer.quota = int(math.Floor(target * cellSize * cellSize * cellSize))
+	}
+	return equalizer
+}
+
+// Offers a point to the reservoir of its cell
+func (e *densityEqualizer) offer(point *data.Point) {
+	index := gridIndex{x: int(math.Floor(point.X / e.size)), y: int(math.Floor(point.Y / e.size))}
+	if e.volume {
+		index.z = int(math.Floor(point.Z / e.height))
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	cell, ok := e.cells[index]
+	if !ok {
+		cell = &reservoir{capacity: e.quota}
+		e.cells[index] = cell
+	}
+	cell.seen++
+	if len(cell.points) < cell.capacity {
+		cell.points = append(cell.points, point)
+	} else if j := e.random.Int63n(cell.seen); j < int64(cell.capacity) {
+		cell.points[j] = point
+	}
+}
+
+// Returns the points kept by the cells and the number of points offered
+func (e *densityEqualizer) getPoints() ([]*data.Point, int64) {
+	var points []*data.Point
+	var seen int64
+	for _, cell := range e.cells {
+		points = append(points, cell.points...)
+		seen += cell.seen
+	}
+	return points, seen
+}
@@ -2,18 +2,21 @@ package grid_tree
 
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"math"
 	"sync"
 )
 
-// Data structure that accepts points and stores just the one closest to its center, or if the side is too small,
-// all the points. It assumes that coordinates are expressed in a metric cartesian system.
+// Data structure that accepts points and stores just the one of lowest score according to its sampling strategy, by
+// default the one closest to its center, or if the side is too small, all the points. It assumes that coordinates are
+// expressed in a metric cartesian system.
 type gridCell struct {
-	index              gridIndex     // unique spatial index of the cell
-	size               float64       // length of the side of the cell (cubic cell)
-	points             []*data.Point // points stored in the cell
-	sizeThreshold      float64       // if size is below sizeThreshold store all points in the cell instead of just the one closest to the center
-	distanceFromCenter float64       // distance from center of current point at index 0
+	index              gridIndex              // unique spatial index of the cell
+	size               float64                // length of the side of the cell (cubic cell)
+	points             []*data.Point          // points stored in the cell
+	sizeThreshold      float64                // if size is below sizeThreshold store all points in the cell instead of just the one closest to the center
+	distanceFromCenter float64                // score of the current point at index 0, its distance from the center by default
+	strategy           tiler.SamplingStrategy // scores the points the cell keeps the one of lowest score of, the distance from the center if nil
 	sync.RWMutex
 }
 
@@ -55,14 +58,14 @@ func (gc *gridCell) isSizeBelowThreshold() bool {
 func (gc *gridCell) storeFirstPoint(point *data.Point) {
 	gc.Lock()
 	gc.points = []*data.Point{point}
-	gc.distanceFromCenter = gc.getDistanceFromCenter(point)
+	gc.distanceFromCenter = gc.getDistance(point)
 	gc.Unlock()
 }
 
 // takes the input point and compares its distance from the center to the one in the points array,
 // storing in the array only the one closest to the center and returning the other, rejected and farthest from the center, one
 func (gc *gridCell) storeClosestPointAndReturnFarthestOne(point *data.Point) *data.Point {
-	distance := gc.getDistanceFromCenter(point)
+	distance := gc.getDistance(point)
 
 	if distance < gc.distanceFromCenter {
 		gc.Lock()
@@ -76,13 +79,29 @@ func (gc *gridCell) storeClosestPointAndReturnFarthestOne(point *data.Point) *da
 	return point
 }
 
-// computes the cartesian distance of a point from the cell center
-func (gc *gridCell) getDistanceFromCenter(point *data.Point) float64 {
+// returns the score of the point the cell keeps the point of lowest score by
+func (gc *gridCell) getDistance(point *data.Point) float64 {
 	xc, yc, zc := gc.getCellCenter()
+	if gc.strategy == nil {
+		return centerSampling{}.Score(point, xc, yc, zc, gc.size)
+	}
+	return gc.strategy.Score(point, xc, yc, zc, gc.size)
+}
+
+// returns a pseudo random priority in [0, 1) derived from the coordinates of the point. As every cell keeps the point
+// of lowest priority among the ones reaching it, its pick is a uniform random one that does not depend on the order
+// the points are added in, so that the tiles are the same whatever the number of insert workers.
+func getRandomPriority(point *data.Point) float64 {
+	hash := mixBits(math.Float64bits(point.X))
+	hash = mixBits(hash ^ math.Float64bits(point.Y))
+	hash = mixBits(hash ^ math.Float64bits(point.Z))
+	return float64(hash>>11) / (1 << 53)
+}
 
-	return math.Sqrt(
-		math.Pow(point.X-xc, 2) +
-			math.Pow(point.Y-yc, 2) +
-			math.Pow(point.Z-zc, 2),
-	)
+// splitmix64 finalizer, spreading every input bit over all the output bits
+func mixBits(value uint64) uint64 {
+	value += 0x9e3779b97f4a7c15
+	value = (value ^ (value >> 30)) * 0xbf58476d1ce4e5b9
+	value = (value ^ (value >> 27)) * 0x94d049bb133111eb
+	return value ^ (value >> 31)
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"math"
 	"sync"
 	"sync/atomic"
@@ -14,7 +15,8 @@ import (
 // Models a node of the octree, which can either be a leaf (a node without children nodes) or not.
 // Each Node can contain up to eight children nodes. The node uses a grid algorithm to decide which points to store.
 // It divides its bounding box in gridCells and only stores points retained by these cells, propagating the ones rejected
-// by the cells to its children which will have smaller cells.
+// by the cells to its children which will have smaller cells. With the Poisson sampling the node stores instead the
+// points lying farther than the cell size from all the points it stores.
 type GridNode struct {
 	root                bool
 	parent              octree.INode
@@ -22,13 +24,19 @@ type GridNode struct {
 	children            [8]octree.INode
 	cells               map[gridIndex]*gridCell
 	points              []*data.Point
+	sampledPoints       []*data.Point
 	cellSize            float64
 	minCellSize         float64
+	cellCount           int
 	totalNumberOfPoints int64
-	numberOfPoints      int32
+	numberOfPoints      int64
 	leaf                int32
 	initialized         bool
 	rootGeometricError	float64
+	spooler             *gridSpooler
+	spool               *nodeSpool
+	sampling            tiler.GridSampling
+	strategy            tiler.SamplingStrategy
 	sync.RWMutex
 }
 
@@ -68,7 +76,7 @@ func (n *GridNode) AddDataPoint(point *data.Point) {
 		n.addPointToChildren(pushedOutPoint)
 	} else {
 		// if no point was rejected then the number of points stored is increased by 1
-		atomic.AddInt32(&n.numberOfPoints, 1)
+		atomic.AddInt64(&n.numberOfPoints, 1)
 	}
 
 	// in any case the total number of points stored by the n or its children increases by one
@@ -97,15 +105,28 @@ func (n *GridNode) GetChildren() [8]octree.INode {
 	return n.children
 }
 
+// Returns the points of the node, read from its spool file every time if the tree is spooled, so that only the
+// nodes being exported are held in memory. A failed read returns no points and is recorded by the spooler.
 func (n *GridNode) GetPoints() []*data.Point {
-	return n.points
+	if n.spooler == nil {
+		return n.points
+	}
+	if n.spool == nil {
+		return nil
+	}
+	points, err := n.spool.read(n.numberOfPoints)
+	if err != nil {
+		n.spooler.setError(err)
+		return nil
+	}
+	return points
 }
 
 func (n *GridNode) TotalNumberOfPoints() int64 {
 	return n.totalNumberOfPoints
 }
 
-func (n *GridNode) NumberOfPoints() int32 {
+func (n *GridNode) NumberOfPoints() int64 {
 	return n.numberOfPoints
 }
 
@@ -117,14 +138,15 @@ func (n *GridNode) IsInitialized() bool {
 	return n.initialized
 }
 
+// Returns true for the root of the grid unless it is the child of an overflow node
 func (n *GridNode) IsRoot() bool {
-	return n.root
+	return n.root && n.parent == nil
 }
 
 // Computes the geometric error for the given GridNode
 func (n *GridNode) ComputeGeometricError() float64 {
 	// geometric error is estimated as the maximum possible distance between two points lying in the cell
-	if n.IsRoot() {
+	if n.root {
 		return n.cellSize * math.Sqrt(3) * 2 * n.rootGeometricError
 	}
 	return n.cellSize * math.Sqrt(3) * 2
@@ -149,12 +171,20 @@ func getOctantFromElement(element *data.Point, bbox *geometry.BoundingBox) uint8
 // and recursively builds the points of its children.
 // sets the slice reference to nil to allow GC to happen as the cells won't be used anymore
 func (n *GridNode) BuildPoints() {
-	var points []*data.Point
+	points := n.sampledPoints
 	for _, cell := range n.cells {
 		points = append(points, cell.points...)
 	}
 	n.points = points
+	n.cellCount = len(n.cells)
 	n.cells = nil
+	n.sampledPoints = nil
+	if n.spooler != nil {
+		for _, point := range n.points {
+			n.spooler.spool(n.getSpool(), point)
+		}
+		n.points = nil
+	}
 
 	for _, child := range n.children {
 		if child != nil {
@@ -167,6 +197,16 @@ func (n *GridNode) GetParent() octree.INode {
 	return n.parent
 }
 
+func (n *GridNode) GetCellSize() float64 {
+	return n.cellSize
+}
+
+// Returns the number of cells that held points when the node was built, none for the nodes whose points bypass the
+// cells, as the Poisson sampled or spooled ones
+func (n *GridNode) NumberOfCells() int {
+	return n.cellCount
+}
+
 // gets the grid cell where the given point falls into, eventually creating it if it does not exist
 func (n *GridNode) getPointGridCell(point *data.Point) *gridCell {
 	index := *n.getPointGridCellIndex(point)
@@ -200,6 +240,7 @@ func (n *GridNode) initializeGridCell(index *gridIndex) *gridCell {
 			index:         *index,
 			size:          n.cellSize,
 			sizeThreshold: n.minCellSize,
+			strategy:      n.strategy,
 		}
 		n.cells[*index] = out
 	}
@@ -211,14 +252,76 @@ func (n *GridNode) initializeGridCell(index *gridIndex) *gridCell {
 
 // atomically checks if the node is empty
 func (n *GridNode) isEmpty() bool {
-	return atomic.LoadInt32(&n.numberOfPoints) == 0
+	return atomic.LoadInt64(&n.numberOfPoints) == 0
 }
 
-// pushes a point to its gridcell and returns the point eventually pushed out
+// pushes a point to its gridcell and returns the point eventually pushed out. The cells storing all their points
+// of spooled trees are bypassed, their points being spooled right away.
 func (n *GridNode) pushPointToCell(point *data.Point) *data.Point {
+	if n.spooler != nil && n.cellSize < n.minCellSize {
+		n.spooler.spool(n.getSpool(), point)
+		return nil
+	}
+	if n.sampling == tiler.GridSamplingPoisson && n.cellSize >= n.minCellSize {
+		return n.pushPointToDisk(point)
+	}
 	return n.getPointGridCell(point).pushPoint(point)
 }
 
+// stores the point in its cell unless a point already stored by the node lies closer than the cell size, returning it
+// to be pushed out otherwise. As the cell size is the radius of the disk, only the cells around the one of the point
+// can hold a point too close to it. The check and the store are done under the node lock so that concurrent points
+// cannot both be accepted, hence the points kept depend on the order the points are added in.
+func (n *GridNode) pushPointToDisk(point *data.Point) *data.Point {
+	index := n.getPointGridCellIndex(point)
+	squaredRadius := n.cellSize * n.cellSize
+
+	n.Lock()
+	defer n.Unlock()
+	for x := index.x - 1; x <= index.x+1; x++ {
+		for y := index.y - 1; y <= index.y+1; y++ {
+			for z := index.z - 1; z <= index.z+1; z++ {
+				cell := n.cells[gridIndex{x, y, z}]
+				if cell == nil {
+					continue
+				}
+				for _, stored := range cell.points {
+					dx, dy, dz := point.X-stored.X, point.Y-stored.Y, point.Z-stored.Z
+					if dx*dx+dy*dy+dz*dz < squaredRadius {
+						return point
+					}
+				}
+			}
+		}
+	}
+
+	cell := n.cells[*index]
+	if cell == nil {
+		cell = &gridCell{index: *index, size: n.cellSize, sizeThreshold: n.minCellSize}
+		n.cells[*index] = cell
+	}
+	cell.points = append(cell.points, point)
+	return nil
+}
+
+// returns the spool file of the node, eventually creating it
+func (n *GridNode) getSpool() *nodeSpool {
+	n.RLock()
+	spool := n.spool
+	n.RUnlock()
+	if spool != nil {
+		return spool
+	}
+
+	n.Lock()
+	if n.spool == nil {
+		n.spool = n.spooler.newSpool()
+	}
+	spool = n.spool
+	n.Unlock()
+	return spool
+}
+
 // add a point to the node children and clears the leaf flag from this node
 func (n *GridNode) addPointToChildren(point *data.Point) {
 	n.children[getOctantFromElement(point, n.boundingBox)].AddDataPoint(point)
@@ -236,12 +339,109 @@ func (n *GridNode) initializeChildren() {
 	for i := uint8(0); i < 8; i++ {
 		if n.children[i] == nil {
 			n.children[i] = NewGridNode(n, getOctantBoundingBox(&i, n.boundingBox), n.cellSize/2.0, n.minCellSize, false, n.rootGeometricError)
+			n.children[i].(*GridNode).spooler = n.spooler
+			n.children[i].(*GridNode).sampling = n.sampling
+			n.children[i].(*GridNode).strategy = n.strategy
 		}
 	}
 	n.initialized = true
 	n.Unlock()
 }
 
+// Stores a point picked by the level sampler in the descendant node at the given depth, bypassing the grid cells
+func (n *GridNode) storePointAtDepth(point *data.Point, depth int) {
+	if depth > 0 {
+		n.getChildAtDepth(point).storePointAtDepth(point, depth-1)
+		return
+	}
+
+	n.Lock()
+	n.sampledPoints = append(n.sampledPoints, point)
+	n.Unlock()
+	atomic.AddInt64(&n.numberOfPoints, 1)
+	atomic.AddInt64(&n.totalNumberOfPoints, 1)
+}
+
+// Adds a point to the descendant node at the given depth, where the grid cells decide where to store it
+func (n *GridNode) addPointAtDepth(point *data.Point, depth int) {
+	if depth > 0 {
+		n.getChildAtDepth(point).addPointAtDepth(point, depth-1)
+		return
+	}
+
+	n.AddDataPoint(point)
+}
+
+// Returns the child containing the given point, accounting the point as stored below this node
+func (n *GridNode) getChildAtDepth(point *data.Point) *GridNode {
+	n.RLock()
+	initialized := n.initialized
+	n.RUnlock()
+	if !initialized {
+		n.initializeChildren()
+	}
+
+	n.clearLeafFlag()
+	atomic.AddInt64(&n.totalNumberOfPoints, 1)
+	return n.children[getOctantFromElement(point, n.boundingBox)].(*GridNode)
+}
+
+// Moves points up from the children to every node left without points by the level sampler, so that the hierarchy
+// of tiles has no holes. Must be called after BuildPoints.
+func (n *GridNode) fillEmptyNodes() {
+	n.fill()
+
+	isLeaf := true
+	for _, child := range n.children {
+		if child != nil {
+			child.(*GridNode).fillEmptyNodes()
+			isLeaf = isLeaf && child.TotalNumberOfPoints() == 0
+		}
+	}
+	if isLeaf {
+		n.leaf = 1
+	}
+}
+
+// Moves a point from the most populated child to the node if it stores no points but its children do
+func (n *GridNode) fill() {
+	if n.numberOfPoints > 0 || n.totalNumberOfPoints == 0 {
+		return
+	}
+
+	var source *GridNode
+	for _, child := range n.children {
+		if child != nil && (source == nil || child.TotalNumberOfPoints() > source.totalNumberOfPoints) {
+			source = child.(*GridNode)
+		}
+	}
+	if source == nil || source.totalNumberOfPoints == 0 {
+		return
+	}
+
+	if point := source.takePoint(); point != nil {
+		n.points = append(n.points, point)
+		n.numberOfPoints++
+	}
+}
+
+// Removes and returns a point of the node, refilling it from its children if it's left without points
+func (n *GridNode) takePoint() *data.Point {
+	n.fill()
+	if len(n.points) == 0 {
+		return nil
+	}
+
+	last := len(n.points) - 1
+	point := n.points[last]
+	n.points = n.points[:last]
+	n.numberOfPoints--
+	n.totalNumberOfPoints--
+	n.fill()
+
+	return point
+}
+
 // Returns a bounding box from the given box and the given octant index
 func getOctantBoundingBox(octant *uint8, bbox *geometry.BoundingBox) *geometry.BoundingBox {
 	return geometry.NewBoundingBoxFromParent(bbox, octant)
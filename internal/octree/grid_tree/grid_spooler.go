@@ -0,0 +1,147 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Max number of bytes of the spooled points buffered in memory, across all the nodes, before being appended to their
+// files
+const spoolMemoryBudget = 256 * 1024 * 1024
+
+// Spool file of the points of a grid node, buffered in memory until the buffers of all the nodes exceed the memory
+// budget
+type nodeSpool struct {
+	file   string
+	buffer []byte
+	sync.Mutex
+}
+
+// Appends the buffered points to the spool file returning the number of bytes written
+func (s *nodeSpool) flush() (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	if len(s.buffer) == 0 {
+		return 0, nil
+	}
+	file, err := os.OpenFile(s.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return 0, err
+	}
+	_, err = file.Write(s.buffer)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	flushed := int64(len(s.buffer))
+	s.buffer = nil
+	return flushed, err
+}
+
+// Reads the points of the spool file, which must have been flushed
+func (s *nodeSpool) read(count int64) ([]*data.Point, error) {
+	content, err := ioutil.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	points := make([]*data.Point, 0, count)
+	for offset := 0; offset < len(content); {
+		point, size := data.DecodePoint(content[offset:])
+		points = append(points, point)
+		offset += size
+	}
+	return points, nil
+}
+
+// Moves the points of the grid nodes to spool files in a folder, so that the memory taken by the tree is bounded by
+// the number of its grid cells rather than by the number of its points. The points stored by the cells smaller than
+// the min cell size, i.e. most of the points of dense clouds, are spooled as soon as they reach their node, while the
+// points retained by the larger cells are spooled once the tree is built.
+type gridSpooler struct {
+	folder   string
+	spools   []*nodeSpool
+	buffered int64
+	flush    sync.Mutex
+	// first error spooling the points or reading them back, returned when the tree is built or exported
+	err error
+	sync.Mutex
+}
+
+// Creates a spooler writing the spool files in a temporary folder created in the given one, in the system temporary
+// folder if empty
+func newGridSpooler(folder string) (*gridSpooler, error) {
+	folder, err := ioutil.TempDir(folder, "gocesiumtiler-spool")
+	if err != nil {
+		return nil, err
+	}
+	return &gridSpooler{folder: folder}, nil
+}
+
+// Returns a new spool file
+func (s *gridSpooler) newSpool() *nodeSpool {
+	s.Lock()
+	defer s.Unlock()
+	spool := &nodeSpool{file: filepath.Join(s.folder, strconv.Itoa(len(s.spools))+".points")}
+	s.spools = append(s.spools, spool)
+	return spool
+}
+
+// Buffers the given point in the given spool, flushing the buffers of all the spools if they exceed the memory budget
+func (s *gridSpooler) spool(spool *nodeSpool, point *data.Point) {
+	spool.Lock()
+	size := len(spool.buffer)
+	spool.buffer = data.EncodePoint(spool.buffer, point)
+	added := int64(len(spool.buffer) - size)
+	spool.Unlock()
+	if atomic.AddInt64(&s.buffered, added) > spoolMemoryBudget {
+		s.flushSpools(spoolMemoryBudget)
+	}
+}
+
+// Appends the buffered points of all the spools to their files if they exceed the given number of bytes
+func (s *gridSpooler) flushSpools(threshold int64) {
+	s.flush.Lock()
+	defer s.flush.Unlock()
+	if atomic.LoadInt64(&s.buffered) <= threshold {
+		// already flushed by another goroutine
+		return
+	}
+	s.Lock()
+	spools := s.spools
+	s.Unlock()
+	for _, spool := range spools {
+		flushed, err := spool.flush()
+		atomic.AddInt64(&s.buffered, -flushed)
+		if err != nil {
+			s.setError(err)
+		}
+	}
+}
+
+// Records the given error unless an earlier one has been recorded
+func (s *gridSpooler) setError(err error) {
+	s.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.Unlock()
+}
+
+// Returns the first error spooling the points or reading them back, nil if none
+func (s *gridSpooler) getError() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.err
+}
+
+// Removes the spool files
+func (s *gridSpooler) close() error {
+	return os.RemoveAll(s.folder)
+}
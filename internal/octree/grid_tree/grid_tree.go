@@ -2,14 +2,20 @@ package grid_tree
 
 import (
 	"errors"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
 	"log"
-	"runtime"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Coordinates are stored in EPSG 3395, which is a cartesian 2D metric reference system
@@ -25,23 +31,70 @@ type GridTree struct {
 	coordinateConverter converters.CoordinateConverter
 	elevationCorrector  converters.ElevationCorrector
 	rootGeometricError	float64
+	originSnap          tiler.OriginSnapMode
+	centroidAccumulator centroidAccumulator
+	insertWorkers       int
+	levelRetention      []float64
+	rootPercentile      float64
+	outliers            *outlierCollector
+	leafPointCap        int
+	leafCapPolicy       tiler.LeafCapPolicy
+	densityTarget       float64
+	densityMode         tiler.DensityMode
+	densityCellSize     float64
+	sampling            tiler.GridSampling
+	strategy            tiler.SamplingStrategy
+	pointCount          int64
+	sampler             *levelSampler
+	cancellation        *cancellation.Token
 	point_loader.Loader
 	sync.RWMutex
 }
 
-// Builds an empty GridTree initializing its properties to the correct defaults
-func NewGridTree(coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector, maxCellSize float64, minCellSize float64, rootGeometricError float64) octree.ITree {
+// Builds an empty GridTree with the grid settings of the given options. If LevelRetention is not empty, the top levels
+// of the tree store a random sample of the given fraction of the points each, instead of the points retained by their
+// grid cells. If RootPercentile is positive, the root bounds enclose the points once the given percentage of them is
+// discarded at both ends of every axis, and the points outside of them are stored in an overflow node above the root.
+// If LeafPointCap is positive, the leaf cap policy is applied to the leaves that reached the min cell size holding
+// more points. If DensityTarget is positive, the points are subsampled beforehand so that no cell of DensityCellSize
+// meters holds more than the target density, in points per square or cubic meter according to the density mode. The
+// grid cells keep the point of lowest score of the sampling strategy, the built-in strategy of the grid sampling if
+// nil, e.g. the point closest to their center if it is CENTER. Building stops once the cancellation token, if any, is
+// cancelled.
+func NewGridTree(opts *tiler.TilerOptions, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) octree.ITree {
 	return &GridTree{
 		built:               false,
-		maxCellSize:         maxCellSize,
-		minCellSize:         minCellSize,
+		maxCellSize:         opts.CellMaxSize,
+		minCellSize:         opts.CellMinSize,
 		Loader:              point_loader.NewSequentialLoader(),
 		coordinateConverter: coordinateConverter,
 		elevationCorrector:  elevationCorrector,
-		rootGeometricError:  rootGeometricError,
+		rootGeometricError:  opts.RootGeometricError,
+		originSnap:          opts.OriginSnap,
+		insertWorkers:       tiler.WorkersOrNumCPU(opts.InsertWorkers),
+		levelRetention:      opts.LevelRetention,
+		rootPercentile:      opts.RootPercentile,
+		leafPointCap:        opts.LeafPointCap,
+		leafCapPolicy:       opts.LeafCapPolicy,
+		densityTarget:       opts.DensityTarget,
+		densityMode:         opts.DensityMode,
+		densityCellSize:     opts.DensityCellSize,
+		sampling:            opts.GridSampling,
+		strategy:            getSamplingStrategy(opts),
+		cancellation:        opts.Cancellation,
 	}
 }
 
+// Returns the strategy of the options deciding the point kept by the grid cells, or the built-in one of the grid
+// sampling if none is given
+func getSamplingStrategy(opts *tiler.TilerOptions) tiler.SamplingStrategy {
+	if opts.SamplingStrategy != nil {
+		return opts.SamplingStrategy
+	}
+	index, _ := tiler.GetSamplingAttributeIndex(opts.GridSamplingAttribute, opts.LasAttributes)
+	return NewSamplingStrategy(opts.GridSampling, index)
+}
+
 // Builds the hierarchical tree structure 
 func (tree *GridTree) Build() error {
 	if tree.built {
@@ -49,17 +102,54 @@ func (tree *GridTree) Build() error {
 	}
 
 	tree.init()
+	if tree.densityTarget > 0 {
+		if err := tree.equalizeDensity(); err != nil {
+			return err
+		}
+	}
 
 	var wg sync.WaitGroup
 	tree.launchParallelPointLoaders(&wg)
 	wg.Wait()
+	if err := tree.cancellation.Err(); err != nil {
+		return err
+	}
 
-	tree.rootNode.(*GridNode).BuildPoints()
+	root := tree.rootNode.(*GridNode)
+	if tree.sampler != nil {
+		tree.storeSampledPoints()
+	}
+	root.BuildPoints()
+	if tree.sampler != nil {
+		root.fillEmptyNodes()
+	}
+	if tree.leafPointCap > 0 {
+		tree.capLeaves(root)
+	}
+	if tree.outliers != nil && len(tree.outliers.points) > 0 {
+		tree.rootNode = newOverflowNode(root, tree.outliers.points)
+	}
 	tree.built = true
 
 	return nil
 }
 
+// Applies the leaf cap policy to the leaves of the tree, reporting the ones exceeding the cap
+func (tree *GridTree) capLeaves(root *GridNode) {
+	report := &leafCapReport{}
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	dropped := root.capLeafPoints(tree.leafPointCap, tree.leafCapPolicy, random, report)
+	if report.exceedingLeaves == 0 {
+		return
+	}
+	tools.LogOutput(fmt.Sprintf("> %d leaves at the min cell size exceeded the cap of %d points, the largest holding %d points", report.exceedingLeaves, tree.leafPointCap, report.largestLeaf))
+	if tree.leafCapPolicy == tiler.LeafCapKeepAll {
+		tools.LogOutput("> WARNING: kept all the points of the leaves exceeding the cap, consider a RANDOM or DENSEST leaf cap policy")
+	} else {
+		tools.LogOutput("> dropped", dropped, "points from the leaves exceeding the cap")
+	}
+}
+
 func (tree *GridTree) GetRootNode() octree.INode {
 	return tree.rootNode
 }
@@ -68,8 +158,14 @@ func (tree *GridTree) IsBuilt() bool {
 	return tree.built
 }
 
-func (tree *GridTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) {
-	tree.Loader.AddPoint(tree.getPointFromRawData(coordinate, r, g, b, intensity, classification, srid))
+func (tree *GridTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	point := tree.getPointFromRawData(coordinate, r, g, b, intensity, classification, srid)
+	point.Attributes = attributes
+	if tree.originSnap == tiler.OriginSnapCentroid {
+		tree.centroidAccumulator.add(point)
+	}
+	atomic.AddInt64(&tree.pointCount, 1)
+	tree.Loader.AddPoint(point)
 }
 
 func (tree *GridTree) getPointFromRawData(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) *data.Point {
@@ -96,30 +192,107 @@ func (tree *GridTree) getPointFromRawData(coordinate *geometry.Coordinate, r uin
 
 
 func (tree *GridTree) init() {
-	box := tree.GetBounds()
+	box := tree.getRootBounds()
 	node := NewGridNode(nil, geometry.NewBoundingBox(box[0], box[1], box[2], box[3], box[4], box[5]), tree.maxCellSize, tree.minCellSize, true, tree.rootGeometricError)
+	node.(*GridNode).sampling = tree.sampling
+	node.(*GridNode).strategy = tree.strategy
 	tree.rootNode = node
+	if tree.rootPercentile > 0 {
+		tree.outliers = newOutlierCollector(node.GetBoundingBox())
+	}
+	if len(tree.levelRetention) > 0 {
+		tree.sampler = newLevelSampler(tree.levelRetention, atomic.LoadInt64(&tree.pointCount))
+	}
 	tree.InitializeLoader()
 }
 
 func (tree *GridTree) launchParallelPointLoaders(waitGroup *sync.WaitGroup) {
-	N := runtime.NumCPU()
-
-	for i := 0; i < N; i++ {
+	for i := 0; i < tree.insertWorkers; i++ {
 		waitGroup.Add(1)
 		go tree.launchPointLoader(waitGroup)
 	}
 }
 
+// Loads the points in a density equalizer and replaces the loader with the one of the points it keeps, which are
+// then inserted in the tree. The level sampler, if any, is sized on the number of points kept.
+func (tree *GridTree) equalizeDensity() error {
+	bounds := tree.rootNode.GetBoundingBox()
+	equalizer := newDensityEqualizer(tree.densityTarget, tree.densityCellSize, tree.densityMode, (bounds.Ymin+bounds.Ymax)/2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < tree.insertWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				if i%cancellation.CheckInterval == 0 && tree.cancellation.IsCancelled() {
+					return
+				}
+				val, shouldContinue := tree.Loader.GetNext()
+				if val != nil {
+					equalizer.offer(val)
+				}
+				if !shouldContinue {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := tree.cancellation.Err(); err != nil {
+		return err
+	}
+
+	points, seen := equalizer.getPoints()
+	loader := point_loader.NewSequentialLoader()
+	for _, point := range points {
+		loader.AddPoint(point)
+	}
+	loader.InitializeLoader()
+	tree.Loader = loader
+	if tree.sampler != nil {
+		tree.sampler = newLevelSampler(tree.levelRetention, int64(len(points)))
+	}
+	tools.LogOutput(fmt.Sprintf("> capped the density to %d points per cell of %g m, keeping %d of %d points", equalizer.quota, tree.densityCellSize, len(points), seen))
+	return nil
+}
+
 func (tree *GridTree) launchPointLoader(waitGroup *sync.WaitGroup) {
-	for {
+	for i := 0; ; i++ {
+		if i%cancellation.CheckInterval == 0 && tree.cancellation.IsCancelled() {
+			break
+		}
 		val, shouldContinue := tree.Loader.GetNext()
 		if val != nil {
-			tree.rootNode.AddDataPoint(val)
+			tree.insertPoint(val)
 		}
 		if !shouldContinue {
 			break
 		}
 	}
 	waitGroup.Done()
-}
\ No newline at end of file
+}
+// Inserts the point in the tree. If level retention targets are set the point is offered to the level sampler first
+// and only the points not sampled are inserted in the grid cells of the levels below the sampled ones.
+func (tree *GridTree) insertPoint(point *data.Point) {
+	if tree.outliers != nil && tree.outliers.offer(point) {
+		return
+	}
+	if tree.sampler == nil {
+		tree.rootNode.AddDataPoint(point)
+		return
+	}
+	if rejected := tree.sampler.offer(point); rejected != nil {
+		tree.rootNode.(*GridNode).addPointAtDepth(rejected, tree.sampler.depth())
+	}
+}
+
+// Stores the points picked by the level sampler in the nodes of their levels
+func (tree *GridTree) storeSampledPoints() {
+	root := tree.rootNode.(*GridNode)
+	for depth := 0; depth < tree.sampler.depth(); depth++ {
+		for _, point := range tree.sampler.getLevelPoints(depth) {
+			root.storePointAtDepth(point, depth)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+)
+
+// Summary of the leaves exceeding the leaf point cap
+type leafCapReport struct {
+	exceedingLeaves int
+	largestLeaf     int
+}
+
+// Applies the given policy to the leaves of the subtree of the node whose cells reached the min cell size, storing all
+// their points, if they exceed the given number of points. Returns the number of points dropped from the subtree. Must
+// be called after BuildPoints.
+func (n *GridNode) capLeafPoints(limit int, policy tiler.LeafCapPolicy, random *rand.Rand, report *leafCapReport) int64 {
+	var dropped int64
+	for _, child := range n.children {
+		if child != nil {
+			dropped += child.(*GridNode).capLeafPoints(limit, policy, random, report)
+		}
+	}
+
+	if n.cellSize < n.minCellSize && len(n.points) > limit {
+		report.exceedingLeaves++
+		if len(n.points) > report.largestLeaf {
+			report.largestLeaf = len(n.points)
+		}
+
+		kept := n.points
+		switch policy {
+		case tiler.LeafCapRandom:
+			random.Shuffle(len(kept), func(i, j int) { kept[i], kept[j] = kept[j], kept[i] })
+			kept = kept[:limit]
+		case tiler.LeafCapDensest:
+			kept = n.keepDensestCellsPoints(limit)
+		}
+		removed := len(n.points) - len(kept)
+		n.points = kept
+		atomic.AddInt64(&n.numberOfPoints, -int64(removed))
+		dropped += int64(removed)
+	}
+
+	atomic.AddInt64(&n.totalNumberOfPoints, -dropped)
+	return dropped
+}
+
+// Returns the given number of points of the node, taken from its grid cells holding the most points
+func (n *GridNode) keepDensestCellsPoints(limit int) []*data.Point {
+	counts := make(map[gridIndex]int)
+	indices := make([]gridIndex, len(n.points))
+	for i, point := range n.points {
+		indices[i] = *n.getPointGridCellIndex(point)
+		counts[indices[i]]++
+	}
+
+	order := make([]int, len(n.points))
+	for i := range order {
+		order[i] = i
+	}
+	// ties are broken by cell so that the points of a cell are kept or dropped together
+	sort.SliceStable(order, func(a, b int) bool {
+		first, second := indices[order[a]], indices[order[b]]
+		if counts[first] != counts[second] {
+			return counts[first] > counts[second]
+		}
+		if first.x != second.x {
+			return first.x < second.x
+		}
+		if first.y != second.y {
+			return first.y < second.y
+		}
+		return first.z < second.z
+	})
+
+	kept := make([]*data.Point, limit)
+	for i := range kept {
+		kept[i] = n.points[order[i]]
+	}
+	return kept
+}
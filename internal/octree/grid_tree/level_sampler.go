@@ -0,0 +1,76 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Fixed size uniform random sample of the points offered to it
+type reservoir struct {
+	points   []*data.Point
+	capacity int
+	seen     int64
+}
+
+// Thread safe sampler keeping a reservoir of points for each of the top levels of the tree. The reservoir of each level
+// holds the given fraction of the total number of points. The points rejected or evicted by a level reservoir are
+// offered to the next one, so that every level receives a uniform random sample of the points not retained above it.
+type levelSampler struct {
+	levels []*reservoir
+	random *rand.Rand
+	sync.Mutex
+}
+
+func newLevelSampler(fractions []float64, totalPoints int64) *levelSampler {
+	sampler := &levelSampler{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, fraction := range fractions {
+		capacity := int(fraction * float64(totalPoints))
+		sampler.levels = append(sampler.levels, &reservoir{
+			points:   make([]*data.Point, 0, capacity),
+			capacity: capacity,
+		})
+	}
+	return sampler
+}
+
+// Offers a point to the level reservoirs, returning the point that did not fit in any of them, if any
+func (s *levelSampler) offer(point *data.Point) *data.Point {
+	s.Lock()
+	defer s.Unlock()
+	for _, level := range s.levels {
+		point = s.offerToLevel(level, point)
+		if point == nil {
+			return nil
+		}
+	}
+	return point
+}
+
+// Reservoir sampling step: returns the point rejected or evicted by the level, nil if the level was not full yet
+func (s *levelSampler) offerToLevel(level *reservoir, point *data.Point) *data.Point {
+	level.seen++
+	if len(level.points) < level.capacity {
+		level.points = append(level.points, point)
+		return nil
+	}
+	if j := s.random.Int63n(level.seen); j < int64(level.capacity) {
+		evicted := level.points[j]
+		level.points[j] = point
+		return evicted
+	}
+	return point
+}
+
+// Returns the number of levels handled by the sampler
+func (s *levelSampler) depth() int {
+	return len(s.levels)
+}
+
+// Returns the points sampled for the given level
+func (s *levelSampler) getLevelPoints(depth int) []*data.Point {
+	return s.levels[depth].points
+}
@@ -0,0 +1,98 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"sync"
+)
+
+// Thread safe accumulator of the points coordinates used to compute their centroid
+type centroidAccumulator struct {
+	sumX  float64
+	sumY  float64
+	sumZ  float64
+	count int64
+	sync.Mutex
+}
+
+func (c *centroidAccumulator) add(point *data.Point) {
+	c.Lock()
+	c.sumX += point.X
+	c.sumY += point.Y
+	c.sumZ += point.Z
+	c.count++
+	c.Unlock()
+}
+
+// returns the centroid of the accumulated points and whether any point has been accumulated
+func (c *centroidAccumulator) centroid() ([3]float64, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if c.count == 0 {
+		return [3]float64{}, false
+	}
+	n := float64(c.count)
+	return [3]float64{c.sumX / n, c.sumY / n, c.sumZ / n}, true
+}
+
+// Returns the bounds of the root node in xMin, xMax, yMin, yMax, zMin, zMax order, placed according to the origin
+// snap mode of the tree. Sides shorter than the minimum cell size, as for a single point or a flat cloud, are extended
+// to it so that the root node never has zero volume.
+func (tree *GridTree) getRootBounds() []float64 {
+	return tree.placeRootBounds(tree.getDataBounds())
+}
+
+// Places the root node around the given bounds of the points according to the origin snap mode of the tree
+func (tree *GridTree) placeRootBounds(dataBounds []float64) []float64 {
+	bounds := geometry.ExtendDegenerateBounds(dataBounds, tree.minCellSize)
+	switch tree.originSnap {
+	case tiler.OriginSnapGrid:
+		return snapBoundsToGrid(bounds, tree.maxCellSize)
+	case tiler.OriginSnapCentroid:
+		if centroid, ok := tree.centroidAccumulator.centroid(); ok {
+			return centerBounds(bounds, centroid)
+		}
+	}
+	return bounds
+}
+
+// Moves the lower corner of the bounds to the closest lower multiple of the cell size and extends every side to the
+// cell size times the smallest power of two covering the original bounds. This way the boundaries of the nodes at
+// any depth fall on the boundaries of the grid cells of that depth.
+func snapBoundsToGrid(bounds []float64, cellSize float64) []float64 {
+	snapped := make([]float64, 6)
+	for axis := 0; axis < 3; axis++ {
+		min, max := bounds[2*axis], bounds[2*axis+1]
+		snappedMin := math.Floor(min/cellSize) * cellSize
+		side := cellSize
+		for snappedMin+side < max {
+			side *= 2
+		}
+		snapped[2*axis] = snappedMin
+		snapped[2*axis+1] = snappedMin + side
+	}
+	return snapped
+}
+
+// Extends the bounds so that they are centered on the given point
+func centerBounds(bounds []float64, center [3]float64) []float64 {
+	centered := make([]float64, 6)
+	for axis := 0; axis < 3; axis++ {
+		halfSide := math.Max(center[axis]-bounds[2*axis], bounds[2*axis+1]-center[axis])
+		centered[2*axis] = center[axis] - halfSide
+		centered[2*axis+1] = center[axis] + halfSide
+	}
+	return centered
+}
+
+// Returns the bounds of the loaded points, discarding the outliers at both ends of every axis if a root percentile is
+// set
+func (tree *GridTree) getDataBounds() []float64 {
+	if loader, ok := tree.Loader.(*point_loader.SequentialLoader); ok && tree.rootPercentile > 0 {
+		return loader.GetPercentileBounds(tree.rootPercentile)
+	}
+	return tree.GetBounds()
+}
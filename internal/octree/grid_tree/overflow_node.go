@@ -0,0 +1,120 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"math"
+	"sync"
+)
+
+// Thread safe collector of the points lying outside of the root bounds
+type outlierCollector struct {
+	bounds *geometry.BoundingBox
+	points []*data.Point
+	sync.Mutex
+}
+
+func newOutlierCollector(bounds *geometry.BoundingBox) *outlierCollector {
+	return &outlierCollector{bounds: bounds}
+}
+
+// Collects the given point if it lies outside of the root bounds, returning whether it has been collected
+func (c *outlierCollector) offer(point *data.Point) bool {
+	b := c.bounds
+	if point.X >= b.Xmin && point.X <= b.Xmax && point.Y >= b.Ymin && point.Y <= b.Ymax && point.Z >= b.Zmin && point.Z <= b.Zmax {
+		return false
+	}
+	c.Lock()
+	c.points = append(c.points, point)
+	c.Unlock()
+	return true
+}
+
+// Root node storing the outliers of the point cloud, whose only child is the root of the grid built from the other
+// points. Its bounding box encloses all the points and its geometric error is large enough for it to be refined as
+// soon as the point cloud is in view.
+type overflowNode struct {
+	core           *GridNode
+	points         []*data.Point
+	boundingBox    *geometry.BoundingBox
+	geometricError float64
+}
+
+// Makes a node storing the given outlier points the parent of the given grid root, which stops being the tree root
+func newOverflowNode(core *GridNode, points []*data.Point) *overflowNode {
+	coreBox := core.GetBoundingBox()
+	bounds := []float64{coreBox.Xmin, coreBox.Xmax, coreBox.Ymin, coreBox.Ymax, coreBox.Zmin, coreBox.Zmax}
+	for _, point := range points {
+		bounds[0], bounds[1] = math.Min(bounds[0], point.X), math.Max(bounds[1], point.X)
+		bounds[2], bounds[3] = math.Min(bounds[2], point.Y), math.Max(bounds[3], point.Y)
+		bounds[4], bounds[5] = math.Min(bounds[4], point.Z), math.Max(bounds[5], point.Z)
+	}
+	diagonal := math.Sqrt(math.Pow(bounds[1]-bounds[0], 2) + math.Pow(bounds[3]-bounds[2], 2) + math.Pow(bounds[5]-bounds[4], 2))
+
+	node := &overflowNode{
+		core:           core,
+		points:         points,
+		boundingBox:    geometry.NewBoundingBox(bounds[0], bounds[1], bounds[2], bounds[3], bounds[4], bounds[5]),
+		geometricError: math.Max(diagonal, core.ComputeGeometricError()),
+	}
+	core.parent = node
+	return node
+}
+
+func (n *overflowNode) AddDataPoint(point *data.Point) {
+	n.points = append(n.points, point)
+}
+
+func (n *overflowNode) GetInternalSrid() int {
+	return internalCoordinateEpsgCode
+}
+
+func (n *overflowNode) IsRoot() bool {
+	return true
+}
+
+func (n *overflowNode) GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error) {
+	return converter.Convert2DBoundingboxToWGS84Region(n.boundingBox, n.GetInternalSrid())
+}
+
+func (n *overflowNode) GetChildren() [8]octree.INode {
+	return [8]octree.INode{n.core}
+}
+
+func (n *overflowNode) GetPoints() []*data.Point {
+	return n.points
+}
+
+func (n *overflowNode) TotalNumberOfPoints() int64 {
+	return n.core.TotalNumberOfPoints() + int64(len(n.points))
+}
+
+func (n *overflowNode) NumberOfPoints() int64 {
+	return int64(len(n.points))
+}
+
+func (n *overflowNode) IsLeaf() bool {
+	return false
+}
+
+func (n *overflowNode) IsInitialized() bool {
+	return true
+}
+
+func (n *overflowNode) ComputeGeometricError() float64 {
+	return n.geometricError
+}
+
+func (n *overflowNode) GetParent() octree.INode {
+	return nil
+}
+
+func (n *overflowNode) GetBoundingBox() *geometry.BoundingBox {
+	return n.boundingBox
+}
+
+func (n *overflowNode) GetCoreNode() octree.INode {
+	return n.core
+}
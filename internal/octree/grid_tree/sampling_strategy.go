@@ -0,0 +1,64 @@
+package grid_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+)
+
+// Returns the built-in strategy of the given grid sampling, the cells of the MAX sampling keeping the point of highest
+// value of the supplementary attribute of the given index, or of highest intensity if the index is negative. The
+// POISSON sampling, which does not pick a point per cell, keeps the points closest to the center of the cells smaller
+// than the min cell size.
+func NewSamplingStrategy(sampling tiler.GridSampling, attributeIndex int) tiler.SamplingStrategy {
+	switch sampling {
+	case tiler.GridSamplingRandom:
+		return randomSampling{}
+	case tiler.GridSamplingFirst:
+		return firstSampling{}
+	case tiler.GridSamplingMax:
+		return maxAttributeSampling{attributeIndex: attributeIndex}
+	}
+	return centerSampling{}
+}
+
+// Keeps the point closest to the center of the cell
+type centerSampling struct{}
+
+func (s centerSampling) Score(point *data.Point, centerX float64, centerY float64, centerZ float64, size float64) float64 {
+	return math.Sqrt(
+		math.Pow(point.X-centerX, 2) +
+			math.Pow(point.Y-centerY, 2) +
+			math.Pow(point.Z-centerZ, 2),
+	)
+}
+
+// Keeps the point of lowest pseudo random priority
+type randomSampling struct{}
+
+func (s randomSampling) Score(point *data.Point, centerX float64, centerY float64, centerZ float64, size float64) float64 {
+	return getRandomPriority(point)
+}
+
+// Keeps the first point, as no other point gets a lower score
+type firstSampling struct{}
+
+func (s firstSampling) Score(point *data.Point, centerX float64, centerY float64, centerZ float64, size float64) float64 {
+	return 0
+}
+
+// Keeps the point of highest value of an attribute, the intensity if the index of the attribute is negative. The points
+// missing the attribute get the lowest priority.
+type maxAttributeSampling struct {
+	attributeIndex int
+}
+
+func (s maxAttributeSampling) Score(point *data.Point, centerX float64, centerY float64, centerZ float64, size float64) float64 {
+	if s.attributeIndex < 0 {
+		return -float64(point.Intensity)
+	}
+	if s.attributeIndex >= len(point.Attributes) {
+		return math.Inf(1)
+	}
+	return -float64(point.Attributes[s.attributeIndex])
+}
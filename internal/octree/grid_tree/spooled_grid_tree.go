@@ -0,0 +1,124 @@
+package grid_tree
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"sync"
+)
+
+// A GridTree trading IO for memory so that point clouds larger than the memory can be tiled with the grid algorithm.
+// In the first pass the points are only measured, to place the root node around them. In the second pass they are
+// inserted straight in the nodes, whose cells smaller than the min cell size, storing all the points they receive,
+// append them to the spool files of their nodes. Once the tree is built the points retained by the larger cells are
+// spooled too, and the points of the nodes are read back from their files every time they are requested. The memory
+// taken by the tree is hence bounded by the number of its grid cells, i.e. by the extent of the cloud, whatever the
+// number of its points.
+type SpooledGridTree struct {
+	*GridTree
+	spoolFolder string
+	spooler     *gridSpooler
+	pass        int
+	// bounds and count of the points added in the first pass
+	bounds []float64
+	count  int64
+	mutex  sync.Mutex
+}
+
+// Builds an empty SpooledGridTree, with the settings of a GridTree, whose spool files are written in a temporary
+// folder created in the spool folder of the options, in the system temporary folder if empty. The level retention,
+// root percentile, leaf cap and density target settings are not supported.
+func NewSpooledGridTree(opts *tiler.TilerOptions, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) octree.ITree {
+	return &SpooledGridTree{
+		GridTree:    NewGridTree(opts, coordinateConverter, elevationCorrector).(*GridTree),
+		spoolFolder: opts.SpoolFolder,
+		pass:        1,
+		bounds:      []float64{math.MaxFloat64, -math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64},
+	}
+}
+
+func (t *SpooledGridTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	point := t.getPointFromRawData(coordinate, r, g, b, intensity, classification, srid)
+	point.Attributes = attributes
+	if t.pass == 2 {
+		t.rootNode.AddDataPoint(point)
+		return
+	}
+
+	if t.originSnap == tiler.OriginSnapCentroid {
+		t.centroidAccumulator.add(point)
+	}
+	t.mutex.Lock()
+	t.bounds[0], t.bounds[1] = math.Min(t.bounds[0], point.X), math.Max(t.bounds[1], point.X)
+	t.bounds[2], t.bounds[3] = math.Min(t.bounds[2], point.Y), math.Max(t.bounds[3], point.Y)
+	t.bounds[4], t.bounds[5] = math.Min(t.bounds[4], point.Z), math.Max(t.bounds[5], point.Z)
+	t.count++
+	t.mutex.Unlock()
+}
+
+// Ends the first pass creating the root node around the points and requesting a second pass unless no points have
+// been added. Ends the second pass writing the buffered points in the spool files.
+func (t *SpooledGridTree) EndPass() (bool, error) {
+	if t.pass == 1 {
+		if t.count == 0 {
+			return false, nil
+		}
+		spooler, err := newGridSpooler(t.spoolFolder)
+		if err != nil {
+			return false, err
+		}
+		t.spooler = spooler
+		box := t.placeRootBounds(t.bounds)
+		root := NewGridNode(nil, geometry.NewBoundingBox(box[0], box[1], box[2], box[3], box[4], box[5]), t.maxCellSize, t.minCellSize, true, t.rootGeometricError).(*GridNode)
+		root.spooler = spooler
+		root.sampling = t.sampling
+		root.strategy = t.strategy
+		t.rootNode = root
+		t.pass = 2
+		return true, nil
+	}
+
+	t.spooler.flushSpools(0)
+	return false, t.spooler.err
+}
+
+// Builds the tree once the second pass has ended, spooling the points retained by the grid cells
+func (t *SpooledGridTree) Build() error {
+	if t.built {
+		return errors.New("octree already built")
+	}
+	if t.pass == 1 && t.count > 0 {
+		return errors.New("the points have not been added in the second pass")
+	}
+	if err := t.cancellation.Err(); err != nil {
+		return err
+	}
+	if t.spooler != nil {
+		t.rootNode.(*GridNode).BuildPoints()
+		t.spooler.flushSpools(0)
+		if t.spooler.err != nil {
+			return t.spooler.err
+		}
+	}
+	t.built = true
+	return nil
+}
+
+// Returns the first error reading back the points of the nodes from their spool files
+func (t *SpooledGridTree) Err() error {
+	if t.spooler == nil {
+		return nil
+	}
+	return t.spooler.getError()
+}
+
+// Removes the spool files of the nodes
+func (t *SpooledGridTree) Close() error {
+	if t.spooler == nil {
+		return nil
+	}
+	return t.spooler.close()
+}
@@ -0,0 +1,161 @@
+package octree
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Color ramp mapping the intensity of the points to colors, the colors being evenly spaced over the intensity range
+// and interpolated between them
+type ColorRamp [][3]uint8
+
+// Ramp from black to white used when no ramp is given
+var grayscaleRamp = ColorRamp{{0, 0, 0}, {255, 255, 255}}
+
+// Parses a color ramp given as a comma separated list of at least two hex colors, e.g. #000080,#00ff00,#ff0000 for
+// the lowest, middle and highest intensities. The grayscale ramp is returned if the value is empty.
+func ParseColorRamp(value string) (ColorRamp, error) {
+	if strings.TrimSpace(value) == "" {
+		return grayscaleRamp, nil
+	}
+	var ramp ColorRamp
+	for _, token := range strings.Split(value, ",") {
+		hex := strings.TrimPrefix(strings.TrimSpace(token), "#")
+		color, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, errors.New("invalid color " + strings.TrimSpace(token) + ", colors should be written as #rrggbb")
+		}
+		ramp = append(ramp, [3]uint8{uint8(color >> 16), uint8(color >> 8), uint8(color)})
+	}
+	if len(ramp) < 2 {
+		return nil, errors.New("the ramp should have at least two colors")
+	}
+	return ramp, nil
+}
+
+// Returns the color of the given intensity along the ramp
+func (ramp ColorRamp) GetColor(intensity uint8) (uint8, uint8, uint8) {
+	position := float64(intensity) / 255 * float64(len(ramp)-1)
+	index := int(position)
+	if index >= len(ramp)-1 {
+		last := ramp[len(ramp)-1]
+		return last[0], last[1], last[2]
+	}
+	weight := position - float64(index)
+	var color [3]uint8
+	for i := range color {
+		color[i] = uint8(math.Round(float64(ramp[index][i])*(1-weight) + float64(ramp[index+1][i])*weight))
+	}
+	return color[0], color[1], color[2]
+}
+
+// Parses the low and high percentiles of the intensities stretched over the whole color ramp, given as two comma
+// separated percentages, e.g. 2,98
+func ParseIntensityStretch(value string) (float64, float64, error) {
+	tokens := strings.Split(value, ",")
+	if len(tokens) != 2 {
+		return 0, 0, errors.New("should be two comma separated percentiles, e.g. 2,98")
+	}
+	low, lowErr := strconv.ParseFloat(strings.TrimSpace(tokens[0]), 64)
+	high, highErr := strconv.ParseFloat(strings.TrimSpace(tokens[1]), 64)
+	if lowErr != nil || highErr != nil {
+		return 0, 0, errors.New("should be two comma separated percentiles, e.g. 2,98")
+	}
+	if low < 0 || high > 100 || low >= high {
+		return 0, 0, errors.New("percentiles should be between 0 and 100, the low one lower than the high one")
+	}
+	return low, high, nil
+}
+
+// Tree counting the points added to it by intensity, without storing them, to compute the percentiles of the
+// intensities of a file before tiling it
+type IntensityHistogram struct {
+	counts [256]int64
+}
+
+func NewIntensityHistogram() *IntensityHistogram {
+	return &IntensityHistogram{}
+}
+
+func (h *IntensityHistogram) Build() error {
+	return nil
+}
+
+func (h *IntensityHistogram) GetRootNode() INode {
+	return nil
+}
+
+func (h *IntensityHistogram) IsBuilt() bool {
+	return false
+}
+
+func (h *IntensityHistogram) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	atomic.AddInt64(&h.counts[intensity], 1)
+}
+
+// Returns the lowest intensity which at least the given percentage of the points do not exceed, 0 if no point has
+// been added
+func (h *IntensityHistogram) GetPercentile(percentile float64) uint8 {
+	var total int64
+	for i := range h.counts {
+		total += atomic.LoadInt64(&h.counts[i])
+	}
+	threshold := int64(math.Ceil(percentile / 100 * float64(total)))
+	var cumulated int64
+	for intensity := range h.counts {
+		cumulated += atomic.LoadInt64(&h.counts[intensity])
+		if cumulated >= threshold && cumulated > 0 {
+			return uint8(intensity)
+		}
+	}
+	return 255
+}
+
+// Decorates a tree replacing the color of every point added to it with the color of its intensity along a ramp
+type intensityColorTree struct {
+	ITree
+	ramp ColorRamp
+	// intensities mapped to the ends of the ramp, the ones in between being stretched over the whole ramp
+	min uint8
+	max uint8
+}
+
+// Wraps the given tree so that all the points added to it are colored by their intensity along the given ramp
+func NewIntensityColorTree(tree ITree, ramp ColorRamp) ITree {
+	return NewStretchedIntensityColorTree(tree, ramp, 0, 255)
+}
+
+// Wraps the given tree so that all the points added to it are colored by their intensity along the given ramp, the
+// intensities from min to max being stretched over the whole ramp and the other ones clamped to its ends. The
+// intensities are not stretched if max is not greater than min.
+func NewStretchedIntensityColorTree(tree ITree, ramp ColorRamp, min uint8, max uint8) ITree {
+	if max <= min {
+		min, max = 0, 255
+	}
+	return &intensityColorTree{
+		ITree: tree,
+		ramp:  ramp,
+		min:   min,
+		max:   max,
+	}
+}
+
+func (t *intensityColorTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	r, g, b = t.ramp.GetColor(t.stretch(intensity))
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+}
+
+// Returns the given intensity stretched from the min and max intensities of the tree to the whole 0-255 range
+func (t *intensityColorTree) stretch(intensity uint8) uint8 {
+	if intensity <= t.min {
+		return 0
+	}
+	if intensity >= t.max {
+		return 255
+	}
+	return uint8(math.Round(float64(intensity-t.min) * 255 / float64(t.max-t.min)))
+}
@@ -0,0 +1,77 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"sort"
+	"sync"
+)
+
+// A tree routing the points added to it to a tree per classification, created on demand by a factory, so that every
+// class can be tiled in its own tileset. The layered tree itself has no root node.
+type LayeredTree struct {
+	newTree func() ITree
+	layers  map[uint8]ITree
+	built   bool
+	sync.RWMutex
+}
+
+// Creates a layered tree whose layers are created by the given factory
+func NewLayeredTree(newTree func() ITree) *LayeredTree {
+	return &LayeredTree{
+		newTree: newTree,
+		layers:  make(map[uint8]ITree),
+	}
+}
+
+func (t *LayeredTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	t.RLock()
+	layer, ok := t.layers[classification]
+	t.RUnlock()
+	if !ok {
+		t.Lock()
+		if layer, ok = t.layers[classification]; !ok {
+			layer = t.newTree()
+			t.layers[classification] = layer
+		}
+		t.Unlock()
+	}
+	layer.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+}
+
+// Builds the trees of all the layers
+func (t *LayeredTree) Build() error {
+	for _, classification := range t.GetClassifications() {
+		if err := t.layers[classification].Build(); err != nil {
+			return err
+		}
+	}
+	t.built = true
+	return nil
+}
+
+func (t *LayeredTree) GetRootNode() INode {
+	return nil
+}
+
+func (t *LayeredTree) IsBuilt() bool {
+	return t.built
+}
+
+// Returns the classifications of the points added to the tree, in ascending order
+func (t *LayeredTree) GetClassifications() []uint8 {
+	t.RLock()
+	defer t.RUnlock()
+	classifications := make([]uint8, 0, len(t.layers))
+	for classification := range t.layers {
+		classifications = append(classifications, classification)
+	}
+	sort.Slice(classifications, func(i, j int) bool { return classifications[i] < classifications[j] })
+	return classifications
+}
+
+// Returns the tree holding the points of the given classification, nil if there are none
+func (t *LayeredTree) GetLayer(classification uint8) ITree {
+	t.RLock()
+	defer t.RUnlock()
+	return t.layers[classification]
+}
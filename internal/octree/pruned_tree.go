@@ -0,0 +1,131 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+	"sync"
+)
+
+// Height in pixels and vertical field of view in radians of the reference viewport used to turn screen-space errors
+// into geometric errors, matching a full HD screen and the default camera of Cesium
+const (
+	pruneScreenHeight = 1080
+	pruneFieldOfView  = math.Pi / 3
+)
+
+// Returns the geometric error in meters below which a tile is never refined by a viewer showing the tileset with the
+// given maximum screen-space error, in pixels, from no closer than the given distance, in meters
+func PruningGeometricError(maxScreenError float64, minDistance float64) float64 {
+	return maxScreenError * minDistance * 2 * math.Tan(pruneFieldOfView/2) / pruneScreenHeight
+}
+
+// Decorates a tree merging into their parent the leaf tiles whose content would never be requested, that is the
+// children of the nodes whose geometric error does not exceed the given one. Merging is repeated bottom-up so that
+// over-deep branches collapse into a single tile. The tree is pruned the first time its root is requested once built.
+type prunedTree struct {
+	ITree
+	maxGeometricError float64
+	root              INode
+	once              sync.Once
+}
+
+// Wraps the given tree so that its built root node is pruned according to the given geometric error
+func NewPrunedTree(tree ITree, maxGeometricError float64) ITree {
+	return &prunedTree{
+		ITree:             tree,
+		maxGeometricError: maxGeometricError,
+	}
+}
+
+func (t *prunedTree) GetRootNode() INode {
+	root := t.ITree.GetRootNode()
+	if root == nil || !t.ITree.IsBuilt() {
+		return root
+	}
+	t.once.Do(func() {
+		t.root = newPrunedNode(root, nil, t.maxGeometricError)
+	})
+	return t.root
+}
+
+// A node whose children may have been merged into it
+type prunedNode struct {
+	INode
+	parent   INode
+	children [8]INode
+	points   []*data.Point
+	leaf     bool
+}
+
+func newPrunedNode(node INode, parent INode, maxGeometricError float64) *prunedNode {
+	pruned := &prunedNode{
+		INode:  node,
+		parent: parent,
+		points: node.GetPoints(),
+		leaf:   node.IsLeaf(),
+	}
+	for i, child := range node.GetChildren() {
+		if child != nil {
+			pruned.children[i] = newPrunedNode(child, pruned, maxGeometricError)
+		}
+	}
+
+	if hasRefinedChildren(pruned) && node.ComputeGeometricError() <= maxGeometricError && !hasRefinedGrandchildren(pruned) {
+		pruned.mergeChildren()
+	}
+	return pruned
+}
+
+// Returns true if any child of the node holds points, i.e. if the node is refined into child tiles
+func hasRefinedChildren(node INode) bool {
+	for _, child := range node.GetChildren() {
+		if child != nil && child.TotalNumberOfPoints() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if any child of the node is refined in turn, so that the children are not all leaf tiles
+func hasRefinedGrandchildren(node INode) bool {
+	for _, child := range node.GetChildren() {
+		if child != nil && hasRefinedChildren(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// Moves the points of the children into the node, which becomes a leaf
+func (n *prunedNode) mergeChildren() {
+	points := make([]*data.Point, 0, n.TotalNumberOfPoints())
+	points = append(points, n.points...)
+	for i, child := range n.children {
+		if child != nil {
+			points = append(points, child.GetPoints()...)
+			n.children[i] = nil
+		}
+	}
+	n.points = points
+	n.leaf = true
+}
+
+func (n *prunedNode) GetParent() INode {
+	return n.parent
+}
+
+func (n *prunedNode) GetChildren() [8]INode {
+	return n.children
+}
+
+func (n *prunedNode) GetPoints() []*data.Point {
+	return n.points
+}
+
+func (n *prunedNode) NumberOfPoints() int64 {
+	return int64(len(n.points))
+}
+
+func (n *prunedNode) IsLeaf() bool {
+	return n.leaf
+}
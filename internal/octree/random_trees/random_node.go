@@ -22,7 +22,7 @@ type RandomNode struct {
 	points              []*data.Point
 	internalSrid        int
 	totalNumberOfPoints int64
-	numberOfPoints      int32
+	numberOfPoints      int64
 	tilerOptions        *tiler.TilerOptions
 	leaf                bool
 	initialized         bool
@@ -47,7 +47,7 @@ func NewRandomNode(boundingBox *geometry.BoundingBox, opts *tiler.TilerOptions,
 
 // Adds a Point to the RandomNode eventually propagating it to the RandomNode relevant children
 func (n *RandomNode) AddDataPoint(element *data.Point) {
-	if atomic.LoadInt32(&n.numberOfPoints) == 0 {
+	if atomic.LoadInt64(&n.numberOfPoints) == 0 {
 		n.Lock()
 		for i := uint8(0); i < 8; i++ {
 			if n.children[i] == nil {
@@ -57,10 +57,10 @@ func (n *RandomNode) AddDataPoint(element *data.Point) {
 		n.initialized = true
 		n.Unlock()
 	}
-	if atomic.LoadInt32(&n.numberOfPoints) < n.tilerOptions.MaxNumPointsPerNode {
+	if atomic.LoadInt64(&n.numberOfPoints) < int64(n.tilerOptions.MaxNumPointsPerNode) {
 		n.Lock()
 		n.points = append(n.points, element)
-		atomic.AddInt32(&n.numberOfPoints, 1)
+		atomic.AddInt64(&n.numberOfPoints, 1)
 		n.Unlock()
 	} else {
 		n.children[getOctantFromElement(element, n.boundingBox)].AddDataPoint(element)
@@ -107,7 +107,7 @@ func (n *RandomNode) TotalNumberOfPoints() int64 {
 	return n.totalNumberOfPoints
 }
 
-func (n *RandomNode) NumberOfPoints() int32 {
+func (n *RandomNode) NumberOfPoints() int64 {
 	return n.numberOfPoints
 }
 
@@ -160,12 +160,14 @@ func (n *RandomNode) estimateErrorAsBoundingBoxDiagonal() float64 {
 	var lngA = region[0]
 	var lngB = region[2]
 	latA = region[1]
-	return 6371000 * math.Acos(math.Cos(latA)*math.Cos(latB)*math.Cos(lngB-lngA)+math.Sin(latA)*math.Sin(latB))
+	// rounding can push the cosine of the angle slightly out of range for very small boxes
+	cosine := math.Max(-1, math.Min(1, math.Cos(latA)*math.Cos(latB)*math.Cos(lngB-lngA)+math.Sin(latA)*math.Sin(latB)))
+	return 6371000 * math.Acos(cosine)
 }
 
 func (n *RandomNode) estimateErrorAsDensityDifference() float64 {
 	volume := n.boundingBox.GetWGS84Volume()
-	totalRenderedPoints := int64(n.NumberOfPoints())
+	totalRenderedPoints := n.NumberOfPoints()
 	parent := n.GetParent()
 	for parent != nil {
 		for _, e := range parent.GetPoints() {
@@ -175,6 +177,9 @@ func (n *RandomNode) estimateErrorAsDensityDifference() float64 {
 		}
 		parent = parent.(*RandomNode).parent
 	}
+	if volume <= 0 || totalRenderedPoints == 0 {
+		return 0
+	}
 	densityWithAllPoints := math.Pow(volume/float64(totalRenderedPoints+n.TotalNumberOfPoints()-int64(n.NumberOfPoints())), 0.333)
 	densityWithOnlyThisTile := math.Pow(volume/float64(totalRenderedPoints), 0.333)
 
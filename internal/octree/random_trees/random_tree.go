@@ -2,6 +2,7 @@ package random_trees
 
 import (
 	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
@@ -9,10 +10,12 @@ import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/point_loader"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"log"
-	"runtime"
 	"sync"
 )
 
+// Minimum side of the root node box, in degrees along the horizontal axes and in meters along the vertical one
+const minRootSide = 1e-6
+
 // Represents an RandomTree of points and contains all information needed
 // to propagate points in the tree
 type RandomTree struct {
@@ -57,6 +60,9 @@ func (t *RandomTree) Build() error {
 	var wg sync.WaitGroup
 	t.launchParallelPointLoaders(&wg)
 	wg.Wait()
+	if err := t.opts.Cancellation.Err(); err != nil {
+		return err
+	}
 
 	t.built = true
 
@@ -64,14 +70,14 @@ func (t *RandomTree) Build() error {
 }
 
 func (t *RandomTree) init() {
-	box := t.GetBounds()
+	box := geometry.ExtendDegenerateBounds(t.GetBounds(), minRootSide)
 	node := NewRandomNode(geometry.NewBoundingBox(box[0], box[1], box[2], box[3], box[4], box[5]), t.opts, nil)
 	t.rootNode = node
 	t.InitializeLoader()
 }
 
 func (t *RandomTree) launchParallelPointLoaders(waitGroup *sync.WaitGroup) {
-	N := runtime.NumCPU()
+	N := tiler.WorkersOrNumCPU(t.opts.InsertWorkers)
 
 	for i := 0; i < N; i++ {
 		waitGroup.Add(1)
@@ -80,7 +86,10 @@ func (t *RandomTree) launchParallelPointLoaders(waitGroup *sync.WaitGroup) {
 }
 
 func (t *RandomTree) launchPointLoader(waitGroup *sync.WaitGroup) {
-	for {
+	for i := 0; ; i++ {
+		if i%cancellation.CheckInterval == 0 && t.opts.Cancellation.IsCancelled() {
+			break
+		}
 		val, shouldContinue := t.Loader.GetNext()
 		if val != nil {
 			t.rootNode.AddDataPoint(val)
@@ -100,8 +109,10 @@ func (t *RandomTree) IsBuilt() bool {
 	return t.built
 }
 
-func (t *RandomTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) {
-	t.Loader.AddPoint(t.getPointFromRawData(coordinate, r, g, b, intensity, classification, srid))
+func (t *RandomTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	point := t.getPointFromRawData(coordinate, r, g, b, intensity, classification, srid)
+	point.Attributes = attributes
+	t.Loader.AddPoint(point)
 }
 
 func (t *RandomTree) getPointFromRawData(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) *data.Point {
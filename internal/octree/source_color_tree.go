@@ -0,0 +1,66 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+)
+
+// Hue step between the colors of consecutive sources, the golden ratio conjugate spreads them evenly on the color wheel
+const sourceHueStep = 0.618033988749895
+
+// Decorates a tree replacing the color of every point added to it with the color of its source
+type sourceColorTree struct {
+	ITree
+	r uint8
+	g uint8
+	b uint8
+}
+
+// Wraps the given tree so that all the points added to it get the color of the source with the given index
+func NewSourceColorTree(tree ITree, sourceIndex int) ITree {
+	r, g, b := SourceColor(sourceIndex)
+	return &sourceColorTree{
+		ITree: tree,
+		r:     r,
+		g:     g,
+		b:     b,
+	}
+}
+
+func (t *sourceColorTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	t.ITree.AddPoint(coordinate, t.r, t.g, t.b, intensity, classification, srid, attributes)
+}
+
+// Returns the color assigned to the source with the given index. Colors are bright and saturated and consecutive
+// sources get far apart hues, so that adjacent deliveries are easy to tell apart.
+func SourceColor(sourceIndex int) (uint8, uint8, uint8) {
+	hue := math.Mod(float64(sourceIndex)*sourceHueStep, 1) * 6
+	return hsvToRgb(hue, 0.75, 0.95)
+}
+
+// Converts the given color, whose hue is in the [0, 6) range and saturation and value in [0, 1], to RGB
+func hsvToRgb(hue float64, saturation float64, value float64) (uint8, uint8, uint8) {
+	chroma := value * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue, 2)-1))
+	var r, g, b float64
+	switch int(hue) {
+	case 0:
+		r, g = chroma, x
+	case 1:
+		r, g = x, chroma
+	case 2:
+		g, b = chroma, x
+	case 3:
+		g, b = x, chroma
+	case 4:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	m := value - chroma
+	return toColorComponent(r + m), toColorComponent(g + m), toColorComponent(b + m)
+}
+
+func toColorComponent(value float64) uint8 {
+	return uint8(math.Round(value * 255))
+}
@@ -0,0 +1,311 @@
+package octree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"sort"
+	"sync"
+)
+
+// Max number of levels of new tiles created below a tile of the tree, bounding the subdivision of the tiles holding
+// many points at the same position
+const maxSplitLevels = 16
+
+// Decorates a tree splitting the tiles whose estimated content size exceeds the given max size despite the settings of
+// the tree, e.g. the leaves of dense pockets that reached the min cell size. The points a tile cannot hold are pushed
+// down to its octants, into the existing children or into new child tiles whose geometric error is half the one of the
+// tile, which are split in turn if needed. The points kept by a split tile are spread over its volume, taken evenly
+// along the Morton order. The tree is split the first time its root is requested once built.
+type SplitTree struct {
+	ITree
+	maxPoints int
+	root      INode
+	once      sync.Once
+	// number of tiles of the tree that have been split and of the new tiles created by the splits
+	splitTiles   int
+	createdTiles int
+}
+
+// Wraps the given tree so that the tiles of its built root node exceeding the given max size are split, given the
+// estimated size in bytes of every point and of the overhead of every tile
+func NewSplitTree(tree ITree, maxSize int64, pointSize int64, tileSize int64) *SplitTree {
+	maxPoints := 1
+	if points := (maxSize - tileSize) / pointSize; points > 1 {
+		maxPoints = int(points)
+	}
+	return &SplitTree{
+		ITree:     tree,
+		maxPoints: maxPoints,
+	}
+}
+
+func (t *SplitTree) GetRootNode() INode {
+	root := t.ITree.GetRootNode()
+	if root == nil || !t.ITree.IsBuilt() {
+		return root
+	}
+	t.once.Do(func() {
+		// no tile of a tree holding fewer points than a tile can hold needs to be split
+		if root.TotalNumberOfPoints() <= int64(t.maxPoints) {
+			t.root = root
+			return
+		}
+		t.root = t.newSplitNode(root, nil, nil)
+	})
+	return t.root
+}
+
+// Returns the max number of points of the tiles
+func (t *SplitTree) GetMaxPoints() int {
+	return t.maxPoints
+}
+
+// Returns the number of tiles of the tree that have been split and the number of new tiles created to split them
+func (t *SplitTree) GetSplitTiles() (int, int) {
+	return t.splitTiles, t.createdTiles
+}
+
+// A node of the tree that may have been split or may have received the points of a split parent
+type splitNode struct {
+	INode
+	parent   INode
+	children [8]INode
+	// points of the node once split or extended, nil if left untouched
+	points []*data.Point
+	total  int64
+	leaf   bool
+}
+
+func (t *SplitTree) newSplitNode(node INode, parent INode, extra []*data.Point) *splitNode {
+	split := &splitNode{
+		INode:  node,
+		parent: parent,
+		total:  node.TotalNumberOfPoints() + int64(len(extra)),
+		leaf:   node.IsLeaf(),
+	}
+	// the empty nodes receiving points become new tiles
+	if node.TotalNumberOfPoints() == 0 && len(extra) > 0 {
+		t.createdTiles++
+	}
+	// the outliers of an overflow node lie outside of the volume of its only child, hence they cannot be pushed down
+	_, overflow := node.(OverflowNode)
+	var pushed [8][]*data.Point
+	if len(extra) > 0 || (!overflow && node.NumberOfPoints() > int64(t.maxPoints)) {
+		split.points = append(append([]*data.Point{}, node.GetPoints()...), extra...)
+		if !overflow && len(split.points) > t.maxPoints {
+			split.points, pushed = t.split(split.points, node.GetBoundingBox())
+		}
+	}
+
+	for i, child := range node.GetChildren() {
+		if child != nil {
+			split.children[i] = t.newSplitNode(child, split, pushed[i])
+		} else if len(pushed[i]) > 0 {
+			box := geometry.NewBoundingBoxFromParent(node.GetBoundingBox(), getOctantIndex(i))
+			split.children[i] = t.newSplitTile(pushed[i], box, split, node.ComputeGeometricError()/2, node.GetInternalSrid(), 1)
+		}
+	}
+	for _, child := range split.children {
+		if child != nil && child.TotalNumberOfPoints() > 0 {
+			split.leaf = false
+		}
+	}
+	return split
+}
+
+// Keeps the max number of points of a tile among the given ones, evenly taken along their Morton order in the given
+// box, returning them with the other points grouped by the octant of the box holding them
+func (t *SplitTree) split(points []*data.Point, box *geometry.BoundingBox) ([]*data.Point, [8][]*data.Point) {
+	t.splitTiles++
+	codes := make([]uint64, len(points))
+	order := make([]int, len(points))
+	for i, point := range points {
+		codes[i] = getMortonCode(point, box)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return codes[order[i]] < codes[order[j]] })
+
+	kept := make([]*data.Point, 0, t.maxPoints)
+	var pushed [8][]*data.Point
+	next := 0
+	for i, index := range order {
+		// the i-th point is kept if it is the first one reaching the next evenly spaced position
+		if next < t.maxPoints && i*t.maxPoints >= next*len(points) {
+			kept = append(kept, points[index])
+			next++
+			continue
+		}
+		octant := getPointOctant(points[index], box)
+		pushed[octant] = append(pushed[octant], points[index])
+	}
+	return kept, pushed
+}
+
+// A new tile holding the points pushed down by a split parent
+type splitTile struct {
+	parent         INode
+	children       [8]INode
+	points         []*data.Point
+	total          int64
+	boundingBox    *geometry.BoundingBox
+	geometricError float64
+	srid           int
+}
+
+func (t *SplitTree) newSplitTile(points []*data.Point, box *geometry.BoundingBox, parent INode, geometricError float64, srid int, level int) *splitTile {
+	t.createdTiles++
+	tile := &splitTile{
+		parent:         parent,
+		points:         points,
+		total:          int64(len(points)),
+		boundingBox:    box,
+		geometricError: geometricError,
+		srid:           srid,
+	}
+	if len(points) <= t.maxPoints || level >= maxSplitLevels {
+		return tile
+	}
+
+	var pushed [8][]*data.Point
+	tile.points, pushed = t.split(points, box)
+	for i := range pushed {
+		if len(pushed[i]) > 0 {
+			childBox := geometry.NewBoundingBoxFromParent(box, getOctantIndex(i))
+			tile.children[i] = t.newSplitTile(pushed[i], childBox, tile, geometricError/2, srid, level+1)
+		}
+	}
+	return tile
+}
+
+// Returns the index of the octant of the given box holding the given point, numbered like the children of the nodes
+func getPointOctant(point *data.Point, box *geometry.BoundingBox) int {
+	octant := 0
+	if point.X > box.Xmid {
+		octant += 1
+	}
+	if point.Y > box.Ymid {
+		octant += 2
+	}
+	if point.Z > box.Zmid {
+		octant += 4
+	}
+	return octant
+}
+
+func getOctantIndex(octant int) *uint8 {
+	index := uint8(octant)
+	return &index
+}
+
+// Returns the Morton code of the position of the given point in the given box, with 21 bits per axis
+func getMortonCode(point *data.Point, box *geometry.BoundingBox) uint64 {
+	quantize := func(value float64, min float64, max float64) uint64 {
+		if max <= min {
+			return 0
+		}
+		q := (value - min) / (max - min) * (1<<21 - 1)
+		if q < 0 {
+			return 0
+		} else if q > 1<<21-1 {
+			return 1<<21 - 1
+		}
+		return uint64(q)
+	}
+	x, y, z := quantize(point.X, box.Xmin, box.Xmax), quantize(point.Y, box.Ymin, box.Ymax), quantize(point.Z, box.Zmin, box.Zmax)
+	var code uint64
+	for bit := uint(0); bit < 21; bit++ {
+		code |= (x>>bit&1)<<(3*bit) | (y>>bit&1)<<(3*bit+1) | (z>>bit&1)<<(3*bit+2)
+	}
+	return code
+}
+
+func (n *splitNode) GetParent() INode {
+	return n.parent
+}
+
+func (n *splitNode) GetChildren() [8]INode {
+	return n.children
+}
+
+func (n *splitNode) GetPoints() []*data.Point {
+	if n.points == nil {
+		return n.INode.GetPoints()
+	}
+	return n.points
+}
+
+func (n *splitNode) NumberOfPoints() int64 {
+	if n.points == nil {
+		return n.INode.NumberOfPoints()
+	}
+	return int64(len(n.points))
+}
+
+func (n *splitNode) TotalNumberOfPoints() int64 {
+	return n.total
+}
+
+func (n *splitNode) IsLeaf() bool {
+	return n.leaf
+}
+
+// Returns true for the empty nodes that received the points of their split parent, which are written as tiles
+func (n *splitNode) IsInitialized() bool {
+	return n.INode.IsInitialized() || n.total > 0
+}
+
+func (n *splitTile) AddDataPoint(element *data.Point) {}
+
+func (n *splitTile) GetInternalSrid() int {
+	return n.srid
+}
+
+func (n *splitTile) IsRoot() bool {
+	return false
+}
+
+func (n *splitTile) GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error) {
+	return converter.Convert2DBoundingboxToWGS84Region(n.boundingBox, n.srid)
+}
+
+func (n *splitTile) GetChildren() [8]INode {
+	return n.children
+}
+
+func (n *splitTile) GetPoints() []*data.Point {
+	return n.points
+}
+
+func (n *splitTile) TotalNumberOfPoints() int64 {
+	return n.total
+}
+
+func (n *splitTile) NumberOfPoints() int64 {
+	return int64(len(n.points))
+}
+
+func (n *splitTile) IsLeaf() bool {
+	for _, child := range n.children {
+		if child != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *splitTile) IsInitialized() bool {
+	return true
+}
+
+func (n *splitTile) ComputeGeometricError() float64 {
+	return n.geometricError
+}
+
+func (n *splitTile) GetParent() INode {
+	return n.parent
+}
+
+func (n *splitTile) GetBoundingBox() *geometry.BoundingBox {
+	return n.boundingBox
+}
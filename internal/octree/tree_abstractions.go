@@ -11,7 +11,7 @@ type ITree interface {
 	GetRootNode() INode
 	IsBuilt() bool
 	// Adds a Point to the Tree
-	AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int)
+	AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32)
 }
 
 type INode interface {
@@ -22,10 +22,45 @@ type INode interface {
 	GetChildren() [8]INode
 	GetPoints() []*data.Point
 	TotalNumberOfPoints() int64
-	NumberOfPoints() int32
+	NumberOfPoints() int64
 	IsLeaf() bool
 	IsInitialized() bool
 	ComputeGeometricError() float64
 	GetParent() INode
 	GetBoundingBox() *geometry.BoundingBox
 }
+
+// A root node storing the points lying far from the bulk of the point cloud, which is held by its core node child
+type OverflowNode interface {
+	INode
+	GetCoreNode() INode
+}
+
+// A leaf node holding the points of sibling leaf tiles bundled in a single tile content
+type BundleNode interface {
+	INode
+	// Returns the bundled leaf nodes, whose points the bundle holds
+	GetBundledNodes() []INode
+}
+
+// A node keeping its points through a grid of cells, e.g. to report how the grid sampled the points
+type CellNode interface {
+	INode
+	// Returns the size of the cells of the node
+	GetCellSize() float64
+	// Returns the number of cells that held points once the tree was built
+	NumberOfCells() int
+}
+
+// A tree needing the points to be added in more than one pass, e.g. to size its nodes before storing their points.
+// Once all the points have been added the pass is ended, and if requested all of them are added again before the tree
+// is built.
+type MultiPassTree interface {
+	ITree
+	// Ends the current pass returning true if the points have to be added again
+	EndPass() (bool, error)
+	// Releases the resources held by the tree once its export is complete
+	Close() error
+	// Returns the first error reading back the points of the nodes, whose tiles are then incomplete, nil if none
+	Err() error
+}
@@ -0,0 +1,210 @@
+package two_pass_tree
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// A node of a TwoPassTree, whose points are stored in its spool file. Nodes without children are leaves and keep all
+// the points reaching them.
+type TwoPassNode struct {
+	tree                *TwoPassTree
+	parent              *TwoPassNode
+	boundingBox         *geometry.BoundingBox
+	children            [8]octree.INode
+	keepRatio           float64
+	spoolFile           string
+	buffer              []byte
+	numberOfPoints      int64
+	totalNumberOfPoints int64
+	leaf                bool
+	sync.Mutex
+}
+
+func newTwoPassNode(tree *TwoPassTree, boundingBox *geometry.BoundingBox, parent *TwoPassNode, index int) *TwoPassNode {
+	return &TwoPassNode{
+		tree:        tree,
+		parent:      parent,
+		boundingBox: boundingBox,
+		keepRatio:   1,
+		spoolFile:   filepath.Join(tree.folder, strconv.Itoa(index)+".points"),
+	}
+}
+
+// Returns true if the node keeps a point reaching it. The first point is always kept so that every node holding
+// points in its branch also holds points of its own.
+func (n *TwoPassNode) keeps() bool {
+	return n.keepRatio >= 1 || atomic.LoadInt64(&n.numberOfPoints) == 0 || rand.Float64() < n.keepRatio
+}
+
+// Buffers the given point returning the number of bytes it takes
+func (n *TwoPassNode) spool(point *data.Point) int64 {
+	n.Lock()
+	defer n.Unlock()
+	size := len(n.buffer)
+	n.buffer = data.EncodePoint(n.buffer, point)
+	atomic.AddInt64(&n.numberOfPoints, 1)
+	return int64(len(n.buffer) - size)
+}
+
+// Appends the buffered points to the spool file returning the number of bytes written
+func (n *TwoPassNode) flush() (int64, error) {
+	n.Lock()
+	defer n.Unlock()
+	if len(n.buffer) == 0 {
+		return 0, nil
+	}
+	file, err := os.OpenFile(n.spoolFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return 0, err
+	}
+	_, err = file.Write(n.buffer)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	flushed := int64(len(n.buffer))
+	n.buffer = nil
+	return flushed, err
+}
+
+// Counts the points of the branch of the node, the nodes whose branch holds no points being removed
+func (n *TwoPassNode) countPoints() int64 {
+	n.totalNumberOfPoints = n.numberOfPoints
+	n.leaf = true
+	for i, child := range n.children {
+		if child == nil {
+			continue
+		}
+		if total := child.(*TwoPassNode).countPoints(); total > 0 {
+			n.totalNumberOfPoints += total
+			n.leaf = false
+		} else {
+			n.children[i] = nil
+		}
+	}
+	return n.totalNumberOfPoints
+}
+
+// Returns the first node of the branch of the given point, starting from this node, that keeps it
+func (n *TwoPassNode) getKeepingNode(point *data.Point) *TwoPassNode {
+	node := n
+	for !node.keeps() {
+		node = node.children[getOctant(point, node.boundingBox)].(*TwoPassNode)
+	}
+	return node
+}
+
+// Adds the point to the first node of its branch keeping it, writing it directly in the spool file of the node. A
+// failed write is recorded by the tree.
+func (n *TwoPassNode) AddDataPoint(element *data.Point) {
+	node := n.getKeepingNode(element)
+	node.spool(element)
+	if _, err := node.flush(); err != nil {
+		n.tree.setError(err)
+	}
+}
+
+func (n *TwoPassNode) GetParent() octree.INode {
+	if n.parent == nil {
+		return nil
+	}
+	return n.parent
+}
+
+func (n *TwoPassNode) GetInternalSrid() int {
+	return 4326
+}
+
+func (n *TwoPassNode) GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error) {
+	return converter.Convert2DBoundingboxToWGS84Region(n.boundingBox, n.GetInternalSrid())
+}
+
+func (n *TwoPassNode) GetBoundingBox() *geometry.BoundingBox {
+	return n.boundingBox
+}
+
+func (n *TwoPassNode) GetChildren() [8]octree.INode {
+	return n.children
+}
+
+// Reads the points of the node from its spool file. The points are not cached, so that only the nodes being exported
+// are held in memory. A failed read returns no points and is recorded by the tree.
+func (n *TwoPassNode) GetPoints() []*data.Point {
+	if atomic.LoadInt64(&n.numberOfPoints) == 0 {
+		return nil
+	}
+	content, err := ioutil.ReadFile(n.spoolFile)
+	if err != nil {
+		n.tree.setError(err)
+		return nil
+	}
+	points := make([]*data.Point, 0, n.numberOfPoints)
+	for offset := 0; offset < len(content); {
+		point, size := data.DecodePoint(content[offset:])
+		points = append(points, point)
+		offset += size
+	}
+	return points
+}
+
+func (n *TwoPassNode) TotalNumberOfPoints() int64 {
+	return n.totalNumberOfPoints
+}
+
+func (n *TwoPassNode) NumberOfPoints() int64 {
+	return atomic.LoadInt64(&n.numberOfPoints)
+}
+
+func (n *TwoPassNode) IsLeaf() bool {
+	return n.leaf
+}
+
+func (n *TwoPassNode) IsRoot() bool {
+	return n.parent == nil
+}
+
+func (n *TwoPassNode) IsInitialized() bool {
+	return n.totalNumberOfPoints > 0
+}
+
+// Computes the geometric error of the node as the difference between the spacing of the points shown with the tile
+// and the one of all the points of its branch. As the points of the ancestors are on disk, the number of the ones lying
+// in the node is estimated from the share of the points of their branches held by the branch of the node.
+func (n *TwoPassNode) ComputeGeometricError() float64 {
+	if n.IsRoot() && n.IsLeaf() {
+		return n.estimateErrorAsBoundingBoxDiagonal()
+	}
+
+	volume := n.boundingBox.GetWGS84Volume()
+	renderedPoints := float64(n.numberOfPoints)
+	for parent := n.parent; parent != nil; parent = parent.parent {
+		renderedPoints += float64(parent.numberOfPoints) * float64(n.totalNumberOfPoints) / float64(parent.totalNumberOfPoints)
+	}
+	if volume <= 0 || renderedPoints == 0 {
+		return 0
+	}
+	spacingWithAllPoints := math.Pow(volume/(renderedPoints+float64(n.totalNumberOfPoints-n.numberOfPoints)), 0.333)
+	spacingWithOnlyThisTile := math.Pow(volume/renderedPoints, 0.333)
+
+	return spacingWithOnlyThisTile - spacingWithAllPoints
+}
+
+func (n *TwoPassNode) estimateErrorAsBoundingBoxDiagonal() float64 {
+	regionBox, _ := proj4_coordinate_converter.NewProj4CoordinateConverter().Convert2DBoundingboxToWGS84Region(n.boundingBox, n.GetInternalSrid())
+	region := regionBox.GetAsArray()
+	latA, latB, lngA, lngB := region[1], region[3], region[0], region[2]
+	// rounding can push the cosine of the angle slightly out of range for very small boxes
+	cosine := math.Max(-1, math.Min(1, math.Cos(latA)*math.Cos(latB)*math.Cos(lngB-lngA)+math.Sin(latA)*math.Sin(latB)))
+	return 6371000 * math.Acos(cosine)
+}
@@ -0,0 +1,265 @@
+package two_pass_tree
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"io/ioutil"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Minimum side of the root node box, in degrees along the horizontal axes and in meters along the vertical one
+const minRootSide = 1e-6
+
+// Max number of points sampled in the first pass to estimate the distribution of the points among the nodes
+const skeletonSampleSize = 1 << 20
+
+// Max depth of the nodes of the skeleton, reached when the points are more concentrated than the sample can tell
+const maxSkeletonDepth = 24
+
+// Max number of bytes of the spooled points buffered in memory, across all the nodes, before being appended to their
+// files
+const spoolMemoryBudget = 256 * 1024 * 1024
+
+// A tree trading IO for memory so that huge point clouds can be tiled on machines whose memory cannot hold all their
+// points. In the first pass the points are only sampled, along with their bounds and count, to build the skeleton of
+// the tree and to decide the fraction of the points reaching every node that the node keeps. In the second pass every
+// point descends the skeleton until kept by a node, and is appended to the spool file of the node in the spool folder.
+// The points of the nodes are read back from their files every time they are requested.
+type TwoPassTree struct {
+	maxPointsPerNode    int32
+	spoolFolder         string
+	coordinateConverter converters.CoordinateConverter
+	elevationCorrector  converters.ElevationCorrector
+	pass                int
+	rootNode            *TwoPassNode
+	nodes               []*TwoPassNode
+	folder              string
+	built               bool
+	// bounds, count and sample of the points added in the first pass
+	bounds []float64
+	count  int64
+	sample [][3]float64
+	// bytes of the points buffered by all the nodes
+	buffered int64
+	flush    sync.Mutex
+	// first error spooling the points or reading them back, returned when the pass ends or the tree is exported
+	err error
+	sync.Mutex
+}
+
+// Builds an empty TwoPassTree whose nodes keep about the given number of points each and whose spool files are
+// written in a temporary folder created in the given one, in the system temporary folder if empty
+func NewTwoPassTree(maxPointsPerNode int32, spoolFolder string, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) octree.ITree {
+	return &TwoPassTree{
+		maxPointsPerNode:    maxPointsPerNode,
+		spoolFolder:         spoolFolder,
+		coordinateConverter: coordinateConverter,
+		elevationCorrector:  elevationCorrector,
+		pass:                1,
+		bounds:              []float64{math.MaxFloat64, -math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64},
+	}
+}
+
+func (t *TwoPassTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	point := t.getPointFromRawData(coordinate, r, g, b, intensity, classification, srid)
+	point.Attributes = attributes
+	if t.pass == 1 {
+		t.samplePoint(point)
+	} else {
+		t.spoolPoint(point)
+	}
+}
+
+// Extends the bounds with the given point, keeping it in the sample with a probability ensuring that the sample is a
+// uniform pick among all the points added so far
+func (t *TwoPassTree) samplePoint(point *data.Point) {
+	t.Lock()
+	defer t.Unlock()
+	t.bounds[0] = math.Min(t.bounds[0], point.X)
+	t.bounds[1] = math.Max(t.bounds[1], point.X)
+	t.bounds[2] = math.Min(t.bounds[2], point.Y)
+	t.bounds[3] = math.Max(t.bounds[3], point.Y)
+	t.bounds[4] = math.Min(t.bounds[4], point.Z)
+	t.bounds[5] = math.Max(t.bounds[5], point.Z)
+	t.count++
+	coordinates := [3]float64{point.X, point.Y, point.Z}
+	if len(t.sample) < skeletonSampleSize {
+		t.sample = append(t.sample, coordinates)
+	} else if index := rand.Int63n(t.count); index < skeletonSampleSize {
+		t.sample[index] = coordinates
+	}
+}
+
+// Appends the point to the first node of its branch keeping it, flushing the buffers of all the nodes if they exceed
+// the memory budget
+func (t *TwoPassTree) spoolPoint(point *data.Point) {
+	node := t.rootNode.getKeepingNode(point)
+	if atomic.AddInt64(&t.buffered, node.spool(point)) > spoolMemoryBudget {
+		t.flushNodes()
+	}
+}
+
+// Appends the points buffered by the nodes to their spool files
+func (t *TwoPassTree) flushNodes() {
+	t.flush.Lock()
+	defer t.flush.Unlock()
+	if atomic.LoadInt64(&t.buffered) <= spoolMemoryBudget {
+		// already flushed by another goroutine
+		return
+	}
+	for _, node := range t.nodes {
+		flushed, err := node.flush()
+		atomic.AddInt64(&t.buffered, -flushed)
+		if err != nil {
+			t.setError(err)
+		}
+	}
+}
+
+func (t *TwoPassTree) setError(err error) {
+	t.Lock()
+	if t.err == nil {
+		t.err = err
+	}
+	t.Unlock()
+}
+
+// Ends the first pass building the skeleton of the tree, and requesting a second pass unless no points have been
+// added. Ends the second pass writing the buffered points in the spool files.
+func (t *TwoPassTree) EndPass() (bool, error) {
+	if t.pass == 1 {
+		if t.count == 0 {
+			return false, nil
+		}
+		folder, err := ioutil.TempDir(t.spoolFolder, "gocesiumtiler-spool")
+		if err != nil {
+			return false, err
+		}
+		t.folder = folder
+		t.buildSkeleton()
+		t.sample = nil
+		t.pass = 2
+		return true, nil
+	}
+
+	for _, node := range t.nodes {
+		if _, err := node.flush(); err != nil {
+			t.setError(err)
+		}
+	}
+	atomic.StoreInt64(&t.buffered, 0)
+	return false, t.err
+}
+
+// Creates the nodes of the tree down to the ones expected to hold less than the max number of points, every node
+// keeping the fraction of the points reaching it that makes it hold about the max number of points
+func (t *TwoPassTree) buildSkeleton() {
+	box := geometry.ExtendDegenerateBounds(t.bounds, minRootSide)
+	boundingBox := geometry.NewBoundingBox(box[0], box[1], box[2], box[3], box[4], box[5])
+	t.rootNode = t.newNode(boundingBox, nil)
+	// every point of the sample stands for this number of points
+	weight := float64(t.count) / float64(len(t.sample))
+	t.splitNode(t.rootNode, t.sample, weight, 0)
+}
+
+func (t *TwoPassTree) splitNode(node *TwoPassNode, sample [][3]float64, weight float64, depth int) {
+	expected := float64(len(sample)) * weight
+	if expected <= float64(t.maxPointsPerNode) || depth == maxSkeletonDepth {
+		return
+	}
+
+	node.keepRatio = float64(t.maxPointsPerNode) / expected
+	var octants [8][][3]float64
+	for _, coordinates := range sample {
+		octant := getOctant(&data.Point{X: coordinates[0], Y: coordinates[1], Z: coordinates[2]}, node.boundingBox)
+		octants[octant] = append(octants[octant], coordinates)
+	}
+	for i := uint8(0); i < 8; i++ {
+		child := t.newNode(geometry.NewBoundingBoxFromParent(node.boundingBox, &i), node)
+		node.children[i] = child
+		// only the points not kept by the node reach its children
+		t.splitNode(child, octants[i], weight*(1-node.keepRatio), depth+1)
+	}
+}
+
+func (t *TwoPassTree) newNode(boundingBox *geometry.BoundingBox, parent *TwoPassNode) *TwoPassNode {
+	node := newTwoPassNode(t, boundingBox, parent, len(t.nodes))
+	t.nodes = append(t.nodes, node)
+	return node
+}
+
+// Builds the tree once the second pass has ended, counting the spooled points of every node
+func (t *TwoPassTree) Build() error {
+	if t.built {
+		return errors.New("octree already built")
+	}
+	if t.err != nil {
+		return t.err
+	}
+	if t.pass == 1 && t.count > 0 {
+		return errors.New("the points have not been added in the second pass")
+	}
+	if t.rootNode != nil {
+		t.rootNode.countPoints()
+	}
+	t.built = true
+	return nil
+}
+
+// Returns the first error reading back the points of the nodes from their spool files
+func (t *TwoPassTree) Err() error {
+	t.Lock()
+	defer t.Unlock()
+	return t.err
+}
+
+// Removes the spool files of the nodes
+func (t *TwoPassTree) Close() error {
+	if t.folder == "" {
+		return nil
+	}
+	return os.RemoveAll(t.folder)
+}
+
+func (t *TwoPassTree) GetRootNode() octree.INode {
+	if t.rootNode == nil {
+		return nil
+	}
+	return t.rootNode
+}
+
+func (t *TwoPassTree) IsBuilt() bool {
+	return t.built
+}
+
+func (t *TwoPassTree) getPointFromRawData(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int) *data.Point {
+	tr, err := t.coordinateConverter.ConvertCoordinateSrid(srid, 4326, *coordinate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return data.NewPoint(tr.X, tr.Y, t.elevationCorrector.CorrectElevation(tr.X, tr.Y, tr.Z), r, g, b, intensity, classification)
+}
+
+// Returns the index of the octant of the given box that contains the given point
+func getOctant(point *data.Point, bbox *geometry.BoundingBox) uint8 {
+	var result uint8 = 0
+	if point.X > bbox.Xmid {
+		result += 1
+	}
+	if point.Y > bbox.Ymid {
+		result += 2
+	}
+	if point.Z > bbox.Zmid {
+		result += 4
+	}
+	return result
+}
@@ -0,0 +1,195 @@
+package picking
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/csv"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Level of detail selecting all the tiles, i.e. the full resolution of the tileset
+const FullResolution = -1
+
+// Radius in meters of the sphere the positions are projected on, the WGS84 equatorial radius
+const earthRadius = 6378137.0
+
+// Point of a tree close to a queried position
+type Neighbor struct {
+	// Distance in meters of the point from the queried position
+	Distance       float64
+	Longitude      float64
+	Latitude       float64
+	Height         float64
+	Classification uint8
+	Intensity      uint8
+	R, G, B        uint8
+	// Depth of the tile the point has been picked from, 0 for the root
+	Level int
+}
+
+// Parses a position given as a comma separated longitude, latitude and ellipsoidal height, e.g. 12.49,41.89,35.2
+func ParsePosition(value string) (float64, float64, float64, error) {
+	coordinates := strings.Split(value, ",")
+	if len(coordinates) != 3 {
+		return 0, 0, 0, errors.New("the position should be a comma separated longitude, latitude and height")
+	}
+	var position [3]float64
+	for i, coordinate := range coordinates {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(coordinate), 64)
+		if err != nil {
+			return 0, 0, 0, errors.New("invalid coordinate " + strings.TrimSpace(coordinate) + " of the position")
+		}
+		position[i] = parsed
+	}
+	if math.Abs(position[0]) > 180 || math.Abs(position[1]) > 90 {
+		return 0, 0, 0, errors.New("the longitude and latitude of the position should be degrees")
+	}
+	return position[0], position[1], position[2], nil
+}
+
+// Node of the tree to visit, with the lower bound of the distance of its points from the queried position
+type candidate struct {
+	node     octree.INode
+	level    int
+	distance float64
+}
+
+// Nodes to visit, the closest first
+type candidates []*candidate
+
+func (c candidates) Len() int            { return len(c) }
+func (c candidates) Less(i, j int) bool  { return c[i].distance < c[j].distance }
+func (c candidates) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *candidates) Push(x interface{}) { *c = append(*c, x.(*candidate)) }
+func (c *candidates) Pop() interface{} {
+	old := *c
+	last := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return last
+}
+
+// Closest points found so far, the farthest first so that it is replaced by closer ones
+type neighbors []*Neighbor
+
+func (n neighbors) Len() int            { return len(n) }
+func (n neighbors) Less(i, j int) bool  { return n[i].Distance > n[j].Distance }
+func (n neighbors) Swap(i, j int)       { n[i], n[j] = n[j], n[i] }
+func (n *neighbors) Push(x interface{}) { *n = append(*n, x.(*Neighbor)) }
+func (n *neighbors) Pop() interface{} {
+	old := *n
+	last := old[len(old)-1]
+	*n = old[:len(old)-1]
+	return last
+}
+
+// Returns the given number of points of the given tree closest to the given position, sorted by distance, considering
+// the points of the given level of detail, i.e. the ones of the nodes down to the given depth, or all of them for
+// FullResolution. The points of the tree must be EPSG:4326 coordinates, as the ones of a mounted tileset, and the
+// distances are measured on the plane tangent to the position, accurate up to a few kilometers. The nodes are visited
+// from the closest one and the ones farther than the points found are skipped. With replace true the points that the
+// tiles repeat from their ancestors, as in the tilesets refined with the REPLACE mode, are picked once.
+func Nearest(root octree.INode, lon float64, lat float64, height float64, count int, level int, replace bool, cancellation *cancellation.Token) ([]*Neighbor, error) {
+	if root.GetInternalSrid() != 4326 {
+		return nil, errors.New("points can only be picked from trees of EPSG:4326 coordinates")
+	}
+	if count <= 0 {
+		return nil, errors.New("the number of points to pick should be positive")
+	}
+	metersPerDegree := earthRadius * math.Pi / 180
+	scaleX := metersPerDegree * math.Cos(lat*math.Pi/180)
+	getDistance := func(dLon float64, dLat float64, dHeight float64) float64 {
+		return math.Sqrt(math.Pow(dLon*scaleX, 2) + math.Pow(dLat*metersPerDegree, 2) + dHeight*dHeight)
+	}
+	getBoxDistance := func(box *geometry.BoundingBox) float64 {
+		gap := func(value float64, min float64, max float64) float64 {
+			return math.Max(0, math.Max(min-value, value-max))
+		}
+		return getDistance(gap(lon, box.Xmin, box.Xmax), gap(lat, box.Ymin, box.Ymax), gap(height, box.Zmin, box.Zmax))
+	}
+
+	queue := &candidates{{node: root, level: 0, distance: getBoxDistance(root.GetBoundingBox())}}
+	found := &neighbors{}
+	var picked io.PointSet
+	if replace {
+		picked = io.PointSet{}
+	}
+	for queue.Len() > 0 {
+		if err := cancellation.Err(); err != nil {
+			return nil, err
+		}
+		next := heap.Pop(queue).(*candidate)
+		if found.Len() == count && next.distance >= (*found)[0].Distance {
+			break
+		}
+
+		for _, point := range next.node.GetPoints() {
+			distance := getDistance(point.X-lon, point.Y-lat, point.Z-height)
+			if (found.Len() == count && distance >= (*found)[0].Distance) || picked.Contains(point) {
+				continue
+			}
+			if picked != nil {
+				picked.Add(point)
+			}
+			heap.Push(found, &Neighbor{
+				Distance:       distance,
+				Longitude:      point.X,
+				Latitude:       point.Y,
+				Height:         point.Z,
+				Classification: point.Classification,
+				Intensity:      point.Intensity,
+				R:              point.R,
+				G:              point.G,
+				B:              point.B,
+				Level:          next.level,
+			})
+			if found.Len() > count {
+				heap.Pop(found)
+			}
+		}
+		if level != FullResolution && next.level >= level {
+			continue
+		}
+		for _, child := range next.node.GetChildren() {
+			if child != nil {
+				heap.Push(queue, &candidate{node: child, level: next.level + 1, distance: getBoxDistance(child.GetBoundingBox())})
+			}
+		}
+	}
+
+	result := []*Neighbor(*found)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Distance < result[j].Distance
+	})
+	return result, nil
+}
+
+// Encodes the given points as a CSV table with a row per point
+func ToCsv(points []*Neighbor) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.Write([]string{"distance", "longitude", "latitude", "height", "classification", "intensity", "red", "green", "blue", "level"})
+	for _, n := range points {
+		_ = writer.Write([]string{
+			strconv.FormatFloat(n.Distance, 'f', 3, 64),
+			strconv.FormatFloat(n.Longitude, 'f', 8, 64),
+			strconv.FormatFloat(n.Latitude, 'f', 8, 64),
+			strconv.FormatFloat(n.Height, 'f', 3, 64),
+			strconv.Itoa(int(n.Classification)),
+			strconv.Itoa(int(n.Intensity)),
+			strconv.Itoa(int(n.R)),
+			strconv.Itoa(int(n.G)),
+			strconv.Itoa(int(n.B)),
+			strconv.Itoa(n.Level),
+		})
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
@@ -3,10 +3,14 @@ package point_loader
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 )
 
+// Max number of points sampled to estimate the percentiles of the coordinates
+const percentileSampleSize = 1 << 20
+
 // Stores points and returns them in order
 type SequentialLoader struct {
 	sync.Mutex
@@ -65,3 +69,33 @@ func (eb *SequentialLoader) recomputeBoundsFromElement(element *data.Point) {
 func (eb *SequentialLoader) GetBounds() []float64 {
 	return []float64{eb.minX, eb.maxX, eb.minY, eb.maxY, eb.minZ, eb.maxZ}
 }
+
+// Returns the bounds enclosing the stored points once the given percentage of them is discarded at both ends of every
+// axis, in minX, maxX, minY, maxY, minZ, maxZ order. Percentiles are estimated on an evenly spaced sample of the points
+// of large clouds. Must be called before the first call to GetNext.
+func (eb *SequentialLoader) GetPercentileBounds(percentage float64) []float64 {
+	eb.Lock()
+	defer eb.Unlock()
+
+	step := len(eb.sequentialList)/percentileSampleSize + 1
+	var values [3][]float64
+	for i := 0; i < len(eb.sequentialList); i += step {
+		point := eb.sequentialList[i]
+		values[0] = append(values[0], point.X)
+		values[1] = append(values[1], point.Y)
+		values[2] = append(values[2], point.Z)
+	}
+	if len(values[0]) == 0 {
+		return eb.GetBounds()
+	}
+
+	bounds := make([]float64, 6)
+	for axis := range values {
+		sort.Float64s(values[axis])
+		last := len(values[axis]) - 1
+		discarded := int(math.Floor(float64(last) * percentage / 100))
+		bounds[2*axis] = values[axis][discarded]
+		bounds[2*axis+1] = values[axis][last-discarded]
+	}
+	return bounds
+}
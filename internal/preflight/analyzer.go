@@ -0,0 +1,111 @@
+package preflight
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Approximate length in meters of a degree of latitude, and of longitude at the equator
+const (
+	metersPerDegreeLatitude  = 110574.0
+	metersPerDegreeLongitude = 111320.0
+)
+
+// Header level information of a point cloud file, enough to spot suspicious configurations without reading the points
+type FileInfo struct {
+	File           string
+	MinX           float64
+	MaxX           float64
+	MinY           float64
+	MaxY           float64
+	NumberOfPoints int
+	// true if the file declares an orthometric, i.e. geoid based, vertical reference system
+	Orthometric bool
+	// day the file was created according to its header, zero if not recorded
+	CreationDate time.Time
+	// true if the point data record format of the file stores the colors of the points
+	HasRgb bool
+}
+
+// Checks the tiler options against the point cloud metadata before the tiling starts, returning warnings about
+// configurations that are likely to produce wrong or poor results
+type Analyzer interface {
+	Analyze(info *FileInfo, opts *tiler.TilerOptions) []string
+}
+
+type StandardAnalyzer struct {
+	converter converters.CoordinateConverter
+}
+
+func NewAnalyzer(converter converters.CoordinateConverter) Analyzer {
+	return &StandardAnalyzer{
+		converter: converter,
+	}
+}
+
+func (a *StandardAnalyzer) Analyze(info *FileInfo, opts *tiler.TilerOptions) []string {
+	var warnings []string
+
+	if opts.Srid != 4326 && math.Abs(info.MinX) <= 180 && math.Abs(info.MaxX) <= 180 &&
+		math.Abs(info.MinY) <= 90 && math.Abs(info.MaxY) <= 90 && info.MaxX-info.MinX < 1 && info.MaxY-info.MinY < 1 {
+		warnings = append(warnings, "header bounds look like geographic degrees but the srid is EPSG:"+strconv.Itoa(opts.Srid)+
+			", check the -srid flag")
+	}
+
+	region, ok := a.toGeographicRegion(info, opts.Srid)
+	if !ok {
+		warnings = append(warnings, "header bounds are not valid coordinates in EPSG:"+strconv.Itoa(opts.Srid)+
+			", check the -srid flag")
+	} else if opts.Algorithm == tiler.Grid && info.NumberOfPoints > 0 {
+		spacing := estimatePointSpacing(region, info.NumberOfPoints)
+		if spacing > 0 && opts.CellMinSize > spacing {
+			warnings = append(warnings, "grid-min-size of "+formatMeters(opts.CellMinSize)+
+				" is larger than the estimated average point spacing of "+formatMeters(spacing)+
+				", the finest tiles will not reach the full data resolution")
+		}
+	}
+
+	if info.Orthometric && !opts.EnableGeoidZCorrection {
+		warnings = append(warnings, "the file declares orthometric heights but the geoid correction is disabled, "+
+			"points will be placed below their true position unless -geoid is set")
+	}
+
+	return warnings
+}
+
+// Converts the header bounds to a EPSG:4326 region, returning false if they are not valid in the given srid
+func (a *StandardAnalyzer) toGeographicRegion(info *FileInfo, srid int) (*geometry.BoundingBox, bool) {
+	lower, err := a.converter.ConvertCoordinateSrid(srid, 4326, geometry.Coordinate{X: info.MinX, Y: info.MinY})
+	if err != nil {
+		return nil, false
+	}
+	upper, err := a.converter.ConvertCoordinateSrid(srid, 4326, geometry.Coordinate{X: info.MaxX, Y: info.MaxY})
+	if err != nil {
+		return nil, false
+	}
+
+	for _, c := range []geometry.Coordinate{lower, upper} {
+		if math.IsNaN(c.X) || math.IsNaN(c.Y) || math.Abs(c.X) > 180 || math.Abs(c.Y) > 90 {
+			return nil, false
+		}
+	}
+
+	return geometry.NewBoundingBox(lower.X, upper.X, lower.Y, upper.Y, 0, 0), true
+}
+
+// Estimates the average horizontal spacing in meters of the given number of points evenly spread in the region
+func estimatePointSpacing(region *geometry.BoundingBox, numberOfPoints int) float64 {
+	midLatitude := (region.Ymin + region.Ymax) / 2 * math.Pi / 180
+	width := math.Abs(region.Xmax-region.Xmin) * metersPerDegreeLongitude * math.Cos(midLatitude)
+	height := math.Abs(region.Ymax-region.Ymin) * metersPerDegreeLatitude
+
+	return math.Sqrt(width * height / float64(numberOfPoints))
+}
+
+func formatMeters(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64) + " m"
+}
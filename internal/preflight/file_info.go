@@ -0,0 +1,113 @@
+package preflight
+
+import (
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
+	"strings"
+	"time"
+)
+
+// Record ids of the LASF_Projection VLRs describing the coordinate reference system of the points
+const (
+	geoKeyDirectoryRecordId = 34735
+	wktRecordId             = 2112
+)
+
+// GeoTIFF key holding the EPSG code of the vertical coordinate system
+const verticalCsTypeGeoKey = 4096
+
+// GeoTIFF code marking user defined vertical coordinate systems
+const userDefinedGeoKeyValue = 32767
+
+// Reads the header and the VLRs of the given LAS file, without loading its points
+func ReadLasFileInfo(filePath string) (*FileInfo, error) {
+	las, err := lidario.NewLasFile(filePath, "rh")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = las.Close() }()
+
+	return &FileInfo{
+		File:           filePath,
+		MinX:           las.Header.MinX,
+		MaxX:           las.Header.MaxX,
+		MinY:           las.Header.MinY,
+		MaxY:           las.Header.MaxY,
+		NumberOfPoints: las.Header.NumberPoints,
+		Orthometric:    IsOrthometric(las.VlrData),
+		CreationDate:   getCreationDate(las.Header.FileCreationYear, las.Header.FileCreationDay),
+		HasRgb:         hasRgb(las.Header.PointFormatID),
+	}, nil
+}
+
+// Returns true if the given point data record format stores the colors of the points, i.e. the formats 2, 3, 5, 7, 8
+// and 10
+func hasRgb(formatId byte) bool {
+	switch formatId {
+	case 2, 3, 5, 7, 8, 10:
+		return true
+	}
+	return false
+}
+
+// Returns the UTC date of the given day of the year, zero if the header does not record it
+func getCreationDate(year int, dayOfYear int) time.Time {
+	if year <= 0 || dayOfYear <= 0 || dayOfYear > 366 {
+		return time.Time{}
+	}
+	return time.Date(year, time.January, dayOfYear, 0, 0, 0, 0, time.UTC)
+}
+
+// Returns true if the given VLRs declare a vertical coordinate system which is not based on an ellipsoid
+func IsOrthometric(vlrs []lidario.VLR) bool {
+	for _, vlr := range vlrs {
+		switch vlr.RecordID {
+		case geoKeyDirectoryRecordId:
+			if code, ok := getVerticalCsCode(vlr.BinaryData); ok && !isEllipsoidalVerticalCs(code) {
+				return true
+			}
+		case wktRecordId:
+			if isOrthometricWkt(strings.Trim(string(vlr.BinaryData), "\x00")) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns the vertical coordinate system code stored in a GeoKey directory, if any
+func getVerticalCsCode(data []byte) (int, bool) {
+	if len(data) < 8 {
+		return 0, false
+	}
+	numberOfKeys := int(binary.LittleEndian.Uint16(data[6:8]))
+	for i := 1; i <= numberOfKeys && (i+1)*8 <= len(data); i++ {
+		entry := data[i*8 : (i+1)*8]
+		keyId := binary.LittleEndian.Uint16(entry[0:2])
+		tagLocation := binary.LittleEndian.Uint16(entry[2:4])
+		value := int(binary.LittleEndian.Uint16(entry[6:8]))
+		if keyId == verticalCsTypeGeoKey && tagLocation == 0 && value != 0 && value != userDefinedGeoKeyValue {
+			return value, true
+		}
+	}
+
+	return 0, false
+}
+
+// GeoTIFF encodes ellipsoidal heights with the 5001-5033 vertical coordinate system codes
+func isEllipsoidalVerticalCs(code int) bool {
+	return code >= 5001 && code <= 5033
+}
+
+// Returns true if the given WKT defines a vertical coordinate system which does not refer to an ellipsoid
+func isOrthometricWkt(wkt string) bool {
+	upper := strings.ToUpper(wkt)
+	for _, keyword := range []string{"VERT_CS[", "VERTCRS[", "VERTICALCRS["} {
+		if index := strings.Index(upper, keyword); index >= 0 {
+			return !strings.Contains(upper[index:], "ELLIPSOID")
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,122 @@
+package preview
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/compression"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+)
+
+// Release of CesiumJS loaded by the viewer page from the Cesium CDN
+const cesiumVersion = "1.120"
+
+// Viewer page loading the tilesets of the served folder, whose urls it receives as a JSON array
+var viewerPage = template.Must(template.New("viewer").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>gocesiumtiler preview</title>
+  <script src="https://cesium.com/downloads/cesiumjs/releases/{{.Version}}/Build/Cesium/Cesium.js"></script>
+  <link href="https://cesium.com/downloads/cesiumjs/releases/{{.Version}}/Build/Cesium/Widgets/widgets.css" rel="stylesheet">
+  <style>html, body, #viewer { width: 100%; height: 100%; margin: 0; padding: 0; overflow: hidden; }</style>
+</head>
+<body>
+  <div id="viewer"></div>
+  <script>
+    const tilesets = {{.Tilesets}};
+    const viewer = new Cesium.Viewer("viewer", {
+      baseLayer: new Cesium.ImageryLayer(new Cesium.OpenStreetMapImageryProvider({ url: "https://tile.openstreetmap.org/" })),
+      baseLayerPicker: false,
+      geocoder: false,
+      animation: false,
+      timeline: false,
+    });
+    (async () => {
+      for (const url of tilesets) {
+        const tileset = await Cesium.Cesium3DTileset.fromUrl(url);
+        tileset.pointCloudShading.attenuation = true;
+        viewer.scene.primitives.add(tileset);
+        if (url === tilesets[0]) {
+          await viewer.zoomTo(tileset);
+        }
+      }
+    })().catch((error) => window.alert("Cannot load the tilesets: " + error));
+  </script>
+</body>
+</html>
+`))
+
+// Serves a folder of tilesets to the clients hosted elsewhere, answering the CORS preflight requests, along with a
+// CesiumJS viewer of its tilesets at the root, unless the folder has its own index.html. The files are served by a
+// compression.Handler, decoding the zstd compressed tile contents.
+type Handler struct {
+	folder string
+	files  http.Handler
+}
+
+// Creates a handler serving the files of the given folder, decoded with the given decoder
+func NewHandler(folder string, decoder *compression.Decoder) *Handler {
+	return &Handler{
+		folder: folder,
+		files:  compression.NewHandler(folder, decoder),
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+		w.Header().Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+		if _, err := os.Stat(path.Join(h.folder, "index.html")); os.IsNotExist(err) {
+			h.serveViewer(w, r)
+			return
+		}
+	}
+	h.files.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveViewer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodGet {
+		_ = viewerPage.Execute(w, struct {
+			Version  string
+			Tilesets []string
+		}{cesiumVersion, FindTilesets(h.folder)})
+	}
+}
+
+// Returns the urls, relative to the given folder, of the tilesets it holds: its own tileset.json if any, otherwise
+// the ones of its subfolders, i.e. one tileset per input file or the merged tileset
+func FindTilesets(folder string) []string {
+	if _, err := os.Stat(path.Join(folder, "tileset.json")); err == nil {
+		return []string{"tileset.json"}
+	}
+	entries, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return []string{}
+	}
+	tilesets := []string{}
+	for _, entry := range entries {
+		if _, err := os.Stat(path.Join(folder, entry.Name(), "tileset.json")); entry.IsDir() && err == nil {
+			tilesets = append(tilesets, url.PathEscape(entry.Name())+"/tileset.json")
+		}
+	}
+	sort.Strings(tilesets)
+	return tilesets
+}
@@ -0,0 +1,187 @@
+package profile
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Radius in meters of the sphere the line is projected on, the WGS84 equatorial radius
+const earthRadius = 6378137.0
+
+// Point of a profile, located by its distance along the line and its signed distance from it
+type Sample struct {
+	// Distance in meters along the line of the projection of the point on it
+	Distance float64
+	// Distance in meters of the point from the line, positive on its left
+	Offset         float64
+	Longitude      float64
+	Latitude       float64
+	Elevation      float64
+	Classification uint8
+	Intensity      uint8
+	R, G, B        uint8
+}
+
+// Polyline in EPSG:4326 coordinates along which a profile is extracted
+type Line [][2]float64
+
+// Parses a polyline given as a semicolon separated list of at least two longitude,latitude pairs, e.g.
+// 12.49,41.89;12.50,41.90
+func ParseLine(value string) (Line, error) {
+	var line Line
+	for _, vertex := range strings.Split(value, ";") {
+		coordinates := strings.Split(vertex, ",")
+		if len(coordinates) != 2 {
+			return nil, errors.New("the profile line should be a semicolon separated list of longitude,latitude pairs")
+		}
+		lon, errLon := strconv.ParseFloat(strings.TrimSpace(coordinates[0]), 64)
+		lat, errLat := strconv.ParseFloat(strings.TrimSpace(coordinates[1]), 64)
+		if errLon != nil || errLat != nil || math.Abs(lon) > 180 || math.Abs(lat) > 90 {
+			return nil, errors.New("invalid vertex " + strings.TrimSpace(vertex) + " of the profile line")
+		}
+		line = append(line, [2]float64{lon, lat})
+	}
+	if len(line) < 2 {
+		return nil, errors.New("the profile line should have at least two vertices")
+	}
+	return line, nil
+}
+
+// Segment of a line projected on the plane tangent to its first vertex, in meters
+type segment struct {
+	ax, ay, bx, by float64
+	length         float64
+	// distance along the line of the start of the segment
+	start float64
+	// bounds in degrees of the segment expanded by the buffer
+	minLon, maxLon, minLat, maxLat float64
+}
+
+// Extracts the profile of the points of the given tree whose horizontal distance from the given line is at most the
+// given buffer in meters, sorted by distance along the line. The points of the tree must be EPSG:4326 coordinates, as
+// the ones of a mounted tileset, and only the nodes whose bounding box intersects the buffer are visited. With replace
+// true the points that the tiles repeat from their parents, as in the tilesets refined with the REPLACE mode, are
+// extracted once.
+func Extract(root octree.INode, line Line, buffer float64, replace bool, cancellation *cancellation.Token) ([]*Sample, error) {
+	if root.GetInternalSrid() != 4326 {
+		return nil, errors.New("profiles can only be extracted from trees of EPSG:4326 coordinates")
+	}
+	originLon, originLat := line[0][0], line[0][1]
+	metersPerDegree := earthRadius * math.Pi / 180
+	scaleX := metersPerDegree * math.Cos(originLat*math.Pi/180)
+	project := func(lon float64, lat float64) (float64, float64) {
+		return (lon - originLon) * scaleX, (lat - originLat) * metersPerDegree
+	}
+
+	segments := make([]*segment, len(line)-1)
+	distance := 0.0
+	for i := range segments {
+		s := &segment{start: distance}
+		s.ax, s.ay = project(line[i][0], line[i][1])
+		s.bx, s.by = project(line[i+1][0], line[i+1][1])
+		s.length = math.Hypot(s.bx-s.ax, s.by-s.ay)
+		bufferLon, bufferLat := buffer/scaleX, buffer/metersPerDegree
+		s.minLon, s.maxLon = math.Min(line[i][0], line[i+1][0])-bufferLon, math.Max(line[i][0], line[i+1][0])+bufferLon
+		s.minLat, s.maxLat = math.Min(line[i][1], line[i+1][1])-bufferLat, math.Max(line[i][1], line[i+1][1])+bufferLat
+		segments[i] = s
+		distance += s.length
+	}
+
+	var samples []*Sample
+	var visit func(node octree.INode, parentPoints io.PointSet) error
+	visit = func(node octree.INode, parentPoints io.PointSet) error {
+		if err := cancellation.Err(); err != nil {
+			return err
+		}
+		box := node.GetBoundingBox()
+		var intersected []*segment
+		for _, s := range segments {
+			if box.Xmin <= s.maxLon && box.Xmax >= s.minLon && box.Ymin <= s.maxLat && box.Ymax >= s.minLat {
+				intersected = append(intersected, s)
+			}
+		}
+		if len(intersected) == 0 {
+			return nil
+		}
+
+		points := node.GetPoints()
+		var nodePoints io.PointSet
+		if replace {
+			nodePoints = io.NewPointSet(points)
+		}
+		for _, point := range points {
+			if parentPoints.Contains(point) {
+				continue
+			}
+			x, y := project(point.X, point.Y)
+			best := math.Inf(1)
+			var sample *Sample
+			for _, s := range intersected {
+				along, offset, ok := s.locate(x, y, s == segments[0], s == segments[len(segments)-1])
+				if ok && math.Abs(offset) <= buffer && math.Abs(offset) < best {
+					best = math.Abs(offset)
+					sample = &Sample{
+						Distance:       s.start + along,
+						Offset:         offset,
+						Longitude:      point.X,
+						Latitude:       point.Y,
+						Elevation:      point.Z,
+						Classification: point.Classification,
+						Intensity:      point.Intensity,
+						R:              point.R,
+						G:              point.G,
+						B:              point.B,
+					}
+				}
+			}
+			if sample != nil {
+				samples = append(samples, sample)
+			}
+		}
+		for _, child := range node.GetChildren() {
+			if child != nil && child.TotalNumberOfPoints() > 0 {
+				if err := visit(child, nodePoints); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := visit(root, nil); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Distance < samples[j].Distance
+	})
+	return samples, nil
+}
+
+// Returns the distance along the segment of the projection of the given position, clamped to the segment, and the
+// signed distance of the position from it. Returns false if the position projects before the start of the first
+// segment of the line or after the end of its last one, the profile not extending past the ends of the line, or if
+// the segment is empty, as the ones between repeated vertices.
+func (s *segment) locate(x float64, y float64, first bool, last bool) (float64, float64, bool) {
+	if s.length == 0 {
+		return 0, 0, false
+	}
+	dx, dy := (s.bx-s.ax)/s.length, (s.by-s.ay)/s.length
+	along := (x-s.ax)*dx + (y-s.ay)*dy
+	if (first && along < 0) || (last && along > s.length) {
+		return 0, 0, false
+	}
+	along = math.Max(0, math.Min(s.length, along))
+	px, py := s.ax+along*dx, s.ay+along*dy
+	offset := math.Hypot(x-px, y-py)
+	// the left side of the segment is the one of the positive cross product
+	if dx*(y-s.ay)-dy*(x-s.ax) < 0 {
+		offset = -offset
+	}
+	return along, offset, true
+}
@@ -0,0 +1,105 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"math"
+	"path"
+	"strconv"
+)
+
+// Names of the files holding the extracted profile
+const (
+	CsvFileName = "profile.csv"
+	SvgFileName = "profile.svg"
+)
+
+// Size in pixels of the SVG chart of the profiles and of its margins holding the axes labels
+const (
+	svgWidth  = 1200
+	svgHeight = 500
+	svgMargin = 60
+)
+
+// Writes the given profile in the CSV and SVG files of the given folder
+func Write(samples []*Sample, folder string, storage storage.Storage) error {
+	table, err := ToCsv(samples)
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFile(path.Join(folder, CsvFileName), table, 0666); err != nil {
+		return err
+	}
+	return storage.WriteFile(path.Join(folder, SvgFileName), ToSvg(samples), 0666)
+}
+
+// Encodes the given profile as a CSV table with a row per point
+func ToCsv(samples []*Sample) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.Write([]string{"distance", "offset", "longitude", "latitude", "elevation", "classification", "intensity", "red", "green", "blue"})
+	for _, s := range samples {
+		_ = writer.Write([]string{
+			strconv.FormatFloat(s.Distance, 'f', 3, 64),
+			strconv.FormatFloat(s.Offset, 'f', 3, 64),
+			strconv.FormatFloat(s.Longitude, 'f', 8, 64),
+			strconv.FormatFloat(s.Latitude, 'f', 8, 64),
+			strconv.FormatFloat(s.Elevation, 'f', 3, 64),
+			strconv.Itoa(int(s.Classification)),
+			strconv.Itoa(int(s.Intensity)),
+			strconv.Itoa(int(s.R)),
+			strconv.Itoa(int(s.G)),
+			strconv.Itoa(int(s.B)),
+		})
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// Draws the given profile as an SVG chart of the elevation of the points along the line, in the colors of the points.
+// The axes are scaled independently to fill the chart, their ranges being labeled.
+func ToSvg(samples []*Sample) []byte {
+	minDistance, maxDistance := 0.0, 1.0
+	minElevation, maxElevation := 0.0, 1.0
+	if len(samples) > 0 {
+		minDistance, maxDistance = samples[0].Distance, samples[len(samples)-1].Distance
+		minElevation, maxElevation = math.Inf(1), math.Inf(-1)
+		for _, s := range samples {
+			minElevation, maxElevation = math.Min(minElevation, s.Elevation), math.Max(maxElevation, s.Elevation)
+		}
+	}
+	// degenerate ranges are widened so that the points are drawn in the middle of the chart
+	if maxDistance-minDistance < 1e-6 {
+		minDistance, maxDistance = minDistance-0.5, maxDistance+0.5
+	}
+	if maxElevation-minElevation < 1e-6 {
+		minElevation, maxElevation = minElevation-0.5, maxElevation+0.5
+	}
+	plotWidth, plotHeight := float64(svgWidth-2*svgMargin), float64(svgHeight-2*svgMargin)
+	toX := func(distance float64) float64 {
+		return svgMargin + (distance-minDistance)/(maxDistance-minDistance)*plotWidth
+	}
+	toY := func(elevation float64) float64 {
+		return svgMargin + (maxElevation-elevation)/(maxElevation-minElevation)*plotHeight
+	}
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", svgWidth, svgHeight, svgWidth, svgHeight)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="white"/>`+"\n", svgWidth, svgHeight)
+	fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="none" stroke="black"/>`+"\n", svgMargin, svgMargin, plotWidth, plotHeight)
+	fmt.Fprint(&svg, `<g font-family="sans-serif" font-size="12">`+"\n")
+	fmt.Fprintf(&svg, `<text x="%d" y="%d">%.2f m</text>`+"\n", svgMargin, svgHeight-svgMargin+20, minDistance)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="end">%.2f m</text>`+"\n", svgWidth-svgMargin, svgHeight-svgMargin+20, maxDistance)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle">distance along the line</text>`+"\n", svgWidth/2, svgHeight-svgMargin+40)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="end">%.2f m</text>`+"\n", svgMargin-5, svgMargin+4, maxElevation)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="end">%.2f m</text>`+"\n", svgMargin-5, svgHeight-svgMargin, minElevation)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d">%d points</text>`+"\n", svgMargin, svgMargin-10, len(samples))
+	fmt.Fprint(&svg, "</g>\n<g>\n")
+	for _, s := range samples {
+		fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="2" height="2" fill="#%02x%02x%02x"/>`+"\n", toX(s.Distance)-1, toY(s.Elevation)-1, s.R, s.G, s.B)
+	}
+	fmt.Fprint(&svg, "</g>\n</svg>\n")
+	return svg.Bytes()
+}
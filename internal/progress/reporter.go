@@ -0,0 +1,88 @@
+package progress
+
+import (
+	"sync"
+)
+
+// Type of the events reported during a tiling job
+type EventType string
+
+const (
+	// The processing of an input file started
+	FileStarted EventType = "FILE_STARTED"
+
+	// The processing of an input file finished, after all the events of its phases
+	FileFinished EventType = "FILE_FINISHED"
+
+	// The processing of an input file failed and the file has been skipped, as the recoverable errors do not abort the job
+	FileFailed EventType = "FILE_FAILED"
+
+	// A phase of the processing of a file, e.g. read, build or export, started
+	PhaseStarted EventType = "PHASE_STARTED"
+
+	// A phase of the processing of a file finished, after all the events reported while it was running
+	PhaseFinished EventType = "PHASE_FINISHED"
+
+	// A batch of points of the file being read has been loaded, also reported with the total once the file is read
+	PointsRead EventType = "POINTS_READ"
+
+	// The content of a tile has been written, along with its tileset.json file if any. The tileset.json files of the
+	// tilesets whose contents are deduplicated or named by a template are written once all their tiles are written.
+	TileWritten EventType = "TILE_WRITTEN"
+)
+
+// Event reported during a tiling job
+type Event struct {
+	// Position of the event among the ones of the reporter, starting from 1 and increasing by 1 at every event
+	Sequence uint64
+	Type     EventType
+	// Name of the input file being processed, set by all the events but the tile written ones
+	File string
+	// Zero based index of the file among the input files and number of input files, set by the file events
+	FileIndex int
+	FileCount int
+	// Name of the phase, set by the phase events
+	Phase string
+	// Number of points read from the file so far, set by the points read events
+	Points int64
+	// Folder of the tile, set by the tile written events
+	Tile string
+	// Error the processing of the file failed with, set by the file failed events
+	Error string
+}
+
+// Reports the events of a tiling job to a callback. It is safe to report events from multiple goroutines. Events are
+// delivered one at a time, never concurrently, in the order they are reported, which is the order of their sequence
+// numbers, and before Report returns, so that the callback runs on the goroutine of the stage reporting the event
+// and all events have been delivered when the job returns. A slow callback slows down the job. A nil reporter
+// ignores the events, so that stages can report them unconditionally.
+type Reporter struct {
+	callback func(event Event)
+	sequence uint64
+	sync.Mutex
+}
+
+// Instantiates a reporter delivering the events to the given callback
+func NewReporter(callback func(event Event)) *Reporter {
+	return &Reporter{callback: callback}
+}
+
+// Instantiates a reporter sending the events on the given channel, which has to be received from while the job runs
+// and is never closed by the reporter
+func NewChannelReporter(events chan<- Event) *Reporter {
+	return NewReporter(func(event Event) {
+		events <- event
+	})
+}
+
+// Delivers the given event to the callback, assigning it the next sequence number
+func (r *Reporter) Report(event Event) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.sequence++
+	event.Sequence = r.sequence
+	r.callback(event)
+}
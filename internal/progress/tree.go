@@ -0,0 +1,39 @@
+package progress
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"sync"
+	"sync/atomic"
+)
+
+// Number of points added to a tracked tree between two points read events
+const BatchSize = 100000
+
+// Wraps the given tree so that a points read event of the given file is reported every BatchSize points added to it
+func (r *Reporter) TrackTree(tree octree.ITree, file string) octree.ITree {
+	return &progressTree{ITree: tree, reporter: r, file: file}
+}
+
+type progressTree struct {
+	octree.ITree
+	reporter *Reporter
+	file     string
+	count    int64
+	// highest count reported so far, so that the counts of the events never decrease when points are added by
+	// concurrent workers
+	reported int64
+	mutex    sync.Mutex
+}
+
+func (t *progressTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+	if count := atomic.AddInt64(&t.count, 1); count%BatchSize == 0 {
+		t.mutex.Lock()
+		if count > t.reported {
+			t.reported = count
+			t.reporter.Report(Event{Type: PointsRead, File: t.file, Points: count})
+		}
+		t.mutex.Unlock()
+	}
+}
@@ -0,0 +1,149 @@
+package quarantine
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"math"
+	"sync"
+)
+
+// Reason a point has been quarantined for
+type Reason string
+
+const (
+	// The coordinates of the point, as read or once transformed, are not finite numbers
+	ReasonNonFinite Reason = "NON_FINITE"
+	// The coordinates of the point cannot be transformed to EPSG:4326
+	ReasonConversionFailed Reason = "CONVERSION_FAILED"
+	// The transformed coordinates are not a position on the Earth, i.e. the longitude or latitude exceed their range or
+	// the height is farther from the ellipsoid than a point cloud can be
+	ReasonOutOfRange Reason = "OUT_OF_RANGE"
+	// The transformed coordinates lie outside of the declared validity area
+	ReasonOutsideArea Reason = "OUTSIDE_AREA"
+)
+
+// Max distance in meters from the ellipsoid of the heights of valid points
+const maxAbsoluteHeight = 100000
+
+// Max number of quarantined points listed in the report of a file, the other ones are only counted
+const maxReportedPoints = 100000
+
+// A quarantined point, with its coordinates as given to the tree and once transformed
+type Point struct {
+	Coordinate geometry.Coordinate
+	Srid       int
+	Longitude  float64
+	Latitude   float64
+	Height     float64
+	Reason     Reason
+}
+
+// Decorates a tree checking that the coordinates of the added points can be placed on the Earth once transformed to
+// EPSG:4326 with the elevation correction applied, and that they lie in the validity area, if any. The points failing
+// the checks are not added to the wrapped tree but counted by reason and listed in the report.
+type Tree struct {
+	octree.ITree
+	converter converters.CoordinateConverter
+	corrector converters.ElevationCorrector
+	area      []float64
+	counts    map[Reason]int64
+	points    []Point
+	sync.Mutex
+}
+
+// Wraps the given tree quarantining the points that cannot be transformed by the given converter and elevation
+// corrector, or that lie outside of the given area, as min longitude, min latitude, max longitude and max latitude in
+// degrees. No area is checked if nil.
+func NewQuarantineTree(tree octree.ITree, converter converters.CoordinateConverter, corrector converters.ElevationCorrector, area []float64) *Tree {
+	return &Tree{
+		ITree:     tree,
+		converter: converter,
+		corrector: corrector,
+		area:      area,
+		counts:    make(map[Reason]int64),
+	}
+}
+
+func (t *Tree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	if point := t.check(coordinate, srid); point != nil {
+		t.quarantine(point)
+		return
+	}
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+}
+
+// Returns the quarantined point if the given coordinate fails any check, nil otherwise
+func (t *Tree) check(coordinate *geometry.Coordinate, srid int) *Point {
+	point := &Point{Coordinate: *coordinate, Srid: srid, Longitude: math.NaN(), Latitude: math.NaN(), Height: math.NaN()}
+	if !isFinite(coordinate.X, coordinate.Y, coordinate.Z) {
+		point.Reason = ReasonNonFinite
+		return point
+	}
+	wgs84, err := t.converter.ConvertCoordinateSrid(srid, 4326, *coordinate)
+	if err != nil {
+		point.Reason = ReasonConversionFailed
+		return point
+	}
+	point.Longitude, point.Latitude = wgs84.X, wgs84.Y
+	point.Height = t.corrector.CorrectElevation(wgs84.X, wgs84.Y, wgs84.Z)
+	switch {
+	case !isFinite(point.Longitude, point.Latitude, point.Height):
+		point.Reason = ReasonNonFinite
+	case math.Abs(point.Longitude) > 180 || math.Abs(point.Latitude) > 90 || math.Abs(point.Height) > maxAbsoluteHeight:
+		point.Reason = ReasonOutOfRange
+	case t.area != nil && (point.Longitude < t.area[0] || point.Latitude < t.area[1] || point.Longitude > t.area[2] || point.Latitude > t.area[3]):
+		point.Reason = ReasonOutsideArea
+	default:
+		return nil
+	}
+	return point
+}
+
+func (t *Tree) quarantine(point *Point) {
+	t.Lock()
+	defer t.Unlock()
+	t.counts[point.Reason]++
+	if len(t.points) < maxReportedPoints {
+		t.points = append(t.points, *point)
+	}
+}
+
+// Returns the number of quarantined points
+func (t *Tree) GetCount() int64 {
+	t.Lock()
+	defer t.Unlock()
+	var count int64
+	for _, reasonCount := range t.counts {
+		count += reasonCount
+	}
+	return count
+}
+
+// Returns the number of quarantined points for every reason
+func (t *Tree) GetCounts() map[Reason]int64 {
+	t.Lock()
+	defer t.Unlock()
+	counts := make(map[Reason]int64, len(t.counts))
+	for reason, count := range t.counts {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// Forgets the quarantined points, e.g. before the points are added again by a further pass of a multi-pass tree
+func (t *Tree) Reset() {
+	t.Lock()
+	defer t.Unlock()
+	t.counts = make(map[Reason]int64)
+	t.points = nil
+}
+
+func isFinite(values ...float64) bool {
+	for _, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package quarantine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"path"
+	"strconv"
+)
+
+// Folder of the output directory where the quarantine reports are written
+const FolderName = "quarantine"
+
+// Header of the quarantine reports
+var reportHeader = []string{"x", "y", "z", "srid", "longitude", "latitude", "height", "reason"}
+
+// Returns the path of the quarantine report of the tileset of the given name in the given output folder
+func GetReportPath(output string, name string) string {
+	return path.Join(output, FolderName, name+".csv")
+}
+
+// Writes the quarantined points at the given path as a CSV file listing their coordinates as given to the tree, their
+// srid, their transformed coordinates, NaN if they could not be computed, and the reason they have been quarantined for
+func (t *Tree) WriteReport(storage storage.Storage, reportPath string) error {
+	t.Lock()
+	points := t.points
+	t.Unlock()
+
+	content := new(bytes.Buffer)
+	writer := csv.NewWriter(content)
+	_ = writer.Write(reportHeader)
+	for _, point := range points {
+		_ = writer.Write([]string{
+			formatValue(point.Coordinate.X),
+			formatValue(point.Coordinate.Y),
+			formatValue(point.Coordinate.Z),
+			strconv.Itoa(point.Srid),
+			formatValue(point.Longitude),
+			formatValue(point.Latitude),
+			formatValue(point.Height),
+			string(point.Reason),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	if err := storage.MkdirAll(path.Dir(reportPath), 0777); err != nil {
+		return err
+	}
+	return storage.WriteFile(reportPath, content.Bytes(), 0666)
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,109 @@
+package raster
+
+import (
+	"bytes"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// Renders point clouds as seen from above with an orthographic projection, splatting every point on a single pixel.
+// When several points fall in the same pixel the highest one is drawn, pixels without points are transparent.
+type Rasterizer interface {
+	// Renders the given points, contained in the given bounding box, preserving its aspect ratio
+	Render(points []*data.Point, boundingBox *geometry.BoundingBox) image.Image
+	// Renders the given points as a PNG encoded image
+	RenderPng(points []*data.Point, boundingBox *geometry.BoundingBox) ([]byte, error)
+}
+
+type orthographicRasterizer struct {
+	size int
+}
+
+// Instantiates a rasterizer producing images whose longest side is made of the given number of pixels
+func NewRasterizer(size int) Rasterizer {
+	return &orthographicRasterizer{size: size}
+}
+
+func (r *orthographicRasterizer) Render(points []*data.Point, boundingBox *geometry.BoundingBox) image.Image {
+	width, height, scale := r.getImageSize(boundingBox)
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	topZ := make([]float64, width*height)
+	for i := range topZ {
+		topZ[i] = math.Inf(-1)
+	}
+
+	colored := hasColors(points)
+	for _, point := range points {
+		col := clamp(int((point.X-boundingBox.Xmin)*scale), width)
+		// image rows grow southwards
+		row := clamp(int((boundingBox.Ymax-point.Y)*scale), height)
+		if point.Z < topZ[row*width+col] {
+			continue
+		}
+		topZ[row*width+col] = point.Z
+		img.SetNRGBA(col, row, getColor(point, boundingBox, colored))
+	}
+
+	return img
+}
+
+func (r *orthographicRasterizer) RenderPng(points []*data.Point, boundingBox *geometry.BoundingBox) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, r.Render(points, boundingBox)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Returns the width and height of the image preserving the aspect ratio of the bounding box, along with the number
+// of pixels per unit of the bounding box
+func (r *orthographicRasterizer) getImageSize(boundingBox *geometry.BoundingBox) (int, int, float64) {
+	dx := boundingBox.Xmax - boundingBox.Xmin
+	dy := boundingBox.Ymax - boundingBox.Ymin
+	if dx <= 0 && dy <= 0 {
+		return 1, 1, 0
+	}
+
+	scale := float64(r.size) / math.Max(dx, dy)
+	width := int(math.Max(1, math.Round(dx*scale)))
+	height := int(math.Max(1, math.Round(dy*scale)))
+	return width, height, scale
+}
+
+// Returns true if any point has a color, otherwise points are shaded by elevation
+func hasColors(points []*data.Point) bool {
+	for _, point := range points {
+		if point.R != 0 || point.G != 0 || point.B != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func getColor(point *data.Point, boundingBox *geometry.BoundingBox, colored bool) color.NRGBA {
+	if colored {
+		return color.NRGBA{R: point.R, G: point.G, B: point.B, A: 255}
+	}
+
+	shade := uint8(255)
+	if dz := boundingBox.Zmax - boundingBox.Zmin; dz > 0 {
+		// the lowest points are drawn dark gray so that they stand out of the transparent background
+		shade = uint8(64 + 191*math.Max(0, math.Min(1, (point.Z-boundingBox.Zmin)/dz)))
+	}
+	return color.NRGBA{R: shade, G: shade, B: shade, A: 255}
+}
+
+// Clamps an index to the [0, size-1] range, as points lying on the max bounds map to the pixel after the last one
+func clamp(index int, size int) int {
+	if index < 0 {
+		return 0
+	}
+	if index >= size {
+		return size - 1
+	}
+	return index
+}
@@ -0,0 +1,172 @@
+package e57_reader
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"math"
+	"strconv"
+)
+
+// Size of the header of the compressed vector binary sections
+const sectionHeaderSize = 32
+
+// Identifier of the compressed vector binary sections
+const compressedVectorSectionId = 1
+
+// Types of the packets of the compressed vector binary sections
+const (
+	indexPacket = 0
+	dataPacket  = 1
+	emptyPacket = 2
+)
+
+// Size of the header of the data packets, followed by the lengths of their bytestream buffers
+const dataPacketHeaderSize = 6
+
+// Values of a field, concatenated from the buffers of the data packets, as a record can straddle two packets.
+// Integers are packed least significant bit first with the bits of their field, floats are little endian.
+type bytestream struct {
+	field *field
+	data  []byte
+	// position of the next value in bits from the beginning of the data
+	bit uint64
+}
+
+// Returns the number of values that can be decoded from the data received so far, -1 if unlimited
+func (s *bytestream) available() int64 {
+	remaining := uint64(len(s.data))*8 - s.bit
+	switch {
+	case s.field.kind == floatField && s.field.double:
+		return int64(remaining / 64)
+	case s.field.kind == floatField:
+		return int64(remaining / 32)
+	case s.field.bits == 0:
+		return -1
+	}
+	return int64(remaining / uint64(s.field.bits))
+}
+
+// Decodes the next value, which has to be available
+func (s *bytestream) next() float64 {
+	f := s.field
+	if f.kind == floatField {
+		offset := s.bit / 8
+		if f.double {
+			s.bit += 64
+			return math.Float64frombits(binary.LittleEndian.Uint64(s.data[offset:]))
+		}
+		s.bit += 32
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(s.data[offset:])))
+	}
+	var raw uint64
+	for read := 0; read < f.bits; {
+		shift := int(s.bit & 7)
+		take := 8 - shift
+		if take > f.bits-read {
+			take = f.bits - read
+		}
+		chunk := uint64(s.data[s.bit>>3]>>uint(shift)) & (1<<uint(take) - 1)
+		raw |= chunk << uint(read)
+		read += take
+		s.bit += uint64(take)
+	}
+	return float64(f.minimum+int64(raw))*f.scale + f.offset
+}
+
+// Drops the data of the values already decoded
+func (s *bytestream) compact() {
+	consumed := s.bit / 8
+	s.data = append(s.data[:0], s.data[consumed:]...)
+	s.bit -= consumed * 8
+}
+
+// Decodes the point records of the compressed vector binary section of the given scan, one data packet at a time,
+// calling the given function with the values of the fields of every record, in the order of the fields of the scan.
+// Decoding stops between two packets once the given cancellation token, if any, is cancelled.
+func readRecords(file *pagedFile, s *scan, cancel *cancellation.Token, onRecord func(values []float64)) error {
+	header := make([]byte, sectionHeaderSize)
+	sectionStart := file.toLogical(s.fileOffset)
+	if err := file.readAt(header, sectionStart); err != nil {
+		return err
+	}
+	if header[0] != compressedVectorSectionId {
+		return errors.New("invalid compressed vector section at offset " + strconv.FormatUint(s.fileOffset, 10))
+	}
+	sectionEnd := sectionStart + binary.LittleEndian.Uint64(header[8:])
+	offset := file.toLogical(binary.LittleEndian.Uint64(header[16:]))
+
+	streams := make([]*bytestream, len(s.fields))
+	for i, f := range s.fields {
+		streams[i] = &bytestream{field: f}
+	}
+	values := make([]float64, len(s.fields))
+	packetHeader := make([]byte, 4)
+	packet := make([]byte, 0, 1<<16)
+	for decoded := int64(0); decoded < s.recordCount; {
+		if err := cancel.Err(); err != nil {
+			return err
+		}
+		if offset+uint64(len(packetHeader)) > sectionEnd {
+			return errors.New("the points section ends after " + strconv.FormatInt(decoded, 10) + " of " + strconv.FormatInt(s.recordCount, 10) + " records")
+		}
+		if err := file.readAt(packetHeader, offset); err != nil {
+			return err
+		}
+		length := uint64(binary.LittleEndian.Uint16(packetHeader[2:])) + 1
+		offset += length
+		if packetHeader[0] == indexPacket || packetHeader[0] == emptyPacket {
+			continue
+		}
+		if packetHeader[0] != dataPacket || length < dataPacketHeaderSize {
+			return errors.New("invalid packet in the points section")
+		}
+		packet = packet[:length]
+		if err := file.readAt(packet, offset-length); err != nil {
+			return err
+		}
+		if err := appendBuffers(packet, streams); err != nil {
+			return err
+		}
+
+		// the records whose values have all been received are decoded
+		count := s.recordCount - decoded
+		for _, stream := range streams {
+			if available := stream.available(); available >= 0 && available < count {
+				count = available
+			}
+		}
+		for record := int64(0); record < count; record++ {
+			for i, stream := range streams {
+				values[i] = stream.next()
+			}
+			onRecord(values)
+		}
+		decoded += count
+		for _, stream := range streams {
+			stream.compact()
+		}
+	}
+	return nil
+}
+
+// Appends the bytestream buffers of the given data packet to the bytestreams of the fields
+func appendBuffers(packet []byte, streams []*bytestream) error {
+	count := int(binary.LittleEndian.Uint16(packet[4:]))
+	if count != len(streams) {
+		return errors.New("data packet with " + strconv.Itoa(count) + " bytestreams for " + strconv.Itoa(len(streams)) + " fields")
+	}
+	start := dataPacketHeaderSize + 2*count
+	if start > len(packet) {
+		return errors.New("truncated data packet")
+	}
+	for i, stream := range streams {
+		end := start + int(binary.LittleEndian.Uint16(packet[dataPacketHeaderSize+2*i:]))
+		if end > len(packet) {
+			return errors.New("truncated data packet")
+		}
+		stream.data = append(stream.data, packet[start:end]...)
+		start = end
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package e57_reader
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"math"
+)
+
+// Reads point clouds stored as E57 files, adding the points of all their scans once moved by the pose of their scan.
+// The points are read from the cartesian coordinates of the records, or from the spherical ones if missing, and
+// colored by their color and intensity normalized from the limits declared by the scans. Only the bit pack codec of
+// the standard is supported, while the images are ignored.
+type E57Reader struct {
+	transformer  readers.PointTransformer
+	storage      storage.Storage
+	cancellation *cancellation.Token
+}
+
+// Instantiates a new E57Reader reading files from the given storage. If the transformer is not nil every point is
+// moved by it according to its time stamp. Reading stops between two data packets once the given cancellation token,
+// if any, is cancelled.
+func NewE57Reader(transformer readers.PointTransformer, storage storage.Storage, cancellation *cancellation.Token) readers.Reader {
+	return &E57Reader{
+		transformer:  transformer,
+		storage:      storage,
+		cancellation: cancellation,
+	}
+}
+
+func (r *E57Reader) Read(filePath string, srid int, tree octree.ITree) error {
+	file, err := r.storage.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	paged, err := openPagedFile(file)
+	if err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	content := make([]byte, paged.header.xmlLogicalLength)
+	if err := paged.readAt(content, paged.toLogical(paged.header.xmlPhysicalOffset)); err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	scans, err := parseScans(content)
+	if err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	for _, s := range scans {
+		if err := r.readScan(paged, s, srid, tree); err != nil {
+			return errors.New(filePath + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Adds to the tree the points of the given scan, skipping the ones flagged as invalid
+func (r *E57Reader) readScan(file *pagedFile, s *scan, srid int, tree octree.ITree) error {
+	x, y, z := s.fieldIndex("cartesianX"), s.fieldIndex("cartesianY"), s.fieldIndex("cartesianZ")
+	invalidState := s.fieldIndex("cartesianInvalidState")
+	spherical := x < 0 || y < 0 || z < 0
+	if spherical {
+		x, y, z = s.fieldIndex("sphericalRange"), s.fieldIndex("sphericalAzimuth"), s.fieldIndex("sphericalElevation")
+		invalidState = s.fieldIndex("sphericalInvalidState")
+		if x < 0 || y < 0 || z < 0 {
+			return errors.New("scan " + s.name + " has neither cartesian nor spherical coordinates")
+		}
+	}
+	time := s.fieldIndex("timeStamp")
+	if r.transformer != nil && time < 0 {
+		return errors.New("scan " + s.name + ": points must have a time stamp to be transformed")
+	}
+	red, green, blue := s.newNormalizer("colorRed"), s.newNormalizer("colorGreen"), s.newNormalizer("colorBlue")
+	intensity := s.newNormalizer("intensity")
+	colorInvalid, intensityInvalid := s.fieldIndex("isColorInvalid"), s.fieldIndex("isIntensityInvalid")
+
+	return readRecords(file, s, r.cancellation, func(values []float64) {
+		if invalidState >= 0 && values[invalidState] != 0 {
+			return
+		}
+		px, py, pz := values[x], values[y], values[z]
+		if spherical {
+			horizontal := px * math.Cos(pz)
+			px, py, pz = horizontal*math.Cos(py), horizontal*math.Sin(py), px*math.Sin(pz)
+		}
+		if s.pose != nil {
+			px, py, pz = s.pose.Apply(px, py, pz)
+		}
+		coordinate, pointSrid := &geometry.Coordinate{X: px, Y: py, Z: pz}, srid
+		if r.transformer != nil {
+			coordinate, pointSrid = r.transformer.Transform(coordinate, values[time], srid)
+		}
+		var cr, cg, cb, ci uint8
+		if colorInvalid < 0 || values[colorInvalid] == 0 {
+			cr, cg, cb = red.normalize(values), green.normalize(values), blue.normalize(values)
+		}
+		if intensityInvalid < 0 || values[intensityInvalid] == 0 {
+			ci = intensity.normalize(values)
+		}
+		tree.AddPoint(coordinate, cr, cg, cb, ci, 0, pointSrid, nil)
+	})
+}
+
+// Maps the values of a field of the records to the 0-255 range according to the limits of the field
+type normalizer struct {
+	index   int
+	minimum float64
+	maximum float64
+}
+
+// Returns the normalizer of the field of the given name, which always yields 0 if the records do not have the field
+func (s *scan) newNormalizer(name string) normalizer {
+	limits := s.limits[name]
+	return normalizer{index: s.fieldIndex(name), minimum: limits[0], maximum: limits[1]}
+}
+
+func (n normalizer) normalize(values []float64) uint8 {
+	if n.index < 0 {
+		return 0
+	}
+	value := values[n.index]
+	if n.maximum > n.minimum {
+		value = (value - n.minimum) / (n.maximum - n.minimum) * 255
+	}
+	return uint8(math.Max(0, math.Min(255, math.Round(value))))
+}
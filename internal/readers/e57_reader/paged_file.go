@@ -0,0 +1,116 @@
+package e57_reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+// Signature opening every E57 file
+var e57Signature = []byte("ASTM-E57")
+
+// Size of the header of the file at the beginning of its first page
+const fileHeaderSize = 48
+
+// Size of the CRC-32C checksum ending every page of the file
+const checksumSize = 4
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Header of an E57 file. The offsets and the length of the file are physical ones, i.e. they count the checksums of
+// the pages, while the length of the XML section is a logical one.
+type fileHeader struct {
+	majorVersion      uint32
+	minorVersion      uint32
+	physicalLength    uint64
+	xmlPhysicalOffset uint64
+	xmlLogicalLength  uint64
+	pageSize          uint64
+}
+
+// Reads the logical content of an E57 file, made of pages whose last bytes are a checksum of the other ones, verifying
+// the checksum of every page read. The last page read is cached as the sections are read sequentially.
+type pagedFile struct {
+	file      io.ReaderAt
+	header    fileHeader
+	page      []byte
+	pageIndex int64
+}
+
+// Reads the header of the given E57 file
+func openPagedFile(file io.ReaderAt) (*pagedFile, error) {
+	buffer := make([]byte, fileHeaderSize)
+	if _, err := file.ReadAt(buffer, 0); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.New("not an E57 file")
+		}
+		return nil, err
+	}
+	if !bytes.Equal(buffer[:8], e57Signature) {
+		return nil, errors.New("not an E57 file")
+	}
+	header := fileHeader{
+		majorVersion:      binary.LittleEndian.Uint32(buffer[8:]),
+		minorVersion:      binary.LittleEndian.Uint32(buffer[12:]),
+		physicalLength:    binary.LittleEndian.Uint64(buffer[16:]),
+		xmlPhysicalOffset: binary.LittleEndian.Uint64(buffer[24:]),
+		xmlLogicalLength:  binary.LittleEndian.Uint64(buffer[32:]),
+		pageSize:          binary.LittleEndian.Uint64(buffer[40:]),
+	}
+	if header.majorVersion != 1 {
+		return nil, errors.New("unsupported E57 version " + strconv.Itoa(int(header.majorVersion)) + "." + strconv.Itoa(int(header.minorVersion)))
+	}
+	if header.pageSize <= fileHeaderSize+checksumSize || header.pageSize > 1<<20 || header.physicalLength%header.pageSize != 0 {
+		return nil, errors.New("invalid E57 page size " + strconv.FormatUint(header.pageSize, 10))
+	}
+	return &pagedFile{file: file, header: header, pageIndex: -1}, nil
+}
+
+// Returns the logical offset of the given physical one
+func (f *pagedFile) toLogical(physical uint64) uint64 {
+	return physical/f.header.pageSize*(f.header.pageSize-checksumSize) + physical%f.header.pageSize
+}
+
+// Fills the buffer with the logical content of the file starting from the given logical offset
+func (f *pagedFile) readAt(buffer []byte, logical uint64) error {
+	payloadSize := f.header.pageSize - checksumSize
+	for len(buffer) > 0 {
+		if err := f.loadPage(int64(logical / payloadSize)); err != nil {
+			return err
+		}
+		copied := copy(buffer, f.page[logical%payloadSize:payloadSize])
+		buffer = buffer[copied:]
+		logical += uint64(copied)
+	}
+	return nil
+}
+
+// Reads the page of the given index unless cached, verifying its checksum
+func (f *pagedFile) loadPage(index int64) error {
+	if index == f.pageIndex {
+		return nil
+	}
+	offset := uint64(index) * f.header.pageSize
+	if offset+f.header.pageSize > f.header.physicalLength {
+		return errors.New("read past the end of the E57 file")
+	}
+	if f.page == nil {
+		f.page = make([]byte, f.header.pageSize)
+	}
+	f.pageIndex = -1
+	if _, err := f.file.ReadAt(f.page, int64(offset)); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.New("truncated E57 file")
+		}
+		return err
+	}
+	payloadSize := f.header.pageSize - checksumSize
+	if crc32.Checksum(f.page[:payloadSize], castagnoliTable) != binary.BigEndian.Uint32(f.page[payloadSize:]) {
+		return errors.New("checksum mismatch in page " + strconv.FormatInt(index, 10) + " of the E57 file")
+	}
+	f.pageIndex = index
+	return nil
+}
@@ -0,0 +1,252 @@
+package e57_reader
+
+import (
+	"encoding/xml"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/trajectory"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Element of the XML section of an E57 file, holding its attributes, its child elements and its text
+type element struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []*element `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+// Returns the child element of the given local name, nil if missing
+func (e *element) child(name string) *element {
+	if e == nil {
+		return nil
+	}
+	for _, child := range e.Children {
+		if child.XMLName.Local == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// Returns the value of the attribute of the given local name, empty if missing
+func (e *element) attr(name string) string {
+	for _, attr := range e.Attrs {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Returns the trimmed text of the element, empty if the element is missing
+func (e *element) text() string {
+	if e == nil {
+		return ""
+	}
+	return strings.TrimSpace(e.Text)
+}
+
+// Returns the value of the given numeric attribute, the given default if missing or invalid
+func (e *element) floatAttr(name string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(strings.TrimSpace(e.attr(name)), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// Returns the value of a Float, Integer or ScaledInteger element, the given default if the element is missing. The
+// elements without text have the value 0.
+func (e *element) value(defaultValue float64) float64 {
+	if e == nil {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(e.text(), 64)
+	if err != nil {
+		value = 0
+	}
+	if e.attr("type") == "ScaledInteger" {
+		value = value*e.floatAttr("scale", 1) + e.floatAttr("offset", 0)
+	}
+	return value
+}
+
+// Kinds of the fields of the point records, as named by the type attribute of the prototype elements
+const (
+	floatField         = "Float"
+	integerField       = "Integer"
+	scaledIntegerField = "ScaledInteger"
+)
+
+// Field of the point records of a scan, stored as a bytestream of the binary section of the points
+type field struct {
+	name string
+	kind string
+	// true for double precision floats, the default precision
+	double bool
+	// limits of the raw value of integers and scaled integers
+	minimum int64
+	maximum int64
+	// scaled integers are raw*scale + offset
+	scale  float64
+	offset float64
+	// number of bits every integer is packed in
+	bits int
+	// limits of the value of floats, 0 and 1 if not declared
+	floatMinimum float64
+	floatMaximum float64
+}
+
+// Returns the limits of the values of the field declared by the prototype
+func (f *field) limits() (float64, float64) {
+	switch f.kind {
+	case integerField:
+		return float64(f.minimum), float64(f.maximum)
+	case scaledIntegerField:
+		return float64(f.minimum)*f.scale + f.offset, float64(f.maximum)*f.scale + f.offset
+	}
+	return f.floatMinimum, f.floatMaximum
+}
+
+// Scan of an E57 file, i.e. a data3D entry, whose point records are stored in a compressed vector binary section
+type scan struct {
+	name        string
+	pose        *trajectory.Pose
+	fileOffset  uint64
+	recordCount int64
+	fields      []*field
+	// limits of the values of the fields, mapped to the 0-255 range of the colors and of the intensity, by field name
+	limits map[string][2]float64
+}
+
+// Returns the index of the field of the given name, -1 if the records do not have it
+func (s *scan) fieldIndex(name string) int {
+	for i, f := range s.fields {
+		if f.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Parses the scans declared by the given XML section
+func parseScans(content []byte) ([]*scan, error) {
+	root := &element{}
+	if err := xml.Unmarshal(content, root); err != nil {
+		return nil, errors.New("invalid XML section: " + err.Error())
+	}
+	if root.XMLName.Local != "e57Root" {
+		return nil, errors.New("invalid XML section: missing e57Root element")
+	}
+	data3D := root.child("data3D")
+	if data3D == nil {
+		return nil, nil
+	}
+	scans := make([]*scan, 0, len(data3D.Children))
+	for i, entry := range data3D.Children {
+		s, err := parseScan(entry, i)
+		if err != nil {
+			return nil, err
+		}
+		scans = append(scans, s)
+	}
+	return scans, nil
+}
+
+func parseScan(entry *element, index int) (*scan, error) {
+	s := &scan{
+		name:   entry.child("name").text(),
+		limits: make(map[string][2]float64),
+	}
+	if s.name == "" {
+		s.name = strconv.Itoa(index)
+	}
+	points := entry.child("points")
+	if points == nil || points.attr("type") != "CompressedVector" {
+		return nil, errors.New("scan " + s.name + " has no compressed vector of points")
+	}
+	var err error
+	if s.fileOffset, err = strconv.ParseUint(points.attr("fileOffset"), 10, 64); err != nil {
+		return nil, errors.New("scan " + s.name + " has an invalid points file offset")
+	}
+	if s.recordCount, err = strconv.ParseInt(points.attr("recordCount"), 10, 64); err != nil || s.recordCount < 0 {
+		return nil, errors.New("scan " + s.name + " has an invalid points record count")
+	}
+	if codecs := points.child("codecs"); codecs != nil && len(codecs.Children) > 0 {
+		return nil, errors.New("scan " + s.name + " uses unsupported codecs, only the bit pack codec is supported")
+	}
+	if s.fields, err = parsePrototype(points.child("prototype"), nil); err != nil {
+		return nil, errors.New("scan " + s.name + ": " + err.Error())
+	}
+	if len(s.fields) == 0 {
+		return nil, errors.New("scan " + s.name + " has no point fields")
+	}
+
+	if pose := entry.child("pose"); pose != nil {
+		rotation, translation := pose.child("rotation"), pose.child("translation")
+		s.pose = &trajectory.Pose{
+			Translation: [3]float64{translation.child("x").value(0), translation.child("y").value(0), translation.child("z").value(0)},
+			Rotation:    [4]float64{rotation.child("x").value(0), rotation.child("y").value(0), rotation.child("z").value(0), rotation.child("w").value(1)},
+		}
+	}
+
+	// the limits declared by the scan take precedence over the ones of the prototype
+	for _, f := range s.fields {
+		minimum, maximum := f.limits()
+		s.limits[f.name] = [2]float64{minimum, maximum}
+	}
+	setLimits := func(limits *element, name string) {
+		if limits != nil {
+			current := s.limits[name]
+			s.limits[name] = [2]float64{limits.child(name + "Minimum").value(current[0]), limits.child(name + "Maximum").value(current[1])}
+		}
+	}
+	setLimits(entry.child("intensityLimits"), "intensity")
+	for _, name := range []string{"colorRed", "colorGreen", "colorBlue"} {
+		setLimits(entry.child("colorLimits"), name)
+	}
+	return s, nil
+}
+
+// Returns the fields of the records described by the given prototype, the leaves of nested structures in depth-first
+// order as their bytestreams are stored
+func parsePrototype(prototype *element, fields []*field) ([]*field, error) {
+	if prototype == nil {
+		return fields, nil
+	}
+	for _, child := range prototype.Children {
+		f := &field{name: child.XMLName.Local, kind: child.attr("type")}
+		switch f.kind {
+		case "Structure":
+			var err error
+			if fields, err = parsePrototype(child, fields); err != nil {
+				return nil, err
+			}
+			continue
+		case floatField:
+			f.double = child.attr("precision") != "single"
+			f.floatMinimum, f.floatMaximum = child.floatAttr("minimum", 0), child.floatAttr("maximum", 1)
+		case integerField, scaledIntegerField:
+			minimum, minErr := strconv.ParseInt(child.attr("minimum"), 10, 64)
+			maximum, maxErr := strconv.ParseInt(child.attr("maximum"), 10, 64)
+			if minErr != nil || maxErr != nil || maximum < minimum {
+				return nil, errors.New("invalid limits of the " + f.name + " field")
+			}
+			f.minimum, f.maximum = minimum, maximum
+			f.bits = bits.Len64(uint64(maximum - minimum))
+			f.scale, f.offset = 1, 0
+			if f.kind == scaledIntegerField {
+				f.scale, f.offset = child.floatAttr("scale", 1), child.floatAttr("offset", 0)
+			}
+			if math.IsNaN(f.scale) || f.scale == 0 {
+				return nil, errors.New("invalid scale of the " + f.name + " field")
+			}
+		default:
+			return nil, errors.New("unsupported " + f.kind + " field " + f.name)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
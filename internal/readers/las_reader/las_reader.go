@@ -0,0 +1,197 @@
+package las_reader
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/sidecar"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
+	"sync/atomic"
+)
+
+// Reads LAS files using the lidario library
+type LasReader struct {
+	transformer  readers.PointTransformer
+	storage      storage.Storage
+	workers      int
+	chunkSize    int
+	filter       lidario.PointFilter
+	attributes   lidario.AttributeSource
+	recovery     *lidario.RecoveryCounts
+	cancellation *cancellation.Token
+}
+
+// Instantiates a new LasReader reading files from the given storage. If the transformer is not nil every point is
+// moved by it according to its GPS time. Points are read in batches of the given number of points, all at once if 0,
+// decoded by the given number of goroutines, one per CPU if 0, and only the ones accepted by the filter, if not nil,
+// are loaded, with the supplementary attributes of the given source, if not nil. If the given recovery counts are not
+// nil the malformed records are skipped and counted in them rather than failing the read. Loading stops once the
+// given cancellation token, if any, is cancelled.
+func NewLasReader(transformer readers.PointTransformer, storage storage.Storage, workers int, chunkSize int, filter lidario.PointFilter, attributes lidario.AttributeSource, recovery *lidario.RecoveryCounts, cancellation *cancellation.Token) readers.Reader {
+	return &LasReader{
+		transformer:  transformer,
+		storage:      storage,
+		workers:      workers,
+		chunkSize:    chunkSize,
+		filter:       filter,
+		attributes:   attributes,
+		recovery:     recovery,
+		cancellation: cancellation,
+	}
+}
+
+// Builds the filter discarding the points flagged as overlap if excludeOverlap is true, the ones not matching the
+// returns mode and the ones of scanner channels other than the given one, if not negative. Returns nil if all the
+// points are to be loaded.
+func NewAttributeFilter(excludeOverlap bool, returns tiler.ReturnsMode, scannerChannel int) lidario.PointFilter {
+	if !excludeOverlap && (returns == "" || returns == tiler.ReturnsAll) && scannerChannel < 0 {
+		return nil
+	}
+
+	return func(point *lidario.PointAttributes) bool {
+		if excludeOverlap && point.Overlap {
+			return false
+		}
+		if scannerChannel >= 0 && int(point.ScannerChannel) != scannerChannel {
+			return false
+		}
+		switch returns {
+		case tiler.ReturnsFirst:
+			return point.ReturnNumber <= 1
+		case tiler.ReturnsLast:
+			return point.ReturnNumber >= point.NumberOfReturns
+		}
+		return true
+	}
+}
+
+// Number of the points carrying each of the LAS flags, a point having more flags is counted once for each of them
+type FlagCounts struct {
+	Withheld  int64
+	Synthetic int64
+	KeyPoint  int64
+}
+
+// Builds the filter applying the given handling to the points flagged as withheld, synthetic or key-point. The points
+// having a flag to drop are always discarded, the ones having a flag to split are loaded only if split is true and
+// the others only if it is false. If counts is not nil the flags of all the filtered points are counted in it.
+// Returns nil if all the points are to be loaded and nothing has to be counted.
+func NewFlagFilter(withheld tiler.FlaggedPointsMode, synthetic tiler.FlaggedPointsMode, keyPoint tiler.FlaggedPointsMode, split bool, counts *FlagCounts) lidario.PointFilter {
+	if !split && counts == nil && isKept(withheld) && isKept(synthetic) && isKept(keyPoint) {
+		return nil
+	}
+
+	if counts == nil {
+		// the flags are counted anyway, in a discarded instance, to keep the filter simple
+		counts = &FlagCounts{}
+	}
+	return func(point *lidario.PointAttributes) bool {
+		drop, splitPoint := false, false
+		apply := func(flagged bool, mode tiler.FlaggedPointsMode, count *int64) {
+			if flagged {
+				atomic.AddInt64(count, 1)
+				drop = drop || mode == tiler.FlaggedPointsDrop
+				splitPoint = splitPoint || mode == tiler.FlaggedPointsSplit
+			}
+		}
+		apply(point.Withheld, withheld, &counts.Withheld)
+		apply(point.Synthetic, synthetic, &counts.Synthetic)
+		apply(point.KeyPoint, keyPoint, &counts.KeyPoint)
+		return !drop && splitPoint == split
+	}
+}
+
+// Returns true if the points flagged with the given handling are loaded with the other ones
+func isKept(mode tiler.FlaggedPointsMode) bool {
+	return mode == "" || mode == tiler.FlaggedPointsKeep
+}
+
+// Builds the source of the supplementary attributes of the points joined from the given sidecar table. Returns nil if
+// the table is nil.
+func NewSidecarAttributes(table *sidecar.Table) lidario.AttributeSource {
+	if table == nil {
+		return nil
+	}
+	return func(index int, point *lidario.PointAttributes) []float32 {
+		return table.GetAttributes(index, point.GpsTime)
+	}
+}
+
+// Builds the source of the given LAS attributes of the points, named as in tiler.LasAttributes, followed by the
+// supplementary attributes of the given source, if not nil. Returns the given source if no LAS attribute is given.
+func NewLasAttributes(names []string, source lidario.AttributeSource) lidario.AttributeSource {
+	if len(names) == 0 {
+		return source
+	}
+	values := make([]func(point *lidario.PointAttributes) float32, len(names))
+	for i, name := range names {
+		switch name {
+		case "return_number":
+			values[i] = func(point *lidario.PointAttributes) float32 { return float32(point.ReturnNumber) }
+		case "number_of_returns":
+			values[i] = func(point *lidario.PointAttributes) float32 { return float32(point.NumberOfReturns) }
+		case "gps_time":
+			// the seconds of the week keep a precision of about 0.06 seconds as float, unlike the absolute times
+			values[i] = func(point *lidario.PointAttributes) float32 { return float32(point.GetGpsWeekTime()) }
+		case "normal_x", "normal_y", "normal_z":
+			// the LAS points store no normal
+			values[i] = func(point *lidario.PointAttributes) float32 { return 0 }
+		default:
+			values[i] = func(point *lidario.PointAttributes) float32 { return float32(point.ScannerChannel) }
+		}
+	}
+	return func(index int, point *lidario.PointAttributes) []float32 {
+		attributes := make([]float32, len(values))
+		for i, value := range values {
+			attributes[i] = value(point)
+		}
+		if source != nil {
+			attributes = append(attributes, source(index, point)...)
+		}
+		return attributes
+	}
+}
+
+// Combines the given filters, ignoring the nil ones, into a filter accepting the points accepted by all of them.
+// Returns nil if all the filters are nil.
+func CombineFilters(filters ...lidario.PointFilter) lidario.PointFilter {
+	var active []lidario.PointFilter
+	for _, filter := range filters {
+		if filter != nil {
+			active = append(active, filter)
+		}
+	}
+	switch len(active) {
+	case 0:
+		return nil
+	case 1:
+		return active[0]
+	}
+
+	return func(point *lidario.PointAttributes) bool {
+		for _, filter := range active {
+			if !filter(point) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func (r *LasReader) Read(filePath string, srid int, tree octree.ITree) error {
+	var lasFileLoader = lidario.NewLasFileLoader(tree)
+	lasFileLoader.PointTransformer = r.transformer
+	lasFileLoader.Storage = r.storage
+	lasFileLoader.Workers = r.workers
+	lasFileLoader.ChunkSize = r.chunkSize
+	lasFileLoader.Filter = r.filter
+	lasFileLoader.Attributes = r.attributes
+	lasFileLoader.Recovery = r.recovery
+	lasFileLoader.Cancellation = r.cancellation
+	lf, err := lasFileLoader.LoadLasFile(filePath, srid)
+	// the file has to be closed even if loading failed, to release its descriptor
+	defer func() { _ = lf.Close() }()
+	return err
+}
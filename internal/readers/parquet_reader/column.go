@@ -0,0 +1,296 @@
+package parquet_reader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"io/ioutil"
+	"math"
+	"strconv"
+)
+
+// Compression codecs of the column chunks
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+	codecZstd         = 6
+)
+
+// Encodings of the values of the pages
+const (
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRleDictionary   = 8
+	encodingByteStreamSplit = 9
+)
+
+// Decodes the values of a column chunk of a flat schema, whose values are optional if the max definition level is 1
+type columnDecoder struct {
+	column             *columnMetaData
+	maxDefinitionLevel int
+	dictionary         []float64
+}
+
+// Decodes the pages of the given column chunk returning one value per row, NaN for the null ones
+func (d *columnDecoder) decodeChunk(chunk []byte, numRows int64) ([]float64, error) {
+	values := make([]float64, 0, numRows)
+	r := newThriftReader(chunk)
+	for int64(len(values)) < d.column.numValues && r.pos < len(chunk) {
+		header, err := readPageHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if header.compressedSize < 0 || int(header.compressedSize) > len(chunk)-r.pos {
+			return nil, errors.New("truncated parquet page in column " + d.column.getName())
+		}
+		page := chunk[r.pos : r.pos+int(header.compressedSize)]
+		r.pos += int(header.compressedSize)
+
+		switch header.pageType {
+		case pageDictionary:
+			data, err := decompress(d.column.codec, page)
+			if err != nil {
+				return nil, err
+			}
+			if d.dictionary, err = decodePlain(data, int(header.numValues), d.column.physicalType); err != nil {
+				return nil, err
+			}
+		case pageData:
+			data, err := decompress(d.column.codec, page)
+			if err != nil {
+				return nil, err
+			}
+			var levels []byte
+			if d.maxDefinitionLevel > 0 {
+				if len(data) < 4 || int(binary.LittleEndian.Uint32(data)) > len(data)-4 {
+					return nil, errors.New("invalid definition levels in column " + d.column.getName())
+				}
+				length := int(binary.LittleEndian.Uint32(data))
+				levels, data = data[4:4+length], data[4+length:]
+			}
+			if values, err = d.appendPageValues(values, header, levels, data); err != nil {
+				return nil, err
+			}
+		case pageDataV2:
+			levelsLength := int(header.repetitionLevelsLength + header.definitionLevelsLength)
+			if header.repetitionLevelsLength < 0 || header.definitionLevelsLength < 0 || levelsLength > len(page) {
+				return nil, errors.New("invalid definition levels in column " + d.column.getName())
+			}
+			levels, data := page[header.repetitionLevelsLength:levelsLength], page[levelsLength:]
+			if header.compressed {
+				if data, err = decompress(d.column.codec, data); err != nil {
+					return nil, err
+				}
+			}
+			if values, err = d.appendPageValues(values, header, levels, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if int64(len(values)) != numRows {
+		return nil, errors.New("column " + d.column.getName() + " has " + strconv.Itoa(len(values)) + " values instead of " + strconv.FormatInt(numRows, 10))
+	}
+	return values, nil
+}
+
+// Appends to the given values the ones of a data page, the definition levels of the page, if any, marking the nulls
+func (d *columnDecoder) appendPageValues(values []float64, header *pageHeader, levels []byte, data []byte) ([]float64, error) {
+	numValues := int(header.numValues)
+	nonNull := numValues
+	var definitionLevels []int
+	if d.maxDefinitionLevel > 0 {
+		var err error
+		if definitionLevels, err = decodeHybrid(levels, 1, numValues); err != nil {
+			return nil, err
+		}
+		nonNull = 0
+		for _, level := range definitionLevels {
+			nonNull += level
+		}
+	}
+
+	pageValues, err := d.decodeValues(data, nonNull, header.encoding)
+	if err != nil {
+		return nil, err
+	}
+	if definitionLevels == nil {
+		return append(values, pageValues...), nil
+	}
+	next := 0
+	for _, level := range definitionLevels {
+		if level == 0 {
+			values = append(values, math.NaN())
+		} else {
+			values = append(values, pageValues[next])
+			next++
+		}
+	}
+	return values, nil
+}
+
+func (d *columnDecoder) decodeValues(data []byte, count int, encoding int32) ([]float64, error) {
+	switch encoding {
+	case encodingPlain:
+		return decodePlain(data, count, d.column.physicalType)
+	case encodingPlainDictionary, encodingRleDictionary:
+		if len(data) == 0 {
+			if count == 0 {
+				return nil, nil
+			}
+			return nil, errors.New("invalid dictionary indices in column " + d.column.getName())
+		}
+		indices, err := decodeHybrid(data[1:], int(data[0]), count)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]float64, count)
+		for i, index := range indices {
+			if index >= len(d.dictionary) {
+				return nil, errors.New("invalid dictionary index in column " + d.column.getName())
+			}
+			values[i] = d.dictionary[index]
+		}
+		return values, nil
+	case encodingByteStreamSplit:
+		return decodeByteStreamSplit(data, count, d.column.physicalType)
+	}
+	return nil, errors.New("unsupported parquet encoding " + strconv.Itoa(int(encoding)) + " in column " + d.column.getName())
+}
+
+// Returns the given number of values plainly encoded with the given physical type
+func decodePlain(data []byte, count int, physicalType int32) ([]float64, error) {
+	size := getValueSize(physicalType)
+	if size == 0 {
+		return nil, errors.New("unsupported parquet physical type " + strconv.Itoa(int(physicalType)))
+	}
+	if len(data) < count*size {
+		return nil, errors.New("truncated parquet values")
+	}
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = decodeValue(data[i*size:], physicalType)
+	}
+	return values, nil
+}
+
+// Returns the given number of values whose bytes are split in a stream per byte of the values
+func decodeByteStreamSplit(data []byte, count int, physicalType int32) ([]float64, error) {
+	size := getValueSize(physicalType)
+	if size == 0 || (physicalType != typeFloat && physicalType != typeDouble) {
+		return nil, errors.New("unsupported parquet physical type " + strconv.Itoa(int(physicalType)) + " for byte stream split")
+	}
+	if len(data) < count*size {
+		return nil, errors.New("truncated parquet values")
+	}
+	values := make([]float64, count)
+	value := make([]byte, size)
+	for i := range values {
+		for b := 0; b < size; b++ {
+			value[b] = data[b*count+i]
+		}
+		values[i] = decodeValue(value, physicalType)
+	}
+	return values, nil
+}
+
+// Returns the size in bytes of the values of the given physical type, 0 if it is not supported
+func getValueSize(physicalType int32) int {
+	switch physicalType {
+	case typeInt32, typeFloat:
+		return 4
+	case typeInt64, typeDouble:
+		return 8
+	}
+	return 0
+}
+
+func decodeValue(data []byte, physicalType int32) float64 {
+	switch physicalType {
+	case typeInt32:
+		return float64(int32(binary.LittleEndian.Uint32(data)))
+	case typeInt64:
+		return float64(int64(binary.LittleEndian.Uint64(data)))
+	case typeFloat:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	default:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data))
+	}
+}
+
+// Decodes the given number of values of the given bit width stored with the RLE / bit-packing hybrid encoding
+func decodeHybrid(data []byte, bitWidth int, count int) ([]int, error) {
+	if bitWidth > 32 {
+		return nil, errors.New("invalid parquet bit width " + strconv.Itoa(bitWidth))
+	}
+	byteWidth := (bitWidth + 7) / 8
+	values := make([]int, 0, count)
+	pos := 0
+	for len(values) < count {
+		header, n := binary.Uvarint(data[pos:])
+		if n <= 0 {
+			return nil, errors.New("truncated parquet run")
+		}
+		pos += n
+
+		if header&1 == 0 {
+			// run of repeated values
+			if pos+byteWidth > len(data) {
+				return nil, errors.New("truncated parquet run")
+			}
+			value := 0
+			for i := 0; i < byteWidth; i++ {
+				value |= int(data[pos+i]) << (8 * i)
+			}
+			pos += byteWidth
+			for i := uint64(0); i < header>>1 && len(values) < count; i++ {
+				values = append(values, value)
+			}
+			continue
+		}
+
+		// groups of 8 bit-packed values
+		groups := int(header >> 1)
+		if pos+groups*bitWidth > len(data) {
+			return nil, errors.New("truncated parquet run")
+		}
+		for i := 0; i < groups*8 && len(values) < count; i++ {
+			value := 0
+			for bit := 0; bit < bitWidth; bit++ {
+				position := i*bitWidth + bit
+				value |= int(data[pos+position/8]>>(position%8)&1) << bit
+			}
+			values = append(values, value)
+		}
+		pos += groups * bitWidth
+	}
+	return values, nil
+}
+
+// Decompresses the given page data with the given codec
+func decompress(codec int32, data []byte) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return data, nil
+	case codecSnappy:
+		return snappy.Decode(nil, data)
+	case codecGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = reader.Close() }()
+		return ioutil.ReadAll(reader)
+	case codecZstd:
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(data, nil)
+	}
+	return nil, errors.New("unsupported parquet compression codec " + strconv.Itoa(int(codec)))
+}
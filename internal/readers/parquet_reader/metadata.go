@@ -0,0 +1,250 @@
+package parquet_reader
+
+import (
+	"errors"
+	"strings"
+)
+
+// Physical types of the Parquet columns
+const (
+	typeBoolean = 0
+	typeInt32   = 1
+	typeInt64   = 2
+	typeFloat   = 4
+	typeDouble  = 5
+)
+
+// Repetition types of the Parquet schema elements
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+	repetitionRepeated = 2
+)
+
+// Element of the flattened schema of a Parquet file, the first one being the root of the schema
+type schemaElement struct {
+	name        string
+	repetition  int32
+	numChildren int32
+}
+
+// Location and encoding of the values of a column in a row group
+type columnMetaData struct {
+	physicalType         int32
+	path                 []string
+	codec                int32
+	numValues            int64
+	totalCompressedSize  int64
+	dataPageOffset       int64
+	dictionaryPageOffset int64
+}
+
+// Returns the name of the column, its path in the schema
+func (c *columnMetaData) getName() string {
+	return strings.Join(c.path, ".")
+}
+
+// Returns the offset of the first page of the column chunk in the file
+func (c *columnMetaData) getStartOffset() int64 {
+	if c.dictionaryPageOffset > 0 && c.dictionaryPageOffset < c.dataPageOffset {
+		return c.dictionaryPageOffset
+	}
+	return c.dataPageOffset
+}
+
+type rowGroup struct {
+	columns []*columnMetaData
+	numRows int64
+}
+
+// Footer of a Parquet file describing its schema and row groups
+type fileMetaData struct {
+	schema    []*schemaElement
+	numRows   int64
+	rowGroups []*rowGroup
+}
+
+func readFileMetaData(r *thriftReader) (*fileMetaData, error) {
+	metadata := &fileMetaData{}
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch id {
+		case 2:
+			err = r.readList(func(byte) error {
+				element, err := readSchemaElement(r)
+				metadata.schema = append(metadata.schema, element)
+				return err
+			})
+		case 3:
+			metadata.numRows, err = r.readInt()
+		case 4:
+			err = r.readList(func(byte) error {
+				group, err := readRowGroup(r)
+				metadata.rowGroups = append(metadata.rowGroups, group)
+				return err
+			})
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	return metadata, err
+}
+
+func readSchemaElement(r *thriftReader) (*schemaElement, error) {
+	element := &schemaElement{}
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch id {
+		case 3:
+			element.repetition, err = r.readInt32()
+		case 4:
+			element.name, err = r.readString()
+		case 5:
+			element.numChildren, err = r.readInt32()
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	return element, err
+}
+
+func readRowGroup(r *thriftReader) (*rowGroup, error) {
+	group := &rowGroup{}
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch id {
+		case 1:
+			err = r.readList(func(byte) error {
+				column, err := readColumnChunk(r)
+				group.columns = append(group.columns, column)
+				return err
+			})
+		case 3:
+			group.numRows, err = r.readInt()
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	return group, err
+}
+
+func readColumnChunk(r *thriftReader) (*columnMetaData, error) {
+	var column *columnMetaData
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch id {
+		case 1:
+			// chunks stored in other files are not supported
+			err = errors.New("parquet files referencing external column chunks are not supported")
+		case 3:
+			column, err = readColumnMetaData(r)
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	if err == nil && column == nil {
+		err = errors.New("parquet column chunk without metadata")
+	}
+	return column, err
+}
+
+func readColumnMetaData(r *thriftReader) (*columnMetaData, error) {
+	column := &columnMetaData{}
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch id {
+		case 1:
+			column.physicalType, err = r.readInt32()
+		case 3:
+			err = r.readList(func(byte) error {
+				name, err := r.readString()
+				column.path = append(column.path, name)
+				return err
+			})
+		case 4:
+			column.codec, err = r.readInt32()
+		case 5:
+			column.numValues, err = r.readInt()
+		case 7:
+			column.totalCompressedSize, err = r.readInt()
+		case 9:
+			column.dataPageOffset, err = r.readInt()
+		case 11:
+			column.dictionaryPageOffset, err = r.readInt()
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	return column, err
+}
+
+// Page types
+const (
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// Header preceding every page of a column chunk
+type pageHeader struct {
+	pageType         int32
+	uncompressedSize int32
+	compressedSize   int32
+	numValues        int32
+	encoding         int32
+	// length of the repetition and definition levels, stored uncompressed before the values by the v2 data pages
+	repetitionLevelsLength int32
+	definitionLevelsLength int32
+	// false if the values of a v2 data page are not compressed
+	compressed bool
+}
+
+func readPageHeader(r *thriftReader) (*pageHeader, error) {
+	header := &pageHeader{compressed: true}
+	// the data page, dictionary page and data page v2 headers hold the number of values and their encoding
+	readValuesHeader := func(numValuesId int16, encodingId int16) error {
+		return r.readStruct(func(id int16, fieldType byte) error {
+			var err error
+			switch {
+			case id == numValuesId:
+				header.numValues, err = r.readInt32()
+			case id == encodingId:
+				header.encoding, err = r.readInt32()
+			case header.pageType == pageDataV2 && id == 5:
+				header.definitionLevelsLength, err = r.readInt32()
+			case header.pageType == pageDataV2 && id == 6:
+				header.repetitionLevelsLength, err = r.readInt32()
+			case header.pageType == pageDataV2 && id == 7:
+				header.compressed = fieldType == compactTrue
+			default:
+				err = r.skip(fieldType)
+			}
+			return err
+		})
+	}
+
+	err := r.readStruct(func(id int16, fieldType byte) error {
+		var err error
+		switch id {
+		case 1:
+			header.pageType, err = r.readInt32()
+		case 2:
+			header.uncompressedSize, err = r.readInt32()
+		case 3:
+			header.compressedSize, err = r.readInt32()
+		case 5, 7:
+			err = readValuesHeader(1, 2)
+		case 8:
+			err = readValuesHeader(1, 4)
+		default:
+			err = r.skip(fieldType)
+		}
+		return err
+	})
+	return header, err
+}
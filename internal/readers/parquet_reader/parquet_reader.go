@@ -0,0 +1,263 @@
+package parquet_reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io"
+	"math"
+	"strings"
+)
+
+// Magic number opening and closing every Parquet file
+var parquetMagic = []byte("PAR1")
+
+// Fields of the points read from the columns of Parquet tables, by default from the columns of the same name
+var pointFields = []string{"x", "y", "z", "r", "g", "b", "intensity", "classification", "gps_time"}
+
+// Reads point clouds stored as Parquet tables holding a point per row, one row group at a time. Only the flat columns
+// plainly, dictionary or byte stream split encoded, uncompressed or compressed with snappy, gzip or zstd are supported.
+type ParquetReader struct {
+	columns      string
+	transformer  readers.PointTransformer
+	storage      storage.Storage
+	cancellation *cancellation.Token
+}
+
+// Instantiates a new ParquetReader reading files from the given storage. The point fields are read from the columns
+// of the given mapping, see ParseColumnMapping. If the transformer is not nil every point is moved by it according
+// to its GPS time. Reading stops between two row groups once the given cancellation token, if any, is cancelled.
+func NewParquetReader(columns string, transformer readers.PointTransformer, storage storage.Storage, cancellation *cancellation.Token) readers.Reader {
+	return &ParquetReader{
+		columns:      columns,
+		transformer:  transformer,
+		storage:      storage,
+		cancellation: cancellation,
+	}
+}
+
+// Parses a comma separated list of field=column pairs, e.g. x=easting,y=northing, returning the name of the column of
+// every point field. The fields missing from the list are read from the column of the same name, if any.
+func ParseColumnMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, field := range pointFields {
+		mapping[field] = field
+	}
+	if strings.TrimSpace(value) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.Split(pair, "=")
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.New("invalid column mapping " + pair + ", expected field=column")
+		}
+		field := strings.ToLower(strings.TrimSpace(parts[0]))
+		if _, ok := mapping[field]; !ok {
+			return nil, errors.New("unknown point field " + field + ", expected one of " + strings.Join(pointFields, ", "))
+		}
+		mapping[field] = strings.TrimSpace(parts[1])
+	}
+	return mapping, nil
+}
+
+func (r *ParquetReader) Read(filePath string, srid int, tree octree.ITree) error {
+	mapping, err := ParseColumnMapping(r.columns)
+	if err != nil {
+		return err
+	}
+	file, err := r.storage.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	metadata, err := readFooter(file)
+	if err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	optional, err := getOptionalColumns(metadata.schema)
+	if err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	for _, field := range []string{"x", "y", "z"} {
+		if _, ok := optional[strings.ToLower(mapping[field])]; !ok {
+			return errors.New(filePath + ": missing column " + mapping[field] + " of the " + field + " coordinates")
+		}
+	}
+	if r.transformer != nil {
+		if _, ok := optional[strings.ToLower(mapping["gps_time"])]; !ok {
+			return errors.New(filePath + ": points must have GPS time to be transformed, missing column " + mapping["gps_time"])
+		}
+	}
+
+	for _, group := range metadata.rowGroups {
+		if err := r.cancellation.Err(); err != nil {
+			return err
+		}
+		fields, err := readRowGroupFields(file, group, mapping, optional)
+		if err != nil {
+			return errors.New(filePath + ": " + err.Error())
+		}
+		r.addPoints(fields, group.numRows, srid, tree)
+	}
+	return nil
+}
+
+// Adds to the tree the points of a row group whose fields have been read, skipping the ones with null coordinates
+func (r *ParquetReader) addPoints(fields map[string][]float64, numRows int64, srid int, tree octree.ITree) {
+	value := func(field string, row int64) float64 {
+		values, ok := fields[field]
+		if !ok || math.IsNaN(values[row]) {
+			return 0
+		}
+		return values[row]
+	}
+
+	x, y, z := fields["x"], fields["y"], fields["z"]
+	for row := int64(0); row < numRows; row++ {
+		if math.IsNaN(x[row]) || math.IsNaN(y[row]) || math.IsNaN(z[row]) {
+			continue
+		}
+		coordinate, pointSrid := &geometry.Coordinate{X: x[row], Y: y[row], Z: z[row]}, srid
+		if r.transformer != nil {
+			coordinate, pointSrid = r.transformer.Transform(coordinate, value("gps_time", row), srid)
+		}
+		tree.AddPoint(
+			coordinate,
+			clampToUint8(value("r", row)),
+			clampToUint8(value("g", row)),
+			clampToUint8(value("b", row)),
+			clampToUint8(value("intensity", row)),
+			clampToUint8(value("classification", row)),
+			pointSrid,
+			nil,
+		)
+	}
+}
+
+// Reads the values of the columns of the mapped point fields in the given row group
+func readRowGroupFields(file io.ReaderAt, group *rowGroup, mapping map[string]string, optional map[string]bool) (map[string][]float64, error) {
+	columns := make(map[string]*columnMetaData)
+	for _, column := range group.columns {
+		columns[strings.ToLower(column.getName())] = column
+	}
+
+	fields := make(map[string][]float64)
+	for _, field := range pointFields {
+		name := strings.ToLower(mapping[field])
+		column, ok := columns[name]
+		if !ok {
+			continue
+		}
+		chunk := make([]byte, column.totalCompressedSize)
+		if _, err := file.ReadAt(chunk, column.getStartOffset()); err != nil && err != io.EOF {
+			return nil, err
+		}
+		decoder := &columnDecoder{column: column}
+		if optional[name] {
+			decoder.maxDefinitionLevel = 1
+		}
+		values, err := decoder.decodeChunk(chunk, group.numRows)
+		if err != nil {
+			return nil, err
+		}
+		fields[field] = values
+	}
+	return fields, nil
+}
+
+// Returns the columns of the flat schema, by lower case name, mapped to true if their values are optional
+func getOptionalColumns(schema []*schemaElement) (map[string]bool, error) {
+	columns := make(map[string]bool)
+	for _, element := range schema[1:] {
+		if element.numChildren > 0 || element.repetition == repetitionRepeated {
+			return nil, errors.New("nested or repeated column " + element.name + " is not supported")
+		}
+		columns[strings.ToLower(element.name)] = element.repetition == repetitionOptional
+	}
+	return columns, nil
+}
+
+// Reads the metadata stored in the footer of a Parquet file
+func readFooter(file io.ReaderAt) (*fileMetaData, error) {
+	size, err := getFileSize(file)
+	if err != nil {
+		return nil, err
+	}
+	tail := make([]byte, 8)
+	if size < 12 {
+		return nil, errors.New("not a parquet file")
+	}
+	if _, err := file.ReadAt(tail, size-8); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(tail[4:], parquetMagic) {
+		return nil, errors.New("not a parquet file")
+	}
+	length := int64(binary.LittleEndian.Uint32(tail))
+	if length > size-12 {
+		return nil, errors.New("invalid parquet footer length")
+	}
+	footer := make([]byte, length)
+	if _, err := file.ReadAt(footer, size-8-length); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	metadata, err := readFileMetaData(newThriftReader(footer))
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata.schema) == 0 {
+		return nil, errors.New("parquet file without schema")
+	}
+	return metadata, nil
+}
+
+// Returns the size of the given file, found by probing the offsets it can be read at since the storages do not expose
+// the size of their files
+func getFileSize(file io.ReaderAt) (int64, error) {
+	probe := make([]byte, 1)
+	readable := func(offset int64) (bool, error) {
+		n, err := file.ReadAt(probe, offset)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		return n == 1, nil
+	}
+
+	// the last readable offset is searched between the last readable power of two and the next one
+	low, high := int64(-1), int64(1)
+	for {
+		ok, err := readable(high - 1)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		low, high = high-1, high*2
+	}
+	high--
+	for high-low > 1 {
+		middle := (low + high) / 2
+		ok, err := readable(middle)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return low + 1, nil
+}
+
+func clampToUint8(value float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, value)))
+}
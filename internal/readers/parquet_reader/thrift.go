@@ -0,0 +1,205 @@
+package parquet_reader
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// Types of the fields of the thrift compact protocol
+const (
+	compactStop   = 0
+	compactTrue   = 1
+	compactFalse  = 2
+	compactByte   = 3
+	compactI16    = 4
+	compactI32    = 5
+	compactI64    = 6
+	compactDouble = 7
+	compactBinary = 8
+	compactList   = 9
+	compactSet    = 10
+	compactMap    = 11
+	compactStruct = 12
+)
+
+var errTruncatedThrift = errors.New("truncated parquet metadata")
+
+// Decodes the structures serialized with the thrift compact protocol, in which Parquet stores its metadata
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func newThriftReader(data []byte) *thriftReader {
+	return &thriftReader{data: data}
+}
+
+func (r *thriftReader) readByte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncatedThrift
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *thriftReader) readVarint() (uint64, error) {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, errTruncatedThrift
+	}
+	r.pos += n
+	return value, nil
+}
+
+// Reads a zigzag encoded i16, i32 or i64 value
+func (r *thriftReader) readInt() (int64, error) {
+	value, err := r.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(value>>1) ^ -int64(value&1), nil
+}
+
+func (r *thriftReader) readInt32() (int32, error) {
+	value, err := r.readInt()
+	return int32(value), err
+}
+
+func (r *thriftReader) readBinary() ([]byte, error) {
+	length, err := r.readVarint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.data)-r.pos) < length {
+		return nil, errTruncatedThrift
+	}
+	value := r.data[r.pos : r.pos+int(length)]
+	r.pos += int(length)
+	return value, nil
+}
+
+func (r *thriftReader) readString() (string, error) {
+	value, err := r.readBinary()
+	return string(value), err
+}
+
+// Reads the header of a list or set returning its size and the type of its elements
+func (r *thriftReader) readListHeader() (int, byte, error) {
+	header, err := r.readByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	size := int(header >> 4)
+	if size == 15 {
+		longSize, err := r.readVarint()
+		if err != nil {
+			return 0, 0, err
+		}
+		if longSize > uint64(len(r.data)) {
+			return 0, 0, errTruncatedThrift
+		}
+		size = int(longSize)
+	}
+	return size, header & 0x0f, nil
+}
+
+// Reads a list whose elements are read by the given function, called once per element
+func (r *thriftReader) readList(element func(elementType byte) error) error {
+	size, elementType, err := r.readListHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < size; i++ {
+		if err := element(elementType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads a struct calling the given function for every field, which has to read or skip its value. Boolean values are
+// stored in the field type, compactTrue or compactFalse, and have no value to read.
+func (r *thriftReader) readStruct(field func(id int16, fieldType byte) error) error {
+	var lastId int16
+	for {
+		header, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		if header == compactStop {
+			return nil
+		}
+
+		id := lastId + int16(header>>4)
+		if header>>4 == 0 {
+			longId, err := r.readInt()
+			if err != nil {
+				return err
+			}
+			id = int16(longId)
+		}
+		lastId = id
+		if err := field(id, header&0x0f); err != nil {
+			return err
+		}
+	}
+}
+
+// Skips the value of the given type
+func (r *thriftReader) skip(valueType byte) error {
+	switch valueType {
+	case compactTrue, compactFalse:
+		return nil
+	case compactByte:
+		_, err := r.readByte()
+		return err
+	case compactI16, compactI32, compactI64:
+		_, err := r.readVarint()
+		return err
+	case compactDouble:
+		if len(r.data)-r.pos < 8 {
+			return errTruncatedThrift
+		}
+		r.pos += 8
+		return nil
+	case compactBinary:
+		_, err := r.readBinary()
+		return err
+	case compactList, compactSet:
+		return r.readList(r.skipElement)
+	case compactMap:
+		size, err := r.readVarint()
+		if err != nil || size == 0 {
+			return err
+		}
+		types, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < size; i++ {
+			if err := r.skipElement(types >> 4); err != nil {
+				return err
+			}
+			if err := r.skipElement(types & 0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case compactStruct:
+		return r.readStruct(func(id int16, fieldType byte) error {
+			return r.skip(fieldType)
+		})
+	}
+	return errors.New("invalid thrift type " + strconv.Itoa(int(valueType)))
+}
+
+// Skips an element of a container of the given type, where booleans take a byte each
+func (r *thriftReader) skipElement(elementType byte) error {
+	if elementType == compactTrue || elementType == compactFalse {
+		_, err := r.readByte()
+		return err
+	}
+	return r.skip(elementType)
+}
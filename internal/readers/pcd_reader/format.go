@@ -0,0 +1,292 @@
+package pcd_reader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Layouts of the data of the PCD files
+const (
+	asciiData            = "ascii"
+	binaryData           = "binary"
+	binaryCompressedData = "binary_compressed"
+)
+
+// Max size of a line of the header or of the ascii data
+const maxLineSize = 1 << 20
+
+// Field of the points of a PCD file, made of count values of the given size and type, I for the signed integers, U for
+// the unsigned ones and F for the floats
+type field struct {
+	name  string
+	size  int
+	kind  string
+	count int
+	// offset in bytes of the field from the beginning of the binary records
+	offset int
+	// index of the first value of the field among the values of the ascii records
+	token int
+}
+
+type header struct {
+	fields []*field
+	points int64
+	data   string
+	// size in bytes of the binary records
+	recordSize int
+	// number of values of the ascii records
+	tokens int
+}
+
+// Returns the index of the first field among the given names, -1 if none
+func (h *header) fieldIndex(names ...string) int {
+	for _, name := range names {
+		for i, f := range h.fields {
+			if f.name == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Reads the header of a PCD file, leaving the reader at the beginning of the data
+func readHeader(reader *bufio.Reader) (*header, error) {
+	h := &header{}
+	var names, sizes, kinds, counts []string
+	width, height := int64(-1), int64(1)
+	h.points = -1
+	for {
+		// the lines longer than the buffer of the reader fail, as the ones of the files that are not PCD ones
+		slice, err := reader.ReadSlice('\n')
+		if err != nil {
+			return nil, errors.New("not a PCD file")
+		}
+		tokens := strings.Fields(string(slice))
+		if len(tokens) == 0 || strings.HasPrefix(tokens[0], "#") {
+			continue
+		}
+		values := tokens[1:]
+		switch strings.ToUpper(tokens[0]) {
+		case "VERSION", "VIEWPOINT":
+		case "FIELDS":
+			names = values
+		case "SIZE":
+			sizes = values
+		case "TYPE":
+			kinds = values
+		case "COUNT":
+			counts = values
+		case "WIDTH", "HEIGHT", "POINTS":
+			if len(values) != 1 {
+				return nil, errors.New("invalid PCD " + tokens[0])
+			}
+			value, err := strconv.ParseInt(values[0], 10, 64)
+			if err != nil || value < 0 {
+				return nil, errors.New("invalid PCD " + tokens[0])
+			}
+			switch strings.ToUpper(tokens[0]) {
+			case "WIDTH":
+				width = value
+			case "HEIGHT":
+				height = value
+			default:
+				h.points = value
+			}
+		case "DATA":
+			if len(values) != 1 || (values[0] != asciiData && values[0] != binaryData && values[0] != binaryCompressedData) {
+				return nil, errors.New("unsupported PCD data " + strings.Join(values, " "))
+			}
+			h.data = values[0]
+			if h.points < 0 {
+				h.points = width * height
+			}
+			if h.points < 0 {
+				return nil, errors.New("missing PCD point count")
+			}
+			return h, h.setFields(names, sizes, kinds, counts)
+		default:
+			return nil, errors.New("not a PCD file")
+		}
+	}
+}
+
+// Sets the fields of the header declared by the FIELDS, SIZE, TYPE and optional COUNT lines
+func (h *header) setFields(names []string, sizes []string, kinds []string, counts []string) error {
+	if len(names) == 0 || len(sizes) != len(names) || len(kinds) != len(names) || (counts != nil && len(counts) != len(names)) {
+		return errors.New("the PCD fields, sizes and types do not match")
+	}
+	for i, name := range names {
+		f := &field{name: name, kind: strings.ToUpper(kinds[i]), count: 1, offset: h.recordSize, token: h.tokens}
+		var err error
+		if f.size, err = strconv.Atoi(sizes[i]); err != nil {
+			return errors.New("invalid size of the PCD field " + name)
+		}
+		if counts != nil {
+			if f.count, err = strconv.Atoi(counts[i]); err != nil || f.count < 1 {
+				return errors.New("invalid count of the PCD field " + name)
+			}
+		}
+		valid := (f.kind == "I" || f.kind == "U") && (f.size == 1 || f.size == 2 || f.size == 4 || f.size == 8) ||
+			f.kind == "F" && (f.size == 4 || f.size == 8)
+		if !valid {
+			return errors.New("unsupported type " + f.kind + strconv.Itoa(f.size) + " of the PCD field " + name)
+		}
+		h.fields = append(h.fields, f)
+		h.recordSize += f.size * f.count
+		h.tokens += f.count
+	}
+	return nil
+}
+
+// Reads the records of the points of a PCD file, returning the first value of every field and the bits of the first
+// value of the given color field, if not negative, which packs the red, green and blue components
+type recordReader interface {
+	next(values []float64) (uint32, error)
+}
+
+// Returns the reader of the records of the given header
+func newRecordReader(reader *bufio.Reader, h *header, color int) (recordReader, error) {
+	switch h.data {
+	case binaryData:
+		return &binaryRecordReader{reader: reader, header: h, color: color, record: make([]byte, h.recordSize)}, nil
+	case binaryCompressedData:
+		return newCompressedRecordReader(reader, h, color)
+	}
+	return &asciiRecordReader{reader: reader, header: h, color: color}, nil
+}
+
+// Reads the binary records, stored point after point
+type binaryRecordReader struct {
+	reader *bufio.Reader
+	header *header
+	color  int
+	record []byte
+}
+
+func (r *binaryRecordReader) next(values []float64) (uint32, error) {
+	if _, err := io.ReadFull(r.reader, r.record); err != nil {
+		return 0, errors.New("truncated PCD data")
+	}
+	return decodeRecord(r.record, r.header, r.color, values), nil
+}
+
+// Reads the compressed binary records, whose fields are stored one after the other for all the points once
+// decompressed
+type compressedRecordReader struct {
+	data   []byte
+	header *header
+	color  int
+	point  int64
+	record []byte
+}
+
+func newCompressedRecordReader(reader *bufio.Reader, h *header, color int) (recordReader, error) {
+	var sizes [2]uint32
+	if err := binary.Read(reader, binary.LittleEndian, &sizes); err != nil {
+		return nil, errors.New("truncated PCD data")
+	}
+	if int64(sizes[1]) != h.points*int64(h.recordSize) {
+		return nil, errors.New("the PCD compressed data does not hold the declared points")
+	}
+	compressed := make([]byte, sizes[0])
+	if _, err := io.ReadFull(reader, compressed); err != nil {
+		return nil, errors.New("truncated PCD data")
+	}
+	data, err := decompressLzf(compressed, int(sizes[1]))
+	if err != nil {
+		return nil, err
+	}
+	return &compressedRecordReader{data: data, header: h, color: color, record: make([]byte, h.recordSize)}, nil
+}
+
+func (r *compressedRecordReader) next(values []float64) (uint32, error) {
+	// the point is gathered from the columns of its fields
+	for _, f := range r.header.fields {
+		size := f.size * f.count
+		start := r.header.points*int64(f.offset) + r.point*int64(size)
+		copy(r.record[f.offset:f.offset+size], r.data[start:start+int64(size)])
+	}
+	r.point++
+	return decodeRecord(r.record, r.header, r.color, values), nil
+}
+
+// Decodes the first value of every field of the given little endian binary record
+func decodeRecord(record []byte, h *header, color int, values []float64) uint32 {
+	for i, f := range h.fields {
+		raw := record[f.offset:]
+		switch {
+		case f.kind == "F" && f.size == 4:
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw)))
+		case f.kind == "F":
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw))
+		case f.kind == "U":
+			values[i] = float64(readUnsigned(raw, f.size))
+		default:
+			unsigned := readUnsigned(raw, f.size)
+			// the sign bit of the value is extended
+			shift := uint(64 - 8*f.size)
+			values[i] = float64(int64(unsigned<<shift) >> shift)
+		}
+	}
+	if color < 0 || h.fields[color].size != 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(record[h.fields[color].offset:])
+}
+
+func readUnsigned(raw []byte, size int) uint64 {
+	switch size {
+	case 1:
+		return uint64(raw[0])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(raw))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(raw))
+	}
+	return binary.LittleEndian.Uint64(raw)
+}
+
+// Reads the ascii records, one point per line
+type asciiRecordReader struct {
+	reader *bufio.Reader
+	header *header
+	color  int
+}
+
+func (r *asciiRecordReader) next(values []float64) (uint32, error) {
+	var tokens []string
+	for len(tokens) == 0 {
+		line, err := r.reader.ReadSlice('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return 0, errors.New("truncated PCD data")
+		}
+		tokens = strings.Fields(string(line))
+	}
+	if len(tokens) != r.header.tokens {
+		return 0, errors.New("PCD record of " + strconv.Itoa(len(tokens)) + " values for " + strconv.Itoa(r.header.tokens) + " declared")
+	}
+	for i, f := range r.header.fields {
+		value, err := strconv.ParseFloat(tokens[f.token], 64)
+		if err != nil && !strings.EqualFold(tokens[f.token], "nan") {
+			return 0, errors.New("invalid PCD value " + tokens[f.token])
+		}
+		if err != nil {
+			value = math.NaN()
+		}
+		values[i] = value
+	}
+	if r.color < 0 {
+		return 0, nil
+	}
+	// the packed colors of the float fields are written as the float of the same bits
+	if r.header.fields[r.color].kind == "F" {
+		return math.Float32bits(float32(values[r.color])), nil
+	}
+	return uint32(values[r.color]), nil
+}
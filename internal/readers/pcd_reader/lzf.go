@@ -0,0 +1,51 @@
+package pcd_reader
+
+import "errors"
+
+// Decompresses the given LZF data, the compression of the binary_compressed PCD files, into a buffer of the given
+// size. The data is a sequence of literal runs, whose control byte is their length minus 1 below 32, and of back
+// references, whose control byte holds their length minus 2 in its 3 high bits, extended by the next byte if they are
+// all set, and the high bits of their distance minus 1, whose low byte follows.
+func decompressLzf(data []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, size)
+	corrupted := errors.New("corrupted LZF data")
+	for i := 0; i < len(data); {
+		control := int(data[i])
+		i++
+		if control < 32 {
+			length := control + 1
+			if i+length > len(data) || len(out)+length > size {
+				return nil, corrupted
+			}
+			out = append(out, data[i:i+length]...)
+			i += length
+			continue
+		}
+
+		length := control >> 5
+		if length == 7 {
+			if i >= len(data) {
+				return nil, corrupted
+			}
+			length += int(data[i])
+			i++
+		}
+		if i >= len(data) {
+			return nil, corrupted
+		}
+		reference := len(out) - (control&0x1f)<<8 - int(data[i]) - 1
+		i++
+		length += 2
+		if reference < 0 || len(out)+length > size {
+			return nil, corrupted
+		}
+		// the reference can overlap the bytes being copied, repeating them
+		for j := 0; j < length; j++ {
+			out = append(out, out[reference+j])
+		}
+	}
+	if len(out) != size {
+		return nil, corrupted
+	}
+	return out, nil
+}
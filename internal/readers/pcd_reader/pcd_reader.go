@@ -0,0 +1,155 @@
+package pcd_reader
+
+import (
+	"bufio"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"math"
+)
+
+// Number of points read between two checks of the cancellation token
+const cancellationCheckInterval = 65536
+
+// Names of the fields the point fields are read from, in order of precedence, as written by the Point Cloud Library
+var pointFields = map[string][]string{
+	"x":              {"x"},
+	"y":              {"y"},
+	"z":              {"z"},
+	"intensity":      {"intensity"},
+	"classification": {"classification", "label"},
+	"time":           {"timestamp", "time", "t", "gps_time"},
+	"normal_x":       {"normal_x"},
+	"normal_y":       {"normal_y"},
+	"normal_z":       {"normal_z"},
+}
+
+// Names of the fields packing the colors of the points as 0x00RRGGBB, or 0xAARRGGBB
+var colorFields = []string{"rgb", "rgba"}
+
+// Reads point clouds stored as PCD files, with ascii, binary or binary_compressed data, adding a point per point of the
+// file. The VIEWPOINT of the files is not applied, the points being taken in the frame they are stored in.
+type PcdReader struct {
+	attributes   []string
+	transformer  readers.PointTransformer
+	storage      storage.Storage
+	cancellation *cancellation.Token
+}
+
+// Instantiates a new PcdReader reading files from the given storage. The given supplementary attributes, named as in
+// tiler.LasAttributes, are attached to the points, the components of the normals being read from the normal_x,
+// normal_y and normal_z fields, the GPS time from the time of the points and the other attributes being 0. If the
+// transformer is not nil every point is moved by it according to its time. Reading stops between two chunks of points
+// once the given cancellation token, if any, is cancelled.
+func NewPcdReader(attributes []string, transformer readers.PointTransformer, storage storage.Storage, cancellation *cancellation.Token) readers.Reader {
+	return &PcdReader{
+		attributes:   attributes,
+		transformer:  transformer,
+		storage:      storage,
+		cancellation: cancellation,
+	}
+}
+
+func (r *PcdReader) Read(filePath string, srid int, tree octree.ITree) error {
+	file, err := r.storage.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReaderSize(file, maxLineSize)
+	header, err := readHeader(reader)
+	if err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	if err := r.readPoints(reader, header, srid, tree); err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	return nil
+}
+
+// Adds to the tree the points of the file, skipping the ones with NaN coordinates as the invalid points of the
+// organized clouds
+func (r *PcdReader) readPoints(reader *bufio.Reader, header *header, srid int, tree octree.ITree) error {
+	fields := make(map[string]int)
+	for field, names := range pointFields {
+		fields[field] = header.fieldIndex(names...)
+	}
+	for _, field := range []string{"x", "y", "z"} {
+		if fields[field] < 0 {
+			return errors.New("missing field " + field)
+		}
+	}
+	if r.transformer != nil && fields["time"] < 0 {
+		return errors.New("points must have a time to be transformed, missing field timestamp or time")
+	}
+	color := header.fieldIndex(colorFields...)
+	records, err := newRecordReader(reader, header, color)
+	if err != nil {
+		return err
+	}
+	value := func(record []float64, field string) float64 {
+		if index := fields[field]; index >= 0 {
+			return record[index]
+		}
+		return 0
+	}
+
+	record := make([]float64, len(header.fields))
+	for point := int64(0); point < header.points; point++ {
+		if point%cancellationCheckInterval == 0 {
+			if err := r.cancellation.Err(); err != nil {
+				return err
+			}
+		}
+		rgb, err := records.next(record)
+		if err != nil {
+			return err
+		}
+		x, y, z := value(record, "x"), value(record, "y"), value(record, "z")
+		if math.IsNaN(x) || math.IsNaN(y) || math.IsNaN(z) {
+			continue
+		}
+		coordinate, pointSrid := &geometry.Coordinate{X: x, Y: y, Z: z}, srid
+		if r.transformer != nil {
+			coordinate, pointSrid = r.transformer.Transform(coordinate, value(record, "time"), srid)
+		}
+		var attributes []float32
+		if len(r.attributes) > 0 {
+			attributes = make([]float32, len(r.attributes))
+			for i, name := range r.attributes {
+				if name == "gps_time" {
+					attributes[i] = float32(value(record, "time"))
+				} else if _, ok := pointFields[name]; ok {
+					attributes[i] = float32(value(record, name))
+				}
+			}
+		}
+		intensity := value(record, "intensity")
+		if index := fields["intensity"]; index >= 0 && header.fields[index].kind == "U" && header.fields[index].size == 2 {
+			// the 16 bits intensities are scaled down as the ones of the LAS files
+			intensity /= 256
+		}
+		tree.AddPoint(
+			coordinate,
+			uint8(rgb>>16),
+			uint8(rgb>>8),
+			uint8(rgb),
+			clampToUint8(intensity),
+			clampToUint8(value(record, "classification")),
+			pointSrid,
+			attributes,
+		)
+	}
+	return nil
+}
+
+func clampToUint8(value float64) uint8 {
+	if math.IsNaN(value) {
+		return 0
+	}
+	return uint8(math.Max(0, math.Min(255, value)))
+}
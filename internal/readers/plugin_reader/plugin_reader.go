@@ -0,0 +1,220 @@
+package plugin_reader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Magic number opening the point stream written by the plugins
+var magic = []byte("GCTP")
+
+// Versions of the point stream protocol: version 1 streams the records until the end of the output, version 2 groups
+// them in frames prefixed by their length, a zero length frame closing the stream
+const (
+	versionRecords = 1
+	versionFrames  = 2
+)
+
+// Max length of a frame of a version 2 stream
+const maxFrameSize = 64 * 1024 * 1024
+
+// Argument of the command replaced by the path of the file, appended as last argument if missing
+const InputPlaceholder = "{input}"
+
+// Size of a point record of the stream: x, y and z as float64, r, g, b, intensity and classification as uint8 and
+// the GPS time as float64, all little endian
+const recordSize = 3*8 + 5 + 8
+
+// Max number of bytes of the standard error of a failed plugin reported in the error
+const maxStderrSize = 4096
+
+// Reads point clouds through an external program, typically a converter linked against a closed-source vendor SDK
+// that cannot be integrated in the tool or a bridge to a library such as PDAL. The program is run with the path of the
+// file in place of the {input} argument, or as last argument, and writes the points on its standard output as a stream
+// opening with the GCTP magic and the protocol version as a little endian uint32. Version 1 streams follow with a
+// record per point until the end of the output, version 2 ones with frames made of their length in bytes as a little
+// endian uint32 and of a whole number of records, up to a zero length frame ending the stream, which makes truncated
+// streams detectable. Every record holds x, y and z as float64, in the srid of the job, r, g, b, intensity and
+// classification as uint8 and the GPS time as float64, all little endian. The program has to exit with status 0,
+// failures being reported along with its standard error.
+type PluginReader struct {
+	command      []string
+	transformer  readers.PointTransformer
+	cancellation *cancellation.Token
+}
+
+// Instantiates a new PluginReader running the given program and arguments. Plugins read the files from the local
+// filesystem. If the transformer is not nil every point is moved by it according to its GPS time. The program is
+// killed once the given cancellation token, if any, is cancelled.
+func NewPluginReader(command []string, transformer readers.PointTransformer, cancellation *cancellation.Token) readers.Reader {
+	return &PluginReader{
+		command:      command,
+		transformer:  transformer,
+		cancellation: cancellation,
+	}
+}
+
+func (r *PluginReader) Read(filePath string, srid int, tree octree.ITree) error {
+	if len(r.command) == 0 {
+		return errors.New("no plugin command to read " + filePath)
+	}
+	cmd := exec.Command(r.command[0], getArguments(r.command[1:], filePath)...)
+	stderr := &limitedBuffer{limit: maxStderrSize}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.New("cannot start plugin " + r.command[0] + ": " + err.Error())
+	}
+
+	readErr := r.readStream(bufio.NewReaderSize(stdout, recordSize*4096), srid, tree)
+	if readErr != nil {
+		// the plugin is not waited for output anymore
+		_ = cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	if readErr == cancellation.ErrCancelled {
+		return readErr
+	}
+	if waitErr != nil {
+		message := "plugin " + r.command[0] + " failed reading " + filePath + ": " + waitErr.Error()
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			message += ": " + output
+		}
+		return errors.New(message)
+	}
+	if readErr != nil {
+		return errors.New("plugin " + r.command[0] + " reading " + filePath + ": " + readErr.Error())
+	}
+	return nil
+}
+
+// Reads the point stream written by the plugin, adding its points to the tree
+func (r *PluginReader) readStream(stream io.Reader, srid int, tree octree.ITree) error {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(stream, header); err != nil {
+		return errors.New("missing stream header")
+	}
+	if !bytes.Equal(header[:4], magic) {
+		return errors.New("invalid stream header")
+	}
+	switch streamVersion := binary.LittleEndian.Uint32(header[4:]); streamVersion {
+	case versionRecords:
+		return r.readRecords(stream, srid, tree)
+	case versionFrames:
+		return r.readFrames(stream, srid, tree)
+	default:
+		return errors.New("unsupported stream version " + strconv.Itoa(int(streamVersion)))
+	}
+}
+
+// Reads the records of a version 1 stream up to its end
+func (r *PluginReader) readRecords(stream io.Reader, srid int, tree octree.ITree) error {
+	record := make([]byte, recordSize)
+	for count := 0; ; count++ {
+		if count%cancellation.CheckInterval == 0 {
+			if err := r.cancellation.Err(); err != nil {
+				return err
+			}
+		}
+		if _, err := io.ReadFull(stream, record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.New("truncated point record")
+		}
+		r.addPoint(record, srid, tree)
+	}
+}
+
+// Reads the frames of a version 2 stream up to the zero length one
+func (r *PluginReader) readFrames(stream io.Reader, srid int, tree octree.ITree) error {
+	prefix := make([]byte, 4)
+	var frame []byte
+	for {
+		if err := r.cancellation.Err(); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(stream, prefix); err != nil {
+			return errors.New("stream ended without its closing frame")
+		}
+		length := binary.LittleEndian.Uint32(prefix)
+		if length == 0 {
+			return nil
+		}
+		if length%recordSize != 0 || length > maxFrameSize {
+			return errors.New("invalid frame length " + strconv.Itoa(int(length)))
+		}
+		if cap(frame) < int(length) {
+			frame = make([]byte, length)
+		}
+		frame = frame[:length]
+		if _, err := io.ReadFull(stream, frame); err != nil {
+			return errors.New("truncated frame")
+		}
+		for offset := 0; offset < len(frame); offset += recordSize {
+			r.addPoint(frame[offset:offset+recordSize], srid, tree)
+		}
+	}
+}
+
+// Adds the point of the given record to the tree
+func (r *PluginReader) addPoint(record []byte, srid int, tree octree.ITree) {
+	coordinate := &geometry.Coordinate{
+		X: math.Float64frombits(binary.LittleEndian.Uint64(record[0:])),
+		Y: math.Float64frombits(binary.LittleEndian.Uint64(record[8:])),
+		Z: math.Float64frombits(binary.LittleEndian.Uint64(record[16:])),
+	}
+	pointSrid := srid
+	if r.transformer != nil {
+		gpsTime := math.Float64frombits(binary.LittleEndian.Uint64(record[29:]))
+		coordinate, pointSrid = r.transformer.Transform(coordinate, gpsTime, srid)
+	}
+	tree.AddPoint(coordinate, record[24], record[25], record[26], record[27], record[28], pointSrid, nil)
+}
+
+// Returns the given arguments with the {input} ones replaced by the file path, which is appended if none is found
+func getArguments(arguments []string, filePath string) []string {
+	result := make([]string, 0, len(arguments)+1)
+	replaced := false
+	for _, argument := range arguments {
+		if strings.Contains(argument, InputPlaceholder) {
+			argument = strings.ReplaceAll(argument, InputPlaceholder, filePath)
+			replaced = true
+		}
+		result = append(result, argument)
+	}
+	if !replaced {
+		result = append(result, filePath)
+	}
+	return result
+}
+
+// Buffer keeping the first bytes written to it up to its limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
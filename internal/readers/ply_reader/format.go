@@ -0,0 +1,256 @@
+package ply_reader
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Formats of the body of the PLY files
+const (
+	asciiFormat        = "ascii"
+	littleEndianFormat = "binary_little_endian"
+	bigEndianFormat    = "binary_big_endian"
+)
+
+// Max size of the header, which guards against reading a whole file that is not a PLY one
+const maxHeaderSize = 1 << 20
+
+// Size in bytes of the scalar types of the properties, by their name and by their alias
+var scalarSizes = map[string]int{
+	"char": 1, "int8": 1, "uchar": 1, "uint8": 1,
+	"short": 2, "int16": 2, "ushort": 2, "uint16": 2,
+	"int": 4, "int32": 4, "uint": 4, "uint32": 4,
+	"float": 4, "float32": 4, "double": 8, "float64": 8,
+}
+
+// Property of the records of an element, either a scalar or a list of scalars preceded by their count
+type property struct {
+	name string
+	kind string
+	// type of the count of the lists, empty for the scalars
+	countKind string
+}
+
+// Element of a PLY file, e.g. the vertices or the faces, made of count records holding the properties
+type element struct {
+	name       string
+	count      int64
+	properties []property
+}
+
+// Returns the index of the first scalar property among the given names, -1 if none
+func (e *element) propertyIndex(names []string) int {
+	for _, name := range names {
+		for i, p := range e.properties {
+			if p.name == name && p.countKind == "" {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Returns the scalar type of the property of the given index, empty if the index is negative
+func (e *element) propertyKind(index int) string {
+	if index < 0 {
+		return ""
+	}
+	return e.properties[index].kind
+}
+
+type header struct {
+	format   string
+	elements []*element
+}
+
+// Reads the header of a PLY file, leaving the reader at the beginning of the body
+func readHeader(reader *bufio.Reader) (*header, error) {
+	h := &header{}
+	read := 0
+	for lineNumber := 0; ; lineNumber++ {
+		// the lines longer than the buffer of the reader fail, as the ones of the files that are not PLY ones
+		slice, err := reader.ReadSlice('\n')
+		line := string(slice)
+		read += len(line)
+		if err != nil || read > maxHeaderSize {
+			if lineNumber == 0 {
+				return nil, errors.New("not a PLY file")
+			}
+			return nil, errors.New("truncated PLY header")
+		}
+		tokens := strings.Fields(line)
+		if lineNumber == 0 {
+			if len(tokens) != 1 || tokens[0] != "ply" {
+				return nil, errors.New("not a PLY file")
+			}
+			continue
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		switch tokens[0] {
+		case "format":
+			if len(tokens) != 3 || (tokens[1] != asciiFormat && tokens[1] != littleEndianFormat && tokens[1] != bigEndianFormat) {
+				return nil, errors.New("unsupported PLY format " + strings.TrimSpace(line))
+			}
+			h.format = tokens[1]
+		case "element":
+			if len(tokens) != 3 {
+				return nil, errors.New("invalid PLY element " + strings.TrimSpace(line))
+			}
+			count, err := strconv.ParseInt(tokens[2], 10, 64)
+			if err != nil || count < 0 {
+				return nil, errors.New("invalid count of the PLY element " + tokens[1])
+			}
+			h.elements = append(h.elements, &element{name: tokens[1], count: count})
+		case "property":
+			if len(h.elements) == 0 {
+				return nil, errors.New("PLY property declared before any element")
+			}
+			p, err := parseProperty(tokens)
+			if err != nil {
+				return nil, err
+			}
+			current := h.elements[len(h.elements)-1]
+			current.properties = append(current.properties, p)
+		case "end_header":
+			if h.format == "" {
+				return nil, errors.New("missing PLY format")
+			}
+			return h, nil
+		}
+	}
+}
+
+func parseProperty(tokens []string) (property, error) {
+	if len(tokens) == 3 && scalarSizes[tokens[1]] > 0 {
+		return property{name: tokens[2], kind: tokens[1]}, nil
+	}
+	if len(tokens) == 5 && tokens[1] == "list" && scalarSizes[tokens[2]] > 0 && scalarSizes[tokens[3]] > 0 {
+		return property{name: tokens[4], kind: tokens[3], countKind: tokens[2]}, nil
+	}
+	return property{}, errors.New("invalid PLY property " + strings.Join(tokens, " "))
+}
+
+// Reads the values of the records of the body of a PLY file
+type valueReader interface {
+	// Reads the next value of the given scalar type
+	next(kind string) (float64, error)
+}
+
+// Returns the reader of the values of the given format
+func newValueReader(reader *bufio.Reader, format string) valueReader {
+	switch format {
+	case littleEndianFormat:
+		return &binaryValueReader{reader: reader, order: binary.LittleEndian}
+	case bigEndianFormat:
+		return &binaryValueReader{reader: reader, order: binary.BigEndian}
+	}
+	return &asciiValueReader{reader: reader}
+}
+
+// Reads the next record of the given element, storing the values of its scalar properties in the given slice, while
+// the lists are skipped
+func readRecord(values valueReader, e *element, record []float64) error {
+	for i, p := range e.properties {
+		if p.countKind == "" {
+			value, err := values.next(p.kind)
+			if err != nil {
+				return err
+			}
+			record[i] = value
+			continue
+		}
+		count, err := values.next(p.countKind)
+		if err != nil {
+			return err
+		}
+		if count < 0 || count > math.MaxInt32 {
+			return errors.New("invalid length of the PLY list " + p.name)
+		}
+		for item := 0; item < int(count); item++ {
+			if _, err := values.next(p.kind); err != nil {
+				return err
+			}
+		}
+		record[i] = 0
+	}
+	return nil
+}
+
+// Skips all the records of the given element
+func skipElement(values valueReader, e *element) error {
+	record := make([]float64, len(e.properties))
+	for i := int64(0); i < e.count; i++ {
+		if err := readRecord(values, e, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type binaryValueReader struct {
+	reader  *bufio.Reader
+	order   binary.ByteOrder
+	scratch [8]byte
+}
+
+func (r *binaryValueReader) next(kind string) (float64, error) {
+	raw := r.scratch[:scalarSizes[kind]]
+	if _, err := io.ReadFull(r.reader, raw); err != nil {
+		return 0, errors.New("truncated PLY body")
+	}
+	switch kind {
+	case "char", "int8":
+		return float64(int8(raw[0])), nil
+	case "uchar", "uint8":
+		return float64(raw[0]), nil
+	case "short", "int16":
+		return float64(int16(r.order.Uint16(raw))), nil
+	case "ushort", "uint16":
+		return float64(r.order.Uint16(raw)), nil
+	case "int", "int32":
+		return float64(int32(r.order.Uint32(raw))), nil
+	case "uint", "uint32":
+		return float64(r.order.Uint32(raw)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(r.order.Uint32(raw))), nil
+	}
+	return math.Float64frombits(r.order.Uint64(raw)), nil
+}
+
+// Reads the values of an ASCII body, separated by white spaces
+type asciiValueReader struct {
+	reader *bufio.Reader
+	token  []byte
+}
+
+func (r *asciiValueReader) next(kind string) (float64, error) {
+	r.token = r.token[:0]
+	for {
+		c, err := r.reader.ReadByte()
+		if err != nil {
+			if len(r.token) > 0 {
+				break
+			}
+			return 0, errors.New("truncated PLY body")
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if len(r.token) > 0 {
+				break
+			}
+			continue
+		}
+		r.token = append(r.token, c)
+	}
+	value, err := strconv.ParseFloat(string(r.token), 64)
+	if err != nil {
+		return 0, errors.New("invalid PLY value " + string(r.token))
+	}
+	return value, nil
+}
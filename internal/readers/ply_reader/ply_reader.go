@@ -0,0 +1,169 @@
+package ply_reader
+
+import (
+	"bufio"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"math"
+)
+
+// Number of vertices read between two checks of the cancellation token
+const cancellationCheckInterval = 65536
+
+// Names of the vertex properties the point fields are read from, in order of precedence. The colors come from the
+// photogrammetry and mesh tools, the scalar_ properties from the scalar fields exported by CloudCompare.
+var pointFields = map[string][]string{
+	"x":              {"x"},
+	"y":              {"y"},
+	"z":              {"z"},
+	"r":              {"red", "diffuse_red", "r"},
+	"g":              {"green", "diffuse_green", "g"},
+	"b":              {"blue", "diffuse_blue", "b"},
+	"intensity":      {"intensity", "scalar_intensity"},
+	"classification": {"classification", "scalar_classification", "label"},
+	"time":           {"gps_time", "time", "timestamp", "scalar_gps_time"},
+	"normal_x":       {"nx", "normal_x"},
+	"normal_y":       {"ny", "normal_y"},
+	"normal_z":       {"nz", "normal_z"},
+}
+
+// Reads point clouds stored as PLY files, either ASCII or binary, adding a point per vertex of the vertex element. The
+// other elements, e.g. the faces of meshes, are skipped.
+type PlyReader struct {
+	attributes   []string
+	transformer  readers.PointTransformer
+	storage      storage.Storage
+	cancellation *cancellation.Token
+}
+
+// Instantiates a new PlyReader reading files from the given storage. The given supplementary attributes, named as in
+// tiler.LasAttributes, are attached to the points, the components of the normals being read from the nx, ny and nz
+// properties, the GPS time from the time of the points and the other attributes being 0. If the transformer is not nil
+// every point is moved by it according to its time. Reading stops between two chunks of vertices once the given
+// cancellation token, if any, is cancelled.
+func NewPlyReader(attributes []string, transformer readers.PointTransformer, storage storage.Storage, cancellation *cancellation.Token) readers.Reader {
+	return &PlyReader{
+		attributes:   attributes,
+		transformer:  transformer,
+		storage:      storage,
+		cancellation: cancellation,
+	}
+}
+
+func (r *PlyReader) Read(filePath string, srid int, tree octree.ITree) error {
+	file, err := r.storage.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReaderSize(file, 1<<20)
+	header, err := readHeader(reader)
+	if err != nil {
+		return errors.New(filePath + ": " + err.Error())
+	}
+	values := newValueReader(reader, header.format)
+	for _, element := range header.elements {
+		if element.name != "vertex" {
+			if err := skipElement(values, element); err != nil {
+				return errors.New(filePath + ": " + err.Error())
+			}
+			continue
+		}
+		if err := r.readVertices(element, values, srid, tree); err != nil {
+			return errors.New(filePath + ": " + err.Error())
+		}
+		return nil
+	}
+	return errors.New(filePath + ": missing vertex element")
+}
+
+// Adds to the tree a point per vertex, skipping the ones with NaN coordinates
+func (r *PlyReader) readVertices(element *element, values valueReader, srid int, tree octree.ITree) error {
+	fields := make(map[string]int)
+	for field, names := range pointFields {
+		fields[field] = element.propertyIndex(names)
+	}
+	for _, field := range []string{"x", "y", "z"} {
+		if fields[field] < 0 {
+			return errors.New("missing vertex property " + field)
+		}
+	}
+	if r.transformer != nil && fields["time"] < 0 {
+		return errors.New("points must have a time to be transformed, missing vertex property gps_time or time")
+	}
+	value := func(record []float64, field string) float64 {
+		if index := fields[field]; index >= 0 {
+			return record[index]
+		}
+		return 0
+	}
+	color := func(record []float64, field string) uint8 {
+		return scaleToUint8(value(record, field), element.propertyKind(fields[field]), true)
+	}
+
+	record := make([]float64, len(element.properties))
+	for vertex := int64(0); vertex < element.count; vertex++ {
+		if vertex%cancellationCheckInterval == 0 {
+			if err := r.cancellation.Err(); err != nil {
+				return err
+			}
+		}
+		if err := readRecord(values, element, record); err != nil {
+			return err
+		}
+		x, y, z := value(record, "x"), value(record, "y"), value(record, "z")
+		if math.IsNaN(x) || math.IsNaN(y) || math.IsNaN(z) {
+			continue
+		}
+		coordinate, pointSrid := &geometry.Coordinate{X: x, Y: y, Z: z}, srid
+		if r.transformer != nil {
+			coordinate, pointSrid = r.transformer.Transform(coordinate, value(record, "time"), srid)
+		}
+		var attributes []float32
+		if len(r.attributes) > 0 {
+			attributes = make([]float32, len(r.attributes))
+			for i, name := range r.attributes {
+				if name == "gps_time" {
+					attributes[i] = float32(value(record, "time"))
+				} else if _, ok := pointFields[name]; ok {
+					attributes[i] = float32(value(record, name))
+				}
+			}
+		}
+		tree.AddPoint(
+			coordinate,
+			color(record, "r"),
+			color(record, "g"),
+			color(record, "b"),
+			scaleToUint8(value(record, "intensity"), element.propertyKind(fields["intensity"]), false),
+			clampToUint8(value(record, "classification")),
+			pointSrid,
+			attributes,
+		)
+	}
+	return nil
+}
+
+// Converts a color or an intensity of the given type to 8 bits: the 16 bits values are scaled down as the ones of the
+// LAS files and the float colors, ranging from 0 to 1, are scaled up. The other values are clamped.
+func scaleToUint8(value float64, kind string, color bool) uint8 {
+	switch {
+	case kind == "ushort" || kind == "uint16":
+		return clampToUint8(value / 256)
+	case color && (kind == "float" || kind == "float32" || kind == "double" || kind == "float64"):
+		return clampToUint8(math.Round(value * 255))
+	}
+	return clampToUint8(value)
+}
+
+func clampToUint8(value float64) uint8 {
+	if math.IsNaN(value) {
+		return 0
+	}
+	return uint8(math.Max(0, math.Min(255, value)))
+}
@@ -0,0 +1,13 @@
+package readers
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+)
+
+// A PointTransformer moves the points read from the input files before they are added to the tree, according to the
+// time they were acquired at. It allows to georeference raw data recorded by moving sensors.
+type PointTransformer interface {
+	// Transforms the given coordinate, expressed in the given srid and acquired at the given time, returning the
+	// transformed coordinate and the srid it is expressed in
+	Transform(coordinate *geometry.Coordinate, time float64, srid int) (*geometry.Coordinate, int)
+}
@@ -0,0 +1,11 @@
+package readers
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+)
+
+// A Reader parses a point cloud file and loads its points into a tree
+type Reader interface {
+	// Reads all the points of the given file, whose coordinates are expressed in the given srid, adding them to the tree
+	Read(filePath string, srid int, tree octree.ITree) error
+}
@@ -0,0 +1,237 @@
+package rosbag_reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io"
+	"io/ioutil"
+)
+
+// Magic line opening every ROS bag file in format 2.0
+const bagMagic = "#ROSBAG V2.0\n"
+
+// Record op codes of the ROS bag 2.0 format
+const (
+	opMessageData = 0x02
+	opChunk       = 0x05
+	opConnection  = 0x07
+)
+
+// A bag record, made of a header of name=value fields and a data section
+type record struct {
+	header map[string][]byte
+	data   []byte
+}
+
+// A connection links the messages stored in the bag to the topic they were published on and to their type
+type connection struct {
+	id      uint32
+	topic   string
+	msgType string
+}
+
+// A serialized message along with the connection it was recorded on and its timestamp in seconds
+type message struct {
+	conn *connection
+	time float64
+	data []byte
+}
+
+// Sequentially reads ROS bag 2.0 files resolving the connections of the stored messages
+type bagReader struct {
+	connections map[uint32]*connection
+	storage     storage.Storage
+}
+
+func newBagReader(storage storage.Storage) *bagReader {
+	return &bagReader{
+		connections: make(map[uint32]*connection),
+		storage:     storage,
+	}
+}
+
+// Iterates all the messages stored in the given bag file in the order they were written, invoking the callback
+// for each of them. Iteration stops at the first error returned by the callback.
+func (b *bagReader) forEachMessage(filePath string, callback func(*message) error) error {
+	file, err := b.storage.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(bagMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != bagMagic {
+		return errors.New("not a ROS bag 2.0 file: " + filePath)
+	}
+
+	return b.readRecords(reader, func(rec *record) error {
+		if getOp(rec) != opChunk {
+			return b.processRecord(rec, callback)
+		}
+
+		chunkData, err := decompressChunk(rec)
+		if err != nil {
+			return err
+		}
+
+		return b.readRecords(bytes.NewReader(chunkData), func(chunkRecord *record) error {
+			return b.processRecord(chunkRecord, callback)
+		})
+	})
+}
+
+// Reads all the records available in the given reader until EOF
+func (b *bagReader) readRecords(reader io.Reader, process func(*record) error) error {
+	for {
+		rec, err := readRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := process(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// Registers connection records and submits message records to the callback, ignoring all other records
+func (b *bagReader) processRecord(rec *record, callback func(*message) error) error {
+	switch getOp(rec) {
+	case opConnection:
+		conn, err := parseConnection(rec)
+		if err != nil {
+			return err
+		}
+		b.connections[conn.id] = conn
+	case opMessageData:
+		connId, err := getUint32Field(rec.header, "conn")
+		if err != nil {
+			return err
+		}
+		conn, ok := b.connections[connId]
+		if !ok {
+			return errors.New("message references an unknown connection")
+		}
+		timeField, ok := rec.header["time"]
+		if !ok || len(timeField) != 8 {
+			return errors.New("message record without a valid time field")
+		}
+		sec := binary.LittleEndian.Uint32(timeField[0:4])
+		nsec := binary.LittleEndian.Uint32(timeField[4:8])
+		return callback(&message{
+			conn: conn,
+			time: float64(sec) + float64(nsec)*1e-9,
+			data: rec.data,
+		})
+	}
+
+	return nil
+}
+
+// Reads a single record from the given reader, returning io.EOF if no more records are available
+func readRecord(reader io.Reader) (*record, error) {
+	header, err := readLengthPrefixedBlock(reader)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readLengthPrefixedBlock(reader)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseHeaderFields(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record{header: fields, data: data}, nil
+}
+
+// Reads a block of bytes preceded by its uint32 length
+func readLengthPrefixedBlock(reader io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	block := make([]byte, length)
+	if _, err := io.ReadFull(reader, block); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return block, nil
+}
+
+// Parses a sequence of length prefixed name=value fields
+func parseHeaderFields(header []byte) (map[string][]byte, error) {
+	fields := make(map[string][]byte)
+	for len(header) > 0 {
+		if len(header) < 4 {
+			return nil, errors.New("malformed record header")
+		}
+		length := int(binary.LittleEndian.Uint32(header[0:4]))
+		if len(header) < 4+length {
+			return nil, errors.New("malformed record header")
+		}
+		field := header[4 : 4+length]
+		separator := bytes.IndexByte(field, '=')
+		if separator < 0 {
+			return nil, errors.New("malformed record header field")
+		}
+		fields[string(field[:separator])] = field[separator+1:]
+		header = header[4+length:]
+	}
+	return fields, nil
+}
+
+func parseConnection(rec *record) (*connection, error) {
+	connId, err := getUint32Field(rec.header, "conn")
+	if err != nil {
+		return nil, err
+	}
+	// the connection type is stored along other connection metadata in the record data section, using the header format
+	connectionHeader, err := parseHeaderFields(rec.data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &connection{
+		id:      connId,
+		topic:   string(rec.header["topic"]),
+		msgType: string(connectionHeader["type"]),
+	}, nil
+}
+
+func decompressChunk(rec *record) ([]byte, error) {
+	switch compression := string(rec.header["compression"]); compression {
+	case "none":
+		return rec.data, nil
+	case "bz2":
+		return ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(rec.data)))
+	default:
+		return nil, errors.New("unsupported chunk compression '" + compression + "', decompress the bag with 'rosbag decompress'")
+	}
+}
+
+func getOp(rec *record) byte {
+	op := rec.header["op"]
+	if len(op) != 1 {
+		return 0
+	}
+	return op[0]
+}
+
+func getUint32Field(header map[string][]byte, name string) (uint32, error) {
+	value, ok := header[name]
+	if !ok || len(value) != 4 {
+		return 0, errors.New("missing or invalid record header field " + name)
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
@@ -0,0 +1,249 @@
+package rosbag_reader
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/trajectory"
+	"math"
+)
+
+// Message types supported by the reader
+const (
+	pointCloud2Type      = "sensor_msgs/PointCloud2"
+	poseStampedType      = "geometry_msgs/PoseStamped"
+	transformStampedType = "geometry_msgs/TransformStamped"
+	odometryType         = "nav_msgs/Odometry"
+)
+
+// sensor_msgs/PointField datatype codes
+const (
+	int8Type    = 1
+	uint8Type   = 2
+	int16Type   = 3
+	uint16Type  = 4
+	int32Type   = 5
+	uint32Type  = 6
+	float32Type = 7
+	float64Type = 8
+)
+
+var errTruncatedMessage = errors.New("truncated ROS message")
+
+// Decodes the primitive types of the ROS1 message serialization format, recording the first error met
+type decoder struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (d *decoder) next(size int) []byte {
+	if d.err != nil || d.offset+size > len(d.data) {
+		d.err = errTruncatedMessage
+		// primitive decoders need a zeroed buffer, variable length blocks are returned as nil
+		if size > 8 {
+			return nil
+		}
+		return make([]byte, size)
+	}
+	out := d.data[d.offset : d.offset+size]
+	d.offset += size
+	return out
+}
+
+func (d *decoder) uint8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) uint32() uint32 {
+	return binary.LittleEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) float64() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(d.next(8)))
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) bytes() []byte {
+	length := d.uint32()
+	if d.err != nil {
+		return nil
+	}
+	return d.next(int(length))
+}
+
+// decodes a std_msgs/Header returning its timestamp in seconds and its frame id
+func (d *decoder) header() (float64, string) {
+	_ = d.uint32() // seq
+	sec := d.uint32()
+	nsec := d.uint32()
+	frameId := d.string()
+	return float64(sec) + float64(nsec)*1e-9, frameId
+}
+
+// decodes a geometry_msgs/Pose or geometry_msgs/Transform, which share the same layout
+func (d *decoder) pose(time float64) *trajectory.Pose {
+	p := &trajectory.Pose{Time: time}
+	for i := 0; i < 3; i++ {
+		p.Translation[i] = d.float64()
+	}
+	for i := 0; i < 4; i++ {
+		p.Rotation[i] = d.float64()
+	}
+	return p
+}
+
+type pointField struct {
+	offset   uint32
+	datatype uint8
+}
+
+// A decoded sensor_msgs/PointCloud2 message
+type pointCloud struct {
+	time        float64
+	width       uint32
+	height      uint32
+	fields      map[string]pointField
+	isBigEndian bool
+	pointStep   uint32
+	rowStep     uint32
+	data        []byte
+}
+
+func decodePointCloud2(data []byte) (*pointCloud, error) {
+	d := &decoder{data: data}
+	cloud := &pointCloud{fields: make(map[string]pointField)}
+	cloud.time, _ = d.header()
+	cloud.height = d.uint32()
+	cloud.width = d.uint32()
+	numFields := d.uint32()
+	for i := uint32(0); i < numFields && d.err == nil; i++ {
+		name := d.string()
+		field := pointField{offset: d.uint32(), datatype: d.uint8()}
+		_ = d.uint32() // count
+		cloud.fields[name] = field
+	}
+	cloud.isBigEndian = d.uint8() != 0
+	cloud.pointStep = d.uint32()
+	cloud.rowStep = d.uint32()
+	cloud.data = d.bytes()
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	for _, name := range []string{"x", "y", "z"} {
+		if _, ok := cloud.fields[name]; !ok {
+			return nil, errors.New("point cloud without " + name + " field")
+		}
+	}
+
+	return cloud, nil
+}
+
+// Returns the offset of the given point, identified by its row and column, in the data buffer
+func (c *pointCloud) pointOffset(row uint32, col uint32) int {
+	return int(row*c.rowStep + col*c.pointStep)
+}
+
+// Returns the value of the named field of the point at the given offset and whether the field exists
+func (c *pointCloud) readField(pointOffset int, name string) (float64, bool) {
+	field, ok := c.fields[name]
+	if !ok {
+		return 0, false
+	}
+	raw := c.rawField(pointOffset, field)
+	if raw == nil {
+		return 0, false
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if c.isBigEndian {
+		order = binary.BigEndian
+	}
+
+	switch field.datatype {
+	case int8Type:
+		return float64(int8(raw[0])), true
+	case uint8Type:
+		return float64(raw[0]), true
+	case int16Type:
+		return float64(int16(order.Uint16(raw))), true
+	case uint16Type:
+		return float64(order.Uint16(raw)), true
+	case int32Type:
+		return float64(int32(order.Uint32(raw))), true
+	case uint32Type:
+		return float64(order.Uint32(raw)), true
+	case float32Type:
+		return float64(math.Float32frombits(order.Uint32(raw))), true
+	case float64Type:
+		return math.Float64frombits(order.Uint64(raw)), true
+	}
+
+	return 0, false
+}
+
+// Returns the packed RGB color of the point at the given offset, if the cloud has a rgb or rgba field
+func (c *pointCloud) readColor(pointOffset int) (uint8, uint8, uint8, bool) {
+	field, ok := c.fields["rgb"]
+	if !ok {
+		field, ok = c.fields["rgba"]
+	}
+	if !ok || (field.datatype != float32Type && field.datatype != uint32Type) {
+		return 0, 0, 0, false
+	}
+	raw := c.rawField(pointOffset, field)
+	if raw == nil {
+		return 0, 0, 0, false
+	}
+
+	var packed uint32
+	if c.isBigEndian {
+		packed = binary.BigEndian.Uint32(raw)
+	} else {
+		packed = binary.LittleEndian.Uint32(raw)
+	}
+
+	return uint8(packed >> 16), uint8(packed >> 8), uint8(packed), true
+}
+
+func (c *pointCloud) rawField(pointOffset int, field pointField) []byte {
+	size := [...]int{0, 1, 1, 2, 2, 4, 4, 4, 8}
+	if int(field.datatype) >= len(size) || field.datatype == 0 {
+		return nil
+	}
+	start := pointOffset + int(field.offset)
+	end := start + size[field.datatype]
+	if end > len(c.data) {
+		return nil
+	}
+	return c.data[start:end]
+}
+
+// Decodes the pose carried by a geometry_msgs/PoseStamped, geometry_msgs/TransformStamped or nav_msgs/Odometry message
+func decodePose(msgType string, data []byte) (*trajectory.Pose, error) {
+	d := &decoder{data: data}
+	time, _ := d.header()
+
+	var p *trajectory.Pose
+	switch msgType {
+	case poseStampedType:
+		p = d.pose(time)
+	case transformStampedType, odometryType:
+		_ = d.string() // child frame id
+		p = d.pose(time)
+	default:
+		return nil, errors.New("unsupported pose message type " + msgType)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	return p, nil
+}
+
+func isPoseType(msgType string) bool {
+	return msgType == poseStampedType || msgType == transformStampedType || msgType == odometryType
+}
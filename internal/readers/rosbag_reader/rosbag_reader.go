@@ -0,0 +1,127 @@
+package rosbag_reader
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/trajectory"
+	"math"
+)
+
+// Reads the sensor_msgs/PointCloud2 messages stored in ROS bag files. If a pose topic is configured, every
+// cloud is moved from the sensor frame to the map frame using the pose interpolated at the cloud timestamp.
+type RosBagReader struct {
+	cloudTopic   string
+	poseTopic    string
+	transformer  readers.PointTransformer
+	storage      storage.Storage
+	cancellation *cancellation.Token
+}
+
+// Instantiates a new RosBagReader reading bags from the given storage. If cloudTopic is empty all PointCloud2 messages
+// are read. If poseTopic is empty the clouds are moved with the given transformer, if not nil, evaluated at the cloud
+// timestamp, otherwise the points are assumed to be already expressed in the map frame. Reading stops between two
+// clouds once the given cancellation token, if any, is cancelled.
+func NewRosBagReader(cloudTopic string, poseTopic string, transformer readers.PointTransformer, storage storage.Storage, cancellation *cancellation.Token) readers.Reader {
+	return &RosBagReader{
+		cloudTopic:   cloudTopic,
+		poseTopic:    poseTopic,
+		transformer:  transformer,
+		storage:      storage,
+		cancellation: cancellation,
+	}
+}
+
+func (r *RosBagReader) Read(filePath string, srid int, tree octree.ITree) error {
+	transformer := r.transformer
+	if r.poseTopic != "" {
+		poses, err := r.readTrajectory(filePath)
+		if err != nil {
+			return err
+		}
+		transformer = poses
+	}
+
+	return newBagReader(r.storage).forEachMessage(filePath, func(msg *message) error {
+		if err := r.cancellation.Err(); err != nil {
+			return err
+		}
+		if msg.conn.msgType != pointCloud2Type || (r.cloudTopic != "" && msg.conn.topic != r.cloudTopic) {
+			return nil
+		}
+
+		cloud, err := decodePointCloud2(msg.data)
+		if err != nil {
+			return err
+		}
+
+		addCloudPoints(cloud, transformer, srid, tree)
+		return nil
+	})
+}
+
+// Collects all the poses published on the pose topic
+func (r *RosBagReader) readTrajectory(filePath string) (*trajectory.Trajectory, error) {
+	var poses []*trajectory.Pose
+	err := newBagReader(r.storage).forEachMessage(filePath, func(msg *message) error {
+		if msg.conn.topic != r.poseTopic {
+			return nil
+		}
+		if !isPoseType(msg.conn.msgType) {
+			return errors.New("unsupported message type " + msg.conn.msgType + " on pose topic " + r.poseTopic)
+		}
+		p, err := decodePose(msg.conn.msgType, msg.data)
+		if err != nil {
+			return err
+		}
+		poses = append(poses, p)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	if len(poses) == 0 {
+		return nil, errors.New("no pose messages found on topic " + r.poseTopic)
+	}
+
+	return trajectory.NewTrajectory(poses, 0), nil
+}
+
+// Adds to the tree all the valid points of the cloud, eventually moved by the given transformer
+func addCloudPoints(cloud *pointCloud, transformer readers.PointTransformer, srid int, tree octree.ITree) {
+	for row := uint32(0); row < cloud.height; row++ {
+		for col := uint32(0); col < cloud.width; col++ {
+			offset := cloud.pointOffset(row, col)
+			x, okX := cloud.readField(offset, "x")
+			y, okY := cloud.readField(offset, "y")
+			z, okZ := cloud.readField(offset, "z")
+
+			// non dense clouds mark invalid points with NaN coordinates
+			if !okX || !okY || !okZ || math.IsNaN(x) || math.IsNaN(y) || math.IsNaN(z) {
+				continue
+			}
+
+			coordinate, pointSrid := &geometry.Coordinate{X: x, Y: y, Z: z}, srid
+			if transformer != nil {
+				coordinate, pointSrid = transformer.Transform(coordinate, cloud.time, srid)
+			}
+
+			r, g, b, _ := cloud.readColor(offset)
+			intensity, _ := cloud.readField(offset, "intensity")
+			classification, ok := cloud.readField(offset, "classification")
+			if !ok {
+				classification, _ = cloud.readField(offset, "label")
+			}
+
+			tree.AddPoint(coordinate, r, g, b, clampToUint8(intensity), clampToUint8(classification), pointSrid, nil)
+		}
+	}
+}
+
+func clampToUint8(value float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, value)))
+}
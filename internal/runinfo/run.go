@@ -0,0 +1,85 @@
+package runinfo
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"sync"
+	"time"
+)
+
+// Name of the run metadata file written in the output folder
+const FileName = "run.json"
+
+// An input file tiled by the run
+type Input struct {
+	File          string    `json:"file"`
+	Hash          string    `json:"sha256"`
+	Tileset       string    `json:"tileset"`
+	TerrainOffset float64   `json:"terrainOffset,omitempty"`
+	Started       time.Time `json:"started"`
+	Seconds       float64   `json:"seconds"`
+}
+
+// Metadata of a tiling job: the version of the tool and the resolved options it has been run with, the checksums of
+// its inputs and its timing, so that the tilesets it wrote can be reproduced or audited
+type Run struct {
+	Version  string              `json:"version"`
+	Options  *tiler.TilerOptions `json:"options"`
+	Started  time.Time           `json:"started"`
+	Finished time.Time           `json:"finished"`
+	Seconds  float64             `json:"seconds"`
+	Inputs   []*Input            `json:"inputs"`
+	sync.Mutex
+}
+
+// Starts recording the metadata of a job run with the given options by the given version of the tool
+func NewRun(version string, opts *tiler.TilerOptions) *Run {
+	return &Run{
+		Version: version,
+		Options: opts,
+		Started: time.Now().UTC(),
+		Inputs:  []*Input{},
+	}
+}
+
+// Registers the input file with the given content hash the given tileset is being written from
+func (r *Run) StartInput(file string, hash string, tileset string) *Input {
+	r.Lock()
+	defer r.Unlock()
+	input := &Input{File: file, Hash: hash, Tileset: tileset, Started: time.Now().UTC()}
+	r.Inputs = append(r.Inputs, input)
+	return input
+}
+
+// Records that the tileset of the input has been written
+func (i *Input) Finish() {
+	i.Seconds = time.Since(i.Started).Seconds()
+}
+
+// Returns the metadata of the run embedded in the extras of the root tileset written from the given input. The
+// timing of the input is not known yet when its tilesets are written, hence only its start is recorded.
+func (r *Run) GetTilesetExtras(input *Input) map[string]interface{} {
+	return map[string]interface{}{
+		"version": r.Version,
+		"options": r.Options,
+		"input": map[string]interface{}{
+			"file":    input.File,
+			"sha256":  input.Hash,
+			"started": input.Started,
+		},
+	}
+}
+
+// Records the end of the run and writes its metadata at the given path
+func (r *Run) Save(storage storage.Storage, filePath string) error {
+	r.Lock()
+	defer r.Unlock()
+	r.Finished = time.Now().UTC()
+	r.Seconds = r.Finished.Sub(r.Started).Seconds()
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(filePath, content, 0666)
+}
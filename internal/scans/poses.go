@@ -0,0 +1,39 @@
+package scans
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+)
+
+// Number of columns of a scan pose CSV record: scan, x, y, z, qx, qy, qz, qw
+const poseColumns = 8
+
+// Registration pose of a scan, the position of the scanner and its orientation as a unit quaternion (x, y, z, w) in
+// the srid of the input points
+type Pose struct {
+	Position    [3]float64 `json:"position"`
+	Orientation [4]float64 `json:"orientation"`
+}
+
+// Loads the scan poses stored in a comma separated file whose records hold the name of the scan file, with or without
+// its extension, the position of the scanner and its orientation: scan,x,y,z,qx,qy,qz,qw.
+func LoadPoses(filePath string) (map[string]*Pose, error) {
+	records, err := tools.ReadCsvFloatRecords(filePath, "scan pose", poseColumns, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	poses := make(map[string]*Pose)
+	for _, record := range records {
+		values := record.Values
+		poses[record.Text[0]] = &Pose{
+			Position:    [3]float64{values[0], values[1], values[2]},
+			Orientation: [4]float64{values[3], values[4], values[5], values[6]},
+		}
+	}
+
+	if len(poses) == 0 {
+		return nil, errors.New("no scan poses found in " + filePath)
+	}
+	return poses, nil
+}
@@ -0,0 +1,84 @@
+package scans
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Name of the attribute holding the id of the scan of every point
+const AttributeName = "scan_id"
+
+// A registered scan merged in a tileset, identified by the index of its file among the merged ones
+type Scan struct {
+	Id     int
+	Name   string
+	Points int64
+	Pose   *Pose
+}
+
+// Scans of the registered files merged in a single tileset, the points of every scan being tagged with its id and
+// counted as they are read, so that the scans can be toggled and checked in the viewers
+type Registry struct {
+	scans []*Scan
+}
+
+// Builds the registry of the scans stored in the given files, in this order, attaching the given poses to the scans
+// whose file name, with or without its extension, they are keyed by
+func NewRegistry(files []string, poses map[string]*Pose) *Registry {
+	registry := &Registry{}
+	for i, file := range files {
+		name := filepath.Base(file)
+		pose, ok := poses[name]
+		if !ok {
+			pose = poses[strings.TrimSuffix(name, filepath.Ext(name))]
+		}
+		registry.scans = append(registry.scans, &Scan{Id: i, Name: name, Pose: pose})
+	}
+	return registry
+}
+
+// Returns the scans of the registry
+func (r *Registry) GetScans() []*Scan {
+	return r.scans
+}
+
+// Zeroes the point counts of the scans, before their files are read again
+func (r *Registry) ResetCounts() {
+	for _, scan := range r.scans {
+		atomic.StoreInt64(&scan.Points, 0)
+	}
+}
+
+// Wraps the given tree so that all the points added to it are counted and get the id of the scan with the given id
+// appended to their attributes
+func (r *Registry) Track(tree octree.ITree, id int) octree.ITree {
+	return &scanTree{ITree: tree, scan: r.scans[id]}
+}
+
+// Returns the scans recorded in the extras of the root tileset
+func (r *Registry) GetTilesetExtras() []map[string]interface{} {
+	extras := make([]map[string]interface{}, len(r.scans))
+	for i, scan := range r.scans {
+		extras[i] = map[string]interface{}{"id": scan.Id, "name": scan.Name, "points": atomic.LoadInt64(&scan.Points)}
+		if scan.Pose != nil {
+			extras[i]["pose"] = scan.Pose
+		}
+	}
+	return extras
+}
+
+// Decorates a tree appending the id of a scan to the attributes of every point added to it
+type scanTree struct {
+	octree.ITree
+	scan *Scan
+}
+
+func (t *scanTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	atomic.AddInt64(&t.scan.Points, 1)
+	// the attributes may be shared by the reader, hence they are copied rather than appended to in place
+	tagged := append(attributes[:len(attributes):len(attributes)], float32(t.scan.Id))
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, tagged)
+}
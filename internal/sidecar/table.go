@@ -0,0 +1,91 @@
+package sidecar
+
+import (
+	"encoding/csv"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Supplementary attributes of the points of a point cloud produced separately from it, e.g. the segment ids computed
+// by an external classifier, joined to the points by their index in the file or by their GPS time
+type Table struct {
+	// names of the attributes, in the order of their values
+	Names []string
+	key   tiler.SidecarKey
+	rows  map[float64][]float32
+}
+
+// Loads the attributes stored in a comma separated file whose first line holds the column names. The first column is
+// the key of the points, either their zero based index in the file or their GPS time according to the given key, and
+// the other ones are the numeric attributes to join. Lines starting with # are ignored.
+func LoadTable(storage storage.Storage, filePath string, key tiler.SidecarKey) (*Table, error) {
+	file, err := storage.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF || (err == nil && len(header) < 2) {
+		return nil, errors.New("no attributes found in " + filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Names: make([]string, len(header)-1),
+		key:   key,
+		rows:  make(map[float64][]float32),
+	}
+	for i, name := range header[1:] {
+		table.Names[i] = strings.TrimSpace(name)
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rowKey, values, err := parseRecord(record)
+		if err != nil {
+			return nil, errors.New("invalid attributes record in " + filePath + ": " + err.Error())
+		}
+		table.rows[rowKey] = values
+	}
+	return table, nil
+}
+
+func parseRecord(record []string) (float64, []float32, error) {
+	key, err := strconv.ParseFloat(record[0], 64)
+	if err != nil {
+		return 0, nil, err
+	}
+	values := make([]float32, len(record)-1)
+	for i, field := range record[1:] {
+		value, err := strconv.ParseFloat(field, 32)
+		if err != nil {
+			return 0, nil, err
+		}
+		values[i] = float32(value)
+	}
+	return key, values, nil
+}
+
+// Returns the attributes of the point with the given index in the file and GPS time, nil if the table has none
+func (t *Table) GetAttributes(index int, gpsTime float64) []float32 {
+	if t.key == tiler.SidecarKeyGpsTime {
+		return t.rows[gpsTime]
+	}
+	return t.rows[float64(index)]
+}
@@ -0,0 +1,189 @@
+package stac
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// Version of the STAC specification the documents conform to
+const stacVersion = "1.0.0"
+
+// Schema of the STAC point cloud extension, describing the point count and attributes of point cloud assets
+const pointCloudExtension = "https://stac-extensions.github.io/pointcloud/v1.0.0/schema.json"
+
+// Media type of the tileset.json files
+const tilesetMediaType = "application/json"
+
+type Link struct {
+	Rel   string `json:"rel"`
+	Href  string `json:"href"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+type Asset struct {
+	Href  string   `json:"href"`
+	Type  string   `json:"type,omitempty"`
+	Title string   `json:"title,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// GeoJSON polygon
+type Geometry struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// STAC Item describing a tileset, whose spatial extent is given in EPSG:4326 coordinates
+type Item struct {
+	StacVersion    string                 `json:"stac_version"`
+	StacExtensions []string               `json:"stac_extensions"`
+	Type           string                 `json:"type"`
+	Id             string                 `json:"id"`
+	Collection     string                 `json:"collection,omitempty"`
+	Geometry       Geometry               `json:"geometry"`
+	Bbox           []float64              `json:"bbox"`
+	Properties     map[string]interface{} `json:"properties"`
+	Links          []Link                 `json:"links"`
+	Assets         map[string]Asset       `json:"assets"`
+	datetime       time.Time
+}
+
+// Attribute of the points stored in the tiles, as described by the point cloud extension
+type pointCloudSchema struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+	Type string `json:"type"`
+}
+
+// Attributes of the points written in the PNTS tiles
+var pntsSchemas = []pointCloudSchema{
+	{Name: "X", Size: 4, Type: "floating"},
+	{Name: "Y", Size: 4, Type: "floating"},
+	{Name: "Z", Size: 4, Type: "floating"},
+	{Name: "Red", Size: 1, Type: "unsigned"},
+	{Name: "Green", Size: 1, Type: "unsigned"},
+	{Name: "Blue", Size: 1, Type: "unsigned"},
+	{Name: "Intensity", Size: 1, Type: "unsigned"},
+	{Name: "Classification", Size: 1, Type: "unsigned"},
+}
+
+// Creates the item of a tileset from its bounds, given as west, south, east and north longitudes and latitudes in
+// degrees followed by the min and max heights, the time the points were acquired and the number of points. The item
+// links the tileset.json file stored in its folder.
+func NewItem(id string, bounds [6]float64, datetime time.Time, pointCount int64) *Item {
+	west, south, east, north := bounds[0], bounds[1], bounds[2], bounds[3]
+	return &Item{
+		StacVersion:    stacVersion,
+		StacExtensions: []string{pointCloudExtension},
+		Type:           "Feature",
+		Id:             id,
+		Geometry: Geometry{
+			Type:        "Polygon",
+			Coordinates: [][][2]float64{{{west, south}, {east, south}, {east, north}, {west, north}, {west, south}}},
+		},
+		// 3D bounding boxes list the min height after the south west corner and the max one after the north east one
+		Bbox: []float64{west, south, bounds[4], east, north, bounds[5]},
+		Properties: map[string]interface{}{
+			"datetime":            datetime.UTC().Format(time.RFC3339),
+			"pointcloud:count":    pointCount,
+			"pointcloud:type":     "lidar",
+			"pointcloud:encoding": "3d-tiles",
+			"pointcloud:schemas":  pntsSchemas,
+		},
+		Links: []Link{{Rel: "self", Href: "item.json", Type: "application/geo+json"}},
+		Assets: map[string]Asset{
+			"tileset": {Href: "tileset.json", Type: tilesetMediaType, Title: "3D Tiles tileset", Roles: []string{"data"}},
+		},
+		datetime: datetime,
+	}
+}
+
+// Adds an asset to the item, given its href relative to the item folder
+func (i *Item) AddAsset(key string, asset Asset) {
+	i.Assets[key] = asset
+}
+
+// Encodes the item as JSON
+func (i *Item) ToJson() ([]byte, error) {
+	return json.MarshalIndent(i, "", "\t")
+}
+
+type Extent struct {
+	Spatial struct {
+		Bbox [][]float64 `json:"bbox"`
+	} `json:"spatial"`
+	Temporal struct {
+		Interval [][]*string `json:"interval"`
+	} `json:"temporal"`
+}
+
+// STAC collection grouping the items of the tilesets produced by a tiling job
+type Collection struct {
+	StacVersion string `json:"stac_version"`
+	Type        string `json:"type"`
+	Id          string `json:"id"`
+	Description string `json:"description"`
+	License     string `json:"license"`
+	Extent      Extent `json:"extent"`
+	Links       []Link `json:"links"`
+}
+
+// Marks the item as a member of the collection with the given id, stored in the parent folder of the item
+func (i *Item) SetCollection(collectionId string) {
+	i.Collection = collectionId
+	i.Links = append(i.Links,
+		Link{Rel: "collection", Href: "../collection.json", Type: "application/json"},
+		Link{Rel: "parent", Href: "../collection.json", Type: "application/json"},
+		Link{Rel: "root", Href: "../collection.json", Type: "application/json"},
+	)
+}
+
+// Creates the collection of the given items, which are stored in a folder named as their id next to the collection.
+// Its extent is the union of the bounds and the acquisition times of the items.
+func NewCollection(id string, description string, items []*Item) *Collection {
+	collection := &Collection{
+		StacVersion: stacVersion,
+		Type:        "Collection",
+		Id:          id,
+		Description: description,
+		License:     "proprietary",
+		Links:       []Link{{Rel: "self", Href: "collection.json", Type: "application/json"}, {Rel: "root", Href: "collection.json", Type: "application/json"}},
+	}
+
+	bbox := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	var start, end time.Time
+	for _, item := range items {
+		bbox[0], bbox[1] = math.Min(bbox[0], item.Bbox[0]), math.Min(bbox[1], item.Bbox[1])
+		bbox[2], bbox[3] = math.Max(bbox[2], item.Bbox[3]), math.Max(bbox[3], item.Bbox[4])
+		if start.IsZero() || item.datetime.Before(start) {
+			start = item.datetime
+		}
+		if end.IsZero() || item.datetime.After(end) {
+			end = item.datetime
+		}
+		collection.Links = append(collection.Links, Link{Rel: "item", Href: item.Id + "/item.json", Type: "application/geo+json"})
+	}
+
+	if len(items) == 0 {
+		bbox = []float64{-180, -90, 180, 90}
+	}
+	collection.Extent.Spatial.Bbox = [][]float64{bbox}
+	collection.Extent.Temporal.Interval = [][]*string{{formatTime(start), formatTime(end)}}
+	return collection
+}
+
+// Encodes the collection as JSON
+func (c *Collection) ToJson() ([]byte, error) {
+	return json.MarshalIndent(c, "", "\t")
+}
+
+// Formats the time as RFC 3339, returning nil for zero times which stand for open intervals
+func formatTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	formatted := t.UTC().Format(time.RFC3339)
+	return &formatted
+}
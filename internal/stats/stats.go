@@ -0,0 +1,250 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statistics of a point filter, i.e. of a processing step that can discard points
+type FilterStats struct {
+	Name    string `json:"name"`
+	Input   int64  `json:"input"`
+	Kept    int64  `json:"kept"`
+	Dropped int64  `json:"dropped"`
+}
+
+// Statistics of a tree level: how many points reached it and how many were retained by its nodes
+type LevelStats struct {
+	Depth          int     `json:"depth"`
+	Nodes          int     `json:"nodes"`
+	InputPoints    int64   `json:"inputPoints"`
+	RetainedPoints int64   `json:"retainedPoints"`
+	RetentionRate  float64 `json:"retentionRate"`
+}
+
+// Duration of a processing phase
+type PhaseStats struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+}
+
+// Statistics of the processing of a single input file
+type FileStats struct {
+	File              string        `json:"file"`
+	PointsRead        int64         `json:"pointsRead"`
+	PointsKept        int64         `json:"pointsKept"`
+	WithheldPoints    int64         `json:"withheldPoints"`
+	SyntheticPoints   int64         `json:"syntheticPoints"`
+	KeyPoints         int64         `json:"keyPoints"`
+	InvalidColors     string        `json:"invalidColors,omitempty"`
+	QuarantinedPoints int64         `json:"quarantinedPoints,omitempty"`
+	SkippedRecords    int64         `json:"skippedRecords,omitempty"`
+	RecoveredRecords  int64         `json:"recoveredRecords,omitempty"`
+	Error             string        `json:"error,omitempty"`
+	Filters           []FilterStats `json:"filters"`
+	Levels            []LevelStats  `json:"levels"`
+	Phases            []PhaseStats  `json:"phases"`
+	collector         *Collector
+}
+
+// Final statistics of a tiling job
+type Summary struct {
+	Files           []*FileStats `json:"files"`
+	TotalPointsRead int64        `json:"totalPointsRead"`
+	TotalPointsKept int64        `json:"totalPointsKept"`
+	FailedFiles     int          `json:"failedFiles,omitempty"`
+	Seconds         float64      `json:"seconds"`
+	PeakRssBytes    uint64       `json:"peakRssBytes"`
+}
+
+// Collects the statistics of a tiling job, sampling the memory usage at the end of every phase
+type Collector struct {
+	start      time.Time
+	files      []*FileStats
+	peakMemory uint64
+	sync.Mutex
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		start: time.Now(),
+	}
+}
+
+// Registers a new input file whose statistics have to be collected
+func (c *Collector) NewFileStats(file string) *FileStats {
+	c.Lock()
+	defer c.Unlock()
+	fileStats := &FileStats{File: file, collector: c}
+	c.files = append(c.files, fileStats)
+	return fileStats
+}
+
+// Records the error the processing of the given input file failed with, on its latest statistics or on new ones if
+// the file failed before they were registered
+func (c *Collector) FailFile(file string, err error) {
+	c.Lock()
+	defer c.Unlock()
+	for i := len(c.files) - 1; i >= 0; i-- {
+		if c.files[i].File == file {
+			c.files[i].Error = err.Error()
+			return
+		}
+	}
+	c.files = append(c.files, &FileStats{File: file, Error: err.Error(), collector: c})
+}
+
+// Samples the memory allocated by the process keeping track of its peak
+func (c *Collector) SampleMemory() {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	c.Lock()
+	defer c.Unlock()
+	if memStats.Sys > c.peakMemory {
+		c.peakMemory = memStats.Sys
+	}
+}
+
+// Returns the summary of the statistics collected so far
+func (c *Collector) GetSummary() *Summary {
+	c.SampleMemory()
+
+	c.Lock()
+	defer c.Unlock()
+	summary := &Summary{
+		Files:        c.files,
+		Seconds:      time.Since(c.start).Seconds(),
+		PeakRssBytes: c.peakMemory,
+	}
+	// the kernel high water mark is the most accurate estimate, when available
+	if peakRss, ok := readPeakRss(); ok && peakRss > summary.PeakRssBytes {
+		summary.PeakRssBytes = peakRss
+	}
+	for _, file := range c.files {
+		summary.TotalPointsRead += file.PointsRead
+		summary.TotalPointsKept += file.PointsKept
+		if file.Error != "" {
+			summary.FailedFiles++
+		}
+	}
+
+	return summary
+}
+
+// Writes the summary of the collected statistics as json in the given file
+func (c *Collector) WriteSummary(filePath string) error {
+	jsonData, err := json.MarshalIndent(c.GetSummary(), "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, jsonData, 0666)
+}
+
+// Starts timing the given phase, the returned function must be called when the phase ends
+func (f *FileStats) StartPhase(name string) func() {
+	start := time.Now()
+	return func() {
+		f.Phases = append(f.Phases, PhaseStats{Name: name, Seconds: time.Since(start).Seconds()})
+		f.collector.SampleMemory()
+	}
+}
+
+// Wraps the given tree counting the points added to it
+func (f *FileStats) CountPoints(tree octree.ITree) octree.ITree {
+	return &countingTree{ITree: tree, count: &f.PointsRead}
+}
+
+// Collects the number of points kept by the given built tree and its per level retention rates
+func (f *FileStats) CollectTreeStats(tree octree.ITree) {
+	root := tree.GetRootNode()
+	if root == nil {
+		return
+	}
+
+	f.PointsKept = root.TotalNumberOfPoints()
+	f.Filters = append(f.Filters, FilterStats{
+		Name:    "tree",
+		Input:   f.PointsRead,
+		Kept:    f.PointsKept,
+		Dropped: f.PointsRead - f.PointsKept,
+	})
+
+	f.Levels = ComputeLevelStats(root)
+}
+
+// Visits breadth first the tree having the given root node returning the statistics of each of its levels
+func ComputeLevelStats(root octree.INode) []LevelStats {
+	var levels []LevelStats
+	for depth, level := 0, []octree.INode{root}; len(level) > 0; depth++ {
+		levelStats := LevelStats{Depth: depth}
+		var nextLevel []octree.INode
+		for _, node := range level {
+			levelStats.Nodes++
+			levelStats.InputPoints += node.TotalNumberOfPoints()
+			levelStats.RetainedPoints += node.NumberOfPoints()
+			for _, child := range node.GetChildren() {
+				if child != nil && child.TotalNumberOfPoints() > 0 {
+					nextLevel = append(nextLevel, child)
+				}
+			}
+		}
+		if levelStats.InputPoints > 0 {
+			levelStats.RetentionRate = float64(levelStats.RetainedPoints) / float64(levelStats.InputPoints)
+		}
+		levels = append(levels, levelStats)
+		level = nextLevel
+	}
+
+	return levels
+}
+
+// Decorates a tree counting the points added to it
+type countingTree struct {
+	octree.ITree
+	count *int64
+}
+
+func (t *countingTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	atomic.AddInt64(t.count, 1)
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+}
+
+// Reads the peak resident set size of the process from /proc, available on Linux only
+func readPeakRss() (uint64, bool) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, false
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "VmHWM:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return 0, false
+		}
+		kiloBytes, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return kiloBytes * 1024, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"math"
+)
+
+// Number of points of a tile having a given classification
+type ClassCount struct {
+	Classification uint8
+	Count          uint32
+}
+
+// Aggregate statistics of the points of a tile content
+type TileStats struct {
+	PointCount    uint32
+	MinElevation  float64
+	MaxElevation  float64
+	MeanElevation float64
+	// classification histogram, sorted by classification and without empty classes
+	Classes []ClassCount
+}
+
+// Computes the aggregate statistics of the given points, whose Z coordinate is the elevation
+func ComputeTileStats(points []*data.Point) *TileStats {
+	tileStats := &TileStats{
+		MinElevation: math.Inf(1),
+		MaxElevation: math.Inf(-1),
+	}
+	if len(points) == 0 {
+		tileStats.MinElevation, tileStats.MaxElevation = 0, 0
+		return tileStats
+	}
+
+	var histogram [256]uint32
+	sum := 0.0
+	for _, point := range points {
+		tileStats.MinElevation = math.Min(tileStats.MinElevation, point.Z)
+		tileStats.MaxElevation = math.Max(tileStats.MaxElevation, point.Z)
+		sum += point.Z
+		histogram[point.Classification]++
+	}
+	tileStats.PointCount = uint32(len(points))
+	tileStats.MeanElevation = sum / float64(len(points))
+
+	for classification, count := range histogram {
+		if count > 0 {
+			tileStats.Classes = append(tileStats.Classes, ClassCount{Classification: uint8(classification), Count: count})
+		}
+	}
+	return tileStats
+}
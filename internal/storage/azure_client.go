@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the Blob service REST API of the requests
+const azureApiVersion = "2020-10-02"
+
+// Client of Azure Blob Storage writing block blobs in a container, authenticated either by a shared access signature
+// appended to the requests or by the shared key of the storage account
+type azureClient struct {
+	endpoint  *url.URL
+	account   string
+	container string
+	sasToken  url.Values
+	key       []byte
+	requester objectRequester
+}
+
+// Instantiates an Azure Blob Storage client writing in the given container of the account of the
+// AZURE_STORAGE_ACCOUNT environment variable, authenticated by the AZURE_STORAGE_SAS_TOKEN or AZURE_STORAGE_KEY ones.
+// The endpoint of the account, https://<account>.blob.core.windows.net by default, is given by AZURE_STORAGE_ENDPOINT,
+// e.g. to write to an emulator.
+func newAzureClientFromEnvironment(container string) (ObjectClient, error) {
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	if account == "" {
+		return nil, errors.New("the az output requires the AZURE_STORAGE_ACCOUNT environment variable")
+	}
+	endpoint := os.Getenv("AZURE_STORAGE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	parsed, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.New("invalid object store endpoint " + endpoint)
+	}
+	client := &azureClient{
+		endpoint:  parsed,
+		account:   account,
+		container: container,
+		requester: newObjectRequester(),
+	}
+	if token := os.Getenv("AZURE_STORAGE_SAS_TOKEN"); token != "" {
+		if client.sasToken, err = url.ParseQuery(strings.TrimPrefix(token, "?")); err != nil {
+			return nil, errors.New("invalid AZURE_STORAGE_SAS_TOKEN: " + err.Error())
+		}
+	} else if key := os.Getenv("AZURE_STORAGE_KEY"); key != "" {
+		if client.key, err = base64.StdEncoding.DecodeString(key); err != nil {
+			return nil, errors.New("invalid AZURE_STORAGE_KEY: " + err.Error())
+		}
+	} else {
+		return nil, errors.New("the az output requires the AZURE_STORAGE_SAS_TOKEN or AZURE_STORAGE_KEY environment variable")
+	}
+	return client, nil
+}
+
+func (c *azureClient) PutObject(key string, data []byte) error {
+	if len(data) > multipartThreshold {
+		return c.putBlocks(key, data)
+	}
+	_, _, err := c.send(http.MethodPut, key, nil, http.Header{"X-Ms-Blob-Type": {"BlockBlob"}}, data)
+	return err
+}
+
+func (c *azureClient) GetObject(key string) ([]byte, error) {
+	_, body, err := c.send(http.MethodGet, key, nil, nil, nil)
+	return body, err
+}
+
+// Uploads the given blob as a list of blocks, committed once all of them are uploaded. The uncommitted blocks of a
+// failed upload are discarded by the service.
+func (c *azureClient) putBlocks(key string, data []byte) error {
+	blockList := struct {
+		XMLName xml.Name `xml:"BlockList"`
+		Latest  []string `xml:"Latest"`
+	}{}
+	for i, content := range splitParts(data) {
+		// the ids of the blocks of a blob must have the same length
+		id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", i)))
+		if _, _, err := c.send(http.MethodPut, key, url.Values{"comp": {"block"}, "blockid": {id}}, nil, content); err != nil {
+			return err
+		}
+		blockList.Latest = append(blockList.Latest, id)
+	}
+	content, err := xml.Marshal(blockList)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.send(http.MethodPut, key, url.Values{"comp": {"blocklist"}}, nil, append([]byte(xml.Header), content...))
+	return err
+}
+
+// Sends an authenticated request on the given blob with the given query parameters, headers and body
+func (c *azureClient) send(method string, key string, query url.Values, header http.Header, body []byte) (http.Header, []byte, error) {
+	target := *c.endpoint
+	target.Path = c.endpoint.Path + "/" + c.container + "/" + key
+	target.RawPath = c.endpoint.EscapedPath() + "/" + escapeKey(c.container) + "/" + escapeKey(key)
+	parameters := url.Values{}
+	for name, values := range query {
+		parameters[name] = values
+	}
+	for name, values := range c.sasToken {
+		parameters[name] = values
+	}
+	target.RawQuery = parameters.Encode()
+	return c.requester.send(func() (*http.Request, error) {
+		request, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		request.ContentLength = int64(len(body))
+		for name, values := range header {
+			request.Header[name] = values
+		}
+		request.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+		request.Header.Set("X-Ms-Version", azureApiVersion)
+		if c.key != nil {
+			request.Header.Set("Authorization", "SharedKey "+c.account+":"+c.sign(request, query))
+		}
+		return request, nil
+	})
+}
+
+// Returns the shared key signature of the given request with the given query parameters, excluding the shared access
+// signature, not sent along with the shared key
+func (c *azureClient) sign(request *http.Request, query url.Values) string {
+	contentLength := ""
+	if request.ContentLength > 0 {
+		contentLength = strconv.FormatInt(request.ContentLength, 10)
+	}
+	var msHeaders []string
+	for name, values := range request.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name+":"+strings.TrimSpace(strings.Join(values, ",")))
+		}
+	}
+	sort.Strings(msHeaders)
+
+	// the resource is the account followed by the encoded path and the sorted lower case query parameters
+	resource := "/" + c.account + request.URL.EscapedPath()
+	var names []string
+	for name := range query {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		resource += "\n" + name + ":" + strings.Join(values, ",")
+	}
+
+	stringToSign := strings.Join([]string{
+		request.Method,
+		request.Header.Get("Content-Encoding"),
+		request.Header.Get("Content-Language"),
+		contentLength,
+		request.Header.Get("Content-MD5"),
+		request.Header.Get("Content-Type"),
+		"", // Date, replaced by x-ms-date
+		request.Header.Get("If-Modified-Since"),
+		request.Header.Get("If-Match"),
+		request.Header.Get("If-None-Match"),
+		request.Header.Get("If-Unmodified-Since"),
+		request.Header.Get("Range"),
+		strings.Join(msHeaders, "\n"),
+		resource,
+	}, "\n")
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
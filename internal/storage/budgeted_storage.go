@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage decorator bounding the number of files concurrently open through it, so that outputs made of millions of
+// tiles can be written within the file descriptors limit of the process. Created directories are remembered so that
+// every output folder is created only once, regardless of the number of files written in it.
+type BudgetedStorage struct {
+	storage     Storage
+	slots       chan struct{}
+	directories sync.Map
+}
+
+// Instantiates a new BudgetedStorage allowing at most maxOpenFiles files to be open at the same time
+func NewBudgetedStorage(storage Storage, maxOpenFiles int) Storage {
+	if maxOpenFiles < 1 {
+		maxOpenFiles = 1
+	}
+	return &BudgetedStorage{
+		storage: storage,
+		slots:   make(chan struct{}, maxOpenFiles),
+	}
+}
+
+func (s *BudgetedStorage) Open(filePath string) (File, error) {
+	s.acquire()
+	file, err := s.storage.Open(filePath)
+	if err != nil {
+		s.release()
+		return nil, err
+	}
+
+	return &budgetedFile{File: file, storage: s}, nil
+}
+
+func (s *BudgetedStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	s.acquire()
+	defer s.release()
+	return s.storage.WriteFile(filePath, data, perm)
+}
+
+func (s *BudgetedStorage) MkdirAll(directory string, perm os.FileMode) error {
+	directory = filepath.Clean(directory)
+	if _, ok := s.directories.Load(directory); ok {
+		return nil
+	}
+
+	// creating a directory requires a descriptor as well
+	s.acquire()
+	defer s.release()
+	if err := s.storage.MkdirAll(directory, perm); err != nil {
+		return err
+	}
+
+	// parents have been created as well
+	for dir := directory; ; dir = filepath.Dir(dir) {
+		if _, loaded := s.directories.LoadOrStore(dir, true); loaded || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *BudgetedStorage) acquire() {
+	s.slots <- struct{}{}
+}
+
+func (s *BudgetedStorage) release() {
+	<-s.slots
+}
+
+// File releasing its slot in the budget once closed
+type budgetedFile struct {
+	File
+	storage *BudgetedStorage
+	once    sync.Once
+}
+
+func (f *budgetedFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(f.storage.release)
+	return err
+}
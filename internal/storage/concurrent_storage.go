@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"os"
+	"sync"
+)
+
+// Number of files waiting to be written per writer goroutine of a ConcurrentStorage, beyond which the writes block
+const concurrentWritesPerWriter = 4
+
+// Storage decorator handing the files to write over to a pool of writer goroutines, so that the callers serializing
+// the tiles go on with the next ones while the file system writes the previous ones. The number of files waiting to
+// be written is bounded, further writes blocking until a writer is free so that the memory held by the pending
+// contents does not grow when the storage is slower than the callers. The first error writing a file is returned by
+// the following writes and by Close, which has to be called once all the tiles are submitted. The files written after
+// Close are written right away.
+type ConcurrentStorage struct {
+	storage Storage
+	queue   chan *pendingWrite
+	writers sync.WaitGroup
+	// number of files submitted and not yet written, signaled by written when it drops to 0
+	pending int
+	written *sync.Cond
+	closed  bool
+	// held for reading while submitting the files, so that the queue is not closed meanwhile
+	submit sync.RWMutex
+	err    error
+	sync.Mutex
+}
+
+// A file waiting to be written
+type pendingWrite struct {
+	filePath string
+	data     []byte
+	perm     os.FileMode
+}
+
+// Instantiates a new ConcurrentStorage writing the files through the given storage with the given number of writers
+func NewConcurrentStorage(storage Storage, writers int) *ConcurrentStorage {
+	if writers < 1 {
+		writers = 1
+	}
+	s := &ConcurrentStorage{
+		storage: storage,
+		queue:   make(chan *pendingWrite, writers*concurrentWritesPerWriter),
+	}
+	s.written = sync.NewCond(&s.Mutex)
+	for i := 0; i < writers; i++ {
+		s.writers.Add(1)
+		go s.write()
+	}
+	return s
+}
+
+// Opens the given file once all the files submitted so far are written, so that the files just written can be read
+func (s *ConcurrentStorage) Open(filePath string) (File, error) {
+	s.Lock()
+	for s.pending > 0 {
+		s.written.Wait()
+	}
+	s.Unlock()
+	return s.storage.Open(filePath)
+}
+
+// Submits the given file to the writers, blocking while the queue of the files waiting to be written is full. The data
+// must not be modified afterwards.
+func (s *ConcurrentStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	if err := s.getError(); err != nil {
+		return err
+	}
+	s.submit.RLock()
+	defer s.submit.RUnlock()
+	if s.closed {
+		return s.storage.WriteFile(filePath, data, perm)
+	}
+	s.Lock()
+	s.pending++
+	s.Unlock()
+	s.queue <- &pendingWrite{filePath: filePath, data: data, perm: perm}
+	return nil
+}
+
+func (s *ConcurrentStorage) MkdirAll(directory string, perm os.FileMode) error {
+	return s.storage.MkdirAll(directory, perm)
+}
+
+// Waits for all the submitted files to be written and stops the writers, returning the first error writing them
+func (s *ConcurrentStorage) Close() error {
+	s.submit.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.submit.Unlock()
+	s.writers.Wait()
+	return s.getError()
+}
+
+// Writes the submitted files until the storage is closed
+func (s *ConcurrentStorage) write() {
+	defer s.writers.Done()
+	for write := range s.queue {
+		// once a write failed the remaining files are dropped, the job being failed anyway
+		if s.getError() == nil {
+			if err := s.storage.WriteFile(write.filePath, write.data, write.perm); err != nil {
+				s.setError(err)
+			}
+		}
+		s.Lock()
+		if s.pending--; s.pending == 0 {
+			s.written.Broadcast()
+		}
+		s.Unlock()
+	}
+}
+
+func (s *ConcurrentStorage) getError() error {
+	s.Lock()
+	defer s.Unlock()
+	return s.err
+}
+
+func (s *ConcurrentStorage) setError(err error) {
+	s.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.Unlock()
+}
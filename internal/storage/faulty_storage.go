@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Error returned by the operations failed on purpose by a FaultyStorage
+var ErrInjectedFault = errors.New("injected storage fault")
+
+// Settings of the faults injected by a FaultyStorage
+type FaultConfig struct {
+	ErrorRate     float64       // Probability of an operation to fail with ErrInjectedFault
+	ShortReadRate float64       // Probability of a read to return fewer bytes than requested
+	Latency       time.Duration // Maximum random delay added to every operation
+	Seed          int64         // Seed of the random faults, the same seed gives the same sequence of faults
+}
+
+// Storage decorator injecting random errors, latency and short reads in the operations of the wrapped storage, used
+// to validate the behaviour of the tiler when storage misbehaves
+type FaultyStorage struct {
+	storage Storage
+	config  FaultConfig
+	random  *rand.Rand
+	sync.Mutex
+}
+
+func NewFaultyStorage(storage Storage, config FaultConfig) Storage {
+	return &FaultyStorage{
+		storage: storage,
+		config:  config,
+		random:  rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+func (s *FaultyStorage) Open(filePath string) (File, error) {
+	if err := s.injectFault(); err != nil {
+		return nil, err
+	}
+	file, err := s.storage.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &faultyFile{File: file, storage: s}, nil
+}
+
+func (s *FaultyStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	if err := s.injectFault(); err != nil {
+		return err
+	}
+	return s.storage.WriteFile(filePath, data, perm)
+}
+
+func (s *FaultyStorage) MkdirAll(directory string, perm os.FileMode) error {
+	if err := s.injectFault(); err != nil {
+		return err
+	}
+	return s.storage.MkdirAll(directory, perm)
+}
+
+// Sleeps for a random latency and returns ErrInjectedFault according to the configured error rate
+func (s *FaultyStorage) injectFault() error {
+	s.Lock()
+	latency := time.Duration(0)
+	if s.config.Latency > 0 {
+		latency = time.Duration(s.random.Int63n(int64(s.config.Latency)))
+	}
+	fail := s.random.Float64() < s.config.ErrorRate
+	s.Unlock()
+
+	time.Sleep(latency)
+	if fail {
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+// Returns the number of bytes a read of the given size should return according to the configured short read rate
+func (s *FaultyStorage) getReadSize(size int) int {
+	s.Lock()
+	defer s.Unlock()
+	if size > 1 && s.random.Float64() < s.config.ShortReadRate {
+		return 1 + s.random.Intn(size-1)
+	}
+	return size
+}
+
+type faultyFile struct {
+	File
+	storage *FaultyStorage
+}
+
+func (f *faultyFile) Read(p []byte) (int, error) {
+	if err := f.storage.injectFault(); err != nil {
+		return 0, err
+	}
+	// short reads are legit for io.Reader, callers must keep reading
+	return f.File.Read(p[:f.storage.getReadSize(len(p))])
+}
+
+func (f *faultyFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.storage.injectFault(); err != nil {
+		return 0, err
+	}
+	size := f.storage.getReadSize(len(p))
+	n, err := f.File.ReadAt(p[:size], off)
+	// io.ReaderAt requires an error when fewer bytes than requested are returned
+	if err == nil && size < len(p) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
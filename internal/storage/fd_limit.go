@@ -0,0 +1,26 @@
+package storage
+
+// Share of the file descriptors limit of the process granted to the storage, the rest is left to the other files the
+// process needs, e.g. the geoid model and the proj database
+const fdLimitShare = 0.5
+
+// Budget used when the file descriptors limit cannot be determined
+const defaultOpenFilesBudget = 256
+
+// Minimum budget granted regardless of the file descriptors limit
+const minOpenFilesBudget = 8
+
+// Returns the default number of files the storage can keep open at the same time, derived from the file descriptors
+// limit of the process where available
+func GetDefaultOpenFilesBudget() int {
+	limit, ok := getFileDescriptorsLimit()
+	if !ok {
+		return defaultOpenFilesBudget
+	}
+
+	budget := int(float64(limit) * fdLimitShare)
+	if budget < minOpenFilesBudget {
+		return minOpenFilesBudget
+	}
+	return budget
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package storage
+
+import (
+	"math"
+	"syscall"
+)
+
+// Returns the soft limit of the open file descriptors of the process
+func getFileDescriptorsLimit() (uint64, bool) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, false
+	}
+	// unlimited descriptors
+	if limit.Cur == math.MaxUint64 {
+		return 0, false
+	}
+	return uint64(limit.Cur), true
+}
@@ -0,0 +1,6 @@
+package storage
+
+// Windows has no per process descriptors limit comparable to the unix one, the default budget is used
+func getFileDescriptorsLimit() (uint64, bool) {
+	return 0, false
+}
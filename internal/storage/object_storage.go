@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Url schemes of the object stores the tilesets can be written to, i.e. AWS S3, Google Cloud Storage and Azure Blob
+// Storage
+const (
+	S3Scheme    = "s3"
+	GcsScheme   = "gs"
+	AzureScheme = "az"
+)
+
+// Size of the objects beyond which they are uploaded in parts, and size of the parts
+const (
+	multipartThreshold = 16 << 20
+	multipartPartSize  = 8 << 20
+)
+
+// Number of attempts of the requests to the object stores, retried after a delay doubling at every failure
+const objectRequestAttempts = 5
+
+// Delay before the first retry of a failed request to an object store
+const objectRetryDelay = 500 * time.Millisecond
+
+// Timeout of the requests to the object stores, long enough to upload a part on a slow connection
+const objectRequestTimeout = 5 * time.Minute
+
+// Client of an object store, writing and reading whole objects by key in a bucket or container
+type ObjectClient interface {
+	// Uploads the given object, in parts if it is large
+	PutObject(key string, data []byte) error
+
+	// Downloads the given object, returning an error satisfying os.IsNotExist if it does not exist
+	GetObject(key string) ([]byte, error)
+}
+
+// Returns true if the given output is the url of an object store, e.g. s3://bucket/prefix, rather than a folder
+func IsObjectUrl(output string) bool {
+	for _, scheme := range []string{S3Scheme, GcsScheme, AzureScheme} {
+		if strings.HasPrefix(strings.ToLower(output), scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// Splits the given object store url in its scheme, bucket and key prefix, without leading and trailing slashes
+func ParseObjectUrl(url string) (string, string, string, error) {
+	separator := strings.Index(url, "://")
+	if !IsObjectUrl(url) || separator < 0 {
+		return "", "", "", errors.New("the url " + url + " should start with s3://, gs:// or az://")
+	}
+	scheme, location := strings.ToLower(url[:separator]), strings.TrimRight(url[separator+3:], "/")
+	bucket, prefix := location, ""
+	if slash := strings.Index(location, "/"); slash >= 0 {
+		bucket, prefix = location[:slash], strings.Trim(location[slash+1:], "/")
+	}
+	if bucket == "" {
+		return "", "", "", errors.New("the url " + url + " does not name a bucket")
+	}
+	return scheme, bucket, prefix, nil
+}
+
+// Instantiates the client of the object store of the given url, configured by the environment variables of the
+// store, and returns it along with the key prefix of the url
+func NewObjectClient(url string) (ObjectClient, string, error) {
+	scheme, bucket, prefix, err := ParseObjectUrl(url)
+	if err != nil {
+		return nil, "", err
+	}
+	var client ObjectClient
+	switch scheme {
+	case S3Scheme:
+		client, err = newS3ClientFromEnvironment(bucket)
+	case GcsScheme:
+		client, err = newGcsClientFromEnvironment(bucket)
+	case AzureScheme:
+		client, err = newAzureClientFromEnvironment(bucket)
+	}
+	return client, prefix, err
+}
+
+// Storage writing the files of an output folder as objects of an object store, so that the tilesets stream to the
+// store without being written locally. The tiler writes in a local staging folder standing for the output url: the
+// files written through the storage are uploaded under the key prefix of the url, while the ones written directly in
+// the staging folder are uploaded by Sync. The files outside of the staging folder, e.g. the spooled points, are
+// written to the local file system.
+type ObjectStorage struct {
+	client  ObjectClient
+	prefix  string
+	staging string
+	// folder holding the staging folder, removed by Cleanup
+	temporary string
+	local     Storage
+	// keys of the objects uploaded through the storage, not uploaded again by Sync
+	uploaded map[string]bool
+	sync.Mutex
+}
+
+// Instantiates a storage uploading the files to the object store of the given url. The staging folder, created in the
+// temporary folder of the system, is named after the last element of the url so that the outputs named after their
+// folder are named after the url.
+func NewObjectStorage(url string) (*ObjectStorage, error) {
+	client, prefix, err := NewObjectClient(url)
+	if err != nil {
+		return nil, err
+	}
+	_, bucket, _, _ := ParseObjectUrl(url)
+	temporary, err := ioutil.TempDir("", "gocesiumtiler-output")
+	if err != nil {
+		return nil, err
+	}
+	staging := filepath.Join(temporary, path.Base("/"+bucket+"/"+prefix))
+	if err := os.MkdirAll(staging, 0777); err != nil {
+		_ = os.RemoveAll(temporary)
+		return nil, err
+	}
+	return NewObjectStorageWithClient(client, prefix, staging, temporary), nil
+}
+
+// Instantiates a storage uploading the files of the given staging folder with the given client under the given key
+// prefix. Cleanup removes the given temporary folder, if any.
+func NewObjectStorageWithClient(client ObjectClient, prefix string, staging string, temporary string) *ObjectStorage {
+	return &ObjectStorage{
+		client:    client,
+		prefix:    strings.Trim(prefix, "/"),
+		staging:   staging,
+		temporary: temporary,
+		local:     NewOsStorage(),
+		uploaded:  make(map[string]bool),
+	}
+}
+
+// Returns the local folder standing for the output url, in which the tiler writes
+func (s *ObjectStorage) GetStagingFolder() string {
+	return s.staging
+}
+
+// Opens the given file from the staging folder if written there, otherwise downloads its object
+func (s *ObjectStorage) Open(filePath string) (File, error) {
+	key, ok := s.getKey(filePath)
+	if !ok {
+		return s.local.Open(filePath)
+	}
+	if file, err := os.Open(filePath); err == nil {
+		return file, nil
+	}
+	data, err := s.client.GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	return &objectFile{bytes.NewReader(data)}, nil
+}
+
+// Uploads the given file as an object, unless it is outside of the staging folder
+func (s *ObjectStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	key, ok := s.getKey(filePath)
+	if !ok {
+		return s.local.WriteFile(filePath, data, perm)
+	}
+	if err := s.client.PutObject(key, data); err != nil {
+		return err
+	}
+	s.Lock()
+	s.uploaded[key] = true
+	s.Unlock()
+	return nil
+}
+
+// Creates the given directory locally, the object stores having no directories, so that the files written directly
+// in the staging folder can be written
+func (s *ObjectStorage) MkdirAll(directory string, perm os.FileMode) error {
+	return s.local.MkdirAll(directory, perm)
+}
+
+// Uploads the files written directly in the staging folder, e.g. the statistics of the job, which are not uploaded yet
+func (s *ObjectStorage) Sync() error {
+	return filepath.Walk(s.staging, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		key, _ := s.getKey(filePath)
+		s.Lock()
+		uploaded := s.uploaded[key]
+		s.Unlock()
+		if uploaded {
+			return nil
+		}
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		return s.WriteFile(filePath, data, info.Mode())
+	})
+}
+
+// Removes the temporary folder holding the staging folder
+func (s *ObjectStorage) Cleanup() {
+	if s.temporary != "" {
+		_ = os.RemoveAll(s.temporary)
+	}
+}
+
+// Returns the key of the object of the given file, or false if the file is outside of the staging folder
+func (s *ObjectStorage) getKey(filePath string) (string, bool) {
+	relative, err := filepath.Rel(s.staging, filePath)
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if s.prefix == "" {
+		return filepath.ToSlash(relative), true
+	}
+	return s.prefix + "/" + filepath.ToSlash(relative), true
+}
+
+// A downloaded object opened for reading
+type objectFile struct {
+	*bytes.Reader
+}
+
+func (f *objectFile) Close() error {
+	return nil
+}
+
+// Sends the requests to an object store, retrying the failed ones
+type objectRequester struct {
+	client *http.Client
+}
+
+func newObjectRequester() objectRequester {
+	return objectRequester{client: &http.Client{Timeout: objectRequestTimeout}}
+}
+
+// Sends the request built by the given function, built again at every attempt, retrying the requests failed for
+// network errors, throttling or server errors. Returns the headers and the body of the response, or an error if its
+// status is not successful, satisfying os.IsNotExist if the object is not found.
+func (r objectRequester) send(build func() (*http.Request, error)) (http.Header, []byte, error) {
+	delay := objectRetryDelay
+	for attempt := 1; ; attempt++ {
+		request, err := build()
+		if err != nil {
+			return nil, nil, err
+		}
+		header, body, retry, err := r.do(request)
+		if err == nil || !retry || attempt == objectRequestAttempts {
+			return header, body, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Sends the given request returning true along with the error if it failed and can be retried
+func (r objectRequester) do(request *http.Request) (http.Header, []byte, bool, error) {
+	response, err := r.client.Do(request)
+	if err != nil {
+		return nil, nil, true, err
+	}
+	defer func() { _ = response.Body.Close() }()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, true, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil, false, &os.PathError{Op: strings.ToLower(request.Method), Path: request.URL.Path, Err: os.ErrNotExist}
+	}
+	if response.StatusCode/100 != 2 {
+		retry := response.StatusCode/100 == 5 || response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusRequestTimeout
+		message := string(body)
+		if len(message) > 512 {
+			message = message[:512]
+		}
+		return nil, nil, retry, errors.New(request.Method + " " + request.URL.Path + " failed with status " + response.Status + ": " + message)
+	}
+	return response.Header, body, false, nil
+}
+
+// Returns the parts of the given data uploaded separately
+func splitParts(data []byte) [][]byte {
+	var parts [][]byte
+	for start := 0; start < len(data); start += multipartPartSize {
+		end := start + multipartPartSize
+		if end > len(data) {
+			end = len(data)
+		}
+		parts = append(parts, data[start:end])
+	}
+	return parts
+}
+
+// Escapes the given key as a url path, keeping its slashes
+func escapeKey(key string) string {
+	return uriEncode(key, false)
+}
+
+// Percent-encodes every byte of the given value other than the unreserved characters, and the slashes if encodeSlash
+// is false, as required by the signatures of the object stores
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			encoded.WriteByte(c)
+		} else {
+			encoded.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return encoded.String()
+}
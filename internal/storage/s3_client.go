@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Endpoint of the interoperable XML API of Google Cloud Storage, compatible with the S3 one
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// Signs the requests to the S3 compatible object stores with the AWS Signature Version 4
+type SigV4Signer struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+	Service      string
+}
+
+// Signs the given request, whose body has the given hex encoded SHA-256 hash, at the given time. The x-amz-date,
+// x-amz-content-sha256 and, with temporary credentials, x-amz-security-token headers are set and signed along with
+// the host and the other headers of the request.
+func (s *SigV4Signer) Sign(request *http.Request, payloadHash string, at time.Time) {
+	at = at.UTC()
+	date := at.Format("20060102")
+	request.Header.Set("X-Amz-Date", at.Format("20060102T150405Z"))
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + at.Format("20060102T150405Z") + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSha256([]byte("AWS4"+s.SecretKey), date)
+	for _, element := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSha256(key, element)
+	}
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+",SignedHeaders="+signedHeaders+",Signature="+signature)
+}
+
+// Returns the query parameters sorted by name, names and values being percent-encoded
+func canonicalQuery(query url.Values) string {
+	var parameters []string
+	for name, values := range query {
+		for _, value := range values {
+			parameters = append(parameters, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(parameters)
+	return strings.Join(parameters, "&")
+}
+
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Client of the S3 API, also spoken by Google Cloud Storage and by self hosted stores such as MinIO
+type s3Client struct {
+	endpoint *url.URL
+	bucket   string
+	// true if the bucket is the first element of the path rather than a subdomain of the endpoint
+	pathStyle bool
+	signer    SigV4Signer
+	requester objectRequester
+}
+
+// Instantiates an S3 client with the credentials and the region of the AWS environment variables, i.e.
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION. The endpoint of a compatible store is
+// given by AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL, whose buckets are addressed in the path.
+func newS3ClientFromEnvironment(bucket string) (ObjectClient, error) {
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint, pathStyle := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"), true
+	if endpoint == "" {
+		endpoint, pathStyle = "https://s3."+region+".amazonaws.com", false
+	}
+	signer := SigV4Signer{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Region:       region,
+		Service:      "s3",
+	}
+	if signer.AccessKey == "" || signer.SecretKey == "" {
+		return nil, errors.New("the s3 output requires the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables")
+	}
+	return newS3Client(endpoint, bucket, pathStyle, signer)
+}
+
+// Instantiates a client of the XML API of Google Cloud Storage authenticated by the HMAC key of the
+// GOOGLE_HMAC_ACCESS_KEY_ID and GOOGLE_HMAC_SECRET environment variables
+func newGcsClientFromEnvironment(bucket string) (ObjectClient, error) {
+	signer := SigV4Signer{
+		AccessKey: os.Getenv("GOOGLE_HMAC_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("GOOGLE_HMAC_SECRET"),
+		Region:    "auto",
+		Service:   "s3",
+	}
+	if signer.AccessKey == "" || signer.SecretKey == "" {
+		return nil, errors.New("the gs output requires the GOOGLE_HMAC_ACCESS_KEY_ID and GOOGLE_HMAC_SECRET environment variables")
+	}
+	endpoint := os.Getenv("GOOGLE_STORAGE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = gcsEndpoint
+	}
+	return newS3Client(endpoint, bucket, true, signer)
+}
+
+func newS3Client(endpoint string, bucket string, pathStyle bool, signer SigV4Signer) (*s3Client, error) {
+	parsed, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.New("invalid object store endpoint " + endpoint)
+	}
+	return &s3Client{
+		endpoint:  parsed,
+		bucket:    bucket,
+		pathStyle: pathStyle,
+		signer:    signer,
+		requester: newObjectRequester(),
+	}, nil
+}
+
+func (c *s3Client) PutObject(key string, data []byte) error {
+	if len(data) > multipartThreshold {
+		return c.putMultipartObject(key, data)
+	}
+	_, _, err := c.send(http.MethodPut, key, nil, data)
+	return err
+}
+
+func (c *s3Client) GetObject(key string) ([]byte, error) {
+	_, body, err := c.send(http.MethodGet, key, nil, nil)
+	return body, err
+}
+
+// Uploads the given object in parts, aborting the upload if a part fails so that the store does not keep them
+func (c *s3Client) putMultipartObject(key string, data []byte) error {
+	_, body, err := c.send(http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadId string
+	}
+	if err := xml.Unmarshal(body, &initiated); err != nil || initiated.UploadId == "" {
+		return errors.New("unexpected response initiating the upload of " + key + ": " + string(body))
+	}
+
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	completion := struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{}
+	for i, content := range splitParts(data) {
+		query := url.Values{"partNumber": {strconv.Itoa(i + 1)}, "uploadId": {initiated.UploadId}}
+		header, _, err := c.send(http.MethodPut, key, query, content)
+		if err != nil {
+			c.abortMultipartUpload(key, initiated.UploadId)
+			return err
+		}
+		completion.Parts = append(completion.Parts, part{PartNumber: i + 1, ETag: header.Get("ETag")})
+	}
+
+	content, err := xml.Marshal(completion)
+	if err != nil {
+		return err
+	}
+	_, body, err = c.send(http.MethodPost, key, url.Values{"uploadId": {initiated.UploadId}}, content)
+	if err == nil {
+		// the completion can fail after the response status has been sent, in which case the body holds the error
+		var result struct {
+			XMLName xml.Name
+			Message string
+		}
+		if xml.Unmarshal(body, &result) == nil && result.XMLName.Local == "Error" {
+			err = errors.New("the upload of " + key + " failed: " + result.Message)
+		}
+	}
+	if err != nil {
+		c.abortMultipartUpload(key, initiated.UploadId)
+	}
+	return err
+}
+
+func (c *s3Client) abortMultipartUpload(key string, uploadId string) {
+	_, _, _ = c.send(http.MethodDelete, key, url.Values{"uploadId": {uploadId}}, nil)
+}
+
+// Sends a signed request on the given object with the given query parameters and body
+func (c *s3Client) send(method string, key string, query url.Values, body []byte) (http.Header, []byte, error) {
+	target := *c.endpoint
+	target.Path, target.RawPath = c.endpoint.Path+"/"+key, c.endpoint.EscapedPath()+"/"+escapeKey(key)
+	if c.pathStyle {
+		target.Path, target.RawPath = c.endpoint.Path+"/"+c.bucket+"/"+key, c.endpoint.EscapedPath()+"/"+escapeKey(c.bucket)+"/"+escapeKey(key)
+	} else {
+		target.Host = c.bucket + "." + c.endpoint.Host
+	}
+	target.RawQuery = canonicalQuery(query)
+	payloadHash := hashHex(body)
+	return c.requester.send(func() (*http.Request, error) {
+		request, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		request.ContentLength = int64(len(body))
+		c.signer.Sign(request, payloadHash, time.Now())
+		return request, nil
+	})
+}
+
+// Returns the value of the first of the given environment variables which is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
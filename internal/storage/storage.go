@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// A file opened for reading
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Closer
+}
+
+// Abstracts the file system operations performed while tiling, so that they can be redirected or fault injected
+type Storage interface {
+	// Opens the given file for reading
+	Open(filePath string) (File, error)
+
+	// Writes the given data to the file, creating it or truncating it if it exists
+	WriteFile(filePath string, data []byte, perm os.FileMode) error
+
+	// Creates the given directory along with any missing parent
+	MkdirAll(directory string, perm os.FileMode) error
+}
+
+// Storage backed by the local file system
+type OsStorage struct{}
+
+func NewOsStorage() Storage {
+	return &OsStorage{}
+}
+
+func (s *OsStorage) Open(filePath string) (File, error) {
+	return os.Open(filePath)
+}
+
+func (s *OsStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(filePath, data, perm)
+}
+
+func (s *OsStorage) MkdirAll(directory string, perm os.FileMode) error {
+	return os.MkdirAll(directory, perm)
+}
@@ -0,0 +1,295 @@
+package synthetic
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// Size of the header of the LAS 1.2 files
+const headerSize = 227
+
+// Point data format and record length of the points, i.e. the coordinates, the intensity, the returns, the
+// classification and the colors
+const (
+	pointFormat  = 2
+	recordLength = 26
+)
+
+// Record id and size of the LASF_Projection VLR declaring the coordinate reference system as a GeoKey directory
+// holding the model type and the EPSG code of the coordinate system
+const (
+	geoKeyDirectoryRecordId = 34735
+	vlrHeaderSize           = 54
+	geoKeyDirectorySize     = 24
+)
+
+// GeoTIFF keys and model types of the GeoKey directory
+const (
+	modelTypeGeoKey       = 1024
+	geographicTypeGeoKey  = 2048
+	projectedCsTypeGeoKey = 3072
+	modelTypeProjected    = 1
+	modelTypeGeographic   = 2
+)
+
+// ASPRS classes of the generated points
+const (
+	classGround   = 2
+	classBuilding = 6
+	classNoise    = 7
+)
+
+// Approximate length in meters of a degree of latitude, converting the extent of the geographic datasets
+const metersPerDegree = 111320.0
+
+// Synthetic point cloud made of a rolling terrain, box shaped buildings standing on it and noise points scattered
+// above and below it, generated deterministically from a seed so that the same settings always produce the same file
+type Dataset struct {
+	// EPSG code of the coordinate reference system of the points, either geographic in degrees or projected in meters
+	Srid int
+	// Coordinates of the center of the dataset in the coordinate reference system
+	OriginX float64
+	OriginY float64
+	// Size in meters of the side of the square area covered by the dataset
+	Extent float64
+	// Number of terrain and roof points per square meter
+	Density float64
+	// Number of buildings standing on the terrain
+	Buildings int
+	// Fraction of noise points added to the terrain and roof points
+	Noise float64
+	Seed  int64
+}
+
+// Returns true if the given EPSG code is the one of a geographic coordinate system, whose coordinates are degrees
+func IsGeographic(srid int) bool {
+	return srid >= 4000 && srid < 5000
+}
+
+// Returns the default center of the datasets in the coordinate system with the given EPSG code, i.e. a point of
+// central Italy for the geographic systems and a point in the valid area of most UTM zones for the projected ones
+func GetDefaultOrigin(srid int) (float64, float64) {
+	if IsGeographic(srid) {
+		return 12.5, 41.9
+	}
+	return 500000, 4600000
+}
+
+type building struct {
+	minU, maxU, minV, maxV float64
+	roof                   float64
+}
+
+type point struct {
+	u, v, z        float64
+	classification uint8
+	r, g, b        uint16
+	intensity      uint16
+}
+
+// Writes the points of the dataset in the LAS 1.2 file at the given path, returning the number of points written
+func (d *Dataset) WriteLas(filePath string) (int64, error) {
+	// the point counts of the LAS 1.2 headers are 32 bit unsigned integers
+	if d.Density*d.Extent*d.Extent > math.MaxUint32 {
+		return 0, errors.New("a LAS 1.2 file cannot hold more than 4294967295 points, lower the density or the extent")
+	}
+	file, err := os.Create(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	writer := &lasWriter{dataset: d, scale: 0.01}
+	if IsGeographic(d.Srid) {
+		writer.scale = 1e-7
+	}
+	offset := int64(headerSize + vlrHeaderSize + geoKeyDirectorySize)
+	if _, err := file.Seek(offset, 0); err != nil {
+		return 0, err
+	}
+	writer.buffer = bufio.NewWriter(file)
+
+	d.generate(writer.write)
+	if err := writer.buffer.Flush(); err != nil {
+		return 0, err
+	}
+	if writer.count > math.MaxUint32 {
+		return 0, errors.New("a LAS 1.2 file cannot hold more than 4294967295 points, lower the density or the extent")
+	}
+	header := append(writer.getHeader(uint32(offset)), d.getProjectionVlr()...)
+	if _, err := file.WriteAt(header, 0); err != nil {
+		return 0, err
+	}
+	return writer.count, file.Close()
+}
+
+// Generates the points of the dataset in the local frame of the dataset, whose axes are in meters from its min corner
+func (d *Dataset) generate(emit func(p *point)) {
+	random := rand.New(rand.NewSource(d.Seed))
+
+	// the terrain is the sum of waves of random direction, wavelength and phase
+	var waves [4][4]float64
+	for i := range waves {
+		angle := random.Float64() * 2 * math.Pi
+		length := 150 + random.Float64()*450
+		waves[i] = [4]float64{math.Cos(angle) / length, math.Sin(angle) / length, random.Float64() * 2 * math.Pi, 3 + random.Float64()*9}
+	}
+	terrain := func(u float64, v float64) float64 {
+		z := 100.0
+		for _, wave := range waves {
+			z += wave[3] * math.Sin(2*math.Pi*(u*wave[0]+v*wave[1])+wave[2])
+		}
+		return z
+	}
+
+	buildings := make([]building, d.Buildings)
+	for i := range buildings {
+		width, depth := 10+random.Float64()*30, 10+random.Float64()*30
+		u, v := random.Float64()*math.Max(d.Extent-width, 0), random.Float64()*math.Max(d.Extent-depth, 0)
+		buildings[i] = building{
+			minU: u,
+			maxU: u + width,
+			minV: v,
+			maxV: v + depth,
+			roof: terrain(u+width/2, v+depth/2) + 5 + random.Float64()*25,
+		}
+	}
+
+	count := int(d.Density * d.Extent * d.Extent)
+	for i := 0; i < count; i++ {
+		p := &point{u: random.Float64() * d.Extent, v: random.Float64() * d.Extent, intensity: uint16(random.Intn(1 << 12))}
+		p.z, p.classification = terrain(p.u, p.v), classGround
+		shade := uint16(random.Intn(30))
+		p.r, p.g, p.b = (90+shade)<<8, (120+shade)<<8, (60+shade)<<8
+		for _, b := range buildings {
+			if p.u >= b.minU && p.u <= b.maxU && p.v >= b.minV && p.v <= b.maxV {
+				p.z, p.classification = b.roof, classBuilding
+				p.r, p.g, p.b = (170+shade)<<8, (70+shade)<<8, (60+shade)<<8
+			}
+		}
+		emit(p)
+	}
+
+	// the walls are sampled with the density of the terrain, from the terrain to the roof
+	for _, b := range buildings {
+		width, depth := b.maxU-b.minU, b.maxV-b.minV
+		perimeter := 2 * (width + depth)
+		for i := 0; i < int(d.Density*perimeter*(b.roof-terrain(b.minU, b.minV))); i++ {
+			p := &point{classification: classBuilding, intensity: uint16(random.Intn(1 << 12))}
+			switch position := random.Float64() * perimeter; {
+			case position < width:
+				p.u, p.v = b.minU+position, b.minV
+			case position < 2*width:
+				p.u, p.v = b.minU+position-width, b.maxV
+			case position < 2*width+depth:
+				p.u, p.v = b.minU, b.minV+position-2*width
+			default:
+				p.u, p.v = b.maxU, b.minV+position-2*width-depth
+			}
+			ground := terrain(p.u, p.v)
+			p.z = ground + random.Float64()*math.Max(b.roof-ground, 0)
+			shade := uint16(random.Intn(30))
+			p.r, p.g, p.b = (180+shade)<<8, (180+shade)<<8, (170+shade)<<8
+			emit(p)
+		}
+	}
+
+	for i := 0; i < int(float64(count)*d.Noise); i++ {
+		p := &point{u: random.Float64() * d.Extent, v: random.Float64() * d.Extent, classification: classNoise}
+		p.z = terrain(p.u, p.v) - 20 + random.Float64()*80
+		p.r, p.g, p.b = 255<<8, 255<<8, 255<<8
+		emit(p)
+	}
+}
+
+// Returns the coordinates in the coordinate reference system of the dataset of the given point of its local frame
+func (d *Dataset) getCoordinates(u float64, v float64) (float64, float64) {
+	u, v = u-d.Extent/2, v-d.Extent/2
+	if IsGeographic(d.Srid) {
+		return d.OriginX + u/(metersPerDegree*math.Cos(d.OriginY*math.Pi/180)), d.OriginY + v/metersPerDegree
+	}
+	return d.OriginX + u, d.OriginY + v
+}
+
+// Returns the LASF_Projection VLR declaring the EPSG code of the coordinate reference system of the dataset
+func (d *Dataset) getProjectionVlr() []byte {
+	vlr := make([]byte, vlrHeaderSize+geoKeyDirectorySize)
+	copy(vlr[2:18], "LASF_Projection")
+	binary.LittleEndian.PutUint16(vlr[18:20], geoKeyDirectoryRecordId)
+	binary.LittleEndian.PutUint16(vlr[20:22], geoKeyDirectorySize)
+	copy(vlr[22:54], "GeoKeyDirectoryTag")
+
+	modelType, csKey := uint16(modelTypeProjected), uint16(projectedCsTypeGeoKey)
+	if IsGeographic(d.Srid) {
+		modelType, csKey = modelTypeGeographic, geographicTypeGeoKey
+	}
+	// key directory version, revision, minor revision and number of keys, then id, location, count and value of every key
+	keys := []uint16{1, 1, 0, 2, modelTypeGeoKey, 0, 1, modelType, csKey, 0, 1, uint16(d.Srid)}
+	for i, key := range keys {
+		binary.LittleEndian.PutUint16(vlr[vlrHeaderSize+2*i:], key)
+	}
+	return vlr
+}
+
+// Writes the points of a dataset to a LAS file, keeping track of their bounds and count for the header
+type lasWriter struct {
+	dataset                            *Dataset
+	buffer                             *bufio.Writer
+	scale                              float64
+	count                              int64
+	minX, maxX, minY, maxY, minZ, maxZ float64
+}
+
+func (w *lasWriter) write(p *point) {
+	x, y := w.dataset.getCoordinates(p.u, p.v)
+	if w.count == 0 {
+		w.minX, w.maxX, w.minY, w.maxY, w.minZ, w.maxZ = x, x, y, y, p.z, p.z
+	}
+	w.minX, w.maxX = math.Min(w.minX, x), math.Max(w.maxX, x)
+	w.minY, w.maxY = math.Min(w.minY, y), math.Max(w.maxY, y)
+	w.minZ, w.maxZ = math.Min(w.minZ, p.z), math.Max(w.maxZ, p.z)
+	w.count++
+
+	record := make([]byte, recordLength)
+	binary.LittleEndian.PutUint32(record[0:4], uint32(int32(math.Round((x-w.dataset.OriginX)/w.scale))))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(int32(math.Round((y-w.dataset.OriginY)/w.scale))))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(int32(math.Round(p.z/0.01))))
+	binary.LittleEndian.PutUint16(record[12:14], p.intensity)
+	// single return
+	record[14] = 1 | 1<<3
+	record[15] = p.classification
+	binary.LittleEndian.PutUint16(record[20:22], p.r)
+	binary.LittleEndian.PutUint16(record[22:24], p.g)
+	binary.LittleEndian.PutUint16(record[24:26], p.b)
+	// the buffered writer keeps the first error, returned when flushed
+	_, _ = w.buffer.Write(record)
+}
+
+// Returns the LAS 1.2 header of the points written, declaring a VLR and the points at the given offset
+func (w *lasWriter) getHeader(pointsOffset uint32) []byte {
+	header := make([]byte, headerSize)
+	copy(header[0:4], "LASF")
+	header[24], header[25] = 1, 2
+	copy(header[26:58], "SYNTHETIC")
+	copy(header[58:90], "gocesiumtiler")
+	binary.LittleEndian.PutUint16(header[94:96], headerSize)
+	binary.LittleEndian.PutUint32(header[96:100], pointsOffset)
+	binary.LittleEndian.PutUint32(header[100:104], 1)
+	header[104] = pointFormat
+	binary.LittleEndian.PutUint16(header[105:107], recordLength)
+	binary.LittleEndian.PutUint32(header[107:111], uint32(w.count))
+	binary.LittleEndian.PutUint32(header[111:115], uint32(w.count))
+	values := []float64{
+		w.scale, w.scale, 0.01,
+		w.dataset.OriginX, w.dataset.OriginY, 0,
+		w.maxX, w.minX, w.maxY, w.minY, w.maxZ, w.minZ,
+	}
+	for i, value := range values {
+		binary.LittleEndian.PutUint64(header[131+8*i:], math.Float64bits(value))
+	}
+	return header
+}
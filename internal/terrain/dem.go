@@ -0,0 +1,136 @@
+package terrain
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A digital elevation model made of a regular grid of heights
+type Dem struct {
+	columns  int
+	rows     int
+	xMin     float64
+	yMin     float64
+	cellSize float64
+	noData   float64
+	heights  []float64
+}
+
+// Loads a digital elevation model stored as an ESRI ASCII grid (.asc) file
+func LoadAsciiGrid(filePath string) (*Dem, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanWords)
+
+	header := make(map[string]float64)
+	var firstValue string
+	for scanner.Scan() {
+		key := strings.ToLower(scanner.Text())
+		if _, err := strconv.ParseFloat(key, 64); err == nil {
+			// header ended, this is the first height of the grid
+			firstValue = key
+			break
+		}
+		if !scanner.Scan() {
+			return nil, errors.New("truncated ascii grid header in " + filePath)
+		}
+		value, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return nil, errors.New("invalid ascii grid header value for " + key + " in " + filePath)
+		}
+		header[key] = value
+	}
+
+	dem, err := newDemFromHeader(header)
+	if err != nil {
+		return nil, errors.New(err.Error() + " in " + filePath)
+	}
+
+	values := []string{firstValue}
+	for scanner.Scan() {
+		values = append(values, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if firstValue == "" || len(values) != dem.columns*dem.rows {
+		return nil, errors.New("the number of heights does not match the grid size in " + filePath)
+	}
+
+	dem.heights = make([]float64, len(values))
+	for i, value := range values {
+		if dem.heights[i], err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, errors.New("invalid height " + value + " in " + filePath)
+		}
+	}
+
+	return dem, nil
+}
+
+func newDemFromHeader(header map[string]float64) (*Dem, error) {
+	for _, key := range []string{"ncols", "nrows", "cellsize"} {
+		if _, ok := header[key]; !ok {
+			return nil, errors.New("missing ascii grid header field " + key)
+		}
+	}
+
+	dem := &Dem{
+		columns:  int(header["ncols"]),
+		rows:     int(header["nrows"]),
+		cellSize: header["cellsize"],
+		noData:   -9999,
+	}
+	if dem.columns <= 0 || dem.rows <= 0 || dem.cellSize <= 0 {
+		return nil, errors.New("invalid ascii grid size")
+	}
+	if noData, ok := header["nodata_value"]; ok {
+		dem.noData = noData
+	}
+
+	// grid origin can be given either as the lower left corner or as the center of the lower left cell
+	xCorner, hasXCorner := header["xllcorner"]
+	yCorner, hasYCorner := header["yllcorner"]
+	xCenter, hasXCenter := header["xllcenter"]
+	yCenter, hasYCenter := header["yllcenter"]
+	switch {
+	case hasXCorner && hasYCorner:
+		dem.xMin, dem.yMin = xCorner, yCorner
+	case hasXCenter && hasYCenter:
+		dem.xMin, dem.yMin = xCenter-dem.cellSize/2, yCenter-dem.cellSize/2
+	default:
+		return nil, errors.New("missing ascii grid origin")
+	}
+
+	return dem, nil
+}
+
+// Returns the index of the grid cell containing the given point and whether the point falls inside the grid
+func (d *Dem) CellIndex(x float64, y float64) (int, bool) {
+	col := int(math.Floor((x - d.xMin) / d.cellSize))
+	// rows are stored from north to south
+	row := d.rows - 1 - int(math.Floor((y-d.yMin)/d.cellSize))
+	if col < 0 || col >= d.columns || row < 0 || row >= d.rows {
+		return 0, false
+	}
+
+	return row*d.columns + col, true
+}
+
+// Returns the terrain height at the given point and whether it is known
+func (d *Dem) HeightAt(x float64, y float64) (float64, bool) {
+	index, ok := d.CellIndex(x, y)
+	if !ok || d.heights[index] == d.noData {
+		return 0, false
+	}
+
+	return d.heights[index], true
+}
@@ -0,0 +1,96 @@
+package terrain
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Estimates the vertical offset between a point cloud and the terrain. It implements the octree.ITree interface so
+// that it can be filled by the readers, keeping for every terrain cell the lowest point, which is likely to lie on
+// the ground, without storing the whole cloud.
+type OffsetSampler struct {
+	dem                 *Dem
+	demSrid             int
+	coordinateConverter converters.CoordinateConverter
+	elevationCorrector  converters.ElevationCorrector
+	lowestPoints        map[int]geometry.Coordinate
+	sync.Mutex
+}
+
+// Instantiates a new OffsetSampler for the given terrain, whose coordinates are expressed in the given srid. Point
+// heights are corrected with the given elevation corrector, as the tree would, before being compared to the terrain.
+func NewOffsetSampler(dem *Dem, demSrid int, coordinateConverter converters.CoordinateConverter, elevationCorrector converters.ElevationCorrector) *OffsetSampler {
+	return &OffsetSampler{
+		dem:                 dem,
+		demSrid:             demSrid,
+		coordinateConverter: coordinateConverter,
+		elevationCorrector:  elevationCorrector,
+		lowestPoints:        make(map[int]geometry.Coordinate),
+	}
+}
+
+func (s *OffsetSampler) Build() error {
+	return nil
+}
+
+func (s *OffsetSampler) GetRootNode() octree.INode {
+	return nil
+}
+
+func (s *OffsetSampler) IsBuilt() bool {
+	return false
+}
+
+func (s *OffsetSampler) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	wgs84coords, err := s.coordinateConverter.ConvertCoordinateSrid(srid, 4326, *coordinate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	z := s.elevationCorrector.CorrectElevation(wgs84coords.X, wgs84coords.Y, wgs84coords.Z)
+
+	demCoords, err := s.coordinateConverter.ConvertCoordinateSrid(4326, s.demSrid, wgs84coords)
+	if err != nil {
+		log.Fatal(err)
+	}
+	demCoords.Z = z
+
+	cell, ok := s.dem.CellIndex(demCoords.X, demCoords.Y)
+	if !ok {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if lowest, exists := s.lowestPoints[cell]; !exists || demCoords.Z < lowest.Z {
+		s.lowestPoints[cell] = demCoords
+	}
+}
+
+// Returns the vertical offset to add to the points to make the cloud sit on the terrain, computed as the median of
+// the differences between the terrain height and the lowest point of every cell
+func (s *OffsetSampler) ComputeOffset() (float64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var differences []float64
+	for _, point := range s.lowestPoints {
+		if height, ok := s.dem.HeightAt(point.X, point.Y); ok {
+			differences = append(differences, height-point.Z)
+		}
+	}
+	if len(differences) == 0 {
+		return 0, errors.New("the point cloud does not overlap the terrain")
+	}
+
+	sort.Float64s(differences)
+	middle := len(differences) / 2
+	if len(differences)%2 == 0 {
+		return (differences[middle-1] + differences[middle]) / 2, nil
+	}
+	return differences[middle], nil
+}
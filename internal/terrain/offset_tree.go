@@ -0,0 +1,25 @@
+package terrain
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+)
+
+// Decorates a tree shifting vertically all the points added to it by a fixed offset
+type offsetTree struct {
+	octree.ITree
+	offset float64
+}
+
+// Wraps the given tree so that the given vertical offset is added to the elevation of every point added to it
+func NewOffsetTree(tree octree.ITree, offset float64) octree.ITree {
+	return &offsetTree{
+		ITree:  tree,
+		offset: offset,
+	}
+}
+
+func (t *offsetTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	shifted := geometry.Coordinate{X: coordinate.X, Y: coordinate.Y, Z: coordinate.Z + t.offset}
+	t.ITree.AddPoint(&shifted, r, g, b, intensity, classification, srid, attributes)
+}
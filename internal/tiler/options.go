@@ -1,9 +1,36 @@
 package tiler
 
-import "strings"
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/progress"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
 
 type Algorithm string
 type RefineMode string
+type CoordinateFrame string
+type OriginSnapMode string
+type ReturnsMode string
+type CompressionMode string
+type ElevationStepKind string
+type LeafCapPolicy string
+type FlaggedPointsMode string
+type AlphaSource string
+type BatchTableEncoding string
+type InvalidColorsMode string
+type SidecarKey string
+type ErrorPolicy string
+type TilesetVersion string
+type ColorSource string
+type DensityMode string
+type GridSampling string
+type TreeDumpFormat string
 
 const (
 	// Uniform random pick among all loaded elements. points will tend to be selected in areas with higher density.
@@ -15,6 +42,10 @@ const (
 	// the selection will begin again from the first one. If one box becomes empty is removed and replaced with the last one in the set.
 	Random    Algorithm = "RANDOM"
 	RandomBox Algorithm = "RANDOMBOX"
+
+	// Random pick reading the points twice: the first pass samples them to size the nodes, the second one appends every
+	// point to the spool file of the node keeping it, so that the points are never all held in memory.
+	TwoPass Algorithm = "TWOPASS"
 )
 
 const (
@@ -41,13 +72,811 @@ func ParseRefineMode(value string) RefineMode {
 	return ""
 }
 
+const (
+	// Points are stored as Earth-Centered Earth-Fixed coordinates relative to the tile center
+	CoordinateFrameEcef CoordinateFrame = "ECEF"
+
+	// Points are stored in a local East-North-Up frame and the root tile carries the transform to ECEF
+	CoordinateFrameLocal CoordinateFrame = "LOCAL"
+)
+
+func (e CoordinateFrame) String() string {
+	if e == CoordinateFrameEcef {
+		return "ECEF"
+	} else if e == CoordinateFrameLocal {
+		return "LOCAL"
+	}
+	return ""
+}
+
+func ParseCoordinateFrame(value string) CoordinateFrame {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "ECEF" {
+		return CoordinateFrameEcef
+	} else if normalizedValue == "LOCAL" {
+		return CoordinateFrameLocal
+	}
+	return ""
+}
+
+const (
+	// The root bounding box is the raw bounding box of the points
+	OriginSnapNone OriginSnapMode = "NONE"
+
+	// The root bounding box origin is snapped to a multiple of the grid max cell size and its sides extended to the
+	// max cell size times a power of two, so that node boundaries are aligned to the grid cells at every depth
+	OriginSnapGrid OriginSnapMode = "GRID"
+
+	// The root bounding box is centered on the points centroid
+	OriginSnapCentroid OriginSnapMode = "CENTROID"
+)
+
+func (e OriginSnapMode) String() string {
+	if e == OriginSnapNone {
+		return "NONE"
+	} else if e == OriginSnapGrid {
+		return "GRID"
+	} else if e == OriginSnapCentroid {
+		return "CENTROID"
+	}
+	return ""
+}
+
+func ParseOriginSnapMode(value string) OriginSnapMode {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "NONE" {
+		return OriginSnapNone
+	} else if normalizedValue == "GRID" {
+		return OriginSnapGrid
+	} else if normalizedValue == "CENTROID" {
+		return OriginSnapCentroid
+	}
+	return ""
+}
+
+const (
+	// Leaves exceeding the cap keep all their points and are reported with a warning
+	LeafCapKeepAll LeafCapPolicy = "KEEP_ALL"
+
+	// Leaves exceeding the cap keep a uniform random subsample of their points
+	LeafCapRandom LeafCapPolicy = "RANDOM"
+
+	// Leaves exceeding the cap keep the points of their densest grid cells, dropping the sparsest ones, e.g. noise,
+	// first
+	LeafCapDensest LeafCapPolicy = "DENSEST"
+)
+
+func (e LeafCapPolicy) String() string {
+	if e == LeafCapKeepAll {
+		return "KEEP_ALL"
+	} else if e == LeafCapRandom {
+		return "RANDOM"
+	} else if e == LeafCapDensest {
+		return "DENSEST"
+	}
+	return ""
+}
+
+func ParseLeafCapPolicy(value string) LeafCapPolicy {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "KEEP_ALL" {
+		return LeafCapKeepAll
+	} else if normalizedValue == "RANDOM" {
+		return LeafCapRandom
+	} else if normalizedValue == "DENSEST" {
+		return LeafCapDensest
+	}
+	return ""
+}
+
+const (
+	// Flagged points are tiled like any other point
+	FlaggedPointsKeep FlaggedPointsMode = "KEEP"
+
+	// Flagged points are discarded while loading
+	FlaggedPointsDrop FlaggedPointsMode = "DROP"
+
+	// Flagged points are tiled in a separate tileset, alongside the one of the points of the file without flags
+	FlaggedPointsSplit FlaggedPointsMode = "SPLIT"
+)
+
+func (e FlaggedPointsMode) String() string {
+	if e == FlaggedPointsKeep {
+		return "KEEP"
+	} else if e == FlaggedPointsDrop {
+		return "DROP"
+	} else if e == FlaggedPointsSplit {
+		return "SPLIT"
+	}
+	return ""
+}
+
+func ParseFlaggedPointsMode(value string) FlaggedPointsMode {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "KEEP" {
+		return FlaggedPointsKeep
+	} else if normalizedValue == "DROP" {
+		return FlaggedPointsDrop
+	} else if normalizedValue == "SPLIT" {
+		return FlaggedPointsSplit
+	}
+	return ""
+}
+
+const (
+	// Points are opaque, their colors are written as RGB
+	AlphaNone AlphaSource = "NONE"
+
+	// The alpha of the points is proportional to their intensity, their colors are written as RGBA
+	AlphaIntensity AlphaSource = "INTENSITY"
+)
+
+func (e AlphaSource) String() string {
+	if e == AlphaNone {
+		return "NONE"
+	} else if e == AlphaIntensity {
+		return "INTENSITY"
+	}
+	return ""
+}
+
+func ParseAlphaSource(value string) AlphaSource {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "NONE" {
+		return AlphaNone
+	} else if normalizedValue == "INTENSITY" {
+		return AlphaIntensity
+	}
+	return ""
+}
+
+const (
+	// The batch table properties are stored in the binary body of the batch table, referenced by its JSON header
+	BatchTableBinary BatchTableEncoding = "BINARY"
+
+	// The batch table properties are stored as arrays in the JSON header of the batch table, without binary body
+	BatchTableJson BatchTableEncoding = "JSON"
+)
+
+func (e BatchTableEncoding) String() string {
+	if e == BatchTableBinary {
+		return "BINARY"
+	} else if e == BatchTableJson {
+		return "JSON"
+	}
+	return ""
+}
+
+func ParseBatchTableEncoding(value string) BatchTableEncoding {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "BINARY" {
+		return BatchTableBinary
+	} else if normalizedValue == "JSON" {
+		return BatchTableJson
+	}
+	return ""
+}
+
+const (
+	// Colors detected as invalid are written as they are
+	InvalidColorsKeep InvalidColorsMode = "KEEP"
+
+	// Colors detected as invalid are not written, the tiles only hold the positions and the batch table properties
+	InvalidColorsOmit InvalidColorsMode = "OMIT"
+
+	// Colors detected as invalid are replaced with the intensity of the points, as grayscale
+	InvalidColorsIntensity InvalidColorsMode = "INTENSITY"
+)
+
+func (e InvalidColorsMode) String() string {
+	if e == InvalidColorsKeep {
+		return "KEEP"
+	} else if e == InvalidColorsOmit {
+		return "OMIT"
+	} else if e == InvalidColorsIntensity {
+		return "INTENSITY"
+	}
+	return ""
+}
+
+func ParseInvalidColorsMode(value string) InvalidColorsMode {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "KEEP" {
+		return InvalidColorsKeep
+	} else if normalizedValue == "OMIT" {
+		return InvalidColorsOmit
+	} else if normalizedValue == "INTENSITY" {
+		return InvalidColorsIntensity
+	}
+	return ""
+}
+
+const (
+	// The sidecar records are joined to the points by their zero based index in the input file
+	SidecarKeyIndex SidecarKey = "INDEX"
+
+	// The sidecar records are joined to the points by their GPS time
+	SidecarKeyGpsTime SidecarKey = "GPS_TIME"
+)
+
+func (e SidecarKey) String() string {
+	if e == SidecarKeyIndex {
+		return "INDEX"
+	} else if e == SidecarKeyGpsTime {
+		return "GPS_TIME"
+	}
+	return ""
+}
+
+func ParseSidecarKey(value string) SidecarKey {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "INDEX" {
+		return SidecarKeyIndex
+	} else if normalizedValue == "GPS_TIME" {
+		return SidecarKeyGpsTime
+	}
+	return ""
+}
+
+const (
+	// All the returns are loaded
+	ReturnsAll ReturnsMode = "ALL"
+
+	// Only the first return of every pulse is loaded
+	ReturnsFirst ReturnsMode = "FIRST"
+
+	// Only the last return of every pulse is loaded
+	ReturnsLast ReturnsMode = "LAST"
+)
+
+func (e ReturnsMode) String() string {
+	if e == ReturnsAll {
+		return "ALL"
+	} else if e == ReturnsFirst {
+		return "FIRST"
+	} else if e == ReturnsLast {
+		return "LAST"
+	}
+	return ""
+}
+
+func ParseReturnsMode(value string) ReturnsMode {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "ALL" {
+		return ReturnsAll
+	} else if normalizedValue == "FIRST" {
+		return ReturnsFirst
+	} else if normalizedValue == "LAST" {
+		return ReturnsLast
+	}
+	return ""
+}
+
+const (
+	// Tile contents are written uncompressed
+	CompressionNone CompressionMode = "NONE"
+
+	// Tile contents are compressed with zstd
+	CompressionZstd CompressionMode = "ZSTD"
+)
+
+func (e CompressionMode) String() string {
+	if e == CompressionNone {
+		return "NONE"
+	} else if e == CompressionZstd {
+		return "ZSTD"
+	}
+	return ""
+}
+
+func ParseCompressionMode(value string) CompressionMode {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "NONE" {
+		return CompressionNone
+	} else if normalizedValue == "ZSTD" {
+		return CompressionZstd
+	}
+	return ""
+}
+
+// Parses a comma separated list of per level retention fractions, returning false if any value is not a number.
+// An empty value disables the retention targets.
+func ParseLevelRetention(value string) ([]float64, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, true
+	}
+	var fractions []float64
+	for _, token := range strings.Split(value, ",") {
+		fraction, err := strconv.ParseFloat(strings.TrimSpace(token), 64)
+		if err != nil {
+			return nil, false
+		}
+		fractions = append(fractions, fraction)
+	}
+	return fractions, true
+}
+
+// Parses a decimal number written with either a dot or a comma as decimal separator, e.g. 0.15 or 0,15. Thousands
+// separators are not supported, hence the numbers holding both separators, more than a comma or a comma followed by
+// exactly three digits after a non zero integer part, e.g. 1,500, are rejected as ambiguous.
+func ParseDecimal(value string) (float64, error) {
+	normalizedValue := strings.TrimSpace(value)
+	if separator := strings.Index(normalizedValue, ","); separator >= 0 {
+		integer, fraction := strings.TrimLeft(normalizedValue[:separator], "+-"), normalizedValue[separator+1:]
+		if strings.Count(normalizedValue, ",") > 1 || strings.Contains(normalizedValue, ".") {
+			return 0, errors.New("ambiguous number " + normalizedValue + ", thousands separators are not supported")
+		}
+		if len(fraction) == 3 && strings.Trim(integer, "0") != "" {
+			return 0, errors.New("ambiguous number " + normalizedValue + ", write it with a dot as decimal separator")
+		}
+		normalizedValue = strings.Replace(normalizedValue, ",", ".", 1)
+	}
+	number, err := strconv.ParseFloat(normalizedValue, 64)
+	if err != nil {
+		return 0, errors.New("invalid number " + strings.TrimSpace(value))
+	}
+	return number, nil
+}
+
+// Parses a size in bytes, optionally followed by the decimal K, M, G and T unit prefixes or by the binary Ki, Mi, Gi and
+// Ti ones, with or without the trailing B, e.g. 20GB, 512MiB or 1,5GB. Returns false if the value is not a non negative
+// size. An empty value means no size.
+func ParseByteSize(value string) (int64, bool) {
+	normalizedValue := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	if normalizedValue == "" {
+		return 0, strings.TrimSpace(value) == ""
+	}
+	multiplier, decimal, binary := 1.0, 1.0, 1.0
+	for _, prefix := range []string{"K", "M", "G", "T"} {
+		decimal, binary = decimal*1000, binary*1024
+		if strings.HasSuffix(normalizedValue, prefix+"I") {
+			multiplier, normalizedValue = binary, strings.TrimSuffix(normalizedValue, prefix+"I")
+			break
+		} else if strings.HasSuffix(normalizedValue, prefix) {
+			multiplier, normalizedValue = decimal, strings.TrimSuffix(normalizedValue, prefix)
+			break
+		}
+	}
+	size, err := ParseDecimal(normalizedValue)
+	if err != nil || !(size >= 0) || size*multiplier >= 1<<62 {
+		return 0, false
+	}
+	return int64(size * multiplier), true
+}
+
+// Formats a size in bytes with the largest decimal unit prefix it reaches, e.g. 512 B, 32.77 KB or 1.50 GB, so that
+// it can be parsed back by ParseByteSize
+func FormatByteSize(size int64) string {
+	if size < 1000 && size > -1000 {
+		return strconv.FormatInt(size, 10) + " B"
+	}
+	value := float64(size)
+	for _, unit := range []string{"KB", "MB", "GB"} {
+		value /= 1000
+		if math.Abs(value) < 999.995 {
+			return strconv.FormatFloat(value, 'f', 2, 64) + " " + unit
+		}
+	}
+	return strconv.FormatFloat(value/1000, 'f', 2, 64) + " TB"
+}
+
+// Parses the validity area of the coordinates as a comma separated list of min longitude, min latitude, max longitude
+// and max latitude in degrees, returning false if the value holds other than four numbers. An empty value declares no
+// validity area.
+func ParseValidityArea(value string) ([]float64, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, true
+	}
+	tokens := strings.Split(value, ",")
+	if len(tokens) != 4 {
+		return nil, false
+	}
+	area := make([]float64, 4)
+	for i, token := range tokens {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(token), 64)
+		if err != nil {
+			return nil, false
+		}
+		area[i] = bound
+	}
+	return area, true
+}
+
+const (
+	// The first recoverable error, e.g. an unreadable input file or an untransformable point, aborts the run
+	ErrorPolicyFail ErrorPolicy = "FAIL"
+
+	// The recoverable errors are logged and what caused them skipped, the input files failing being listed at the end
+	// of the run, which fails only if no file could be tiled
+	ErrorPolicyContinue ErrorPolicy = "CONTINUE"
+)
+
+func (e ErrorPolicy) String() string {
+	if e == ErrorPolicyFail {
+		return "FAIL"
+	} else if e == ErrorPolicyContinue {
+		return "CONTINUE"
+	}
+	return ""
+}
+
+func ParseErrorPolicy(value string) ErrorPolicy {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "FAIL" {
+		return ErrorPolicyFail
+	} else if normalizedValue == "CONTINUE" {
+		return ErrorPolicyContinue
+	}
+	return ""
+}
+
+const (
+	// 3D Tiles 1.0 tilesets, whose tile contents are pnts files with feature and batch tables
+	TilesetVersion10 TilesetVersion = "1.0"
+
+	// 3D Tiles 1.1 tilesets, whose tile contents are glb files with point primitives, the properties of the points being
+	// stored with the EXT_mesh_features and EXT_structural_metadata extensions
+	TilesetVersion11 TilesetVersion = "1.1"
+)
+
+func (e TilesetVersion) String() string {
+	if e == TilesetVersion10 {
+		return "1.0"
+	} else if e == TilesetVersion11 {
+		return "1.1"
+	}
+	return ""
+}
+
+func ParseTilesetVersion(value string) TilesetVersion {
+	normalizedValue := strings.TrimSpace(value)
+	if normalizedValue == "1.0" || normalizedValue == "1" {
+		return TilesetVersion10
+	} else if normalizedValue == "1.1" {
+		return TilesetVersion11
+	}
+	return ""
+}
+
+const (
+	// The points are written with the colors of the input files
+	ColorSourceRgb ColorSource = "RGB"
+
+	// The points are colored by their intensity along a color ramp, grayscale by default
+	ColorSourceIntensity ColorSource = "INTENSITY"
+
+	// The points are written with the colors of the input files, or colored by their intensity stretched between
+	// percentiles along the color ramp if the input files do not store colors
+	ColorSourceAuto ColorSource = "AUTO"
+)
+
+func (e ColorSource) String() string {
+	if e == ColorSourceRgb {
+		return "RGB"
+	} else if e == ColorSourceIntensity {
+		return "INTENSITY"
+	} else if e == ColorSourceAuto {
+		return "AUTO"
+	}
+	return ""
+}
+
+func ParseColorSource(value string) ColorSource {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "RGB" {
+		return ColorSourceRgb
+	} else if normalizedValue == "INTENSITY" {
+		return ColorSourceIntensity
+	} else if normalizedValue == "AUTO" {
+		return ColorSourceAuto
+	}
+	return ""
+}
+
+const (
+	// The density target is a number of points per square meter, counted in columns of the ground
+	DensityArea DensityMode = "AREA"
+
+	// The density target is a number of points per cubic meter, counted in voxels
+	DensityVolume DensityMode = "VOLUME"
+)
+
+func (e DensityMode) String() string {
+	if e == DensityArea {
+		return "AREA"
+	} else if e == DensityVolume {
+		return "VOLUME"
+	}
+	return ""
+}
+
+func ParseDensityMode(value string) DensityMode {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "AREA" {
+		return DensityArea
+	} else if normalizedValue == "VOLUME" {
+		return DensityVolume
+	}
+	return ""
+}
+
+const (
+	// Every grid cell keeps the point closest to its center, which spaces the points evenly but lays them on the
+	// lattice of the cells
+	GridSamplingCenter GridSampling = "CENTER"
+
+	// Every grid cell keeps a random point among the ones reaching it, as a random shuffle of the points would, which
+	// avoids the regular patterns of the coarse levels
+	GridSamplingRandom GridSampling = "RANDOM"
+
+	// Every node keeps the points lying farther than its cell size from all the points it already keeps, a Poisson-disk
+	// sampling which spaces the points of the coarse levels evenly with no lattice
+	GridSamplingPoisson GridSampling = "POISSON"
+
+	// Every grid cell keeps the first point reaching it, the cheapest pick, which depends on the order the points are
+	// read and inserted in
+	GridSamplingFirst GridSampling = "FIRST"
+
+	// Every grid cell keeps the point of highest value of an attribute, e.g. the brightest point of the cell
+	GridSamplingMax GridSampling = "MAX"
+)
+
+// Decides which point every cell of the grid algorithm keeps among the points reaching it: the cell keeps the point of
+// lowest score, pushing the other ones down to the children of its node. The coordinates of the points and of the
+// center of the cell, whose side is size, are the metric ones of the tree. Score is called concurrently by the insert
+// workers, hence it has to be safe for concurrent use.
+type SamplingStrategy interface {
+	Score(point *data.Point, centerX float64, centerY float64, centerZ float64, size float64) float64
+}
+
+func (e GridSampling) String() string {
+	if e == GridSamplingCenter {
+		return "CENTER"
+	} else if e == GridSamplingRandom {
+		return "RANDOM"
+	} else if e == GridSamplingPoisson {
+		return "POISSON"
+	} else if e == GridSamplingFirst {
+		return "FIRST"
+	} else if e == GridSamplingMax {
+		return "MAX"
+	}
+	return ""
+}
+
+func ParseGridSampling(value string) GridSampling {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "CENTER" {
+		return GridSamplingCenter
+	} else if normalizedValue == "RANDOM" {
+		return GridSamplingRandom
+	} else if normalizedValue == "POISSON" {
+		return GridSamplingPoisson
+	} else if normalizedValue == "FIRST" {
+		return GridSamplingFirst
+	} else if normalizedValue == "MAX" {
+		return GridSamplingMax
+	}
+	return ""
+}
+
+const (
+	// The structure of the trees is not dumped
+	TreeDumpNone TreeDumpFormat = "NONE"
+
+	// The structure of every tree is dumped as a JSON file, readable by any tool
+	TreeDumpJson TreeDumpFormat = "JSON"
+
+	// The structure of every tree is dumped as a compact binary file, for the trees of millions of nodes
+	TreeDumpBinary TreeDumpFormat = "BINARY"
+)
+
+func (e TreeDumpFormat) String() string {
+	if e == TreeDumpNone {
+		return "NONE"
+	} else if e == TreeDumpJson {
+		return "JSON"
+	} else if e == TreeDumpBinary {
+		return "BINARY"
+	}
+	return ""
+}
+
+func ParseTreeDumpFormat(value string) TreeDumpFormat {
+	normalizedValue := strings.Trim(strings.ToUpper(value), " ")
+	if normalizedValue == "NONE" {
+		return TreeDumpNone
+	} else if normalizedValue == "JSON" {
+		return TreeDumpJson
+	} else if normalizedValue == "BINARY" {
+		return TreeDumpBinary
+	}
+	return ""
+}
+
+const (
+	// Converts the heights from the geoid to the ellipsoid
+	ElevationStepGeoid ElevationStepKind = "GEOID"
+
+	// Adds a constant offset to the heights
+	ElevationStepOffset ElevationStepKind = "OFFSET"
+
+	// Adds to the heights the correction read from an ESRI ASCII grid in EPSG:4326 coordinates, points outside of the
+	// grid or on its no data cells are left unchanged
+	ElevationStepRaster ElevationStepKind = "RASTER"
+)
+
+// A step of the chain of corrections applied to the heights of the points
+type ElevationStep struct {
+	Kind   ElevationStepKind
+	Offset float64 // Offset in meters added by OFFSET steps
+	File   string  // Correction grid of RASTER steps
+}
+
+// Parses a comma separated list of elevation steps applied in sequence, e.g. geoid,offset:-0.35,raster:fix.asc,
+// returning false if any step is unknown or lacks its argument. An empty value disables the chain.
+func ParseElevationPipeline(value string) ([]ElevationStep, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, true
+	}
+	var steps []ElevationStep
+	for _, token := range strings.Split(value, ",") {
+		kind, argument := strings.TrimSpace(token), ""
+		if separator := strings.Index(kind, ":"); separator >= 0 {
+			kind, argument = strings.TrimSpace(kind[:separator]), strings.TrimSpace(kind[separator+1:])
+		}
+		step := ElevationStep{Kind: ElevationStepKind(strings.ToUpper(kind))}
+		switch step.Kind {
+		case ElevationStepGeoid:
+			if argument != "" {
+				return nil, false
+			}
+		case ElevationStepOffset:
+			offset, err := strconv.ParseFloat(argument, 64)
+			if err != nil {
+				return nil, false
+			}
+			step.Offset = offset
+		case ElevationStepRaster:
+			if argument == "" {
+				return nil, false
+			}
+			step.File = argument
+		default:
+			return nil, false
+		}
+		steps = append(steps, step)
+	}
+	return steps, true
+}
+
+// An external program reading the point cloud files of an extension, e.g. a converter built with a vendor SDK
+type ReaderPlugin struct {
+	Extension string   // Lower case extension of the files read by the plugin, including the dot
+	Command   []string // Program and arguments of the plugin, run with the path of the file as {input} or last argument
+}
+
+// Parses a semicolon separated list of extension=command pairs, e.g. .rdbx=rdb2points --all;.rxp=rxp2points, the
+// arguments of the commands being separated by spaces, returning false if any pair lacks its extension or command.
+// An empty value configures no plugins.
+func ParseReaderPlugins(value string) ([]ReaderPlugin, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, true
+	}
+	var plugins []ReaderPlugin
+	for _, token := range strings.Split(value, ";") {
+		separator := strings.Index(token, "=")
+		if separator < 0 {
+			return nil, false
+		}
+		extension := strings.ToLower(strings.TrimSpace(token[:separator]))
+		command := strings.Fields(token[separator+1:])
+		if extension == "" || extension == "." || len(command) == 0 {
+			return nil, false
+		}
+		if !strings.HasPrefix(extension, ".") {
+			extension = "." + extension
+		}
+		plugins = append(plugins, ReaderPlugin{Extension: extension, Command: command})
+	}
+	return plugins, true
+}
+
+// Returns the plugin reading the files with the extension of the given file, nil if none
+func GetReaderPlugin(plugins []ReaderPlugin, fileName string) *ReaderPlugin {
+	extension := strings.ToLower(filepath.Ext(fileName))
+	for i := range plugins {
+		if plugins[i].Extension == extension {
+			return &plugins[i]
+		}
+	}
+	return nil
+}
+
+// Names of the LAS point attributes that can be written in the batch tables, along with the intensity and the
+// classification always written. The components of the normals are read from the PLY and PCD files storing them.
+var LasAttributes = []string{"return_number", "number_of_returns", "gps_time", "scanner_channel", "normal_x", "normal_y", "normal_z"}
+
+// Returns the index among the supplementary attributes of the points of the given attribute maximized by the MAX grid
+// sampling, one of the given LAS attributes written in the batch tables, or -1 for the intensity, which is used if the
+// attribute is empty. False is returned if the attribute is not written in the batch tables.
+func GetSamplingAttributeIndex(attribute string, lasAttributes []string) (int, bool) {
+	name := strings.ToLower(strings.TrimSpace(attribute))
+	if name == "" || name == "intensity" {
+		return -1, true
+	}
+	for i, lasAttribute := range lasAttributes {
+		if lasAttribute == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Parses a comma separated list of the LAS point attributes written in the batch tables, e.g. return_number,gps_time,
+// returning false if any attribute is unknown or repeated. The names are case insensitive. An empty value selects no
+// attribute.
+func ParseLasAttributes(value string) ([]string, bool) {
+	if strings.TrimSpace(value) == "" {
+		return nil, true
+	}
+	var names []string
+	for _, token := range strings.Split(value, ",") {
+		name := strings.ToLower(strings.TrimSpace(token))
+		known := false
+		for _, attribute := range LasAttributes {
+			known = known || attribute == name
+		}
+		for _, selected := range names {
+			known = known && selected != name
+		}
+		if !known {
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
+
+// Number of bits the positions of the Draco compressed contents are quantized to if not configured
+const DefaultDracoPositionBits = 14
+
+// Parses a comma separated list of attribute=bits pairs, e.g. POSITION=16,COLOR=6,INTENSITY=8,gps_time=24, giving the
+// number of bits the attributes of the Draco compressed contents are quantized to, returning false if any pair lacks
+// its attribute or if its bits are not an integer. The POSITION, COLOR, INTENSITY and CLASSIFICATION names are case
+// insensitive, the other ones naming the supplementary attributes. The POSITION bits default to
+// DefaultDracoPositionBits, the other attributes being stored losslessly unless configured.
+func ParseDracoQuantization(value string) (map[string]int, bool) {
+	quantization := map[string]int{"POSITION": DefaultDracoPositionBits}
+	if strings.TrimSpace(value) == "" {
+		return quantization, true
+	}
+	for _, token := range strings.Split(value, ",") {
+		separator := strings.Index(token, "=")
+		if separator < 0 {
+			return nil, false
+		}
+		name := strings.TrimSpace(token[:separator])
+		bits, err := strconv.Atoi(strings.TrimSpace(token[separator+1:]))
+		if name == "" || err != nil {
+			return nil, false
+		}
+		switch upperName := strings.ToUpper(name); upperName {
+		case "POSITION", "COLOR", "INTENSITY", "CLASSIFICATION":
+			name = upperName
+		}
+		quantization[name] = bits
+	}
+	return quantization, true
+}
+
 // Contains the options needed for the tiling algorithm
 type TilerOptions struct {
 	Input                  string     // Input LAS file/folder
 	Output                 string     // Output Cesium Tileset folder
 	Srid                   int        // EPSG code for SRID of input LAS points
 	ZOffset                float64    // Z Offset in meters to apply to points during conversion
-	MaxNumPointsPerNode    int32      // Maximum allowed number of points per node for Random and RandomBox Algorithms
+	MaxNumPointsPerNode    int32      // Maximum allowed number of points per node for Random, RandomBox and TwoPass Algorithms
 	EnableGeoidZCorrection bool       // Enables the conversion from geoid to ellipsoid height
 	FolderProcessing       bool       // Enables the processing of all LAS files in folder
 	Recursive              bool       // Recursive lookup of LAS files in subfolders
@@ -57,4 +886,165 @@ type TilerOptions struct {
 	CellMinSize            float64    // Min cell size for grid algorithm
 	RefineMode             RefineMode // Refine mode to use to generate the tileset
 	RootGeometricError	   float64
+	CoordinateFrame        CoordinateFrame // Reference frame of the point coordinates written in the tiles
+	ContentExtension       string          // Extension of the tile content files, defaults to .pnts, or .glb for 3D Tiles 1.1 tilesets
+	ExtensionlessContent   bool            // Writes tile contents without extension declaring their content type in the tileset
+	HostConfig             bool            // Writes web server configuration snippets mapping the tileset files content types
+	RosCloudTopic          string          // Topic of the PointCloud2 messages to read from ROS bags, all if empty
+	RosPoseTopic           string          // Topic of the pose messages used to georeference ROS bag clouds, none if empty
+	TrajectoryFile         string          // Pose CSV or SBET trajectory used to georeference points by their time, none if empty
+	TerrainFile            string          // ESRI ASCII grid DEM the point cloud is clamped to, none if empty
+	TerrainSrid            int             // EPSG srid code of the terrain DEM coordinates
+	TerrainOffset          float64         // Vertical offset applied to clamp the points to the terrain, computed while tiling
+	OriginSnap             OriginSnapMode  // Strategy used to place the root bounding box of the grid algorithm
+	StatsFinal             bool            // Writes the final statistics of the job in the stats.json output file
+	Tui                    bool            // Shows a live terminal dashboard of the job progress instead of the log
+	MaxOpenFiles           int             // Maximum number of files open at the same time while tiling, automatic if 0
+	StallTimeout           float64         // Minutes without progress after which the job is considered stalled, disabled if 0
+	StallAbort             bool            // Aborts the job when a stall is detected
+	ReadWorkers            int             // Goroutines decoding the points of LAS files, one per CPU if 0
+	ConvertWorkers         int             // Goroutines converting the coordinates of the points, done by the readers if 0
+	InsertWorkers          int             // Goroutines inserting the points in the tree, one per CPU if 0
+	WriteWorkers           int             // Goroutines writing the tiles, one per CPU if 0
+	TileMetadata           bool            // Attaches the aggregate statistics of the points of every tile as 3D Tiles metadata
+	ThumbnailSize          int             // Size in pixels of the top-down PNG thumbnail written for every tile, none if 0
+	Coverage               bool            // Writes the footprint and per level coverage of every tileset as GeoJSON and KML
+	Stac                   bool            // Writes a STAC item describing every tileset
+	StacCollection         bool            // Writes a STAC collection listing the items of all the tilesets, implies Stac
+	GeoVolumes             bool            // Writes static OGC API 3D GeoVolumes documents describing the tilesets
+	LevelRetention         []float64       // Fractions of the points sampled in each of the top levels of the grid tree, none if empty
+	SkipDuplicates         bool            // Skips the input files whose content matches the one of a file already processed in the output folder
+	ControlPointsFile      string          // CSV of control points whose transformation residuals are reported before tiling, none if empty
+	ControlPointsSrid      int             // EPSG srid code of the expected coordinates of the control points
+	ControlPointsTolerance float64         // Max residual in meters allowed for the control points before aborting the job, disabled if 0
+	ExcludeOverlap         bool            // Discards the LAS points flagged as overlap or having the overlap class
+	Returns                ReturnsMode     // Returns of every pulse to load from LAS files
+	ScannerChannel         int             // Scanner channel of the LAS points to load, all channels if negative
+	PruneScreenError       float64         // Max screen-space error in pixels of the viewers, the tiles they never request are merged if positive
+	PruneDistance          float64         // Min distance in meters the tileset is viewed from when pruning tiles
+	Compression            CompressionMode // Compression of the tile content files
+	ZstdDictionary         bool            // Compresses the tile contents of every tileset with a zstd dictionary trained on a sample of them
+	DeduplicateTiles       bool            // Writes byte-identical tile contents once, pointing all their tiles to the same file
+	UriTemplate            string          // Template of the paths of the tile contents relative to the tileset folder, default layout if empty
+	ContentBaseUrl         string          // Base url of the output folder used to reference the tile contents with absolute urls, relative uris if empty
+	MaxDirectoryEntries    int             // Max number of entries of the tileset directories, exceeding entries are moved to shard folders. 0 means no limit
+	Availability           bool            // Writes alongside every tileset a bitmap of the quadtree cells containing points at every level
+	Cancellation           *cancellation.Token `json:"-"` // Cancel request polled by the stages of the job, never cancelled if nil
+	SourceColors           bool            // Colors the points of every input file with a distinct color replacing their own
+	ElevationPipeline      []ElevationStep // Corrections applied in sequence to the heights of the points, replacing ZOffset and EnableGeoidZCorrection if not empty
+	ConverterCacheSize     int             // Max number of coordinate conversions cached by quantized source position, disabled if 0
+	ConverterCacheQuantum  float64         // Quantization step of the source coordinates keying the cached conversions, in units of the input srid
+	RootPercentile         float64         // Percentage of the points discarded at both ends of every axis when computing the root bounds of the grid algorithm, raw bounds if 0
+	LeafPointCap           int             // Max number of points of the grid leaves that reached the min cell size, no limit if 0
+	LeafCapPolicy          LeafCapPolicy   // Strategy applied to the grid leaves exceeding the leaf point cap
+	WithheldPoints         FlaggedPointsMode // Handling of the LAS points flagged as withheld
+	SyntheticPoints        FlaggedPointsMode // Handling of the LAS points flagged as synthetic
+	KeyPoints              FlaggedPointsMode // Handling of the LAS points flagged as model key-points
+	Alpha                  AlphaSource     // Attribute the alpha of the points is derived from, colors are written as RGB if none
+	AlphaMin               float64         // Alpha in the [0, 1] range of the points having the lowest value of the alpha source
+	GhostFilter            bool            // Removes the ghost points mirrored behind reflective surfaces, as recorded by terrestrial scanners
+	GhostVoxelSize         float64         // Size of the voxels the ghost points are detected on, in units of the input srid
+	GhostMaxDepth          float64         // Max distance of the ghost points behind the reflective surfaces, in units of the input srid
+	BatchTable             BatchTableEncoding // Encoding of the batch table properties of the tile contents, binary if not set
+	LegacyTables           bool            // Writes the tables of the pnts contents unpadded rather than aligned to 8-byte boundaries
+	RunMetadata            bool            // Writes the run.json file and embeds the run metadata in the extras of the root tilesets
+	ToolVersion            string          // Version of the tool recorded by the run metadata
+	RunExtras              map[string]interface{} `json:"-"` // Run metadata embedded in the extras of the root tileset, computed while tiling
+	ClassLayers            bool            // Tiles the points of every classification in its own tileset, referenced by an overview tileset
+	InvalidColors          InvalidColorsMode // Handling of the colors of the input files detected as constant or invalid, kept if not set
+	ColorsInvalid          bool            `json:"-"` // True if the colors of the points being tiled have been detected as invalid
+	SidecarFolder          string          // Folder of the CSV tables of supplementary attributes joined to the points of the input files of the same name
+	SidecarKey             SidecarKey      // Key the sidecar records are joined to the points by
+	AttributeNames         []string        `json:"-"` // Names of the supplementary attributes of the points being tiled, written in the batch tables
+	ParquetColumns         string          // Columns of the Parquet inputs the point fields are read from, as field=column pairs
+	ParquetExport          bool            // If true the points of every tileset are also written as a Parquet dataset partitioned by tile
+	Progress               *progress.Reporter `json:"-"` // Receives the phase, points read and tile written events of the job, none are reported if nil
+	WebhookUrl             string          // Url the outcome of the job is posted to when it ends, none is notified if empty
+	WebhookSecret          string          `json:"-"` // Secret the webhook notifications are signed with using HMAC-SHA256, unsigned if empty
+	ReaderPlugins          []ReaderPlugin  // External programs reading the point cloud files of the extensions not supported natively
+	BridgeCommand          []string        // Program and arguments reading every input file, whatever its format, e.g. through PDAL, none if empty
+	SpoolFolder            string          // Folder where the TwoPass algorithm spools the points of the nodes, the system temporary folder if empty
+	Quarantine             bool            // If true the points that cannot be placed on the Earth once transformed are reported rather than tiled
+	ValidityArea           []float64       // Min longitude, min latitude, max longitude and max latitude in degrees of the points to tile, the other ones being quarantined
+	ErrorPolicy            ErrorPolicy     // Whether the recoverable errors abort the run or are logged and skipped
+	ReadChunkSize          int             // Points of LAS and LAZ files read and decoded at a time, all the points of the file if 0
+	License                string          // Name of the license of the tilesets recorded in their root tileset.json, none if empty
+	LicenseUrl             string          // Url of the text of the license recorded along with its name
+	Watermark              string          // Owner id encoded by the sparse watermark points injected in the tilesets, none if empty
+	TilesetVersion         TilesetVersion  // Version of the 3D Tiles specification of the tilesets, deciding the format of the tile contents
+	SizeBudget             int64           // Max estimated size in bytes of the tile contents of every tileset, whose levels are thinned to fit in it, no budget if 0
+	DracoCompression       bool            // If true the points of the pnts contents are compressed with Draco
+	DracoQuantization      map[string]int  // Number of bits the POSITION, COLOR, INTENSITY and supplementary attributes of the Draco compressed contents are quantized to
+	CoarseFirst            bool            // If true the tiles are written level by level from the root, recording the written levels in a manifest
+	BundleThreshold        int64           // Max estimated content size in bytes of the sibling leaf tiles bundled into composite tiles, no bundling if 0
+	BundleMaxSize          int64           // Max estimated content size in bytes of every bundle of leaf tiles
+	ImplicitTiling         bool            // If true the tiles are declared by implicit tiling subtree files rather than by tileset.json files
+	SubtreeLevels          int             // Number of levels of the tree spanned by every subtree file of the implicit tilesets
+	SplitTileSize          int64           // Max estimated content size in bytes of the tiles, the larger ones being split once the tree is built, no splitting if 0
+	Merge                  bool            // If true the points of all the input files are tiled in a single tileset rather than a tileset per file
+	RecoverRecords         bool            // If true the malformed point records of LAS and LAZ files are skipped and counted rather than failing the file
+	ColorSource            ColorSource     // Source of the colors of the points, the colors of the input files, the intensity of the points or the intensity if the files store no colors
+	ColorRamp              string          // Comma separated hex colors the intensity is mapped to if the points are colored by intensity, grayscale if empty
+	LasAttributes          []string        // Names of the LAS point attributes written in the batch tables before the sidecar attributes
+	Resume                 bool            // If true the input files completed by an interrupted run with the same options are skipped, as recorded in its checkpoint
+	ZonalStats             string          // GeoJSON file of the zones whose point statistics are written alongside every tileset, none if empty
+	CrsDefinition          string          // Definition of the coordinate reference system of the input points replacing the one of the Srid code, none if empty
+	CrsBackend             string          // Name of the coordinate converter backend, the bundled proj4 one if empty
+	GeoidModel             string          // Geoid model of the geoid corrections, a named model or the path of a geoid grid, the bundled EGM84 model if empty
+	DensityTarget          float64         // Max density of the points tiled by the grid algorithm, in points per square or cubic meter according to DensityMode, no cap if 0
+	DensityMode            DensityMode     // Whether the density target counts the points per square meter of ground or per cubic meter
+	DensityCellSize        float64         // Size in meters of the cells the density target is enforced in, holding the target density times their area or volume points each
+	GridSpool              bool            // If true the Grid algorithm reads the files twice and spools the points of its nodes to disk in SpoolFolder rather than holding them in memory
+	GridSampling           GridSampling    // Point kept by every cell of the Grid algorithm, the one closest to its center if not set
+	ScanIds                bool            // If true the merged input files are registered scans, whose id is written in the batch tables and listed in the extras of the root tileset
+	ScanPoses              string          // CSV file of the registration poses of the scans listed in the extras of the root tileset, none if empty
+	WriterConcurrency      int             // Goroutines writing the tile files serialized by the write workers, which write them themselves if 0
+	IntensityStretch       string          // Low and high percentiles of the intensities stretched over the color ramp when the points are colored automatically by intensity, e.g. 2,98
+	ScanExtras             []map[string]interface{} `json:"-"` // Scans embedded in the extras of the root tileset, computed while tiling
+	IntensityRange         [2]uint8        `json:"-"` // Intensities at the stretch percentiles of the points colored automatically by intensity, computed while tiling
+	GridSamplingAttribute  string          // Attribute maximized by the MAX grid sampling, the intensity or one of LasAttributes, the intensity if empty
+	SamplingStrategy       SamplingStrategy `json:"-"` // Strategy deciding the point kept by the grid cells replacing the one of GridSampling, e.g. provided by library users, none if nil
+	TreeDump               TreeDumpFormat  // Format of the dump of the structure of every built tree written alongside its tileset, none if NONE or empty
+	Wireframe              bool            // Writes the companion tileset drawing the bounding boxes of the nodes of every tree level by level
+}
+
+// Returns the paths of the input option, i.e. the files separated by the OS path list separator of the input files
+// given explicitly to be merged, or the only input file or folder otherwise
+func GetInputPaths(opts *TilerOptions) []string {
+	if opts.Merge && !opts.FolderProcessing {
+		return filepath.SplitList(opts.Input)
+	}
+	return []string{opts.Input}
+}
+
+// Returns the custom coordinate reference system definitions of the options keyed by srid, i.e. the definition of the
+// input srid if given
+func GetCrsDefinitions(opts *TilerOptions) map[int]string {
+	definitions := make(map[int]string)
+	if opts.CrsDefinition != "" {
+		definitions[opts.Srid] = opts.CrsDefinition
+	}
+	return definitions
+}
+
+// Returns true if the heights of the points are corrected by the geoid model, either enabled by itself or as a step
+// of the elevation pipeline
+func IsGeoidCorrected(opts *TilerOptions) bool {
+	if len(opts.ElevationPipeline) == 0 {
+		return opts.EnableGeoidZCorrection
+	}
+	for _, step := range opts.ElevationPipeline {
+		if step.Kind == ElevationStepGeoid {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the given number of workers if positive, otherwise the number of CPUs
+func WorkersOrNumCPU(workers int) int {
+	if workers > 0 {
+		return workers
+	}
+	return runtime.NumCPU()
 }
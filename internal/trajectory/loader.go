@@ -0,0 +1,32 @@
+package trajectory
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// Loads the trajectory stored in the given file, choosing the format according to its extension: .csv and .txt files
+// are read as pose CSV files, .out and .sbet files as Applanix SBET files
+func LoadTrajectory(filePath string) (*Trajectory, error) {
+	var trajectory *Trajectory
+	var err error
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv", ".txt":
+		trajectory, err = LoadPoseCsv(filePath)
+	case ".out", ".sbet":
+		trajectory, err = LoadSbet(filePath)
+	default:
+		return nil, errors.New("unsupported trajectory file format: " + filePath)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if trajectory.Len() == 0 {
+		return nil, errors.New("no poses found in trajectory file " + filePath)
+	}
+
+	return trajectory, nil
+}
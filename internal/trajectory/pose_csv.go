@@ -0,0 +1,29 @@
+package trajectory
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+)
+
+// Number of columns of a pose CSV record: time, x, y, z, qx, qy, qz, qw
+const poseCsvColumns = 8
+
+// Loads a trajectory from a comma separated file whose records hold the time, the position and the orientation, as a
+// unit quaternion, of the sensor: time,x,y,z,qx,qy,qz,qw. Positions are expressed in the srid of the input points.
+func LoadPoseCsv(filePath string) (*Trajectory, error) {
+	records, err := tools.ReadCsvFloatRecords(filePath, "pose", poseCsvColumns, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	poses := make([]*Pose, 0, len(records))
+	for _, record := range records {
+		values := record.Values
+		poses = append(poses, &Pose{
+			Time:        values[0],
+			Translation: [3]float64{values[1], values[2], values[3]},
+			Rotation:    [4]float64{values[4], values[5], values[6], values[7]},
+		})
+	}
+
+	return NewTrajectory(poses, 0), nil
+}
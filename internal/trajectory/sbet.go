@@ -0,0 +1,80 @@
+package trajectory
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+)
+
+// Number of float64 values of each SBET record: time, latitude, longitude, altitude, velocities, roll, pitch,
+// heading, wander angle, accelerations and angular rates
+const sbetRecordValues = 17
+
+// WGS84 ellipsoid parameters
+const semiMajorAxis = 6378137.0
+const eccentricitySquared = 6.69437999014e-3
+
+// Loads a trajectory from an Applanix Smoothed Best Estimate of Trajectory (SBET) binary file. Poses are converted to
+// transformations from the sensor body frame (x forward, y right, z down) to ECEF coordinates and transformed points
+// are returned as EPSG:4326 coordinates. Headings are assumed to be relative to the true north and lever arms and
+// boresight angles are not taken into account.
+func LoadSbet(filePath string) (*Trajectory, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+	var poses []*Pose
+	var record [sbetRecordValues]float64
+	for {
+		err := binary.Read(reader, binary.LittleEndian, &record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		poses = append(poses, sbetRecordToPose(record))
+	}
+
+	return NewEcefTrajectory(poses), nil
+}
+
+// Converts a SBET record, whose angles are expressed in radians, in the corresponding ECEF pose
+func sbetRecordToPose(record [sbetRecordValues]float64) *Pose {
+	time, lat, lon, alt := record[0], record[1], record[2], record[3]
+	roll, pitch, heading := record[7], record[8], record[9]
+
+	sinLat, cosLat := math.Sin(lat), math.Cos(lat)
+	sinLon, cosLon := math.Sin(lon), math.Cos(lon)
+	n := semiMajorAxis / math.Sqrt(1-eccentricitySquared*sinLat*sinLat)
+
+	// the columns of the matrix are the north, east and down axes expressed in ECEF
+	nedToEcef := fromRotationMatrix([3][3]float64{
+		{-sinLat * cosLon, -sinLon, -cosLat * cosLon},
+		{-sinLat * sinLon, cosLon, -cosLat * sinLon},
+		{cosLat, 0, -sinLat},
+	})
+	bodyToNed := multiply(axisRotation(2, heading), multiply(axisRotation(1, pitch), axisRotation(0, roll)))
+
+	return &Pose{
+		Time: time,
+		Translation: [3]float64{
+			(n + alt) * cosLat * cosLon,
+			(n + alt) * cosLat * sinLon,
+			(n*(1-eccentricitySquared) + alt) * sinLat,
+		},
+		Rotation: multiply(nedToEcef, bodyToNed),
+	}
+}
+
+// Returns the quaternion of the rotation of the given angle around the given axis (0 for x, 1 for y, 2 for z)
+func axisRotation(axis int, angle float64) [4]float64 {
+	q := [4]float64{0, 0, 0, math.Cos(angle / 2)}
+	q[axis] = math.Sin(angle / 2)
+	return q
+}
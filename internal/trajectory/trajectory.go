@@ -0,0 +1,154 @@
+package trajectory
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"sort"
+)
+
+// A rigid transformation recorded at a given time, made of a translation and a unit quaternion rotation (x, y, z, w)
+type Pose struct {
+	Time        float64
+	Translation [3]float64
+	Rotation    [4]float64
+}
+
+// Applies the pose to the given point rotating and then translating it
+func (p *Pose) Apply(x, y, z float64) (float64, float64, float64) {
+	qx, qy, qz, qw := p.Rotation[0], p.Rotation[1], p.Rotation[2], p.Rotation[3]
+
+	// v' = v + w*t + q x t, with t = 2 * q x v
+	tx := 2 * (qy*z - qz*y)
+	ty := 2 * (qz*x - qx*z)
+	tz := 2 * (qx*y - qy*x)
+
+	return x + qw*tx + (qy*tz - qz*ty) + p.Translation[0],
+		y + qw*ty + (qz*tx - qx*tz) + p.Translation[1],
+		z + qw*tz + (qx*ty - qy*tx) + p.Translation[2]
+}
+
+// Time ordered list of poses that can be interpolated at arbitrary times. It implements the readers.PointTransformer
+// interface moving points from the sensor frame to the frame the poses are expressed in.
+type Trajectory struct {
+	poses []*Pose
+	srid  int
+	// if true the poses move points to ECEF coordinates, which are then converted to EPSG:4326 ones
+	ecef bool
+}
+
+// Builds a new trajectory from the given poses. The srid is the one of the frame the poses are expressed in, if 0
+// the poses are assumed to be expressed in the same srid of the points they are applied to.
+func NewTrajectory(poses []*Pose, srid int) *Trajectory {
+	sort.Slice(poses, func(i, j int) bool { return poses[i].Time < poses[j].Time })
+	return &Trajectory{poses: poses, srid: srid}
+}
+
+// Builds a new trajectory from poses moving points to ECEF coordinates. Transformed points are returned as
+// EPSG:4326 coordinates, height being relative to the WGS84 ellipsoid.
+func NewEcefTrajectory(poses []*Pose) *Trajectory {
+	trajectory := NewTrajectory(poses, 4326)
+	trajectory.ecef = true
+	return trajectory
+}
+
+// Returns the number of poses in the trajectory
+func (t *Trajectory) Len() int {
+	return len(t.poses)
+}
+
+// Returns the pose at the given time, linearly interpolating the translation and spherically interpolating the
+// rotation between the closest recorded poses. Times outside of the trajectory are clamped to its ends.
+func (t *Trajectory) PoseAt(time float64) *Pose {
+	n := len(t.poses)
+	i := sort.Search(n, func(i int) bool { return t.poses[i].Time >= time })
+	if i == 0 {
+		return t.poses[0]
+	}
+	if i == n {
+		return t.poses[n-1]
+	}
+
+	before, after := t.poses[i-1], t.poses[i]
+	if after.Time == before.Time {
+		return after
+	}
+	ratio := (time - before.Time) / (after.Time - before.Time)
+
+	interpolated := &Pose{Time: time, Rotation: slerp(before.Rotation, after.Rotation, ratio)}
+	for k := 0; k < 3; k++ {
+		interpolated.Translation[k] = before.Translation[k] + (after.Translation[k]-before.Translation[k])*ratio
+	}
+	return interpolated
+}
+
+// Moves the given coordinate using the pose interpolated at the given time
+func (t *Trajectory) Transform(coordinate *geometry.Coordinate, time float64, srid int) (*geometry.Coordinate, int) {
+	x, y, z := t.PoseAt(time).Apply(coordinate.X, coordinate.Y, coordinate.Z)
+	if t.ecef {
+		x, y, z = geometry.EcefToGeographic(x, y, z)
+	}
+	if t.srid != 0 {
+		srid = t.srid
+	}
+
+	return &geometry.Coordinate{X: x, Y: y, Z: z}, srid
+}
+
+// Spherical linear interpolation between two unit quaternions
+func slerp(a [4]float64, b [4]float64, ratio float64) [4]float64 {
+	dot := a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]
+
+	// take the shortest path
+	if dot < 0 {
+		dot = -dot
+		b = [4]float64{-b[0], -b[1], -b[2], -b[3]}
+	}
+
+	wa, wb := 1-ratio, ratio
+	if dot < 0.9995 {
+		theta := math.Acos(dot)
+		wa = math.Sin((1-ratio)*theta) / math.Sin(theta)
+		wb = math.Sin(ratio*theta) / math.Sin(theta)
+	}
+
+	var out [4]float64
+	norm := 0.0
+	for k := 0; k < 4; k++ {
+		out[k] = wa*a[k] + wb*b[k]
+		norm += out[k] * out[k]
+	}
+	norm = math.Sqrt(norm)
+	for k := 0; k < 4; k++ {
+		out[k] /= norm
+	}
+	return out
+}
+
+// Hamilton product of two quaternions in (x, y, z, w) order
+func multiply(a [4]float64, b [4]float64) [4]float64 {
+	return [4]float64{
+		a[3]*b[0] + a[0]*b[3] + a[1]*b[2] - a[2]*b[1],
+		a[3]*b[1] - a[0]*b[2] + a[1]*b[3] + a[2]*b[0],
+		a[3]*b[2] + a[0]*b[1] - a[1]*b[0] + a[2]*b[3],
+		a[3]*b[3] - a[0]*b[0] - a[1]*b[1] - a[2]*b[2],
+	}
+}
+
+// Converts a rotation matrix, given by rows, to a unit quaternion in (x, y, z, w) order
+func fromRotationMatrix(m [3][3]float64) [4]float64 {
+	trace := m[0][0] + m[1][1] + m[2][2]
+	switch {
+	case trace > 0:
+		s := 2 * math.Sqrt(trace+1)
+		return [4]float64{(m[2][1] - m[1][2]) / s, (m[0][2] - m[2][0]) / s, (m[1][0] - m[0][1]) / s, s / 4}
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[0][0]-m[1][1]-m[2][2])
+		return [4]float64{s / 4, (m[0][1] + m[1][0]) / s, (m[0][2] + m[2][0]) / s, (m[2][1] - m[1][2]) / s}
+	case m[1][1] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[1][1]-m[0][0]-m[2][2])
+		return [4]float64{(m[0][1] + m[1][0]) / s, s / 4, (m[1][2] + m[2][1]) / s, (m[0][2] - m[2][0]) / s}
+	default:
+		s := 2 * math.Sqrt(1+m[2][2]-m[0][0]-m[1][1])
+		return [4]float64{(m[0][2] + m[2][0]) / s, (m[1][2] + m[2][1]) / s, s / 4, (m[1][0] - m[0][1]) / s}
+	}
+}
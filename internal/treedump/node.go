@@ -0,0 +1,113 @@
+package treedump
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+)
+
+// Structure of a node of a dumped tree. Reloaded nodes implement octree.INode without points, so that the tools
+// walking the nodes of a tree, e.g. to compute its coverage or its availability, can run on a dump.
+type Node struct {
+	// index of the node among the children of its parent, 0 for the root
+	Octant int    `json:"octant"`
+	Kind   string `json:"kind,omitempty"`
+	// Xmin, Xmax, Ymin, Ymax, Zmin, Zmax in the srid of the tree
+	Box            [6]float64 `json:"box"`
+	Points         int64      `json:"points"`
+	TotalPoints    int64      `json:"totalPoints"`
+	GeometricError float64    `json:"geometricError"`
+	// size and number of the cells of the grid nodes, 0 for the other ones
+	CellSize float64 `json:"cellSize,omitempty"`
+	Cells    int     `json:"cells,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+	parent   *Node
+	srid     int
+}
+
+// Links the given node and its descendants to their parent, validating their octants, returning the number of nodes
+func (n *Node) link(parent *Node, srid int) (int, error) {
+	n.parent, n.srid = parent, srid
+	nodes := 1
+	var octants [8]bool
+	for _, child := range n.Children {
+		if child == nil || child.Octant < 0 || child.Octant > 7 || octants[child.Octant] {
+			return 0, errors.New("invalid children octants in the tree dump")
+		}
+		octants[child.Octant] = true
+		descendants, err := child.link(n, srid)
+		if err != nil {
+			return 0, err
+		}
+		nodes += descendants
+	}
+	return nodes, nil
+}
+
+// The points are not dumped, hence the points added to a reloaded node are ignored
+func (n *Node) AddDataPoint(element *data.Point) {}
+
+func (n *Node) GetInternalSrid() int {
+	return n.srid
+}
+
+func (n *Node) IsRoot() bool {
+	return n.parent == nil
+}
+
+func (n *Node) GetBoundingBoxRegion(converter converters.CoordinateConverter) (*geometry.BoundingBox, error) {
+	return converter.Convert2DBoundingboxToWGS84Region(n.GetBoundingBox(), n.srid)
+}
+
+func (n *Node) GetChildren() [8]octree.INode {
+	var children [8]octree.INode
+	for _, child := range n.Children {
+		children[child.Octant] = child
+	}
+	return children
+}
+
+func (n *Node) GetPoints() []*data.Point {
+	return nil
+}
+
+func (n *Node) TotalNumberOfPoints() int64 {
+	return n.TotalPoints
+}
+
+func (n *Node) NumberOfPoints() int64 {
+	return n.Points
+}
+
+func (n *Node) IsLeaf() bool {
+	return len(n.Children) == 0
+}
+
+func (n *Node) IsInitialized() bool {
+	return true
+}
+
+func (n *Node) ComputeGeometricError() float64 {
+	return n.GeometricError
+}
+
+func (n *Node) GetParent() octree.INode {
+	if n.parent == nil {
+		return nil
+	}
+	return n.parent
+}
+
+func (n *Node) GetBoundingBox() *geometry.BoundingBox {
+	return geometry.NewBoundingBox(n.Box[0], n.Box[1], n.Box[2], n.Box[3], n.Box[4], n.Box[5])
+}
+
+func (n *Node) GetCellSize() float64 {
+	return n.CellSize
+}
+
+func (n *Node) NumberOfCells() int {
+	return n.Cells
+}
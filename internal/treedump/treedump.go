@@ -0,0 +1,251 @@
+package treedump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Name of the JSON dump file written in the tileset folder
+const JsonFileName = "tree.json"
+
+// Name of the binary dump file written in the tileset folder
+const BinaryFileName = "tree.bin"
+
+// Magic number opening the binary dump files
+var magic = []byte("GCTD")
+
+// Version of the dump formats
+const version = 1
+
+// Kinds of the nodes other than the plain ones, which have an empty kind
+const (
+	KindOverflow = "overflow"
+	KindBundle   = "bundle"
+)
+
+// Codes of the kinds of the nodes in the binary dumps
+var kindCodes = map[string]byte{"": 0, KindOverflow: 1, KindBundle: 2}
+
+// Structure of a built tree, i.e. the bounds and the number of points of its nodes without the points themselves, to
+// be analyzed offline or reloaded as a tree of nodes without points
+type Dump struct {
+	Version int   `json:"version"`
+	Srid    int   `json:"srid"`
+	Nodes   int   `json:"nodes"`
+	Root    *Node `json:"root"`
+}
+
+// Statistics of a level of a dumped tree
+type Level struct {
+	Depth       int
+	Nodes       int
+	Leaves      int
+	Points      int64
+	Cells       int64
+	MaxCellSize float64
+	// largest geometric error of the nodes of the level
+	GeometricError float64
+}
+
+// Captures the structure of the given built tree
+func Capture(root octree.INode) *Dump {
+	dump := &Dump{Version: version, Srid: root.GetInternalSrid()}
+	var capture func(node octree.INode, octant int, parent *Node) *Node
+	capture = func(node octree.INode, octant int, parent *Node) *Node {
+		box := node.GetBoundingBox()
+		captured := &Node{
+			Octant:         octant,
+			Box:            [6]float64{box.Xmin, box.Xmax, box.Ymin, box.Ymax, box.Zmin, box.Zmax},
+			Points:         node.NumberOfPoints(),
+			TotalPoints:    node.TotalNumberOfPoints(),
+			GeometricError: node.ComputeGeometricError(),
+			parent:         parent,
+			srid:           dump.Srid,
+		}
+		switch typed := node.(type) {
+		case octree.OverflowNode:
+			captured.Kind = KindOverflow
+		case octree.BundleNode:
+			captured.Kind = KindBundle
+		case octree.CellNode:
+			captured.CellSize, captured.Cells = typed.GetCellSize(), typed.NumberOfCells()
+		}
+		dump.Nodes++
+		for childOctant, child := range node.GetChildren() {
+			if child != nil {
+				captured.Children = append(captured.Children, capture(child, childOctant, captured))
+			}
+		}
+		return captured
+	}
+	dump.Root = capture(root, 0, nil)
+	return dump
+}
+
+// Returns the statistics of every level of the dumped tree, from the root one
+func (d *Dump) Levels() []Level {
+	var levels []Level
+	for depth, level := 0, []*Node{d.Root}; len(level) > 0; depth++ {
+		stats := Level{Depth: depth, Nodes: len(level)}
+		var next []*Node
+		for _, node := range level {
+			if len(node.Children) == 0 {
+				stats.Leaves++
+			}
+			stats.Points += node.Points
+			stats.Cells += int64(node.Cells)
+			stats.MaxCellSize = math.Max(stats.MaxCellSize, node.CellSize)
+			stats.GeometricError = math.Max(stats.GeometricError, node.GeometricError)
+			next = append(next, node.Children...)
+		}
+		levels = append(levels, stats)
+		level = next
+	}
+	return levels
+}
+
+// Encodes the dump as JSON, the children of the nodes being listed in octant order
+func (d *Dump) ToJson() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+// Encodes the dump in its compact binary form: a header with the magic number, the format version, the srid and the
+// number of nodes, little endian, followed by the nodes in depth first order, each one made of its kind, the mask of
+// its children octants, its bounds, its own and total number of points, its geometric error, the size of its cells and
+// its number of cells
+func (d *Dump) ToBytes() []byte {
+	buffer := bytes.NewBuffer(nil)
+	buffer.Write(magic)
+	buffer.WriteByte(version)
+	_ = binary.Write(buffer, binary.LittleEndian, int32(d.Srid))
+	_ = binary.Write(buffer, binary.LittleEndian, uint32(d.Nodes))
+
+	var write func(node *Node)
+	write = func(node *Node) {
+		var mask byte
+		for _, child := range node.Children {
+			mask |= 1 << uint(child.Octant)
+		}
+		buffer.WriteByte(kindCodes[node.Kind])
+		buffer.WriteByte(mask)
+		_ = binary.Write(buffer, binary.LittleEndian, node.Box)
+		_ = binary.Write(buffer, binary.LittleEndian, []int64{node.Points, node.TotalPoints})
+		_ = binary.Write(buffer, binary.LittleEndian, []float64{node.GeometricError, node.CellSize})
+		_ = binary.Write(buffer, binary.LittleEndian, uint32(node.Cells))
+		for _, child := range node.Children {
+			write(child)
+		}
+	}
+	write(d.Root)
+	return buffer.Bytes()
+}
+
+// Decodes a dump encoded by ToBytes or ToJson, telling them apart by the magic number of the binary form
+func Parse(data []byte) (*Dump, error) {
+	var dump *Dump
+	var err error
+	if bytes.HasPrefix(data, magic) {
+		dump, err = parseBytes(data)
+	} else {
+		dump = &Dump{}
+		if jsonErr := json.Unmarshal(data, dump); jsonErr != nil {
+			err = errors.New("not a tree dump: " + jsonErr.Error())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dump.Version != version {
+		return nil, errors.New("unsupported tree dump version " + strconv.Itoa(dump.Version))
+	}
+	if dump.Root == nil {
+		return nil, errors.New("the tree dump has no root node")
+	}
+	nodes, err := dump.Root.link(nil, dump.Srid)
+	if err != nil {
+		return nil, err
+	}
+	if nodes != dump.Nodes {
+		return nil, errors.New("the tree dump declares " + strconv.Itoa(dump.Nodes) + " nodes but holds " + strconv.Itoa(nodes))
+	}
+	return dump, nil
+}
+
+func parseBytes(data []byte) (*Dump, error) {
+	reader := bytes.NewReader(data[len(magic):])
+	var header struct {
+		Version byte
+		Srid    int32
+		Nodes   uint32
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, errors.New("truncated tree dump")
+	}
+	dump := &Dump{Version: int(header.Version), Srid: int(header.Srid), Nodes: int(header.Nodes)}
+	if dump.Version != version {
+		return dump, nil
+	}
+
+	codes := make(map[byte]string)
+	for kind, code := range kindCodes {
+		codes[code] = kind
+	}
+	var read func(octant int) (*Node, error)
+	read = func(octant int) (*Node, error) {
+		var record struct {
+			Kind           byte
+			Mask           byte
+			Box            [6]float64
+			Points         int64
+			TotalPoints    int64
+			GeometricError float64
+			CellSize       float64
+			Cells          uint32
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &record); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, errors.New("truncated tree dump")
+			}
+			return nil, err
+		}
+		kind, ok := codes[record.Kind]
+		if !ok {
+			return nil, errors.New("unknown node kind " + strconv.Itoa(int(record.Kind)) + " in the tree dump")
+		}
+		node := &Node{
+			Octant:         octant,
+			Kind:           kind,
+			Box:            record.Box,
+			Points:         record.Points,
+			TotalPoints:    record.TotalPoints,
+			GeometricError: record.GeometricError,
+			CellSize:       record.CellSize,
+			Cells:          int(record.Cells),
+		}
+		for childOctant := 0; childOctant < 8; childOctant++ {
+			if record.Mask&(1<<uint(childOctant)) != 0 {
+				child, err := read(childOctant)
+				if err != nil {
+					return nil, err
+				}
+				node.Children = append(node.Children, child)
+			}
+		}
+		return node, nil
+	}
+	root, err := read(0)
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() > 0 {
+		return nil, errors.New("trailing data after the nodes of the tree dump")
+	}
+	dump.Root = root
+	return dump, nil
+}
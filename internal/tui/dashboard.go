@@ -0,0 +1,259 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/stats"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Size in characters of the density map
+const (
+	mapColumns = 60
+	mapRows    = 20
+)
+
+// Interval between two redraws of the dashboard
+const refreshInterval = 500 * time.Millisecond
+
+// ANSI sequence moving the cursor to the top left corner and clearing the screen
+const clearScreen = "\033[H\033[2J"
+
+// States of the job controlled from the dashboard
+const (
+	stateRunning int32 = iota
+	statePaused
+	stateAborted
+)
+
+// Terminal dashboard showing the live progress of a tiling job. Commands are read line by line from the input:
+// "p" pauses or resumes the loading of the points, "q" aborts the job once the file being processed is exported.
+type Dashboard struct {
+	out        io.Writer
+	in         io.Reader
+	start      time.Time
+	fileStart  time.Time
+	file       string
+	fileIndex  int
+	fileCount  int
+	message    string
+	density    *densityMap
+	levels     []stats.LevelStats
+	pointsRead int64
+	state      int32
+	resumed    *sync.Cond
+	stop       chan struct{}
+	stopped    sync.WaitGroup
+	sync.Mutex
+}
+
+func NewDashboard(out io.Writer, in io.Reader) *Dashboard {
+	dashboard := &Dashboard{
+		out:  out,
+		in:   in,
+		stop: make(chan struct{}),
+	}
+	dashboard.resumed = sync.NewCond(&dashboard.Mutex)
+	return dashboard
+}
+
+// Starts redrawing the dashboard periodically and listening for commands
+func (d *Dashboard) Start() {
+	d.start = time.Now()
+	d.stopped.Add(1)
+	go d.refresh()
+	go d.listen()
+}
+
+// Stops the periodic redraw, drawing the dashboard a last time
+func (d *Dashboard) Stop() {
+	close(d.stop)
+	d.stopped.Wait()
+	d.Draw()
+}
+
+// Registers the beginning of the processing of a new file. If the bounds of the file are known, the density map of
+// the loaded points is drawn
+func (d *Dashboard) StartFile(file string, index int, count int, bounds *geometry.BoundingBox) {
+	d.Lock()
+	defer d.Unlock()
+	d.file = file
+	d.fileIndex = index
+	d.fileCount = count
+	d.fileStart = time.Now()
+	d.levels = nil
+	atomic.StoreInt64(&d.pointsRead, 0)
+	d.density = nil
+	if bounds != nil {
+		d.density = newDensityMap(bounds, mapColumns, mapRows)
+	}
+}
+
+// Wraps the given tree so that the points added to it are reported to the dashboard
+func (d *Dashboard) Track(tree octree.ITree) octree.ITree {
+	return &trackingTree{ITree: tree, dashboard: d}
+}
+
+// Records the node counts of every level of the given built tree
+func (d *Dashboard) SetTree(tree octree.ITree) {
+	root := tree.GetRootNode()
+	if root == nil {
+		return
+	}
+	levels := stats.ComputeLevelStats(root)
+
+	d.Lock()
+	d.levels = levels
+	d.Unlock()
+}
+
+// Records the given message as the current status of the job
+func (d *Dashboard) Log(message string) {
+	d.Lock()
+	d.message = message
+	d.Unlock()
+}
+
+// Pauses the loading of the points if running, resumes it if paused
+func (d *Dashboard) TogglePause() {
+	d.Lock()
+	defer d.Unlock()
+	switch d.state {
+	case stateRunning:
+		atomic.StoreInt32(&d.state, statePaused)
+	case statePaused:
+		atomic.StoreInt32(&d.state, stateRunning)
+		d.resumed.Broadcast()
+	}
+}
+
+// Aborts the job: points are no longer loaded and the remaining files should be skipped
+func (d *Dashboard) Abort() {
+	d.Lock()
+	defer d.Unlock()
+	atomic.StoreInt32(&d.state, stateAborted)
+	d.resumed.Broadcast()
+}
+
+// Returns true if the job has been aborted
+func (d *Dashboard) IsAborted() bool {
+	return atomic.LoadInt32(&d.state) == stateAborted
+}
+
+// Returns the number of points loaded from the current file
+func (d *Dashboard) PointsRead() int64 {
+	return atomic.LoadInt64(&d.pointsRead)
+}
+
+// Draws the dashboard on the output
+func (d *Dashboard) Draw() {
+	_, _ = io.WriteString(d.out, clearScreen+strings.Join(d.render(), "\n")+"\n")
+}
+
+// Blocks while the dashboard is paused, returning false if the job has been aborted
+func (d *Dashboard) waitIfPaused() bool {
+	// avoid locking on every point while running
+	if atomic.LoadInt32(&d.state) == stateRunning {
+		return true
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	for d.state == statePaused {
+		d.resumed.Wait()
+	}
+	return d.state != stateAborted
+}
+
+// Counts a loaded point. The density map is only replaced by StartFile, before the points of the file are loaded
+func (d *Dashboard) countPoint(coordinate *geometry.Coordinate) {
+	atomic.AddInt64(&d.pointsRead, 1)
+	if d.density != nil {
+		d.density.add(coordinate.X, coordinate.Y)
+	}
+}
+
+func (d *Dashboard) refresh() {
+	defer d.stopped.Done()
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.Draw()
+		}
+	}
+}
+
+// Reads the commands from the input until it is closed
+func (d *Dashboard) listen() {
+	scanner := bufio.NewScanner(d.in)
+	for scanner.Scan() {
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "p":
+			d.TogglePause()
+		case "q":
+			d.Abort()
+		}
+	}
+}
+
+func (d *Dashboard) render() []string {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	pointsRead := d.PointsRead()
+
+	d.Lock()
+	defer d.Unlock()
+
+	state := "running"
+	switch d.state {
+	case statePaused:
+		state = "paused"
+	case stateAborted:
+		state = "aborting"
+	}
+
+	pointsPerSecond := 0.0
+	if elapsed := time.Since(d.fileStart).Seconds(); elapsed > 0 && !d.fileStart.IsZero() {
+		pointsPerSecond = float64(pointsRead) / elapsed
+	}
+
+	lines := []string{
+		"gocesiumtiler - " + state + " - elapsed " + time.Since(d.start).Truncate(time.Second).String(),
+		fmt.Sprintf("file %d/%d: %s", d.fileIndex, d.fileCount, d.file),
+		fmt.Sprintf("points loaded: %d (%.0f points/s)", pointsRead, pointsPerSecond),
+		"memory: " + tiler.FormatByteSize(int64(memStats.Sys)),
+		"status: " + d.message,
+		"",
+	}
+
+	if len(d.levels) > 0 {
+		lines = append(lines, "depth      nodes     points")
+		for _, level := range d.levels {
+			lines = append(lines, fmt.Sprintf("%5d %10d %10d", level.Depth, level.Nodes, level.RetainedPoints))
+		}
+		lines = append(lines, "")
+	}
+
+	if d.density != nil {
+		border := "+" + strings.Repeat("-", mapColumns) + "+"
+		lines = append(lines, border)
+		for _, row := range d.density.render() {
+			lines = append(lines, "|"+row+"|")
+		}
+		lines = append(lines, border, "")
+	}
+
+	return append(lines, "commands: p + enter to pause/resume, q + enter to abort")
+}
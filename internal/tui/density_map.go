@@ -0,0 +1,88 @@
+package tui
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Characters used to draw the density map cells, from the emptiest to the densest
+const densityRamp = " .:-=+*#%@"
+
+// Coarse 2D histogram of the points falling in a bounding box, rendered as ASCII art
+type densityMap struct {
+	bounds *geometry.BoundingBox
+	cols   int
+	rows   int
+	counts []int64
+	sync.Mutex
+}
+
+func newDensityMap(bounds *geometry.BoundingBox, cols int, rows int) *densityMap {
+	return &densityMap{
+		bounds: bounds,
+		cols:   cols,
+		rows:   rows,
+		counts: make([]int64, cols*rows),
+	}
+}
+
+// Counts the given point in its cell, points outside of the bounds are ignored
+func (m *densityMap) add(x float64, y float64) {
+	width, height := m.bounds.Xmax-m.bounds.Xmin, m.bounds.Ymax-m.bounds.Ymin
+	if width <= 0 || height <= 0 {
+		return
+	}
+	col := int((x - m.bounds.Xmin) / width * float64(m.cols))
+	row := int((m.bounds.Ymax - y) / height * float64(m.rows))
+	// points lying on the max bounds belong to the last cells
+	if col == m.cols {
+		col--
+	}
+	if row == m.rows {
+		row--
+	}
+	if col < 0 || col >= m.cols || row < 0 || row >= m.rows {
+		return
+	}
+
+	m.Lock()
+	m.counts[row*m.cols+col]++
+	m.Unlock()
+}
+
+// Renders the map, north up, scaling the cell densities logarithmically to the characters of the ramp
+func (m *densityMap) render() []string {
+	m.Lock()
+	defer m.Unlock()
+
+	var max int64
+	for _, count := range m.counts {
+		if count > max {
+			max = count
+		}
+	}
+
+	lines := make([]string, m.rows)
+	for row := 0; row < m.rows; row++ {
+		var sb strings.Builder
+		for col := 0; col < m.cols; col++ {
+			sb.WriteByte(densityRamp[getRampIndex(m.counts[row*m.cols+col], max)])
+		}
+		lines[row] = sb.String()
+	}
+
+	return lines
+}
+
+func getRampIndex(count int64, max int64) int {
+	if count == 0 || max == 0 {
+		return 0
+	}
+	if max == 1 {
+		return len(densityRamp) - 1
+	}
+	ratio := math.Log(float64(count)) / math.Log(float64(max))
+	return 1 + int(math.Round(ratio*float64(len(densityRamp)-2)))
+}
@@ -0,0 +1,21 @@
+package tui
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+)
+
+// Decorates a tree reporting the added points to the dashboard, blocking while the dashboard is paused and
+// discarding the points once it has been aborted
+type trackingTree struct {
+	octree.ITree
+	dashboard *Dashboard
+}
+
+func (t *trackingTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	if !t.dashboard.waitIfPaused() {
+		return
+	}
+	t.dashboard.countPoint(coordinate)
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+}
@@ -0,0 +1,220 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Url of the GitHub API returning the latest release of the tool
+const DefaultReleasesUrl = "https://api.github.com/repos/mfbonfigli/gocesiumtiler/releases/latest"
+
+// Extension of the release assets holding the base64 encoded Ed25519 signature of the binary of the same name
+const SignatureExtension = ".sig"
+
+// Base64 encoded Ed25519 public key verifying the signatures of the release binaries, set when building the releases
+// with -ldflags "-X github.com/mfbonfigli/gocesiumtiler/internal/update.PublicKey=<key>". The builds without a key
+// cannot update themselves.
+var PublicKey = ""
+
+// Largest binary downloaded, to not exhaust the memory if the server misbehaves
+const maxBinarySize = 512 << 20
+
+// A release of the tool, with the download urls of its assets by name
+type Release struct {
+	Version string
+	Assets  map[string]string
+}
+
+// Returns the name of the release binary built for the given OS and architecture, e.g. gocesiumtiler-linux-amd64 or
+// gocesiumtiler-windows-amd64.exe
+func GetAssetName(goos string, goarch string) string {
+	name := "gocesiumtiler-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Returns true if the given version, e.g. v1.3.0, is newer than the current one. The versions are compared by their
+// dot separated numbers, the missing ones being 0, and the versions that cannot be parsed are never newer.
+func IsNewer(version string, current string) bool {
+	parse := func(version string) ([]int, bool) {
+		var numbers []int
+		for _, token := range strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".") {
+			number, err := strconv.Atoi(token)
+			if err != nil || number < 0 {
+				return nil, false
+			}
+			numbers = append(numbers, number)
+		}
+		return numbers, true
+	}
+	newer, ok := parse(version)
+	old, okCurrent := parse(current)
+	if !ok || !okCurrent {
+		return false
+	}
+	for i := 0; i < len(newer) || i < len(old); i++ {
+		var n, o int
+		if i < len(newer) {
+			n = newer[i]
+		}
+		if i < len(old) {
+			o = old[i]
+		}
+		if n != o {
+			return n > o
+		}
+	}
+	return false
+}
+
+// Retrieves the releases of the tool and downloads their binaries
+type Updater struct {
+	url       string
+	publicKey ed25519.PublicKey
+	client    *http.Client
+}
+
+// Instantiates an updater reading the latest release from the given GitHub API url and verifying the binaries with
+// the given base64 encoded Ed25519 public key, whose requests time out after the given duration. The binaries cannot
+// be downloaded if the key is empty.
+func NewUpdater(url string, publicKey string, timeout time.Duration) (*Updater, error) {
+	updater := &Updater{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+	if publicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(publicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("the release public key should be a base64 encoded Ed25519 public key")
+		}
+		updater.publicKey = key
+	}
+	return updater, nil
+}
+
+// Returns the latest release of the tool
+func (u *Updater) GetLatest() (*Release, error) {
+	content, err := u.get(u.url, 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name string `json:"name"`
+			Url  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(content, &response); err != nil {
+		return nil, errors.New("cannot parse the latest release: " + err.Error())
+	}
+	if response.TagName == "" {
+		return nil, errors.New("the latest release has no version")
+	}
+	release := &Release{Version: response.TagName, Assets: make(map[string]string)}
+	for _, asset := range response.Assets {
+		release.Assets[asset.Name] = asset.Url
+	}
+	return release, nil
+}
+
+// Downloads the binary of the given release built for the running OS and architecture, returning an error if its
+// signature is missing or does not match
+func (u *Updater) Download(release *Release) ([]byte, error) {
+	if u.publicKey == nil {
+		return nil, errors.New("this build has no release public key and cannot verify the release binaries, download them from the release page")
+	}
+	name := GetAssetName(runtime.GOOS, runtime.GOARCH)
+	binaryUrl, ok := release.Assets[name]
+	if !ok {
+		return nil, errors.New("release " + release.Version + " has no " + name + " binary")
+	}
+	signatureUrl, ok := release.Assets[name+SignatureExtension]
+	if !ok {
+		return nil, errors.New("release " + release.Version + " has no signature of the " + name + " binary")
+	}
+
+	encoded, err := u.get(signatureUrl, 1<<10)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, errors.New("the signature of the " + name + " binary should be a base64 encoded Ed25519 signature")
+	}
+	binary, err := u.get(binaryUrl, maxBinarySize)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(u.publicKey, binary, signature) {
+		return nil, errors.New("the signature of the " + name + " binary of release " + release.Version + " does not match, the binary has not been installed")
+	}
+	return binary, nil
+}
+
+// Returns the content at the given url, failing if it is larger than the given size
+func (u *Updater) get(url string, maxSize int64) ([]byte, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", "gocesiumtiler")
+	response, err := u.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode/100 != 2 {
+		return nil, errors.New(url + " responded " + response.Status)
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(response.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxSize {
+		return nil, errors.New(url + " is larger than expected")
+	}
+	return content, nil
+}
+
+// Replaces the executable at the given path with the given binary. The new binary is written next to it and renamed
+// over it, after moving the running executable aside as Windows does not allow overwriting it.
+func Replace(executable string, binary []byte) error {
+	info, err := os.Stat(executable)
+	if err != nil {
+		return err
+	}
+	directory, name := filepath.Split(executable)
+	replacement := filepath.Join(directory, "."+name+".new")
+	if err := ioutil.WriteFile(replacement, binary, info.Mode().Perm()|0100); err != nil {
+		return err
+	}
+	previous := filepath.Join(directory, "."+name+".old")
+	_ = os.Remove(previous)
+	if err := os.Rename(executable, previous); err != nil {
+		_ = os.Remove(replacement)
+		return err
+	}
+	if err := os.Rename(replacement, executable); err != nil {
+		// restores the running executable
+		_ = os.Rename(previous, executable)
+		_ = os.Remove(replacement)
+		return err
+	}
+	// the running executable cannot be removed on Windows, where it is left aside until the next update
+	_ = os.Remove(previous)
+	return nil
+}
@@ -0,0 +1,71 @@
+package watchdog
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"os"
+)
+
+// Wraps the given tree so that every point added to it is reported as progress
+func (w *Watchdog) TrackTree(tree octree.ITree) octree.ITree {
+	return &progressTree{ITree: tree, watchdog: w}
+}
+
+// Wraps the given storage so that every completed operation is reported as progress
+func (w *Watchdog) TrackStorage(storage storage.Storage) storage.Storage {
+	return &progressStorage{storage: storage, watchdog: w}
+}
+
+type progressTree struct {
+	octree.ITree
+	watchdog *Watchdog
+}
+
+func (t *progressTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+	t.watchdog.Progress()
+}
+
+type progressStorage struct {
+	storage  storage.Storage
+	watchdog *Watchdog
+}
+
+func (s *progressStorage) Open(filePath string) (storage.File, error) {
+	file, err := s.storage.Open(filePath)
+	s.watchdog.Progress()
+	if err != nil {
+		return nil, err
+	}
+	return &progressFile{File: file, watchdog: s.watchdog}, nil
+}
+
+func (s *progressStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	err := s.storage.WriteFile(filePath, data, perm)
+	s.watchdog.Progress()
+	return err
+}
+
+func (s *progressStorage) MkdirAll(directory string, perm os.FileMode) error {
+	err := s.storage.MkdirAll(directory, perm)
+	s.watchdog.Progress()
+	return err
+}
+
+type progressFile struct {
+	storage.File
+	watchdog *Watchdog
+}
+
+func (f *progressFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.watchdog.Progress()
+	return n, err
+}
+
+func (f *progressFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	f.watchdog.Progress()
+	return n, err
+}
@@ -0,0 +1,121 @@
+package watchdog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Detects when a job stops making progress, e.g. because of a reader stuck on a network file system or a deadlock.
+// When no progress is reported for longer than the timeout, the stacks of all the goroutines and the current phase
+// are dumped to a file and the stall handler is invoked.
+type Watchdog struct {
+	timeout      time.Duration
+	dumpFolder   string
+	onStall      func(dumpFile string)
+	progress     int64
+	lastProgress int64
+	phase        string
+	stop         chan struct{}
+	stopped      sync.WaitGroup
+	sync.Mutex
+}
+
+// Instantiates a new watchdog writing its dumps in the given folder and invoking onStall, with the path of the dump,
+// after every detected stall
+func NewWatchdog(timeout time.Duration, dumpFolder string, onStall func(dumpFile string)) *Watchdog {
+	return &Watchdog{
+		timeout:    timeout,
+		dumpFolder: dumpFolder,
+		onStall:    onStall,
+		stop:       make(chan struct{}),
+	}
+}
+
+// Starts watching the progress of the job
+func (w *Watchdog) Start() {
+	w.Progress()
+	w.stopped.Add(1)
+	go w.watch()
+}
+
+// Stops watching the progress of the job
+func (w *Watchdog) Stop() {
+	close(w.stop)
+	w.stopped.Wait()
+}
+
+// Reports that the job made progress
+func (w *Watchdog) Progress() {
+	atomic.AddInt64(&w.progress, 1)
+	atomic.StoreInt64(&w.lastProgress, time.Now().UnixNano())
+}
+
+// Records the phase the job is in, entering a new phase counts as progress
+func (w *Watchdog) SetPhase(phase string) {
+	w.Lock()
+	w.phase = phase
+	w.Unlock()
+	w.Progress()
+}
+
+func (w *Watchdog) watch() {
+	defer w.stopped.Done()
+	ticker := time.NewTicker(w.getCheckInterval())
+	defer ticker.Stop()
+
+	// a stall is reported once, until progress is made again
+	reportedProgress := int64(-1)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			progress := atomic.LoadInt64(&w.progress)
+			idle := time.Since(time.Unix(0, atomic.LoadInt64(&w.lastProgress)))
+			if idle < w.timeout || progress == reportedProgress {
+				continue
+			}
+			reportedProgress = progress
+			w.onStall(w.dump(idle, progress))
+		}
+	}
+}
+
+// Writes the state of the job and the stacks of all the goroutines to a new file, returning its path. The dump is
+// written directly to the file system, bypassing the tiler storage which could be the stalled component.
+func (w *Watchdog) dump(idle time.Duration, progress int64) string {
+	w.Lock()
+	phase := w.phase
+	w.Unlock()
+
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("no progress for %s\n", idle.Truncate(time.Second)))
+	buffer.WriteString("phase: " + phase + "\n")
+	buffer.WriteString("progress events: " + strconv.FormatInt(progress, 10) + "\n\n")
+	_ = pprof.Lookup("goroutine").WriteTo(&buffer, 2)
+
+	dumpFile := path.Join(w.dumpFolder, "stall-"+time.Now().Format("20060102-150405")+".txt")
+	if err := ioutil.WriteFile(dumpFile, buffer.Bytes(), 0666); err != nil {
+		return ""
+	}
+	return dumpFile
+}
+
+// Checks are performed often enough to detect a stall shortly after the timeout has expired
+func (w *Watchdog) getCheckInterval() time.Duration {
+	interval := w.timeout / 10
+	if interval < 10*time.Millisecond {
+		return 10 * time.Millisecond
+	}
+	if interval > 30*time.Second {
+		return 30 * time.Second
+	}
+	return interval
+}
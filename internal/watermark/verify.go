@@ -0,0 +1,50 @@
+package watermark
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+)
+
+// Min number of distinct watermark cells found for the watermark to be considered present. A point of a tileset not
+// watermarked with the owner id matches by chance with a probability of one in Interval times 2^24.
+const MinMatches = 3
+
+// Result of the search of a watermark in a tileset
+type Verification struct {
+	// Number of points checked
+	Points int64
+	// Number of distinct cells holding a watermark point of the owner id
+	Matches int
+}
+
+// Returns true if enough watermark points have been found to prove that the tileset has been watermarked
+func (v *Verification) IsPresent() bool {
+	return v.Matches >= MinMatches
+}
+
+// Searches the watermark points of the given owner id among the points of the given tree, whose coordinates are
+// EPSG:4326 longitudes and latitudes, such as the ones of a mounted tileset
+func Verify(root octree.INode, owner string) *Verification {
+	verification := &Verification{}
+	key := []byte(owner)
+	matches := make(map[[2]int64]bool)
+	nodes := []octree.INode{root}
+	for len(nodes) > 0 {
+		node := nodes[len(nodes)-1]
+		nodes = nodes[:len(nodes)-1]
+		for _, point := range node.GetPoints() {
+			verification.Points++
+			lonCell, latCell := getCell(point.X, point.Y)
+			code := getCode(key, lonCell, latCell)
+			if isSelected(code) && point.R == code[0] && point.G == code[1] && point.B == code[2] {
+				matches[[2]int64{lonCell, latCell}] = true
+			}
+		}
+		for _, child := range node.GetChildren() {
+			if child != nil {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	verification.Matches = len(matches)
+	return verification
+}
@@ -0,0 +1,87 @@
+package watermark
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"math"
+	"sync/atomic"
+)
+
+// Size in degrees of the cells of the longitude and latitude grid the watermark points are placed in. The points are
+// placed at the center of their cell, far enough from its borders to be found in the same cell once written with the
+// single precision of the tile contents and read back.
+const CellSize = 1e-6
+
+// On average one cell every Interval cells holding points receives a watermark point
+const Interval = 10000
+
+// Decorates a tree injecting sparse watermark points encoding the owner id. The cells of the points added to the tree
+// are selected by a keyed hash of the owner id and of the cell, so that the same cells are selected whatever the order
+// the points are added in. For every point falling in a selected cell a watermark point is added at the center of the
+// cell, with the attributes of the point but the color derived from the same hash, so that Verify can recognize it.
+type Tree struct {
+	octree.ITree
+	converter converters.CoordinateConverter
+	key       []byte
+	count     int64
+}
+
+// Wraps the given tree injecting the watermark points of the given owner id, placed once the coordinates of the points
+// are transformed to EPSG:4326 by the given converter
+func NewWatermarkTree(tree octree.ITree, converter converters.CoordinateConverter, owner string) *Tree {
+	return &Tree{
+		ITree:     tree,
+		converter: converter,
+		key:       []byte(owner),
+	}
+}
+
+func (t *Tree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	t.ITree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+	wgs84, err := t.converter.ConvertCoordinateSrid(srid, 4326, *coordinate)
+	if err != nil || math.IsNaN(wgs84.X) || math.IsNaN(wgs84.Y) {
+		return
+	}
+	lonCell, latCell := getCell(wgs84.X, wgs84.Y)
+	code := getCode(t.key, lonCell, latCell)
+	if !isSelected(code) {
+		return
+	}
+	center := geometry.Coordinate{X: (float64(lonCell) + 0.5) * CellSize, Y: (float64(latCell) + 0.5) * CellSize, Z: wgs84.Z}
+	atomic.AddInt64(&t.count, 1)
+	t.ITree.AddPoint(&center, code[0], code[1], code[2], intensity, classification, 4326, attributes)
+}
+
+// Returns the number of watermark points injected
+func (t *Tree) GetCount() int64 {
+	return atomic.LoadInt64(&t.count)
+}
+
+// Forgets the injected points, e.g. before the points are added again by a further pass of a multi-pass tree
+func (t *Tree) Reset() {
+	atomic.StoreInt64(&t.count, 0)
+}
+
+// Returns the indexes of the cell of the given longitude and latitude
+func getCell(longitude float64, latitude float64) (int64, int64) {
+	return int64(math.Floor(longitude / CellSize)), int64(math.Floor(latitude / CellSize))
+}
+
+// Returns the keyed hash of the given cell, whose first three bytes are the color of the watermark points of the cell
+// and the following ones decide whether the cell is selected
+func getCode(key []byte, lonCell int64, latCell int64) []byte {
+	message := make([]byte, 16)
+	binary.LittleEndian.PutUint64(message[0:8], uint64(lonCell))
+	binary.LittleEndian.PutUint64(message[8:16], uint64(latCell))
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+func isSelected(code []byte) bool {
+	return binary.LittleEndian.Uint32(code[3:7])%Interval == 0
+}
@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/runinfo"
+	"github.com/mfbonfigli/gocesiumtiler/internal/stats"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header holding the HMAC-SHA256 signature of the body of the notifications, as sha256=<hex digest>
+const SignatureHeader = "X-Gocesiumtiler-Signature"
+
+// Header holding the event of the notifications
+const EventHeader = "X-Gocesiumtiler-Event"
+
+// Events notified at the end of a job
+const (
+	JobCompleted = "job.completed"
+	JobFailed    = "job.failed"
+)
+
+// Number of attempts to deliver a notification, retried after a delay doubling at every failure
+const maxAttempts = 3
+
+// Delay before the first retry of a failed delivery
+const retryDelay = time.Second
+
+// Content of the notification posted at the end of a job. The text field makes it displayable as is by chat
+// incoming webhooks such as the Slack ones.
+type Payload struct {
+	Event    string         `json:"event"`
+	Text     string         `json:"text"`
+	Error    string         `json:"error,omitempty"`
+	Output   string         `json:"output"`
+	Finished time.Time      `json:"finished"`
+	Stats    *stats.Summary `json:"stats"`
+	// metadata of the run, if recorded
+	Run *runinfo.Run `json:"run,omitempty"`
+}
+
+// Builds the payload notifying the end of a job writing in the given output folder, failed if the given error is not
+// nil, with the given statistics and run metadata, if any
+func NewPayload(jobErr error, output string, summary *stats.Summary, run *runinfo.Run) *Payload {
+	payload := &Payload{
+		Event:    JobCompleted,
+		Output:   output,
+		Finished: time.Now().UTC(),
+		Stats:    summary,
+		Run:      run,
+	}
+	details := strconv.Itoa(len(summary.Files)) + " files, " + strconv.FormatInt(summary.TotalPointsRead, 10) + " points read in " + strconv.FormatFloat(summary.Seconds, 'f', 1, 64) + " s"
+	payload.Text = "gocesiumtiler job writing " + output + " completed: " + details
+	if jobErr != nil {
+		payload.Event = JobFailed
+		payload.Error = jobErr.Error()
+		payload.Text = "gocesiumtiler job writing " + output + " failed after " + details + ": " + jobErr.Error()
+	}
+	return payload
+}
+
+// Posts the job notifications to a webhook url, signing them with a shared secret if any
+type Notifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// Instantiates a notifier posting to the given url, whose requests time out after the given duration. The
+// notifications are not signed if the secret is empty.
+func NewNotifier(url string, secret string, timeout time.Duration) *Notifier {
+	return &Notifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Posts the given payload as json, retrying the deliveries failed for network errors or server errors
+func (n *Notifier) Notify(payload *Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(payload.Event, body)
+		if err == nil || !retry || attempt == maxAttempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Posts the given body returning true along with the error if the delivery failed and can be retried
+func (n *Notifier) post(event string, body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, event)
+	if n.secret != "" {
+		request.Header.Set(SignatureHeader, Sign(body, n.secret))
+	}
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	_ = response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return response.StatusCode >= 500, errors.New("webhook responded " + response.Status)
+	}
+	return false, nil
+}
+
+// Returns the signature of the given body with the given secret, sha256= followed by the hex encoded HMAC-SHA256 of
+// the body, which receivers recompute to authenticate the notifications
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,118 @@
+package zonal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/coverage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// Names of the files holding the statistics of the zones
+const (
+	GeoJsonFileName = "zonal.geojson"
+	CsvFileName     = "zonal.csv"
+)
+
+// Writes the statistics of the given zones in the GeoJSON and CSV files of the given folder
+func Write(zones []*Zone, statistics []*Statistics, folder string, storage storage.Storage) error {
+	geoJson, err := ToGeoJson(zones, statistics)
+	if err != nil {
+		return err
+	}
+	if err := storage.WriteFile(path.Join(folder, GeoJsonFileName), geoJson, 0666); err != nil {
+		return err
+	}
+	table, err := ToCsv(zones, statistics)
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(path.Join(folder, CsvFileName), table, 0666)
+}
+
+type geoJsonOutputFeature struct {
+	Type       string                 `json:"type"`
+	Id         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   geoJsonOutputGeometry  `json:"geometry"`
+}
+
+type geoJsonOutputGeometry struct {
+	Type        string             `json:"type"`
+	Coordinates []coverage.Polygon `json:"coordinates"`
+}
+
+// Encodes the given zones and their statistics as a GeoJSON feature collection, the statistics being added to the
+// properties of the zones: points, area in square meters, density in points per square meter, mean and max
+// elevation and the number of points per classification
+func ToGeoJson(zones []*Zone, statistics []*Statistics) ([]byte, error) {
+	features := make([]geoJsonOutputFeature, len(zones))
+	for i, zone := range zones {
+		s := statistics[i]
+		properties := make(map[string]interface{}, len(zone.Properties)+6)
+		for key, value := range zone.Properties {
+			properties[key] = value
+		}
+		classes := make(map[string]int64, len(s.Classes))
+		for class, count := range s.Classes {
+			classes[strconv.Itoa(int(class))] = count
+		}
+		properties["points"] = s.Count
+		properties["area_m2"] = zone.Area
+		properties["density"] = s.GetDensity(zone)
+		properties["mean_elevation"] = s.MeanElevation
+		properties["max_elevation"] = s.MaxElevation
+		properties["classes"] = classes
+		features[i] = geoJsonOutputFeature{
+			Type:       "Feature",
+			Id:         zone.Id,
+			Properties: properties,
+			Geometry:   geoJsonOutputGeometry{Type: "MultiPolygon", Coordinates: zone.Polygons},
+		}
+	}
+	return json.MarshalIndent(map[string]interface{}{"type": "FeatureCollection", "features": features}, "", "\t")
+}
+
+// Encodes the statistics of the given zones as a CSV table with a row per zone and a class_<code> column for every
+// classification found in any zone
+func ToCsv(zones []*Zone, statistics []*Statistics) ([]byte, error) {
+	present := make(map[uint8]bool)
+	for _, s := range statistics {
+		for class := range s.Classes {
+			present[class] = true
+		}
+	}
+	classes := make([]int, 0, len(present))
+	for class := range present {
+		classes = append(classes, int(class))
+	}
+	sort.Ints(classes)
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	header := []string{"id", "points", "area_m2", "density", "mean_elevation", "max_elevation"}
+	for _, class := range classes {
+		header = append(header, "class_"+strconv.Itoa(class))
+	}
+	_ = writer.Write(header)
+	for i, zone := range zones {
+		s := statistics[i]
+		row := []string{
+			zone.Id,
+			strconv.FormatInt(s.Count, 10),
+			strconv.FormatFloat(zone.Area, 'f', 2, 64),
+			strconv.FormatFloat(s.GetDensity(zone), 'f', 4, 64),
+			strconv.FormatFloat(s.MeanElevation, 'f', 3, 64),
+			strconv.FormatFloat(s.MaxElevation, 'f', 3, 64),
+		}
+		for _, class := range classes {
+			row = append(row, strconv.FormatInt(s.Classes[uint8(class)], 10))
+		}
+		_ = writer.Write(row)
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
@@ -0,0 +1,270 @@
+package zonal
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/coverage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"math"
+	"strconv"
+)
+
+// Radius in meters of the sphere the areas of the zones are computed on, the WGS84 equatorial radius
+const earthRadius = 6378137.0
+
+// Polygonal area whose points are summarized, read from a GeoJSON feature
+type Zone struct {
+	// Id of the feature, or its one based position in the collection if it has none
+	Id         string
+	Properties map[string]interface{}
+	// Polygons of the zone, in EPSG:4326 coordinates
+	Polygons []coverage.Polygon
+	// Area of the zone in square meters
+	Area float64
+}
+
+// Statistics of the points contained in a zone
+type Statistics struct {
+	Count         int64
+	MeanElevation float64
+	MaxElevation  float64
+	// Number of points per classification
+	Classes map[uint8]int64
+	sum     float64
+}
+
+// Returns the number of points per square meter of the given zone
+func (s *Statistics) GetDensity(zone *Zone) float64 {
+	if zone.Area == 0 {
+		return 0
+	}
+	return float64(s.Count) / zone.Area
+}
+
+type geoJsonFeature struct {
+	Id         interface{}            `json:"id,omitempty"`
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+// Reads the zones of the given GeoJSON feature collection, whose features are polygons or multipolygons in EPSG:4326
+// coordinates. The features of other geometries are rejected.
+func ReadZones(content []byte) ([]*Zone, error) {
+	var collection struct {
+		Type     string           `json:"type"`
+		Features []geoJsonFeature `json:"features"`
+	}
+	if err := json.Unmarshal(content, &collection); err != nil {
+		return nil, errors.New("cannot parse the zones: " + err.Error())
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) == 0 {
+		return nil, errors.New("the zones should be a GeoJSON feature collection with at least one feature")
+	}
+
+	zones := make([]*Zone, len(collection.Features))
+	for i, feature := range collection.Features {
+		zone := &Zone{Id: strconv.Itoa(i + 1), Properties: feature.Properties}
+		switch id := feature.Id.(type) {
+		case string:
+			zone.Id = id
+		case float64:
+			zone.Id = strconv.FormatFloat(id, 'f', -1, 64)
+		}
+		if feature.Geometry == nil {
+			return nil, errors.New("zone " + zone.Id + " has no geometry")
+		}
+		var err error
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon coverage.Polygon
+			err = json.Unmarshal(feature.Geometry.Coordinates, &polygon)
+			zone.Polygons = []coverage.Polygon{polygon}
+		case "MultiPolygon":
+			err = json.Unmarshal(feature.Geometry.Coordinates, &zone.Polygons)
+		default:
+			return nil, errors.New("zone " + zone.Id + " should be a Polygon or a MultiPolygon, not a " + feature.Geometry.Type)
+		}
+		if err != nil {
+			return nil, errors.New("cannot parse the coordinates of zone " + zone.Id + ": " + err.Error())
+		}
+		for _, polygon := range zone.Polygons {
+			for j, ring := range polygon {
+				if len(ring) < 4 {
+					return nil, errors.New("zone " + zone.Id + " has a ring with less than 4 positions")
+				}
+				// the holes are subtracted from the outer ring
+				if j == 0 {
+					zone.Area += getRingArea(ring)
+				} else {
+					zone.Area -= getRingArea(ring)
+				}
+			}
+		}
+		zones[i] = zone
+	}
+	return zones, nil
+}
+
+// Returns the area in square meters of the given ring of EPSG:4326 coordinates, measured on a sphere
+func getRingArea(ring coverage.Ring) float64 {
+	toRadians := math.Pi / 180
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		a, b := ring[i], ring[i+1]
+		area += (b[0] - a[0]) * toRadians * (2 + math.Sin(a[1]*toRadians) + math.Sin(b[1]*toRadians))
+	}
+	return math.Abs(area * earthRadius * earthRadius / 2)
+}
+
+// Zone converted to the coordinate system of the points of a tree, with its 2D bounds
+type projectedZone struct {
+	polygons               []coverage.Polygon
+	minX, maxX, minY, maxY float64
+}
+
+func (z *projectedZone) contains(x float64, y float64) bool {
+	if x < z.minX || x > z.maxX || y < z.minY || y > z.maxY {
+		return false
+	}
+	for _, polygon := range z.polygons {
+		if isInRing(polygon[0], x, y) {
+			inHole := false
+			for _, hole := range polygon[1:] {
+				if isInRing(hole, x, y) {
+					inHole = true
+					break
+				}
+			}
+			if !inHole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if the given position is inside the given ring, by the even-odd rule
+func isInRing(ring coverage.Ring, x float64, y float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > y) != (b[1] > y) && x < (b[0]-a[0])*(y-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Computes the statistics of the points of the given tree contained in every given zone. The zones are converted to
+// the coordinate system of the points, their edges being straight lines in it, and only the nodes whose bounding box
+// intersects a zone are visited. With replace true the points of every node that repeat the ones of its parent, as
+// in the tilesets refined with the REPLACE mode, are counted once, the points of the tree being EPSG:4326 coordinates
+// as the ones of a mounted tileset.
+func Compute(root octree.INode, zones []*Zone, converter converters.CoordinateConverter, replace bool, cancellation *cancellation.Token) ([]*Statistics, error) {
+	srid := root.GetInternalSrid()
+	projected := make([]*projectedZone, len(zones))
+	statistics := make([]*Statistics, len(zones))
+	for i, zone := range zones {
+		z, err := projectZone(zone, srid, converter)
+		if err != nil {
+			return nil, err
+		}
+		projected[i] = z
+		statistics[i] = &Statistics{Classes: make(map[uint8]int64), MaxElevation: math.Inf(-1)}
+	}
+
+	var visit func(node octree.INode, parentPoints io.PointSet) error
+	visit = func(node octree.INode, parentPoints io.PointSet) error {
+		if err := cancellation.Err(); err != nil {
+			return err
+		}
+		box := node.GetBoundingBox()
+		var intersected []int
+		for i, z := range projected {
+			if box.Xmin <= z.maxX && box.Xmax >= z.minX && box.Ymin <= z.maxY && box.Ymax >= z.minY {
+				intersected = append(intersected, i)
+			}
+		}
+		if len(intersected) == 0 {
+			return nil
+		}
+
+		points := node.GetPoints()
+		var nodePoints io.PointSet
+		if replace {
+			nodePoints = io.NewPointSet(points)
+		}
+		for _, point := range points {
+			if parentPoints.Contains(point) {
+				continue
+			}
+			for _, i := range intersected {
+				if projected[i].contains(point.X, point.Y) {
+					statistics[i].add(point)
+				}
+			}
+		}
+		for _, child := range node.GetChildren() {
+			if child != nil && child.TotalNumberOfPoints() > 0 {
+				if err := visit(child, nodePoints); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := visit(root, nil); err != nil {
+		return nil, err
+	}
+
+	for _, s := range statistics {
+		if s.Count > 0 {
+			s.MeanElevation = s.sum / float64(s.Count)
+		} else {
+			s.MaxElevation = 0
+		}
+	}
+	return statistics, nil
+}
+
+func (s *Statistics) add(point *data.Point) {
+	s.Count++
+	s.sum += point.Z
+	s.MaxElevation = math.Max(s.MaxElevation, point.Z)
+	s.Classes[point.Classification]++
+}
+
+// Converts the positions of the given zone to the given srid, computing its bounds
+func projectZone(zone *Zone, srid int, converter converters.CoordinateConverter) (*projectedZone, error) {
+	z := &projectedZone{minX: math.Inf(1), maxX: math.Inf(-1), minY: math.Inf(1), maxY: math.Inf(-1)}
+	for _, polygon := range zone.Polygons {
+		converted := make(coverage.Polygon, len(polygon))
+		for i, ring := range polygon {
+			converted[i] = make(coverage.Ring, len(ring))
+			for j, position := range ring {
+				x, y := position[0], position[1]
+				if srid != 4326 {
+					coordinate, err := converter.ConvertCoordinateSrid(4326, srid, geometry.Coordinate{X: x, Y: y})
+					if err != nil {
+						return nil, err
+					}
+					x, y = coordinate.X, coordinate.Y
+				}
+				converted[i][j] = [2]float64{x, y}
+				z.minX, z.maxX = math.Min(z.minX, x), math.Max(z.maxX, x)
+				z.minY, z.maxY = math.Min(z.minY, y), math.Max(z.maxY, y)
+			}
+		}
+		z.polygons = append(z.polygons, converted)
+	}
+	return z, nil
+}
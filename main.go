@@ -24,12 +24,36 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/compatibility"
+	"github.com/mfbonfigli/gocesiumtiler/internal/compression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/gh_offset_calculator"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/grid_offset_calculator"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/picking"
+	"github.com/mfbonfigli/gocesiumtiler/internal/preview"
+	"github.com/mfbonfigli/gocesiumtiler/internal/profile"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/parquet_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/synthetic"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/internal/treedump"
+	"github.com/mfbonfigli/gocesiumtiler/internal/update"
+	"github.com/mfbonfigli/gocesiumtiler/internal/watermark"
+	"github.com/mfbonfigli/gocesiumtiler/internal/zonal"
 	"github.com/mfbonfigli/gocesiumtiler/pkg"
 	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager/std_algorithm_manager"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -38,6 +62,9 @@ import (
 
 const VERSION = "1.2.0"
 
+// Largest thumbnail that can be rendered for a tile, in pixels
+const maxThumbnailSize = 4096
+
 const logo = `
                            _                 _   _ _
   __ _  ___   ___ ___  ___(_)_   _ _ __ ___ | |_(_) | ___ _ __ 
@@ -76,6 +103,100 @@ func main() {
 		tools.DisableLoggerTimestamp()
 	}
 
+	if *flags.SelfUpdate {
+		selfUpdate()
+		return
+	}
+
+	if *flags.Serve != "" {
+		serve(*flags.Serve, *flags.Output)
+		return
+	}
+
+	if *flags.VerifyWatermark != "" {
+		verifyWatermark(*flags.VerifyWatermark, *flags.Input)
+		return
+	}
+
+	if *flags.Generate != "" {
+		generateDataset(*flags.Generate, flags)
+		return
+	}
+
+	if *flags.InspectTree != "" {
+		inspectTree(*flags.InspectTree)
+		return
+	}
+
+	if *flags.Profile != "" {
+		extractProfile(*flags.Profile, *flags.ProfileBuffer, *flags.Input, *flags.Output)
+		return
+	}
+
+	if *flags.Nearest != "" {
+		pickNearest(*flags.Nearest, *flags.NearestCount, *flags.NearestLevel, *flags.Input)
+		return
+	}
+
+	if *flags.ZonalTileset != "" {
+		computeZonalStatistics(*flags.ZonalTileset, *flags.ZonalStats, *flags.Output)
+		return
+	}
+
+	if *flags.CheckUpdate {
+		checkUpdate()
+	}
+
+	levelRetention, ok := tiler.ParseLevelRetention(*flags.LevelRetention)
+	if !ok {
+		log.Fatal("Error parsing input parameters: level-retention should be a comma separated list of numbers")
+	}
+
+	elevationPipeline, ok := tiler.ParseElevationPipeline(*flags.ElevationPipeline)
+	if !ok {
+		log.Fatal("Error parsing input parameters: elevation-pipeline should be a comma separated list of geoid, offset:<meters> or raster:<file> steps")
+	}
+
+	readerPlugins, ok := tiler.ParseReaderPlugins(*flags.ReaderPlugins)
+	if !ok {
+		log.Fatal("Error parsing input parameters: reader-plugins should be a semicolon separated list of extension=command pairs")
+	}
+
+	validityArea, ok := tiler.ParseValidityArea(*flags.ValidityArea)
+	if !ok {
+		log.Fatal("Error parsing input parameters: validity-area should be a comma separated list of min longitude, min latitude, max longitude and max latitude")
+	}
+
+	sizeBudget, ok := tiler.ParseByteSize(*flags.SizeBudget)
+	if !ok {
+		log.Fatal("Error parsing input parameters: size-budget should be a size in bytes, optionally followed by a unit such as KB, MB, GB, KiB, MiB or GiB")
+	}
+
+	dracoQuantization, ok := tiler.ParseDracoQuantization(*flags.DracoQuantization)
+	if !ok {
+		log.Fatal("Error parsing input parameters: draco-quantization should be a comma separated list of attribute=bits pairs")
+	}
+
+	bundleThreshold, ok := tiler.ParseByteSize(*flags.BundleThreshold)
+	if !ok {
+		log.Fatal("Error parsing input parameters: bundle-threshold should be a size in bytes, optionally followed by a unit such as KB, MB, GB, KiB, MiB or GiB")
+	}
+
+	bundleMaxSize, ok := tiler.ParseByteSize(*flags.BundleMaxSize)
+	if !ok {
+		log.Fatal("Error parsing input parameters: bundle-max-size should be a size in bytes, optionally followed by a unit such as KB, MB, GB, KiB, MiB or GiB")
+	}
+
+	splitTileSize, ok := tiler.ParseByteSize(*flags.SplitTileSize)
+	if !ok {
+		log.Fatal("Error parsing input parameters: split-tile-size should be a size in bytes, optionally followed by a unit such as KB, MB, GB, KiB, MiB or GiB")
+	}
+
+	lasAttributes, ok := tiler.ParseLasAttributes(*flags.LasAttributes)
+	if !ok {
+		log.Fatal("Error parsing input parameters: las-attributes should be a comma separated list of distinct attributes among " + strings.Join(tiler.LasAttributes, ", "))
+	}
+
 	// Put args inside a TilerOptions struct
 	opts := tiler.TilerOptions{
 		Input:                  *flags.Input,
@@ -92,6 +213,122 @@ func main() {
 		CellMaxSize:            *flags.GridCellMaxSize,
 		RefineMode:             tiler.ParseRefineMode(*flags.RefineMode),
 		RootGeometricError:		*flags.RootGeometricError,
+		CoordinateFrame:        tiler.ParseCoordinateFrame(*flags.CoordinateFrame),
+		ContentExtension:       getContentExtension(*flags.ContentExtension, tiler.ParseTilesetVersion(*flags.TilesetVersion)),
+		ExtensionlessContent:   *flags.ExtensionlessContent,
+		HostConfig:             *flags.HostConfig,
+		RosCloudTopic:          *flags.RosCloudTopic,
+		RosPoseTopic:           *flags.RosPoseTopic,
+		TrajectoryFile:         *flags.TrajectoryFile,
+		TerrainFile:            *flags.TerrainFile,
+		TerrainSrid:            *flags.TerrainSrid,
+		OriginSnap:             tiler.ParseOriginSnapMode(*flags.OriginSnap),
+		StatsFinal:             *flags.StatsFinal,
+		Tui:                    *flags.Tui,
+		MaxOpenFiles:           *flags.MaxOpenFiles,
+		StallTimeout:           *flags.StallTimeout,
+		StallAbort:             *flags.StallAbort,
+		ReadWorkers:            *flags.ReadWorkers,
+		ConvertWorkers:         *flags.ConvertWorkers,
+		InsertWorkers:          *flags.InsertWorkers,
+		WriteWorkers:           *flags.WriteWorkers,
+		TileMetadata:           *flags.TileMetadata,
+		ThumbnailSize:          *flags.ThumbnailSize,
+		Coverage:               *flags.Coverage,
+		Stac:                   *flags.Stac,
+		StacCollection:         *flags.StacCollection,
+		GeoVolumes:             *flags.GeoVolumes,
+		LevelRetention:         levelRetention,
+		SkipDuplicates:         *flags.SkipDuplicates,
+		ControlPointsFile:      *flags.ControlPointsFile,
+		ControlPointsSrid:      *flags.ControlPointsSrid,
+		ControlPointsTolerance: *flags.ControlPointsTolerance,
+		ExcludeOverlap:         *flags.ExcludeOverlap,
+		Returns:                tiler.ParseReturnsMode(*flags.Returns),
+		ScannerChannel:         *flags.ScannerChannel,
+		PruneScreenError:       *flags.PruneScreenError,
+		PruneDistance:          *flags.PruneDistance,
+		Compression:            tiler.ParseCompressionMode(*flags.Compression),
+		ZstdDictionary:         *flags.ZstdDictionary,
+		DeduplicateTiles:       *flags.DeduplicateTiles,
+		UriTemplate:            *flags.UriTemplate,
+		ContentBaseUrl:         *flags.ContentBaseUrl,
+		MaxDirectoryEntries:    *flags.MaxDirectoryEntries,
+		Availability:           *flags.Availability,
+		Cancellation:           cancellation.NewToken(),
+		SourceColors:           *flags.SourceColors,
+		ElevationPipeline:      elevationPipeline,
+		ConverterCacheSize:     *flags.ConverterCacheSize,
+		ConverterCacheQuantum:  *flags.ConverterCacheQuantum,
+		RootPercentile:         *flags.RootPercentile,
+		LeafPointCap:           *flags.LeafPointCap,
+		LeafCapPolicy:          tiler.ParseLeafCapPolicy(*flags.LeafCapPolicy),
+		WithheldPoints:         tiler.ParseFlaggedPointsMode(*flags.WithheldPoints),
+		SyntheticPoints:        tiler.ParseFlaggedPointsMode(*flags.SyntheticPoints),
+		KeyPoints:              tiler.ParseFlaggedPointsMode(*flags.KeyPoints),
+		Alpha:                  tiler.ParseAlphaSource(*flags.Alpha),
+		AlphaMin:               *flags.AlphaMin,
+		GhostFilter:            *flags.GhostFilter,
+		GhostVoxelSize:         *flags.GhostVoxelSize,
+		GhostMaxDepth:          *flags.GhostMaxDepth,
+		BatchTable:             tiler.ParseBatchTableEncoding(*flags.BatchTable),
+		LegacyTables:           *flags.LegacyTables,
+		RunMetadata:            *flags.RunMetadata,
+		ToolVersion:            VERSION,
+		ClassLayers:            *flags.ClassLayers,
+		InvalidColors:          tiler.ParseInvalidColorsMode(*flags.InvalidColors),
+		SidecarFolder:          *flags.SidecarFolder,
+		SidecarKey:             tiler.ParseSidecarKey(*flags.SidecarKey),
+		ParquetColumns:         *flags.ParquetColumns,
+		ParquetExport:          *flags.ParquetExport,
+		WebhookUrl:             *flags.WebhookUrl,
+		WebhookSecret:          *flags.WebhookSecret,
+		ReaderPlugins:          readerPlugins,
+		BridgeCommand:          strings.Fields(*flags.Bridge),
+		SpoolFolder:            *flags.SpoolFolder,
+		Quarantine:             *flags.Quarantine || validityArea != nil,
+		ValidityArea:           validityArea,
+		ErrorPolicy:            tiler.ParseErrorPolicy(*flags.ErrorPolicy),
+		ReadChunkSize:          *flags.ReadChunkSize,
+		License:                *flags.License,
+		LicenseUrl:             *flags.LicenseUrl,
+		Watermark:              *flags.Watermark,
+		TilesetVersion:         tiler.ParseTilesetVersion(*flags.TilesetVersion),
+		SizeBudget:             sizeBudget,
+		DracoCompression:       *flags.Draco,
+		DracoQuantization:      dracoQuantization,
+		CoarseFirst:            *flags.CoarseFirst,
+		BundleThreshold:        bundleThreshold,
+		BundleMaxSize:          bundleMaxSize,
+		ImplicitTiling:         *flags.ImplicitTiling,
+		SubtreeLevels:          *flags.SubtreeLevels,
+		SplitTileSize:          splitTileSize,
+		Merge:                  *flags.Merge,
+		RecoverRecords:         *flags.RecoverRecords,
+		ColorSource:            tiler.ParseColorSource(*flags.ColorSource),
+		ColorRamp:              *flags.ColorRamp,
+		LasAttributes:          lasAttributes,
+		Resume:                 *flags.Resume,
+		ZonalStats:             *flags.ZonalStats,
+		CrsDefinition:          *flags.Crs,
+		CrsBackend:             *flags.CrsBackend,
+		GeoidModel:             *flags.GeoidModel,
+		DensityTarget:          *flags.DensityTarget,
+		DensityMode:            tiler.ParseDensityMode(*flags.DensityMode),
+		DensityCellSize:        *flags.DensityCellSize,
+		GridSpool:              *flags.GridSpool,
+		GridSampling:           tiler.ParseGridSampling(*flags.GridSampling),
+		ScanIds:                *flags.ScanIds,
+		ScanPoses:              *flags.ScanPoses,
+		WriterConcurrency:      *flags.WriterConcurrency,
+		IntensityStretch:       *flags.IntensityStretch,
+		GridSamplingAttribute:  *flags.GridSamplingAttribute,
+		TreeDump:               tiler.ParseTreeDumpFormat(*flags.TreeDump),
+		Wireframe:              *flags.Wireframe,
+	}
+
+	if *flags.Target != "" {
+		restrictToTarget(&opts, *flags.Target)
 	}
 
 	// Validate TilerOptions
@@ -99,9 +336,11 @@ func main() {
 		log.Fatal("Error parsing input parameters: " + msg)
 	}
 
+	cancelOnInterrupt(opts.Cancellation)
+
 	// Starts the tiler
 	// defer timeTrack(time.Now(), "tiler")
-	err := pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(&opts)).RunTiler(&opts)
+	err := pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(&opts), storage.NewOsStorage()).RunTiler(&opts)
 
 	if err != nil {
 		log.Fatal("Error while tiling: ", err)
@@ -113,10 +352,16 @@ func main() {
 // Validates the input options provided to the command line tool checking
 // that input and output folders/files exist
 func validateOptions(opts *tiler.TilerOptions) (string, bool) {
-	if _, err := os.Stat(opts.Input); os.IsNotExist(err) {
-		return "Input file/folder not found", false
+	for _, input := range tiler.GetInputPaths(opts) {
+		if _, err := os.Stat(input); os.IsNotExist(err) {
+			return "Input file/folder not found", false
+		}
 	}
-	if _, err := os.Stat(opts.Output); os.IsNotExist(err) {
+	if storage.IsObjectUrl(opts.Output) {
+		if _, _, err := storage.NewObjectClient(opts.Output); err != nil {
+			return "output " + err.Error(), false
+		}
+	} else if _, err := os.Stat(opts.Output); os.IsNotExist(err) {
 		return "Output folder not found", false
 	}
 
@@ -128,9 +373,813 @@ func validateOptions(opts *tiler.TilerOptions) (string, bool) {
 		return "refine-mode should be either ADD or REPLACE", false
 	}
 
+	if opts.CoordinateFrame == "" {
+		return "frame should be either ECEF or LOCAL", false
+	}
+
+	if opts.OriginSnap == "" {
+		return "origin-snap should be one of NONE, GRID or CENTROID", false
+	}
+
+	if opts.GridSampling == "" {
+		return "grid-sampling should be one of CENTER, RANDOM, POISSON, FIRST or MAX", false
+	}
+
+	if _, ok := tiler.GetSamplingAttributeIndex(opts.GridSamplingAttribute, opts.LasAttributes); opts.GridSampling == tiler.GridSamplingMax && !ok {
+		return "grid-sampling-attribute should be intensity or one of the las-attributes", false
+	}
+
+	if opts.GridSampling != tiler.GridSamplingCenter && opts.Algorithm != tiler.Grid {
+		return "grid-sampling " + opts.GridSampling.String() + " is only supported by the GRID algorithm", false
+	}
+
+	if opts.Returns == "" {
+		return "returns should be one of ALL, FIRST or LAST", false
+	}
+
+	if opts.TreeDump == "" {
+		return "tree-dump should be one of NONE, JSON or BINARY", false
+	}
+
+	if opts.Compression == "" {
+		return "compression should be one of NONE or ZSTD", false
+	}
+
+	if opts.ZstdDictionary && opts.Compression != tiler.CompressionZstd {
+		return "zstd-dict requires compression to be ZSTD", false
+	}
+
+	if opts.UriTemplate != "" {
+		if _, err := io.ParseUriTemplate(opts.UriTemplate); err != nil {
+			return "uri-template is not valid: " + err.Error(), false
+		}
+	}
+
+	if opts.MaxDirectoryEntries != 0 && opts.MaxDirectoryEntries < io.MinDirectoryEntries {
+		return "max-dir-entries should be 0 or at least " + strconv.Itoa(io.MinDirectoryEntries), false
+	}
+
+	if opts.ContentBaseUrl != "" {
+		if baseUrl, err := url.Parse(opts.ContentBaseUrl); err != nil || !baseUrl.IsAbs() || baseUrl.Host == "" {
+			return "content-base-url should be an absolute url, e.g. https://cdn.example.com/datasets/", false
+		}
+	}
+
+	if opts.WebhookUrl != "" {
+		if webhookUrl, err := url.Parse(opts.WebhookUrl); err != nil || (webhookUrl.Scheme != "http" && webhookUrl.Scheme != "https") || webhookUrl.Host == "" {
+			return "webhook should be an absolute http or https url", false
+		}
+	}
+
+	if opts.ScannerChannel > 3 {
+		return "scanner-channel should be between 0 and 3, or negative to load all channels", false
+	}
+
+	if opts.PruneScreenError < 0 {
+		return "prune-sse cannot be negative", false
+	}
+
+	if opts.PruneScreenError > 0 && opts.PruneDistance <= 0 {
+		return "prune-distance must be positive", false
+	}
+
+	if opts.MaxOpenFiles < 0 {
+		return "max-open-files cannot be negative", false
+	}
+
+	if opts.StallTimeout < 0 {
+		return "stall-timeout cannot be negative", false
+	}
+
+	if opts.StallAbort && opts.StallTimeout == 0 {
+		return "stall-abort requires stall-timeout to be set", false
+	}
+
+	if opts.LicenseUrl != "" && opts.License == "" {
+		return "license-url requires license to be set", false
+	}
+
+	if opts.TilesetVersion == "" {
+		return "tileset-version should be one of 1.0 or 1.1", false
+	}
+
+	if opts.TilesetVersion == tiler.TilesetVersion11 && (opts.BatchTable == tiler.BatchTableJson || opts.LegacyTables) {
+		return "batch-table JSON and legacy-tables only apply to the pnts contents of 3D Tiles 1.0 tilesets", false
+	}
+
+	if opts.SizeBudget > 0 && opts.RefineMode == tiler.RefineModeReplace {
+		return "size-budget only supports the ADD refine mode, as REPLACE repeats the points of the parent tiles", false
+	}
+
+	if opts.SizeBudget > 0 && opts.ClassLayers {
+		return "size-budget cannot be combined with class-layers", false
+	}
+
+	if opts.DracoCompression && opts.TilesetVersion == tiler.TilesetVersion11 {
+		return "draco only applies to the pnts contents of 3D Tiles 1.0 tilesets, as the glTF draco extension does not support point primitives", false
+	}
+
+	if opts.DracoCompression && opts.BatchTable == tiler.BatchTableJson {
+		return "draco requires the binary batch tables", false
+	}
+
+	for name, bits := range opts.DracoQuantization {
+		if name == "CLASSIFICATION" {
+			return "draco-quantization cannot quantize the classifications, which are always stored losslessly", false
+		}
+		if (name == "COLOR" || name == "INTENSITY") && (bits < 1 || bits > 8) {
+			return "draco-quantization bits of " + name + " must be between 1 and 8", false
+		}
+		if bits < 1 || bits > 30 {
+			return "draco-quantization bits of " + name + " must be between 1 and 30", false
+		}
+	}
+
+	if opts.CoarseFirst && (opts.DeduplicateTiles || opts.UriTemplate != "" || opts.MaxDirectoryEntries > 0 || opts.ZstdDictionary) {
+		return "coarse-first cannot be combined with dedup-tiles, uri-template, max-dir-entries or zstd-dictionary, which hold back the tileset.json files or the contents until all the tiles are written", false
+	}
+
+	if opts.CoarseFirst && opts.WriterConcurrency > 0 {
+		return "coarse-first cannot be combined with writer-concurrency, as the tiles of a level have to be stored before the next level is written", false
+	}
+
+	if opts.BundleThreshold > 0 && opts.RefineMode == tiler.RefineModeReplace {
+		return "bundle-threshold only supports the ADD refine mode, as REPLACE repeats the points of the parent tiles in every bundled tile", false
+	}
+
+	if opts.BundleThreshold > 0 && opts.BundleMaxSize < 2*opts.BundleThreshold {
+		return "bundle-max-size should be at least twice bundle-threshold to hold two tiles per bundle", false
+	}
+
+	if opts.ImplicitTiling {
+		if msg, res := validateImplicitTilingOptions(opts); !res {
+			return msg, false
+		}
+	}
+
+	if opts.Merge && (opts.SkipDuplicates || opts.RunMetadata || opts.SidecarFolder != "" || opts.Resume) {
+		return "merge cannot be combined with skip-duplicates, run-metadata, sidecar-folder or resume, which track every input file in its own tileset", false
+	}
+
+	if opts.ScanIds && !opts.Merge {
+		return "scan-ids requires merge, the scans being the merged input files", false
+	}
+
+	if opts.ScanPoses != "" {
+		if !opts.ScanIds {
+			return "scan-poses requires scan-ids", false
+		}
+		if _, err := os.Stat(opts.ScanPoses); os.IsNotExist(err) {
+			return "scan-poses file not found", false
+		}
+	}
+
+	if opts.ColorSource == "" {
+		return "color-source should be one of RGB, INTENSITY or AUTO", false
+	}
+
+	if _, err := octree.ParseColorRamp(opts.ColorRamp); err != nil {
+		return "color-ramp " + err.Error(), false
+	}
+
+	if opts.ColorSource == tiler.ColorSourceAuto {
+		if _, _, err := octree.ParseIntensityStretch(opts.IntensityStretch); err != nil {
+			return "intensity-stretch " + err.Error(), false
+		}
+	}
+
+	if opts.SourceColors && opts.ColorSource == tiler.ColorSourceIntensity {
+		return "source-colors cannot be combined with color-source INTENSITY, which both replace the colors of the points", false
+	}
+
+	if opts.ReadChunkSize < 0 {
+		return "read-chunk-size cannot be negative", false
+	}
+
+	if opts.ReadWorkers < 0 || opts.ConvertWorkers < 0 || opts.InsertWorkers < 0 || opts.WriteWorkers < 0 || opts.WriterConcurrency < 0 {
+		return "the number of workers cannot be negative", false
+	}
+
+	if opts.ThumbnailSize < 0 || opts.ThumbnailSize > maxThumbnailSize {
+		return "thumbnail-size must be between 0 and " + strconv.Itoa(maxThumbnailSize), false
+	}
+
+	if msg, res := validateLevelRetention(opts); !res {
+		return msg, false
+	}
+
+	if msg, res := validateElevationPipeline(opts); !res {
+		return msg, false
+	}
+
+	if opts.RootPercentile < 0 || opts.RootPercentile >= 50 {
+		return "root-percentile must be between 0 and 50", false
+	}
+
+	if opts.RootPercentile > 0 && opts.Algorithm != tiler.Grid {
+		return "root-percentile is only supported by the GRID algorithm", false
+	}
+
+	if opts.LeafCapPolicy == "" {
+		return "leaf-cap-policy should be one of KEEP_ALL, RANDOM or DENSEST", false
+	}
+
+	if opts.LeafPointCap < 0 {
+		return "leaf-cap cannot be negative", false
+	}
+
+	if opts.LeafPointCap > 0 && opts.Algorithm != tiler.Grid {
+		return "leaf-cap is only supported by the GRID algorithm", false
+	}
+
+	if msg, res := validateDensityTarget(opts); !res {
+		return msg, false
+	}
+
+	if area := opts.ValidityArea; area != nil && (area[0] < -180 || area[2] > 180 || area[1] < -90 || area[3] > 90 || area[0] >= area[2] || area[1] >= area[3]) {
+		return "validity-area should be min longitude, min latitude, max longitude and max latitude within -180,-90,180,90", false
+	}
+
+	if opts.ErrorPolicy == "" {
+		return "error-policy should be one of FAIL or CONTINUE", false
+	}
+
+	if opts.Algorithm == tiler.TwoPass {
+		if msg, res := validateTwoPassOptions(opts); !res {
+			return msg, false
+		}
+	}
+
+	if opts.GridSpool {
+		if msg, res := validateGridSpoolOptions(opts); !res {
+			return msg, false
+		}
+	}
+
+	if converter, err := converters.NewCoordinateConverter(opts.CrsBackend, tiler.GetCrsDefinitions(opts)); err != nil {
+		return err.Error(), false
+	} else {
+		converter.Cleanup()
+	}
+
+	if !grid_offset_calculator.IsDefaultGeoidModel(opts.GeoidModel) {
+		if err := grid_offset_calculator.CheckModelGrid(opts.GeoidModel); err != nil {
+			return "geoid-model " + err.Error(), false
+		}
+	} else if tiler.IsGeoidCorrected(opts) {
+		if err := gh_offset_calculator.CheckGravitationalModel(); err != nil {
+			return err.Error(), false
+		}
+	}
+
+	if _, err := parquet_reader.ParseColumnMapping(opts.ParquetColumns); err != nil {
+		return "parquet-columns " + err.Error(), false
+	}
+
+	if opts.SidecarKey == "" {
+		return "sidecar-key should be one of INDEX or GPS_TIME", false
+	}
+
+	if opts.InvalidColors == "" {
+		return "invalid-colors should be one of KEEP, OMIT or INTENSITY", false
+	}
+
+	if opts.ClassLayers && (opts.Coverage || opts.Availability || opts.Stac || opts.StacCollection || opts.GeoVolumes || opts.TreeDump != tiler.TreeDumpNone || opts.Wireframe) {
+		return "class-layers is not supported together with coverage, availability, stac, stac-collection, geovolumes, tree-dump and wireframe", false
+	}
+
+	if opts.ClassLayers && opts.ZonalStats != "" {
+		return "zonal-stats is not supported together with class-layers", false
+	}
+
+	if opts.BatchTable == "" {
+		return "batch-table should be one of BINARY or JSON", false
+	}
+
+	if opts.GhostFilter && opts.GhostVoxelSize <= 0 {
+		return "ghost-voxel must be greater than 0", false
+	}
+
+	if opts.GhostFilter && opts.GhostMaxDepth <= opts.GhostVoxelSize {
+		return "ghost-depth must be greater than ghost-voxel", false
+	}
+
+	if opts.Alpha == "" {
+		return "alpha should be one of NONE or INTENSITY", false
+	}
+
+	if opts.AlphaMin < 0 || opts.AlphaMin > 1 {
+		return "alpha-min must be between 0 and 1", false
+	}
+
+	if opts.WithheldPoints == "" {
+		return "withheld should be one of KEEP, DROP or SPLIT", false
+	}
+
+	if opts.SyntheticPoints == "" {
+		return "synthetic should be one of KEEP, DROP or SPLIT", false
+	}
+
+	if opts.KeyPoints == "" {
+		return "key-points should be one of KEEP, DROP or SPLIT", false
+	}
+
+	if opts.ConverterCacheSize < 0 {
+		return "converter-cache cannot be negative", false
+	}
+
+	if opts.ConverterCacheSize > 0 && opts.ConverterCacheQuantum <= 0 {
+		return "converter-cache-quantum must be greater than 0", false
+	}
+
+	if strings.ContainsAny(opts.ContentExtension, "/\\") {
+		return "content-extension cannot contain path separators", false
+	}
+
+	if opts.TrajectoryFile != "" {
+		if _, err := os.Stat(opts.TrajectoryFile); os.IsNotExist(err) {
+			return "Trajectory file not found", false
+		}
+	}
+
+	if opts.TerrainFile != "" {
+		if _, err := os.Stat(opts.TerrainFile); os.IsNotExist(err) {
+			return "Terrain file not found", false
+		}
+	}
+
+	if opts.ControlPointsFile != "" {
+		if _, err := os.Stat(opts.ControlPointsFile); os.IsNotExist(err) {
+			return "Control points file not found", false
+		}
+	}
+
+	if opts.ControlPointsTolerance < 0 {
+		return "control-points-tolerance cannot be negative", false
+	}
+
+	if opts.ControlPointsTolerance > 0 && opts.ControlPointsFile == "" {
+		return "control-points-tolerance requires control-points to be set", false
+	}
+
+	return "", true
+}
+
+// Checks that the tree can be tiled implicitly, its tiles being the octants of the grid cells, and that the tiles are
+// written at the paths and with the properties the implicit tiling declares
+func validateImplicitTilingOptions(opts *tiler.TilerOptions) (string, bool) {
+	if opts.Algorithm != tiler.Grid {
+		return "implicit-tiling is only supported by the GRID algorithm", false
+	}
+	if opts.SubtreeLevels < 1 || opts.SubtreeLevels > 8 {
+		return "subtree-levels must be between 1 and 8", false
+	}
+	if opts.RootPercentile > 0 || opts.BundleThreshold > 0 {
+		return "implicit-tiling cannot be combined with root-percentile or bundle-threshold, whose tiles are not octants of their parents", false
+	}
+	if opts.CoarseFirst || opts.DeduplicateTiles || opts.UriTemplate != "" || opts.MaxDirectoryEntries > 0 || opts.ContentBaseUrl != "" {
+		return "implicit-tiling cannot be combined with coarse-first, dedup-tiles, uri-template, max-dir-entries or content-base-url, as the implicit tile contents are named after their coordinates", false
+	}
+	if opts.TileMetadata {
+		return "implicit-tiling cannot be combined with tile-metadata, as the implicit tiles declare no metadata", false
+	}
+	return "", true
+}
+
+// Checks that the density mode is valid and, if a density target is set, that it is positive, that the grid algorithm,
+// the only one supporting it, is used and that the density cells can hold a point at the target density
+func validateDensityTarget(opts *tiler.TilerOptions) (string, bool) {
+	if opts.DensityMode == "" {
+		return "density-mode should be either AREA or VOLUME", false
+	}
+	if opts.DensityTarget < 0 {
+		return "density-target cannot be negative", false
+	}
+	if opts.DensityTarget == 0 {
+		return "", true
+	}
+	if opts.Algorithm != tiler.Grid {
+		return "density-target is only supported by the GRID algorithm", false
+	}
+	if opts.DensityCellSize <= 0 {
+		return "density-cell-size must be positive", false
+	}
+	cell := opts.DensityCellSize * opts.DensityCellSize
+	if opts.DensityMode == tiler.DensityVolume {
+		cell *= opts.DensityCellSize
+	}
+	if opts.DensityTarget*cell < 1 {
+		return "density-cell-size is too small to hold a point at density-target, use larger cells", false
+	}
+	return "", true
+}
+
+// Checks that every retention fraction is in the (0, 1] range, that they do not sum to more than 1 and that the grid
+// algorithm, the only one supporting them, is used
+func validateLevelRetention(opts *tiler.TilerOptions) (string, bool) {
+	if len(opts.LevelRetention) == 0 {
+		return "", true
+	}
+	if opts.Algorithm != tiler.Grid {
+		return "level-retention is only supported by the GRID algorithm", false
+	}
+	sum := 0.0
+	for _, fraction := range opts.LevelRetention {
+		if fraction <= 0 || fraction > 1 {
+			return "level-retention fractions must be greater than 0 and lower or equal to 1", false
+		}
+		sum += fraction
+	}
+	if sum > 1 {
+		return "level-retention fractions cannot sum to more than 1", false
+	}
+	return "", true
+}
+
+// Checks that the options do not require the points of a file to be held in memory, which the TwoPass algorithm would
+// otherwise avoid
+func validateTwoPassOptions(opts *tiler.TilerOptions) (string, bool) {
+	if opts.ConvertWorkers > 0 {
+		return "convert-workers is not supported by the TWOPASS algorithm", false
+	}
+	if opts.GhostFilter {
+		return "ghost-filter is not supported by the TWOPASS algorithm", false
+	}
+	if opts.ClassLayers {
+		return "class-layers is not supported by the TWOPASS algorithm", false
+	}
+	if opts.PruneScreenError > 0 {
+		return "prune-sse is not supported by the TWOPASS algorithm", false
+	}
+	return "", true
+}
+
+// Checks that the spooled grid tree is not combined with the options needing all the points in memory
+func validateGridSpoolOptions(opts *tiler.TilerOptions) (string, bool) {
+	if opts.Algorithm != tiler.Grid {
+		return "grid-spool is only supported by the GRID algorithm", false
+	}
+	if opts.ConvertWorkers > 0 {
+		return "convert-workers is not supported with grid-spool", false
+	}
+	if opts.GhostFilter {
+		return "ghost-filter is not supported with grid-spool", false
+	}
+	if opts.ClassLayers {
+		return "class-layers is not supported with grid-spool", false
+	}
+	if opts.PruneScreenError > 0 {
+		return "prune-sse is not supported with grid-spool", false
+	}
+	if len(opts.LevelRetention) > 0 || opts.RootPercentile > 0 || opts.LeafPointCap > 0 || opts.DensityTarget > 0 {
+		return "level-retention, root-percentile, leaf-cap and density-target are not supported with grid-spool", false
+	}
+	return "", true
+}
+
+// Checks that the elevation steps are not combined with the corrections they replace and that the correction grids exist
+func validateElevationPipeline(opts *tiler.TilerOptions) (string, bool) {
+	if len(opts.ElevationPipeline) == 0 {
+		return "", true
+	}
+	if opts.ZOffset != 0 || opts.EnableGeoidZCorrection {
+		return "elevation-pipeline cannot be combined with zoffset or geoid, add offset or geoid steps to the pipeline instead", false
+	}
+	for _, step := range opts.ElevationPipeline {
+		if step.Kind != tiler.ElevationStepRaster {
+			continue
+		}
+		if _, err := os.Stat(step.File); os.IsNotExist(err) {
+			return "elevation correction grid " + step.File + " not found", false
+		}
+	}
 	return "", true
 }
 
+// Cancels the job on the first interrupt signal, letting the running loops stop at their next checkpoint. Further
+// interrupts terminate the process right away.
+func cancelOnInterrupt(token *cancellation.Token) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		tools.LogOutput("Interrupted, stopping the job. Press Ctrl+C again to terminate immediately")
+		token.Cancel()
+	}()
+}
+
+// Serves the given output folder over HTTP decoding the zstd compressed tile contents
+func serve(address string, folder string) {
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		log.Fatal("Error parsing input parameters: Output folder not found")
+	}
+
+	decoder, err := compression.LoadDecoder(folder)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	viewerUrl := "http://" + address + "/"
+	if strings.HasPrefix(address, ":") {
+		viewerUrl = "http://localhost" + address + "/"
+	}
+	tools.LogOutput("Serving " + folder + " on " + address + ", open " + viewerUrl + " to view its tilesets")
+	log.Fatal(http.ListenAndServe(address, preview.NewHandler(folder, decoder)))
+}
+
+// Returns the extension of the tile content files set by the given flag, replacing its default .pnts one with .glb
+// for the 3D Tiles 1.1 tilesets
+func getContentExtension(extension string, version tiler.TilesetVersion) string {
+	if version == tiler.TilesetVersion11 && extension == ".pnts" {
+		return ".glb"
+	}
+	return extension
+}
+
+// Searches the watermark points of the given owner id in the tileset at the given path, exiting with an error if they
+// are not found
+func verifyWatermark(owner string, input string) {
+	tilesetPath := input
+	if info, err := os.Stat(input); err != nil {
+		log.Fatal("Error parsing input parameters: Input tileset not found")
+	} else if info.IsDir() {
+		tilesetPath = path.Join(input, "tileset.json")
+	}
+
+	decoder, err := compression.LoadDecoder(path.Dir(tilesetPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	root, err := io.MountTileset(tilesetPath, storage.NewOsStorage(), decoder.Decode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tools.LogOutput("Searching the watermark points of " + owner + " in " + tilesetPath)
+	verification := watermark.Verify(root, owner)
+	if err := root.Err(); err != nil {
+		log.Fatal(err)
+	}
+	tools.LogOutput(fmt.Sprintf("Found %d watermark points among %d points", verification.Matches, verification.Points))
+	if !verification.IsPresent() {
+		log.Fatal("Watermark of " + owner + " not found")
+	}
+	tools.LogOutput("Watermark of " + owner + " found")
+}
+
+// Prints as CSV the given number of points of the tileset at the given path closest to the given position, among the
+// ones of the given level of detail
+func pickNearest(value string, count int, level int, input string) {
+	lon, lat, height, err := picking.ParsePosition(value)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: " + err.Error())
+	}
+	if count <= 0 {
+		log.Fatal("Error parsing input parameters: nearest-count should be positive")
+	}
+	if level < 0 {
+		level = picking.FullResolution
+	}
+	tilesetPath := input
+	if info, err := os.Stat(input); err != nil {
+		log.Fatal("Error parsing input parameters: Input tileset not found")
+	} else if info.IsDir() {
+		tilesetPath = path.Join(input, "tileset.json")
+	}
+
+	decoder, err := compression.LoadDecoder(path.Dir(tilesetPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	root, err := io.MountTileset(tilesetPath, storage.NewOsStorage(), decoder.Decode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	neighbors, err := picking.Nearest(root, lon, lat, height, count, level, root.IsReplaceRefined(), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := root.Err(); err != nil {
+		log.Fatal(err)
+	}
+	table, err := picking.ToCsv(neighbors)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(string(table))
+}
+
+// Reloads the tree dump at the given path and logs the statistics of the levels of the tree
+func inspectTree(dumpPath string) {
+	content, err := ioutil.ReadFile(dumpPath)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: Tree dump not found")
+	}
+	dump, err := treedump.Parse(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tools.LogOutput(fmt.Sprintf("Tree of %d nodes and %d points in EPSG:%d", dump.Nodes, dump.Root.TotalPoints, dump.Srid))
+	for _, level := range dump.Levels() {
+		tools.LogOutput(fmt.Sprintf("level %d: %d nodes, %d leaves, %d points, %d cells of up to %.3f m, geometric error %.3f",
+			level.Depth, level.Nodes, level.Leaves, level.Points, level.Cells, level.MaxCellSize, level.GeometricError))
+	}
+}
+
+// Writes in the given output folder the profile of the points of the tileset at the given path within the given
+// buffer from the given line
+func extractProfile(value string, buffer float64, input string, output string) {
+	line, err := profile.ParseLine(value)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: " + err.Error())
+	}
+	if buffer <= 0 {
+		log.Fatal("Error parsing input parameters: profile-buffer should be positive")
+	}
+	if _, err := os.Stat(output); err != nil {
+		log.Fatal("Error parsing input parameters: Output folder not found")
+	}
+	tilesetPath := input
+	if info, err := os.Stat(input); err != nil {
+		log.Fatal("Error parsing input parameters: Input tileset not found")
+	} else if info.IsDir() {
+		tilesetPath = path.Join(input, "tileset.json")
+	}
+
+	decoder, err := compression.LoadDecoder(path.Dir(tilesetPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	root, err := io.MountTileset(tilesetPath, storage.NewOsStorage(), decoder.Decode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tools.LogOutput(fmt.Sprintf("Extracting the profile of the points within %.2f m of the line from %s", buffer, tilesetPath))
+	samples, err := profile.Extract(root, line, buffer, root.IsReplaceRefined(), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := root.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if err := profile.Write(samples, output, storage.NewOsStorage()); err != nil {
+		log.Fatal(err)
+	}
+	tools.LogOutput(fmt.Sprintf("Written the profile of %d points to %s and %s", len(samples), path.Join(output, profile.CsvFileName), path.Join(output, profile.SvgFileName)))
+}
+
+// Writes in the given output folder the statistics of the points of the tileset at the given path contained in the
+// zones of the given GeoJSON file
+func computeZonalStatistics(input string, zonesPath string, output string) {
+	if zonesPath == "" {
+		log.Fatal("Error parsing input parameters: zonal-tileset requires zonal-stats")
+	}
+	if _, err := os.Stat(output); err != nil {
+		log.Fatal("Error parsing input parameters: Output folder not found")
+	}
+	tilesetPath := input
+	if info, err := os.Stat(input); err != nil {
+		log.Fatal("Error parsing input parameters: Input tileset not found")
+	} else if info.IsDir() {
+		tilesetPath = path.Join(input, "tileset.json")
+	}
+	content, err := ioutil.ReadFile(zonesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	zones, err := zonal.ReadZones(content)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	decoder, err := compression.LoadDecoder(path.Dir(tilesetPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	root, err := io.MountTileset(tilesetPath, storage.NewOsStorage(), decoder.Decode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tools.LogOutput(fmt.Sprintf("Computing the statistics of %d zones over %s", len(zones), tilesetPath))
+	statistics, err := zonal.Compute(root, zones, nil, root.IsReplaceRefined(), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := root.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if err := zonal.Write(zones, statistics, output, storage.NewOsStorage()); err != nil {
+		log.Fatal(err)
+	}
+	tools.LogOutput("Written the statistics to " + path.Join(output, zonal.GeoJsonFileName) + " and " + path.Join(output, zonal.CsvFileName))
+}
+
+// Writes the synthetic LAS file described by the generate flags at the given path
+func generateDataset(filePath string, flags tools.Flags) {
+	dataset := synthetic.Dataset{
+		Srid:      *flags.Srid,
+		Extent:    *flags.GenerateExtent,
+		Density:   *flags.GenerateDensity,
+		Buildings: *flags.GenerateBuildings,
+		Noise:     *flags.GenerateNoise,
+		Seed:      int64(*flags.GenerateSeed),
+	}
+	dataset.OriginX, dataset.OriginY = synthetic.GetDefaultOrigin(dataset.Srid)
+	if *flags.GenerateOrigin != "" {
+		coordinates := strings.Split(*flags.GenerateOrigin, ",")
+		if len(coordinates) != 2 {
+			log.Fatal("Error parsing input parameters: generate-origin should be a comma separated pair of coordinates")
+		}
+		var errX, errY error
+		dataset.OriginX, errX = strconv.ParseFloat(strings.TrimSpace(coordinates[0]), 64)
+		dataset.OriginY, errY = strconv.ParseFloat(strings.TrimSpace(coordinates[1]), 64)
+		if errX != nil || errY != nil {
+			log.Fatal("Error parsing input parameters: generate-origin should be a comma separated pair of coordinates")
+		}
+	}
+	if dataset.Extent <= 0 || dataset.Density <= 0 {
+		log.Fatal("Error parsing input parameters: generate-extent and generate-density should be positive")
+	}
+	if dataset.Buildings < 0 || dataset.Noise < 0 {
+		log.Fatal("Error parsing input parameters: generate-buildings and generate-noise cannot be negative")
+	}
+
+	tools.LogOutput(fmt.Sprintf("Generating a synthetic point cloud of %.0fx%.0f m in EPSG:%d", dataset.Extent, dataset.Extent, dataset.Srid))
+	count, err := dataset.WriteLas(filePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tools.LogOutput(fmt.Sprintf("Written %d points to %s", count, filePath))
+}
+
+// Disables the features of the given options that the given target client does not support, logging a warning for
+// every feature disabled
+func restrictToTarget(opts *tiler.TilerOptions, value string) {
+	target, err := compatibility.ParseTarget(value)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: " + err.Error())
+	}
+	warnings, err := compatibility.Restrict(opts, target)
+	if err != nil {
+		log.Fatal("Error parsing input parameters: " + err.Error())
+	}
+	for _, warning := range warnings {
+		tools.LogOutput("Warning:", warning)
+	}
+}
+
+// Replaces the executable with the verified binary of the latest release, if newer than the running version
+func selfUpdate() {
+	updater, err := update.NewUpdater(update.DefaultReleasesUrl, update.PublicKey, 5*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	release, err := updater.GetLatest()
+	if err != nil {
+		log.Fatal("Cannot retrieve the latest release: " + err.Error())
+	}
+	if !update.IsNewer(release.Version, VERSION) {
+		tools.LogOutput("v." + VERSION + " is up to date, the latest release is " + release.Version)
+		return
+	}
+
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+	}
+	if err != nil {
+		log.Fatal("Cannot locate the executable: " + err.Error())
+	}
+	tools.LogOutput("Downloading release " + release.Version)
+	binary, err := updater.Download(release)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := update.Replace(executable, binary); err != nil {
+		log.Fatal("Cannot replace " + executable + ": " + err.Error())
+	}
+	tools.LogOutput("Updated " + executable + " from v." + VERSION + " to " + release.Version)
+}
+
+// Logs a notice if a release newer than the running version is available, the check failing silently but for a
+// log line so that the job is never held up by it
+func checkUpdate() {
+	updater, _ := update.NewUpdater(update.DefaultReleasesUrl, "", 5*time.Second)
+	release, err := updater.GetLatest()
+	if err != nil {
+		tools.LogOutput("Cannot check for updates: " + err.Error())
+		return
+	}
+	if update.IsNewer(release.Version, VERSION) {
+		tools.LogOutput("A newer release, " + release.Version + ", is available: run gocesiumtiler -self-update to install it")
+	}
+}
+
 func timeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
 	tools.LogOutput(fmt.Sprintf("%s took %s", name, elapsed))
@@ -143,7 +1192,7 @@ func printLogo() {
 func showHelp() {
 	printLogo()
 	fmt.Println("***")
-	fmt.Println("GoCesiumTiler is a tool that processes LAS files and transforms them in a 3D Tiles data structure consumable by Cesium.js")
+	fmt.Println("GoCesiumTiler is a tool that processes LAS, E57, PLY, PCD, ROS bag and Parquet files and transforms them in a 3D Tiles data structure consumable by Cesium.js")
 	printVersion()
 	fmt.Println("***")
 	fmt.Println("")
@@ -8,5 +8,6 @@ import (
 type AlgorithmManager interface {
 	GetElevationCorrectionAlgorithm() converters.ElevationCorrector
 	GetTreeAlgorithm() octree.ITree
+	NewTreeAlgorithm() octree.ITree
 	GetCoordinateConverterAlgorithm() converters.CoordinateConverter
 }
@@ -2,14 +2,20 @@ package std_algorithm_manager
 
 import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
-	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/cached_coordinate_converter"
+	_ "github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	_ "github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj_coordinate_converter"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/geoid_elevation_corrector"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/offset_elevation_corrector"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/pipeline_elevation_corrector"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/raster_elevation_corrector"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/gh_offset_calculator"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/grid_offset_calculator"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree/random_trees"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/two_pass_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/terrain"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager"
 	"log"
@@ -23,9 +29,14 @@ type StandardAlgorithmManager struct {
 }
 
 func NewAlgorithmManager(opts *tiler.TilerOptions) algorithm_manager.AlgorithmManager {
-	coordinateConverter := proj4_coordinate_converter.NewProj4CoordinateConverter()
-	ellipsoidToGeoidOffsetCalculator := gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculator(coordinateConverter)
-	elevationCorrectionAlgorithm := evaluateElevationCorrectionAlgorithm(opts, ellipsoidToGeoidOffsetCalculator, coordinateConverter)
+	coordinateConverter, err := converters.NewCoordinateConverter(opts.CrsBackend, tiler.GetCrsDefinitions(opts))
+	if err != nil {
+		log.Fatal("Error initializing the coordinate converter: ", err)
+	}
+	if opts.ConverterCacheSize > 0 {
+		coordinateConverter = cached_coordinate_converter.NewCachedCoordinateConverter(coordinateConverter, opts.ConverterCacheSize, opts.ConverterCacheQuantum)
+	}
+	elevationCorrectionAlgorithm := evaluateElevationCorrectionAlgorithm(opts, coordinateConverter)
 
 	algorithmManager := &StandardAlgorithmManager{
 		options:             opts,
@@ -45,29 +56,80 @@ func (am *StandardAlgorithmManager) GetTreeAlgorithm() octree.ITree {
 	return am.treeStructure
 }
 
+// Returns a new empty tree of the configured algorithm, independent of the one returned by GetTreeAlgorithm
+func (am *StandardAlgorithmManager) NewTreeAlgorithm() octree.ITree {
+	return evaluateTreeAlgorithm(am.options, am.coordinateConverter, am.elevationCorrector)
+}
+
 func (am *StandardAlgorithmManager) GetCoordinateConverterAlgorithm() converters.CoordinateConverter {
 	return am.coordinateConverter
 }
 
-func evaluateElevationCorrectionAlgorithm(options *tiler.TilerOptions, ellipsoidToGeoidOffsetCalculator converters.EllipsoidToGeoidOffsetCalculator, converter converters.CoordinateConverter) converters.ElevationCorrector {
+func evaluateElevationCorrectionAlgorithm(options *tiler.TilerOptions, converter converters.CoordinateConverter) converters.ElevationCorrector {
+	if len(options.ElevationPipeline) > 0 {
+		return evaluateElevationPipeline(options, converter)
+	}
+
 	var elevationCorrectors []converters.ElevationCorrector
 	elevationCorrectors = append(elevationCorrectors, offset_elevation_corrector.NewOffsetElevationCorrector(options.ZOffset))
 
 	if options.EnableGeoidZCorrection {
-		elevationCorrectors = append(elevationCorrectors, geoid_elevation_corrector.NewGeoidElevationCorrector(options.Srid, ellipsoidToGeoidOffsetCalculator))
+		elevationCorrectors = append(elevationCorrectors, evaluateGeoidElevationCorrector(options, converter))
+	}
+
+	return pipeline_elevation_corrector.NewPipelineElevationCorrector(elevationCorrectors)
+}
+
+// Chains the elevation correctors of the configured elevation steps in their order
+func evaluateElevationPipeline(options *tiler.TilerOptions, converter converters.CoordinateConverter) converters.ElevationCorrector {
+	var elevationCorrectors []converters.ElevationCorrector
+	for _, step := range options.ElevationPipeline {
+		switch step.Kind {
+		case tiler.ElevationStepGeoid:
+			elevationCorrectors = append(elevationCorrectors, evaluateGeoidElevationCorrector(options, converter))
+		case tiler.ElevationStepOffset:
+			elevationCorrectors = append(elevationCorrectors, offset_elevation_corrector.NewOffsetElevationCorrector(step.Offset))
+		case tiler.ElevationStepRaster:
+			corrections, err := terrain.LoadAsciiGrid(step.File)
+			if err != nil {
+				log.Fatal("Error loading the elevation correction grid: ", err)
+			}
+			elevationCorrectors = append(elevationCorrectors, raster_elevation_corrector.NewRasterElevationCorrector(corrections))
+		default:
+			log.Fatal("Unrecognized elevation step")
+		}
 	}
 
 	return pipeline_elevation_corrector.NewPipelineElevationCorrector(elevationCorrectors)
 }
 
+// Returns the corrector converting the heights above the geoid of the configured model to heights above the ellipsoid.
+// The corrected coordinates are always in EPSG:4326, whatever the input srid. Grid models are interpolated at every
+// point, while the offset of the spherical harmonic model, expensive to compute, is the one of the first point.
+func evaluateGeoidElevationCorrector(options *tiler.TilerOptions, converter converters.CoordinateConverter) converters.ElevationCorrector {
+	if grid_offset_calculator.IsDefaultGeoidModel(options.GeoidModel) {
+		return geoid_elevation_corrector.NewGeoidElevationCorrector(4326, gh_offset_calculator.NewEllipsoidToGeoidGHOffsetCalculator(converter))
+	}
+	calculator, err := grid_offset_calculator.NewGridOffsetCalculator(options.GeoidModel, converter)
+	if err != nil {
+		log.Fatal("Error loading the geoid grid: ", err)
+	}
+	return geoid_elevation_corrector.NewPointwiseGeoidElevationCorrector(4326, calculator)
+}
+
 func evaluateTreeAlgorithm(options *tiler.TilerOptions, converter converters.CoordinateConverter, elevationCorrection converters.ElevationCorrector) octree.ITree {
 	switch options.Algorithm {
 	case tiler.Grid:
-		return grid_tree.NewGridTree(converter, elevationCorrection, options.CellMaxSize, options.CellMinSize, options.RootGeometricError)
+		if options.GridSpool {
+			return grid_tree.NewSpooledGridTree(options, converter, elevationCorrection)
+		}
+		return grid_tree.NewGridTree(options, converter, elevationCorrection)
 	case tiler.RandomBox:
 		return random_trees.NewBoxedRandomTree(options, converter, elevationCorrection)
 	case tiler.Random:
 		return random_trees.NewRandomTree(options, converter, elevationCorrection)
+	case tiler.TwoPass:
+		return two_pass_tree.NewTwoPassTree(options.MaxNumPointsPerNode, options.SpoolFolder, converter, elevationCorrection)
 	}
 
 	log.Fatal("Unrecognized strategy")
@@ -3,17 +3,60 @@ package pkg
 import (
 	"errors"
 	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/accuracy"
+	"github.com/mfbonfigli/gocesiumtiler/internal/analytics"
+	"github.com/mfbonfigli/gocesiumtiler/internal/availability"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/checkpoint"
+	"github.com/mfbonfigli/gocesiumtiler/internal/compression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/cached_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/coverage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geovolumes"
+	"github.com/mfbonfigli/gocesiumtiler/internal/ghosts"
 	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/ledger"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/preflight"
+	"github.com/mfbonfigli/gocesiumtiler/internal/progress"
+	"github.com/mfbonfigli/gocesiumtiler/internal/quarantine"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/e57_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/las_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/parquet_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/pcd_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/plugin_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/ply_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/rosbag_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/runinfo"
+	"github.com/mfbonfigli/gocesiumtiler/internal/scans"
+	"github.com/mfbonfigli/gocesiumtiler/internal/sidecar"
+	"github.com/mfbonfigli/gocesiumtiler/internal/stac"
+	"github.com/mfbonfigli/gocesiumtiler/internal/stats"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/terrain"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/internal/trajectory"
+	"github.com/mfbonfigli/gocesiumtiler/internal/treedump"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tui"
+	"github.com/mfbonfigli/gocesiumtiler/internal/watchdog"
+	"github.com/mfbonfigli/gocesiumtiler/internal/watermark"
+	"github.com/mfbonfigli/gocesiumtiler/internal/webhook"
+	"github.com/mfbonfigli/gocesiumtiler/internal/zonal"
 	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager"
 	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
-	"log"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 type ITiler interface {
@@ -23,70 +66,1180 @@ type ITiler interface {
 type Tiler struct {
 	fileFinder       tools.FileFinder
 	algorithmManager algorithm_manager.AlgorithmManager
+	storage          storage.Storage
 }
 
-func NewTiler(fileFinder tools.FileFinder, algorithmManager algorithm_manager.AlgorithmManager) ITiler {
+// Holds the resources shared by the processing of all the input files of a tiling job
+type processingContext struct {
+	transformer readers.PointTransformer
+	storage     storage.Storage
+	dem         *terrain.Dem
+	stats       *stats.Collector
+	dashboard   *tui.Dashboard
+	watchdog    *watchdog.Watchdog
+	stacItems   []*stac.Item
+	geoVolumes  *geovolumes.Api
+	ledger      *ledger.Ledger
+	checkpoint  *checkpoint.Checkpoint
+	run         *runinfo.Run
+	// zones whose point statistics are written alongside every tileset, nil if none
+	zones []*zonal.Zone
+	// index of the file being processed among the input files
+	sourceIndex int
+	// counts of the flagged points of the file being read, nil if they are not to be counted
+	flagCounts *las_reader.FlagCounts
+	// counts of the malformed records skipped while reading the file being processed, nil if they fail the file
+	recoveryCounts *lidario.RecoveryCounts
+	// true while reading the flagged points to split from the file being processed
+	splitPass bool
+	// supplementary attributes joined to the points of the file being processed, nil if none
+	sidecar *sidecar.Table
+	// receives the progress events of the job, nil if they are not reported
+	progress *progress.Reporter
+	// input files read in place of every file to process when they are merged in a single tileset, nil otherwise
+	mergedFiles []string
+	// scans of the merged files whose points are tagged with their scan id, nil if they are not
+	scans *scans.Registry
+}
+
+// Name of the tileset holding the points of all the input files when they are merged
+const mergedTilesetName = "merged"
+
+// Suffix of the name of the tileset holding the flagged points split from an input file
+const flaggedTilesetSuffix = "_flagged"
+
+// Timeout of every delivery attempt of the webhook notification
+const webhookTimeout = 30 * time.Second
+
+// Starts timing the given phase of the processing of a file, reporting it to the watchdog and to the progress reporter
+// if enabled
+func (ctx *processingContext) startPhase(fileStats *stats.FileStats, name string) func() {
+	if ctx.watchdog != nil {
+		ctx.watchdog.SetPhase(fileStats.File + ": " + name)
+	}
+	ctx.progress.Report(progress.Event{Type: progress.PhaseStarted, File: fileStats.File, Phase: name})
+	endPhase := fileStats.StartPhase(name)
+	return func() {
+		endPhase()
+		ctx.progress.Report(progress.Event{Type: progress.PhaseFinished, File: fileStats.File, Phase: name})
+	}
+}
+
+func NewTiler(fileFinder tools.FileFinder, algorithmManager algorithm_manager.AlgorithmManager, storage storage.Storage) ITiler {
 	return &Tiler{
 		fileFinder:       fileFinder,
 		algorithmManager: algorithmManager,
+		storage:          storage,
 	}
 }
 
 // Starts the tiling process
 func (tiler *Tiler) RunTiler(opts *tiler.TilerOptions) error {
 	tools.LogOutput("Preparing list of files to process...")
+	jobStorage, jobOpts := tiler.storage, opts
+	// the tilesets of an object store url are written in a local staging folder standing for the url
+	var objectStorage *storage.ObjectStorage
+	if storage.IsObjectUrl(opts.Output) {
+		var err error
+		if objectStorage, err = storage.NewObjectStorage(opts.Output); err != nil {
+			return err
+		}
+		defer objectStorage.Cleanup()
+		stagedOpts := *opts
+		stagedOpts.Output = objectStorage.GetStagingFolder()
+		jobStorage, jobOpts = objectStorage, &stagedOpts
+	}
+	ctx := &processingContext{
+		stats:    stats.NewCollector(),
+		storage:  storage.NewBudgetedStorage(jobStorage, getOpenFilesBudget(jobOpts)),
+		progress: opts.Progress,
+	}
+
+	err := tiler.runJob(jobOpts, ctx)
+	if objectStorage != nil {
+		tools.LogOutput("Uploading the remaining files to", opts.Output)
+		if syncErr := objectStorage.Sync(); err == nil {
+			err = syncErr
+		}
+	}
+	if opts.WebhookUrl != "" {
+		notifyWebhook(err, opts, ctx)
+	}
+	return err
+}
+
+// Runs the tiling job sharing the resources of the given context between its input files
+func (tiler *Tiler) runJob(opts *tiler.TilerOptions, ctx *processingContext) error {
+	if opts.StallTimeout > 0 {
+		ctx.watchdog = watchdog.NewWatchdog(time.Duration(opts.StallTimeout*float64(time.Minute)), opts.Output, getStallHandler(opts))
+		ctx.storage = ctx.watchdog.TrackStorage(ctx.storage)
+		ctx.watchdog.Start()
+		defer ctx.watchdog.Stop()
+	}
 
 	// Prepare list of files to process
 	lasFiles := tiler.fileFinder.GetLasFilesToProcess(opts)
 
+	// Warn about suspicious configurations before starting the long run
+	tiler.runPreflightChecks(lasFiles, opts)
+
+	// the merged files are processed as a single file, all of them being read whenever its points are read
+	if opts.Merge {
+		tools.LogOutput("Merging", len(lasFiles), "files in the", mergedTilesetName, "tileset")
+		ctx.mergedFiles = lasFiles
+		lasFiles = []string{mergedTilesetName}
+	}
+
 	// Define point_loader strategy
 	var tree = tiler.algorithmManager.GetTreeAlgorithm()
 
+	var err error
+	ctx.transformer, err = getPointTransformer(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx.dem, err = getTerrain(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.ControlPointsFile != "" {
+		if err := tiler.checkControlPoints(opts, ctx); err != nil {
+			return err
+		}
+	}
+
+	if opts.GeoVolumes {
+		ctx.geoVolumes = geovolumes.NewApi(getOutputName(opts))
+	}
+
+	if opts.SkipDuplicates {
+		ctx.ledger, err = ledger.Load(ctx.storage, getLedgerPath(opts))
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Resume {
+		var matches bool
+		ctx.checkpoint, matches, err = checkpoint.Load(ctx.storage, getCheckpointPath(opts), opts)
+		if err != nil {
+			return err
+		}
+		if !matches {
+			return errors.New("the checkpoint " + getCheckpointPath(opts) + " has been written by a job with other options, remove it to tile the files again")
+		}
+	}
+
+	if opts.ZonalStats != "" {
+		content, err := ioutil.ReadFile(opts.ZonalStats)
+		if err != nil {
+			return err
+		}
+		if ctx.zones, err = zonal.ReadZones(content); err != nil {
+			return err
+		}
+	}
+
+	if opts.ScanIds {
+		if ctx.scans, err = loadScans(ctx.mergedFiles, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.RunMetadata {
+		ctx.run = runinfo.NewRun(opts.ToolVersion, opts)
+	}
+
+	if opts.Tui {
+		ctx.dashboard = tui.NewDashboard(os.Stdout, os.Stdin)
+		tools.SetLogListener(ctx.dashboard.Log)
+		ctx.dashboard.Start()
+		defer func() {
+			ctx.dashboard.Stop()
+			tools.SetLogListener(nil)
+		}()
+	}
+
+	processFile := tiler.processLasFile
+	if ctx.ledger != nil {
+		processFile = tiler.processLasFileOnce
+	}
+	if ctx.checkpoint != nil {
+		processFile = getResumingProcessor(processFile)
+	}
+
 	// load las points in octree buffer
 	for i, filePath := range lasFiles {
+		if err := opts.Cancellation.Err(); err != nil {
+			return err
+		}
+		if ctx.dashboard != nil {
+			if ctx.dashboard.IsAborted() {
+				tools.LogOutput("Job aborted, skipping the remaining files")
+				break
+			}
+			ctx.dashboard.StartFile(filepath.Base(filePath), i+1, len(lasFiles), getDensityMapBounds(filePath, ctx.transformer))
+		}
 		tools.LogOutput("Processing file " + strconv.Itoa(i+1) + "/" + strconv.Itoa(len(lasFiles)))
-		tiler.processLasFile(filePath, opts, tree)
+		ctx.sourceIndex = i
+		fileEvent := progress.Event{File: filepath.Base(filePath), FileIndex: i, FileCount: len(lasFiles)}
+		fileEvent.Type = progress.FileStarted
+		ctx.progress.Report(fileEvent)
+		// every file after the first one is loaded in a new tree, left untouched by the files before it even if they failed
+		fileTree := tree
+		if i > 0 {
+			fileTree = tiler.algorithmManager.NewTreeAlgorithm()
+		}
+		if err := processFile(filePath, opts, fileTree, ctx); err != nil {
+			if !skipsFailedFiles(opts) {
+				return err
+			}
+			tools.LogOutput("> ERROR: skipping", filepath.Base(filePath), "as it cannot be tiled:", err)
+			ctx.stats.FailFile(filepath.Base(filePath), err)
+			fileEvent.Type, fileEvent.Error = progress.FileFailed, err.Error()
+			ctx.progress.Report(fileEvent)
+			fileEvent.Error = ""
+			continue
+		}
+		fileEvent.Type = progress.FileFinished
+		ctx.progress.Report(fileEvent)
+	}
+	if err := reportFailures(len(lasFiles), opts, ctx); err != nil {
+		return err
+	}
+	if ctx.ledger != nil && len(ctx.ledger.Skipped) > 0 {
+		tools.LogOutput("Skipped", len(ctx.ledger.Skipped), "duplicate files, listed in", getLedgerPath(opts))
+	}
+	if cached, ok := tiler.algorithmManager.GetCoordinateConverterAlgorithm().(*cached_coordinate_converter.CachedCoordinateConverter); ok {
+		tools.LogOutput(fmt.Sprintf("Coordinate converter cache hit rate %.1f%%", cached.HitRate()*100))
 	}
 	tiler.algorithmManager.GetCoordinateConverterAlgorithm().Cleanup()
 
+	if opts.HostConfig {
+		tools.LogOutput("Writing host configuration files...")
+		if err := io.WriteHostConfigFiles(opts.Output, opts); err != nil {
+			return err
+		}
+	}
+
+	if ctx.geoVolumes != nil {
+		tools.LogOutput("Writing OGC API 3D GeoVolumes documents...")
+		if err := writeGeoVolumes(ctx.geoVolumes, opts, ctx.storage); err != nil {
+			return err
+		}
+	}
+
+	if opts.StacCollection {
+		tools.LogOutput("Writing STAC collection...")
+		if err := writeStacCollection(ctx.stacItems, opts, ctx.storage); err != nil {
+			return err
+		}
+	}
+
+	if ctx.run != nil {
+		if err := ctx.run.Save(ctx.storage, path.Join(opts.Output, runinfo.FileName)); err != nil {
+			return err
+		}
+	}
+
+	if opts.StatsFinal {
+		return writeStats(ctx.stats, opts)
+	}
+
 	return nil
 }
 
-func (tiler *Tiler) processLasFile(filePath string, opts *tiler.TilerOptions, tree octree.ITree) {
+func (tiler *Tiler) processLasFile(filePath string, opts *tiler.TilerOptions, tree octree.ITree, ctx *processingContext) error {
+	fileStats := ctx.stats.NewFileStats(filepath.Base(filePath))
+	baseTree := tree
+
+	var runInput *runinfo.Input
+	if ctx.run != nil {
+		hash, err := ledger.HashFile(ctx.storage, filePath)
+		if err != nil {
+			return err
+		}
+		runInput = ctx.run.StartInput(filePath, hash, getFilenameWithoutExtension(filePath))
+		defer runInput.Finish()
+	}
+
+	if opts.SidecarFolder != "" {
+		table, err := loadSidecarTable(filePath, opts, ctx.storage)
+		if err != nil {
+			return err
+		}
+		ctx.sidecar = table
+		defer func() { ctx.sidecar = nil }()
+	}
+
+	if opts.RecoverRecords {
+		ctx.recoveryCounts = &lidario.RecoveryCounts{}
+		defer func() { ctx.recoveryCounts = nil }()
+	}
+
+	if colorsAutomatically(filePath, opts, ctx) {
+		endPhase := ctx.startPhase(fileStats, "stretch")
+		var err error
+		if opts, err = tiler.stretchIntensities(filePath, opts, ctx); err != nil {
+			return err
+		}
+		endPhase()
+	}
+
+	// every class is loaded in a tree of its own, pruned independently of the other ones
+	var layers *octree.LayeredTree
+	if opts.ClassLayers {
+		layers = octree.NewLayeredTree(func() octree.ITree {
+			return getBundledTree(getPrunedTree(newSplitTree(tiler.algorithmManager.NewTreeAlgorithm(), opts, ctx), opts), opts, ctx)
+		})
+		tree = layers
+	}
+
+	// the points are checked as inserted, after every decorator altering their coordinates
+	var quarantineTree *quarantine.Tree
+	if quarantinesPoints(opts) {
+		quarantineTree = tiler.newQuarantineTree(tree, opts)
+		tree = quarantineTree
+	}
+	// the decorators altering the colors wrap the watermark tree, so that they leave the code of the watermark points intact
+	var watermarkTree *watermark.Tree
+	if opts.Watermark != "" {
+		watermarkTree = watermark.NewWatermarkTree(tree, tiler.algorithmManager.GetCoordinateConverterAlgorithm(), opts.Watermark)
+		tree = watermarkTree
+	}
+
+	// the conversion workers insert the points in the tree, so the tree has to be wrapped before any other decorator
+	if opts.ConvertWorkers > 0 {
+		tree = octree.NewConcurrentTree(tree, opts.ConvertWorkers)
+	}
+
+	var budgetedTree *octree.BudgetedTree
+	var splitTree *octree.SplitTree
+	if layers == nil {
+		splitTree = newSplitTree(tree, opts, ctx)
+		tree = splitTree
+		tree = getPrunedTree(tree, opts)
+		if opts.SizeBudget > 0 {
+			budgetedTree = newBudgetedTree(tree, opts, ctx)
+			tree = budgetedTree
+		}
+		tree = getBundledTree(tree, opts, ctx)
+	}
+
+	// the ghost filter buffers the whole file, the kept points are then inserted by the trees it wraps
+	if opts.GhostFilter {
+		tree = ghosts.NewGhostFilterTree(tree, opts.GhostVoxelSize, opts.GhostMaxDepth, opts.ReadWorkers)
+	}
+
+	fileOpts := opts
+	if ctx.dem != nil {
+		endPhase := ctx.startPhase(fileStats, "terrain")
+		var err error
+		fileOpts, err = tiler.clampToTerrain(filePath, opts, ctx)
+		if err != nil {
+			return err
+		}
+		tree = terrain.NewOffsetTree(tree, fileOpts.TerrainOffset)
+		endPhase()
+	}
+	if runInput != nil {
+		runInput.TerrainOffset = fileOpts.TerrainOffset
+		runOpts := *fileOpts
+		runOpts.RunExtras = ctx.run.GetTilesetExtras(runInput)
+		fileOpts = &runOpts
+	}
+	if names := getAttributeNames(opts, ctx); len(names) > 0 {
+		attributeOpts := *fileOpts
+		attributeOpts.AttributeNames = names
+		fileOpts = &attributeOpts
+	}
+	if opts.SourceColors {
+		r, g, b := octree.SourceColor(ctx.sourceIndex)
+		tools.LogOutput(fmt.Sprintf("> coloring the points of %s with #%02x%02x%02x", filepath.Base(filePath), r, g, b))
+		tree = octree.NewSourceColorTree(tree, ctx.sourceIndex)
+	}
+	tree = getIntensityColorTree(tree, opts, filePath)
+	// the source colors replace the input ones, so they do not need to be checked
+	var colorCheck *octree.ColorCheckTree
+	if checksColors(opts) {
+		colorCheck = octree.NewColorCheckTree(tree)
+		tree = colorCheck
+	}
+	// the further passes of multi-pass trees read the points again without counting them
+	passTree := tree
+	if ctx.dashboard != nil {
+		tree = ctx.dashboard.Track(tree)
+	}
+	if ctx.watchdog != nil {
+		tree = ctx.watchdog.TrackTree(tree)
+		passTree = ctx.watchdog.TrackTree(passTree)
+	}
+	if ctx.progress != nil {
+		tree = ctx.progress.TrackTree(tree, fileStats.File)
+	}
+
 	// Create empty octree
-	tiler.readLasData(filePath, opts, tree)
-	tiler.prepareDataStructure(tree)
-	tiler.exportToCesiumTileset(tree, opts, getFilenameWithoutExtension(filePath))
+	endPhase := ctx.startPhase(fileStats, "read")
+	ctx.flagCounts = &las_reader.FlagCounts{}
+	// only the records skipped by this read are reported, the other reads of the file skipping the same ones
+	if ctx.recoveryCounts != nil {
+		ctx.recoveryCounts = &lidario.RecoveryCounts{}
+	}
+	recoveryCounts := ctx.recoveryCounts
+	err := tiler.readLasData(filePath, opts, fileStats.CountPoints(tree), ctx)
+	flagCounts := ctx.flagCounts
+	ctx.flagCounts = nil
+	if recoveryCounts != nil {
+		reportRecoveredRecords(recoveryCounts, fileStats)
+	}
+	if err != nil {
+		return err
+	}
+	ctx.progress.Report(progress.Event{Type: progress.PointsRead, File: fileStats.File, Points: fileStats.PointsRead})
+	endPhase()
+	reportFlaggedPoints(flagCounts, opts, fileStats)
+	if err := opts.Cancellation.Err(); err != nil {
+		return err
+	}
+	if multiPass, ok := baseTree.(octree.MultiPassTree); ok {
+		defer closeMultiPassTree(multiPass)
+		endPhase = ctx.startPhase(fileStats, "spool")
+		if quarantineTree != nil {
+			quarantineTree.Reset()
+		}
+		if watermarkTree != nil {
+			watermarkTree.Reset()
+		}
+		if err := tiler.readFurtherPasses(filePath, opts, multiPass, passTree, ctx); err != nil {
+			return err
+		}
+		endPhase()
+	}
+
+	endPhase = ctx.startPhase(fileStats, "build")
+	if err := tiler.prepareDataStructure(tree); err != nil {
+		return err
+	}
+	endPhase()
+	if watermarkTree != nil {
+		tools.LogOutput("> injected", watermarkTree.GetCount(), "watermark points")
+	}
+	if splitTree != nil {
+		reportSplitTiles(splitTree)
+	}
+	if budgetedTree != nil {
+		reportSizeBudget(budgetedTree)
+	}
+	if quarantineTree != nil {
+		fileStats.QuarantinedPoints = quarantineTree.GetCount()
+		if err := reportQuarantinedPoints(quarantineTree, getFilenameWithoutExtension(filePath), opts, ctx); err != nil {
+			return err
+		}
+	}
+	if ctx.dashboard != nil {
+		ctx.dashboard.SetTree(tree)
+	}
+	if colorCheck != nil {
+		fileOpts = reportInvalidColors(colorCheck, fileOpts, fileStats)
+	}
+	if ctx.scans != nil {
+		fileOpts = getScanOptions(fileOpts, ctx.scans)
+	}
+
+	if layers != nil {
+		endPhase = ctx.startPhase(fileStats, "export")
+		if err := tiler.exportClassLayers(layers, fileOpts, getFilenameWithoutExtension(filePath), ctx); err != nil {
+			return err
+		}
+		if err := getReadError(baseTree); err != nil {
+			return err
+		}
+		endPhase()
+		if err := tiler.processSplitPoints(filePath, fileOpts, flagCounts, ctx); err != nil {
+			return err
+		}
+		tools.LogOutput("> done processing", filepath.Base(filePath))
+		return nil
+	}
+
+	// files whose points have all been filtered out would otherwise produce a tileset with an empty root tile
+	if root := tree.GetRootNode(); root == nil || root.TotalNumberOfPoints() == 0 {
+		tools.LogOutput("> no points to tile in", filepath.Base(filePath), "skipping")
+		return tiler.processSplitPoints(filePath, fileOpts, flagCounts, ctx)
+	}
+
+	endPhase = ctx.startPhase(fileStats, "export")
+	if err := tiler.exportToCesiumTileset(tree, fileOpts, getFilenameWithoutExtension(filePath), ctx); err != nil {
+		return err
+	}
+	if err := getReadError(baseTree); err != nil {
+		return err
+	}
+	endPhase()
+
+	if opts.Coverage {
+		endPhase = ctx.startPhase(fileStats, "coverage")
+		if err := tiler.writeCoverage(tree, getFilenameWithoutExtension(filePath), opts, ctx); err != nil {
+			return err
+		}
+		endPhase()
+	}
+
+	if dumpsTree(opts) {
+		if err := writeTreeDump(tree, getFilenameWithoutExtension(filePath), opts, ctx); err != nil {
+			return err
+		}
+	}
+
+	if opts.Wireframe {
+		if err := tiler.writeWireframe(tree, getFilenameWithoutExtension(filePath), opts, ctx); err != nil {
+			return err
+		}
+	}
+
+	if ctx.zones != nil {
+		endPhase = ctx.startPhase(fileStats, "zonal")
+		if err := tiler.writeZonalStatistics(tree, getFilenameWithoutExtension(filePath), opts, ctx); err != nil {
+			return err
+		}
+		endPhase()
+	}
+
+	if opts.Availability {
+		if err := tiler.writeAvailability(tree, getFilenameWithoutExtension(filePath), opts, ctx); err != nil {
+			return err
+		}
+	}
+
+	if opts.Stac || opts.StacCollection {
+		if err := tiler.writeStacItem(tree, filePath, opts, ctx); err != nil {
+			return err
+		}
+	}
+
+	if ctx.geoVolumes != nil {
+		bounds, err := tiler.getTilesetBounds(tree.GetRootNode())
+		if err != nil {
+			return err
+		}
+		ctx.geoVolumes.AddCollection(getFilenameWithoutExtension(filePath), bounds)
+	}
+
+	if opts.StatsFinal {
+		fileStats.CollectTreeStats(tree)
+	}
+
+	// the outputs written after the tileset read the points of the nodes again
+	if err := getReadError(baseTree); err != nil {
+		return err
+	}
+
+	if err := tiler.processSplitPoints(filePath, fileOpts, flagCounts, ctx); err != nil {
+		return err
+	}
 
 	tools.LogOutput("> done processing", filepath.Base(filePath))
+	return nil
 }
 
-func (tiler *Tiler) readLasData(filePath string, opts *tiler.TilerOptions, tree octree.ITree) {
-	// Reading files
-	tools.LogOutput("> reading data from las file...", filepath.Base(filePath))
-	err := readLas(filePath, opts, tree)
+// Tiles the points of the given file having a flag to split in their own tileset, named after the file with the
+// _flagged suffix. Nothing is done if the file has no such points.
+func (tiler *Tiler) processSplitPoints(filePath string, opts *tiler.TilerOptions, flagCounts *las_reader.FlagCounts, ctx *processingContext) error {
+	if !hasPointsToSplit(flagCounts, opts) {
+		return nil
+	}
 
+	tree := tiler.algorithmManager.NewTreeAlgorithm()
+	baseTree := tree
+	var quarantineTree *quarantine.Tree
+	if quarantinesPoints(opts) {
+		quarantineTree = tiler.newQuarantineTree(tree, opts)
+		tree = quarantineTree
+	}
+	// the decorators altering the colors wrap the watermark tree, so that they leave the code of the watermark points intact
+	var watermarkTree *watermark.Tree
+	if opts.Watermark != "" {
+		watermarkTree = watermark.NewWatermarkTree(tree, tiler.algorithmManager.GetCoordinateConverterAlgorithm(), opts.Watermark)
+		tree = watermarkTree
+	}
+	if opts.ConvertWorkers > 0 {
+		tree = octree.NewConcurrentTree(tree, opts.ConvertWorkers)
+	}
+	splitTree := newSplitTree(tree, opts, ctx)
+	tree = getPrunedTree(splitTree, opts)
+	var budgetedTree *octree.BudgetedTree
+	if opts.SizeBudget > 0 {
+		budgetedTree = newBudgetedTree(tree, opts, ctx)
+		tree = budgetedTree
+	}
+	tree = getBundledTree(tree, opts, ctx)
+	if ctx.dem != nil {
+		tree = terrain.NewOffsetTree(tree, opts.TerrainOffset)
+	}
+	if opts.SourceColors {
+		tree = octree.NewSourceColorTree(tree, ctx.sourceIndex)
+	}
+	tree = getIntensityColorTree(tree, opts, "")
+
+	tools.LogOutput("> reading flagged points from file...", filepath.Base(filePath))
+	ctx.splitPass = true
+	err := tiler.readPointCloud(filePath, opts, tree, ctx)
+	if multiPass, ok := baseTree.(octree.MultiPassTree); ok && err == nil {
+		defer closeMultiPassTree(multiPass)
+		if quarantineTree != nil {
+			quarantineTree.Reset()
+		}
+		if watermarkTree != nil {
+			watermarkTree.Reset()
+		}
+		err = tiler.readFurtherPasses(filePath, opts, multiPass, tree, ctx)
+	}
+	ctx.splitPass = false
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	if err := opts.Cancellation.Err(); err != nil {
+		return err
+	}
+
+	if err := tiler.prepareDataStructure(tree); err != nil {
+		return err
+	}
+	reportSplitTiles(splitTree)
+	if budgetedTree != nil {
+		reportSizeBudget(budgetedTree)
+	}
+	if quarantineTree != nil {
+		if err := reportQuarantinedPoints(quarantineTree, getFilenameWithoutExtension(filePath)+flaggedTilesetSuffix, opts, ctx); err != nil {
+			return err
+		}
+	}
+	if root := tree.GetRootNode(); root == nil || root.TotalNumberOfPoints() == 0 {
+		return nil
+	}
+	// the scans of the flagged tileset count its own points
+	if ctx.scans != nil {
+		opts = getScanOptions(opts, ctx.scans)
+	}
+	if err := tiler.exportToCesiumTileset(tree, opts, getFilenameWithoutExtension(filePath)+flaggedTilesetSuffix, ctx); err != nil {
+		return err
+	}
+	return getReadError(baseTree)
 }
 
-func (tiler *Tiler) prepareDataStructure(octree octree.ITree) {
-	// Build tree hierarchical structure
-	tools.LogOutput("> building data structure...")
-	err := octree.Build()
+// Wraps the given tree so that the points that cannot be placed on the Earth or lie outside of the validity area are
+// quarantined
+func (tiler *Tiler) newQuarantineTree(tree octree.ITree, opts *tiler.TilerOptions) *quarantine.Tree {
+	return quarantine.NewQuarantineTree(
+		tree,
+		tiler.algorithmManager.GetCoordinateConverterAlgorithm(),
+		tiler.algorithmManager.GetElevationCorrectionAlgorithm(),
+		opts.ValidityArea,
+	)
+}
+
+// Returns true if the points whose coordinates cannot be transformed are set aside rather than tiled, either to be
+// reported or because the recoverable errors do not abort the run
+func quarantinesPoints(opts *tiler.TilerOptions) bool {
+	return opts.Quarantine || opts.ErrorPolicy == tiler.ErrorPolicyContinue
+}
 
+// Logs the number of quarantined points by reason, writing their report named after the given tileset if any. The
+// points are only logged as skipped if they are quarantined because of the error policy.
+func reportQuarantinedPoints(quarantineTree *quarantine.Tree, name string, opts *tiler.TilerOptions, ctx *processingContext) error {
+	counts := quarantineTree.GetCounts()
+	if len(counts) == 0 {
+		return nil
+	}
+	reasons := make([]string, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, string(reason))
+	}
+	sort.Strings(reasons)
+	action := "quarantined"
+	if !opts.Quarantine {
+		action = "skipped"
+	}
+	for _, reason := range reasons {
+		tools.LogOutput("> WARNING:", action, counts[quarantine.Reason(reason)], "points of", name, "as", reason)
+	}
+	if !opts.Quarantine {
+		return nil
+	}
+	reportPath := quarantine.GetReportPath(opts.Output, name)
+	tools.LogOutput("> quarantined points listed in", reportPath)
+	return quarantineTree.WriteReport(ctx.storage, reportPath)
+}
+
+// Returns true if the input files that cannot be tiled are skipped rather than aborting the run, which they still do
+// if the run has been cancelled
+func skipsFailedFiles(opts *tiler.TilerOptions) bool {
+	return opts.ErrorPolicy == tiler.ErrorPolicyContinue && opts.Cancellation.Err() == nil
+}
+
+// Logs the summary of the recoverable errors skipped during the run, returning an error if none of the given number
+// of input files could be tiled
+func reportFailures(fileCount int, opts *tiler.TilerOptions, ctx *processingContext) error {
+	if opts.ErrorPolicy != tiler.ErrorPolicyContinue {
+		return nil
+	}
+	summary := ctx.stats.GetSummary()
+	var skippedPoints int64
+	for _, fileStats := range summary.Files {
+		skippedPoints += fileStats.QuarantinedPoints
+	}
+	if skippedPoints > 0 && !opts.Quarantine {
+		tools.LogOutput("WARNING: skipped", skippedPoints, "points whose coordinates cannot be transformed")
+	}
+	if summary.FailedFiles == 0 {
+		return nil
+	}
+	tools.LogOutput("WARNING: skipped", summary.FailedFiles, "of", fileCount, "files that cannot be tiled:")
+	for _, fileStats := range summary.Files {
+		if fileStats.Error != "" {
+			tools.LogOutput("-", fileStats.File+":", fileStats.Error)
+		}
+	}
+	if summary.FailedFiles == fileCount {
+		return errors.New("none of the " + strconv.Itoa(fileCount) + " input files could be tiled")
+	}
+	return nil
+}
+
+// Wraps the given tree so that its redundant tiles are pruned, if pruning is enabled
+func getPrunedTree(tree octree.ITree, opts *tiler.TilerOptions) octree.ITree {
+	if opts.PruneScreenError > 0 {
+		return octree.NewPrunedTree(tree, octree.PruningGeometricError(opts.PruneScreenError, opts.PruneDistance))
+	}
+	return tree
+}
+
+// Wraps the given tree so that its levels are thinned to fit in the size budget, estimating the size of the points from
+// the options and the supplementary attributes joined to them
+func newBudgetedTree(tree octree.ITree, opts *tiler.TilerOptions, ctx *processingContext) *octree.BudgetedTree {
+	return octree.NewBudgetedTree(tree, opts.SizeBudget, estimatePointSize(opts, ctx), io.EstimateTileOverhead(opts))
+}
+
+// Wraps the given tree so that its tiles exceeding the max tile size are split, the tiles exceeding the max size of
+// the tile contents being split even if no max tile size is given
+func newSplitTree(tree octree.ITree, opts *tiler.TilerOptions, ctx *processingContext) *octree.SplitTree {
+	maxSize := int64(io.MaxContentSize)
+	if opts.SplitTileSize > 0 && opts.SplitTileSize < maxSize {
+		maxSize = opts.SplitTileSize
+	}
+	return octree.NewSplitTree(tree, maxSize, estimatePointSize(opts, ctx), io.EstimateTileOverhead(opts))
+}
+
+// Logs the number of tiles exceeding the max tile size that have been split, which requests the root of the tree
+func reportSplitTiles(tree *octree.SplitTree) {
+	tree.GetRootNode()
+	split, created := tree.GetSplitTiles()
+	if split > 0 {
+		tools.LogOutput(fmt.Sprintf("> split %d tiles exceeding %d points into their octants, creating %d new tiles", split, tree.GetMaxPoints(), created))
+	}
+}
+
+// Wraps the given tree so that its small sibling leaf tiles are bundled, if bundling is enabled
+func getBundledTree(tree octree.ITree, opts *tiler.TilerOptions, ctx *processingContext) octree.ITree {
+	if opts.BundleThreshold > 0 {
+		return octree.NewBundledTree(tree, opts.BundleThreshold, opts.BundleMaxSize, estimatePointSize(opts, ctx), io.EstimateTileOverhead(opts))
+	}
+	return tree
+}
+
+// Returns the estimated size in bytes of every point written with the options and the supplementary attributes joined
+// to the points
+func estimatePointSize(opts *tiler.TilerOptions, ctx *processingContext) int64 {
+	attributes := len(opts.AttributeNames)
+	if names := getAttributeNames(opts, ctx); len(names) > 0 {
+		attributes = len(names)
+	}
+	return io.EstimatePointSize(opts, attributes)
+}
+
+// Returns the names of the supplementary attributes of the points, i.e. the LAS attributes of the options followed by
+// the attributes of the sidecar table of the file, if any
+func getAttributeNames(opts *tiler.TilerOptions, ctx *processingContext) []string {
+	names := append([]string{}, opts.LasAttributes...)
+	if ctx.sidecar != nil {
+		names = append(names, ctx.sidecar.Names...)
+	}
+	if ctx.scans != nil {
+		names = append(names, scans.AttributeName)
+	}
+	return names
+}
+
+// Logs the retention of the points of every level allocated by the size budget and the estimated size it achieves
+func reportSizeBudget(tree *octree.BudgetedTree) {
+	var size int64
+	for _, allocation := range tree.GetAllocations() {
+		size += allocation.Bytes
+		tools.LogOutput(fmt.Sprintf("> level %d: kept %d of %d points (%.2f%%) in %d tiles, %s", allocation.Depth, allocation.Retained, allocation.Points, 100*float64(allocation.Retained)/float64(allocation.Points), allocation.Tiles, tiler.FormatByteSize(allocation.Bytes)))
+	}
+	tools.LogOutput(fmt.Sprintf("> estimated size of %s for a budget of %s", tiler.FormatByteSize(size), tiler.FormatByteSize(tree.GetBudget())))
+	if size > tree.GetBudget() {
+		tools.LogOutput("> WARNING: the size budget cannot be met keeping a point in every tile, consider a larger budget")
+	}
+}
+
+// Exports the tree of every classification of the given layered tree in its own tileset, named after the class, in
+// the folder of the given name, together with an overview tileset referencing all of them
+func (tiler *Tiler) exportClassLayers(layers *octree.LayeredTree, opts *tiler.TilerOptions, name string, ctx *processingContext) error {
+	var overviewLayers []io.OverviewLayer
+	for _, classification := range layers.GetClassifications() {
+		layer := layers.GetLayer(classification)
+		root := layer.GetRootNode()
+		if root == nil || root.TotalNumberOfPoints() == 0 {
+			continue
+		}
+		layerName := data.GetClassificationName(classification)
+		tools.LogOutput("> exporting layer", layerName+"...")
+		if err := tiler.exportTreeAsTileset(opts, layer, path.Join(name, layerName), ctx.storage); err != nil {
+			return err
+		}
+		overviewLayers = append(overviewLayers, io.OverviewLayer{Name: layerName, Uri: layerName + "/tileset.json", Root: root})
+	}
+	if len(overviewLayers) == 0 {
+		tools.LogOutput("> no points to tile in", name, "skipping")
+		return nil
+	}
+
+	content, err := io.GenerateOverviewTileset(overviewLayers, tiler.algorithmManager.GetCoordinateConverterAlgorithm(), opts.RefineMode)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	return ctx.storage.WriteFile(path.Join(opts.Output, name, "tileset.json"), content, 0666)
 }
 
-func (tiler *Tiler) exportToCesiumTileset(octree octree.ITree, opts *tiler.TilerOptions, fileName string) {
-	tools.LogOutput("> exporting data...")
-	err := tiler.exportTreeAsTileset(opts, octree, fileName)
+// Returns true if the given counts include points of a flag whose points have to be split in their own tileset
+func hasPointsToSplit(flagCounts *las_reader.FlagCounts, opts *tiler.TilerOptions) bool {
+	return (flagCounts.Withheld > 0 && opts.WithheldPoints == tiler.FlaggedPointsSplit) ||
+		(flagCounts.Synthetic > 0 && opts.SyntheticPoints == tiler.FlaggedPointsSplit) ||
+		(flagCounts.KeyPoint > 0 && opts.KeyPoints == tiler.FlaggedPointsSplit)
+}
+
+// Logs the number of flagged points read from a file along with their handling, recording them in its statistics
+func reportFlaggedPoints(flagCounts *las_reader.FlagCounts, opts *tiler.TilerOptions, fileStats *stats.FileStats) {
+	fileStats.WithheldPoints = flagCounts.Withheld
+	fileStats.SyntheticPoints = flagCounts.Synthetic
+	fileStats.KeyPoints = flagCounts.KeyPoint
+	if flagCounts.Withheld > 0 {
+		tools.LogOutput("> withheld points:", flagCounts.Withheld, getFlaggedPointsMode(opts.WithheldPoints))
+	}
+	if flagCounts.Synthetic > 0 {
+		tools.LogOutput("> synthetic points:", flagCounts.Synthetic, getFlaggedPointsMode(opts.SyntheticPoints))
+	}
+	if flagCounts.KeyPoint > 0 {
+		tools.LogOutput("> key-points:", flagCounts.KeyPoint, getFlaggedPointsMode(opts.KeyPoints))
+	}
+}
+
+// Records in the statistics of the file the malformed records skipped while reading it, logging them if any
+func reportRecoveredRecords(recoveryCounts *lidario.RecoveryCounts, fileStats *stats.FileStats) {
+	fileStats.SkippedRecords = recoveryCounts.Skipped
+	fileStats.RecoveredRecords = recoveryCounts.Recovered
+	if recoveryCounts.Skipped > 0 {
+		tools.LogOutput("> WARNING: skipped", recoveryCounts.Skipped, "malformed point records, recovered", recoveryCounts.Recovered, "records after them")
+	}
+}
+
+// Loads the table of supplementary attributes of the given input file, named after it with the csv extension in the
+// sidecar folder. Returns nil if the file has no sidecar table.
+func loadSidecarTable(filePath string, opts *tiler.TilerOptions, storage storage.Storage) (*sidecar.Table, error) {
+	tablePath := filepath.Join(opts.SidecarFolder, getFilenameWithoutExtension(filePath)+".csv")
+	table, err := sidecar.LoadTable(storage, tablePath, opts.SidecarKey)
+	if os.IsNotExist(err) {
+		tools.LogOutput("> no sidecar attributes found for", filepath.Base(filePath))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tools.LogOutput("> joining the", strings.Join(table.Names, ", "), "attributes from", tablePath)
+	return table, nil
+}
+
+// Returns true if the colors of the input files have to be checked, i.e. if invalid colors are not kept and the
+// points are not colored by source or by intensity
+func checksColors(opts *tiler.TilerOptions) bool {
+	return opts.InvalidColors != "" && opts.InvalidColors != tiler.InvalidColorsKeep && !opts.SourceColors && opts.ColorSource != tiler.ColorSourceIntensity
+}
+
+// Wraps the given tree so that the points are colored by their intensity along the ramp of the options if configured,
+// grayscale if the ramp is not valid, logging it for the given file if any
+func getIntensityColorTree(tree octree.ITree, opts *tiler.TilerOptions, filePath string) octree.ITree {
+	if opts.ColorSource != tiler.ColorSourceIntensity {
+		return tree
+	}
+	if filePath != "" {
+		tools.LogOutput("> coloring the points of " + filepath.Base(filePath) + " by intensity")
+	}
+	ramp, err := octree.ParseColorRamp(opts.ColorRamp)
+	if err != nil {
+		ramp, _ = octree.ParseColorRamp("")
+	}
+	return octree.NewStretchedIntensityColorTree(tree, ramp, opts.IntensityRange[0], opts.IntensityRange[1])
+}
+
+// Returns true if the points of the given file have to be colored by intensity rather than by their colors, i.e. if
+// the colors come from the input files and it is made of LAS files whose point format stores no colors
+func colorsAutomatically(filePath string, opts *tiler.TilerOptions, ctx *processingContext) bool {
+	if opts.ColorSource != tiler.ColorSourceAuto || opts.SourceColors || len(opts.BridgeCommand) > 0 {
+		return false
+	}
+	files := ctx.mergedFiles
+	if files == nil {
+		files = []string{filePath}
+	}
+	for _, file := range files {
+		if !isLasFile(file) || tiler.GetReaderPlugin(opts.ReaderPlugins, file) != nil {
+			return false
+		}
+		if info, err := preflight.ReadLasFileInfo(file); err != nil || info.HasRgb {
+			return false
+		}
+	}
+	return true
+}
+
+// Reads the intensities of the points of the given file and returns the options coloring them by intensity, the
+// intensities between the stretch percentiles of the options being stretched over the whole color ramp
+func (tiler *Tiler) stretchIntensities(filePath string, opts *tiler.TilerOptions, ctx *processingContext) (*tiler.TilerOptions, error) {
+	tools.LogOutput("> no RGB in", filepath.Base(filePath), "computing the intensity stretch...")
+	histogram := octree.NewIntensityHistogram()
+	if err := tiler.readPointCloud(filePath, opts, histogram, ctx); err != nil {
+		return nil, err
+	}
+	return getStretchedColorOptions(histogram, opts), nil
+}
+
+// Returns the options coloring the points by intensity, stretched between the intensities of the given histogram at
+// the stretch percentiles of the options, between the lowest and highest intensities if they are not valid
+func getStretchedColorOptions(histogram *octree.IntensityHistogram, opts *tiler.TilerOptions) *tiler.TilerOptions {
+	low, high, err := octree.ParseIntensityStretch(opts.IntensityStretch)
+	if err != nil {
+		low, high = 0, 100
+	}
+	lowest, highest := histogram.GetPercentile(low), histogram.GetPercentile(high)
+	tools.LogOutput(fmt.Sprintf("> coloring the points by intensity stretched from %d to %d", lowest, highest))
+
+	colorOpts := *opts
+	colorOpts.ColorSource = tiler.ColorSourceIntensity
+	colorOpts.IntensityRange = [2]uint8{lowest, highest}
+	return &colorOpts
+}
+
+// Returns the options to tile the points of the given checked tree with, marking their colors as invalid if they have
+// been detected as such
+func reportInvalidColors(colorCheck *octree.ColorCheckTree, opts *tiler.TilerOptions, fileStats *stats.FileStats) *tiler.TilerOptions {
+	defect := colorCheck.GetColorDefect()
+	if defect == "" {
+		return opts
+	}
+	fileStats.InvalidColors = defect
+	if opts.InvalidColors == tiler.InvalidColorsOmit {
+		tools.LogOutput("> colors detected as", defect, "omitting them")
+	} else {
+		tools.LogOutput("> colors detected as", defect, "replacing them with the intensity")
+	}
+	colorOpts := *opts
+	colorOpts.ColorsInvalid = true
+	return &colorOpts
+}
+
+// Returns the given handling of flagged points, KEEP if not set
+func getFlaggedPointsMode(mode tiler.FlaggedPointsMode) tiler.FlaggedPointsMode {
+	if mode == "" {
+		return tiler.FlaggedPointsKeep
+	}
+	return mode
+}
+
+// Processes the given file unless a file with the same content is recorded in the ledger, in which case it is
+// reported as skipped. The ledger is saved after every file so that interrupted runs keep track of the written tilesets.
+func (tiler *Tiler) processLasFileOnce(filePath string, opts *tiler.TilerOptions, tree octree.ITree, ctx *processingContext) error {
+	hash, err := ledger.HashFile(ctx.storage, filePath)
+	if err != nil {
+		return err
+	}
+
+	if entry, ok := ctx.ledger.Lookup(hash); ok {
+		tools.LogOutput("> skipping", filepath.Base(filePath), "as duplicate of", entry.File, "written as tileset", entry.Tileset)
+		ctx.ledger.Skip(filePath, entry)
+	} else {
+		if err := tiler.processLasFile(filePath, opts, tree, ctx); err != nil {
+			return err
+		}
+		ctx.ledger.Record(hash, filePath, getFilenameWithoutExtension(filePath))
+	}
+
+	return ctx.ledger.Save(ctx.storage, getLedgerPath(opts))
+}
+
+// Wraps the given processing of the files so that the files completed by the interrupted job of the checkpoint are
+// skipped. The checkpoint is saved before and after every other file, so that the job can be resumed again.
+func getResumingProcessor(process func(filePath string, opts *tiler.TilerOptions, tree octree.ITree, ctx *processingContext) error) func(filePath string, opts *tiler.TilerOptions, tree octree.ITree, ctx *processingContext) error {
+	return func(filePath string, opts *tiler.TilerOptions, tree octree.ITree, ctx *processingContext) error {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		if entry := ctx.checkpoint.GetCompleted(filePath, info); entry != nil {
+			tools.LogOutput("> skipping", filepath.Base(filePath), "as tileset", entry.Tileset, "has been completed at", entry.Completed.Format(time.RFC3339))
+			return nil
+		}
+		if entry := ctx.checkpoint.GetInterrupted(filePath); entry != nil {
+			tools.LogOutput("> tiling", filepath.Base(filePath), "again, as it was interrupted after being started at", entry.Started.Format(time.RFC3339))
+		}
+
+		ctx.checkpoint.Start(filePath, info, getFilenameWithoutExtension(filePath))
+		if err := ctx.checkpoint.Save(ctx.storage, getCheckpointPath(opts)); err != nil {
+			return err
+		}
+		if err := process(filePath, opts, tree, ctx); err != nil {
+			return err
+		}
+		ctx.checkpoint.Complete(filePath)
+		return ctx.checkpoint.Save(ctx.storage, getCheckpointPath(opts))
+	}
+}
+
+// Transforms the control points through the conversion pipeline writing the report of their residuals in the output
+// folder. An error is returned if any residual exceeds the tolerance set in the options.
+func (tiler *Tiler) checkControlPoints(opts *tiler.TilerOptions, ctx *processingContext) error {
+	tools.LogOutput("Checking control points...")
+	points, err := accuracy.LoadControlPoints(opts.ControlPointsFile)
+	if err != nil {
+		return err
+	}
+
+	report, err := accuracy.Evaluate(
+		points,
+		opts.Srid,
+		opts.ControlPointsSrid,
+		tiler.algorithmManager.GetCoordinateConverterAlgorithm(),
+		tiler.algorithmManager.GetElevationCorrectionAlgorithm(),
+	)
+	if err != nil {
+		return err
+	}
+
+	content, err := report.ToJson()
+	if err != nil {
+		return err
+	}
+	if err := ctx.storage.WriteFile(path.Join(opts.Output, accuracy.ReportFileName), content, 0666); err != nil {
+		return err
+	}
+
+	summary := report.Summary
+	tools.LogOutput("> control points:", summary.Count, "horizontal RMSE:", formatMeters(summary.RmseHorizontal), "vertical RMSE:", formatMeters(summary.RmseVertical), "max residual:", formatMeters(summary.MaxDistance))
+	if opts.ControlPointsTolerance > 0 && summary.MaxDistance > opts.ControlPointsTolerance {
+		return errors.New("control point residuals up to " + formatMeters(summary.MaxDistance) + " exceed the tolerance, see " + accuracy.ReportFileName)
+	}
+	return nil
+}
+
+func formatMeters(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64) + " m"
+}
+
+// Analyzes the headers of the LAS files to process logging a warning for every setting that looks inconsistent with them
+func (tiler *Tiler) runPreflightChecks(files []string, opts *tiler.TilerOptions) {
+	analyzer := preflight.NewAnalyzer(tiler.algorithmManager.GetCoordinateConverterAlgorithm())
+	for _, filePath := range files {
+		if !isLasFile(filePath) {
+			continue
+		}
+		info, err := preflight.ReadLasFileInfo(filePath)
+		if err != nil {
+			tools.LogOutput("Warning:", filepath.Base(filePath), "header cannot be read:", err)
+			continue
+		}
+		for _, warning := range analyzer.Analyze(info, opts) {
+			tools.LogOutput("Warning:", filepath.Base(filePath), warning)
+		}
+	}
+}
+
+func (tiler *Tiler) readLasData(filePath string, opts *tiler.TilerOptions, tree octree.ITree, ctx *processingContext) error {
+	// Reading files
+	tools.LogOutput("> reading data from file...", filepath.Base(filePath))
+	return tiler.readPointCloud(filePath, opts, tree, ctx)
+}
+
+// Reads the given file again into the given tree as long as the given multi-pass tree, which the tree adds the points
+// to, requests further passes
+func (tiler *Tiler) readFurtherPasses(filePath string, opts *tiler.TilerOptions, multiPass octree.MultiPassTree, tree octree.ITree, ctx *processingContext) error {
+	for pass := 2; ; pass++ {
+		again, err := multiPass.EndPass()
+		if err != nil || !again {
+			return err
+		}
+		tools.LogOutput("> reading data from file again, pass", pass, "...", filepath.Base(filePath))
+		if err := tiler.readPointCloud(filePath, opts, tree, ctx); err != nil {
+			return err
+		}
+		if err := opts.Cancellation.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// Returns the first error reading back the points of the given tree if it is a multi-pass one, whose tiles are then
+// incomplete
+func getReadError(tree octree.ITree) error {
+	if multiPass, ok := tree.(octree.MultiPassTree); ok {
+		return multiPass.Err()
+	}
+	return nil
+}
+
+// Releases the resources held by the given multi-pass tree, logging the failures as the tileset has been written
+func closeMultiPassTree(multiPass octree.MultiPassTree) {
+	if err := multiPass.Close(); err != nil {
+		tools.LogOutput("> WARNING: cannot remove the spool files:", err)
+	}
+}
+
+// Samples the terrain against the points of the given file returning a copy of the options holding the vertical
+// offset that makes the point cloud sit on the terrain
+func (tiler *Tiler) clampToTerrain(filePath string, opts *tiler.TilerOptions, ctx *processingContext) (*tiler.TilerOptions, error) {
+	tools.LogOutput("> sampling terrain offset...")
+	sampler := terrain.NewOffsetSampler(
+		ctx.dem,
+		opts.TerrainSrid,
+		tiler.algorithmManager.GetCoordinateConverterAlgorithm(),
+		tiler.algorithmManager.GetElevationCorrectionAlgorithm(),
+	)
+	err := tiler.readPointCloud(filePath, opts, sampler, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := sampler.ComputeOffset()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	tools.LogOutput("> applying terrain offset of", strconv.FormatFloat(offset, 'f', 3, 64), "m")
+
+	fileOpts := *opts
+	fileOpts.TerrainOffset = offset
+	return &fileOpts, nil
+}
+
+func (tiler *Tiler) prepareDataStructure(octree octree.ITree) error {
+	// Build tree hierarchical structure
+	tools.LogOutput("> building data structure...")
+	return octree.Build()
+}
+
+func (tiler *Tiler) exportToCesiumTileset(octree octree.ITree, opts *tiler.TilerOptions, fileName string, ctx *processingContext) error {
+	tools.LogOutput("> exporting data...")
+	return tiler.exportTreeAsTileset(opts, octree, fileName, ctx.storage)
 }
 
 func getFilenameWithoutExtension(filePath string) string {
@@ -95,48 +1248,442 @@ func getFilenameWithoutExtension(filePath string) string {
 	return nameWext[0 : len(nameWext)-len(extension)]
 }
 
-// Reads the given las file and preloads data in a list of Point
-func readLas(file string, opts *tiler.TilerOptions, tree octree.ITree) error {
-	var lf *lidario.LasFile
-	var err error
-	var lasFileLoader = lidario.NewLasFileLoader(tree)
-	lf, err = lasFileLoader.LoadLasFile(file, opts.Srid)
+// Returns true if the given file is a LAS file, uncompressed or compressed as LAZ
+func isLasFile(filePath string) bool {
+	extension := strings.ToLower(filepath.Ext(filePath))
+	return extension == ".las" || extension == ".laz"
+}
+
+// Reads the given point cloud file and preloads its points in the tree, or the points of all the merged files if any
+func (tiler *Tiler) readPointCloud(file string, opts *tiler.TilerOptions, tree octree.ITree, ctx *processingContext) error {
+	if ctx.mergedFiles == nil {
+		return getPointCloudReader(file, opts, ctx).Read(file, opts.Srid, tree)
+	}
+	// every read counts the points of the scans again
+	if ctx.scans != nil {
+		ctx.scans.ResetCounts()
+	}
+	for i, mergedFile := range ctx.mergedFiles {
+		if err := opts.Cancellation.Err(); err != nil {
+			return err
+		}
+		fileTree := tree
+		if ctx.scans != nil {
+			fileTree = ctx.scans.Track(tree, i)
+		}
+		if err := getPointCloudReader(mergedFile, opts, ctx).Read(mergedFile, opts.Srid, fileTree); err != nil {
+			tools.LogOutput("> ERROR: cannot read", filepath.Base(mergedFile))
+			return err
+		}
+	}
+	return nil
+}
+
+// Loads the registry of the scans stored in the given merged files, along with their poses if a poses file is given,
+// warning about the scans without a pose
+func loadScans(files []string, opts *tiler.TilerOptions) (*scans.Registry, error) {
+	var poses map[string]*scans.Pose
+	if opts.ScanPoses != "" {
+		var err error
+		if poses, err = scans.LoadPoses(opts.ScanPoses); err != nil {
+			return nil, err
+		}
+	}
+	registry := scans.NewRegistry(files, poses)
+	for _, scan := range registry.GetScans() {
+		if poses != nil && scan.Pose == nil {
+			tools.LogOutput("> WARNING: no pose found for the scan", scan.Name)
+		}
+	}
+	return registry, nil
+}
+
+// Returns a copy of the given options listing the given scans, with the points read so far, in the extras of the root
+// tileset
+func getScanOptions(opts *tiler.TilerOptions, registry *scans.Registry) *tiler.TilerOptions {
+	scanOpts := *opts
+	scanOpts.ScanExtras = registry.GetTilesetExtras()
+	return &scanOpts
+}
+
+// Returns the reader able to parse the given file according to its extension, the bridge and the plugins taking
+// precedence over the native readers
+func getPointCloudReader(file string, opts *tiler.TilerOptions, ctx *processingContext) readers.Reader {
+	if len(opts.BridgeCommand) > 0 {
+		return plugin_reader.NewPluginReader(opts.BridgeCommand, ctx.transformer, opts.Cancellation)
+	}
+	if plugin := tiler.GetReaderPlugin(opts.ReaderPlugins, file); plugin != nil {
+		return plugin_reader.NewPluginReader(plugin.Command, ctx.transformer, opts.Cancellation)
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".bag":
+		return rosbag_reader.NewRosBagReader(opts.RosCloudTopic, opts.RosPoseTopic, ctx.transformer, ctx.storage, opts.Cancellation)
+	case ".parquet":
+		return parquet_reader.NewParquetReader(opts.ParquetColumns, ctx.transformer, ctx.storage, opts.Cancellation)
+	case ".e57":
+		return e57_reader.NewE57Reader(ctx.transformer, ctx.storage, opts.Cancellation)
+	case ".ply":
+		return ply_reader.NewPlyReader(opts.LasAttributes, ctx.transformer, ctx.storage, opts.Cancellation)
+	case ".pcd":
+		return pcd_reader.NewPcdReader(opts.LasAttributes, ctx.transformer, ctx.storage, opts.Cancellation)
+	default:
+		filter := las_reader.CombineFilters(
+			las_reader.NewAttributeFilter(opts.ExcludeOverlap, opts.Returns, opts.ScannerChannel),
+			las_reader.NewFlagFilter(opts.WithheldPoints, opts.SyntheticPoints, opts.KeyPoints, ctx.splitPass, ctx.flagCounts),
+		)
+		return las_reader.NewLasReader(ctx.transformer, ctx.storage, opts.ReadWorkers, opts.ReadChunkSize, filter, las_reader.NewLasAttributes(opts.LasAttributes, las_reader.NewSidecarAttributes(ctx.sidecar)), ctx.recoveryCounts, opts.Cancellation)
+	}
+}
+
+// Returns the transformer that georeferences the points according to the trajectory file specified in the options,
+// nil if no trajectory has been given
+func getPointTransformer(opts *tiler.TilerOptions) (readers.PointTransformer, error) {
+	if opts.TrajectoryFile == "" {
+		return nil, nil
+	}
+
+	tools.LogOutput("Loading trajectory...")
+	return trajectory.LoadTrajectory(opts.TrajectoryFile)
+}
+
+// Logs the final statistics of the tiling job and writes them in the stats.json file of the output folder
+func writeStats(collector *stats.Collector, opts *tiler.TilerOptions) error {
+	summary := collector.GetSummary()
+	tools.LogOutput("Statistics:")
+	tools.LogOutput("> points read:", summary.TotalPointsRead, "kept:", summary.TotalPointsKept)
+	tools.LogOutput("> peak memory:", tiler.FormatByteSize(int64(summary.PeakRssBytes)))
+	for _, file := range summary.Files {
+		for _, phase := range file.Phases {
+			tools.LogOutput(">", file.File, phase.Name, strconv.FormatFloat(phase.Seconds, 'f', 3, 64), "s")
+		}
+	}
+
+	return collector.WriteSummary(path.Join(opts.Output, "stats.json"))
+}
+
+// Posts the outcome of the job to the webhook, along with its statistics and run metadata. Failed deliveries are
+// logged without failing the job.
+func notifyWebhook(jobErr error, opts *tiler.TilerOptions, ctx *processingContext) {
+	tools.LogOutput("Notifying webhook...")
+	payload := webhook.NewPayload(jobErr, opts.Output, ctx.stats.GetSummary(), ctx.run)
+	if err := webhook.NewNotifier(opts.WebhookUrl, opts.WebhookSecret, webhookTimeout).Notify(payload); err != nil {
+		tools.LogOutput("> webhook notification failed:", err.Error())
+	}
+}
+
+// Writes the coverage of the given built tree as GeoJSON and KML files alongside its tileset
+func (tiler *Tiler) writeCoverage(tree octree.ITree, name string, opts *tiler.TilerOptions, ctx *processingContext) error {
+	tools.LogOutput("> writing coverage...")
+	treeCoverage, err := coverage.ComputeCoverage(tree.GetRootNode(), tiler.algorithmManager.GetCoordinateConverterAlgorithm())
+	if err != nil {
+		return err
+	}
+
+	geoJson, err := treeCoverage.ToGeoJson()
 	if err != nil {
 		return err
 	}
-	defer func() { _ = lf.Close() }()
+	if err := ctx.storage.WriteFile(path.Join(opts.Output, name, "coverage.geojson"), geoJson, 0666); err != nil {
+		return err
+	}
+
+	kml, err := treeCoverage.ToKml(name)
+	if err != nil {
+		return err
+	}
+	return ctx.storage.WriteFile(path.Join(opts.Output, name, "coverage.kml"), kml, 0666)
+}
+
+// Writes the statistics of the points of the given built tree contained in the zones of the job alongside its tileset
+func (tiler *Tiler) writeZonalStatistics(tree octree.ITree, name string, opts *tiler.TilerOptions, ctx *processingContext) error {
+	tools.LogOutput("> writing zonal statistics...")
+	statistics, err := zonal.Compute(tree.GetRootNode(), ctx.zones, tiler.algorithmManager.GetCoordinateConverterAlgorithm(), false, opts.Cancellation)
+	if err != nil {
+		return err
+	}
+	return zonal.Write(ctx.zones, statistics, path.Join(opts.Output, name), ctx.storage)
+}
+
+// Writes the availability bitmap of the given built tree alongside its tileset
+func (tiler *Tiler) writeAvailability(tree octree.ITree, name string, opts *tiler.TilerOptions, ctx *processingContext) error {
+	tools.LogOutput("> writing availability...")
+	treeAvailability := availability.Compute(tree.GetRootNode())
+	return ctx.storage.WriteFile(path.Join(opts.Output, name, availability.FileName), treeAvailability.ToBytes(), 0666)
+}
+
+// Returns true if the structure of the built trees is dumped
+func dumpsTree(opts *tiler.TilerOptions) bool {
+	return opts.TreeDump == tiler.TreeDumpJson || opts.TreeDump == tiler.TreeDumpBinary
+}
+
+// Writes the structure of the given built tree in its tileset folder, in the dump format of the options
+func writeTreeDump(tree octree.ITree, name string, opts *tiler.TilerOptions, ctx *processingContext) error {
+	tools.LogOutput("> writing tree dump...")
+	dump := treedump.Capture(tree.GetRootNode())
+	if opts.TreeDump == tiler.TreeDumpBinary {
+		return ctx.storage.WriteFile(path.Join(opts.Output, name, treedump.BinaryFileName), dump.ToBytes(), 0666)
+	}
+	content, err := dump.ToJson()
+	if err != nil {
+		return err
+	}
+	return ctx.storage.WriteFile(path.Join(opts.Output, name, treedump.JsonFileName), content, 0666)
+}
+
+// Writes the wireframe tileset of the bounding boxes of the nodes of the given built tree in its tileset folder
+func (tiler *Tiler) writeWireframe(tree octree.ITree, name string, opts *tiler.TilerOptions, ctx *processingContext) error {
+	tools.LogOutput("> writing wireframe...")
+	wireframe, err := io.GenerateWireframeTileset(tree.GetRootNode(), tiler.algorithmManager.GetCoordinateConverterAlgorithm())
+	if err != nil {
+		return err
+	}
+	folder := path.Join(opts.Output, name, io.WireframeFolder)
+	if err := ctx.storage.MkdirAll(folder, 0777); err != nil {
+		return err
+	}
+	for depth, content := range wireframe.Levels {
+		if err := ctx.storage.WriteFile(path.Join(folder, io.GetWireframeLevelFileName(depth)), content, 0666); err != nil {
+			return err
+		}
+	}
+	return ctx.storage.WriteFile(path.Join(folder, "tileset.json"), wireframe.Tileset, 0666)
+}
+
+// Writes the STAC item describing the tileset of the given built tree in its folder, recording it in the context so
+// that it can be listed by the collection
+func (tiler *Tiler) writeStacItem(tree octree.ITree, filePath string, opts *tiler.TilerOptions, ctx *processingContext) error {
+	name := getFilenameWithoutExtension(filePath)
+	root := tree.GetRootNode()
+	bounds, err := tiler.getTilesetBounds(root)
+	if err != nil {
+		return err
+	}
+
+	item := stac.NewItem(name, bounds, getAcquisitionTime(filePath), root.TotalNumberOfPoints())
+	if opts.Coverage {
+		item.AddAsset("coverage", stac.Asset{Href: "coverage.geojson", Type: "application/geo+json", Title: "Coverage", Roles: []string{"metadata"}})
+	}
+	if opts.Availability {
+		item.AddAsset("availability", stac.Asset{Href: availability.FileName, Type: "application/octet-stream", Title: "Availability", Roles: []string{"metadata"}})
+	}
+	if opts.Wireframe {
+		item.AddAsset("wireframe", stac.Asset{Href: io.WireframeFolder + "/tileset.json", Type: "application/json", Title: "Wireframe", Roles: []string{"metadata"}})
+	}
+	if opts.ThumbnailSize > 0 {
+		item.AddAsset("thumbnail", stac.Asset{Href: "../thumbnails/" + name + "/thumbnail.png", Type: "image/png", Title: "Root tile thumbnail", Roles: []string{"thumbnail"}})
+	}
+	if opts.StacCollection {
+		item.SetCollection(getOutputName(opts))
+	}
+
+	content, err := item.ToJson()
+	if err != nil {
+		return err
+	}
+	ctx.stacItems = append(ctx.stacItems, item)
+	return ctx.storage.WriteFile(path.Join(opts.Output, name, "item.json"), content, 0666)
+}
+
+// Returns the west, south, east and north longitudes and latitudes in degrees of the tileset of the given tree root,
+// followed by its min and max heights
+func (tiler *Tiler) getTilesetBounds(root octree.INode) ([6]float64, error) {
+	var bounds [6]float64
+	region, err := root.GetBoundingBoxRegion(tiler.algorithmManager.GetCoordinateConverterAlgorithm())
+	if err != nil {
+		return bounds, err
+	}
+
+	// regions are expressed in radians in the west, south, east, north, min height, max height order
+	for i, value := range region.GetAsArray() {
+		bounds[i] = value
+		if i < 4 {
+			bounds[i] = value * 180 / math.Pi
+		}
+	}
+	return bounds, nil
+}
+
+// Writes the STAC collection listing the items of all the tilesets in the output folder
+func writeStacCollection(items []*stac.Item, opts *tiler.TilerOptions, storage storage.Storage) error {
+	collection := stac.NewCollection(getOutputName(opts), "Point cloud tilesets generated by gocesiumtiler", items)
+	content, err := collection.ToJson()
+	if err != nil {
+		return err
+	}
+	return storage.WriteFile(path.Join(opts.Output, "collection.json"), content, 0666)
+}
+
+// Writes the OGC API 3D GeoVolumes documents in their folder of the output directory
+func writeGeoVolumes(api *geovolumes.Api, opts *tiler.TilerOptions, storage storage.Storage) error {
+	documents, err := api.Documents()
+	if err != nil {
+		return err
+	}
+
+	folder := path.Join(opts.Output, geovolumes.Folder)
+	if err := storage.MkdirAll(folder, 0777); err != nil {
+		return err
+	}
+	for name, content := range documents {
+		if err := storage.WriteFile(path.Join(folder, name), content, 0666); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Returns the path of the checkpoint of the job in the output folder
+func getCheckpointPath(opts *tiler.TilerOptions) string {
+	return path.Join(opts.Output, checkpoint.FileName)
+}
+
+// Returns the path of the ledger of the files processed in the output folder
+func getLedgerPath(opts *tiler.TilerOptions) string {
+	return path.Join(opts.Output, ledger.FileName)
+}
+
+// Returns the name of the output folder, naming the STAC collection and the GeoVolumes API
+func getOutputName(opts *tiler.TilerOptions) string {
+	return filepath.Base(filepath.Clean(opts.Output))
+}
+
+// Returns the acquisition time of the points of the given file, i.e. the creation day recorded in the header of LAS
+// files, or the current time if not known
+func getAcquisitionTime(filePath string) time.Time {
+	if isLasFile(filePath) {
+		if info, err := preflight.ReadLasFileInfo(filePath); err == nil && !info.CreationDate.IsZero() {
+			return info.CreationDate
+		}
+	}
+	return time.Now()
+}
+
+// Returns the bounds of the density map drawn by the dashboard, read from the header of LAS files. Nil is returned
+// if the bounds are not known in advance, or if the points are moved by a trajectory
+func getDensityMapBounds(filePath string, transformer readers.PointTransformer) *geometry.BoundingBox {
+	if transformer != nil || !isLasFile(filePath) {
+		return nil
+	}
+	info, err := preflight.ReadLasFileInfo(filePath)
+	if err != nil {
+		return nil
+	}
+
+	return geometry.NewBoundingBox(info.MinX, info.MaxX, info.MinY, info.MaxY, 0, 0)
+}
+
+// Returns the number of files that can be open at the same time while tiling, derived from the file descriptors
+// limit of the process if not set in the options
+func getOpenFilesBudget(opts *tiler.TilerOptions) int {
+	if opts.MaxOpenFiles > 0 {
+		return opts.MaxOpenFiles
+	}
+	return storage.GetDefaultOpenFilesBudget()
+}
+
+// Returns the number of goroutines writing the tiles
+func getWriteWorkers(opts *tiler.TilerOptions) int {
+	return tiler.WorkersOrNumCPU(opts.WriteWorkers)
+}
+
+// Returns the function handling the stalls detected by the watchdog, which reports them and eventually aborts the job
+func getStallHandler(opts *tiler.TilerOptions) func(dumpFile string) {
+	return func(dumpFile string) {
+		// stalls are reported even in silent mode
+		fmt.Fprintln(os.Stderr, "No progress for", opts.StallTimeout, "minutes, goroutine stacks dumped to", dumpFile)
+		if opts.StallAbort {
+			fmt.Fprintln(os.Stderr, "Aborting stalled job")
+			os.Exit(1)
+		}
+	}
+}
+
+// Returns the terrain DEM the point clouds have to be clamped to, nil if no terrain has been given
+func getTerrain(opts *tiler.TilerOptions) (*terrain.Dem, error) {
+	if opts.TerrainFile == "" {
+		return nil, nil
+	}
+
+	tools.LogOutput("Loading terrain...")
+	return terrain.LoadAsciiGrid(opts.TerrainFile)
+}
+
 // Exports the data cloud represented by the given built octree into 3D tiles data structure according to the options
 // specified in the TilerOptions instance
-func (tiler *Tiler) exportTreeAsTileset(opts *tiler.TilerOptions, octree octree.ITree, subfolder string) error {
+func (tiler *Tiler) exportTreeAsTileset(opts *tiler.TilerOptions, octree octree.ITree, subfolder string, storage storage.Storage) error {
 	// if octree is not built, exit
 	if !octree.IsBuilt() {
 		return errors.New("octree not built, data structure not initialized")
 	}
 
-	// a consumer goroutine per CPU
-	numConsumers := runtime.NumCPU()
+	template, err := getUriTemplate(opts)
+	if err != nil {
+		return err
+	}
+
+	// identical tile contents are written once when deduplication is enabled, named after the uri template if any and
+	// spread in shard folders if directories are limited in size
+	var contentIndex *io.ContentIndex
+	if opts.MaxDirectoryEntries > 0 {
+		contentIndex = io.NewShardedContentIndex(opts.DeduplicateTiles, template, opts.MaxDirectoryEntries)
+	} else if opts.DeduplicateTiles || template != nil {
+		contentIndex = io.NewContentIndex(opts.DeduplicateTiles, template)
+	}
+
+	// the tile files are handed over to a pool of writers if configured, closed on return to stop them in any case
+	concurrentStorage := getConcurrentStorage(storage, opts)
+	if concurrentStorage != nil {
+		defer func() { _ = concurrentStorage.Close() }()
+		storage = concurrentStorage
+	}
+
+	compressedStorage, err := getCompressedStorage(storage, opts, contentIndex, subfolder)
+	if err != nil {
+		return err
+	}
+	// the analytics export is written as is, never compressed
+	outputStorage := storage
+	if compressedStorage != nil {
+		storage = compressedStorage
+	}
+
+	// a consumer goroutine per CPU unless configured otherwise
+	numConsumers := getWriteWorkers(opts)
 
 	// init channel where to submit work with a buffer 5 times greater than the number of consumer
 	workChannel := make(chan *io.WorkUnit, numConsumers*5)
 
-	// init channel where consumers can eventually submit errors that prevented them to finish the job
-	errorChannel := make(chan error)
+	// init channel where consumers can eventually submit errors that prevented them to finish the job, each consumer
+	// submits at most one error so that none of them blocks before the wait group is released
+	errorChannel := make(chan error, numConsumers)
 
 	var waitGroup sync.WaitGroup
 
 	// add producer to waitgroup and launch producer goroutine
 	waitGroup.Add(1)
 
+	var manifest *io.WriteManifest
 	producer := io.NewStandardProducer(opts.Output, subfolder, opts)
+	if opts.CoarseFirst {
+		manifest = io.NewWriteManifest(outputStorage, path.Join(opts.Output, subfolder))
+		producer = io.NewCoarseFirstProducer(opts.Output, subfolder, opts, manifest)
+	} else if opts.ImplicitTiling {
+		producer = io.NewImplicitProducer(opts.Output, subfolder, opts)
+	}
 	go producer.Produce(workChannel, &waitGroup, octree.GetRootNode())
 
+	newConsumer := func() *io.StandardConsumer {
+		converter := tiler.algorithmManager.GetCoordinateConverterAlgorithm()
+		if contentIndex != nil {
+			return io.NewIndexedConsumer(converter, opts.RefineMode, storage, contentIndex)
+		}
+		return io.NewStandardConsumer(converter, opts.RefineMode, storage)
+	}
+
 	// add consumers to waitgroup and launch them
 	for i := 0; i < numConsumers; i++ {
 		waitGroup.Add(1)
-		consumer := io.NewStandardConsumer(tiler.algorithmManager.GetCoordinateConverterAlgorithm(), opts.RefineMode)
+		consumer := newConsumer()
 		go consumer.Consume(workChannel, errorChannel, &waitGroup)
 	}
 
@@ -149,12 +1696,92 @@ func (tiler *Tiler) exportTreeAsTileset(opts *tiler.TilerOptions, octree octree.
 	// find if there are errors in the error channel buffer
 	withErrors := false
 	for err := range errorChannel {
-		fmt.Println(err)
+		if err != cancellation.ErrCancelled {
+			fmt.Println(err)
+		}
 		withErrors = true
 	}
+	if err := opts.Cancellation.Err(); err != nil {
+		return err
+	}
 	if withErrors {
 		return errors.New("errors raised during execution. Check console output for details")
 	}
 
+	if contentIndex != nil {
+		if err := newConsumer().WriteDeferredTilesets(); err != nil {
+			return err
+		}
+		if opts.DeduplicateTiles {
+			tools.LogOutput("> shared", contentIndex.Duplicates(), "duplicate tile contents")
+		}
+	}
+
+	if opts.ImplicitTiling {
+		if err := io.WriteSubtrees(storage, path.Join(opts.Output, subfolder), octree.GetRootNode(), opts); err != nil {
+			return err
+		}
+	}
+
+	if compressedStorage != nil {
+		if err := compressedStorage.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if concurrentStorage != nil {
+		if err := concurrentStorage.Close(); err != nil {
+			return err
+		}
+	}
+
+	if manifest != nil {
+		if err := manifest.MarkComplete(); err != nil {
+			return err
+		}
+	}
+
+	if opts.ParquetExport {
+		tools.LogOutput("> writing parquet export...")
+		folder := path.Join(opts.Output, subfolder, analytics.FolderName)
+		return analytics.ExportTree(octree.GetRootNode(), tiler.algorithmManager.GetCoordinateConverterAlgorithm(), opts.AttributeNames, folder, outputStorage, opts.Cancellation)
+	}
 	return nil
 }
+
+// Returns the uri template the tile contents have to be named after, nil if they use the default layout
+func getUriTemplate(opts *tiler.TilerOptions) (*io.UriTemplate, error) {
+	if opts.UriTemplate == "" {
+		return nil, nil
+	}
+	return io.ParseUriTemplate(opts.UriTemplate)
+}
+
+// Returns the storage writing the files with a pool of writer goroutines, or nil if they are written by the consumers
+func getConcurrentStorage(inner storage.Storage, opts *tiler.TilerOptions) *storage.ConcurrentStorage {
+	if opts.WriterConcurrency <= 0 {
+		return nil
+	}
+	return storage.NewConcurrentStorage(inner, opts.WriterConcurrency)
+}
+
+// Returns the storage compressing the tile contents of the given tileset, whose paths are assigned by the given index
+// if not nil, or nil if they are not compressed
+func getCompressedStorage(inner storage.Storage, opts *tiler.TilerOptions, contentIndex *io.ContentIndex, subfolder string) (*compression.ZstdStorage, error) {
+	if opts.Compression != tiler.CompressionZstd {
+		return nil, nil
+	}
+
+	dictionaryPath := ""
+	if opts.ZstdDictionary {
+		dictionaryPath = path.Join(opts.Output, subfolder, compression.DictionaryFileName)
+	}
+	contentFileName, bundleFileName := io.GetContentFileName(opts), io.GetBundleFileName(opts)
+	isContent := func(filePath string) bool {
+		return path.Base(filePath) == contentFileName || path.Base(filePath) == bundleFileName
+	}
+	if contentIndex != nil {
+		isContent = contentIndex.IsContent
+	}
+	return compression.NewZstdStorage(inner, isContent, dictionaryPath)
+}
@@ -0,0 +1,496 @@
+// Package tiler exposes the point cloud tiler as a library, so that tilesets can be generated by other Go programs
+// without running the command line tool. A Tiler is configured with functional options, defaulting to the defaults of
+// the command line flags, and can run any number of jobs.
+//
+//	t := tiler.New(tiler.WithSrid(32633), tiler.WithCellSizes(0.15, 5))
+//	err := t.Run(ctx, "cloud.las", "output")
+package tiler
+
+import (
+	"context"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/gh_offset_calculator"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/grid_offset_calculator"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/progress"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	options "github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/pkg"
+	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager/std_algorithm_manager"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io"
+	"math"
+	"os"
+)
+
+// Type of the events reported to the progress callback
+type EventType string
+
+const (
+	// The processing of an input file started
+	FileStarted EventType = EventType(progress.FileStarted)
+
+	// The processing of an input file finished, after all the events of its phases
+	FileFinished EventType = EventType(progress.FileFinished)
+
+	// The processing of an input file failed and the file has been skipped
+	FileFailed EventType = EventType(progress.FileFailed)
+
+	// A phase of the processing of a file, e.g. read, build or export, started
+	PhaseStarted EventType = EventType(progress.PhaseStarted)
+
+	// A phase of the processing of a file finished
+	PhaseFinished EventType = EventType(progress.PhaseFinished)
+
+	// A batch of points of the file being read has been loaded, also reported with the total once the file is read
+	PointsRead EventType = EventType(progress.PointsRead)
+
+	// The content of a tile has been written
+	TileWritten EventType = EventType(progress.TileWritten)
+)
+
+// Event reported to the progress callback while a job runs
+type Event struct {
+	// Position of the event among the ones of the job, starting from 1
+	Sequence uint64
+	Type     EventType
+	// Name of the input file being processed, set by all the events but the tile written ones
+	File string
+	// Zero based index of the file among the input files and number of input files, set by the file events
+	FileIndex int
+	FileCount int
+	// Name of the phase, set by the phase events
+	Phase string
+	// Number of points read from the file so far, set by the points read events
+	Points int64
+	// Folder of the tile, set by the tile written events
+	Tile string
+	// Error the processing of the file failed with, set by the file failed events
+	Error string
+}
+
+// A file opened for reading by a Storage
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Closer
+}
+
+// Receives the file system operations of the jobs, e.g. to write the tile contents and the tileset.json files to a
+// remote store instead of the local file system
+type Storage interface {
+	// Opens the given file for reading
+	Open(filePath string) (File, error)
+
+	// Writes the given data to the file, creating it or truncating it if it exists
+	WriteFile(filePath string, data []byte, perm os.FileMode) error
+
+	// Creates the given directory along with any missing parent
+	MkdirAll(directory string, perm os.FileMode) error
+}
+
+// Point reaching a cell of the grid algorithm, in the metric coordinates of the tree. The attributes are the values of
+// the supplementary attributes written in the batch tables, if any.
+type Point struct {
+	X              float64
+	Y              float64
+	Z              float64
+	R              uint8
+	G              uint8
+	B              uint8
+	Intensity      uint8
+	Classification uint8
+	Attributes     []float32
+}
+
+// Decides which point every cell of the grid algorithm keeps among the points reaching it, e.g. to experiment with
+// other level of detail picks than the built-in samplings
+type SamplingStrategy interface {
+	// Returns the score of the given point in the cell of the given center and side, the cell keeping the point of
+	// lowest score and pushing the other ones down to the finer levels. Called concurrently by the insert workers.
+	Score(point *Point, centerX float64, centerY float64, centerZ float64, size float64) float64
+}
+
+// Configures a Tiler
+type Option func(t *Tiler)
+
+// Tiles point clouds into Cesium 3D Tiles tilesets
+type Tiler struct {
+	opts     options.TilerOptions
+	storage  Storage
+	callback func(event Event)
+}
+
+// Instantiates a tiler configured with the given options, the other settings taking the defaults of the command line
+// flags
+func New(opts ...Option) *Tiler {
+	t := &Tiler{
+		opts: options.TilerOptions{
+			Srid:                  4326,
+			MaxNumPointsPerNode:   50000,
+			Algorithm:             options.Grid,
+			CellMinSize:           0.15,
+			CellMaxSize:           5.0,
+			RefineMode:            options.RefineModeAdd,
+			RootGeometricError:    1,
+			CoordinateFrame:       options.CoordinateFrameEcef,
+			ContentExtension:      ".pnts",
+			TerrainSrid:           4326,
+			OriginSnap:            options.OriginSnapNone,
+			ControlPointsSrid:     4326,
+			Returns:               options.ReturnsAll,
+			ScannerChannel:        -1,
+			PruneDistance:         10,
+			Compression:           options.CompressionNone,
+			ConverterCacheQuantum: 0.001,
+			LeafCapPolicy:         options.LeafCapKeepAll,
+			WithheldPoints:        options.FlaggedPointsDrop,
+			SyntheticPoints:       options.FlaggedPointsKeep,
+			KeyPoints:             options.FlaggedPointsKeep,
+			Alpha:                 options.AlphaNone,
+			GhostVoxelSize:        0.1,
+			GhostMaxDepth:         5,
+			BatchTable:            options.BatchTableBinary,
+			InvalidColors:         options.InvalidColorsKeep,
+			SidecarKey:            options.SidecarKeyIndex,
+			ErrorPolicy:           options.ErrorPolicyFail,
+			ReadChunkSize:         1000000,
+			TilesetVersion:        options.TilesetVersion10,
+			BundleMaxSize:         1 << 20,
+			SubtreeLevels:         5,
+			ColorSource:           options.ColorSourceAuto,
+			IntensityStretch:      "2,98",
+			DensityMode:           options.DensityArea,
+			DensityCellSize:       1,
+			GridSampling:          options.GridSamplingCenter,
+			TreeDump:              options.TreeDumpNone,
+		},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Sets the EPSG code of the coordinate system of the input points, 4326 by default
+func WithSrid(srid int) Option {
+	return func(t *Tiler) {
+		t.opts.Srid = srid
+	}
+}
+
+// Sets the definition of the coordinate system of the input points, replacing the one of the srid, and the name of
+// the coordinate converter backend: "proj4" by default, accepting PROJ.4 strings, or "proj" in the builds with the
+// proj tag, also accepting WKT, PROJJSON and compound codes like EPSG:32633+5773
+func WithCrs(definition string, backend string) Option {
+	return func(t *Tiler) {
+		t.opts.CrsDefinition = definition
+		t.opts.CrsBackend = backend
+	}
+}
+
+// Sets the min and max size in meters of the cells of the grid algorithm, 0.15 and 5 by default
+func WithCellSizes(min float64, max float64) Option {
+	return func(t *Tiler) {
+		t.opts.CellMinSize = min
+		t.opts.CellMaxSize = max
+	}
+}
+
+// Sets the max number of points of the tiles of the random algorithms, 50000 by default
+func WithMaxPointsPerTile(points int) Option {
+	return func(t *Tiler) {
+		t.opts.MaxNumPointsPerNode = int32(points)
+	}
+}
+
+// Sets the vertical offset in meters added to the elevation of the points and whether their ellipsoidal height is to
+// be converted to the height above the geoid
+func WithElevation(zOffset float64, geoidCorrection bool) Option {
+	return func(t *Tiler) {
+		t.opts.ZOffset = zOffset
+		t.opts.EnableGeoidZCorrection = geoidCorrection
+	}
+}
+
+// Sets the geoid model of the geoid correction, "EGM84" by default, "EGM96", "EGM2008" or "GEOID18" to interpolate
+// their grids from the assets/geoids folder, or the path of a GTX or NGS binary geoid grid
+func WithGeoidModel(model string) Option {
+	return func(t *Tiler) {
+		t.opts.GeoidModel = model
+	}
+}
+
+// Sets the point kept by every grid cell, "CENTER" by default for the point closest to the center of the cell, or
+// "RANDOM" for a random point among the ones reaching it, which avoids the regular patterns of the coarse levels,
+// "POISSON" to keep the points of every node spaced at least a cell size apart, "FIRST" for the first point reaching
+// it or "MAX" for the point of highest intensity
+func WithGridSampling(sampling string) Option {
+	return func(t *Tiler) {
+		t.opts.GridSampling = options.ParseGridSampling(sampling)
+	}
+}
+
+// Sets the strategy deciding the point kept by every grid cell, replacing the grid sampling
+func WithSamplingStrategy(strategy SamplingStrategy) Option {
+	return func(t *Tiler) {
+		t.opts.SamplingStrategy = &samplingAdapter{strategy: strategy}
+	}
+}
+
+// Sets the max density of the points, in points per square meter of ground or, if volume is true, per cubic meter, no
+// cap by default. The cells of the given size in meters holding more points keep a random subsample of them.
+func WithDensityTarget(target float64, cellSize float64, volume bool) Option {
+	return func(t *Tiler) {
+		t.opts.DensityTarget = target
+		t.opts.DensityCellSize = cellSize
+		t.opts.DensityMode = options.DensityArea
+		if volume {
+			t.opts.DensityMode = options.DensityVolume
+		}
+	}
+}
+
+// Sets whether the grid algorithm reads the input files twice and spools the points of the tiles to temporary files
+// in the given folder, in the system temporary folder if empty, so that clouds larger than the memory can be tiled
+func WithGridSpool(spool bool, folder string) Option {
+	return func(t *Tiler) {
+		t.opts.GridSpool = spool
+		t.opts.SpoolFolder = folder
+	}
+}
+
+// Sets whether the merged input files are registered scans, the index of the file of every point being written in the
+// scan_id property of the batch tables and the scans being listed in the extras of the root tileset along with their
+// registration poses, read from the given CSV file if not empty
+func WithScanIds(scanIds bool, poses string) Option {
+	return func(t *Tiler) {
+		t.opts.ScanIds = scanIds
+		t.opts.ScanPoses = poses
+	}
+}
+
+// Sets the refine mode of the tilesets, "ADD" by default, where every tile holds only the points not held by its
+// parent, or "REPLACE", where every tile holds the points of its ancestors too and is rendered in their place
+func WithRefineMode(mode string) Option {
+	return func(t *Tiler) {
+		t.opts.RefineMode = options.ParseRefineMode(mode)
+	}
+}
+
+// Sets the 3D Tiles version of the tilesets, "1.0" by default or "1.1" to write glb contents
+func WithTilesetVersion(version string) Option {
+	return func(t *Tiler) {
+		t.opts.TilesetVersion = options.ParseTilesetVersion(version)
+		if t.opts.TilesetVersion == options.TilesetVersion11 && t.opts.ContentExtension == ".pnts" {
+			t.opts.ContentExtension = ".glb"
+		}
+	}
+}
+
+// Sets the format of the dump of the structure of every built tree written alongside its tileset, "NONE" by default,
+// "JSON" for a tree.json file or "BINARY" for a compact tree.bin file, which hold the bounds and the number of points of
+// the nodes without the points and can be inspected with the -inspect-tree mode of the command line
+func WithTreeDump(format string) Option {
+	return func(t *Tiler) {
+		t.opts.TreeDump = options.ParseTreeDumpFormat(format)
+	}
+}
+
+// Sets whether the wireframe/tileset.json companion tileset, drawing the bounding boxes of the nodes of every tree as
+// glTF lines a level per tile, is written alongside every tileset, false by default
+func WithWireframe(wireframe bool) Option {
+	return func(t *Tiler) {
+		t.opts.Wireframe = wireframe
+	}
+}
+
+// Sets whether the input folders are searched recursively for input files
+func WithRecursive(recursive bool) Option {
+	return func(t *Tiler) {
+		t.opts.Recursive = recursive
+	}
+}
+
+// Sets whether the points of all the input files are merged in a single tileset named merged, the input path being
+// a folder or a list of files separated by the OS path list separator
+func WithMerge(merge bool) Option {
+	return func(t *Tiler) {
+		t.opts.Merge = merge
+	}
+}
+
+// Redirects the file system operations of the jobs to the given storage, the local file system being used by default
+func WithStorage(s Storage) Option {
+	return func(t *Tiler) {
+		t.storage = s
+	}
+}
+
+// Sets the callback receiving the progress events of the jobs. Events are delivered one at a time on the goroutines
+// of the job, hence a slow callback slows down the job.
+func WithProgress(callback func(event Event)) Option {
+	return func(t *Tiler) {
+		t.callback = callback
+	}
+}
+
+// Tiles the point cloud file, or the files in the folder, at the given input path into tilesets written in the given
+// output folder, or uploaded to the object store of the given url, e.g. s3://bucket/prefix, gs://bucket/prefix or
+// az://container/prefix, configured by the environment variables of the store. The job stops as soon as the given
+// context is done, in which case the context error is returned.
+func (t *Tiler) Run(ctx context.Context, input string, output string) error {
+	opts := t.opts
+	opts.Input = input
+	opts.Output = output
+	for _, input := range options.GetInputPaths(&opts) {
+		if info, err := os.Stat(input); err != nil {
+			return err
+		} else {
+			opts.FolderProcessing = info.IsDir()
+		}
+	}
+	if err := validateOptions(&opts, t.storage == nil); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	opts.Cancellation = cancellation.NewToken()
+	if t.callback != nil {
+		opts.Progress = progress.NewReporter(func(event progress.Event) {
+			t.callback(Event{
+				Sequence:  event.Sequence,
+				Type:      EventType(event.Type),
+				File:      event.File,
+				FileIndex: event.FileIndex,
+				FileCount: event.FileCount,
+				Phase:     event.Phase,
+				Points:    event.Points,
+				Tile:      event.Tile,
+				Error:     event.Error,
+			})
+		})
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			opts.Cancellation.Cancel()
+		case <-done:
+		}
+	}()
+
+	var s storage.Storage = storage.NewOsStorage()
+	if t.storage != nil {
+		s = &storageAdapter{t.storage}
+	}
+	err := pkg.NewTiler(tools.NewStandardFileFinder(), std_algorithm_manager.NewAlgorithmManager(&opts), s).RunTiler(&opts)
+	if err == cancellation.ErrCancelled && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Checks the options that would be rejected by the command line tool, including the existence of the output folder if
+// the tilesets are written to the local file system
+func validateOptions(opts *options.TilerOptions, local bool) error {
+	if storage.IsObjectUrl(opts.Output) {
+		if !local {
+			return errors.New("the tilesets cannot be written to an object store url through a custom storage")
+		}
+		if _, _, err := storage.NewObjectClient(opts.Output); err != nil {
+			return err
+		}
+	} else if _, err := os.Stat(opts.Output); local && err != nil {
+		return err
+	}
+	if opts.CellMinSize <= 0 || opts.CellMinSize > opts.CellMaxSize {
+		return errors.New("the min cell size should be positive and not greater than the max cell size")
+	}
+	if opts.RefineMode == "" {
+		return errors.New("the refine mode should be either ADD or REPLACE")
+	}
+	if opts.TilesetVersion == "" {
+		return errors.New("the tileset version should be either 1.0 or 1.1")
+	}
+	if opts.GridSampling == "" {
+		return errors.New("the grid sampling should be one of CENTER, RANDOM, POISSON, FIRST or MAX")
+	}
+	if opts.TreeDump == "" {
+		return errors.New("the tree dump format should be one of NONE, JSON or BINARY")
+	}
+	if opts.SamplingStrategy != nil && (opts.Algorithm != options.Grid || opts.GridSampling == options.GridSamplingPoisson) {
+		return errors.New("the sampling strategy is only supported by the grid algorithm, without the POISSON sampling")
+	}
+	if opts.DensityTarget > 0 && (opts.DensityCellSize <= 0 || opts.DensityTarget*math.Pow(opts.DensityCellSize, 2) < 1 ||
+		opts.DensityMode == options.DensityVolume && opts.DensityTarget*math.Pow(opts.DensityCellSize, 3) < 1) {
+		return errors.New("the cells of the density target should be large enough to hold a point at the target density")
+	}
+	if opts.GridSpool && opts.DensityTarget > 0 {
+		return errors.New("the density target is not supported by the spooled grid")
+	}
+	if opts.ScanIds && !opts.Merge {
+		return errors.New("the scan ids require the input files to be merged")
+	}
+	if opts.ScanPoses != "" {
+		if _, err := os.Stat(opts.ScanPoses); !opts.ScanIds || err != nil {
+			return errors.New("the scan poses require the scan ids and an existing poses file")
+		}
+	}
+	if !grid_offset_calculator.IsDefaultGeoidModel(opts.GeoidModel) {
+		if err := grid_offset_calculator.CheckModelGrid(opts.GeoidModel); err != nil {
+			return err
+		}
+	} else if options.IsGeoidCorrected(opts) {
+		if err := gh_offset_calculator.CheckGravitationalModel(); err != nil {
+			return err
+		}
+	}
+	converter, err := converters.NewCoordinateConverter(opts.CrsBackend, options.GetCrsDefinitions(opts))
+	if err != nil {
+		return err
+	}
+	converter.Cleanup()
+	return nil
+}
+
+// Adapts a Storage to the storage of the jobs
+type storageAdapter struct {
+	storage Storage
+}
+
+func (s *storageAdapter) Open(filePath string) (storage.File, error) {
+	return s.storage.Open(filePath)
+}
+
+func (s *storageAdapter) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	return s.storage.WriteFile(filePath, data, perm)
+}
+
+func (s *storageAdapter) MkdirAll(directory string, perm os.FileMode) error {
+	return s.storage.MkdirAll(directory, perm)
+}
+
+// Adapts a SamplingStrategy to the strategy of the grid cells
+type samplingAdapter struct {
+	strategy SamplingStrategy
+}
+
+func (s *samplingAdapter) Score(point *data.Point, centerX float64, centerY float64, centerZ float64, size float64) float64 {
+	return s.strategy.Score(&Point{
+		X:              point.X,
+		Y:              point.Y,
+		Z:              point.Z,
+		R:              point.R,
+		G:              point.G,
+		B:              point.B,
+		Intensity:      point.Intensity,
+		Classification: point.Classification,
+		Attributes:     point.Attributes,
+	}, centerX, centerY, centerZ, size)
+}
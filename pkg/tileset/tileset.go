@@ -0,0 +1,87 @@
+// Package tileset reads the tilesets generated by the tiler, so that other Go programs, e.g. measurement tools, can
+// query their points. A tileset is opened from its tileset.json file, or the folder holding it, and its nested
+// tilesets and tile contents are loaded the first time a query needs them, then kept in memory.
+//
+//	t, err := tileset.Open("output/cloud")
+//	points, err := t.Nearest(12.4923, 41.8902, 35, 5, tileset.FullResolution)
+package tileset
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/compression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/picking"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"os"
+	"path"
+)
+
+// Level of detail made of all the tiles of the tileset
+const FullResolution = picking.FullResolution
+
+// Point of a tileset, in EPSG:4326 coordinates with ellipsoidal heights
+type Point struct {
+	Longitude      float64
+	Latitude       float64
+	Height         float64
+	Classification uint8
+	Intensity      uint8
+	R, G, B        uint8
+	// Depth of the tile the point has been picked from, 0 for the root
+	Level int
+	// Distance in meters of the point from the queried position
+	Distance float64
+}
+
+// A tileset generated by the tiler, opened for querying. Queries can be run concurrently.
+type Tileset struct {
+	root *io.TilesetNode
+}
+
+// Opens the tileset at the given path, either its tileset.json file or the folder holding it, decompressing its tile
+// contents with the dictionaries found in its folder, if any
+func Open(tilesetPath string) (*Tileset, error) {
+	if info, err := os.Stat(tilesetPath); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		tilesetPath = path.Join(tilesetPath, "tileset.json")
+	}
+	decoder, err := compression.LoadDecoder(path.Dir(tilesetPath))
+	if err != nil {
+		return nil, err
+	}
+	root, err := io.MountTileset(tilesetPath, storage.NewOsStorage(), decoder.Decode)
+	if err != nil {
+		return nil, err
+	}
+	return &Tileset{root: root}, nil
+}
+
+// Returns the given number of points closest to the given position, in EPSG:4326 degrees and meters above the
+// ellipsoid, sorted by distance. Only the points of the given level of detail are considered, i.e. the ones rendered
+// when the tiles down to the given depth are loaded, or all of them for FullResolution. The tiles farther than the
+// points found are not loaded.
+func (t *Tileset) Nearest(longitude float64, latitude float64, height float64, count int, level int) ([]Point, error) {
+	neighbors, err := picking.Nearest(t.root, longitude, latitude, height, count, level, t.root.IsReplaceRefined(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.root.Err(); err != nil {
+		return nil, err
+	}
+	points := make([]Point, len(neighbors))
+	for i, n := range neighbors {
+		points[i] = Point{
+			Longitude:      n.Longitude,
+			Latitude:       n.Latitude,
+			Height:         n.Height,
+			Classification: n.Classification,
+			Intensity:      n.Intensity,
+			R:              n.R,
+			G:              n.G,
+			B:              n.B,
+			Level:          n.Level,
+			Distance:       n.Distance,
+		}
+	}
+	return points, nil
+}
@@ -0,0 +1,23 @@
+#!/bin/sh
+# Writes in test/unit/testdata/laszip the LASzip fixtures checked by TestLaszipFixturesDecompressToTheirLasFiles: for
+# every point format from 0 to 3, a fmtN.las file of the first points of the given LAS file, its fmtN.laz compression
+# with the default chunks of LASzip and its fmtN_chunked.laz compression with chunks of 500 points, all compressed with
+# the version 2 compressor of the point formats 0 to 3. Requires las2las and laszip of LAStools on the PATH.
+#
+# usage: scripts/make_laszip_fixtures.sh input.las [points]
+set -e
+
+if [ -z "$1" ]; then
+	echo "usage: $0 input.las [points]" >&2
+	exit 1
+fi
+input="$1"
+points="${2:-2000}"
+folder="$(dirname "$0")/../test/unit/testdata/laszip"
+mkdir -p "$folder"
+
+for format in 0 1 2 3; do
+	las2las -i "$input" -subseq 0 "$points" -set_point_type "$format" -o "$folder/fmt$format.las"
+	laszip -i "$folder/fmt$format.las" -o "$folder/fmt$format.laz"
+	laszip -i "$folder/fmt$format.las" -chunk_size 500 -o "$folder/fmt${format}_chunked.laz"
+done
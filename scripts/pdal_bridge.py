@@ -0,0 +1,90 @@
+#!/usr/bin/env python3
+"""Bridge reading any point cloud format supported by PDAL for gocesiumtiler.
+
+Reads the given file with PDAL, optionally through the stages of a pipeline file, and writes its points on the
+standard output with version 2 of the GCTP stream protocol, to be used as gocesiumtiler bridge:
+
+    gocesiumtiler -input scans -folder -srid 32633 -bridge "python3 scripts/pdal_bridge.py {input}"
+    gocesiumtiler -input scans -folder -srid 32633 -bridge "python3 scripts/pdal_bridge.py --pipeline filters.json {input}"
+
+The pipeline file holds a JSON list of stages applied after the reader, e.g. a filters.reprojection to the srid of
+the job. Requires the PDAL Python bindings (pip install pdal).
+"""
+
+import argparse
+import json
+import struct
+import sys
+
+import numpy as np
+import pdal
+
+MAGIC = b"GCTP"
+VERSION = 2
+POINTS_PER_FRAME = 65536
+
+RECORD = np.dtype([
+    ("x", "<f8"), ("y", "<f8"), ("z", "<f8"),
+    ("r", "u1"), ("g", "u1"), ("b", "u1"), ("intensity", "u1"), ("classification", "u1"),
+    ("gps_time", "<f8"),
+])
+
+
+def to_uint8(values):
+    values = np.asarray(values, dtype=np.float64)
+    # 16 bit colors and intensities are scaled down
+    if values.size and values.max() > 255:
+        values = values / 256
+    return np.clip(values, 0, 255).astype(np.uint8)
+
+
+def to_records(points):
+    names = points.dtype.names
+    records = np.zeros(len(points), dtype=RECORD)
+    for field, dimension in (("x", "X"), ("y", "Y"), ("z", "Z"), ("gps_time", "GpsTime")):
+        if dimension in names:
+            records[field] = points[dimension]
+    for field, dimension in (("r", "Red"), ("g", "Green"), ("b", "Blue"), ("intensity", "Intensity")):
+        if dimension in names:
+            records[field] = to_uint8(points[dimension])
+    if "Classification" in names:
+        records["classification"] = np.clip(points["Classification"], 0, 255).astype(np.uint8)
+    return records
+
+
+def read_chunks(pipeline):
+    if pipeline.streamable:
+        yield from pipeline.iterator(chunk_size=POINTS_PER_FRAME)
+        return
+    # pipelines with blocking stages are read at once
+    pipeline.execute()
+    for points in pipeline.arrays:
+        for start in range(0, len(points), POINTS_PER_FRAME):
+            yield points[start:start + POINTS_PER_FRAME]
+
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__, formatter_class=argparse.RawDescriptionHelpFormatter)
+    parser.add_argument("--pipeline", help="JSON file holding the list of PDAL stages applied after the reader")
+    parser.add_argument("input", help="point cloud file to read")
+    args = parser.parse_args()
+
+    stages = [args.input]
+    if args.pipeline:
+        with open(args.pipeline) as pipeline_file:
+            stages += json.load(pipeline_file)
+    pipeline = pdal.Pipeline(json.dumps(stages))
+
+    out = sys.stdout.buffer
+    out.write(MAGIC + struct.pack("<I", VERSION))
+    for points in read_chunks(pipeline):
+        frame = to_records(points).tobytes()
+        if frame:
+            out.write(struct.pack("<I", len(frame)))
+            out.write(frame)
+    out.write(struct.pack("<I", 0))
+    out.flush()
+
+
+if __name__ == "__main__":
+    main()
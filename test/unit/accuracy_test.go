@@ -0,0 +1,98 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/accuracy"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/offset_elevation_corrector"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"io/ioutil"
+	"math"
+	"path"
+	"testing"
+)
+
+func TestControlPointsAreLoaded(t *testing.T) {
+	filePath := path.Join(createTempFolder(t), "control_points.csv")
+	content := "id,x,y,z,expected_x,expected_y,expected_z\n# surveyed points\ncp1,1,2,3,4,5,6\ncp2, 7, 8, 9, 10, 11, 12\n"
+	if err := ioutil.WriteFile(filePath, []byte(content), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	points, err := accuracy.LoadControlPoints(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 control points, got %d", len(points))
+	}
+	if points[1].Id != "cp2" || points[1].Source != (geometry.Coordinate{X: 7, Y: 8, Z: 9}) || points[1].Expected != (geometry.Coordinate{X: 10, Y: 11, Z: 12}) {
+		t.Errorf("Unexpected control point %+v", points[1])
+	}
+}
+
+func TestInvalidControlPointIsRejected(t *testing.T) {
+	filePath := path.Join(createTempFolder(t), "control_points.csv")
+	if err := ioutil.WriteFile(filePath, []byte("cp1,1,2,3,4,5,6\ncp2,a,2,3,4,5,6\n"), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if _, err := accuracy.LoadControlPoints(filePath); err == nil {
+		t.Errorf("Expected error loading invalid control point")
+	}
+}
+
+func TestControlPointResidualsAreReported(t *testing.T) {
+	// the mock converter is the identity and the mock elevation corrector doubles the z values, the local frame at
+	// longitude and latitude 0 has the east, north and up axes aligned to the y, z and x ones
+	points := []*accuracy.ControlPoint{
+		{Id: "exact", Source: geometry.Coordinate{X: 0, Y: 0, Z: 10}, Expected: geometry.Coordinate{X: 0, Y: 0, Z: 20}},
+		{Id: "shifted", Source: geometry.Coordinate{X: 0, Y: 0, Z: 5}, Expected: geometry.Coordinate{X: -2, Y: 0, Z: 6}},
+	}
+
+	report, err := accuracy.Evaluate(points, 4326, 4978, &mockCoordinateConverter{}, &mockElevationCorrector{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if report.Points[0].Distance != 0 {
+		t.Errorf("Expected no residual for exact control point, got %f", report.Points[0].Distance)
+	}
+	shifted := report.Points[1]
+	if shifted.East != 0 || shifted.North != 4 || shifted.Up != 2 || shifted.Horizontal != 4 {
+		t.Errorf("Unexpected residual %+v", shifted)
+	}
+
+	summary := report.Summary
+	if summary.Count != 2 || summary.MeanNorth != 2 || summary.MeanUp != 1 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if !almostEqual(summary.RmseHorizontal, math.Sqrt(8)) || !almostEqual(summary.RmseVertical, math.Sqrt(2)) || !almostEqual(summary.MaxDistance, math.Sqrt(20)) {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+}
+
+func TestControlPointHeightResidualIsVertical(t *testing.T) {
+	points := []*accuracy.ControlPoint{
+		{Id: "cp", Source: geometry.Coordinate{X: 12, Y: 42, Z: 100}, Expected: geometry.Coordinate{X: 12, Y: 42, Z: 99}},
+	}
+
+	report, err := accuracy.Evaluate(
+		points,
+		4326,
+		4326,
+		proj4_coordinate_converter.NewProj4CoordinateConverter(),
+		offset_elevation_corrector.NewOffsetElevationCorrector(0),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	residual := report.Points[0]
+	if math.Abs(residual.Up-1) > 1e-3 || residual.Horizontal > 1e-3 {
+		t.Errorf("Expected 1 meter vertical residual, got %+v", residual)
+	}
+}
+
+func almostEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
@@ -0,0 +1,131 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/analytics"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/parquet_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestExportTreeWritesAPartitionPerTile(t *testing.T) {
+	folder, err := ioutil.TempDir("", "analytics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	root := &mockNode{internalSrid: 4326, globalChildrenCount: 3, points: []*data.Point{data.NewPoint(10, 45, 100, 1, 2, 3, 4, 2)}}
+	child := &mockNode{parent: root, internalSrid: 4326, globalChildrenCount: 2, points: []*data.Point{
+		{X: 11, Y: 46, Z: 200, Classification: 6, Attributes: []float32{7}},
+		{X: 12, Y: 47, Z: 300, Classification: 6},
+	}}
+	root.children[3] = child
+	err = analytics.ExportTree(root, proj4_coordinate_converter.NewProj4CoordinateConverter(), []string{"segment"}, folder, storage.NewOsStorage(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	reader := parquet_reader.NewParquetReader("x=longitude,y=latitude,z=height", nil, storage.NewOsStorage(), nil)
+	rootTree := &mockTree{}
+	if err := reader.Read(path.Join(folder, "tile=r", "points.parquet"), 4326, rootTree); err != nil {
+		t.Fatalf("Unexpected error reading the root partition: %s", err.Error())
+	}
+	if len(rootTree.points) != 1 {
+		t.Fatalf("Expected 1 point in the root partition, got %d", len(rootTree.points))
+	}
+	assertPoint(t, rootTree, 10, 45, 100)
+	if point := rootTree.points[0]; point.R != 1 || point.G != 2 || point.B != 3 || point.Intensity != 4 || point.Classification != 2 {
+		t.Errorf("Unexpected root point attributes %v", point)
+	}
+
+	childTree := &mockTree{}
+	if err := reader.Read(path.Join(folder, "tile=r3", "points.parquet"), 4326, childTree); err != nil {
+		t.Fatalf("Unexpected error reading the child partition: %s", err.Error())
+	}
+	if len(childTree.points) != 2 {
+		t.Fatalf("Expected 2 points in the child partition, got %d", len(childTree.points))
+	}
+	assertPoint(t, childTree, 11, 46, 200)
+	if childTree.points[1].Classification != 6 {
+		t.Errorf("Expected classification 6, got %d", childTree.points[1].Classification)
+	}
+}
+
+func TestEncodeParquetWritesNullValues(t *testing.T) {
+	folder, err := ioutil.TempDir("", "analytics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	content := analytics.EncodeParquet([]*analytics.Column{
+		{Name: "x", PhysicalType: analytics.TypeDouble, Values: []float64{1, 2, 3}},
+		{Name: "y", PhysicalType: analytics.TypeDouble, Values: []float64{4, 5, 6}},
+		{Name: "z", PhysicalType: analytics.TypeFloat, Optional: true, Values: []float64{7, math.NaN(), 9}},
+		{Name: "intensity", PhysicalType: analytics.TypeInt32, Optional: true, Values: []float64{math.NaN(), 20, 30}},
+	})
+	parquetFile := path.Join(folder, "points.parquet")
+	if err := ioutil.WriteFile(parquetFile, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := &mockTree{}
+	if err := parquet_reader.NewParquetReader("", nil, storage.NewOsStorage(), nil).Read(parquetFile, 4326, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	// the point with a null coordinate is skipped
+	if len(tree.points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(tree.points))
+	}
+	assertPoint(t, tree, 1, 4, 7)
+	if tree.points[0].Intensity != 0 || tree.points[1].Intensity != 30 {
+		t.Errorf("Expected intensities 0 and 30, got %d and %d", tree.points[0].Intensity, tree.points[1].Intensity)
+	}
+	if point := tree.points[1]; point.X != 3 || point.Z != 9 {
+		t.Errorf("Expected the last point at x 3 and z 9, got %f and %f", point.X, point.Z)
+	}
+}
+
+func TestEncodeParquetSplitsLargeColumnsInPages(t *testing.T) {
+	folder, err := ioutil.TempDir("", "analytics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	// more values than a single page holds
+	rows := 1<<20 + 3
+	x, y, z := make([]float64, rows), make([]float64, rows), make([]float64, rows)
+	for i := range x {
+		x[i], y[i], z[i] = float64(i), float64(i%1000), float64(i%7)
+	}
+	content := analytics.EncodeParquet([]*analytics.Column{
+		{Name: "x", PhysicalType: analytics.TypeDouble, Values: x},
+		{Name: "y", PhysicalType: analytics.TypeDouble, Values: y},
+		{Name: "z", PhysicalType: analytics.TypeFloat, Values: z},
+	})
+	parquetFile := path.Join(folder, "points.parquet")
+	if err := ioutil.WriteFile(parquetFile, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := &mockTree{}
+	if err := parquet_reader.NewParquetReader("", nil, storage.NewOsStorage(), nil).Read(parquetFile, 4326, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(tree.points) != rows {
+		t.Fatalf("Expected %d points, got %d", rows, len(tree.points))
+	}
+	// the points are read in order across the pages
+	for _, i := range []int{0, 1 << 20, rows - 1} {
+		if point := tree.points[i]; point.X != float64(i) || point.Y != float64(i%1000) || point.Z != float64(i%7) {
+			t.Errorf("Expected point %d at (%d, %d, %d), got (%f, %f, %f)", i, i, i%1000, i%7, point.X, point.Y, point.Z)
+		}
+	}
+}
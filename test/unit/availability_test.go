@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/availability"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"testing"
+)
+
+// Builds a tree whose root has points in the octants 1 and 6, the latter having points in its octants 3 and 7 that
+// are stacked on the same cell
+func buildAvailabilityTree() *mockNode {
+	root := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(0, 4, 0, 4, 0, 4),
+		internalSrid:        32633,
+		globalChildrenCount: 3,
+	}
+	root.children[1] = &mockNode{parent: root, globalChildrenCount: 1}
+	inner := &mockNode{parent: root, globalChildrenCount: 2}
+	inner.children[3] = &mockNode{parent: inner, globalChildrenCount: 1}
+	inner.children[7] = &mockNode{parent: inner, globalChildrenCount: 1}
+	root.children[6] = inner
+	return root
+}
+
+func TestAvailabilityMarksCellsWithPoints(t *testing.T) {
+	result := availability.Compute(buildAvailabilityTree())
+
+	if result.Levels() != 3 {
+		t.Fatalf("expected 3 levels, got %d", result.Levels())
+	}
+	if result.Srid != 32633 || result.Bounds != [4]float64{0, 4, 0, 4} {
+		t.Errorf("unexpected srid %d or bounds %v", result.Srid, result.Bounds)
+	}
+	if !result.IsAvailable(0, 0, 0) {
+		t.Errorf("expected the root cell to be available")
+	}
+	if !result.IsAvailable(1, 1, 0) || !result.IsAvailable(1, 0, 1) || result.IsAvailable(1, 0, 0) || result.IsAvailable(1, 1, 1) {
+		t.Errorf("expected only the cells (1, 0) and (0, 1) of level 1 to be available")
+	}
+	// the octants 3 and 7 of the cell (0, 1) both project on its north east quadrant
+	if result.AvailableCells(2) != 1 || !result.IsAvailable(2, 1, 3) || !result.HasQuadkey(2, 11) {
+		t.Errorf("expected only the cell (1, 3) of level 2 to be available")
+	}
+	if result.IsAvailable(2, 4, 0) || result.IsAvailable(3, 0, 0) {
+		t.Errorf("expected cells outside of the quadtree not to be available")
+	}
+}
+
+func TestAvailabilityRoundTripsThroughBytes(t *testing.T) {
+	computed := availability.Compute(buildAvailabilityTree())
+	data := computed.ToBytes()
+
+	// header followed by a byte for the root and a byte for the two cells of level 1
+	if len(data) != 43+2 {
+		t.Errorf("expected 45 bytes, got %d", len(data))
+	}
+
+	parsed, err := availability.Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.Srid != computed.Srid || parsed.Bounds != computed.Bounds || parsed.Levels() != computed.Levels() {
+		t.Fatalf("expected the parsed availability to match the computed one")
+	}
+	for level := 0; level < computed.Levels(); level++ {
+		for x := uint32(0); x < 1<<uint(level); x++ {
+			for y := uint32(0); y < 1<<uint(level); y++ {
+				if parsed.IsAvailable(level, x, y) != computed.IsAvailable(level, x, y) {
+					t.Errorf("availability of cell (%d, %d) of level %d does not match", x, y, level)
+				}
+			}
+		}
+	}
+
+	if _, err := availability.Parse(data[:len(data)-1]); err == nil {
+		t.Errorf("expected an error parsing a truncated availability")
+	}
+	if _, err := availability.Parse([]byte("not an availability")); err == nil {
+		t.Errorf("expected an error parsing an invalid availability")
+	}
+}
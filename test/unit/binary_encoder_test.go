@@ -0,0 +1,128 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+func TestBinaryEncoderWritesInTheGivenByteOrder(t *testing.T) {
+	for _, order := range []tools.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		encoder := tools.NewBinaryEncoder(order, 0)
+		encoder.WriteString("pnts")
+		encoder.WriteUint32(0x01020304)
+		encoder.WriteUint64(0x0102030405060708)
+		encoder.WriteFloat32s([]float32{1.5})
+		encoder.WriteFloat64sAsFloat32s([]float64{-2.25})
+		content, err := encoder.Bytes()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+
+		expected := []byte("pnts")
+		expected = order.AppendUint32(expected, 0x01020304)
+		expected = order.AppendUint64(expected, 0x0102030405060708)
+		expected = order.AppendUint32(expected, math.Float32bits(1.5))
+		expected = order.AppendUint32(expected, math.Float32bits(-2.25))
+		if !bytes.Equal(content, expected) {
+			t.Errorf("Expected %v in %s, got %v", expected, order, content)
+		}
+	}
+}
+
+func TestBinaryEncoderPatchesReservedLengths(t *testing.T) {
+	encoder := tools.NewBinaryEncoder(binary.LittleEndian, 0)
+	length := encoder.Reserve(4)
+	encoder.WriteBytes([]byte{1, 2, 3})
+	encoder.PutLength32(length, encoder.Len())
+	content, err := encoder.Bytes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if !bytes.Equal(content, []byte{7, 0, 0, 0, 1, 2, 3}) {
+		t.Errorf("Expected the reserved length to hold the byte length, got %v", content)
+	}
+}
+
+func TestBinaryEncoderRejectsLengthsOverflowing32Bits(t *testing.T) {
+	encoder := tools.NewBinaryEncoder(binary.LittleEndian, 0)
+	encoder.WriteLength32(math.MaxUint32)
+	if _, err := encoder.Bytes(); err != nil {
+		t.Fatalf("Unexpected error writing the max length: %s", err.Error())
+	}
+	encoder.WriteLength32(math.MaxUint32 + 1)
+	if _, err := encoder.Bytes(); err == nil {
+		t.Errorf("Expected error writing a length overflowing 32 bits")
+	}
+}
+
+func TestBinaryEncoderRejectsMisalignedSections(t *testing.T) {
+	encoder := tools.NewBinaryEncoder(binary.LittleEndian, 0)
+	encoder.WriteBytes([]byte{1, 2, 3, 4})
+	encoder.RequireAlignment(4, "section")
+	if _, err := encoder.Bytes(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	encoder.RequireAlignment(8, "section")
+	if _, err := encoder.Bytes(); err == nil {
+		t.Errorf("Expected error requiring an 8-byte alignment at offset 4")
+	}
+}
+
+// Sections of random lengths padded to random boundaries, as the writers of the tile contents lay them out
+type paddedSections struct {
+	Sections   [][]byte
+	Boundaries []int
+	Paddings   []byte
+}
+
+func (paddedSections) Generate(random *rand.Rand, size int) reflect.Value {
+	var sections paddedSections
+	for i := random.Intn(size + 1); i >= 0; i-- {
+		section := make([]byte, random.Intn(3*size+1))
+		random.Read(section)
+		sections.Sections = append(sections.Sections, section)
+		sections.Boundaries = append(sections.Boundaries, []int{1, 2, 4, 8}[random.Intn(4)])
+		sections.Paddings = append(sections.Paddings, []byte{0, ' '}[random.Intn(2)])
+	}
+	return reflect.ValueOf(sections)
+}
+
+func TestBinaryEncoderPadsEverySectionToItsBoundary(t *testing.T) {
+	property := func(sections paddedSections) bool {
+		encoder := tools.NewBinaryEncoder(binary.LittleEndian, 0)
+		var starts []int
+		for i, section := range sections.Sections {
+			starts = append(starts, encoder.Len())
+			encoder.WriteBytes(section)
+			encoder.Pad(sections.Boundaries[i], sections.Paddings[i])
+			encoder.RequireAlignment(sections.Boundaries[i], "section")
+		}
+		content, err := encoder.Bytes()
+		if err != nil {
+			return false
+		}
+
+		for i, section := range sections.Sections {
+			end := len(content)
+			if i+1 < len(starts) {
+				end = starts[i+1]
+			}
+			// the section is followed by less than a boundary of padding bytes, so that it ends on the boundary
+			padding := content[starts[i]+len(section) : end]
+			if !bytes.Equal(content[starts[i]:starts[i]+len(section)], section) || end%sections.Boundaries[i] != 0 ||
+				len(padding) >= sections.Boundaries[i] || len(bytes.Trim(padding, string(sections.Paddings[i]))) != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500, Rand: rand.New(rand.NewSource(1))}); err != nil {
+		t.Error(err)
+	}
+}
@@ -118,3 +118,15 @@ func TestGetWGS84Volume(t *testing.T) {
 		}
 	}
 }
+
+func TestExtendDegenerateBounds(t *testing.T) {
+	bounds := geometry.ExtendDegenerateBounds([]float64{1, 1, 0, 10, 3, 3.5}, 2)
+
+	expected := []float64{0, 2, 0, 10, 2.25, 4.25}
+	for i := range expected {
+		if bounds[i] != expected[i] {
+			t.Errorf("Expected bounds %v, got %v", expected, bounds)
+			break
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mock storage recording the calls and the maximum number of concurrent writes
+type mockStorage struct {
+	mkdirCalls       int32
+	concurrentWrites int32
+	maxWrites        int32
+}
+
+func (s *mockStorage) Open(filePath string) (storage.File, error) {
+	return os.Open(filePath)
+}
+
+func (s *mockStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	current := atomic.AddInt32(&s.concurrentWrites, 1)
+	for {
+		max := atomic.LoadInt32(&s.maxWrites)
+		if current <= max || atomic.CompareAndSwapInt32(&s.maxWrites, max, current) {
+			break
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+	atomic.AddInt32(&s.concurrentWrites, -1)
+	return nil
+}
+
+func (s *mockStorage) MkdirAll(directory string, perm os.FileMode) error {
+	atomic.AddInt32(&s.mkdirCalls, 1)
+	return nil
+}
+
+func TestBudgetedStorageBoundsConcurrentWrites(t *testing.T) {
+	mock := &mockStorage{}
+	budgetedStorage := storage.NewBudgetedStorage(mock, 3)
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			_ = budgetedStorage.WriteFile("file", nil, 0666)
+		}()
+	}
+	waitGroup.Wait()
+
+	if mock.maxWrites > 3 {
+		t.Errorf("Expected at most 3 concurrent writes, got %d", mock.maxWrites)
+	}
+}
+
+func TestBudgetedStorageCreatesDirectoriesOnce(t *testing.T) {
+	mock := &mockStorage{}
+	budgetedStorage := storage.NewBudgetedStorage(mock, 3)
+
+	for i := 0; i < 5; i++ {
+		_ = budgetedStorage.MkdirAll(path.Join("out", "tileset", "0", "1"), 0777)
+	}
+	// parents are known to exist once a child has been created
+	_ = budgetedStorage.MkdirAll(path.Join("out", "tileset", "0"), 0777)
+	_ = budgetedStorage.MkdirAll(path.Join("out", "tileset", "0", "2"), 0777)
+
+	if mock.mkdirCalls != 2 {
+		t.Errorf("Expected 2 directory creations, got %d", mock.mkdirCalls)
+	}
+}
+
+func TestBudgetedStorageReleasesSlotOnClose(t *testing.T) {
+	filePath := path.Join(createTempFolder(t), "file")
+	if err := storage.NewOsStorage().WriteFile(filePath, []byte("data"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	budgetedStorage := storage.NewBudgetedStorage(storage.NewOsStorage(), 1)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			file, err := budgetedStorage.Open(filePath)
+			if err != nil {
+				t.Error(err)
+				break
+			}
+			_ = file.Close()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the slot to be released when the file is closed")
+	}
+}
+
+func TestDefaultOpenFilesBudgetIsPositive(t *testing.T) {
+	if storage.GetDefaultOpenFilesBudget() < 8 {
+		t.Errorf("Expected a default budget of at least 8 files, got %d", storage.GetDefaultOpenFilesBudget())
+	}
+}
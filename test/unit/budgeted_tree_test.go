@@ -0,0 +1,73 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"testing"
+)
+
+func TestBudgetedTreeKeepsAllPointsWithinBudget(t *testing.T) {
+	tree := buildBudgetedTestTree(t, 1000000)
+
+	if total := countStoredPoints(t, tree.GetRootNode()); total != 1000 {
+		t.Errorf("Expected 1000 points stored in the budgeted tree, got %d", total)
+	}
+	for _, allocation := range tree.GetAllocations() {
+		if allocation.Fraction != 1 || allocation.Retained != allocation.Points {
+			t.Errorf("Expected level %d to be kept whole, got %d of %d points", allocation.Depth, allocation.Retained, allocation.Points)
+		}
+	}
+}
+
+func TestBudgetedTreeThinsLargestLevelsToFitInBudget(t *testing.T) {
+	full := buildBudgetedTestTree(t, 1000000)
+	budgeted := buildBudgetedTestTree(t, 16000)
+
+	var size int64
+	allocations := budgeted.GetAllocations()
+	for _, allocation := range allocations {
+		size += allocation.Bytes
+	}
+	if size > 16000 {
+		t.Errorf("Expected an estimated size within the budget of 16000 bytes, got %d", size)
+	}
+	if allocations[0].Fraction != 1 {
+		t.Errorf("Expected the root level to be kept whole, got a fraction of %f", allocations[0].Fraction)
+	}
+	largest := allocations[0]
+	for _, allocation := range allocations {
+		if allocation.Points > largest.Points {
+			largest = allocation
+		}
+	}
+	if largest.Retained >= largest.Points {
+		t.Errorf("Expected the largest level to be thinned, got %d of %d points", largest.Retained, largest.Points)
+	}
+	if total := countStoredPoints(t, budgeted.GetRootNode()); total >= 1000 {
+		t.Errorf("Expected less than 1000 points stored in the budgeted tree, got %d", total)
+	}
+	if countTiles(budgeted.GetRootNode()) != countTiles(full.GetRootNode()) {
+		t.Errorf("Expected every tile to keep at least one point")
+	}
+}
+
+func buildBudgetedTestTree(t *testing.T, budget int64) *octree.BudgetedTree {
+	tree := octree.NewBudgetedTree(grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{}), budget, 17, 10)
+
+	for i := 0; i < 1000; i++ {
+		coord := &geometry.Coordinate{X: float64(i%10) * 3, Y: float64((i/10)%10) * 3, Z: float64(i / 100)}
+		tree.AddPoint(coord, 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	return tree
+}
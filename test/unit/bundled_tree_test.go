@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"testing"
+)
+
+func TestBundledTreeBundlesSmallSiblingLeaves(t *testing.T) {
+	full := buildBundledTestTree(t, 0, 0)
+	bundled := buildBundledTestTree(t, 1000000, 1000000)
+
+	if total := countStoredPoints(t, bundled.GetRootNode()); total != 1000 {
+		t.Errorf("Expected 1000 points stored in the bundled tree, got %d", total)
+	}
+	bundles := collectBundles(bundled.GetRootNode())
+	if len(bundles) == 0 {
+		t.Fatalf("Expected the small sibling leaves to be bundled")
+	}
+	if countTiles(bundled.GetRootNode()) >= countTiles(full.GetRootNode()) {
+		t.Errorf("Expected less than %d tiles after bundling, got %d", countTiles(full.GetRootNode()), countTiles(bundled.GetRootNode()))
+	}
+	for _, bundle := range bundles {
+		nodes := bundle.GetBundledNodes()
+		if len(nodes) < 2 || !bundle.IsLeaf() {
+			t.Errorf("Expected leaf bundles of at least 2 tiles, got %d tiles", len(nodes))
+		}
+		points := 0
+		box := bundle.GetBoundingBox()
+		for _, node := range nodes {
+			points += len(node.GetPoints())
+			nodeBox := node.GetBoundingBox()
+			if nodeBox.Xmin < box.Xmin || nodeBox.Xmax > box.Xmax || nodeBox.Ymin < box.Ymin || nodeBox.Ymax > box.Ymax || nodeBox.Zmin < box.Zmin || nodeBox.Zmax > box.Zmax {
+				t.Errorf("Expected the bounding box of the bundle to enclose the ones of its tiles")
+			}
+		}
+		if points != len(bundle.GetPoints()) {
+			t.Errorf("Expected the bundle to hold the %d points of its tiles, got %d", points, len(bundle.GetPoints()))
+		}
+	}
+}
+
+func TestBundledTreeKeepsLeavesAboveThreshold(t *testing.T) {
+	full := buildBundledTestTree(t, 0, 0)
+	bundled := buildBundledTestTree(t, 100, 1000000)
+
+	if bundles := collectBundles(bundled.GetRootNode()); len(bundles) != 0 {
+		t.Errorf("Expected no bundles of tiles larger than the threshold, got %d", len(bundles))
+	}
+	if countTiles(bundled.GetRootNode()) != countTiles(full.GetRootNode()) {
+		t.Errorf("Expected %d tiles, got %d", countTiles(full.GetRootNode()), countTiles(bundled.GetRootNode()))
+	}
+}
+
+func TestBundledTreeLimitsBundleSize(t *testing.T) {
+	bundled := buildBundledTestTree(t, 1000000, 1000)
+
+	bundles := collectBundles(bundled.GetRootNode())
+	if len(bundles) == 0 {
+		t.Fatalf("Expected the small sibling leaves to be bundled")
+	}
+	for _, bundle := range bundles {
+		if size := int64(len(bundle.GetBundledNodes()))*100 + int64(len(bundle.GetPoints()))*20; size > 1000 {
+			t.Errorf("Expected bundles within 1000 bytes, got %d", size)
+		}
+	}
+}
+
+// Builds a tree of 1000 points whose small sibling leaves are bundled with the given threshold and max size, estimating
+// 20 bytes per point and 100 bytes per tile, not bundled if the threshold is 0
+func buildBundledTestTree(t *testing.T, threshold int64, maxSize int64) octree.ITree {
+	tree := octree.ITree(grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{}))
+	if threshold > 0 {
+		tree = octree.NewBundledTree(tree, threshold, maxSize, 20, 100)
+	}
+
+	for i := 0; i < 1000; i++ {
+		coord := &geometry.Coordinate{X: float64(i%10) * 3, Y: float64((i/10)%10) * 3, Z: float64(i / 100)}
+		tree.AddPoint(coord, 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	return tree
+}
+
+func collectBundles(node octree.INode) []octree.BundleNode {
+	var bundles []octree.BundleNode
+	if bundle, ok := node.(octree.BundleNode); ok {
+		bundles = append(bundles, bundle)
+	}
+	for _, child := range node.GetChildren() {
+		if child != nil {
+			bundles = append(bundles, collectBundles(child)...)
+		}
+	}
+	return bundles
+}
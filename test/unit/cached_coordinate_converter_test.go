@@ -0,0 +1,76 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/cached_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"testing"
+)
+
+// Converter shifting the coordinates and counting the conversions it performs
+type countingCoordinateConverter struct {
+	mockCoordinateConverter
+	conversions int
+}
+
+func (c *countingCoordinateConverter) ConvertCoordinateSrid(sourceSrid int, targetSrid int, coord geometry.Coordinate) (geometry.Coordinate, error) {
+	c.conversions++
+	return geometry.Coordinate{X: coord.X + 1000, Y: coord.Y + 2000, Z: coord.Z + 30}, nil
+}
+
+func TestCachedConverterReusesConversionsOfTheSameHorizontalPosition(t *testing.T) {
+	inner := &countingCoordinateConverter{}
+	converter := cached_coordinate_converter.NewCachedCoordinateConverter(inner, 100, 0.001)
+
+	for i := 0; i < 10; i++ {
+		converted, err := converter.ConvertCoordinateSrid(32633, 4326, geometry.Coordinate{X: 10.0002, Y: 20, Z: float64(i)})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if converted.X != 1010.0002 || converted.Y != 2020 || converted.Z != float64(i)+30 {
+			t.Errorf("Expected (1010.0002, 2020, %f), got (%f, %f, %f)", float64(i)+30, converted.X, converted.Y, converted.Z)
+		}
+	}
+	if inner.conversions != 1 {
+		t.Errorf("Expected 1 conversion, got %d", inner.conversions)
+	}
+
+	// positions differing by more than the quantization step are converted again
+	_, _ = converter.ConvertCoordinateSrid(32633, 4326, geometry.Coordinate{X: 10.0022, Y: 20, Z: 0})
+	_, _ = converter.ConvertCoordinateSrid(32633, 3395, geometry.Coordinate{X: 10.0002, Y: 20, Z: 0})
+	if inner.conversions != 3 {
+		t.Errorf("Expected 3 conversions, got %d", inner.conversions)
+	}
+	if hitRate := converter.HitRate(); hitRate != 9.0/12 {
+		t.Errorf("Expected hit rate 0.75, got %f", hitRate)
+	}
+}
+
+func TestCachedConverterDoesNotCacheGeocentricConversions(t *testing.T) {
+	inner := &countingCoordinateConverter{}
+	converter := cached_coordinate_converter.NewCachedCoordinateConverter(inner, 100, 0.001)
+
+	for i := 0; i < 3; i++ {
+		_, _ = converter.ConvertCoordinateSrid(4326, 4978, geometry.Coordinate{X: 10, Y: 20, Z: float64(i)})
+		_, _ = converter.ConvertCoordinateSrid(4978, 4326, geometry.Coordinate{X: 10, Y: 20, Z: float64(i)})
+	}
+	if inner.conversions != 6 {
+		t.Errorf("Expected 6 conversions, got %d", inner.conversions)
+	}
+}
+
+func TestCachedConverterEvictsLeastRecentlyUsedConversions(t *testing.T) {
+	inner := &countingCoordinateConverter{}
+	// a single entry per shard
+	converter := cached_coordinate_converter.NewCachedCoordinateConverter(inner, 1, 1)
+
+	for i := 0; i < 1000; i++ {
+		_, _ = converter.ConvertCoordinateSrid(32633, 4326, geometry.Coordinate{X: float64(i), Y: 0, Z: 0})
+	}
+	inner.conversions = 0
+	for i := 0; i < 1000; i++ {
+		_, _ = converter.ConvertCoordinateSrid(32633, 4326, geometry.Coordinate{X: float64(i), Y: 0, Z: 0})
+	}
+	if inner.conversions < 1000-16 {
+		t.Errorf("Expected at most 16 cached conversions, got %d", 1000-inner.conversions)
+	}
+}
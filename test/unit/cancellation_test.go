@@ -0,0 +1,92 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestCancelledGridTreeBuildReturnsErrCancelled(t *testing.T) {
+	token := cancellation.NewToken()
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		InsertWorkers:      2,
+		Cancellation:       token,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+
+	for i := 0; i < 10000; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: float64(i % 100), Y: float64(i / 100), Z: 1}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+	token.Cancel()
+
+	if err := tree.Build(); err != cancellation.ErrCancelled {
+		t.Errorf("Expected the build of a cancelled job to return ErrCancelled, got %v", err)
+	}
+	if tree.IsBuilt() {
+		t.Errorf("Expected the tree of a cancelled job not to be built")
+	}
+}
+
+func TestCancelledConsumerDrainsWorkWithoutWriting(t *testing.T) {
+	tempdir := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	token := cancellation.NewToken()
+	token.Cancel()
+	opts := &tiler.TilerOptions{Srid: 4326, Cancellation: token}
+
+	workChannel := make(chan *io.WorkUnit, 10)
+	for i := 0; i < 10; i++ {
+		workChannel <- &io.WorkUnit{
+			Node: &mockNode{
+				boundingBox:         geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+				points:              []*data.Point{data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)},
+				internalSrid:        4326,
+				globalChildrenCount: 1,
+				localChildrenCount:  1,
+				leaf:                true,
+				opts:                opts,
+			},
+			Opts:     opts,
+			BasePath: tempdir,
+			RootPath: tempdir,
+		}
+	}
+	close(workChannel)
+	errorChannel := make(chan error, 1)
+
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage())
+	consumer.Consume(workChannel, errorChannel, &waitGroup)
+	waitGroup.Wait()
+	close(errorChannel)
+
+	if err := <-errorChannel; err != cancellation.ErrCancelled {
+		t.Errorf("Expected the consumer to report ErrCancelled, got %v", err)
+	}
+	if len(workChannel) != 0 {
+		t.Errorf("Expected the work channel to be drained, %d work units left", len(workChannel))
+	}
+	if files, _ := ioutil.ReadDir(tempdir); len(files) != 0 {
+		t.Errorf("Expected no files written for a cancelled job, found %d", len(files))
+	}
+}
+
+func TestNilTokenIsNeverCancelled(t *testing.T) {
+	var token *cancellation.Token
+	if token.IsCancelled() || token.Err() != nil {
+		t.Errorf("Expected a nil token never to be cancelled")
+	}
+}
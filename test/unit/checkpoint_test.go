@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/checkpoint"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestCheckpointKeepsCompletedFilesAcrossRuns(t *testing.T) {
+	osStorage := storage.NewOsStorage()
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	checkpointPath := path.Join(folder, checkpoint.FileName)
+	opts := &tiler.TilerOptions{Srid: 32633, CellMinSize: 0.15, Resume: true}
+	completedInfo := writeCheckpointTestFile(t, path.Join(folder, "a.las"), "points")
+	interruptedInfo := writeCheckpointTestFile(t, path.Join(folder, "b.las"), "other points")
+
+	c, matches, err := checkpoint.Load(osStorage, checkpointPath, opts)
+	if err != nil || !matches {
+		t.Fatalf("Expected an empty checkpoint, got %v", err)
+	}
+	c.Start(path.Join(folder, "a.las"), completedInfo, "a")
+	c.Complete(path.Join(folder, "a.las"))
+	c.Start(path.Join(folder, "b.las"), interruptedInfo, "b")
+	if err := c.Save(osStorage, checkpointPath); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// the number of goroutines can change when resuming
+	resumedOpts := *opts
+	resumedOpts.ReadWorkers = 2
+	reloaded, matches, err := checkpoint.Load(osStorage, checkpointPath, &resumedOpts)
+	if err != nil || !matches {
+		t.Fatalf("Expected the checkpoint to match the options, got %v", err)
+	}
+	if entry := reloaded.GetCompleted(path.Join(folder, "a.las"), completedInfo); entry == nil || entry.Tileset != "a" {
+		t.Errorf("Expected a.las to be completed, got %+v", entry)
+	}
+	if entry := reloaded.GetCompleted(path.Join(folder, "b.las"), interruptedInfo); entry != nil {
+		t.Errorf("Expected b.las not to be completed, got %+v", entry)
+	}
+	if entry := reloaded.GetInterrupted(path.Join(folder, "b.las")); entry == nil || entry.Started.IsZero() {
+		t.Errorf("Expected b.las to be interrupted, got %+v", entry)
+	}
+
+	// a completed file changed since is tiled again
+	modified := writeCheckpointTestFile(t, path.Join(folder, "a.las"), "changed points")
+	if entry := reloaded.GetCompleted(path.Join(folder, "a.las"), modified); entry != nil {
+		t.Errorf("Expected the changed a.las not to be completed, got %+v", entry)
+	}
+}
+
+func TestCheckpointOfOtherOptionsDoesNotMatch(t *testing.T) {
+	osStorage := storage.NewOsStorage()
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	checkpointPath := path.Join(folder, checkpoint.FileName)
+	opts := &tiler.TilerOptions{Srid: 32633, CellMinSize: 0.15}
+	c, _, _ := checkpoint.Load(osStorage, checkpointPath, opts)
+	if err := c.Save(osStorage, checkpointPath); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	otherOpts := *opts
+	otherOpts.CellMinSize = 0.3
+	if _, matches, err := checkpoint.Load(osStorage, checkpointPath, &otherOpts); err != nil || matches {
+		t.Errorf("Expected the checkpoint not to match other options, got %t and %v", matches, err)
+	}
+}
+
+func writeCheckpointTestFile(t *testing.T, filePath string, content string) os.FileInfo {
+	if err := ioutil.WriteFile(filePath, []byte(content), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	// the modification times of the rewritten files must differ even on file systems with a coarse resolution
+	modified := time.Now().Add(time.Duration(len(content)) * time.Second)
+	if err := os.Chtimes(filePath, modified, modified); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return info
+}
@@ -0,0 +1,33 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"testing"
+)
+
+func TestColorCheckTreeDetectsInvalidColors(t *testing.T) {
+	var tests = []struct {
+		colors   [][3]uint8
+		expected string
+	}{
+		{[][3]uint8{{0, 0, 0}, {0, 0, 0}}, "zero"},
+		{[][3]uint8{{255, 255, 255}, {255, 255, 255}}, "constant"},
+		{[][3]uint8{{0, 1, 0}, {1, 2, 3}}, "dark"},
+		{[][3]uint8{{10, 200, 30}, {40, 50, 60}}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		inner := &mockTree{}
+		tree := octree.NewColorCheckTree(inner)
+		for _, color := range test.colors {
+			tree.AddPoint(&geometry.Coordinate{}, color[0], color[1], color[2], 0, 0, 4326, nil)
+		}
+		if defect := tree.GetColorDefect(); defect != test.expected {
+			t.Errorf("Expected defect %q for colors %v, got %q", test.expected, test.colors, defect)
+		}
+		if len(inner.points) != len(test.colors) {
+			t.Errorf("Expected the points to be added to the wrapped tree")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/compatibility"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"testing"
+)
+
+func TestParseTargetReadsClientAndVersion(t *testing.T) {
+	cases := []struct {
+		value  string
+		client string
+		major  int
+		minor  int
+	}{
+		{"cesium@1.115", compatibility.CesiumJs, 1, 115},
+		{"CesiumJS@v1.97.0", compatibility.CesiumJs, 1, 97},
+		{"unreal-plugin@2.x", compatibility.CesiumUnreal, 2, 0},
+		{"unity@1", compatibility.CesiumUnity, 1, 0},
+	}
+	for _, c := range cases {
+		target, err := compatibility.ParseTarget(c.value)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %s: %s", c.value, err.Error())
+		}
+		if target.Client != c.client || target.Major != c.major || target.Minor != c.minor {
+			t.Errorf("Expected %s to be parsed as %s@%d.%d, got %s", c.value, c.client, c.major, c.minor, target.String())
+		}
+	}
+	for _, value := range []string{"cesium", "potree@1.8", "cesium@latest", "cesium@1.y"} {
+		if _, err := compatibility.ParseTarget(value); err == nil {
+			t.Errorf("Expected an error parsing %s", value)
+		}
+	}
+}
+
+func TestRestrictDisablesUnsupportedFeatures(t *testing.T) {
+	opts := &tiler.TilerOptions{
+		TilesetVersion:   tiler.TilesetVersion11,
+		ContentExtension: ".glb",
+		ImplicitTiling:   true,
+		TileMetadata:     true,
+		BundleThreshold:  32000,
+	}
+	target, _ := compatibility.ParseTarget("unreal@1.30")
+	warnings, err := compatibility.Restrict(opts, target)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if opts.TilesetVersion != tiler.TilesetVersion10 || opts.ContentExtension != ".pnts" || opts.ImplicitTiling || opts.TileMetadata {
+		t.Errorf("Expected the 3D Tiles 1.1 features to be disabled, got %+v", opts)
+	}
+	if opts.BundleThreshold != 32000 {
+		t.Errorf("Expected the bundles to be kept as cmpt contents, got %d", opts.BundleThreshold)
+	}
+	if len(warnings) != 3 {
+		t.Errorf("Expected 3 warnings, got %v", warnings)
+	}
+}
+
+func TestRestrictKeepsSupportedFeatures(t *testing.T) {
+	opts := &tiler.TilerOptions{TilesetVersion: tiler.TilesetVersion11, ContentExtension: ".glb", ImplicitTiling: true}
+	target, _ := compatibility.ParseTarget("cesium@1.115")
+	warnings, err := compatibility.Restrict(opts, target)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if opts.TilesetVersion != tiler.TilesetVersion11 || !opts.ImplicitTiling || len(warnings) != 0 {
+		t.Errorf("Expected the features to be kept, got %+v and %v", opts, warnings)
+	}
+}
+
+func TestRestrictRejectsClientsWithoutPointClouds(t *testing.T) {
+	opts := &tiler.TilerOptions{TilesetVersion: tiler.TilesetVersion10, ContentExtension: ".pnts"}
+	target, _ := compatibility.ParseTarget("unreal@1.20")
+	if _, err := compatibility.Restrict(opts, target); err == nil {
+		t.Errorf("Expected an error for a client without point clouds")
+	}
+}
@@ -0,0 +1,146 @@
+package unit
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/compression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestZstdStorageCompressesTilesWithSharedDictionary(t *testing.T) {
+	folder := createTempFolder(t)
+	dictionaryPath := path.Join(folder, compression.DictionaryFileName)
+	tiles := writeCompressedTestTiles(t, folder, dictionaryPath, 100)
+
+	if _, err := os.Stat(dictionaryPath); err != nil {
+		t.Fatalf("Expected dictionary to be written: %s", err.Error())
+	}
+	assertTilesDecoded(t, folder, tiles)
+
+	tileset, _ := ioutil.ReadFile(path.Join(folder, "tileset.json"))
+	if string(tileset) != `{"asset":{}}` {
+		t.Errorf("Expected tileset.json to be written uncompressed, got %q", tileset)
+	}
+}
+
+func TestZstdStorageWritesHeldTilesOnFlush(t *testing.T) {
+	folder := createTempFolder(t)
+	tiles := writeCompressedTestTiles(t, folder, path.Join(folder, compression.DictionaryFileName), 3)
+
+	assertTilesDecoded(t, folder, tiles)
+}
+
+func TestZstdStorageWithoutDictionary(t *testing.T) {
+	folder := createTempFolder(t)
+	tiles := writeCompressedTestTiles(t, folder, "", 3)
+
+	if _, err := os.Stat(path.Join(folder, compression.DictionaryFileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected no dictionary to be written")
+	}
+	assertTilesDecoded(t, folder, tiles)
+}
+
+func TestHandlerServesDecodedTiles(t *testing.T) {
+	folder := createTempFolder(t)
+	tiles := writeCompressedTestTiles(t, folder, path.Join(folder, compression.DictionaryFileName), 100)
+	decoder, err := compression.LoadDecoder(folder)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	server := httptest.NewServer(compression.NewHandler(folder, decoder))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/7/content.pnts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if !bytes.Equal(body, tiles["7/content.pnts"]) {
+		t.Errorf("Expected the decoded tile content to be served")
+	}
+
+	response, err = http.Get(server.URL + "/tileset.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	_ = response.Body.Close()
+	if response.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected application/json content type, got %s", response.Header.Get("Content-Type"))
+	}
+
+	response, err = http.Get(server.URL + "/missing.pnts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for missing files, got %d", response.StatusCode)
+	}
+}
+
+// Writes the given number of pnts-like tiles and a tileset.json file through a zstd storage, returning the
+// uncompressed tiles by their path relative to the folder
+func writeCompressedTestTiles(t *testing.T, folder string, dictionaryPath string, count int) map[string][]byte {
+	zstdStorage, err := compression.NewZstdStorage(storage.NewOsStorage(), func(filePath string) bool {
+		return path.Base(filePath) == "content.pnts"
+	}, dictionaryPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	random := rand.New(rand.NewSource(1))
+	tiles := make(map[string][]byte)
+	for i := 0; i < count; i++ {
+		tile := []byte(`pnts{"POINTS_LENGTH":500,"POSITION":{"byteOffset":0},"RGB":{"byteOffset":6000}}`)
+		for j := 0; j < 2000; j++ {
+			tile = append(tile, byte(random.Intn(16)), 0x42, 0x7f, byte(j%7))
+		}
+		relativePath := fmt.Sprintf("%d/content.pnts", i)
+		if err := zstdStorage.MkdirAll(path.Join(folder, fmt.Sprint(i)), 0777); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if err := zstdStorage.WriteFile(path.Join(folder, relativePath), tile, 0666); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		tiles[relativePath] = tile
+	}
+	if err := zstdStorage.WriteFile(path.Join(folder, "tileset.json"), []byte(`{"asset":{}}`), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if err := zstdStorage.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return tiles
+}
+
+func assertTilesDecoded(t *testing.T, folder string, tiles map[string][]byte) {
+	decoder, err := compression.LoadDecoder(folder)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	for relativePath, tile := range tiles {
+		content, err := ioutil.ReadFile(path.Join(folder, relativePath))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if !compression.IsCompressed(content) || len(content) >= len(tile) {
+			t.Errorf("Expected %s to be compressed", relativePath)
+		}
+		decoded, err := decoder.Decode(content)
+		if err != nil {
+			t.Fatalf("Unexpected error decoding %s: %s", relativePath, err.Error())
+		}
+		if !bytes.Equal(decoded, tile) {
+			t.Errorf("Decoded %s differs from the written content", relativePath)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mock storage whose writes block until the gate is closed
+type gatedStorage struct {
+	gate    chan struct{}
+	written int32
+}
+
+func (s *gatedStorage) Open(filePath string) (storage.File, error) {
+	return os.Open(filePath)
+}
+
+func (s *gatedStorage) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	<-s.gate
+	atomic.AddInt32(&s.written, 1)
+	return nil
+}
+
+func (s *gatedStorage) MkdirAll(directory string, perm os.FileMode) error {
+	return nil
+}
+
+func TestConcurrentStorageWritesAllTheFiles(t *testing.T) {
+	folder := createTempFolder(t)
+	concurrentStorage := storage.NewConcurrentStorage(storage.NewOsStorage(), 4)
+
+	for i := 0; i < 50; i++ {
+		if err := concurrentStorage.WriteFile(path.Join(folder, strconv.Itoa(i)), []byte(strconv.Itoa(i)), 0666); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+
+	// the files are written before being opened
+	file, err := concurrentStorage.Open(path.Join(folder, "49"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	content, _ := ioutil.ReadAll(file)
+	_ = file.Close()
+	if string(content) != "49" {
+		t.Errorf("Expected to read '49', got '%s'", string(content))
+	}
+
+	if err := concurrentStorage.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	for i := 0; i < 50; i++ {
+		if content, err := ioutil.ReadFile(path.Join(folder, strconv.Itoa(i))); err != nil || string(content) != strconv.Itoa(i) {
+			t.Errorf("Expected file %d to hold '%d', got '%s' (%v)", i, i, string(content), err)
+		}
+	}
+}
+
+func TestConcurrentStorageBlocksWritesWhenTheQueueIsFull(t *testing.T) {
+	gated := &gatedStorage{gate: make(chan struct{})}
+	concurrentStorage := storage.NewConcurrentStorage(gated, 1)
+
+	var submitted int32
+	go func() {
+		for i := 0; i < 10; i++ {
+			_ = concurrentStorage.WriteFile("file", nil, 0666)
+			atomic.AddInt32(&submitted, 1)
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// the writer holds a file and 4 more wait in its queue
+	if count := atomic.LoadInt32(&submitted); count != 5 {
+		t.Errorf("Expected 5 files submitted while the storage is blocked, got %d", count)
+	}
+
+	close(gated.gate)
+	for atomic.LoadInt32(&submitted) < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := concurrentStorage.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if gated.written != 10 {
+		t.Errorf("Expected 10 files written, got %d", gated.written)
+	}
+}
+
+func TestConcurrentStorageReportsWriteErrors(t *testing.T) {
+	faultyStorage := storage.NewFaultyStorage(storage.NewOsStorage(), storage.FaultConfig{ErrorRate: 1})
+	concurrentStorage := storage.NewConcurrentStorage(faultyStorage, 2)
+
+	if err := concurrentStorage.WriteFile(path.Join(createTempFolder(t), "file"), []byte("data"), 0666); err != nil {
+		t.Fatalf("Expected the write to be submitted, got %s", err.Error())
+	}
+	if err := concurrentStorage.Close(); err != storage.ErrInjectedFault {
+		t.Errorf("Expected injected fault on close, got %v", err)
+	}
+	if err := concurrentStorage.WriteFile("file", nil, 0666); err != storage.ErrInjectedFault {
+		t.Errorf("Expected the following writes to fail, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// Tree recording the added points, safe for concurrent use
+type lockingTree struct {
+	mockTree
+	sync.Mutex
+}
+
+func (t *lockingTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	t.Lock()
+	defer t.Unlock()
+	t.mockTree.AddPoint(coordinate, r, g, b, intensity, classification, srid, attributes)
+}
+
+func TestConcurrentTreeInsertsAllPointsBeforeBuilding(t *testing.T) {
+	inner := &lockingTree{}
+	tree := octree.NewConcurrentTree(inner, 4)
+
+	for i := 0; i < 10000; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: float64(i)}, 1, 2, 3, 4, 5, 4326, nil)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(inner.points) != 10000 {
+		t.Fatalf("expected 10000 points, got %d", len(inner.points))
+	}
+	sum := 0.0
+	for _, point := range inner.points {
+		sum += point.X
+	}
+	if sum != 10000*9999/2 {
+		t.Errorf("expected every point to be inserted once, got coordinates sum %f", sum)
+	}
+}
+
+func TestConcurrentTreeWithSingleWorkerPreservesOrder(t *testing.T) {
+	inner := &mockTree{}
+	tree := octree.NewConcurrentTree(inner, 1)
+
+	for i := 0; i < 100; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: float64(i)}, 0, 0, 0, 0, 0, 32633, nil)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, point := range inner.points {
+		if point.X != float64(i) || inner.srids[i] != 32633 {
+			t.Fatalf("expected point %d to keep its position and srid, got x %f srid %d", i, point.X, inner.srids[i])
+		}
+	}
+}
+
+func TestWorkersOrNumCPU(t *testing.T) {
+	if tiler.WorkersOrNumCPU(3) != 3 {
+		t.Errorf("expected configured workers to be used")
+	}
+	if tiler.WorkersOrNumCPU(0) != runtime.NumCPU() {
+		t.Errorf("expected one worker per CPU if not configured")
+	}
+}
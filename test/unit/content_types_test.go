@@ -0,0 +1,59 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestWriteHostConfigFiles(t *testing.T) {
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	err := io.WriteHostConfigFiles(tempdir, &tiler.TilerOptions{ContentExtension: ".bin", ExtensionlessContent: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	expected := map[string][]string{
+		"nginx-mime.conf": {"application/octet-stream bin;", "default_type application/octet-stream;"},
+		".htaccess":       {"AddType application/octet-stream .bin", "ForceType application/octet-stream"},
+		"web.config":      {"fileExtension=\".bin\" mimeType=\"application/octet-stream\"", "fileExtension=\".\" mimeType=\"application/octet-stream\""},
+	}
+
+	for file, snippets := range expected {
+		content, err := ioutil.ReadFile(path.Join(tempdir, file))
+		if err != nil {
+			t.Errorf("Expected host config file %s to exist", file)
+			continue
+		}
+		for _, snippet := range snippets {
+			if !strings.Contains(string(content), snippet) {
+				t.Errorf("Expected %s to contain %s", file, snippet)
+			}
+		}
+	}
+}
+
+func TestWriteHostConfigFilesDefaultExtension(t *testing.T) {
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	err := io.WriteHostConfigFiles(tempdir, &tiler.TilerOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	content, _ := ioutil.ReadFile(path.Join(tempdir, ".htaccess"))
+	if !strings.Contains(string(content), "AddType application/octet-stream .pnts") {
+		t.Errorf("Expected .pnts mapping in .htaccess, got %s", string(content))
+	}
+	if strings.Contains(string(content), "FilesMatch") {
+		t.Errorf("Unexpected extensionless mapping in .htaccess")
+	}
+}
@@ -0,0 +1,96 @@
+package unit
+
+import (
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/grid_offset_calculator"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestMissingResourceErrorDescribesTheExpectedPathAndHint(t *testing.T) {
+	filePath := path.Join(createTempFolder(t), "missing.txt")
+	_, err := converters.ReadResource("test resource", filePath, "provide it")
+
+	var resourceError *converters.ResourceError
+	if !errors.As(err, &resourceError) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected a resource error wrapping the missing file, got %v", err)
+	}
+	expected := "cannot read the test resource expected at " + filePath + ": no such file or directory, provide it"
+	if err.Error() != expected {
+		t.Errorf("Expected error '%s', got '%s'", expected, err.Error())
+	}
+}
+
+func TestCheckResourceRejectsEmptyFilesAndDirectories(t *testing.T) {
+	folder := createTempFolder(t)
+	emptyFile := path.Join(folder, "empty.txt")
+	if err := ioutil.WriteFile(emptyFile, nil, 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	resourceFile := path.Join(folder, "resource.txt")
+	if err := ioutil.WriteFile(resourceFile, []byte("data"), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if err := converters.CheckResource("test resource", emptyFile, ""); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("Expected error checking an empty file, got %v", err)
+	}
+	if err := converters.CheckResource("test resource", folder, ""); err == nil || !strings.Contains(err.Error(), "directory") {
+		t.Errorf("Expected error checking a directory, got %v", err)
+	}
+	if err := converters.CheckResource("test resource", resourceFile, ""); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+}
+
+func TestOnlyTransientErrorsAreRetried(t *testing.T) {
+	if !converters.IsTransientError(&os.PathError{Op: "open", Path: "file", Err: syscall.EIO}) {
+		t.Errorf("Expected i/o errors to be transient")
+	}
+	if !converters.IsTransientError(&os.PathError{Op: "open", Path: "file", Err: syscall.ESTALE}) {
+		t.Errorf("Expected stale file handles to be transient")
+	}
+	if converters.IsTransientError(&os.PathError{Op: "open", Path: "file", Err: syscall.ENOENT}) {
+		t.Errorf("Expected missing files not to be transient")
+	}
+	if converters.IsTransientError(&os.PathError{Op: "open", Path: "file", Err: syscall.EACCES}) {
+		t.Errorf("Expected denied permissions not to be transient")
+	}
+}
+
+func TestProj4ConverterReportsAMissingAssetsFolder(t *testing.T) {
+	folder := createTempFolder(t)
+	previous, set := os.LookupEnv("GOCESIUMTILER_WORKDIR")
+	_ = os.Setenv("GOCESIUMTILER_WORKDIR", folder)
+	defer func() {
+		if set {
+			_ = os.Setenv("GOCESIUMTILER_WORKDIR", previous)
+		} else {
+			_ = os.Unsetenv("GOCESIUMTILER_WORKDIR")
+		}
+	}()
+
+	_, err := proj4_coordinate_converter.NewProj4CoordinateConverterWithDefinitions(nil)
+	if err == nil {
+		t.Fatalf("Expected error without the assets folder")
+	}
+	if !strings.Contains(err.Error(), path.Join(folder, "assets", "epsg_projections.txt")) || !strings.Contains(err.Error(), "GOCESIUMTILER_WORKDIR") {
+		t.Errorf("Expected the error to give the expected path and how to override it, got %s", err.Error())
+	}
+}
+
+func TestCheckModelGridTellsWhereToCopyMissingGrids(t *testing.T) {
+	if _, err := os.Stat(grid_offset_calculator.GetModelGridPath("GEOID18")); err == nil {
+		t.Skip("the GEOID18 grid is installed")
+	}
+	err := grid_offset_calculator.CheckModelGrid("GEOID18")
+	if err == nil || !strings.Contains(err.Error(), "copy the g2018u0.bin grid into the assets/geoids folder") {
+		t.Errorf("Expected the error to tell where to copy the grid, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	_ "github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"testing"
+)
+
+func TestDefaultCoordinateConverterBackendIsRegistered(t *testing.T) {
+	converter, err := converters.NewCoordinateConverter("", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	defer converter.Cleanup()
+
+	output, err := converter.ConvertCoordinateSrid(32633, 4326, geometry.Coordinate{X: 491880.85, Y: 4576930.54, Z: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	if math.Abs(output.X-14.902954) > 5e-7 || math.Abs(output.Y-41.343825) > 5e-7 {
+		t.Errorf("Expected 14.902954, 41.343825, got %.8f, %.8f", output.X, output.Y)
+	}
+}
+
+func TestCoordinateConverterBackendUsesCustomDefinition(t *testing.T) {
+	definitions := map[int]string{900001: "+proj=utm +zone=33 +datum=WGS84 +units=m +no_defs"}
+	converter, err := converters.NewCoordinateConverter(converters.DefaultCoordinateConverterBackend, definitions)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	defer converter.Cleanup()
+
+	output, err := converter.ConvertCoordinateSrid(900001, 4326, geometry.Coordinate{X: 491880.85, Y: 4576930.54, Z: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	if math.Abs(output.X-14.902954) > 5e-7 || math.Abs(output.Y-41.343825) > 5e-7 || output.Z != 10 {
+		t.Errorf("Expected 14.902954, 41.343825, 10, got %.8f, %.8f, %.8f", output.X, output.Y, output.Z)
+	}
+}
+
+func TestProj4CoordinateConverterBackendRejectsWkt(t *testing.T) {
+	definitions := map[int]string{900001: `PROJCS["WGS 84 / UTM zone 33N",GEOGCS["WGS 84",DATUM["WGS_1984"]]]`}
+	if _, err := converters.NewCoordinateConverter(converters.DefaultCoordinateConverterBackend, definitions); err == nil {
+		t.Errorf("Expected an error for a WKT definition")
+	}
+}
+
+func TestUnknownCoordinateConverterBackendReturnsError(t *testing.T) {
+	if _, err := converters.NewCoordinateConverter("unknown", nil); err == nil {
+		t.Errorf("Expected an error for an unknown backend")
+	}
+}
+
+func TestCoordinateConverterBackendsAreListed(t *testing.T) {
+	backends := converters.GetCoordinateConverterBackends()
+	found := false
+	for _, backend := range backends {
+		found = found || backend == converters.DefaultCoordinateConverterBackend
+	}
+	if !found {
+		t.Errorf("Expected the %s backend among %v", converters.DefaultCoordinateConverterBackend, backends)
+	}
+}
@@ -0,0 +1,142 @@
+package unit
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/coverage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Builds a tree whose root spans the [0, 3] x [0, 3] square and whose leaves are the unit cells at the given positions
+func buildCoverageTree(cells ...[2]float64) *mockNode {
+	root := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(0, 3, 0, 3, 0, 1),
+		internalSrid:        4326,
+		globalChildrenCount: int64(len(cells)),
+	}
+	for i, cell := range cells {
+		root.children[i] = &mockNode{
+			parent:              root,
+			boundingBox:         geometry.NewBoundingBox(cell[0], cell[0]+1, cell[1], cell[1]+1, 0, 1),
+			internalSrid:        4326,
+			globalChildrenCount: 1,
+			localChildrenCount:  1,
+		}
+	}
+	return root
+}
+
+// Shoelace area of a ring, positive if counter clockwise
+func ringArea(ring coverage.Ring) float64 {
+	area := 0.0
+	for i := 0; i < len(ring)-1; i++ {
+		area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return area / 2
+}
+
+func TestCoverageFootprintTracesHoles(t *testing.T) {
+	root := buildCoverageTree([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{2, 0}, [2]float64{0, 1},
+		[2]float64{2, 1}, [2]float64{0, 2}, [2]float64{1, 2}, [2]float64{2, 2})
+
+	result, err := coverage.ComputeCoverage(root, coordinateConverter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Footprint) != 1 || len(result.Footprint[0]) != 2 {
+		t.Fatalf("expected a single polygon with a hole, got %v", result.Footprint)
+	}
+	outer, hole := result.Footprint[0][0], result.Footprint[0][1]
+	if len(outer) != 5 || len(hole) != 5 {
+		t.Errorf("expected rectangular rings without collinear vertices, got %v and %v", outer, hole)
+	}
+	if outer[0] != outer[len(outer)-1] {
+		t.Errorf("expected closed rings, got %v", outer)
+	}
+	if math.Abs(ringArea(outer)-9) > 1e-9 || math.Abs(ringArea(hole)+1) > 1e-9 {
+		t.Errorf("expected a counter clockwise outer ring of area 9 and a clockwise hole of area 1, got %f and %f", ringArea(outer), ringArea(hole))
+	}
+}
+
+func TestCoverageFootprintSeparatesCellsTouchingByCorner(t *testing.T) {
+	root := buildCoverageTree([2]float64{0, 0}, [2]float64{1, 1})
+
+	result, err := coverage.ComputeCoverage(root, coordinateConverter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Footprint) != 2 {
+		t.Fatalf("expected 2 polygons, got %d", len(result.Footprint))
+	}
+	for _, polygon := range result.Footprint {
+		if len(polygon) != 1 || math.Abs(ringArea(polygon[0])-1) > 1e-9 {
+			t.Errorf("expected unit squares without holes, got %v", polygon)
+		}
+	}
+}
+
+func TestCoverageLevels(t *testing.T) {
+	root := buildCoverageTree([2]float64{0, 0}, [2]float64{2, 2})
+	// a node stacked on top of another one covers the same cell
+	root.children[2] = &mockNode{
+		parent:              root,
+		boundingBox:         geometry.NewBoundingBox(0, 1, 0, 1, 1, 2),
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+	}
+
+	result, _ := coverage.ComputeCoverage(root, coordinateConverter)
+
+	if len(result.Levels) != 2 {
+		t.Fatalf("expected 2 levels, got %d", len(result.Levels))
+	}
+	if result.Levels[0].Nodes != 1 || len(result.Levels[0].Cells) != 1 {
+		t.Errorf("expected the root level to be made of a single cell, got %v", result.Levels[0])
+	}
+	if result.Levels[1].Nodes != 3 || len(result.Levels[1].Cells) != 2 {
+		t.Errorf("expected 3 nodes covering 2 cells in level 1, got %d nodes and %d cells", result.Levels[1].Nodes, len(result.Levels[1].Cells))
+	}
+}
+
+func TestCoverageEncoding(t *testing.T) {
+	root := buildCoverageTree([2]float64{0, 0}, [2]float64{1, 0})
+	result, _ := coverage.ComputeCoverage(root, coordinateConverter)
+
+	geoJson, err := result.ToGeoJson()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Properties map[string]interface{} `json:"properties"`
+			Geometry   struct {
+				Type        string          `json:"type"`
+				Coordinates [][][][]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(geoJson, &collection); err != nil {
+		t.Fatalf("invalid GeoJSON: %v", err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 3 {
+		t.Fatalf("expected a collection with the footprint and 2 levels, got %s with %d features", collection.Type, len(collection.Features))
+	}
+	footprint := collection.Features[0]
+	if footprint.Properties["name"] != "footprint" || footprint.Geometry.Type != "MultiPolygon" || len(footprint.Geometry.Coordinates) != 1 {
+		t.Errorf("unexpected footprint feature %v", footprint)
+	}
+
+	kml, err := result.ToKml("cloud")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content := string(kml)
+	if !strings.Contains(content, "<name>cloud</name>") || !strings.Contains(content, "<coordinates>0,0 2,0 2,1 0,1 0,0</coordinates>") {
+		t.Errorf("unexpected KML content %s", content)
+	}
+}
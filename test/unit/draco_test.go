@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/draco"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDracoRoundTripRestoresAttributes(t *testing.T) {
+	numPoints := 5000
+	random := rand.New(rand.NewSource(7))
+	positions := &draco.Attribute{Type: draco.Position, Components: 3, Floats: make([]float32, numPoints*3), QuantizationBits: 14}
+	fine := &draco.Attribute{Type: draco.Generic, Components: 1, Floats: make([]float32, numPoints), QuantizationBits: 30}
+	colors := &draco.Attribute{Type: draco.Color, Components: 3, Normalized: true, Bytes: make([]uint8, numPoints*3)}
+	indexes := &draco.Attribute{Type: draco.Generic, Components: 1, Floats: make([]float32, numPoints)}
+	for i := 0; i < numPoints; i++ {
+		for c := 0; c < 3; c++ {
+			positions.Floats[i*3+c] = float32(random.Float64()*200 - 100)
+			colors.Bytes[i*3+c] = uint8(random.Intn(256))
+		}
+		fine.Floats[i] = float32(random.Float64() * 10)
+		indexes.Floats[i] = float32(i)
+	}
+
+	encoded, err := draco.Encode(numPoints, []*draco.Attribute{positions, fine, colors, indexes})
+	if err != nil {
+		t.Fatalf("Unexpected error encoding the point cloud: %s", err.Error())
+	}
+	if len(encoded) >= numPoints*(12+4+3+4) {
+		t.Errorf("Expected the encoded point cloud to be smaller than its raw attributes, got %d bytes", len(encoded))
+	}
+	decoded, err := draco.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Unexpected error decoding the point cloud: %s", err.Error())
+	}
+	if decoded.NumPoints != numPoints || len(decoded.Attributes) != 4 {
+		t.Fatalf("Expected %d points with 4 attributes, got %d points with %d attributes", numPoints, decoded.NumPoints, len(decoded.Attributes))
+	}
+
+	decodedPositions, decodedFine := decoded.GetAttribute(0), decoded.GetAttribute(1)
+	decodedColors, decodedIndexes := decoded.GetAttribute(2), decoded.GetAttribute(3)
+	if decodedPositions.Type != draco.Position || decodedColors.Type != draco.Color || !decodedColors.Normalized {
+		t.Errorf("Expected the attribute semantics to be restored")
+	}
+	positionTolerance := 200.0 / (1<<14 - 1)
+	seen := make(map[int]bool)
+	for i := 0; i < numPoints; i++ {
+		index := int(decodedIndexes.Floats[i])
+		seen[index] = true
+		for c := 0; c < 3; c++ {
+			if math.Abs(float64(decodedPositions.Floats[i*3+c]-positions.Floats[index*3+c])) > positionTolerance {
+				t.Fatalf("Expected point %d to be within the quantization tolerance", index)
+			}
+			if decodedColors.Bytes[i*3+c] != colors.Bytes[index*3+c] {
+				t.Fatalf("Expected the color of point %d to be restored exactly", index)
+			}
+		}
+		if math.Abs(float64(decodedFine.Floats[i]-fine.Floats[index])) > 1e-6 {
+			t.Fatalf("Expected the 30 bits attribute of point %d to be restored, got %f instead of %f", index, decodedFine.Floats[i], fine.Floats[index])
+		}
+	}
+	if len(seen) != numPoints {
+		t.Errorf("Expected every point to be decoded once, got %d distinct points", len(seen))
+	}
+}
+
+func TestDracoDecodeRejectsInvalidBitstreams(t *testing.T) {
+	if _, err := draco.Decode([]byte("pnts")); err == nil {
+		t.Errorf("Expected an error decoding a bitstream without the draco header")
+	}
+	encoded, err := draco.Encode(2, []*draco.Attribute{{Type: draco.Color, Components: 3, Bytes: []uint8{1, 2, 3, 4, 5, 6}}})
+	if err != nil {
+		t.Fatalf("Unexpected error encoding the point cloud: %s", err.Error())
+	}
+	if _, err := draco.Decode(encoded[:len(encoded)-3]); err == nil {
+		t.Errorf("Expected an error decoding a truncated bitstream")
+	}
+}
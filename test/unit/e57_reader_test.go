@@ -0,0 +1,269 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/e57_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"hash/crc32"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestE57ReaderReadsPosedScansWithColors(t *testing.T) {
+	e57File := writeTestE57(t, getTestE57Scans())
+	defer func() { _ = os.RemoveAll(path.Dir(e57File)) }()
+
+	tree := &mockTree{}
+	if err := e57_reader.NewE57Reader(nil, storage.NewOsStorage(), nil).Read(e57File, 32633, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// 300 posed points, 4 valid scaled integer points out of 5 and a spherical point
+	if len(tree.points) != 305 {
+		t.Fatalf("Expected 305 points, got %d", len(tree.points))
+	}
+	for i := 0; i < 300; i++ {
+		point := tree.points[i]
+		if math.Abs(point.X-100) > 1e-6 || math.Abs(point.Y-float64(200+i)) > 1e-6 || math.Abs(point.Z-11) > 1e-6 {
+			t.Fatalf("Expected point %d rotated and moved by the pose to (100, %d, 11), got (%f, %f, %f)", i, 200+i, point.X, point.Y, point.Z)
+		}
+		expectedIntensity := uint8(math.Round(float64(4*i) / 2047 * 255))
+		if point.R != uint8(i%256) || point.G != 0 || point.B != 255 || point.Intensity != expectedIntensity {
+			t.Fatalf("Expected point %d colored (%d, 0, 255) with intensity %d, got (%d, %d, %d) and %d", i, i%256, expectedIntensity, point.R, point.G, point.B, point.Intensity)
+		}
+	}
+	for i, expectedX := range []float64{-2.5, 0.001, 12.345, 99.999} {
+		point := tree.points[300+i]
+		if math.Abs(point.X-expectedX) > 1e-9 || math.Abs(point.Y+expectedX) > 1e-9 || point.Z != 0 {
+			t.Errorf("Expected scaled point %d at (%f, %f, 0), got (%f, %f, %f)", i, expectedX, -expectedX, point.X, point.Y, point.Z)
+		}
+		if point.R != 0 || point.Intensity != 0 {
+			t.Errorf("Expected scaled point %d without color nor intensity, got %d and %d", i, point.R, point.Intensity)
+		}
+	}
+	spherical := tree.points[304]
+	if math.Abs(spherical.X) > 1e-9 || math.Abs(spherical.Y-2) > 1e-9 || math.Abs(spherical.Z) > 1e-9 {
+		t.Errorf("Expected the spherical point at (0, 2, 0), got (%f, %f, %f)", spherical.X, spherical.Y, spherical.Z)
+	}
+	if tree.srids[0] != 32633 {
+		t.Errorf("Expected srid 32633, got %d", tree.srids[0])
+	}
+}
+
+func TestE57ReaderRejectsCorruptedPages(t *testing.T) {
+	e57File := writeTestE57(t, getTestE57Scans())
+	defer func() { _ = os.RemoveAll(path.Dir(e57File)) }()
+	content, err := ioutil.ReadFile(e57File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[1500] ^= 0xff
+	if err := ioutil.WriteFile(e57File, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	err = e57_reader.NewE57Reader(nil, storage.NewOsStorage(), nil).Read(e57File, 32633, &mockTree{})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch in page 1") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+}
+
+// transformer moving every point by its time along the x axis
+type e57TestTransformer struct{}
+
+func (e57TestTransformer) Transform(coordinate *geometry.Coordinate, time float64, srid int) (*geometry.Coordinate, int) {
+	return &geometry.Coordinate{X: coordinate.X + time, Y: coordinate.Y, Z: coordinate.Z}, srid
+}
+
+func TestE57ReaderRequiresTimeStampsToTransform(t *testing.T) {
+	e57File := writeTestE57(t, getTestE57Scans())
+	defer func() { _ = os.RemoveAll(path.Dir(e57File)) }()
+
+	err := e57_reader.NewE57Reader(e57TestTransformer{}, storage.NewOsStorage(), nil).Read(e57File, 32633, &mockTree{})
+	if err == nil || !strings.Contains(err.Error(), "time stamp") {
+		t.Errorf("Expected an error for the missing time stamps, got %v", err)
+	}
+}
+
+// scan of the test E57 files, whose prototype lists the given fields, each stored as the given bytestream
+type e57TestScan struct {
+	// elements of the scan other than the points
+	elements string
+	fields   []string
+	streams  [][]byte
+	records  int
+}
+
+// Returns three scans: 300 double cartesian points rotated by 90 degrees around z and translated by the pose, with
+// colors and intensity limits, 5 scaled integer points of which the third is invalid, nested in a structure, and a
+// single spherical point
+func getTestE57Scans() []e57TestScan {
+	var x, y, z []float64
+	var red, green, blue, intensity []int64
+	for i := 0; i < 300; i++ {
+		x, y, z = append(x, float64(i)), append(y, 0), append(z, 1)
+		red, green, blue, intensity = append(red, int64(i%256)), append(green, 0), append(blue, 255), append(intensity, int64(4*i))
+	}
+	scaled := []int64{-2500, 1, 50000, 12345, 99999}
+	opposite := make([]int64, len(scaled))
+	for i, value := range scaled {
+		opposite[i] = -value
+	}
+	return []e57TestScan{
+		{
+			elements: `<name type="String"><![CDATA[north]]></name>
+<pose type="Structure"><rotation type="Structure"><w type="Float">0.7071067811865476</w><x type="Float">0</x><y type="Float">0</y><z type="Float">0.7071067811865476</z></rotation>
+<translation type="Structure"><x type="Float">100</x><y type="Float">200</y><z type="Float">10</z></translation></pose>
+<intensityLimits type="Structure"><intensityMinimum type="Integer"/><intensityMaximum type="Integer">2047</intensityMaximum></intensityLimits>`,
+			fields: []string{
+				`<cartesianX type="Float"/>`, `<cartesianY type="Float"/>`, `<cartesianZ type="Float" precision="single"/>`,
+				`<colorRed type="Integer" minimum="0" maximum="255"/>`, `<colorGreen type="Integer" minimum="0" maximum="0"/>`,
+				`<colorBlue type="Integer" minimum="0" maximum="255"/>`, `<intensity type="Integer" minimum="0" maximum="4095"/>`,
+			},
+			streams: [][]byte{
+				float64Stream(x), float64Stream(y), float32Stream(z),
+				bitStream(red, 0, 8), bitStream(green, 0, 0), bitStream(blue, 0, 8), bitStream(intensity, 0, 12),
+			},
+			records: 300,
+		},
+		{
+			fields: []string{
+				`<cartesian type="Structure"><cartesianX type="ScaledInteger" minimum="-100000" maximum="100000" scale="0.001"/>`,
+				`<cartesianY type="ScaledInteger" minimum="-100000" maximum="100000" scale="0.001"/>`,
+				`<cartesianZ type="ScaledInteger" minimum="0" maximum="0" scale="0.001"/></cartesian>`,
+				`<cartesianInvalidState type="Integer" minimum="0" maximum="2"/>`,
+			},
+			streams: [][]byte{
+				bitStream(scaled, -100000, 18), bitStream(opposite, -100000, 18), bitStream(make([]int64, 5), 0, 0),
+				bitStream([]int64{0, 0, 1, 0, 0}, 0, 2),
+			},
+			records: 5,
+		},
+		{
+			fields: []string{
+				`<sphericalRange type="Float"/>`, `<sphericalAzimuth type="Float"/>`, `<sphericalElevation type="Float"/>`,
+			},
+			streams: [][]byte{float64Stream([]float64{2}), float64Stream([]float64{math.Pi / 2}), float64Stream([]float64{0})},
+			records: 1,
+		},
+	}
+}
+
+func float64Stream(values []float64) []byte {
+	stream := make([]byte, 8*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint64(stream[8*i:], math.Float64bits(value))
+	}
+	return stream
+}
+
+func float32Stream(values []float64) []byte {
+	stream := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(stream[4*i:], math.Float32bits(float32(value)))
+	}
+	return stream
+}
+
+// packs the given integers, minus the given minimum, with the given bits each, least significant bit first
+func bitStream(values []int64, minimum int64, bits int) []byte {
+	stream := make([]byte, (len(values)*bits+7)/8)
+	for i, value := range values {
+		raw := uint64(value - minimum)
+		for bit := 0; bit < bits; bit++ {
+			if raw&(1<<uint(bit)) != 0 {
+				position := i*bits + bit
+				stream[position/8] |= 1 << uint(position%8)
+			}
+		}
+	}
+	return stream
+}
+
+// writes an E57 file with 1024 bytes pages holding the given scans, whose bytestreams are split in data packets of
+// at most 100 bytes per bytestream, so that the records straddle the packets, preceded by an empty packet, returning
+// the path of the file
+func writeTestE57(t *testing.T, scans []e57TestScan) string {
+	folder, err := ioutil.TempDir("", "e57")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const pageSize, payloadSize = 1024, 1020
+	physical := func(logical int) int {
+		return logical/payloadSize*pageSize + logical%payloadSize
+	}
+
+	logical := bytes.NewBuffer(make([]byte, 48))
+	xml := bytes.NewBufferString(`<?xml version="1.0" encoding="UTF-8"?>
+<e57Root type="Structure" xmlns="http://www.astm.org/COMMIT/E57/2010-e57-v1.0">
+<formatName type="String"><![CDATA[ASTM E57 3D Imaging Data File]]></formatName>
+<data3D type="Vector" allowHeterogeneousChildren="1">`)
+	for _, scan := range scans {
+		sectionStart := logical.Len()
+		packets := &bytes.Buffer{}
+		_, _ = packets.Write([]byte{2, 0, 7, 0, 0, 0, 0, 0})
+		for offset := 0; ; offset += 100 {
+			lengths, buffers := &bytes.Buffer{}, &bytes.Buffer{}
+			for _, stream := range scan.streams {
+				end := offset + 100
+				if end > len(stream) {
+					end = len(stream)
+				}
+				buffer := []byte{}
+				if offset < end {
+					buffer = stream[offset:end]
+				}
+				_ = binary.Write(lengths, binary.LittleEndian, uint16(len(buffer)))
+				_, _ = buffers.Write(buffer)
+			}
+			if buffers.Len() == 0 {
+				break
+			}
+			_ = binary.Write(packets, binary.LittleEndian, []uint8{1, 0})
+			_ = binary.Write(packets, binary.LittleEndian, uint16(6+lengths.Len()+buffers.Len()-1))
+			_ = binary.Write(packets, binary.LittleEndian, uint16(len(scan.streams)))
+			_, _ = packets.Write(lengths.Bytes())
+			_, _ = packets.Write(buffers.Bytes())
+		}
+		_ = binary.Write(logical, binary.LittleEndian, []uint8{1, 0, 0, 0, 0, 0, 0, 0})
+		_ = binary.Write(logical, binary.LittleEndian, []uint64{uint64(32 + packets.Len()), uint64(physical(sectionStart + 32)), 0})
+		_, _ = logical.Write(packets.Bytes())
+
+		_, _ = fmt.Fprintf(xml, `<vectorChild type="Structure">%s<points type="CompressedVector" fileOffset="%d" recordCount="%d">`, scan.elements, physical(sectionStart), scan.records)
+		_, _ = fmt.Fprintf(xml, `<prototype type="Structure">%s</prototype><codecs type="Vector" allowHeterogeneousChildren="1"/></points></vectorChild>`, strings.Join(scan.fields, ""))
+	}
+	xml.WriteString("</data3D></e57Root>")
+	xmlStart := logical.Len()
+	_, _ = logical.Write(xml.Bytes())
+
+	content := logical.Bytes()
+	pages := (len(content) + payloadSize - 1) / payloadSize
+	header := content[:48]
+	copy(header, "ASTM-E57")
+	binary.LittleEndian.PutUint32(header[8:], 1)
+	binary.LittleEndian.PutUint64(header[16:], uint64(pages*pageSize))
+	binary.LittleEndian.PutUint64(header[24:], uint64(physical(xmlStart)))
+	binary.LittleEndian.PutUint64(header[32:], uint64(xml.Len()))
+	binary.LittleEndian.PutUint64(header[40:], pageSize)
+
+	file := &bytes.Buffer{}
+	table := crc32.MakeTable(crc32.Castagnoli)
+	for page := 0; page < pages; page++ {
+		payload := make([]byte, payloadSize)
+		copy(payload, content[page*payloadSize:])
+		_, _ = file.Write(payload)
+		_ = binary.Write(file, binary.BigEndian, crc32.Checksum(payload, table))
+	}
+	e57File := path.Join(folder, "scans.e57")
+	if err := ioutil.WriteFile(e57File, file.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return e57File
+}
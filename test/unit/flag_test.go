@@ -2,8 +2,11 @@ package unit_test
 
 import (
 	"flag"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
+	"io/ioutil"
 	"os"
+	"path"
 	"strconv"
 	"testing"
 )
@@ -394,4 +397,1016 @@ func TestRefineModeFlagDefaultIsAdd(t *testing.T) {
 	if *flags.RefineMode != expected {
 		t.Errorf("Expected Output = %s, got %s", expected, *flags.RefineMode)
 	}
-}
\ No newline at end of file
+}
+
+func TestFrameFlagIsParsed(t *testing.T) {
+	expected := "LOCAL"
+	os.Args = []string{"gocesiumtiler", "-frame=" + expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CoordinateFrame != expected {
+		t.Errorf("Expected Output = %s, got %s", expected, *flags.CoordinateFrame)
+	}
+}
+
+func TestFrameFlagDefaultIsEcef(t *testing.T) {
+	expected := "ECEF"
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.CoordinateFrame != expected {
+		t.Errorf("Expected Output = %s, got %s", expected, *flags.CoordinateFrame)
+	}
+}
+
+func TestContentExtensionFlagIsParsed(t *testing.T) {
+	expected := ".bin"
+	os.Args = []string{"gocesiumtiler", "-content-extension=" + expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ContentExtension != expected {
+		t.Errorf("Expected ContentExtension = %s, got %s", expected, *flags.ContentExtension)
+	}
+}
+
+func TestContentExtensionFlagDefaultIsPnts(t *testing.T) {
+	expected := ".pnts"
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ContentExtension != expected {
+		t.Errorf("Expected ContentExtension = %s, got %s", expected, *flags.ContentExtension)
+	}
+}
+
+func TestExtensionlessFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-extensionless"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ExtensionlessContent != true {
+		t.Errorf("Expected ExtensionlessContent = %t, got %t", true, *flags.ExtensionlessContent)
+	}
+}
+
+func TestHostConfigFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-host-config"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.HostConfig != true {
+		t.Errorf("Expected HostConfig = %t, got %t", true, *flags.HostConfig)
+	}
+}
+
+func TestRosCloudTopicFlagIsParsed(t *testing.T) {
+	expected := "/velodyne_points"
+	os.Args = []string{"gocesiumtiler", "-ros-cloud-topic", expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.RosCloudTopic != expected {
+		t.Errorf("Expected RosCloudTopic = %s, got %s", expected, *flags.RosCloudTopic)
+	}
+}
+
+func TestRosPoseTopicFlagIsParsed(t *testing.T) {
+	expected := "/odom"
+	os.Args = []string{"gocesiumtiler", "-ros-pose-topic", expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.RosPoseTopic != expected {
+		t.Errorf("Expected RosPoseTopic = %s, got %s", expected, *flags.RosPoseTopic)
+	}
+}
+
+func TestTrajectoryFlagIsParsed(t *testing.T) {
+	expected := "trajectory.out"
+	os.Args = []string{"gocesiumtiler", "-trajectory", expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.TrajectoryFile != expected {
+		t.Errorf("Expected TrajectoryFile = %s, got %s", expected, *flags.TrajectoryFile)
+	}
+}
+
+func TestTerrainFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-terrain", "dem.asc", "-terrain-srid", "32633"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.TerrainFile != "dem.asc" {
+		t.Errorf("Expected TerrainFile = %s, got %s", "dem.asc", *flags.TerrainFile)
+	}
+	if *flags.TerrainSrid != 32633 {
+		t.Errorf("Expected TerrainSrid = %d, got %d", 32633, *flags.TerrainSrid)
+	}
+}
+
+func TestTerrainSridFlagDefaultIsWgs84(t *testing.T) {
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.TerrainSrid != 4326 {
+		t.Errorf("Expected TerrainSrid = %d, got %d", 4326, *flags.TerrainSrid)
+	}
+}
+
+func TestOriginSnapFlagIsParsed(t *testing.T) {
+	expected := "centroid"
+	os.Args = []string{"gocesiumtiler", "-origin-snap", expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.OriginSnap != expected {
+		t.Errorf("Expected OriginSnap = %s, got %s", expected, *flags.OriginSnap)
+	}
+}
+
+func TestOriginSnapFlagDefaultIsNone(t *testing.T) {
+	expected := "NONE"
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.OriginSnap != expected {
+		t.Errorf("Expected OriginSnap = %s, got %s", expected, *flags.OriginSnap)
+	}
+}
+
+func TestStatsFinalFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-stats-final"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.StatsFinal {
+		t.Errorf("Expected StatsFinal = true, got false")
+	}
+}
+
+func TestTuiFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-tui"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Tui {
+		t.Errorf("Expected Tui = true, got false")
+	}
+}
+
+func TestMaxOpenFilesFlagIsParsed(t *testing.T) {
+	expected := 128
+	os.Args = []string{"gocesiumtiler", "-max-open-files", "128"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.MaxOpenFiles != expected {
+		t.Errorf("Expected MaxOpenFiles = %d, got %d", expected, *flags.MaxOpenFiles)
+	}
+}
+
+func TestStallFlagsAreParsed(t *testing.T) {
+	expected := 15.0
+	os.Args = []string{"gocesiumtiler", "-stall-timeout", "15", "-stall-abort"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.StallTimeout != expected {
+		t.Errorf("Expected StallTimeout = %f, got %f", expected, *flags.StallTimeout)
+	}
+	if !*flags.StallAbort {
+		t.Errorf("Expected StallAbort = true, got false")
+	}
+}
+
+func TestWorkersFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-read-workers", "2", "-convert-workers", "3", "-insert-workers", "4", "-write-workers", "5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ReadWorkers != 2 {
+		t.Errorf("Expected ReadWorkers = %d, got %d", 2, *flags.ReadWorkers)
+	}
+	if *flags.ConvertWorkers != 3 {
+		t.Errorf("Expected ConvertWorkers = %d, got %d", 3, *flags.ConvertWorkers)
+	}
+	if *flags.InsertWorkers != 4 {
+		t.Errorf("Expected InsertWorkers = %d, got %d", 4, *flags.InsertWorkers)
+	}
+	if *flags.WriteWorkers != 5 {
+		t.Errorf("Expected WriteWorkers = %d, got %d", 5, *flags.WriteWorkers)
+	}
+}
+
+func TestTileMetadataFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-tile-metadata"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.TileMetadata {
+		t.Errorf("Expected TileMetadata = true, got false")
+	}
+}
+
+func TestThumbnailSizeFlagIsParsed(t *testing.T) {
+	expected := 128
+	os.Args = []string{"gocesiumtiler", "-thumbnail-size", "128"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ThumbnailSize != expected {
+		t.Errorf("Expected ThumbnailSize = %d, got %d", expected, *flags.ThumbnailSize)
+	}
+}
+
+func TestCoverageFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-coverage"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Coverage {
+		t.Errorf("Expected Coverage = true, got false")
+	}
+}
+
+func TestStacFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-stac", "-stac-collection"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Stac {
+		t.Errorf("Expected Stac = true, got false")
+	}
+	if !*flags.StacCollection {
+		t.Errorf("Expected StacCollection = true, got false")
+	}
+}
+
+func TestGeoVolumesFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-geovolumes"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.GeoVolumes {
+		t.Errorf("Expected GeoVolumes = true, got false")
+	}
+}
+
+func TestLevelRetentionFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-level-retention", "0.001, 0.005,0.02"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	fractions, ok := tiler.ParseLevelRetention(*flags.LevelRetention)
+	if !ok {
+		t.Fatalf("Expected level retention to be parsed")
+	}
+	expected := []float64{0.001, 0.005, 0.02}
+	if len(fractions) != len(expected) {
+		t.Fatalf("Expected %d fractions, got %d", len(expected), len(fractions))
+	}
+	for i := range expected {
+		if fractions[i] != expected[i] {
+			t.Errorf("Expected fraction %f at index %d, got %f", expected[i], i, fractions[i])
+		}
+	}
+
+	if _, ok := tiler.ParseLevelRetention("0.1,abc"); ok {
+		t.Errorf("Expected invalid level retention not to be parsed")
+	}
+}
+
+func TestSkipDuplicatesFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-skip-duplicates"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.SkipDuplicates {
+		t.Errorf("Expected SkipDuplicates = true, got false")
+	}
+}
+
+func TestControlPointsFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-control-points", "points.csv", "-control-points-srid", "4978", "-control-points-tolerance", "0.05"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ControlPointsFile != "points.csv" {
+		t.Errorf("Expected ControlPointsFile = points.csv, got %s", *flags.ControlPointsFile)
+	}
+	if *flags.ControlPointsSrid != 4978 {
+		t.Errorf("Expected ControlPointsSrid = 4978, got %d", *flags.ControlPointsSrid)
+	}
+	if *flags.ControlPointsTolerance != 0.05 {
+		t.Errorf("Expected ControlPointsTolerance = 0.05, got %f", *flags.ControlPointsTolerance)
+	}
+}
+
+func TestPointAttributeFilterFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-exclude-overlap", "-returns", "first", "-scanner-channel", "1"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.ExcludeOverlap {
+		t.Errorf("Expected ExcludeOverlap = true, got false")
+	}
+	if tiler.ParseReturnsMode(*flags.Returns) != tiler.ReturnsFirst {
+		t.Errorf("Expected Returns = FIRST, got %s", *flags.Returns)
+	}
+	if *flags.ScannerChannel != 1 {
+		t.Errorf("Expected ScannerChannel = 1, got %d", *flags.ScannerChannel)
+	}
+}
+
+func TestPruneFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-prune-sse", "16", "-prune-distance", "25"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.PruneScreenError != 16 {
+		t.Errorf("Expected PruneScreenError = 16, got %f", *flags.PruneScreenError)
+	}
+	if *flags.PruneDistance != 25 {
+		t.Errorf("Expected PruneDistance = 25, got %f", *flags.PruneDistance)
+	}
+}
+
+func TestCompressionFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-compression", "zstd", "-zstd-dict", "-serve", ":8080"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if tiler.ParseCompressionMode(*flags.Compression) != tiler.CompressionZstd {
+		t.Errorf("Expected Compression = ZSTD, got %s", *flags.Compression)
+	}
+	if !*flags.ZstdDictionary {
+		t.Errorf("Expected ZstdDictionary = true, got false")
+	}
+	if *flags.Serve != ":8080" {
+		t.Errorf("Expected Serve = :8080, got %s", *flags.Serve)
+	}
+}
+
+func TestServeSubcommandIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "serve", "-s", "output"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Serve != tools.DefaultServeAddress || *flags.Output != "output" || !*flags.Silent {
+		t.Errorf("Expected to serve output on the default address silently, got %s, %s and %v", *flags.Serve, *flags.Output, *flags.Silent)
+	}
+
+	os.Args = []string{"gocesiumtiler", "serve", "-serve", "127.0.0.1:9000", "-o", "tiles"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags = tools.ParseFlags()
+	if *flags.Serve != "127.0.0.1:9000" || *flags.Output != "tiles" {
+		t.Errorf("Expected to serve tiles on 127.0.0.1:9000, got %s and %s", *flags.Serve, *flags.Output)
+	}
+}
+
+func TestDeduplicateTilesFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-dedup-tiles"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.DeduplicateTiles {
+		t.Errorf("Expected DeduplicateTiles = true, got false")
+	}
+}
+
+func TestUriTemplateFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-uri-template", "{level}/{x}/{y}/{z}.pnts"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.UriTemplate != "{level}/{x}/{y}/{z}.pnts" {
+		t.Errorf("Expected UriTemplate = {level}/{x}/{y}/{z}.pnts, got %s", *flags.UriTemplate)
+	}
+}
+
+func TestContentBaseUrlFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-content-base-url", "https://cdn.example.com/datasets/abc/"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ContentBaseUrl != "https://cdn.example.com/datasets/abc/" {
+		t.Errorf("Expected ContentBaseUrl = https://cdn.example.com/datasets/abc/, got %s", *flags.ContentBaseUrl)
+	}
+}
+
+func TestMaxDirectoryEntriesFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-max-dir-entries", "1000"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.MaxDirectoryEntries != 1000 {
+		t.Errorf("Expected MaxDirectoryEntries = 1000, got %d", *flags.MaxDirectoryEntries)
+	}
+}
+
+func TestAvailabilityFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-availability"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Availability {
+		t.Errorf("Expected Availability = true, got false")
+	}
+}
+
+func TestSourceColorsFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-source-colors"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.SourceColors {
+		t.Errorf("Expected SourceColors = true, got false")
+	}
+}
+
+func TestElevationPipelineFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-elevation-pipeline", "geoid, offset:-0.35,RASTER:fix.asc"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	steps, ok := tiler.ParseElevationPipeline(*flags.ElevationPipeline)
+	if !ok {
+		t.Fatalf("Expected elevation pipeline to be parsed")
+	}
+	expected := []tiler.ElevationStep{
+		{Kind: tiler.ElevationStepGeoid},
+		{Kind: tiler.ElevationStepOffset, Offset: -0.35},
+		{Kind: tiler.ElevationStepRaster, File: "fix.asc"},
+	}
+	if len(steps) != len(expected) {
+		t.Fatalf("Expected %d steps, got %d", len(expected), len(steps))
+	}
+	for i := range expected {
+		if steps[i] != expected[i] {
+			t.Errorf("Expected step %+v at index %d, got %+v", expected[i], i, steps[i])
+		}
+	}
+
+	for _, invalid := range []string{"offset", "offset:abc", "raster", "geoid:1", "scale:2"} {
+		if _, ok := tiler.ParseElevationPipeline(invalid); ok {
+			t.Errorf("Expected invalid elevation pipeline %s not to be parsed", invalid)
+		}
+	}
+}
+
+func TestConverterCacheFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-converter-cache", "100000", "-converter-cache-quantum", "1e-8"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ConverterCacheSize != 100000 {
+		t.Errorf("Expected ConverterCacheSize = 100000, got %d", *flags.ConverterCacheSize)
+	}
+	if *flags.ConverterCacheQuantum != 1e-8 {
+		t.Errorf("Expected ConverterCacheQuantum = 1e-8, got %g", *flags.ConverterCacheQuantum)
+	}
+}
+
+func TestRootPercentileFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-root-percentile", "0.001"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.RootPercentile != 0.001 {
+		t.Errorf("Expected RootPercentile = 0.001, got %f", *flags.RootPercentile)
+	}
+}
+
+func TestLeafCapFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-leaf-cap", "50000", "-leaf-cap-policy", "densest"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.LeafPointCap != 50000 {
+		t.Errorf("Expected LeafPointCap = 50000, got %d", *flags.LeafPointCap)
+	}
+	if policy := tiler.ParseLeafCapPolicy(*flags.LeafCapPolicy); policy != tiler.LeafCapDensest {
+		t.Errorf("Expected LeafCapPolicy = DENSEST, got %s", policy)
+	}
+}
+
+func TestLeafCapPolicyDefaultsToKeepAll(t *testing.T) {
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if policy := tiler.ParseLeafCapPolicy(*flags.LeafCapPolicy); policy != tiler.LeafCapKeepAll {
+		t.Errorf("Expected LeafCapPolicy = KEEP_ALL, got %s", policy)
+	}
+}
+
+func TestFlaggedPointsFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-withheld", "split", "-synthetic", "drop", "-key-points", "split"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if mode := tiler.ParseFlaggedPointsMode(*flags.WithheldPoints); mode != tiler.FlaggedPointsSplit {
+		t.Errorf("Expected WithheldPoints = SPLIT, got %s", mode)
+	}
+	if mode := tiler.ParseFlaggedPointsMode(*flags.SyntheticPoints); mode != tiler.FlaggedPointsDrop {
+		t.Errorf("Expected SyntheticPoints = DROP, got %s", mode)
+	}
+	if mode := tiler.ParseFlaggedPointsMode(*flags.KeyPoints); mode != tiler.FlaggedPointsSplit {
+		t.Errorf("Expected KeyPoints = SPLIT, got %s", mode)
+	}
+}
+
+func TestFlaggedPointsFlagsDefaultToDroppingWithheldPoints(t *testing.T) {
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if mode := tiler.ParseFlaggedPointsMode(*flags.WithheldPoints); mode != tiler.FlaggedPointsDrop {
+		t.Errorf("Expected WithheldPoints = DROP, got %s", mode)
+	}
+	if mode := tiler.ParseFlaggedPointsMode(*flags.SyntheticPoints); mode != tiler.FlaggedPointsKeep {
+		t.Errorf("Expected SyntheticPoints = KEEP, got %s", mode)
+	}
+	if mode := tiler.ParseFlaggedPointsMode(*flags.KeyPoints); mode != tiler.FlaggedPointsKeep {
+		t.Errorf("Expected KeyPoints = KEEP, got %s", mode)
+	}
+}
+
+func TestAlphaFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-alpha", "intensity", "-alpha-min", "0.25"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if alpha := tiler.ParseAlphaSource(*flags.Alpha); alpha != tiler.AlphaIntensity {
+		t.Errorf("Expected Alpha = INTENSITY, got %s", alpha)
+	}
+	if *flags.AlphaMin != 0.25 {
+		t.Errorf("Expected AlphaMin = 0.25, got %f", *flags.AlphaMin)
+	}
+}
+
+func TestGhostFilterFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-ghost-filter", "-ghost-voxel", "0.05", "-ghost-depth", "8"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.GhostFilter {
+		t.Errorf("Expected GhostFilter = true")
+	}
+	if *flags.GhostVoxelSize != 0.05 {
+		t.Errorf("Expected GhostVoxelSize = 0.05, got %f", *flags.GhostVoxelSize)
+	}
+	if *flags.GhostMaxDepth != 8 {
+		t.Errorf("Expected GhostMaxDepth = 8, got %f", *flags.GhostMaxDepth)
+	}
+}
+
+func TestTableLayoutFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-batch-table", "json", "-legacy-tables"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if encoding := tiler.ParseBatchTableEncoding(*flags.BatchTable); encoding != tiler.BatchTableJson {
+		t.Errorf("Expected BatchTable = JSON, got %s", encoding)
+	}
+	if !*flags.LegacyTables {
+		t.Errorf("Expected LegacyTables = true")
+	}
+}
+
+func TestRunMetadataFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-run-metadata"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.RunMetadata {
+		t.Errorf("Expected RunMetadata = true")
+	}
+}
+
+func TestClassLayersFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-class-layers"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.ClassLayers {
+		t.Errorf("Expected ClassLayers = true")
+	}
+}
+
+func TestInvalidColorsFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-invalid-colors", "intensity"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if mode := tiler.ParseInvalidColorsMode(*flags.InvalidColors); mode != tiler.InvalidColorsIntensity {
+		t.Errorf("Expected InvalidColors = INTENSITY, got %s", mode)
+	}
+}
+
+func TestSidecarFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-sidecar", "labels", "-sidecar-key", "gps_time"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.SidecarFolder != "labels" {
+		t.Errorf("Expected SidecarFolder = labels, got %s", *flags.SidecarFolder)
+	}
+	if key := tiler.ParseSidecarKey(*flags.SidecarKey); key != tiler.SidecarKeyGpsTime {
+		t.Errorf("Expected SidecarKey = GPS_TIME, got %s", key)
+	}
+}
+
+func TestParquetColumnsFlagIsParsed(t *testing.T) {
+	expected := "x=easting,y=northing"
+	os.Args = []string{"gocesiumtiler", "-parquet-columns", expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ParquetColumns != expected {
+		t.Errorf("Expected ParquetColumns = %s, got %s", expected, *flags.ParquetColumns)
+	}
+}
+
+func TestParquetExportFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-parquet-export"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.ParquetExport {
+		t.Errorf("Expected ParquetExport = true, got false")
+	}
+}
+
+func TestWebhookFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-webhook", "https://hooks.example.com/job", "-webhook-secret", "secret"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.WebhookUrl != "https://hooks.example.com/job" {
+		t.Errorf("Expected WebhookUrl = https://hooks.example.com/job, got %s", *flags.WebhookUrl)
+	}
+	if *flags.WebhookSecret != "secret" {
+		t.Errorf("Expected WebhookSecret = secret, got %s", *flags.WebhookSecret)
+	}
+}
+
+func TestReaderPluginsFlagIsParsed(t *testing.T) {
+	expected := ".rdbx=rdb2gctp --all"
+	os.Args = []string{"gocesiumtiler", "-reader-plugins", expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ReaderPlugins != expected {
+		t.Errorf("Expected ReaderPlugins = %s, got %s", expected, *flags.ReaderPlugins)
+	}
+}
+
+func TestBridgeFlagIsParsed(t *testing.T) {
+	expected := "python3 pdal_bridge.py {input}"
+	os.Args = []string{"gocesiumtiler", "-bridge", expected}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Bridge != expected {
+		t.Errorf("Expected Bridge = %s, got %s", expected, *flags.Bridge)
+	}
+}
+
+func TestSpoolFolderFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-algorithm", "twopass", "-spool-folder", "/scratch"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.SpoolFolder != "/scratch" {
+		t.Errorf("Expected SpoolFolder = /scratch, got %s", *flags.SpoolFolder)
+	}
+}
+
+func TestQuarantineFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-quarantine", "-validity-area", "12,46,18,48"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Quarantine {
+		t.Errorf("Expected Quarantine = true, got false")
+	}
+	if *flags.ValidityArea != "12,46,18,48" {
+		t.Errorf("Expected ValidityArea = 12,46,18,48, got %s", *flags.ValidityArea)
+	}
+}
+
+func TestErrorPolicyFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-error-policy", "continue"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if policy := tiler.ParseErrorPolicy(*flags.ErrorPolicy); policy != tiler.ErrorPolicyContinue {
+		t.Errorf("Expected ErrorPolicy = CONTINUE, got %s", policy)
+	}
+}
+
+func TestReadChunkSizeFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-read-chunk-size", "250000"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ReadChunkSize != 250000 {
+		t.Errorf("Expected ReadChunkSize = 250000, got %d", *flags.ReadChunkSize)
+	}
+}
+
+func TestLicenseAndWatermarkFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-license", "CC-BY-4.0", "-license-url", "https://example.com/license", "-watermark", "ACME-42"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.License != "CC-BY-4.0" || *flags.LicenseUrl != "https://example.com/license" {
+		t.Errorf("Expected License = CC-BY-4.0 and LicenseUrl = https://example.com/license, got %s and %s", *flags.License, *flags.LicenseUrl)
+	}
+	if *flags.Watermark != "ACME-42" || *flags.VerifyWatermark != "" {
+		t.Errorf("Expected Watermark = ACME-42 and no VerifyWatermark, got %s and %s", *flags.Watermark, *flags.VerifyWatermark)
+	}
+}
+
+func TestTilesetVersionFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-tileset-version", "1.1"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if version := tiler.ParseTilesetVersion(*flags.TilesetVersion); version != tiler.TilesetVersion11 {
+		t.Errorf("Expected TilesetVersion = 1.1, got %s", version)
+	}
+}
+
+func TestSizeBudgetFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-size-budget", "20GB"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if budget, ok := tiler.ParseByteSize(*flags.SizeBudget); !ok || budget != 20000000000 {
+		t.Errorf("Expected SizeBudget = 20000000000, got %d", budget)
+	}
+
+	if budget, ok := tiler.ParseByteSize("512MiB"); !ok || budget != 512*1024*1024 {
+		t.Errorf("Expected 512MiB to be parsed as %d bytes, got %d", 512*1024*1024, budget)
+	}
+	if _, ok := tiler.ParseByteSize("20XB"); ok {
+		t.Errorf("Expected invalid size budget not to be parsed")
+	}
+}
+
+func TestDracoFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-draco", "-draco-quantization", "position=16,Color=6,gps_time=24"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Draco {
+		t.Errorf("Expected Draco = true")
+	}
+	quantization, ok := tiler.ParseDracoQuantization(*flags.DracoQuantization)
+	if !ok || quantization["POSITION"] != 16 || quantization["COLOR"] != 6 || quantization["gps_time"] != 24 {
+		t.Errorf("Expected DracoQuantization = POSITION=16,COLOR=6,gps_time=24, got %v", quantization)
+	}
+
+	if quantization, ok := tiler.ParseDracoQuantization(""); !ok || len(quantization) != 1 || quantization["POSITION"] != tiler.DefaultDracoPositionBits {
+		t.Errorf("Expected the positions to be quantized to %d bits by default, got %v", tiler.DefaultDracoPositionBits, quantization)
+	}
+	if _, ok := tiler.ParseDracoQuantization("POSITION=high"); ok {
+		t.Errorf("Expected invalid draco quantization not to be parsed")
+	}
+}
+
+func TestCoarseFirstFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-coarse-first"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.CoarseFirst {
+		t.Errorf("Expected CoarseFirst = true")
+	}
+}
+
+func TestBundleFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-bundle-threshold", "32KB"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if threshold, ok := tiler.ParseByteSize(*flags.BundleThreshold); !ok || threshold != 32000 {
+		t.Errorf("Expected BundleThreshold = 32000, got %d", threshold)
+	}
+	if maxSize, ok := tiler.ParseByteSize(*flags.BundleMaxSize); !ok || maxSize != 1000000 {
+		t.Errorf("Expected BundleMaxSize = 1000000 by default, got %d", maxSize)
+	}
+}
+
+func TestImplicitTilingFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-implicit-tiling", "-subtree-levels", "3"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.ImplicitTiling {
+		t.Errorf("Expected ImplicitTiling = true")
+	}
+	if *flags.SubtreeLevels != 3 {
+		t.Errorf("Expected SubtreeLevels = 3, got %d", *flags.SubtreeLevels)
+	}
+}
+
+func TestSplitTileSizeFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-split-tile-size", "4MiB"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if size, ok := tiler.ParseByteSize(*flags.SplitTileSize); !ok || size != 4*1024*1024 {
+		t.Errorf("Expected SplitTileSize = %d, got %d", 4*1024*1024, size)
+	}
+}
+
+func TestMergeFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-merge"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Merge {
+		t.Errorf("Expected Merge = true")
+	}
+}
+
+func TestRecoverRecordsFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-recover-records"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.RecoverRecords {
+		t.Errorf("Expected RecoverRecords = true")
+	}
+}
+
+func TestColorSourceFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-color-source", "intensity", "-color-ramp", "#000080,#ff0000"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if source := tiler.ParseColorSource(*flags.ColorSource); source != tiler.ColorSourceIntensity {
+		t.Errorf("Expected ColorSource = INTENSITY, got %s", source)
+	}
+	if *flags.ColorRamp != "#000080,#ff0000" {
+		t.Errorf("Expected ColorRamp = #000080,#ff0000, got %s", *flags.ColorRamp)
+	}
+}
+
+func TestColorSourceIsAutomaticByDefault(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-intensity-stretch", "5,95"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if source := tiler.ParseColorSource(*flags.ColorSource); source != tiler.ColorSourceAuto {
+		t.Errorf("Expected ColorSource = AUTO, got %s", source)
+	}
+	if *flags.IntensityStretch != "5,95" {
+		t.Errorf("Expected IntensityStretch = 5,95, got %s", *flags.IntensityStretch)
+	}
+}
+
+func TestFloatFlagsAcceptDecimalCommas(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-grid-min-size", "0,15", "-x", "2.5", "-zoffset", "-1,25"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridCellMinSize != 0.15 || *flags.GridCellMaxSize != 2.5 || *flags.ZOffset != -1.25 {
+		t.Errorf("Expected 0.15, 2.5 and -1.25, got %f, %f and %f", *flags.GridCellMinSize, *flags.GridCellMaxSize, *flags.ZOffset)
+	}
+
+	os.Args = []string{"gocesiumtiler"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags = tools.ParseFlags()
+	if *flags.GridCellMinSize != 0.15 || *flags.GridCellMaxSize != 5 {
+		t.Errorf("Expected the default cell sizes 0.15 and 5, got %f and %f", *flags.GridCellMinSize, *flags.GridCellMaxSize)
+	}
+}
+
+func TestAmbiguousDecimalsAreRejected(t *testing.T) {
+	expected := map[string]float64{"0,150": 0.15, "12,5": 12.5, "1.500": 1.5, " 3 ": 3, "+0,5": 0.5}
+	for value, number := range expected {
+		if parsed, err := tiler.ParseDecimal(value); err != nil || parsed != number {
+			t.Errorf("Expected %q to be parsed as %f, got %f %v", value, number, parsed, err)
+		}
+	}
+	for _, value := range []string{"1,500", "1.000,5", "1,000.5", "1,2,3", "abc", ""} {
+		if _, err := tiler.ParseDecimal(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+	if size, ok := tiler.ParseByteSize("1,5GB"); !ok || size != 1500000000 {
+		t.Errorf("Expected 1,5GB to be parsed as 1500000000 bytes, got %d", size)
+	}
+}
+
+func TestByteSizesAreFormatted(t *testing.T) {
+	expected := map[int64]string{
+		512:           "512 B",
+		32768:         "32.77 KB",
+		999999:        "1.00 MB",
+		1500000000:    "1.50 GB",
+		2000000000000: "2.00 TB",
+	}
+	for size, formatted := range expected {
+		if value := tiler.FormatByteSize(size); value != formatted {
+			t.Errorf("Expected %d bytes to be formatted as %s, got %s", size, formatted, value)
+		}
+		if parsed, ok := tiler.ParseByteSize(tiler.FormatByteSize(size)); !ok || parsed <= 0 {
+			t.Errorf("Expected %s to be parsed back", formatted)
+		}
+	}
+}
+
+func TestFlagsAreReadFromEnvironment(t *testing.T) {
+	t.Setenv("GOCESIUMTILER_GRID_MAX_SIZE", "2,5")
+	t.Setenv("GOCESIUMTILER_GRID_MIN_SIZE", "0.2")
+	t.Setenv("GOCESIUMTILER_SRID", "32633")
+	t.Setenv("GOCESIUMTILER_MERGE", "true")
+	t.Setenv("GOCESIUMTILER_OUTPUT", "/data/out")
+	os.Args = []string{"gocesiumtiler", "-n", "0.3", "-srid", "4326"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridCellMaxSize != 2.5 || !*flags.Merge || *flags.Output != "/data/out" {
+		t.Errorf("Expected the flags missing from the command line to be read from the environment, got %f, %t and %s", *flags.GridCellMaxSize, *flags.Merge, *flags.Output)
+	}
+	if *flags.GridCellMinSize != 0.3 || *flags.Srid != 4326 {
+		t.Errorf("Expected the command line to take precedence over the environment, got %f and %d", *flags.GridCellMinSize, *flags.Srid)
+	}
+}
+
+func TestFlagsAreReadFromConfigFile(t *testing.T) {
+	config := path.Join(t.TempDir(), "gocesiumtiler.conf")
+	content := "# job defaults\nsrid = 32633\nmerge=true\n\ngrid-max-size=2,5\noutput=/data/config\n"
+	if err := ioutil.WriteFile(config, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOCESIUMTILER_OUTPUT", "/data/env")
+	os.Args = []string{"gocesiumtiler", "-config", config, "-e", "4326"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridCellMaxSize != 2.5 || !*flags.Merge {
+		t.Errorf("Expected the flags missing from the command line and the environment to be read from the configuration file, got %f and %t", *flags.GridCellMaxSize, *flags.Merge)
+	}
+	if *flags.Srid != 4326 || *flags.Output != "/data/env" {
+		t.Errorf("Expected the command line and the environment to take precedence over the configuration file, got %d and %s", *flags.Srid, *flags.Output)
+	}
+}
+
+func TestResumeFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-resume"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.Resume {
+		t.Errorf("Expected Resume = true")
+	}
+}
+
+func TestUpdateFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-self-update", "-check-update"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.SelfUpdate || !*flags.CheckUpdate {
+		t.Errorf("Expected SelfUpdate = true and CheckUpdate = true")
+	}
+}
+
+func TestTargetFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-target", "cesium@1.115"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Target != "cesium@1.115" {
+		t.Errorf("Expected Target = cesium@1.115, got %s", *flags.Target)
+	}
+}
+
+func TestZonalFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-zonal-stats", "zones.geojson", "-zonal-tileset", "out/cloud"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.ZonalStats != "zones.geojson" || *flags.ZonalTileset != "out/cloud" {
+		t.Errorf("Expected ZonalStats = zones.geojson and ZonalTileset = out/cloud, got %s and %s", *flags.ZonalStats, *flags.ZonalTileset)
+	}
+}
+
+func TestProfileFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-profile", "12.49,41.89;12.5,41.9", "-profile-buffer", "2,5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Profile != "12.49,41.89;12.5,41.9" || *flags.ProfileBuffer != 2.5 {
+		t.Errorf("Expected Profile = 12.49,41.89;12.5,41.9 and ProfileBuffer = 2.5, got %s and %f", *flags.Profile, *flags.ProfileBuffer)
+	}
+}
+
+func TestCrsFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-crs", "+proj=utm +zone=33 +datum=WGS84", "-crs-backend", "proj"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Crs != "+proj=utm +zone=33 +datum=WGS84" || *flags.CrsBackend != "proj" {
+		t.Errorf("Expected Crs = +proj=utm +zone=33 +datum=WGS84 and CrsBackend = proj, got %s and %s", *flags.Crs, *flags.CrsBackend)
+	}
+}
+
+func TestGeoidModelFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-geoid", "-geoid-model", "EGM2008"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.ZGeoidCorrection || *flags.GeoidModel != "EGM2008" {
+		t.Errorf("Expected ZGeoidCorrection = true and GeoidModel = EGM2008, got %t and %s", *flags.ZGeoidCorrection, *flags.GeoidModel)
+	}
+}
+
+func TestNearestFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-nearest", "12.49,41.89,35", "-nearest-count", "5", "-nearest-level", "3"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.Nearest != "12.49,41.89,35" || *flags.NearestCount != 5 || *flags.NearestLevel != 3 {
+		t.Errorf("Expected Nearest = 12.49,41.89,35, NearestCount = 5 and NearestLevel = 3, got %s, %d and %d", *flags.Nearest, *flags.NearestCount, *flags.NearestLevel)
+	}
+}
+
+func TestDensityFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-density-target", "50", "-density-mode", "VOLUME", "-density-cell-size", "0.5"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.DensityTarget != 50 || *flags.DensityMode != "VOLUME" || *flags.DensityCellSize != 0.5 {
+		t.Errorf("Expected DensityTarget = 50, DensityMode = VOLUME and DensityCellSize = 0.5, got %f, %s and %f", *flags.DensityTarget, *flags.DensityMode, *flags.DensityCellSize)
+	}
+}
+
+func TestGridSpoolFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-grid-spool", "-spool-folder", "/tmp/spool"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.GridSpool || *flags.SpoolFolder != "/tmp/spool" {
+		t.Errorf("Expected GridSpool = true and SpoolFolder = /tmp/spool, got %t and %s", *flags.GridSpool, *flags.SpoolFolder)
+	}
+}
+
+func TestGridSamplingFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-grid-sampling", "RANDOM"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.GridSampling != "RANDOM" {
+		t.Errorf("Expected GridSampling = RANDOM, got %s", *flags.GridSampling)
+	}
+}
+
+func TestScanFlagsAreParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-merge", "-scan-ids", "-scan-poses", "poses.csv"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if !*flags.ScanIds {
+		t.Errorf("Expected ScanIds = true")
+	}
+	if *flags.ScanPoses != "poses.csv" {
+		t.Errorf("Expected ScanPoses = poses.csv, got %s", *flags.ScanPoses)
+	}
+}
+
+func TestWriterConcurrencyFlagIsParsed(t *testing.T) {
+	os.Args = []string{"gocesiumtiler", "-writer-concurrency", "8"}
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := tools.ParseFlags()
+	if *flags.WriterConcurrency != 8 {
+		t.Errorf("Expected WriterConcurrency = 8, got %d", *flags.WriterConcurrency)
+	}
+}
@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geovolumes"
+	"testing"
+)
+
+func TestGeoVolumesDocuments(t *testing.T) {
+	api := geovolumes.NewApi("survey")
+	api.AddCollection("cloud", [6]float64{13, 42, 14, 43, 10, 50})
+
+	documents, err := api.Documents()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"index.json", "conformance.json", "collections.json"} {
+		if _, ok := documents[name]; !ok {
+			t.Errorf("expected document %s", name)
+		}
+	}
+
+	var landingPage geovolumes.LandingPage
+	_ = json.Unmarshal(documents["index.json"], &landingPage)
+	if landingPage.Title != "survey" || len(landingPage.Links) != 3 {
+		t.Errorf("unexpected landing page %v", landingPage)
+	}
+
+	var collections geovolumes.Collections
+	_ = json.Unmarshal(documents["collections.json"], &collections)
+	if len(collections.Collections) != 1 {
+		t.Fatalf("expected a collection, got %d", len(collections.Collections))
+	}
+	collection := collections.Collections[0]
+	if collection.CollectionType != "3d-container" || collection.Content[0].Href != "../cloud/tileset.json" {
+		t.Errorf("expected a 3D container linking the tileset, got %v", collection)
+	}
+	expectedBbox := []float64{13, 42, 10, 14, 43, 50}
+	for i, value := range expectedBbox {
+		if collection.Extent.Spatial.Bbox[0][i] != value {
+			t.Errorf("expected bbox %v, got %v", expectedBbox, collection.Extent.Spatial.Bbox[0])
+			break
+		}
+	}
+}
+
+func TestGeoVolumesWithoutTilesetsListsNoCollections(t *testing.T) {
+	documents, _ := geovolumes.NewApi("empty").Documents()
+
+	var collections map[string]interface{}
+	_ = json.Unmarshal(documents["collections.json"], &collections)
+	if list, ok := collections["collections"].([]interface{}); !ok || len(list) != 0 {
+		t.Errorf("expected an empty collections list, got %v", collections["collections"])
+	}
+}
@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/ghosts"
+	"math"
+	"testing"
+)
+
+func TestGhostFilterTreeRemovesPointsMirroredBehindWindows(t *testing.T) {
+	tree := &mockTree{}
+	ghostTree := ghosts.NewGhostFilterTree(tree, 0.1, 5, 1)
+
+	// a wall on the x = 0 plane with a window opening
+	wallPoints := 0
+	for y := 0.0; y < 6; y += 0.05 {
+		for z := 0.0; z < 3; z += 0.05 {
+			if y > 2 && y < 4 && z > 1 && z < 2 {
+				continue
+			}
+			ghostTree.AddPoint(&geometry.Coordinate{X: 0, Y: y, Z: z}, 0, 0, 0, 0, 0, 32633, nil)
+			wallPoints++
+		}
+	}
+	// a room object on the scanner side, its sparser reflection behind the window and an object behind the wall
+	realPoints := addCubeSurface(ghostTree, -1.5, 3, 1.5, 0.6, 0.025, 1)
+	addCubeSurface(ghostTree, 1.5, 3, 1.5, 0.6, 0.1, 2)
+	hiddenPoints := addCubeSurface(ghostTree, 1.5, 0.6, 0.5, 0.6, 0.025, 3)
+
+	if err := ghostTree.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	counts := make(map[uint8]int)
+	for _, point := range tree.points {
+		counts[point.Classification]++
+	}
+	if counts[0] != wallPoints || counts[1] != realPoints || counts[3] != hiddenPoints {
+		t.Errorf("Expected all the wall and object points to be kept, got %d/%d, %d/%d, %d/%d",
+			counts[0], wallPoints, counts[1], realPoints, counts[3], hiddenPoints)
+	}
+	if counts[2] != 0 {
+		t.Errorf("Expected the reflected points to be removed, %d kept", counts[2])
+	}
+}
+
+func TestGhostFilterTreeKeepsScenesWithoutReflections(t *testing.T) {
+	tree := &mockTree{}
+	ghostTree := ghosts.NewGhostFilterTree(tree, 0.1, 5, 1)
+	added := addCubeSurface(ghostTree, 0, 0, 0, 3, 0.05, 0)
+
+	if err := ghostTree.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(tree.points) != added {
+		t.Errorf("Expected %d points, got %d", added, len(tree.points))
+	}
+}
+
+// Adds the points sampled with the given step on the faces of the cube with the given center and side, with the given
+// classification, returning their number
+func addCubeSurface(tree interface {
+	AddPoint(*geometry.Coordinate, uint8, uint8, uint8, uint8, uint8, int, []float32)
+}, x, y, z, side, step float64, classification uint8) int {
+	count := 0
+	half := side / 2
+	for a := -half; a <= half+1e-9; a += step {
+		for b := -half; b <= half+1e-9; b += step {
+			for c := -half; c <= half+1e-9; c += step {
+				if math.Abs(math.Abs(a)-half) > 1e-9 && math.Abs(math.Abs(b)-half) > 1e-9 && math.Abs(math.Abs(c)-half) > 1e-9 {
+					continue
+				}
+				tree.AddPoint(&geometry.Coordinate{X: x + a, Y: y + b, Z: z + c}, 0, 0, 0, 0, classification, 32633, nil)
+				count++
+			}
+		}
+	}
+	return count
+}
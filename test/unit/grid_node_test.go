@@ -271,7 +271,7 @@ func TestGridNodeGetNumberOfPoints(t *testing.T) {
 		t.Errorf("Expected node to have NumberOfPoints equal to %d but got %d", 1, node.NumberOfPoints())
 	}
 
-	if node.NumberOfPoints() != int32(len(node.GetPoints())) {
+	if node.NumberOfPoints() != int64(len(node.GetPoints())) {
 		t.Errorf("Expected node to have NumberOfPoints equal to length of GetPoints array %d but got %d", len(node.GetPoints()), node.NumberOfPoints())
 	}
 }
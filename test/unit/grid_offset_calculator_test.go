@@ -0,0 +1,169 @@
+package unit
+
+import (
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/geoid_offset/grid_offset_calculator"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestGridOffsetCalculatorInterpolatesGtxGrid(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	gridFile := path.Join(folder, "geoid.gtx")
+	writeGtxTestFile(t, gridFile, 41, 14, 0.5, 0.5, 2, 3, []float32{30, 31, 32, 40, 41, 42})
+
+	calculator, err := grid_offset_calculator.NewGridOffsetCalculator(gridFile, proj4_coordinate_converter.NewProj4CoordinateConverter())
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+
+	var testData = []struct {
+		lon      float64
+		lat      float64
+		expected float64
+	}{
+		{14, 41, 30},
+		{15, 41.5, 42},
+		{14.25, 41.25, 35.5},
+		{14.75, 41, 31.5},
+	}
+	for _, data := range testData {
+		offset, err := calculator.GetEllipsoidToGeoidOffset(data.lon, data.lat, 4326)
+		if err != nil {
+			t.Fatalf("Unexpected error occurred: %s", err.Error())
+		}
+		if math.Abs(offset-data.expected) > 1e-6 {
+			t.Errorf("Expected offset %f at %f, %f, got %f", data.expected, data.lon, data.lat, offset)
+		}
+	}
+
+	if _, err := calculator.GetEllipsoidToGeoidOffset(15.1, 41.25, 4326); err == nil {
+		t.Errorf("Expected an error for a point outside of the grid")
+	}
+}
+
+func TestGridOffsetCalculatorConvertsSourceSrid(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	gridFile := path.Join(folder, "geoid.gtx")
+	writeGtxTestFile(t, gridFile, 41, 14, 1, 1, 2, 2, []float32{40, 42, 44, 46})
+
+	calculator, err := grid_offset_calculator.NewGridOffsetCalculator(gridFile, proj4_coordinate_converter.NewProj4CoordinateConverter())
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+
+	// 14.902954, 41.343825 in EPSG:4326
+	offset, err := calculator.GetEllipsoidToGeoidOffset(491880.85, 4576930.54, 32633)
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	expected := 40 + 2*0.902954 + 4*0.343825
+	if math.Abs(offset-expected) > 1e-5 {
+		t.Errorf("Expected offset %f, got %f", expected, offset)
+	}
+}
+
+func TestGridOffsetCalculatorWrapsGlobalGrid(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	gridFile := path.Join(folder, "geoid.gtx")
+	// four columns at 0, 90, 180 and 270 degrees east
+	writeGtxTestFile(t, gridFile, -90, 0, 180, 90, 2, 4, []float32{10, 20, 30, 40, 10, 20, 30, 40})
+
+	calculator, err := grid_offset_calculator.NewGridOffsetCalculator(gridFile, proj4_coordinate_converter.NewProj4CoordinateConverter())
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+
+	// between the last column and the first one
+	if offset, err := calculator.GetEllipsoidToGeoidOffset(-45, 0, 4326); err != nil || math.Abs(offset-25) > 1e-6 {
+		t.Errorf("Expected offset 25 across the antimeridian, got %f, %v", offset, err)
+	}
+	if offset, err := calculator.GetEllipsoidToGeoidOffset(180, 0, 4326); err != nil || math.Abs(offset-30) > 1e-6 {
+		t.Errorf("Expected offset 30 at 180 degrees, got %f, %v", offset, err)
+	}
+}
+
+func TestGridOffsetCalculatorRejectsNoDataCells(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	gridFile := path.Join(folder, "geoid.gtx")
+	writeGtxTestFile(t, gridFile, 41, 14, 1, 1, 2, 2, []float32{40, -88.8888, 44, 46})
+
+	calculator, err := grid_offset_calculator.NewGridOffsetCalculator(gridFile, proj4_coordinate_converter.NewProj4CoordinateConverter())
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	if _, err := calculator.GetEllipsoidToGeoidOffset(14.5, 41.5, 4326); err == nil {
+		t.Errorf("Expected an error for a point next to a cell without data")
+	}
+}
+
+func TestGridOffsetCalculatorReadsNgsGrid(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	gridFile := path.Join(folder, "geoid.bin")
+	// little endian NGS grid using the 0..360 longitude range
+	content := make([]byte, 44+4*4)
+	for i, value := range []float64{30, 260, 1, 1} {
+		binary.LittleEndian.PutUint64(content[8*i:], math.Float64bits(value))
+	}
+	for i, value := range []int32{2, 2, 1} {
+		binary.LittleEndian.PutUint32(content[32+4*i:], uint32(value))
+	}
+	for i, value := range []float32{-30, -28, -26, -24} {
+		binary.LittleEndian.PutUint32(content[44+4*i:], math.Float32bits(value))
+	}
+	if err := ioutil.WriteFile(gridFile, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	calculator, err := grid_offset_calculator.NewGridOffsetCalculator(gridFile, proj4_coordinate_converter.NewProj4CoordinateConverter())
+	if err != nil {
+		t.Fatalf("Unexpected error occurred: %s", err.Error())
+	}
+	if offset, err := calculator.GetEllipsoidToGeoidOffset(-99.5, 30.5, 4326); err != nil || math.Abs(offset+27) > 1e-6 {
+		t.Errorf("Expected offset -27, got %f, %v", offset, err)
+	}
+}
+
+func TestGridOffsetCalculatorRejectsUnsupportedGrid(t *testing.T) {
+	if _, err := grid_offset_calculator.NewGridOffsetCalculator("geoid.tif", proj4_coordinate_converter.NewProj4CoordinateConverter()); err == nil {
+		t.Errorf("Expected an error for a missing grid")
+	}
+}
+
+func TestGeoidModelGridPaths(t *testing.T) {
+	if !grid_offset_calculator.IsDefaultGeoidModel("") || !grid_offset_calculator.IsDefaultGeoidModel("egm84") || grid_offset_calculator.IsDefaultGeoidModel("EGM2008") {
+		t.Errorf("Expected EGM84 to be the default geoid model")
+	}
+	if actual := grid_offset_calculator.GetModelGridPath("egm2008"); !strings.HasSuffix(actual, path.Join("assets", "geoids", "egm08_25.gtx")) {
+		t.Errorf("Expected the EGM2008 grid in the assets folder, got %s", actual)
+	}
+	if actual := grid_offset_calculator.GetModelGridPath("/data/custom.gtx"); actual != "/data/custom.gtx" {
+		t.Errorf("Expected the given grid path, got %s", actual)
+	}
+}
+
+// Writes a GTX geoid grid with the given south west cell, steps, size and undulations, stored from south to north
+func writeGtxTestFile(t testing.TB, filePath string, latMin float64, lonMin float64, latStep float64, lonStep float64, rows int32, columns int32, undulations []float32) {
+	content := make([]byte, 40+4*len(undulations))
+	for i, value := range []float64{latMin, lonMin, latStep, lonStep} {
+		binary.BigEndian.PutUint64(content[8*i:], math.Float64bits(value))
+	}
+	binary.BigEndian.PutUint32(content[32:], uint32(rows))
+	binary.BigEndian.PutUint32(content[36:], uint32(columns))
+	for i, value := range undulations {
+		binary.BigEndian.PutUint32(content[40+4*i:], math.Float32bits(value))
+	}
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,8 +1,12 @@
 package unit
 
 import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
 	"testing"
 )
 
@@ -23,13 +27,11 @@ func (m *mockCoordinateConverter) ConvertToWGS84Cartesian(coord geometry.Coordin
 func (m *mockCoordinateConverter) Cleanup() {}
 
 func TestTreeAddPointSuccess(t *testing.T) {
-	tree := grid_tree.NewGridTree(
-		&mockCoordinateConverter{},
-		&mockElevationCorrector{},
-		5.0,
-		0.1,
-		1,
-	)
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
 
 	x := 14.0
 	y := 41.0
@@ -46,7 +48,7 @@ func TestTreeAddPointSuccess(t *testing.T) {
 		Z: z,
 	}
 
-	tree.AddPoint(coord, r, g, b, i, c, 4326)
+	tree.AddPoint(coord, r, g, b, i, c, 4326, nil)
 
 	point, hasMore := tree.(*grid_tree.GridTree).Loader.GetNext()
 
@@ -62,13 +64,11 @@ func TestTreeAddPointSuccess(t *testing.T) {
 }
 
 func TestTreeBuildSuccess(t *testing.T) {
-	tree := grid_tree.NewGridTree(
-		&mockCoordinateConverter{},
-		&mockElevationCorrector{},
-		5.0,
-		0.1,
-		1,
-	)
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
 
 	x := 14.0
 	y := 41.0
@@ -85,7 +85,7 @@ func TestTreeBuildSuccess(t *testing.T) {
 		Z: z,
 	}
 
-	tree.AddPoint(coord, r, g, b, i, c, 4326)
+	tree.AddPoint(coord, r, g, b, i, c, 4326, nil)
 
 	err := tree.Build()
 
@@ -103,13 +103,11 @@ func TestTreeBuildSuccess(t *testing.T) {
 }
 
 func TestGetRootNode(t *testing.T) {
-	tree := grid_tree.NewGridTree(
-		&mockCoordinateConverter{},
-		&mockElevationCorrector{},
-		5.0,
-		0.1,
-		1,
-	)
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
 
 	x := 14.0
 	y := 41.0
@@ -126,7 +124,7 @@ func TestGetRootNode(t *testing.T) {
 		Z: z,
 	}
 
-	tree.AddPoint(coord, r, g, b, i, c, 4326)
+	tree.AddPoint(coord, r, g, b, i, c, 4326, nil)
 
 	err := tree.Build()
 
@@ -147,3 +145,526 @@ func TestGetRootNode(t *testing.T) {
 
 // TODO add test to evaluate safety against race conditions while adding points,
 //  especially check against gridCell being correctly write locked when points slice is edited
+
+func TestGridOriginSnapAlignsRootToCells(t *testing.T) {
+	bbox := buildTreeAndGetRootBoundingBox(t, tiler.OriginSnapGrid)
+
+	assertBoundingBox(t, bbox, geometry.NewBoundingBox(0, 10, 0, 5, 0, 5))
+}
+
+func TestCentroidOriginSnapCentersRootOnCentroid(t *testing.T) {
+	bbox := buildTreeAndGetRootBoundingBox(t, tiler.OriginSnapCentroid)
+
+	assertBoundingBox(t, bbox, geometry.NewBoundingBox(-2, 10, 0, 2, 0, 4))
+}
+
+func TestNoOriginSnapKeepsPointsBounds(t *testing.T) {
+	bbox := buildTreeAndGetRootBoundingBox(t, tiler.OriginSnapNone)
+
+	assertBoundingBox(t, bbox, geometry.NewBoundingBox(0, 10, 0, 2, 0, 4))
+}
+
+func buildTreeAndGetRootBoundingBox(t *testing.T, originSnap tiler.OriginSnapMode) *geometry.BoundingBox {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		OriginSnap:         originSnap,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+
+	// the mock elevation corrector doubles the z values
+	tree.AddPoint(&geometry.Coordinate{X: 0, Y: 0, Z: 0}, 0, 0, 0, 0, 0, 4326, nil)
+	tree.AddPoint(&geometry.Coordinate{X: 10, Y: 2, Z: 2}, 0, 0, 0, 0, 0, 4326, nil)
+	tree.AddPoint(&geometry.Coordinate{X: 2, Y: 1, Z: 1}, 0, 0, 0, 0, 0, 4326, nil)
+
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	if tree.GetRootNode().TotalNumberOfPoints() != 3 {
+		t.Errorf("Expected 3 points in the tree, got %d", tree.GetRootNode().TotalNumberOfPoints())
+	}
+
+	return tree.GetRootNode().GetBoundingBox()
+}
+
+func assertBoundingBox(t *testing.T, actual *geometry.BoundingBox, expected *geometry.BoundingBox) {
+	if actual.Xmin != expected.Xmin || actual.Xmax != expected.Xmax ||
+		actual.Ymin != expected.Ymin || actual.Ymax != expected.Ymax ||
+		actual.Zmin != expected.Zmin || actual.Zmax != expected.Zmax {
+		t.Errorf("Expected bounding box %v, got %v", expected.GetAsArray(), actual.GetAsArray())
+	}
+}
+
+func TestLevelRetentionSamplesTopLevels(t *testing.T) {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		InsertWorkers:      4,
+		LevelRetention:     []float64{0.01, 0.05},
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+
+	for i := 0; i < 1000; i++ {
+		coord := &geometry.Coordinate{X: float64(i%10) * 10, Y: float64((i/10)%10) * 10, Z: float64(i / 100)}
+		tree.AddPoint(coord, 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+
+	root := tree.GetRootNode()
+	if root.NumberOfPoints() != 10 {
+		t.Errorf("Expected 10 points in the root node, got %d", root.NumberOfPoints())
+	}
+
+	levelOnePoints := int64(0)
+	for _, child := range root.GetChildren() {
+		if child != nil {
+			levelOnePoints += child.NumberOfPoints()
+		}
+	}
+	if levelOnePoints < 50 {
+		t.Errorf("Expected at least 50 points in the first level, got %d", levelOnePoints)
+	}
+
+	if total := countStoredPoints(t, root); total != 1000 {
+		t.Errorf("Expected 1000 points stored in the tree, got %d", total)
+	}
+}
+
+// Returns the number of points stored in the subtree, checking that every node having points in its subtree stores
+// some points as well
+func countStoredPoints(t *testing.T, node octree.INode) int64 {
+	if node.TotalNumberOfPoints() > 0 && len(node.GetPoints()) == 0 {
+		t.Errorf("Found node without points having %d points in its subtree", node.TotalNumberOfPoints())
+	}
+	if int(node.NumberOfPoints()) != len(node.GetPoints()) {
+		t.Errorf("Node reports %d points but stores %d", node.NumberOfPoints(), len(node.GetPoints()))
+	}
+
+	total := int64(len(node.GetPoints()))
+	for _, child := range node.GetChildren() {
+		if child != nil {
+			total += countStoredPoints(t, child)
+		}
+	}
+	if total != node.TotalNumberOfPoints() {
+		t.Errorf("Node reports %d points in its subtree but stores %d", node.TotalNumberOfPoints(), total)
+	}
+	return total
+}
+
+func TestIdenticalPointsProduceNonDegenerateRoot(t *testing.T) {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+
+	for i := 0; i < 100; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 5, Y: 5, Z: 5}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+
+	root := tree.GetRootNode()
+	bbox := root.GetBoundingBox()
+	if math.Abs(bbox.Xmax-bbox.Xmin-0.1) > 1e-9 || math.Abs(bbox.Ymax-bbox.Ymin-0.1) > 1e-9 || math.Abs(bbox.Zmax-bbox.Zmin-0.1) > 1e-9 {
+		t.Errorf("Expected root sides as long as the min cell size, got %v", bbox.GetAsArray())
+	}
+	if bbox.Xmid != 5 || bbox.Ymid != 5 || bbox.Zmid != 10 {
+		t.Errorf("Expected root centered on the points, got %v", bbox.GetAsArray())
+	}
+	if geometricError := root.ComputeGeometricError(); math.IsNaN(geometricError) || math.IsInf(geometricError, 0) || geometricError <= 0 {
+		t.Errorf("Expected a positive finite geometric error, got %f", geometricError)
+	}
+	if total := countStoredPoints(t, root); total != 100 {
+		t.Errorf("Expected 100 points stored in the tree, got %d", total)
+	}
+}
+
+func TestTreeRootPercentileMovesOutliersToOverflowNode(t *testing.T) {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		RootPercentile:     0.1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+
+	for i := 0; i < 10000; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 1000 + float64(i%100), Y: 2000 + float64(i/100), Z: 10 + float64(i%7)}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+	// a single stray point would otherwise inflate the root bounding box
+	tree.AddPoint(&geometry.Coordinate{X: 0, Y: 0, Z: 0}, 0, 0, 0, 0, 0, 4326, nil)
+
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+
+	root := tree.GetRootNode()
+	overflow, ok := root.(octree.OverflowNode)
+	if !ok {
+		t.Fatalf("Expected the root to be an overflow node")
+	}
+	if !root.IsRoot() || root.IsLeaf() || root.GetParent() != nil {
+		t.Errorf("Expected the overflow node to be the non leaf tree root")
+	}
+	if len(root.GetPoints()) != 1 || root.GetPoints()[0].X != 0 {
+		t.Errorf("Expected the stray point to be the only overflow point, got %d points", len(root.GetPoints()))
+	}
+	if bbox := root.GetBoundingBox(); bbox.Xmin != 0 || bbox.Ymin != 0 || bbox.Zmin != 0 {
+		t.Errorf("Expected the overflow bounding box to enclose the stray point, got %v", bbox.GetAsArray())
+	}
+
+	core := overflow.GetCoreNode()
+	if core.IsRoot() || core.GetParent() != root || root.GetChildren()[0] != core {
+		t.Errorf("Expected the core node to be the only child of the overflow node")
+	}
+	if bbox := core.GetBoundingBox(); bbox.Xmin < 995 || bbox.Ymin < 1995 || bbox.Zmin < 5 {
+		t.Errorf("Expected the core bounding box to exclude the stray point, got %v", bbox.GetAsArray())
+	}
+	if root.ComputeGeometricError() < core.ComputeGeometricError() {
+		t.Errorf("Expected the overflow geometric error to be at least the core one")
+	}
+	if total := countStoredPoints(t, root); total != 10001 {
+		t.Errorf("Expected 10001 points stored in the tree, got %d", total)
+	}
+}
+
+func TestTreeRootPercentileWithoutOutliersKeepsGridRoot(t *testing.T) {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		RootPercentile:     0.1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+
+	for i := 0; i < 1000; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 5, Y: 5, Z: 5}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	if _, ok := tree.GetRootNode().(*grid_tree.GridNode); !ok || !tree.GetRootNode().IsRoot() {
+		t.Errorf("Expected the grid root to be the tree root when there are no outliers")
+	}
+}
+
+func buildLeafCapTestTree(t *testing.T, leafPointCap int, policy tiler.LeafCapPolicy, coordinates []geometry.Coordinate) octree.ITree {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        1,
+		CellMinSize:        0.3,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+		LeafPointCap:       leafPointCap,
+		LeafCapPolicy:      policy,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+	for i := range coordinates {
+		tree.AddPoint(&coordinates[i], 0, 0, 0, 0, 0, 4326, nil)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	return tree
+}
+
+func getLeafCapTestCoordinates() []geometry.Coordinate {
+	var coordinates []geometry.Coordinate
+	for i := 0; i < 4000; i++ {
+		coordinates = append(coordinates, geometry.Coordinate{X: float64(i%20) * 0.05, Y: float64((i/20)%20) * 0.05, Z: float64(i/400) * 0.1})
+	}
+	return coordinates
+}
+
+func getMaxLeafPoints(node octree.INode) int {
+	max := 0
+	if node.IsLeaf() {
+		max = len(node.GetPoints())
+	}
+	for _, child := range node.GetChildren() {
+		if child != nil {
+			if points := getMaxLeafPoints(child); points > max {
+				max = points
+			}
+		}
+	}
+	return max
+}
+
+func TestTreeLeafCapKeepAllKeepsAllPoints(t *testing.T) {
+	tree := buildLeafCapTestTree(t, 50, tiler.LeafCapKeepAll, getLeafCapTestCoordinates())
+	if total := countStoredPoints(t, tree.GetRootNode()); total != 4000 {
+		t.Errorf("Expected 4000 points stored in the tree, got %d", total)
+	}
+	if max := getMaxLeafPoints(tree.GetRootNode()); max <= 50 {
+		t.Errorf("Expected leaves exceeding the cap to be kept, largest leaf has %d points", max)
+	}
+}
+
+func TestTreeLeafCapRandomSubsamplesLeaves(t *testing.T) {
+	uncapped := buildLeafCapTestTree(t, 0, tiler.LeafCapRandom, getLeafCapTestCoordinates())
+	tree := buildLeafCapTestTree(t, 50, tiler.LeafCapRandom, getLeafCapTestCoordinates())
+
+	if max := getMaxLeafPoints(tree.GetRootNode()); max > 50 {
+		t.Errorf("Expected leaves to hold at most 50 points, largest leaf has %d points", max)
+	}
+	total := countStoredPoints(t, tree.GetRootNode())
+	if total >= 4000 || total != tree.GetRootNode().TotalNumberOfPoints() {
+		t.Errorf("Expected points to be dropped and the totals to be updated, got %d stored and %d reported", total, tree.GetRootNode().TotalNumberOfPoints())
+	}
+	if len(tree.GetRootNode().GetPoints()) != len(uncapped.GetRootNode().GetPoints()) {
+		t.Errorf("Expected the nodes above the min cell size not to be capped")
+	}
+}
+
+func TestTreeLeafCapDensestDropsIsolatedPoints(t *testing.T) {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        0.05,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+		LeafPointCap:       100,
+		LeafCapPolicy:      tiler.LeafCapDensest,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+	// the root cells are smaller than the min cell size, so that the root is a leaf storing all the points
+	for i := 0; i < 100; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 0.01, Y: 0.01, Z: 0.01 + float64(i)*0.0001}, 0, 0, 0, 0, 1, 4326, nil)
+	}
+	for i := 0; i < 20; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 0.5 + float64(i)*0.06, Y: 0.01, Z: 0.01}, 0, 0, 0, 0, 2, 4326, nil)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+
+	root := tree.GetRootNode()
+	if len(root.GetPoints()) != 100 || root.TotalNumberOfPoints() != 100 || root.NumberOfPoints() != 100 {
+		t.Fatalf("Expected 100 points kept, got %d", len(root.GetPoints()))
+	}
+	for _, point := range root.GetPoints() {
+		if point.Classification != 1 {
+			t.Fatalf("Expected only the points of the dense cell to be kept")
+		}
+	}
+}
+
+func buildDensityTestTree(t *testing.T, target float64, mode tiler.DensityMode) octree.ITree {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		InsertWorkers:      2,
+		DensityTarget:      target,
+		DensityMode:        mode,
+		DensityCellSize:    1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+	// a dense cell holding two stacked clusters and a sparse cell holding three points
+	for i := 0; i < 1000; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 0.1 + float64(i%10)*0.08, Y: 0.1 + float64(i/100)*0.08, Z: 0.5 + float64(i/10%10)*0.0001 + float64(i%2)*2}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+	for i := 0; i < 3; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 3.2 + float64(i)*0.2, Y: 0.5, Z: 0.5}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	return tree
+}
+
+func TestDensityTargetCapsDenseCellsPerSquareMeter(t *testing.T) {
+	tree := buildDensityTestTree(t, 10, tiler.DensityArea)
+	if total := countStoredPoints(t, tree.GetRootNode()); total != 13 {
+		t.Errorf("Expected 10 points of the dense cell and the 3 of the sparse one, got %d", total)
+	}
+}
+
+func TestDensityTargetCapsDenseCellsPerCubicMeter(t *testing.T) {
+	tree := buildDensityTestTree(t, 10, tiler.DensityVolume)
+	if total := countStoredPoints(t, tree.GetRootNode()); total != 23 {
+		t.Errorf("Expected 10 points of each stacked cluster and the 3 of the sparse cell, got %d", total)
+	}
+}
+
+func TestZeroDensityTargetKeepsAllPoints(t *testing.T) {
+	tree := buildDensityTestTree(t, 0, tiler.DensityArea)
+	if total := countStoredPoints(t, tree.GetRootNode()); total != 1003 {
+		t.Errorf("Expected all the 1003 points, got %d", total)
+	}
+}
+
+func buildSamplingTestTree(t *testing.T, sampling tiler.GridSampling, reversed bool) octree.ITree {
+	return buildStrategyTestTree(t, sampling, nil, reversed)
+}
+
+// Builds a tree of the lattice of buildSamplingTestTree whose cells keep the points picked by the given strategy, the
+// intensity of the points varying along the lattice
+func buildStrategyTestTree(t *testing.T, sampling tiler.GridSampling, strategy tiler.SamplingStrategy, reversed bool) octree.ITree {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+		GridSampling:       sampling,
+		SamplingStrategy:   strategy,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+	// a lattice of 0.25 m on the plane crossing the centers of the 5 m cells of the root
+	for i := 0; i < 80*80; i++ {
+		index := i
+		if reversed {
+			index = 80*80 - 1 - i
+		}
+		tree.AddPoint(&geometry.Coordinate{X: 0.125 + float64(index%80)*0.25, Y: 0.125 + float64(index/80)*0.25, Z: 2.5}, 0, 0, 0, getStrategyTestIntensity(index), 0, 4326, nil)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	return tree
+}
+
+// Returns the mean distance of the points of the node from the centers of the 5 m cells holding them
+func getMeanDistanceFromCellCenters(node octree.INode) float64 {
+	var sum float64
+	for _, point := range node.GetPoints() {
+		dx, dy := math.Mod(point.X, 5)-2.5, math.Mod(point.Y, 5)-2.5
+		sum += math.Sqrt(dx*dx + dy*dy)
+	}
+	return sum / float64(len(node.GetPoints()))
+}
+
+func TestCenterGridSamplingKeepsThePointsClosestToTheCellCenters(t *testing.T) {
+	root := buildSamplingTestTree(t, tiler.GridSamplingCenter, false).GetRootNode()
+	if len(root.GetPoints()) != 16 {
+		t.Fatalf("Expected a point per root cell, got %d", len(root.GetPoints()))
+	}
+	if distance := getMeanDistanceFromCellCenters(root); distance > 0.2 {
+		t.Errorf("Expected the points closest to the cell centers, got a mean distance of %f m", distance)
+	}
+}
+
+func TestRandomGridSamplingKeepsRandomPointsWhateverTheOrder(t *testing.T) {
+	root := buildSamplingTestTree(t, tiler.GridSamplingRandom, false).GetRootNode()
+	if len(root.GetPoints()) != 16 {
+		t.Fatalf("Expected a point per root cell, got %d", len(root.GetPoints()))
+	}
+	if distance := getMeanDistanceFromCellCenters(root); distance < 1 {
+		t.Errorf("Expected points spread over the cells, got a mean distance of %f m from their centers", distance)
+	}
+
+	kept := make(map[geometry.Coordinate]bool)
+	for _, point := range root.GetPoints() {
+		kept[geometry.Coordinate{X: point.X, Y: point.Y, Z: point.Z}] = true
+	}
+	for _, point := range buildSamplingTestTree(t, tiler.GridSamplingRandom, true).GetRootNode().GetPoints() {
+		if !kept[geometry.Coordinate{X: point.X, Y: point.Y, Z: point.Z}] {
+			t.Errorf("Expected the same points whatever the order of the points, %v was not kept before", point)
+		}
+	}
+}
+
+func TestPoissonGridSamplingSpacesThePointsOfEveryNode(t *testing.T) {
+	tree := buildSamplingTestTree(t, tiler.GridSamplingPoisson, false)
+	root := tree.GetRootNode()
+	if total := countStoredPoints(t, root); total != 80*80 {
+		t.Errorf("Expected all the %d points, got %d", 80*80, total)
+	}
+
+	// the cell size, i.e. the min spacing, halves at every level from the 5 m of the root
+	nodes, spacing := []octree.INode{root}, 5.0
+	for level := 0; level < 3; level++ {
+		var children []octree.INode
+		for _, node := range nodes {
+			points := node.GetPoints()
+			for i := range points {
+				for j := i + 1; j < len(points); j++ {
+					dx, dy, dz := points[i].X-points[j].X, points[i].Y-points[j].Y, points[i].Z-points[j].Z
+					if distance := math.Sqrt(dx*dx + dy*dy + dz*dz); distance < spacing {
+						t.Fatalf("Expected the points of level %d spaced at least %f m apart, got %f m", level, spacing, distance)
+					}
+				}
+			}
+			for _, child := range node.GetChildren() {
+				if child != nil {
+					children = append(children, child)
+				}
+			}
+		}
+		nodes, spacing = children, spacing/2
+	}
+
+	// a maximal set of points 5 m apart in the 20 m square cannot hold fewer points than the 16 cells of the grid
+	if len(root.GetPoints()) < 16 {
+		t.Errorf("Expected at least 16 points in the root, got %d", len(root.GetPoints()))
+	}
+}
+
+func getStrategyTestIntensity(index int) uint8 {
+	return uint8(index * 37 % 251)
+}
+
+// Scores the points by their opposite X coordinate, keeping the easternmost point of every cell
+type eastmostSampling struct{}
+
+func (s eastmostSampling) Score(point *data.Point, centerX float64, centerY float64, centerZ float64, size float64) float64 {
+	return -point.X
+}
+
+func TestFirstGridSamplingKeepsTheFirstPointOfEveryCell(t *testing.T) {
+	root := buildSamplingTestTree(t, tiler.GridSamplingFirst, false).GetRootNode()
+	if len(root.GetPoints()) != 16 {
+		t.Fatalf("Expected a point per root cell, got %d", len(root.GetPoints()))
+	}
+	// the lattice is added row by row, so the first point of every cell is its south western one
+	for _, point := range root.GetPoints() {
+		if math.Mod(point.X, 5) != 0.125 || math.Mod(point.Y, 5) != 0.125 {
+			t.Errorf("Expected the first point of the cell, got %v", point)
+		}
+	}
+}
+
+func TestMaxGridSamplingKeepsThePointOfHighestIntensity(t *testing.T) {
+	highest := make(map[[2]int]uint8)
+	for index := 0; index < 80*80; index++ {
+		cell := [2]int{index % 80 / 20, index / 80 / 20}
+		if intensity := getStrategyTestIntensity(index); intensity > highest[cell] {
+			highest[cell] = intensity
+		}
+	}
+
+	root := buildSamplingTestTree(t, tiler.GridSamplingMax, true).GetRootNode()
+	if len(root.GetPoints()) != 16 {
+		t.Fatalf("Expected a point per root cell, got %d", len(root.GetPoints()))
+	}
+	for _, point := range root.GetPoints() {
+		if cell := [2]int{int(point.X / 5), int(point.Y / 5)}; point.Intensity != highest[cell] {
+			t.Errorf("Expected intensity %d kept by cell %v, got %d", highest[cell], cell, point.Intensity)
+		}
+	}
+}
+
+func TestGridCellsKeepThePointsOfTheGivenStrategy(t *testing.T) {
+	root := buildStrategyTestTree(t, tiler.GridSamplingCenter, eastmostSampling{}, false).GetRootNode()
+	if len(root.GetPoints()) != 16 {
+		t.Fatalf("Expected a point per root cell, got %d", len(root.GetPoints()))
+	}
+	for _, point := range root.GetPoints() {
+		if math.Mod(point.X, 5) != 4.875 {
+			t.Errorf("Expected the easternmost point of the cell, got %v", point)
+		}
+	}
+}
+
+func TestSamplingAttributesAreResolved(t *testing.T) {
+	lasAttributes := []string{"return_number", "gps_time"}
+	expected := map[string]int{"": -1, "Intensity": -1, "gps_time": 1, " return_number": 0}
+	for attribute, index := range expected {
+		if value, ok := tiler.GetSamplingAttributeIndex(attribute, lasAttributes); !ok || value != index {
+			t.Errorf("Expected index %d for attribute %s, got %d %v", index, attribute, value, ok)
+		}
+	}
+	if _, ok := tiler.GetSamplingAttributeIndex("scanner_channel", lasAttributes); ok {
+		t.Errorf("Expected an attribute not written in the batch tables to be rejected")
+	}
+}
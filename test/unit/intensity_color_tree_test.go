@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"testing"
+)
+
+func TestIntensityColorTreeReplacesPointColors(t *testing.T) {
+	ramp, err := octree.ParseColorRamp("")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	inner := &mockTree{}
+	tree := octree.NewIntensityColorTree(inner, ramp)
+	tree.AddPoint(&geometry.Coordinate{X: 1, Y: 2, Z: 3}, 10, 20, 30, 40, 50, 4326, nil)
+
+	point := inner.points[0]
+	if point.R != 40 || point.G != 40 || point.B != 40 {
+		t.Errorf("Expected color 40 40 40, got %d %d %d", point.R, point.G, point.B)
+	}
+	if point.X != 1 || point.Y != 2 || point.Z != 3 || point.Intensity != 40 || point.Classification != 50 || inner.srids[0] != 4326 {
+		t.Errorf("Expected the other point data to be unchanged, got %+v", point)
+	}
+}
+
+func TestColorRampInterpolatesColors(t *testing.T) {
+	ramp, err := octree.ParseColorRamp("#000080, #00ff00,#FF0000")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := map[uint8][3]uint8{
+		0:   {0, 0, 128},
+		64:  {0, 128, 64},
+		255: {255, 0, 0},
+	}
+	for intensity, color := range expected {
+		if r, g, b := ramp.GetColor(intensity); r != color[0] || g != color[1] || b != color[2] {
+			t.Errorf("Expected color %v for intensity %d, got %d %d %d", color, intensity, r, g, b)
+		}
+	}
+}
+
+func TestInvalidColorRampsAreRejected(t *testing.T) {
+	for _, value := range []string{"#ff0000", "#ff0000,blue", "#ff00,#00ff00", "#ff0000,"} {
+		if _, err := octree.ParseColorRamp(value); err == nil {
+			t.Errorf("Expected ramp %s to be rejected", value)
+		}
+	}
+}
+
+func TestIntensityColorTreeStretchesIntensities(t *testing.T) {
+	ramp, _ := octree.ParseColorRamp("")
+	inner := &mockTree{}
+	tree := octree.NewStretchedIntensityColorTree(inner, ramp, 10, 20)
+	for _, intensity := range []uint8{5, 10, 15, 20, 200} {
+		tree.AddPoint(&geometry.Coordinate{X: 1, Y: 2, Z: 3}, 0, 0, 0, intensity, 0, 4326, nil)
+	}
+
+	expected := []uint8{0, 0, 128, 255, 255}
+	for i, point := range inner.points {
+		if point.R != expected[i] || point.G != expected[i] || point.B != expected[i] {
+			t.Errorf("Expected gray %d for intensity %d, got %d %d %d", expected[i], point.Intensity, point.R, point.G, point.B)
+		}
+	}
+	if inner.points[2].Intensity != 15 {
+		t.Errorf("Expected the intensity to be kept, got %d", inner.points[2].Intensity)
+	}
+}
+
+func TestIntensityHistogramComputesPercentiles(t *testing.T) {
+	histogram := octree.NewIntensityHistogram()
+	if percentile := histogram.GetPercentile(50); percentile != 255 {
+		t.Errorf("Expected the highest intensity for an empty histogram, got %d", percentile)
+	}
+	for intensity := 1; intensity <= 100; intensity++ {
+		histogram.AddPoint(&geometry.Coordinate{}, 0, 0, 0, uint8(intensity), 0, 4326, nil)
+	}
+
+	expected := map[float64]uint8{0: 1, 2: 2, 50: 50, 98: 98, 100: 100}
+	for percentile, intensity := range expected {
+		if value := histogram.GetPercentile(percentile); value != intensity {
+			t.Errorf("Expected intensity %d at percentile %v, got %d", intensity, percentile, value)
+		}
+	}
+}
+
+func TestIntensityStretchIsParsed(t *testing.T) {
+	if low, high, err := octree.ParseIntensityStretch(" 2, 98.5"); err != nil || low != 2 || high != 98.5 {
+		t.Errorf("Expected percentiles 2 and 98.5, got %v %v %v", low, high, err)
+	}
+	for _, value := range []string{"", "2", "2,98,99", "a,98", "-1,98", "2,101", "50,50"} {
+		if _, _, err := octree.ParseIntensityStretch(value); err == nil {
+			t.Errorf("Expected stretch %s to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package unit
+
+import (
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/preflight"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/las_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+const orthometricWkt = `COMPD_CS["UTM + NAVD88",PROJCS["UTM"],VERT_CS["NAVD88 height",VERT_DATUM["North American Vertical Datum 1988",2005]]]`
+
+func TestLas14ExtendedPointFormatIsRead(t *testing.T) {
+	tree := readLasTestFile(t, writeLas14TestFile(t), nil)
+
+	if len(tree.points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(tree.points))
+	}
+	point := tree.points[0]
+	if point.X != 1 || point.Y != 2 || point.Z != 3 {
+		t.Errorf("Unexpected coordinates %f %f %f", point.X, point.Y, point.Z)
+	}
+	if point.Classification != 200 || point.Intensity != 2 {
+		t.Errorf("Expected extended classification 200 and intensity 2, got %d and %d", point.Classification, point.Intensity)
+	}
+	if point.R != 10 || point.G != 20 || point.B != 30 {
+		t.Errorf("Unexpected color %d %d %d", point.R, point.G, point.B)
+	}
+}
+
+func TestLas14AttributesAreFiltered(t *testing.T) {
+	filePath := writeLas14TestFile(t)
+	tests := []struct {
+		name           string
+		filter         lidario.PointFilter
+		classification uint8
+	}{
+		{"overlap", las_reader.NewAttributeFilter(true, tiler.ReturnsAll, -1), 2},
+		{"first returns", las_reader.NewAttributeFilter(false, tiler.ReturnsFirst, -1), 200},
+		{"last returns", las_reader.NewAttributeFilter(false, tiler.ReturnsLast, -1), 2},
+		{"scanner channel", las_reader.NewAttributeFilter(false, tiler.ReturnsAll, 2), 200},
+	}
+
+	for _, test := range tests {
+		tree := readLasTestFile(t, filePath, test.filter)
+		if len(tree.points) != 1 || tree.points[0].Classification != test.classification {
+			t.Errorf("%s filter: expected only the point with class %d, got %d points", test.name, test.classification, len(tree.points))
+		}
+	}
+}
+
+func TestAttributeFilterIsNilIfAllPointsAreLoaded(t *testing.T) {
+	if las_reader.NewAttributeFilter(false, tiler.ReturnsAll, -1) != nil {
+		t.Errorf("Expected no filter")
+	}
+}
+
+func TestLas14HeaderAndExtendedVlrsAreRead(t *testing.T) {
+	info, err := preflight.ReadLasFileInfo(writeLas14TestFile(t))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if info.NumberOfPoints != 2 {
+		t.Errorf("Expected 2 points read from the 64-bit point count, got %d", info.NumberOfPoints)
+	}
+	if !info.Orthometric {
+		t.Errorf("Expected orthometric heights declared by the extended VLR WKT")
+	}
+	if !info.HasRgb {
+		t.Errorf("Expected the colors to be stored by the point format 7")
+	}
+}
+
+func TestLegacyClassificationFlagsAreStripped(t *testing.T) {
+	// point format 3: x, y, z, intensity, returns, classification, scan angle, user data, point source, gps time, rgb
+	record := make([]byte, 34)
+	putLasCoordinates(record, 100, 200, 300)
+	binary.LittleEndian.PutUint16(record[12:14], 1024)
+	record[14] = 0x09
+	// withheld ground point
+	record[15] = 0x82
+	binary.LittleEndian.PutUint64(record[20:28], math.Float64bits(10))
+
+	filePath := path.Join(createTempFolder(t), "legacy.las")
+	writeLasTestFile(t, filePath, 2, 3, 34, [][]byte{record}, nil)
+
+	tree := readLasTestFile(t, filePath, nil)
+	if len(tree.points) != 1 || tree.points[0].Classification != 2 || tree.points[0].Intensity != 4 {
+		t.Errorf("Expected one ground point with intensity 4, got %+v", tree.points)
+	}
+}
+
+func TestFlaggedPointsAreDroppedAndSplit(t *testing.T) {
+	// point format 3 records of a withheld, a synthetic and an unflagged ground point
+	var records [][]byte
+	for i, flags := range []byte{0x80, 0x20, 0x00} {
+		record := make([]byte, 34)
+		putLasCoordinates(record, int32(100*i), 200, 300)
+		record[14] = 0x09
+		record[15] = flags | 0x02
+		records = append(records, record)
+	}
+	filePath := path.Join(createTempFolder(t), "flagged.las")
+	writeLasTestFile(t, filePath, 2, 3, 34, records, nil)
+
+	counts := &las_reader.FlagCounts{}
+	tree := readLasTestFile(t, filePath, las_reader.NewFlagFilter(tiler.FlaggedPointsDrop, tiler.FlaggedPointsSplit, tiler.FlaggedPointsKeep, false, counts))
+	if len(tree.points) != 1 || tree.points[0].X != 2 {
+		t.Errorf("Expected only the unflagged point, got %+v", tree.points)
+	}
+	if counts.Withheld != 1 || counts.Synthetic != 1 || counts.KeyPoint != 0 {
+		t.Errorf("Expected one withheld and one synthetic point counted, got %+v", counts)
+	}
+
+	tree = readLasTestFile(t, filePath, las_reader.NewFlagFilter(tiler.FlaggedPointsDrop, tiler.FlaggedPointsSplit, tiler.FlaggedPointsKeep, true, nil))
+	if len(tree.points) != 1 || tree.points[0].X != 1 {
+		t.Errorf("Expected only the synthetic point to be split, got %+v", tree.points)
+	}
+}
+
+func TestFlagFilterIsNilIfAllPointsAreKept(t *testing.T) {
+	if las_reader.NewFlagFilter(tiler.FlaggedPointsKeep, "", tiler.FlaggedPointsKeep, false, nil) != nil {
+		t.Errorf("Expected no filter")
+	}
+}
+
+func readLasTestFile(t *testing.T, filePath string, filter lidario.PointFilter) *mockTree {
+	tree := &mockTree{}
+	if err := las_reader.NewLasReader(nil, storage.NewOsStorage(), 1, 0, filter, nil, nil, nil).Read(filePath, 4326, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return tree
+}
+
+// Writes a LAS 1.4 file with two points in format 7 and an extended VLR holding the WKT of the coordinate system
+func writeLas14TestFile(t *testing.T) string {
+	// point format 7: x, y, z, intensity, returns, flags and channel, classification, user data, scan angle,
+	// point source, gps time, rgb
+	first := make([]byte, 36)
+	putLasCoordinates(first, 100, 200, 300)
+	binary.LittleEndian.PutUint16(first[12:14], 512)
+	// return 1 of 2
+	first[14] = 0x21
+	// overlap flag and scanner channel 2
+	first[15] = 0x28
+	first[16] = 200
+	binary.LittleEndian.PutUint16(first[30:32], 10*256)
+	binary.LittleEndian.PutUint16(first[32:34], 20*256)
+	binary.LittleEndian.PutUint16(first[34:36], 30*256)
+
+	second := make([]byte, 36)
+	putLasCoordinates(second, 400, 500, 600)
+	// return 2 of 2
+	second[14] = 0x22
+	second[16] = 2
+
+	filePath := path.Join(createTempFolder(t), "las14.las")
+	writeLasTestFile(t, filePath, 4, 7, 36, [][]byte{first, second}, []byte(orthometricWkt+"\x00"))
+	return filePath
+}
+
+func putLasCoordinates(record []byte, x, y, z int32) {
+	binary.LittleEndian.PutUint32(record[0:4], uint32(x))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(y))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(z))
+}
+
+// Writes a LAS file of the given minor version and point format with no VLRs and a scale of 0.01. For LAS 1.4 the
+// points are only counted by the 64-bit header field and the given WKT, if any, is stored as an extended VLR.
+func writeLasTestFile(t testing.TB, filePath string, minorVersion byte, format byte, recordLength int, records [][]byte, evlrWkt []byte) {
+	headerSize := 227
+	if minorVersion >= 4 {
+		headerSize = 375
+	}
+	header := make([]byte, headerSize)
+	copy(header[0:4], "LASF")
+	header[24] = 1
+	header[25] = minorVersion
+	binary.LittleEndian.PutUint16(header[94:96], uint16(headerSize))
+	binary.LittleEndian.PutUint32(header[96:100], uint32(headerSize))
+	header[104] = format
+	binary.LittleEndian.PutUint16(header[105:107], uint16(recordLength))
+	for i := 0; i < 3; i++ {
+		binary.LittleEndian.PutUint64(header[131+i*8:139+i*8], math.Float64bits(0.01))
+	}
+	if minorVersion < 4 {
+		binary.LittleEndian.PutUint32(header[107:111], uint32(len(records)))
+	} else {
+		binary.LittleEndian.PutUint64(header[247:255], uint64(len(records)))
+	}
+
+	content := header
+	for _, record := range records {
+		content = append(content, record...)
+	}
+
+	if evlrWkt != nil {
+		binary.LittleEndian.PutUint64(content[235:243], uint64(len(content)))
+		binary.LittleEndian.PutUint32(content[243:247], 1)
+		evlr := make([]byte, 60)
+		copy(evlr[2:18], "LASF_Projection")
+		binary.LittleEndian.PutUint16(evlr[18:20], 2112)
+		binary.LittleEndian.PutUint64(evlr[20:28], uint64(len(evlrWkt)))
+		content = append(append(content, evlr...), evlrWkt...)
+	}
+
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+}
+
+func TestLasFileIsReadInChunks(t *testing.T) {
+	records := createLazTestRecords(1000)
+	filePath := path.Join(createTempFolder(t), "cloud.las")
+	defer func() { _ = os.RemoveAll(path.Dir(filePath)) }()
+	writeLasTestFile(t, filePath, 2, 3, lazTestRecordLength, records, nil)
+	lazPath, _ := writeLazTestFile(t, records, 100)
+	defer func() { _ = os.RemoveAll(path.Dir(lazPath)) }()
+
+	expected := readLasTestFile(t, filePath, nil)
+	for _, file := range []string{filePath, lazPath} {
+		var indexes []int
+		attributes := func(index int, point *lidario.PointAttributes) []float32 {
+			indexes = append(indexes, index)
+			return nil
+		}
+		tree := &mockTree{}
+		if err := las_reader.NewLasReader(nil, storage.NewOsStorage(), 1, 64, nil, attributes, nil, nil).Read(file, 4326, tree); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if !reflect.DeepEqual(tree.points, expected.points) {
+			t.Errorf("Expected the points of %s read in chunks to match the ones read at once", path.Base(file))
+		}
+		for i, index := range indexes {
+			if index != i {
+				t.Fatalf("Expected point %d of %s to be read with index %d, got %d", i, path.Base(file), i, index)
+			}
+		}
+		if len(indexes) != len(records) {
+			t.Errorf("Expected %d indexes, got %d", len(records), len(indexes))
+		}
+	}
+}
+
+func TestLasAttributesAreReadBeforeSidecarAttributes(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	records := createLazTestRecords(50)
+	filePath := path.Join(folder, "cloud.las")
+	writeLasTestFile(t, filePath, 2, 3, lazTestRecordLength, records, nil)
+	// declare the GPS times as adjusted standard GPS times
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	binary.LittleEndian.PutUint16(content[6:8], 1)
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	sidecar := func(index int, point *lidario.PointAttributes) []float32 { return []float32{float32(index)} }
+	attributes := las_reader.NewLasAttributes([]string{"gps_time", "return_number", "number_of_returns"}, sidecar)
+	tree := &mockTree{}
+	if err := las_reader.NewLasReader(nil, storage.NewOsStorage(), 1, 0, nil, attributes, nil, nil).Read(filePath, 4326, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(tree.points) != len(records) {
+		t.Fatalf("Expected %d points, got %d", len(records), len(tree.points))
+	}
+	for i, point := range tree.points {
+		record := records[i]
+		gpsTime := math.Float64frombits(binary.LittleEndian.Uint64(record[20:28]))
+		expected := []float32{float32(math.Mod(gpsTime+1e9, 604800)), float32(record[14] & 0x07), float32(record[14] >> 3 & 0x07), float32(i)}
+		if !reflect.DeepEqual(point.Attributes, expected) {
+			t.Errorf("Expected attributes %v for point %d, got %v", expected, i, point.Attributes)
+		}
+	}
+}
+
+func TestLasAttributesAreParsed(t *testing.T) {
+	if names, ok := tiler.ParseLasAttributes(" GPS_time,return_number "); !ok || !reflect.DeepEqual(names, []string{"gps_time", "return_number"}) {
+		t.Errorf("Expected gps_time and return_number, got %v", names)
+	}
+	for _, value := range []string{"gps_time,gps_time", "scan_angle", "return_number,"} {
+		if _, ok := tiler.ParseLasAttributes(value); ok {
+			t.Errorf("Expected %s to be rejected", value)
+		}
+	}
+}
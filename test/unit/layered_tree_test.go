@@ -0,0 +1,44 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"testing"
+)
+
+func TestLayeredTreeRoutesPointsByClassification(t *testing.T) {
+	trees := make([]*mockTree, 0)
+	layered := octree.NewLayeredTree(func() octree.ITree {
+		tree := &mockTree{}
+		trees = append(trees, tree)
+		return tree
+	})
+
+	layered.AddPoint(&geometry.Coordinate{X: 1}, 0, 0, 0, 0, 6, 4326, nil)
+	layered.AddPoint(&geometry.Coordinate{X: 2}, 0, 0, 0, 0, 2, 4326, nil)
+	layered.AddPoint(&geometry.Coordinate{X: 3}, 0, 0, 0, 0, 6, 4326, nil)
+	if err := layered.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(trees) != 2 {
+		t.Fatalf("Expected a tree per classification, got %d", len(trees))
+	}
+	classifications := layered.GetClassifications()
+	if len(classifications) != 2 || classifications[0] != 2 || classifications[1] != 6 {
+		t.Errorf("Expected classifications [2 6], got %v", classifications)
+	}
+	buildings := layered.GetLayer(6).(*mockTree)
+	if len(buildings.points) != 2 || buildings.points[0].X != 1 || buildings.points[1].X != 3 {
+		t.Errorf("Expected the building points in their own layer, got %d points", len(buildings.points))
+	}
+	if ground := layered.GetLayer(2).(*mockTree); len(ground.points) != 1 {
+		t.Errorf("Expected 1 ground point, got %d", len(ground.points))
+	}
+	if layered.GetLayer(9) != nil {
+		t.Errorf("Expected no layer for a classification without points")
+	}
+	if !layered.IsBuilt() {
+		t.Errorf("Expected the layered tree to be built")
+	}
+}
@@ -0,0 +1,280 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/laszip"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/las_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Point format 3 with two extra bytes
+const lazTestRecordLength = 36
+
+func TestLazFileIsDecompressed(t *testing.T) {
+	records := createLazTestRecords(2500)
+	filePath, offset := writeLazTestFile(t, records, 1000)
+	defer func() { _ = os.RemoveAll(path.Dir(filePath)) }()
+
+	vlr, _ := laszip.NewVlr(3, lazTestRecordLength, 1000)
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	defer func() { _ = file.Close() }()
+	decompressed, err := laszip.Decompress(file, offset, 2500, lazTestRecordLength, vlr, 4, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	for i := 0; i < 2500; i++ {
+		record := decompressed[i*lazTestRecordLength : (i+1)*lazTestRecordLength]
+		if !bytes.Equal(record, records[i]) {
+			t.Fatalf("Point %d decompressed as %v instead of %v", i, record, records[i])
+		}
+	}
+}
+
+func TestLazFileIsDecompressedInBatchesOfWholeChunks(t *testing.T) {
+	records := createLazTestRecords(2500)
+	filePath, offset := writeLazTestFile(t, records, 300)
+	defer func() { _ = os.RemoveAll(path.Dir(filePath)) }()
+
+	vlr, _ := laszip.NewVlr(3, lazTestRecordLength, 300)
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	defer func() { _ = file.Close() }()
+	var firsts []int
+	var decompressed []byte
+	err = laszip.DecompressBatches(file, offset, 2500, lazTestRecordLength, vlr, 2, 1000, nil, func(first int, batch []byte) error {
+		firsts = append(firsts, first)
+		decompressed = append(decompressed, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	// batches of 4 chunks of 300 points reach 1000 points, the last one holding the remaining 100 points
+	if !reflect.DeepEqual(firsts, []int{0, 1200, 2400}) {
+		t.Errorf("Expected batches starting at points 0, 1200 and 2400, got %v", firsts)
+	}
+	if !bytes.Equal(decompressed, bytes.Join(records, nil)) {
+		t.Errorf("Expected the batches to hold all the points in order")
+	}
+}
+
+func TestLazFileIsReadLikeItsLasFile(t *testing.T) {
+	records := createLazTestRecords(300)
+	lazPath, _ := writeLazTestFile(t, records, 128)
+	defer func() { _ = os.RemoveAll(path.Dir(lazPath)) }()
+	lasPath := path.Join(path.Dir(lazPath), "cloud.las")
+	writeLasTestFile(t, lasPath, 2, 3, lazTestRecordLength, records, nil)
+
+	lazTree, lasTree := readLasTestFile(t, lazPath, nil), readLasTestFile(t, lasPath, nil)
+	if len(lazTree.points) != 300 || len(lasTree.points) != 300 {
+		t.Fatalf("Expected 300 points, got %d and %d", len(lazTree.points), len(lasTree.points))
+	}
+	for i := range lasTree.points {
+		if !reflect.DeepEqual(lazTree.points[i], lasTree.points[i]) {
+			t.Fatalf("Point %d read as %v instead of %v", i, lazTree.points[i], lasTree.points[i])
+		}
+	}
+}
+
+func TestTruncatedLazFileIsRejected(t *testing.T) {
+	filePath, _ := writeLazTestFile(t, createLazTestRecords(300), 100)
+	defer func() { _ = os.RemoveAll(path.Dir(filePath)) }()
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := ioutil.WriteFile(filePath, content[:len(content)/2], 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if err := las_reader.NewLasReader(nil, storage.NewOsStorage(), 1, 0, nil, nil, nil, nil).Read(filePath, 4326, &mockTree{}); err == nil {
+		t.Errorf("Expected an error reading a truncated LAZ file")
+	}
+}
+
+func TestLazCompressionOfExtendedPointFormatsIsNotSupported(t *testing.T) {
+	if _, err := laszip.NewVlr(6, 30, laszip.DefaultChunkSize); err == nil {
+		t.Errorf("Expected an error compressing the point format 6")
+	}
+	vlr, err := laszip.ParseVlr((&laszip.Vlr{Compressor: 3, ChunkSize: 1, Items: []laszip.Item{{Type: 10, Size: 30, Version: 3}}}).Bytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if _, err := laszip.Decompress(bytes.NewReader(nil), 0, 1, 30, vlr, 1, nil); err == nil {
+		t.Errorf("Expected an error decompressing points with the layered compressor")
+	}
+}
+
+// Creates records of point format 3 with two extra bytes, varying every field the way scans do: coordinates walking
+// along the scan lines, multiple returns, GPS times repeating, increasing and jumping, and colors changing per channel
+func createLazTestRecords(count int) [][]byte {
+	random := rand.New(rand.NewSource(7))
+	var records [][]byte
+	x, y, z := int32(500000), int32(4600000), int32(1000)
+	gpsTime, r, g, b := 1.5e8, uint16(100*256), uint16(120*256), uint16(140*256)
+	for i := 0; i < count; i++ {
+		record := make([]byte, lazTestRecordLength)
+		x += int32(random.Intn(200) - 50)
+		y += int32(random.Intn(20) - 10)
+		z += int32(random.Intn(100) - 50)
+		if i%97 == 0 {
+			// jump to another scan line
+			x -= 1 << 20
+			z += 1 << 16
+		}
+		putLasCoordinates(record, x, y, z)
+		binary.LittleEndian.PutUint16(record[12:14], uint16(random.Intn(4096)))
+		returns := byte(random.Intn(3) + 1)
+		record[14] = byte(random.Intn(int(returns))+1) | returns<<3 | byte(i%2)<<6
+		record[15] = byte([]int{1, 2, 2, 2, 6}[random.Intn(5)])
+		record[16] = byte(int8(random.Intn(60) - 30))
+		record[17] = byte(i / 500)
+		binary.LittleEndian.PutUint16(record[18:20], uint16(i/1000+1))
+
+		switch {
+		case i%211 == 0:
+			gpsTime += 1e6
+		case i%3 != 0:
+			gpsTime += 1e-5 * float64(random.Intn(3)+1)
+		}
+		recordTime := gpsTime
+		if i%5 == 4 {
+			// interleaved sequence of another scanner
+			recordTime = gpsTime + 300
+		}
+		binary.LittleEndian.PutUint64(record[20:28], math.Float64bits(recordTime))
+
+		if i%4 == 0 {
+			r += uint16(random.Intn(512)) - 256
+			g += uint16(random.Intn(512)) - 256
+			b = r
+		}
+		binary.LittleEndian.PutUint16(record[28:30], r)
+		binary.LittleEndian.PutUint16(record[30:32], g)
+		binary.LittleEndian.PutUint16(record[32:34], b)
+		record[34] = byte(random.Intn(4))
+		record[35] = byte(i)
+		records = append(records, record)
+	}
+	return records
+}
+
+// Writes a LAZ 1.2 file in point format 3 with the given chunk size and no other VLRs than the LASzip one, returning
+// its path and the offset to its points
+func writeLazTestFile(t testing.TB, records [][]byte, chunkSize uint32) (string, int64) {
+	vlr, err := laszip.NewVlr(3, lazTestRecordLength, chunkSize)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	vlrData := vlr.Bytes()
+	headerSize := 227
+	offset := headerSize + 54 + len(vlrData)
+
+	header := make([]byte, headerSize)
+	copy(header[0:4], "LASF")
+	header[24] = 1
+	header[25] = 2
+	binary.LittleEndian.PutUint16(header[94:96], uint16(headerSize))
+	binary.LittleEndian.PutUint32(header[96:100], uint32(offset))
+	binary.LittleEndian.PutUint32(header[100:104], 1)
+	header[104] = 3 | 0x80
+	binary.LittleEndian.PutUint16(header[105:107], lazTestRecordLength)
+	binary.LittleEndian.PutUint32(header[107:111], uint32(len(records)))
+	for i := 0; i < 3; i++ {
+		binary.LittleEndian.PutUint64(header[131+i*8:139+i*8], math.Float64bits(0.01))
+	}
+
+	vlrHeader := make([]byte, 54)
+	copy(vlrHeader[2:18], laszip.VlrUserId)
+	binary.LittleEndian.PutUint16(vlrHeader[18:20], laszip.VlrRecordId)
+	binary.LittleEndian.PutUint16(vlrHeader[20:22], uint16(len(vlrData)))
+
+	points, err := laszip.Compress(bytes.Join(records, nil), int64(offset), vlr)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	content := append(append(append(header, vlrHeader...), vlrData...), points...)
+	filePath := path.Join(createTempFolder(t), "cloud.laz")
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return filePath, int64(offset)
+}
+
+// Folder of the LAZ files compressed by LASzip, each one next to the LAS file it was compressed from, written by
+// scripts/make_laszip_fixtures.sh
+const laszipFixturesFolder = "testdata/laszip"
+
+// Returns the offset, record length, number of points and point data of the given LAS or LAZ file, along with its
+// LASzip VLR if it is compressed
+func readLasFixture(t *testing.T, filePath string) (int64, int, int, []byte, *laszip.Vlr) {
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	headerSize := int(binary.LittleEndian.Uint16(content[94:96]))
+	offset := int64(binary.LittleEndian.Uint32(content[96:100]))
+	recordLength := int(binary.LittleEndian.Uint16(content[105:107]))
+	points := int(binary.LittleEndian.Uint32(content[107:111]))
+
+	var vlr *laszip.Vlr
+	for i, position := 0, headerSize; i < int(binary.LittleEndian.Uint32(content[100:104])); i++ {
+		userId := string(bytes.TrimRight(content[position+2:position+18], "\x00"))
+		recordId := binary.LittleEndian.Uint16(content[position+18 : position+20])
+		length := int(binary.LittleEndian.Uint16(content[position+20 : position+22]))
+		if userId == laszip.VlrUserId && recordId == laszip.VlrRecordId {
+			if vlr, err = laszip.ParseVlr(content[position+54 : position+54+length]); err != nil {
+				t.Fatalf("Unexpected error parsing the LASzip VLR of %s: %s", filePath, err.Error())
+			}
+		}
+		position += 54 + length
+	}
+	return offset, recordLength, points, content[offset:], vlr
+}
+
+func TestLaszipFixturesDecompressToTheirLasFiles(t *testing.T) {
+	lazFiles, _ := filepath.Glob(path.Join(laszipFixturesFolder, "*.laz"))
+	if len(lazFiles) == 0 {
+		t.Skip("no LASzip fixture in " + laszipFixturesFolder + ", see scripts/make_laszip_fixtures.sh")
+	}
+	for _, lazFile := range lazFiles {
+		// the chunked variants are compressed from the same LAS file as the default ones
+		lasFile := strings.TrimSuffix(strings.TrimSuffix(lazFile, ".laz"), "_chunked") + ".las"
+		offset, recordLength, points, _, vlr := readLasFixture(t, lazFile)
+		_, lasRecordLength, lasPoints, lasData, _ := readLasFixture(t, lasFile)
+		if vlr == nil || recordLength != lasRecordLength || points != lasPoints {
+			t.Fatalf("Expected %s to be the LASzip compression of %s", lazFile, lasFile)
+		}
+
+		file, err := os.Open(lazFile)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		decompressed, err := laszip.Decompress(file, offset, points, recordLength, vlr, 2, nil)
+		_ = file.Close()
+		if err != nil {
+			t.Fatalf("Unexpected error decompressing %s: %s", lazFile, err.Error())
+		}
+		for i := 0; i < points; i++ {
+			record, expected := decompressed[i*recordLength:(i+1)*recordLength], lasData[i*recordLength:(i+1)*recordLength]
+			if !bytes.Equal(record, expected) {
+				t.Fatalf("Point %d of %s decompressed as %v instead of %v", i, lazFile, record, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/ledger"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"path"
+	"testing"
+)
+
+func TestHashFileMatchesFilesWithSameContent(t *testing.T) {
+	osStorage := storage.NewOsStorage()
+	folder := createTempFolder(t)
+	writeLedgerTestFile(t, osStorage, path.Join(folder, "a.las"), "points")
+	writeLedgerTestFile(t, osStorage, path.Join(folder, "copy_of_a.las"), "points")
+	writeLedgerTestFile(t, osStorage, path.Join(folder, "b.las"), "other points")
+
+	hashA := hashLedgerTestFile(t, osStorage, path.Join(folder, "a.las"))
+	if hashCopy := hashLedgerTestFile(t, osStorage, path.Join(folder, "copy_of_a.las")); hashCopy != hashA {
+		t.Errorf("Expected files with the same content to have the same hash, got %s and %s", hashA, hashCopy)
+	}
+	if hashB := hashLedgerTestFile(t, osStorage, path.Join(folder, "b.las")); hashB == hashA {
+		t.Errorf("Expected files with different content to have different hashes")
+	}
+}
+
+func TestLedgerIsEmptyIfNotExisting(t *testing.T) {
+	l, err := ledger.Load(storage.NewOsStorage(), path.Join(createTempFolder(t), ledger.FileName))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(l.Entries) != 0 || len(l.Skipped) != 0 {
+		t.Errorf("Expected empty ledger, got %d entries and %d skipped files", len(l.Entries), len(l.Skipped))
+	}
+	if _, ok := l.Lookup("hash"); ok {
+		t.Errorf("Expected no entry in empty ledger")
+	}
+}
+
+func TestLedgerKeepsProcessedFilesAcrossRuns(t *testing.T) {
+	osStorage := storage.NewOsStorage()
+	ledgerPath := path.Join(createTempFolder(t), ledger.FileName)
+
+	l, _ := ledger.Load(osStorage, ledgerPath)
+	l.Record("hash", "input/a.las", "a")
+	entry, _ := l.Lookup("hash")
+	l.Skip("input/copy_of_a.las", entry)
+	if err := l.Save(osStorage, ledgerPath); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	reloaded, err := ledger.Load(osStorage, ledgerPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	entry, ok := reloaded.Lookup("hash")
+	if !ok {
+		t.Fatalf("Expected processed file to be found in the reloaded ledger")
+	}
+	if entry.File != "input/a.las" || entry.Tileset != "a" || entry.Processed.IsZero() {
+		t.Errorf("Unexpected ledger entry %+v", entry)
+	}
+	if len(reloaded.Skipped) != 0 {
+		t.Errorf("Expected skipped files of the previous run not to be reported, got %d", len(reloaded.Skipped))
+	}
+}
+
+func TestLedgerReportsSkippedDuplicates(t *testing.T) {
+	l, _ := ledger.Load(storage.NewOsStorage(), path.Join(createTempFolder(t), ledger.FileName))
+	l.Record("hash", "a.las", "a")
+	entry, _ := l.Lookup("hash")
+	l.Skip("copy_of_a.las", entry)
+
+	if len(l.Skipped) != 1 {
+		t.Fatalf("Expected 1 skipped file, got %d", len(l.Skipped))
+	}
+	skipped := l.Skipped[0]
+	if skipped.File != "copy_of_a.las" || skipped.DuplicateOf != "a.las" || skipped.Tileset != "a" || skipped.Hash != "hash" {
+		t.Errorf("Unexpected skipped file report %+v", skipped)
+	}
+}
+
+func writeLedgerTestFile(t *testing.T, s storage.Storage, filePath string, content string) {
+	if err := s.WriteFile(filePath, []byte(content), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+}
+
+func hashLedgerTestFile(t *testing.T, s storage.Storage, filePath string) string {
+	hash, err := ledger.HashFile(s, filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return hash
+}
@@ -0,0 +1,356 @@
+package unit
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/pkg/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/pkg/tileset"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLibraryTilerWritesTileset(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(1000), nil)
+	output := path.Join(folder, "output")
+	if err := os.Mkdir(output, 0777); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	counts := map[tiler.EventType]int{}
+	var sequence uint64
+	progress := func(event tiler.Event) {
+		counts[event.Type]++
+		if event.Sequence != sequence+1 {
+			t.Errorf("Expected event %d to follow event %d", event.Sequence, sequence)
+		}
+		sequence = event.Sequence
+	}
+	err := tiler.New(tiler.WithSrid(32633), tiler.WithCellSizes(1, 10), tiler.WithProgress(progress)).Run(context.Background(), path.Join(folder, "cloud.las"), output)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if _, err := os.Stat(path.Join(output, "cloud", "tileset.json")); err != nil {
+		t.Errorf("Expected the tileset.json file to be written, got %s", err.Error())
+	}
+	if counts[tiler.FileStarted] != 1 || counts[tiler.FileFinished] != 1 {
+		t.Errorf("Expected one file started and one file finished event, got %d and %d", counts[tiler.FileStarted], counts[tiler.FileFinished])
+	}
+	if counts[tiler.TileWritten] == 0 {
+		t.Errorf("Expected tile written events")
+	}
+}
+
+func TestLibraryTilerWritesPntsOfTheirByteLength(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(1000), nil)
+	if err := tiler.New(tiler.WithSrid(32633), tiler.WithCellSizes(1, 10)).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// the default layout accounts for the batch table in the byte length and aligns every section to 8 bytes
+	contents := 0
+	err := filepath.Walk(path.Join(folder, "cloud"), func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || path.Ext(filePath) != ".pnts" {
+			return err
+		}
+		pnts, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		contents++
+		if byteLength := int(binary.LittleEndian.Uint32(pnts[8:12])); byteLength != len(pnts) {
+			t.Errorf("Expected the byte length of %s to be its size %d, got %d", filePath, len(pnts), byteLength)
+		}
+		offset := 28
+		for i := 0; i < 4; i++ {
+			offset += int(binary.LittleEndian.Uint32(pnts[12+i*4:]))
+			if offset%8 != 0 {
+				t.Errorf("Expected the section %d of %s to end on an 8-byte boundary, ends at %d", i, filePath, offset)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if contents == 0 {
+		t.Errorf("Expected pnts contents to be written")
+	}
+}
+
+func TestLibraryTilerStopsWhenContextIsDone(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(1000), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := tiler.New(tiler.WithSrid(32633)).Run(ctx, path.Join(folder, "cloud.las"), folder)
+	if err != context.Canceled {
+		t.Errorf("Expected the job to return the context error, got %v", err)
+	}
+	if _, err := os.Stat(path.Join(folder, "cloud", "tileset.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no tileset.json file to be written by the cancelled job")
+	}
+}
+
+func TestLibraryTilerFailsOnTruncatedFiles(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	filePath := path.Join(folder, "cloud.las")
+	writeLasTestFile(t, filePath, 2, 3, lazTestRecordLength, createLazTestRecords(1000), nil)
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := ioutil.WriteFile(filePath, content[:len(content)-900*lazTestRecordLength], 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	err = tiler.New(tiler.WithSrid(32633)).Run(context.Background(), filePath, folder)
+	if err == nil || !strings.Contains(err.Error(), "truncated point data") {
+		t.Errorf("Expected a truncated point data error, got %v", err)
+	}
+	if _, err := os.Stat(path.Join(folder, "cloud", "tileset.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no tileset.json file to be written for the truncated file")
+	}
+}
+
+func TestLibraryTilerRejectsInvalidCellSizes(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(10), nil)
+
+	if err := tiler.New(tiler.WithCellSizes(10, 1)).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err == nil {
+		t.Errorf("Expected an error for a min cell size greater than the max one")
+	}
+}
+
+func TestLibraryTilerMergesInputFiles(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	records := createLazTestRecords(1000)
+	writeLasTestFile(t, path.Join(folder, "a.las"), 2, 3, lazTestRecordLength, records[:600], nil)
+	writeLasTestFile(t, path.Join(folder, "b.las"), 2, 3, lazTestRecordLength, records[600:], nil)
+	output := path.Join(folder, "output")
+	if err := os.Mkdir(output, 0777); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	var points int64
+	progress := func(event tiler.Event) {
+		if event.Type == tiler.PointsRead {
+			points = event.Points
+		}
+	}
+	input := path.Join(folder, "a.las") + string(os.PathListSeparator) + path.Join(folder, "b.las")
+	err := tiler.New(tiler.WithSrid(32633), tiler.WithMerge(true), tiler.WithProgress(progress)).Run(context.Background(), input, output)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if _, err := os.Stat(path.Join(output, "merged", "tileset.json")); err != nil {
+		t.Errorf("Expected the merged tileset.json file to be written, got %s", err.Error())
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := os.Stat(path.Join(output, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected no tileset to be written for %s", name)
+		}
+	}
+	if points != 1000 {
+		t.Errorf("Expected the points of both files to be read, got %d", points)
+	}
+}
+
+func TestLibraryTilerListsMergedScans(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	records := createLazTestRecords(1000)
+	writeLasTestFile(t, path.Join(folder, "a.las"), 2, 3, lazTestRecordLength, records[:600], nil)
+	writeLasTestFile(t, path.Join(folder, "b.las"), 2, 3, lazTestRecordLength, records[600:], nil)
+	poses := path.Join(folder, "poses.csv")
+	if err := ioutil.WriteFile(poses, []byte("b,1,2,3,0,0,0,1\n"), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	output := path.Join(folder, "output")
+	if err := os.Mkdir(output, 0777); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	input := path.Join(folder, "a.las") + string(os.PathListSeparator) + path.Join(folder, "b.las")
+	err := tiler.New(tiler.WithSrid(32633), tiler.WithMerge(true), tiler.WithScanIds(true, poses)).Run(context.Background(), input, output)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	content, err := ioutil.ReadFile(path.Join(output, "merged", "tileset.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var written struct {
+		Asset struct {
+			Extras struct {
+				Scans []struct {
+					Id     int
+					Name   string
+					Points int64
+					Pose   *struct{ Position [3]float64 }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(content, &written); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	scans := written.Asset.Extras.Scans
+	if len(scans) != 2 {
+		t.Fatalf("Expected 2 scans in the extras of the root tileset, got %d", len(scans))
+	}
+	if scans[0].Id != 0 || scans[0].Name != "a.las" || scans[0].Points != 600 || scans[0].Pose != nil {
+		t.Errorf("Unexpected first scan %+v", scans[0])
+	}
+	if scans[1].Id != 1 || scans[1].Points != 400 || scans[1].Pose == nil || scans[1].Pose.Position != [3]float64{1, 2, 3} {
+		t.Errorf("Unexpected second scan %+v", scans[1])
+	}
+}
+
+func TestLibraryTilerRejectsScanIdsOfUnmergedFiles(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(10), nil)
+
+	if err := tiler.New(tiler.WithScanIds(true, "")).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err == nil {
+		t.Errorf("Expected an error for scan ids of files not merged")
+	}
+}
+
+func TestLibraryTilerWritesReplaceRefinedTileset(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(1000), nil)
+
+	err := tiler.New(tiler.WithSrid(32633), tiler.WithCellSizes(1, 10), tiler.WithRefineMode("REPLACE")).Run(context.Background(), path.Join(folder, "cloud.las"), folder)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	content, err := ioutil.ReadFile(path.Join(folder, "cloud", "tileset.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var tileset struct {
+		Root struct {
+			Refine string `json:"refine"`
+		} `json:"root"`
+	}
+	if err := json.Unmarshal(content, &tileset); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if tileset.Root.Refine != "REPLACE" {
+		t.Errorf("Expected the REPLACE refine mode, got %s", tileset.Root.Refine)
+	}
+}
+
+func TestLibraryTilerRejectsInvalidRefineMode(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(10), nil)
+
+	if err := tiler.New(tiler.WithRefineMode("MIXED")).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err == nil {
+		t.Errorf("Expected an error for an invalid refine mode")
+	}
+}
+
+func TestLibraryTilerRejectsUnknownCrsBackend(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(10), nil)
+
+	if err := tiler.New(tiler.WithCrs("", "unknown")).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err == nil {
+		t.Errorf("Expected an error for an unknown coordinate converter backend")
+	}
+}
+
+func TestLibraryTilerRejectsMissingGeoidGrid(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(10), nil)
+
+	options := []tiler.Option{tiler.WithElevation(0, true), tiler.WithGeoidModel(path.Join(folder, "missing.gtx"))}
+	if err := tiler.New(options...).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err == nil {
+		t.Errorf("Expected an error for a missing geoid grid")
+	}
+}
+
+func TestLibraryTilerRejectsDensityCellsTooSmall(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(10), nil)
+
+	if err := tiler.New(tiler.WithDensityTarget(0.5, 1, false)).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err == nil {
+		t.Errorf("Expected an error for density cells holding less than a point")
+	}
+}
+
+func TestLibraryTilerWritesSpooledGridTileset(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(1000), nil)
+	spool := path.Join(folder, "spool")
+	if err := os.Mkdir(spool, 0777); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if err := tiler.New(tiler.WithSrid(32633), tiler.WithCellSizes(1, 10), tiler.WithGridSpool(true, spool)).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	opened, err := tileset.Open(path.Join(folder, "cloud"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if points, err := opened.Nearest(0, 0, 0, 1000000, tileset.FullResolution); err != nil || len(points) != 1000 {
+		t.Errorf("Expected the 1000 points in the tileset, got %d, %v", len(points), err)
+	}
+	if entries, _ := ioutil.ReadDir(spool); len(entries) != 0 {
+		t.Errorf("Expected the spool files to be removed, found %d entries", len(entries))
+	}
+}
+
+// Keeps the highest point of every grid cell, counting the points it scores
+type highestPointSampling struct {
+	scored int64
+}
+
+func (s *highestPointSampling) Score(point *tiler.Point, centerX float64, centerY float64, centerZ float64, size float64) float64 {
+	atomic.AddInt64(&s.scored, 1)
+	return -point.Z
+}
+
+func TestLibraryTilerSamplesWithTheGivenStrategy(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(1000), nil)
+
+	strategy := &highestPointSampling{}
+	if err := tiler.New(tiler.WithSrid(32633), tiler.WithCellSizes(1, 10), tiler.WithSamplingStrategy(strategy)).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if atomic.LoadInt64(&strategy.scored) == 0 {
+		t.Errorf("Expected the points to be scored by the strategy")
+	}
+
+	err := tiler.New(tiler.WithGridSampling("POISSON"), tiler.WithSamplingStrategy(strategy)).Run(context.Background(), path.Join(folder, "cloud.las"), folder)
+	if err == nil {
+		t.Errorf("Expected an error for a sampling strategy combined with the POISSON sampling")
+	}
+}
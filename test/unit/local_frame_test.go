@@ -0,0 +1,49 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"math"
+	"testing"
+)
+
+func TestLocalFrameOriginIsZero(t *testing.T) {
+	origin := geometry.Coordinate{X: 4586042.631136, Y: 1126398.922751, Z: 4272825.711405}
+	frame := geometry.NewLocalFrame(origin, 13.7995147, 42.3306312)
+
+	local := frame.FromEcef(origin)
+	if math.Abs(local.X) > 1e-9 || math.Abs(local.Y) > 1e-9 || math.Abs(local.Z) > 1e-9 {
+		t.Errorf("Expected origin to map to (0,0,0), got (%f,%f,%f)", local.X, local.Y, local.Z)
+	}
+}
+
+func TestLocalFrameAxes(t *testing.T) {
+	// frame at lon 0, lat 0: east is +Y, north is +Z, up is +X in ECEF
+	origin := geometry.Coordinate{X: 6378137, Y: 0, Z: 0}
+	frame := geometry.NewLocalFrame(origin, 0, 0)
+
+	local := frame.FromEcef(geometry.Coordinate{X: 6378137 + 3, Y: 1, Z: 2})
+	if math.Abs(local.X-1) > 1e-9 || math.Abs(local.Y-2) > 1e-9 || math.Abs(local.Z-3) > 1e-9 {
+		t.Errorf("Expected local coordinate (1,2,3), got (%f,%f,%f)", local.X, local.Y, local.Z)
+	}
+}
+
+func TestLocalFrameTransformRoundTrip(t *testing.T) {
+	origin := geometry.Coordinate{X: 4586042.631136, Y: 1126398.922751, Z: 4272825.711405}
+	frame := geometry.NewLocalFrame(origin, 13.7995147, 42.3306312)
+	ecef := geometry.Coordinate{X: 4586052.5, Y: 1126380.25, Z: 4272830.75}
+
+	local := frame.FromEcef(ecef)
+	m := frame.GetTransform()
+	if len(m) != 16 {
+		t.Fatalf("Expected 16 elements in transform, got %d", len(m))
+	}
+
+	// column-major 4x4 matrix product
+	x := m[0]*local.X + m[4]*local.Y + m[8]*local.Z + m[12]
+	y := m[1]*local.X + m[5]*local.Y + m[9]*local.Z + m[13]
+	z := m[2]*local.X + m[6]*local.Y + m[10]*local.Z + m[14]
+
+	if math.Abs(x-ecef.X) > 1e-6 || math.Abs(y-ecef.Y) > 1e-6 || math.Abs(z-ecef.Z) > 1e-6 {
+		t.Errorf("Expected (%f,%f,%f) after transform, got (%f,%f,%f)", ecef.X, ecef.Y, ecef.Z, x, y, z)
+	}
+}
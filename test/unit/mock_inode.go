@@ -19,7 +19,7 @@ type mockNode struct {
 	internalSrid        int
 	depth               uint8
 	globalChildrenCount int64
-	localChildrenCount  int32
+	localChildrenCount  int64
 	opts                *tiler.TilerOptions
 	leaf                bool
 	initialized         bool
@@ -67,7 +67,7 @@ func (mockNode *mockNode) TotalNumberOfPoints() int64 {
 	return mockNode.globalChildrenCount
 }
 
-func (mockNode *mockNode) NumberOfPoints() int32 {
+func (mockNode *mockNode) NumberOfPoints() int64 {
 	return mockNode.localChildrenCount
 }
 
@@ -86,3 +86,13 @@ func (mockNode *mockNode) ComputeGeometricError() float64 {
 func (mockNode *mockNode) GetParent() octree.INode {
 	return mockNode.parent
 }
+
+// mock implementation of the BundleNode interface holding the points of the bundled nodes
+type mockBundleNode struct {
+	*mockNode
+	nodes []octree.INode
+}
+
+func (mockBundleNode *mockBundleNode) GetBundledNodes() []octree.INode {
+	return mockBundleNode.nodes
+}
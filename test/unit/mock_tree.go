@@ -0,0 +1,32 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+)
+
+// mock implementation of the ITree interface that records the added points
+type mockTree struct {
+	points []*data.Point
+	srids  []int
+}
+
+func (mockTree *mockTree) Build() error {
+	return nil
+}
+
+func (mockTree *mockTree) GetRootNode() octree.INode {
+	return nil
+}
+
+func (mockTree *mockTree) IsBuilt() bool {
+	return false
+}
+
+func (mockTree *mockTree) AddPoint(coordinate *geometry.Coordinate, r uint8, g uint8, b uint8, intensity uint8, classification uint8, srid int, attributes []float32) {
+	point := data.NewPoint(coordinate.X, coordinate.Y, coordinate.Z, r, g, b, intensity, classification)
+	point.Attributes = attributes
+	mockTree.points = append(mockTree.points, point)
+	mockTree.srids = append(mockTree.srids, srid)
+}
@@ -0,0 +1,77 @@
+package unit
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"testing"
+)
+
+func TestOverviewTilesetReferencesTheLayers(t *testing.T) {
+	ground := &mockNode{
+		boundingBox:    geometry.NewBoundingBox(10, 11, 45, 46, 0, 10),
+		internalSrid:   4326,
+		geometricError: 20,
+	}
+	buildings := &mockNode{
+		boundingBox:    geometry.NewBoundingBox(10.5, 12, 44, 45.5, 5, 30),
+		internalSrid:   4326,
+		geometricError: 10,
+	}
+	layers := []io.OverviewLayer{
+		{Name: data.GetClassificationName(2), Uri: "ground/tileset.json", Root: ground},
+		{Name: data.GetClassificationName(6), Uri: "buildings/tileset.json", Root: buildings},
+	}
+
+	content, err := io.GenerateOverviewTileset(layers, proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var tileset struct {
+		GeometricError float64 `json:"geometricError"`
+		Root           struct {
+			BoundingVolume struct {
+				Region []float64 `json:"region"`
+			} `json:"boundingVolume"`
+			Children []struct {
+				Content struct {
+					Uri    string            `json:"uri"`
+					Extras map[string]string `json:"extras"`
+				} `json:"content"`
+				BoundingVolume struct {
+					Region []float64 `json:"region"`
+				} `json:"boundingVolume"`
+			} `json:"children"`
+		} `json:"root"`
+	}
+	if err := json.Unmarshal(content, &tileset); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if tileset.GeometricError != 20 {
+		t.Errorf("Expected the geometric error of the coarsest layer, got %f", tileset.GeometricError)
+	}
+	children := tileset.Root.Children
+	if len(children) != 2 {
+		t.Fatalf("Expected 2 children, got %d", len(children))
+	}
+	if children[0].Content.Uri != "ground/tileset.json" || children[0].Content.Extras["layer"] != "ground" {
+		t.Errorf("Unexpected ground content %s %v", children[0].Content.Uri, children[0].Content.Extras)
+	}
+	if children[1].Content.Uri != "buildings/tileset.json" || children[1].Content.Extras["layer"] != "buildings" {
+		t.Errorf("Unexpected buildings content %s %v", children[1].Content.Uri, children[1].Content.Extras)
+	}
+
+	region := tileset.Root.BoundingVolume.Region
+	groundRegion, buildingsRegion := children[0].BoundingVolume.Region, children[1].BoundingVolume.Region
+	expected := []float64{groundRegion[0], buildingsRegion[1], buildingsRegion[2], groundRegion[3], groundRegion[4], buildingsRegion[5]}
+	for i := range expected {
+		if region[i] != expected[i] {
+			t.Errorf("Expected the root region %v to bound the layers, got %v", expected, region)
+			break
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/klauspost/compress/snappy"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/parquet_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParquetReaderReadsMappedColumns(t *testing.T) {
+	for _, codec := range []int32{0, 1} {
+		parquetFile := writeTestParquet(t, codec)
+
+		tree := &mockTree{}
+		err := parquet_reader.NewParquetReader("z=height", nil, storage.NewOsStorage(), nil).Read(parquetFile, 4978, tree)
+		_ = os.RemoveAll(path.Dir(parquetFile))
+		if err != nil {
+			t.Fatalf("Unexpected error with codec %d: %s", codec, err.Error())
+		}
+
+		if len(tree.points) != 4 {
+			t.Fatalf("Expected 4 points with codec %d, got %d", codec, len(tree.points))
+		}
+		assertPoint(t, tree, 1, 2, 3)
+		expectedIntensities := []uint8{10, 0, 255, 0}
+		expectedClassifications := []uint8{2, 6, 6, 2}
+		for i, point := range tree.points {
+			if point.Y != float64(3*i+2) || point.Z != float64(3*i+3) {
+				t.Errorf("Expected point %d at y %d and z %d, got %f and %f", i, 3*i+2, 3*i+3, point.Y, point.Z)
+			}
+			if point.Intensity != expectedIntensities[i] {
+				t.Errorf("Expected point %d intensity %d, got %d", i, expectedIntensities[i], point.Intensity)
+			}
+			if point.Classification != expectedClassifications[i] {
+				t.Errorf("Expected point %d classification %d, got %d", i, expectedClassifications[i], point.Classification)
+			}
+			if point.R != 0 || point.G != 0 || point.B != 0 {
+				t.Errorf("Expected point %d without color, got (%d, %d, %d)", i, point.R, point.G, point.B)
+			}
+		}
+		if tree.srids[0] != 4978 {
+			t.Errorf("Expected srid 4978, got %d", tree.srids[0])
+		}
+	}
+}
+
+func TestParquetReaderRejectsMissingCoordinateColumns(t *testing.T) {
+	parquetFile := writeTestParquet(t, 0)
+	defer func() { _ = os.RemoveAll(path.Dir(parquetFile)) }()
+
+	err := parquet_reader.NewParquetReader("", nil, storage.NewOsStorage(), nil).Read(parquetFile, 4978, &mockTree{})
+	if err == nil {
+		t.Errorf("Expected an error for the missing z column")
+	}
+}
+
+func TestParquetColumnMappingIsParsed(t *testing.T) {
+	mapping, err := parquet_reader.ParseColumnMapping("x=easting, Y = northing")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if mapping["x"] != "easting" || mapping["y"] != "northing" || mapping["z"] != "z" || mapping["gps_time"] != "gps_time" {
+		t.Errorf("Unexpected column mapping %v", mapping)
+	}
+
+	for _, invalid := range []string{"x", "x=", "w=width"} {
+		if _, err := parquet_reader.ParseColumnMapping(invalid); err == nil {
+			t.Errorf("Expected an error for column mapping %s", invalid)
+		}
+	}
+}
+
+// column of the test parquet files, whose values are given per row group with NaN for the nulls
+type parquetTestColumn struct {
+	name         string
+	physicalType int32
+	optional     bool
+	// page layout of the column, one of plain, v2 or dictionary
+	layout string
+	values [][]float64
+}
+
+// writes a parquet file with two row groups of two rows, with the X, y and height double columns, the optional
+// intensity int32 column and the dictionary encoded classification int32 column, returning the path of the file
+func writeTestParquet(t *testing.T, codec int32) string {
+	folder, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := []parquetTestColumn{
+		{name: "X", physicalType: 5, layout: "plain", values: [][]float64{{1, 4}, {7, 10}}},
+		{name: "y", physicalType: 5, layout: "v2", values: [][]float64{{2, 5}, {8, 11}}},
+		{name: "height", physicalType: 5, layout: "plain", values: [][]float64{{3, 6}, {9, 12}}},
+		{name: "intensity", physicalType: 1, optional: true, layout: "plain", values: [][]float64{{10, math.NaN()}, {300, 0}}},
+		{name: "classification", physicalType: 1, layout: "dictionary", values: [][]float64{{2, 6}, {6, 2}}},
+	}
+
+	file := bytes.NewBufferString("PAR1")
+	footer := &thriftWriter{}
+	footer.begin()
+	footer.list(2, 12, len(columns)+1)
+	footer.begin()
+	footer.str(4, "schema")
+	footer.int(5, 5, int64(len(columns)))
+	footer.end()
+	for _, column := range columns {
+		footer.begin()
+		footer.int(1, 5, int64(column.physicalType))
+		repetition := int64(0)
+		if column.optional {
+			repetition = 1
+		}
+		footer.int(3, 5, repetition)
+		footer.str(4, column.name)
+		footer.end()
+	}
+	footer.int(3, 6, 4)
+	footer.list(4, 12, 2)
+	for group := 0; group < 2; group++ {
+		footer.begin()
+		footer.list(1, 12, len(columns))
+		for _, column := range columns {
+			start := int64(file.Len())
+			dataOffset := writeParquetChunk(file, column, column.values[group], codec)
+			footer.begin()
+			footer.int(2, 6, start)
+			footer.field(3, 12)
+			footer.begin()
+			footer.int(1, 5, int64(column.physicalType))
+			footer.list(3, 8, 1)
+			footer.binary(column.name)
+			footer.int(4, 5, int64(codec))
+			footer.int(5, 6, 2)
+			footer.int(7, 6, int64(file.Len())-start)
+			footer.int(9, 6, dataOffset)
+			if column.layout == "dictionary" {
+				footer.int(11, 6, start)
+			}
+			footer.end()
+			footer.end()
+		}
+		footer.int(3, 6, 2)
+		footer.end()
+	}
+	footer.end()
+
+	file.Write(footer.out.Bytes())
+	file.Write(uint32Bytes(uint32(footer.out.Len())))
+	file.WriteString("PAR1")
+
+	parquetFile := path.Join(folder, "test.parquet")
+	if err := ioutil.WriteFile(parquetFile, file.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return parquetFile
+}
+
+// writes the pages of a column chunk returning the offset of its data page
+func writeParquetChunk(file *bytes.Buffer, column parquetTestColumn, values []float64, codec int32) int64 {
+	var levels []int
+	var nonNull []float64
+	for _, value := range values {
+		if math.IsNaN(value) {
+			levels = append(levels, 0)
+		} else {
+			levels = append(levels, 1)
+			nonNull = append(nonNull, value)
+		}
+	}
+
+	data := encodeParquetValues(nonNull, column.physicalType)
+	encoding := int64(0)
+	if column.layout == "dictionary" {
+		var dictionary []float64
+		indices := make([]int, len(nonNull))
+		for i, value := range nonNull {
+			indices[i] = len(dictionary)
+			for j, entry := range dictionary {
+				if entry == value {
+					indices[i] = j
+				}
+			}
+			if indices[i] == len(dictionary) {
+				dictionary = append(dictionary, value)
+			}
+		}
+		writeParquetPage(file, 2, len(dictionary), 0, nil, encodeParquetValues(dictionary, column.physicalType), codec)
+		data, encoding = append([]byte{1}, bitPack(indices, 1)...), 8
+	}
+
+	dataOffset := int64(file.Len())
+	if column.layout == "v2" {
+		var definitionLevels []byte
+		if column.optional {
+			definitionLevels = bitPack(levels, 1)
+		}
+		writeParquetPage(file, 3, len(values), encoding, definitionLevels, data, codec)
+		return dataOffset
+	}
+	if column.optional {
+		packed := bitPack(levels, 1)
+		data = append(append(uint32Bytes(uint32(len(packed))), packed...), data...)
+	}
+	writeParquetPage(file, 0, len(values), encoding, nil, data, codec)
+	return dataOffset
+}
+
+// writes a page of the given type, where the definition levels are only written uncompressed by the v2 data pages
+func writeParquetPage(file *bytes.Buffer, pageType int64, numValues int, encoding int64, definitionLevels []byte, data []byte, codec int32) {
+	if codec == 1 {
+		data = snappy.Encode(nil, data)
+	}
+	header := &thriftWriter{}
+	header.begin()
+	header.int(1, 5, pageType)
+	header.int(2, 5, int64(len(definitionLevels)+len(data)))
+	header.int(3, 5, int64(len(definitionLevels)+len(data)))
+	switch pageType {
+	case 0:
+		header.field(5, 12)
+		header.begin()
+		header.int(1, 5, int64(numValues))
+		header.int(2, 5, encoding)
+		header.end()
+	case 2:
+		header.field(7, 12)
+		header.begin()
+		header.int(1, 5, int64(numValues))
+		header.int(2, 5, 0)
+		header.end()
+	case 3:
+		header.field(8, 12)
+		header.begin()
+		header.int(1, 5, int64(numValues))
+		header.int(4, 5, encoding)
+		header.int(5, 5, int64(len(definitionLevels)))
+		header.int(6, 5, 0)
+		header.field(7, 1)
+		header.end()
+	}
+	header.end()
+	file.Write(header.out.Bytes())
+	file.Write(definitionLevels)
+	file.Write(data)
+}
+
+func encodeParquetValues(values []float64, physicalType int32) []byte {
+	out := new(bytes.Buffer)
+	for _, value := range values {
+		if physicalType == 1 {
+			out.Write(uint32Bytes(uint32(int32(value))))
+		} else {
+			_ = binary.Write(out, binary.LittleEndian, value)
+		}
+	}
+	return out.Bytes()
+}
+
+// encodes the values as a single bit-packed run of the RLE / bit-packing hybrid encoding
+func bitPack(values []int, bitWidth int) []byte {
+	groups := (len(values) + 7) / 8
+	out := []byte{byte(groups<<1 | 1)}
+	packed := make([]byte, groups*bitWidth)
+	for i, value := range values {
+		for bit := 0; bit < bitWidth; bit++ {
+			position := i*bitWidth + bit
+			packed[position/8] |= byte((value>>bit)&1) << (position % 8)
+		}
+	}
+	return append(out, packed...)
+}
+
+// minimal writer of structs serialized with the thrift compact protocol
+type thriftWriter struct {
+	out     bytes.Buffer
+	lastIds []int16
+}
+
+func (w *thriftWriter) begin() {
+	w.lastIds = append(w.lastIds, 0)
+}
+
+func (w *thriftWriter) end() {
+	w.out.WriteByte(0)
+	w.lastIds = w.lastIds[:len(w.lastIds)-1]
+}
+
+func (w *thriftWriter) field(id int16, fieldType byte) {
+	last := len(w.lastIds) - 1
+	w.out.WriteByte(byte(id-w.lastIds[last])<<4 | fieldType)
+	w.lastIds[last] = id
+}
+
+func (w *thriftWriter) int(id int16, fieldType byte, value int64) {
+	w.field(id, fieldType)
+	w.varint(uint64(value<<1 ^ value>>63))
+}
+
+func (w *thriftWriter) str(id int16, value string) {
+	w.field(id, 8)
+	w.binary(value)
+}
+
+func (w *thriftWriter) binary(value string) {
+	w.varint(uint64(len(value)))
+	w.out.WriteString(value)
+}
+
+func (w *thriftWriter) list(id int16, elementType byte, size int) {
+	w.field(id, 9)
+	w.out.WriteByte(byte(size)<<4 | elementType)
+}
+
+func (w *thriftWriter) varint(value uint64) {
+	buffer := make([]byte, binary.MaxVarintLen64)
+	w.out.Write(buffer[:binary.PutUvarint(buffer, value)])
+}
@@ -0,0 +1,190 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/pcd_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const pcdTestHeader = `# .PCD v0.7 - Point Cloud Data file format
+VERSION 0.7
+FIELDS x y z rgb intensity normal_x normal_y normal_z
+SIZE 4 4 4 4 2 4 4 4
+TYPE F F F F U F F F
+COUNT 1 1 1 1 1 1 1 1
+WIDTH 3
+HEIGHT 1
+VIEWPOINT 0 0 0 1 0 0 0
+POINTS 3
+`
+
+func writeTestPcd(t *testing.T, content []byte) string {
+	filePath := path.Join(createTempFolder(t), "cloud.pcd")
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+// Returns the little endian binary records of the test points, point after point, the last one having NaN coordinates
+func getTestPcdRecords() [][]byte {
+	var records [][]byte
+	for i := 0; i < 3; i++ {
+		record := bytes.NewBuffer(nil)
+		x := float32(10 + i)
+		if i == 2 {
+			x = float32(math.NaN())
+		}
+		_ = binary.Write(record, binary.LittleEndian, []float32{x, 20, 30.5})
+		_ = binary.Write(record, binary.LittleEndian, uint32(0xff2040c0)+uint32(i))
+		_ = binary.Write(record, binary.LittleEndian, uint16(256*(i+1)))
+		_ = binary.Write(record, binary.LittleEndian, []float32{0, float32(i), 1})
+		records = append(records, record.Bytes())
+	}
+	return records
+}
+
+// Compresses the given data with LZF, greedily replacing the repeated sequences of at least 3 bytes by back references
+func compressLzf(data []byte) []byte {
+	var out, literals []byte
+	flush := func() {
+		for len(literals) > 0 {
+			run := literals
+			if len(run) > 32 {
+				run = run[:32]
+			}
+			out = append(append(out, byte(len(run)-1)), run...)
+			literals = literals[len(run):]
+		}
+	}
+	for i := 0; i < len(data); {
+		bestLength, bestDistance := 0, 0
+		for reference := i - 1; reference >= 0 && i-reference <= 8192; reference-- {
+			length := 0
+			for i+length < len(data) && length < 264 && data[reference+length] == data[i+length] {
+				length++
+			}
+			if length > bestLength {
+				bestLength, bestDistance = length, i-reference
+			}
+		}
+		if bestLength < 3 {
+			literals = append(literals, data[i])
+			i++
+			continue
+		}
+		flush()
+		distance := bestDistance - 1
+		if length := bestLength - 2; length < 7 {
+			out = append(out, byte(length<<5|distance>>8))
+		} else {
+			out = append(out, byte(7<<5|distance>>8), byte(length-7))
+		}
+		out = append(out, byte(distance))
+		i += bestLength
+	}
+	flush()
+	return out
+}
+
+func assertPcdTestPoints(t *testing.T, tree *mockTree, layout string) {
+	if len(tree.points) != 2 {
+		t.Fatalf("Expected 2 points in the %s file, the NaN one being skipped, got %d", layout, len(tree.points))
+	}
+	for i, point := range tree.points {
+		if point.X != float64(10+i) || point.Y != 20 || point.Z != 30.5 || tree.srids[i] != 32633 {
+			t.Errorf("Unexpected coordinates of the point %d of the %s file: (%f, %f, %f)", i, layout, point.X, point.Y, point.Z)
+		}
+		if point.R != 0x20 || point.G != 0x40 || point.B != uint8(0xc0+i) || point.Intensity != uint8(i+1) {
+			t.Errorf("Unexpected color (%d, %d, %d) or intensity %d of the point %d of the %s file", point.R, point.G, point.B, point.Intensity, i, layout)
+		}
+		if len(point.Attributes) != 2 || point.Attributes[0] != float32(i) || point.Attributes[1] != 0 {
+			t.Errorf("Unexpected attributes %v of the point %d of the %s file", point.Attributes, i, layout)
+		}
+	}
+}
+
+func TestPcdReaderReadsAllTheDataLayouts(t *testing.T) {
+	records := getTestPcdRecords()
+	binaryData := bytes.Join(records, nil)
+	// the compressed data holds the values of each field for all the points, one field after the other
+	var columns []byte
+	for _, size := range []int{4, 4, 4, 4, 2, 4, 4, 4} {
+		offset := len(columns) / len(records)
+		for _, record := range records {
+			columns = append(columns, record[offset:offset+size]...)
+		}
+	}
+	compressed := compressLzf(columns)
+	if len(compressed) >= len(columns) {
+		t.Fatalf("Expected the repeated values to be compressed")
+	}
+	sizes := make([]byte, 8)
+	binary.LittleEndian.PutUint32(sizes, uint32(len(compressed)))
+	binary.LittleEndian.PutUint32(sizes[4:], uint32(len(columns)))
+
+	// the ascii packed colors of the unsigned fields are written as integers
+	asciiData := "10 20 30.5 4280303808 256 0 0 1\n\n11 20 30.5 4280303809 512 0 1 1\nnan 20 30.5 4280303810 768 0 2 1\n"
+	layouts := map[string][]byte{
+		"binary":            append([]byte(pcdTestHeader+"DATA binary\n"), binaryData...),
+		"binary_compressed": append(append([]byte(pcdTestHeader+"DATA binary_compressed\n"), sizes...), compressed...),
+		"ascii":             []byte(strings.Replace(pcdTestHeader, "TYPE F F F F U", "TYPE F F F U U", 1) + "DATA ascii\n" + asciiData),
+	}
+
+	for layout, content := range layouts {
+		pcdFile := writeTestPcd(t, content)
+		tree := &mockTree{}
+		reader := pcd_reader.NewPcdReader([]string{"normal_y", "gps_time"}, nil, storage.NewOsStorage(), nil)
+		if err := reader.Read(pcdFile, 32633, tree); err != nil {
+			t.Fatalf("Unexpected error reading the %s file: %s", layout, err.Error())
+		}
+		assertPcdTestPoints(t, tree, layout)
+		_ = os.RemoveAll(path.Dir(pcdFile))
+	}
+}
+
+func TestPcdReaderReadsAsciiFloatPackedColors(t *testing.T) {
+	// the packed colors of the float fields are written as the float of the same bits
+	rgb := strconv.FormatFloat(float64(math.Float32frombits(0x00ff8001)), 'g', -1, 32)
+	content := "FIELDS x y z rgb\nSIZE 4 4 4 4\nTYPE F F F F\nWIDTH 1\nHEIGHT 1\nDATA ascii\n1 2 3 " + rgb + "\n"
+	pcdFile := writeTestPcd(t, []byte(content))
+	defer func() { _ = os.RemoveAll(path.Dir(pcdFile)) }()
+
+	tree := &mockTree{}
+	if err := pcd_reader.NewPcdReader(nil, nil, storage.NewOsStorage(), nil).Read(pcdFile, 4326, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(tree.points) != 1 || tree.points[0].R != 0xff || tree.points[0].G != 0x80 || tree.points[0].B != 0x01 {
+		t.Errorf("Expected a point colored (255, 128, 1), got %v", tree.points)
+	}
+}
+
+func TestPcdReaderRejectsInvalidFiles(t *testing.T) {
+	header := "FIELDS x y z\nSIZE 4 4 4\nTYPE F F F\nPOINTS 2\n"
+	invalid := map[string]string{
+		"not a PCD file":             "ply\nformat ascii 1.0\n",
+		"unsupported PCD data":       header + "DATA binary_lz4\n",
+		"do not match":               "FIELDS x y z\nSIZE 4 4\nTYPE F F F\nPOINTS 1\nDATA ascii\n",
+		"unsupported type F2":        "FIELDS x y z\nSIZE 4 4 2\nTYPE F F F\nPOINTS 1\nDATA ascii\n",
+		"missing field z":            "FIELDS x y\nSIZE 4 4\nTYPE F F\nPOINTS 1\nDATA ascii\n1 2\n",
+		"truncated PCD data":         header + "DATA binary\n" + strings.Repeat("\x00", 20),
+		"PCD record of 2 values":     header + "DATA ascii\n1 2 3\n4 5\n",
+		"does not hold the declared": header + "DATA binary_compressed\n\x01\x00\x00\x00\x17\x00\x00\x00\x00",
+	}
+	for expected, content := range invalid {
+		pcdFile := writeTestPcd(t, []byte(content))
+		err := pcd_reader.NewPcdReader(nil, nil, storage.NewOsStorage(), nil).Read(pcdFile, 4326, &mockTree{})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error containing %q, got %v", expected, err)
+		}
+		_ = os.RemoveAll(path.Dir(pcdFile))
+	}
+}
@@ -0,0 +1,171 @@
+package unit
+
+import (
+	"context"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/picking"
+	"github.com/mfbonfigli/gocesiumtiler/pkg/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/pkg/tileset"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Node recording whether its points have been loaded
+type loadRecordingNode struct {
+	*mockNode
+	loaded bool
+}
+
+func (n *loadRecordingNode) GetPoints() []*data.Point {
+	n.loaded = true
+	return n.mockNode.GetPoints()
+}
+
+func TestParsePositionReadsCoordinates(t *testing.T) {
+	lon, lat, height, err := picking.ParsePosition("12.49, 41.89,35.5")
+	if err != nil || lon != 12.49 || lat != 41.89 || height != 35.5 {
+		t.Errorf("Unexpected position %f, %f, %f, %v", lon, lat, height, err)
+	}
+	for _, value := range []string{"12.49,41.89", "12.49,41.89,a", "200,41.89,0"} {
+		if _, _, _, err := picking.ParsePosition(value); err == nil {
+			t.Errorf("Expected an error parsing %s", value)
+		}
+	}
+}
+
+func TestNearestReturnsClosestPointsSkippingFarNodes(t *testing.T) {
+	d := profileTestDegree
+	child := &mockNode{
+		boundingBox:  geometry.NewBoundingBox(0, 10*d, 0, 10*d, 0, 10),
+		internalSrid: 4326,
+		points:       []*data.Point{data.NewPoint(1*d, 0, 0, 0, 0, 0, 0, 2), data.NewPoint(0, 0, 5, 0, 0, 0, 0, 2)},
+	}
+	far := &loadRecordingNode{mockNode: &mockNode{
+		boundingBox:  geometry.NewBoundingBox(100*d, 110*d, 100*d, 110*d, 0, 10),
+		internalSrid: 4326,
+		points:       []*data.Point{data.NewPoint(100*d, 100*d, 0, 0, 0, 0, 0, 2)},
+	}}
+	root := &mockNode{
+		boundingBox:  geometry.NewBoundingBox(0, 110*d, 0, 110*d, 0, 10),
+		internalSrid: 4326,
+		points:       []*data.Point{data.NewPoint(0, 3*d, 0, 0, 0, 0, 0, 6)},
+		children:     [8]octree.INode{child, far},
+	}
+
+	neighbors, err := picking.Nearest(root, 0, 0, 0, 2, picking.FullResolution, false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(neighbors) != 2 || math.Abs(neighbors[0].Distance-1) > 1e-6 || math.Abs(neighbors[1].Distance-3) > 1e-6 {
+		t.Fatalf("Expected the points at 1 and 3 m, got %v", neighbors)
+	}
+	if neighbors[0].Level != 1 || neighbors[1].Level != 0 || neighbors[1].Classification != 6 {
+		t.Errorf("Unexpected levels or attributes %v, %v", *neighbors[0], *neighbors[1])
+	}
+	if far.loaded {
+		t.Errorf("Expected the points of the far node not to be loaded")
+	}
+
+	// the level of detail of the root only
+	neighbors, err = picking.Nearest(root, 0, 0, 0, 2, 0, false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(neighbors) != 1 || neighbors[0].Level != 0 {
+		t.Errorf("Expected only the point of the root, got %v", neighbors)
+	}
+
+	table, err := picking.ToCsv(neighbors)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if lines := strings.Split(strings.TrimSpace(string(table)), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "3.000,0.00000000,") {
+		t.Errorf("Unexpected table %s", string(table))
+	}
+}
+
+func TestNearestPicksRepeatedPointsOnce(t *testing.T) {
+	d := profileTestDegree
+	repeated := data.NewPoint(1*d, 0, 0, 10, 20, 30, 0, 2)
+	child := &mockNode{
+		boundingBox:  geometry.NewBoundingBox(0, 10*d, 0, 10*d, 0, 10),
+		internalSrid: 4326,
+		points:       []*data.Point{data.NewPoint(1*d, 0, 0, 10, 20, 30, 0, 2), data.NewPoint(2*d, 0, 0, 0, 0, 0, 0, 2)},
+	}
+	root := &mockNode{
+		boundingBox:  geometry.NewBoundingBox(0, 10*d, 0, 10*d, 0, 10),
+		internalSrid: 4326,
+		points:       []*data.Point{repeated},
+		children:     [8]octree.INode{child},
+	}
+
+	neighbors, err := picking.Nearest(root, 0, 0, 0, 2, picking.FullResolution, true, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(neighbors) != 2 || math.Abs(neighbors[0].Distance-1) > 1e-6 || math.Abs(neighbors[1].Distance-2) > 1e-6 {
+		t.Errorf("Expected the points at 1 and 2 m, got %v", neighbors)
+	}
+}
+
+func TestNearestRejectsProjectedTrees(t *testing.T) {
+	root := &mockNode{boundingBox: geometry.NewBoundingBox(0, 1, 0, 1, 0, 1), internalSrid: 32633}
+	if _, err := picking.Nearest(root, 0, 0, 0, 1, picking.FullResolution, false, nil); err == nil {
+		t.Errorf("Expected an error for a tree of projected coordinates")
+	}
+}
+
+func TestTilesetNearestMatchesExhaustiveSearch(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeLasTestFile(t, path.Join(folder, "cloud.las"), 2, 3, lazTestRecordLength, createLazTestRecords(1000), nil)
+	if err := tiler.New(tiler.WithSrid(32633), tiler.WithCellSizes(1, 10)).Run(context.Background(), path.Join(folder, "cloud.las"), folder); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	opened, err := tileset.Open(path.Join(folder, "cloud"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	all, err := opened.Nearest(0, 0, 0, 1000000, tileset.FullResolution)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(all) != 1000 {
+		t.Fatalf("Expected the 1000 points of the tileset, got %d", len(all))
+	}
+
+	query := all[500]
+	expected := make([]float64, len(all))
+	scaleX := 6378137 * math.Pi / 180 * math.Cos(query.Latitude*math.Pi/180)
+	for i, p := range all {
+		dx, dy, dz := (p.Longitude-query.Longitude)*scaleX, (p.Latitude-query.Latitude)*6378137*math.Pi/180, p.Height-query.Height
+		expected[i] = math.Sqrt(dx*dx + dy*dy + dz*dz)
+	}
+	sort.Float64s(expected)
+
+	nearest, err := opened.Nearest(query.Longitude, query.Latitude, query.Height, 10, tileset.FullResolution)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(nearest) != 10 {
+		t.Fatalf("Expected 10 points, got %d", len(nearest))
+	}
+	for i, p := range nearest {
+		if math.Abs(p.Distance-expected[i]) > 1e-6 {
+			t.Errorf("Expected point %d at %f m, got %f m", i, expected[i], p.Distance)
+		}
+	}
+}
+
+func TestTilesetOpenFailsForMissingTileset(t *testing.T) {
+	if _, err := tileset.Open(path.Join(os.TempDir(), "missing-tileset")); err == nil {
+		t.Errorf("Expected an error for a missing tileset")
+	}
+}
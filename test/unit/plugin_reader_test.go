@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/plugin_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestPluginReaderReadsPointStream(t *testing.T) {
+	streamFile := writeTestPointStream(t, []byte("GCTP"), 1)
+	defer func() { _ = os.RemoveAll(path.Dir(streamFile)) }()
+
+	// cat plays the plugin writing the stream stored in the file
+	tree := &mockTree{}
+	if err := plugin_reader.NewPluginReader([]string{"cat"}, nil, nil).Read(streamFile, 32633, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(tree.points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(tree.points))
+	}
+	assertPoint(t, tree, 1, 2, 3)
+	point := tree.points[1]
+	if point.X != 4 || point.R != 10 || point.G != 20 || point.B != 30 || point.Intensity != 40 || point.Classification != 2 {
+		t.Errorf("Unexpected second point %v", point)
+	}
+	if tree.srids[0] != 32633 {
+		t.Errorf("Expected srid 32633, got %d", tree.srids[0])
+	}
+}
+
+func TestPluginReaderRejectsInvalidStream(t *testing.T) {
+	streamFile := writeTestPointStream(t, []byte("LASF"), 1)
+	defer func() { _ = os.RemoveAll(path.Dir(streamFile)) }()
+
+	if err := plugin_reader.NewPluginReader([]string{"cat"}, nil, nil).Read(streamFile, 32633, &mockTree{}); err == nil {
+		t.Errorf("Expected an error for an invalid stream header")
+	}
+}
+
+func TestPluginReaderReportsPluginFailure(t *testing.T) {
+	command := []string{"sh", "-c", "echo cannot open $1 >&2; exit 3", "plugin"}
+	err := plugin_reader.NewPluginReader(command, nil, nil).Read("cloud.rdbx", 32633, &mockTree{})
+	if err == nil {
+		t.Fatalf("Expected an error for a failed plugin")
+	}
+	if !strings.Contains(err.Error(), "cannot open cloud.rdbx") {
+		t.Errorf("Expected the error to report the plugin output, got %s", err.Error())
+	}
+}
+
+func TestPluginReaderReadsFramedStream(t *testing.T) {
+	streamFile := writeTestFramedStream(t, true)
+	defer func() { _ = os.RemoveAll(path.Dir(streamFile)) }()
+
+	// the file path replaces the placeholder rather than being appended
+	tree := &mockTree{}
+	command := []string{"sh", "-c", "cat \"$1\"", "bridge", plugin_reader.InputPlaceholder}
+	if err := plugin_reader.NewPluginReader(command, nil, nil).Read(streamFile, 32633, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(tree.points) != 3 {
+		t.Fatalf("Expected 3 points, got %d", len(tree.points))
+	}
+	assertPoint(t, tree, 1, 2, 3)
+	if point := tree.points[2]; point.X != 7 || point.Y != 8 || point.Z != 9 {
+		t.Errorf("Unexpected third point %v", point)
+	}
+}
+
+func TestPluginReaderRejectsFramedStreamWithoutClosingFrame(t *testing.T) {
+	streamFile := writeTestFramedStream(t, false)
+	defer func() { _ = os.RemoveAll(path.Dir(streamFile)) }()
+
+	if err := plugin_reader.NewPluginReader([]string{"cat"}, nil, nil).Read(streamFile, 32633, &mockTree{}); err == nil {
+		t.Errorf("Expected an error for a stream ended without its closing frame")
+	}
+}
+
+func TestReaderPluginsAreParsed(t *testing.T) {
+	plugins, ok := tiler.ParseReaderPlugins(".RDBX=rdb2gctp --all; rxp = rxp2gctp")
+	if !ok || len(plugins) != 2 {
+		t.Fatalf("Expected 2 plugins, got %v", plugins)
+	}
+	if plugins[0].Extension != ".rdbx" || strings.Join(plugins[0].Command, " ") != "rdb2gctp --all" {
+		t.Errorf("Unexpected plugin %v", plugins[0])
+	}
+	if plugins[1].Extension != ".rxp" || strings.Join(plugins[1].Command, " ") != "rxp2gctp" {
+		t.Errorf("Unexpected plugin %v", plugins[1])
+	}
+	if plugin := tiler.GetReaderPlugin(plugins, "/data/Scan.RXP"); plugin == nil || plugin.Extension != ".rxp" {
+		t.Errorf("Expected the rxp plugin to read Scan.RXP, got %v", plugin)
+	}
+	if tiler.GetReaderPlugin(plugins, "cloud.las") != nil {
+		t.Errorf("Expected no plugin to read cloud.las")
+	}
+
+	for _, invalid := range []string{".rdbx", ".rdbx=", "=rdb2gctp"} {
+		if _, ok := tiler.ParseReaderPlugins(invalid); ok {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+// writes a version 2 point stream holding a frame of two points and a frame of one point, closed by the zero length
+// frame if requested, returning the path of the file
+func writeTestFramedStream(t *testing.T, closed bool) string {
+	folder, err := ioutil.TempDir("", "bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := new(bytes.Buffer)
+	stream.Write([]byte("GCTP"))
+	stream.Write(uint32Bytes(2))
+	for _, points := range [][][3]float64{{{1, 2, 3}, {4, 5, 6}}, {{7, 8, 9}}} {
+		stream.Write(uint32Bytes(uint32(len(points) * 37)))
+		for _, point := range points {
+			_ = binary.Write(stream, binary.LittleEndian, point)
+			stream.Write([]byte{10, 20, 30, 40, 2})
+			_ = binary.Write(stream, binary.LittleEndian, float64(100))
+		}
+	}
+	if closed {
+		stream.Write(uint32Bytes(0))
+	}
+
+	streamFile := path.Join(folder, "test.e57")
+	if err := ioutil.WriteFile(streamFile, stream.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return streamFile
+}
+
+// writes a point stream with the given magic and version holding two points, returning the path of the file
+func writeTestPointStream(t *testing.T, magic []byte, version uint32) string {
+	folder, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := new(bytes.Buffer)
+	stream.Write(magic)
+	stream.Write(uint32Bytes(version))
+	for _, point := range [][3]float64{{1, 2, 3}, {4, 5, 6}} {
+		_ = binary.Write(stream, binary.LittleEndian, point)
+		stream.Write([]byte{10, 20, 30, 40, 2})
+		_ = binary.Write(stream, binary.LittleEndian, float64(100))
+	}
+
+	streamFile := path.Join(folder, "test.gctp")
+	if err := ioutil.WriteFile(streamFile, stream.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return streamFile
+}
@@ -0,0 +1,132 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/ply_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func writeTestPly(t *testing.T, content []byte) string {
+	filePath := path.Join(createTempFolder(t), "cloud.ply")
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestPlyReaderReadsAsciiVerticesWithNormals(t *testing.T) {
+	plyFile := writeTestPly(t, []byte(`ply
+format ascii 1.0
+comment exported by a photogrammetry tool
+element vertex 3
+property float x
+property float y
+property float z
+property float nx
+property float ny
+property float nz
+property uchar red
+property uchar green
+property uchar blue
+element face 1
+property list uchar int vertex_indices
+end_header
+1.5 2.5 3.5 0 0 1 255 128 0
+4 5 6 1 0 0 10 20 30
+nan 0 0 0 1 0 1 1 1
+3 0 1 2
+`))
+	defer func() { _ = os.RemoveAll(path.Dir(plyFile)) }()
+
+	tree := &mockTree{}
+	reader := ply_reader.NewPlyReader([]string{"normal_z", "return_number", "normal_x"}, nil, storage.NewOsStorage(), nil)
+	if err := reader.Read(plyFile, 32633, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(tree.points) != 2 {
+		t.Fatalf("Expected 2 points, the NaN one being skipped, got %d", len(tree.points))
+	}
+	first, second := tree.points[0], tree.points[1]
+	if first.X != 1.5 || first.Y != 2.5 || first.Z != 3.5 || first.R != 255 || first.G != 128 || first.B != 0 {
+		t.Errorf("Unexpected first point (%f, %f, %f) colored (%d, %d, %d)", first.X, first.Y, first.Z, first.R, first.G, first.B)
+	}
+	if len(first.Attributes) != 3 || first.Attributes[0] != 1 || first.Attributes[1] != 0 || first.Attributes[2] != 0 {
+		t.Errorf("Expected the normal of the first point along z, got %v", first.Attributes)
+	}
+	if second.Attributes[0] != 0 || second.Attributes[2] != 1 || second.B != 30 || tree.srids[1] != 32633 {
+		t.Errorf("Expected the normal of the second point along x, got %v", second.Attributes)
+	}
+}
+
+func TestPlyReaderReadsBinaryVerticesAfterOtherElements(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		format := "binary_little_endian"
+		if order == binary.BigEndian {
+			format = "binary_big_endian"
+		}
+		content := bytes.NewBufferString("ply\nformat " + format + " 1.0\n" +
+			"element camera 1\nproperty list uchar float pose\nproperty int id\n" +
+			"element vertex 2\nproperty double x\nproperty double y\nproperty double z\n" +
+			"property ushort red\nproperty ushort green\nproperty ushort blue\nproperty float intensity\n" +
+			"end_header\n")
+		content.WriteByte(2)
+		_ = binary.Write(content, order, []float32{7, 8})
+		_ = binary.Write(content, order, int32(42))
+		for i := 0; i < 2; i++ {
+			_ = binary.Write(content, order, []float64{100 + float64(i), 200, 10.25})
+			_ = binary.Write(content, order, []uint16{65535, 256 * uint16(i), 0})
+			_ = binary.Write(content, order, float32(300))
+		}
+		plyFile := writeTestPly(t, content.Bytes())
+
+		tree := &mockTree{}
+		if err := ply_reader.NewPlyReader(nil, nil, storage.NewOsStorage(), nil).Read(plyFile, 4978, tree); err != nil {
+			t.Fatalf("Unexpected error reading %s: %s", format, err.Error())
+		}
+		if len(tree.points) != 2 {
+			t.Fatalf("Expected 2 points in the %s file, got %d", format, len(tree.points))
+		}
+		for i, point := range tree.points {
+			if math.Abs(point.X-float64(100+i)) > 1e-9 || point.Z != 10.25 || point.R != 255 || point.G != uint8(i) || point.Intensity != 255 {
+				t.Errorf("Unexpected point %d of the %s file: %v", i, format, point)
+			}
+			if point.Attributes != nil {
+				t.Errorf("Expected no attributes, got %v", point.Attributes)
+			}
+		}
+
+		truncated := content.Bytes()[:content.Len()-3]
+		if err := ioutil.WriteFile(plyFile, truncated, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := ply_reader.NewPlyReader(nil, nil, storage.NewOsStorage(), nil).Read(plyFile, 4978, &mockTree{}); err == nil {
+			t.Errorf("Expected an error reading the truncated %s file", format)
+		}
+		_ = os.RemoveAll(path.Dir(plyFile))
+	}
+}
+
+func TestPlyReaderRejectsInvalidFiles(t *testing.T) {
+	invalid := map[string]string{
+		"not a PLY file":         "solid cube\nfacet normal 0 0 1\n",
+		"missing vertex element": "ply\nformat ascii 1.0\nelement face 0\nproperty list uchar int vertex_indices\nend_header\n",
+		"missing vertex property z": "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\n" +
+			"end_header\n1 2\n",
+	}
+	for expected, content := range invalid {
+		plyFile := writeTestPly(t, []byte(content))
+		err := ply_reader.NewPlyReader(nil, nil, storage.NewOsStorage(), nil).Read(plyFile, 4326, &mockTree{})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected an error containing %q, got %v", expected, err)
+		}
+		_ = os.RemoveAll(path.Dir(plyFile))
+	}
+}
@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/preflight"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeWarnsAboutMinCellSizeLargerThanPointSpacing(t *testing.T) {
+	opts := getPreflightOptions()
+	// a 100x100 m square with one million points has a spacing of 0.1 m
+	info := getUtmFileInfo(1000000)
+
+	warnings := preflight.NewAnalyzer(coordinateConverter).Analyze(info, opts)
+
+	assertWarning(t, warnings, "grid-min-size")
+}
+
+func TestAnalyzeAcceptsMinCellSizeSmallerThanPointSpacing(t *testing.T) {
+	opts := getPreflightOptions()
+	info := getUtmFileInfo(10000)
+
+	warnings := preflight.NewAnalyzer(coordinateConverter).Analyze(info, opts)
+
+	if len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+}
+
+func TestAnalyzeWarnsAboutDegreeBoundsWithProjectedSrid(t *testing.T) {
+	opts := getPreflightOptions()
+	info := &preflight.FileInfo{MinX: 14.90, MaxX: 14.91, MinY: 41.34, MaxY: 41.35, NumberOfPoints: 1000}
+
+	warnings := preflight.NewAnalyzer(coordinateConverter).Analyze(info, opts)
+
+	assertWarning(t, warnings, "geographic degrees")
+}
+
+func TestAnalyzeWarnsAboutBoundsNotValidInSrid(t *testing.T) {
+	opts := getPreflightOptions()
+	opts.Srid = 4326
+	info := getUtmFileInfo(10000)
+
+	warnings := preflight.NewAnalyzer(coordinateConverter).Analyze(info, opts)
+
+	assertWarning(t, warnings, "not valid coordinates")
+}
+
+func TestAnalyzeWarnsAboutOrthometricHeightsWithoutGeoidCorrection(t *testing.T) {
+	opts := getPreflightOptions()
+	info := getUtmFileInfo(10000)
+	info.Orthometric = true
+
+	warnings := preflight.NewAnalyzer(coordinateConverter).Analyze(info, opts)
+	assertWarning(t, warnings, "-geoid")
+
+	opts.EnableGeoidZCorrection = true
+	warnings = preflight.NewAnalyzer(coordinateConverter).Analyze(info, opts)
+	if len(warnings) != 0 {
+		t.Errorf("Expected no warnings with geoid correction enabled, got %v", warnings)
+	}
+}
+
+func TestIsOrthometricDetectsVerticalCsFromGeoKeys(t *testing.T) {
+	if !preflight.IsOrthometric([]lidario.VLR{getGeoKeyDirectoryVlr(5703)}) {
+		t.Errorf("Expected NAVD88 heights to be detected as orthometric")
+	}
+	if preflight.IsOrthometric([]lidario.VLR{getGeoKeyDirectoryVlr(5030)}) {
+		t.Errorf("Expected WGS84 ellipsoidal heights not to be detected as orthometric")
+	}
+	if preflight.IsOrthometric(nil) {
+		t.Errorf("Expected files without VLRs not to be detected as orthometric")
+	}
+}
+
+func TestIsOrthometricDetectsVerticalCsFromWkt(t *testing.T) {
+	wkt := `COMPD_CS["WGS 84 / UTM zone 33N + EGM96 height",PROJCS["WGS 84 / UTM zone 33N"],VERT_CS["EGM96 height",VERT_DATUM["EGM96 geoid",2005]]]`
+	vlr := lidario.VLR{UserID: "LASF_Projection", RecordID: 2112, BinaryData: []byte(wkt + "\x00")}
+
+	if !preflight.IsOrthometric([]lidario.VLR{vlr}) {
+		t.Errorf("Expected EGM96 heights to be detected as orthometric")
+	}
+}
+
+func getPreflightOptions() *tiler.TilerOptions {
+	return &tiler.TilerOptions{
+		Srid:        32633,
+		Algorithm:   tiler.Grid,
+		CellMinSize: 0.15,
+		CellMaxSize: 5,
+	}
+}
+
+func getUtmFileInfo(numberOfPoints int) *preflight.FileInfo {
+	return &preflight.FileInfo{
+		MinX:           491880,
+		MaxX:           491980,
+		MinY:           4576930,
+		MaxY:           4577030,
+		NumberOfPoints: numberOfPoints,
+	}
+}
+
+// builds a GeoKey directory VLR holding only the vertical coordinate system key
+func getGeoKeyDirectoryVlr(verticalCsCode uint16) lidario.VLR {
+	data := make([]byte, 16)
+	for i, value := range []uint16{1, 1, 0, 1, 4096, 0, 1, verticalCsCode} {
+		binary.LittleEndian.PutUint16(data[i*2:], value)
+	}
+	return lidario.VLR{UserID: "LASF_Projection", RecordID: 34735, BinaryData: data}
+}
+
+func assertWarning(t *testing.T, warnings []string, expected string) {
+	for _, warning := range warnings {
+		if strings.Contains(warning, expected) {
+			return
+		}
+	}
+	t.Errorf("Expected a warning containing '%s', got %v", expected, warnings)
+}
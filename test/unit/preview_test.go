@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/compression"
+	"github.com/mfbonfigli/gocesiumtiler/internal/preview"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Writes a tileset.json file and a content file in every given subfolder of the given folder
+func writePreviewTestTilesets(t *testing.T, folder string, names ...string) {
+	for _, name := range names {
+		if err := os.MkdirAll(path.Join(folder, name, "0"), 0777); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if err := ioutil.WriteFile(path.Join(folder, name, "tileset.json"), []byte("{}"), 0666); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if err := ioutil.WriteFile(path.Join(folder, name, "0", "content.glb"), []byte("glTF"), 0666); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+}
+
+func newPreviewTestServer(t *testing.T, folder string) *httptest.Server {
+	decoder, err := compression.LoadDecoder(folder)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	server := httptest.NewServer(preview.NewHandler(folder, decoder))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFindTilesetsListsTheTilesetsOfTheSubfolders(t *testing.T) {
+	folder := createTempFolder(t)
+	writePreviewTestTilesets(t, folder, "west", "east field")
+	if err := os.MkdirAll(path.Join(folder, "empty"), 0777); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	expected := []string{"east%20field/tileset.json", "west/tileset.json"}
+	if tilesets := preview.FindTilesets(folder); !reflect.DeepEqual(tilesets, expected) {
+		t.Errorf("Expected tilesets %v, got %v", expected, tilesets)
+	}
+	if tilesets := preview.FindTilesets(path.Join(folder, "west")); !reflect.DeepEqual(tilesets, []string{"tileset.json"}) {
+		t.Errorf("Expected the tileset of the folder itself, got %v", tilesets)
+	}
+}
+
+func TestPreviewServesAViewerOfTheTilesets(t *testing.T) {
+	folder := createTempFolder(t)
+	writePreviewTestTilesets(t, folder, "west", "east")
+	server := newPreviewTestServer(t, folder)
+
+	response, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("Expected the viewer to be served as html, got %s", response.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), `const tilesets = ["east/tileset.json","west/tileset.json"];`) || !strings.Contains(string(body), "Cesium.js") {
+		t.Errorf("Expected the viewer to load the tilesets with CesiumJS, got %s", string(body))
+	}
+
+	// the own page of the folder is served instead of the viewer
+	if err := ioutil.WriteFile(path.Join(folder, "index.html"), []byte("own page"), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	response, err = http.Get(server.URL + "/index.html")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	body, _ = ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if string(body) != "own page" {
+		t.Errorf("Expected the index.html of the folder to be served, got %s", string(body))
+	}
+}
+
+func TestPreviewServesTheTilesWithCorsAndContentTypes(t *testing.T) {
+	folder := createTempFolder(t)
+	writePreviewTestTilesets(t, folder, "west")
+	server := newPreviewTestServer(t, folder)
+
+	response, err := http.Get(server.URL + "/west/0/content.glb")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	_ = response.Body.Close()
+	if response.Header.Get("Content-Type") != "model/gltf-binary" || response.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected a cross origin glb content, got headers %v", response.Header)
+	}
+
+	request, _ := http.NewRequest(http.MethodOptions, server.URL+"/west/tileset.json", nil)
+	request.Header.Set("Origin", "https://viewer.example.com")
+	request.Header.Set("Access-Control-Request-Method", "GET")
+	request.Header.Set("Access-Control-Request-Headers", "range")
+	response, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusNoContent || response.Header.Get("Access-Control-Allow-Origin") != "*" ||
+		!strings.Contains(response.Header.Get("Access-Control-Allow-Methods"), "GET") || response.Header.Get("Access-Control-Allow-Headers") != "range" {
+		t.Errorf("Expected the preflight request to be allowed, got %d and headers %v", response.StatusCode, response.Header)
+	}
+}
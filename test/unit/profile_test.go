@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/profile"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Length in degrees of a meter along the equator
+const profileTestDegree = 1 / (6378137 * math.Pi / 180)
+
+func TestParseLineReadsVertices(t *testing.T) {
+	line, err := profile.ParseLine("12.49,41.89; 12.5,41.9;12.51,41.9")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(line) != 3 || line[1] != [2]float64{12.5, 41.9} {
+		t.Errorf("Unexpected line %v", line)
+	}
+	for _, value := range []string{"12.49,41.89", "12.49,41.89;12.5", "12.49,41.89;200,41.9", "a,b;c,d"} {
+		if _, err := profile.ParseLine(value); err == nil {
+			t.Errorf("Expected an error parsing %s", value)
+		}
+	}
+}
+
+func TestExtractKeepsThePointsWithinTheBuffer(t *testing.T) {
+	// a line along the equator, 100 m long, turning north after 50 m
+	d := profileTestDegree
+	line := profile.Line{{0, 0}, {50 * d, 0}, {50 * d, 50 * d}}
+	child := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(0, 60*d, 0, 60*d, 0, 100),
+		internalSrid:        4326,
+		points:              []*data.Point{data.NewPoint(10*d, 0.5*d, 12, 0, 0, 0, 0, 2), data.NewPoint(50.8*d, 20*d, 14, 0, 0, 0, 0, 6), data.NewPoint(20*d, 3*d, 13, 0, 0, 0, 0, 2)},
+		globalChildrenCount: 3,
+	}
+	// not visited, as it does not intersect the buffer
+	far := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(80*d, 90*d, 80*d, 90*d, 0, 100),
+		internalSrid:        4326,
+		points:              []*data.Point{data.NewPoint(85*d, 85*d, 10, 0, 0, 0, 0, 2)},
+		globalChildrenCount: 1,
+	}
+	root := &mockNode{
+		boundingBox:  geometry.NewBoundingBox(-10*d, 90*d, -10*d, 90*d, 0, 100),
+		internalSrid: 4326,
+		// the first point lies before the start of the line
+		points:              []*data.Point{data.NewPoint(-0.5*d, 0, 11, 0, 0, 0, 0, 2), data.NewPoint(30*d, -0.9*d, 10, 0, 0, 0, 0, 2)},
+		children:            [8]octree.INode{child, far},
+		globalChildrenCount: 6,
+	}
+
+	samples, err := profile.Extract(root, line, 1, false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(samples) != 3 {
+		t.Fatalf("Expected 3 points in the profile, got %d", len(samples))
+	}
+	expected := [][2]float64{{10, 0.5}, {30, -0.9}, {70, -0.8}}
+	for i, s := range samples {
+		if math.Abs(s.Distance-expected[i][0]) > 0.01 || math.Abs(s.Offset-expected[i][1]) > 0.01 {
+			t.Errorf("Expected point %d at %v, got distance %f and offset %f", i, expected[i], s.Distance, s.Offset)
+		}
+	}
+
+	table, err := profile.ToCsv(samples)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if lines := strings.Split(strings.TrimSpace(string(table)), "\n"); len(lines) != 4 || !strings.HasPrefix(lines[1], "10.000,0.500,") {
+		t.Errorf("Unexpected table %s", string(table))
+	}
+	if svg := string(profile.ToSvg(samples)); !strings.HasPrefix(svg, "<svg") || strings.Count(svg, `width="2"`) != 3 {
+		t.Errorf("Unexpected chart %s", svg)
+	}
+}
+
+func TestExtractRejectsProjectedTrees(t *testing.T) {
+	root := &mockNode{boundingBox: geometry.NewBoundingBox(0, 1, 0, 1, 0, 1), internalSrid: 3395}
+	if _, err := profile.Extract(root, profile.Line{{0, 0}, {1, 1}}, 1, false, nil); err == nil {
+		t.Errorf("Expected an error for a tree of projected coordinates")
+	}
+}
@@ -0,0 +1,131 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/progress"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReporterDeliversConcurrentEventsInSequence(t *testing.T) {
+	var running int32
+	var received []uint64
+	reporter := progress.NewReporter(func(event progress.Event) {
+		if atomic.AddInt32(&running, 1) != 1 {
+			t.Errorf("Expected the callback never to run concurrently")
+		}
+		received = append(received, event.Sequence)
+		atomic.AddInt32(&running, -1)
+	})
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for j := 0; j < 1000; j++ {
+				reporter.Report(progress.Event{Type: progress.TileWritten})
+			}
+		}()
+	}
+	waitGroup.Wait()
+
+	if len(received) != 8000 {
+		t.Fatalf("Expected 8000 events, got %d", len(received))
+	}
+	for i, sequence := range received {
+		if sequence != uint64(i+1) {
+			t.Fatalf("Expected event %d to have sequence %d, got %d", i, i+1, sequence)
+		}
+	}
+}
+
+func TestChannelReporterSendsEvents(t *testing.T) {
+	events := make(chan progress.Event, 1)
+	progress.NewChannelReporter(events).Report(progress.Event{Type: progress.PhaseStarted, File: "test.las", Phase: "read"})
+
+	event := <-events
+	if event.Type != progress.PhaseStarted || event.File != "test.las" || event.Phase != "read" || event.Sequence != 1 {
+		t.Errorf("Unexpected event %v", event)
+	}
+}
+
+func TestNilReporterIgnoresEvents(t *testing.T) {
+	var reporter *progress.Reporter
+	reporter.Report(progress.Event{Type: progress.FileStarted})
+}
+
+func TestTrackedTreeReportsBatchesOfPoints(t *testing.T) {
+	var events []progress.Event
+	reporter := progress.NewReporter(func(event progress.Event) {
+		events = append(events, event)
+	})
+	inner := &mockTree{}
+	tree := reporter.TrackTree(inner, "test.las")
+	for i := 0; i < 2*progress.BatchSize+10; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 1, Y: 2, Z: 3}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if len(inner.points) != 2*progress.BatchSize+10 {
+		t.Errorf("Expected all points to be added to the tracked tree, got %d", len(inner.points))
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	for i, event := range events {
+		if event.Type != progress.PointsRead || event.File != "test.las" || event.Points != int64((i+1)*progress.BatchSize) {
+			t.Errorf("Unexpected event %v", event)
+		}
+	}
+}
+
+func TestConsumerReportsWrittenTiles(t *testing.T) {
+	tempdir := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	var tiles []string
+	opts := &tiler.TilerOptions{Srid: 4326, Progress: progress.NewReporter(func(event progress.Event) {
+		if event.Type == progress.TileWritten {
+			tiles = append(tiles, event.Tile)
+		}
+	})}
+
+	workChannel := make(chan *io.WorkUnit, 1)
+	workChannel <- &io.WorkUnit{
+		Node: &mockNode{
+			boundingBox:         geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+			points:              []*data.Point{data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)},
+			internalSrid:        4326,
+			globalChildrenCount: 1,
+			localChildrenCount:  1,
+			leaf:                true,
+			opts:                opts,
+		},
+		Opts:     opts,
+		BasePath: tempdir,
+		RootPath: tempdir,
+	}
+	close(workChannel)
+	errorChannel := make(chan error, 1)
+
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage())
+	consumer.Consume(workChannel, errorChannel, &waitGroup)
+	waitGroup.Wait()
+	close(errorChannel)
+
+	if err := <-errorChannel; err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(tiles) != 1 || tiles[0] != tempdir {
+		t.Errorf("Expected the tile written in %s to be reported, got %v", tempdir, tiles)
+	}
+}
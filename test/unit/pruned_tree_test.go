@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"testing"
+)
+
+func TestPruningGeometricError(t *testing.T) {
+	expected := 16 * 10 * 2 * math.Tan(math.Pi/6) / 1080
+	if geometricError := octree.PruningGeometricError(16, 10); math.Abs(geometricError-expected) > 1e-12 {
+		t.Errorf("Expected geometric error %f, got %f", expected, geometricError)
+	}
+}
+
+func TestPrunedTreeMergesTilesNeverRequested(t *testing.T) {
+	full := buildPrunedTestTree(t, 0)
+	pruned := buildPrunedTestTree(t, 10)
+
+	if total := countStoredPoints(t, pruned.GetRootNode()); total != 1000 {
+		t.Errorf("Expected 1000 points stored in the pruned tree, got %d", total)
+	}
+	fullTiles, prunedTiles := countTiles(full.GetRootNode()), countTiles(pruned.GetRootNode())
+	if prunedTiles >= fullTiles {
+		t.Errorf("Expected less than %d tiles after pruning, got %d", fullTiles, prunedTiles)
+	}
+	assertNoRefinementBelow(t, pruned.GetRootNode(), 10)
+}
+
+func TestPrunedTreeCollapsesToRoot(t *testing.T) {
+	root := buildPrunedTestTree(t, math.MaxFloat64).GetRootNode()
+
+	if !root.IsLeaf() || root.NumberOfPoints() != 1000 {
+		t.Errorf("Expected a leaf root with 1000 points, got leaf %t with %d points", root.IsLeaf(), root.NumberOfPoints())
+	}
+	for _, child := range root.GetChildren() {
+		if child != nil {
+			t.Errorf("Expected no children in the collapsed root")
+		}
+	}
+}
+
+func buildPrunedTestTree(t *testing.T, maxGeometricError float64) octree.ITree {
+	tree := octree.NewPrunedTree(grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{}), maxGeometricError)
+
+	for i := 0; i < 1000; i++ {
+		coord := &geometry.Coordinate{X: float64(i%10) * 3, Y: float64((i/10)%10) * 3, Z: float64(i / 100)}
+		tree.AddPoint(coord, 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	return tree
+}
+
+func countTiles(node octree.INode) int {
+	tiles := 0
+	if node.NumberOfPoints() > 0 {
+		tiles++
+	}
+	for _, child := range node.GetChildren() {
+		if child != nil {
+			tiles += countTiles(child)
+		}
+	}
+	return tiles
+}
+
+// Checks that no node with children holding points has a geometric error lower than the given one
+func assertNoRefinementBelow(t *testing.T, node octree.INode, maxGeometricError float64) {
+	refined := false
+	for _, child := range node.GetChildren() {
+		refined = refined || (child != nil && child.TotalNumberOfPoints() > 0)
+	}
+	if refined && node.ComputeGeometricError() <= maxGeometricError {
+		t.Errorf("Found node with geometric error %f refined into children", node.ComputeGeometricError())
+	}
+	for _, child := range node.GetChildren() {
+		if child != nil {
+			assertNoRefinementBelow(t, child, maxGeometricError)
+			if child.GetParent() != node {
+				t.Errorf("Expected children to reference the pruned parent")
+			}
+		}
+	}
+}
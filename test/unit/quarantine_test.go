@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/quarantine"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestQuarantineTreeRoutesInvalidPointsToTheReport(t *testing.T) {
+	inner := &mockTree{}
+	tree := quarantine.NewQuarantineTree(inner, &mockCoordinateConverter{}, &mockElevationCorrector{}, []float64{10, 40, 20, 50})
+
+	for _, coordinate := range []geometry.Coordinate{
+		{X: 14, Y: 42, Z: 10},
+		{X: math.NaN(), Y: 42, Z: 10},
+		{X: 14, Y: 4600000, Z: 10},
+		{X: 14, Y: 42, Z: math.Inf(1)},
+		{X: 14, Y: 42, Z: 1e6},
+		{X: 30, Y: 42, Z: 10},
+	} {
+		coordinate := coordinate
+		tree.AddPoint(&coordinate, 1, 2, 3, 4, 5, 4326, nil)
+	}
+
+	if len(inner.points) != 1 {
+		t.Fatalf("Expected a single point to be tiled, got %d", len(inner.points))
+	}
+	assertPoint(t, inner, 14, 42, 10)
+	counts := tree.GetCounts()
+	if tree.GetCount() != 5 || counts[quarantine.ReasonNonFinite] != 2 || counts[quarantine.ReasonOutOfRange] != 2 || counts[quarantine.ReasonOutsideArea] != 1 {
+		t.Errorf("Unexpected quarantined points %v", counts)
+	}
+
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	reportPath := quarantine.GetReportPath(folder, "cloud")
+	if err := tree.WriteReport(storage.NewOsStorage(), reportPath); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	content, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 6 || lines[0] != "x,y,z,srid,longitude,latitude,height,reason" {
+		t.Fatalf("Unexpected report %s", string(content))
+	}
+	if lines[1] != "NaN,42,10,4326,NaN,NaN,NaN,NON_FINITE" {
+		t.Errorf("Unexpected report line %s", lines[1])
+	}
+	if lines[5] != "30,42,10,4326,30,42,20,OUTSIDE_AREA" {
+		t.Errorf("Unexpected report line %s", lines[5])
+	}
+
+	tree.Reset()
+	if tree.GetCount() != 0 {
+		t.Errorf("Expected no quarantined points after a reset, got %d", tree.GetCount())
+	}
+}
+
+func TestValidityAreaIsParsed(t *testing.T) {
+	area, ok := tiler.ParseValidityArea(" 12, 46,18,48 ")
+	if !ok || len(area) != 4 || area[0] != 12 || area[1] != 46 || area[2] != 18 || area[3] != 48 {
+		t.Errorf("Unexpected validity area %v", area)
+	}
+	if area, ok := tiler.ParseValidityArea(""); !ok || area != nil {
+		t.Errorf("Expected no validity area, got %v", area)
+	}
+	for _, invalid := range []string{"12,46,18", "12,46,18,x", "12,46,18,48,0"} {
+		if _, ok := tiler.ParseValidityArea(invalid); ok {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/raster_elevation_corrector"
+	"testing"
+)
+
+func TestRasterCorrectionIsAdded(t *testing.T) {
+	corrections := loadTestDem(t, "ncols 2\nnrows 2\nxllcorner 10\nyllcorner 40\ncellsize 1\nNODATA_value -9999\n0.5 -9999\n-0.25 0.75\n")
+	corrector := raster_elevation_corrector.NewRasterElevationCorrector(corrections)
+
+	// rows are stored from north to south
+	if actual := corrector.CorrectElevation(10.5, 41.5, 3); actual != 3.5 {
+		t.Errorf("Expected Elevation = 3.5, got %f", actual)
+	}
+	if actual := corrector.CorrectElevation(10.5, 40.5, 3); actual != 2.75 {
+		t.Errorf("Expected Elevation = 2.75, got %f", actual)
+	}
+}
+
+func TestRasterCorrectionIsSkippedOutsideOfTheGrid(t *testing.T) {
+	corrections := loadTestDem(t, "ncols 2\nnrows 2\nxllcorner 10\nyllcorner 40\ncellsize 1\nNODATA_value -9999\n0.5 -9999\n-0.25 0.75\n")
+	corrector := raster_elevation_corrector.NewRasterElevationCorrector(corrections)
+
+	if actual := corrector.CorrectElevation(11.5, 41.5, 3); actual != 3 {
+		t.Errorf("Expected no data cells to leave Elevation = 3, got %f", actual)
+	}
+	if actual := corrector.CorrectElevation(20, 41.5, 3); actual != 3 {
+		t.Errorf("Expected points outside of the grid to leave Elevation = 3, got %f", actual)
+	}
+}
@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"bytes"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/raster"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRasterizerPreservesAspectRatio(t *testing.T) {
+	boundingBox := geometry.NewBoundingBox(0, 20, 0, 10, 0, 1)
+	img := raster.NewRasterizer(64).Render(nil, boundingBox)
+
+	if img.Bounds().Dx() != 64 || img.Bounds().Dy() != 32 {
+		t.Errorf("Expected a 64x32 image, got %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	if _, _, _, a := img.At(10, 10).RGBA(); a != 0 {
+		t.Errorf("Expected pixels without points to be transparent")
+	}
+}
+
+func TestRasterizerDrawsHighestPointFromAbove(t *testing.T) {
+	boundingBox := geometry.NewBoundingBox(0, 10, 0, 10, 0, 10)
+	points := []*data.Point{
+		data.NewPoint(0.1, 9.9, 8, 255, 0, 0, 0, 0),
+		data.NewPoint(0.1, 9.9, 2, 0, 255, 0, 0, 0),
+		data.NewPoint(10, 0, 5, 0, 0, 255, 0, 0),
+	}
+	img := raster.NewRasterizer(10).Render(points, boundingBox)
+
+	// north west corner is the first pixel of the image
+	if c := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA); c != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("Expected the highest point to be drawn, got %v", c)
+	}
+	// points on the max bounds are drawn in the last pixel
+	if c := color.NRGBAModel.Convert(img.At(9, 9)).(color.NRGBA); c != (color.NRGBA{B: 255, A: 255}) {
+		t.Errorf("Expected the south east point in the last pixel, got %v", c)
+	}
+}
+
+func TestRasterizerShadesUncoloredPointsByElevation(t *testing.T) {
+	boundingBox := geometry.NewBoundingBox(0, 10, 0, 10, 0, 10)
+	points := []*data.Point{
+		data.NewPoint(0, 10, 0, 0, 0, 0, 0, 0),
+		data.NewPoint(10, 0, 10, 0, 0, 0, 0, 0),
+	}
+	pngData, err := raster.NewRasterizer(10).RenderPng(points, boundingBox)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("the thumbnail is not a valid PNG: %v", err)
+	}
+
+	low := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA)
+	high := color.NRGBAModel.Convert(img.At(9, 9)).(color.NRGBA)
+	if low.A != 255 || high.A != 255 || low.R >= high.R {
+		t.Errorf("Expected higher points to be lighter, got %v for the lowest and %v for the highest", low, high)
+	}
+}
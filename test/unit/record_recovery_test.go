@@ -0,0 +1,161 @@
+package unit
+
+import (
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/las_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/third_party/lasread"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Writes a LAS 1.2 file of the given records declaring the bounds of the coordinates of the test records
+func writeBoundedLasTestFile(t testing.TB, filePath string, records [][]byte) {
+	writeLasTestFile(t, filePath, 2, 3, lazTestRecordLength, records, nil)
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	minX, maxX, minY, maxY, minZ, maxZ := math.MaxFloat64, -math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64
+	for _, record := range records {
+		x := float64(int32(binary.LittleEndian.Uint32(record[0:4]))) * 0.01
+		y := float64(int32(binary.LittleEndian.Uint32(record[4:8]))) * 0.01
+		z := float64(int32(binary.LittleEndian.Uint32(record[8:12]))) * 0.01
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		minZ, maxZ = math.Min(minZ, z), math.Max(maxZ, z)
+	}
+	for i, value := range []float64{maxX, minX, maxY, minY, maxZ, minZ} {
+		binary.LittleEndian.PutUint64(content[179+8*i:], math.Float64bits(value))
+	}
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+}
+
+func readRecovering(t *testing.T, filePath string) (*mockTree, *lidario.RecoveryCounts, error) {
+	tree, counts := &mockTree{}, &lidario.RecoveryCounts{}
+	err := las_reader.NewLasReader(nil, storage.NewOsStorage(), 2, 64, nil, nil, counts, nil).Read(filePath, 4326, tree)
+	return tree, counts, err
+}
+
+func TestMalformedLasRecordsAreSkipped(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	records := createLazTestRecords(500)
+	filePath := path.Join(folder, "cloud.las")
+	writeBoundedLasTestFile(t, filePath, records)
+
+	// corrupt the coordinates of two records and truncate the file in the middle of the last ten ones
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	offset := len(content) - len(records)*lazTestRecordLength
+	for _, index := range []int{10, 200} {
+		binary.LittleEndian.PutUint32(content[offset+index*lazTestRecordLength:], 0x7fffffff)
+	}
+	content = content[:len(content)-9*lazTestRecordLength-lazTestRecordLength/2]
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	tree, counts, err := readRecovering(t, filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if counts.Skipped != 12 {
+		t.Errorf("Expected 12 records to be skipped, got %d", counts.Skipped)
+	}
+	if len(tree.points) != 488 {
+		t.Errorf("Expected 488 points to be read, got %d", len(tree.points))
+	}
+}
+
+func TestTruncatedLasFilesAreRejectedWithoutRecovery(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	filePath := path.Join(folder, "cloud.las")
+	writeBoundedLasTestFile(t, filePath, createLazTestRecords(500))
+
+	// only the first three records and half of the fourth one are left
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	offset := len(content) - 500*lazTestRecordLength
+	if err := ioutil.WriteFile(filePath, content[:offset+3*lazTestRecordLength+lazTestRecordLength/2], 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	tree := &mockTree{}
+	err = las_reader.NewLasReader(nil, storage.NewOsStorage(), 2, 64, nil, nil, nil, nil).Read(filePath, 4326, tree)
+	if err == nil || !strings.Contains(err.Error(), "truncated point data: the file holds 3 of the 500 points") {
+		t.Errorf("Expected a truncated point data error, got %v", err)
+	}
+	if len(tree.points) != 0 {
+		t.Errorf("Expected no points of the truncated batch to be read, got %d", len(tree.points))
+	}
+}
+
+func TestCorruptedLazChunksAreSkipped(t *testing.T) {
+	records := createLazTestRecords(300)
+	filePath, offset := writeLazTestFile(t, records, 100)
+	defer func() { _ = os.RemoveAll(path.Dir(filePath)) }()
+
+	// the first chunk starts after the position of the chunk table, its raw first point is followed by its compressed
+	// points, which are scrambled
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	for i := offset + 8 + lazTestRecordLength; i < offset+8+lazTestRecordLength+40; i++ {
+		content[i] = 0xff
+	}
+	if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if err := las_reader.NewLasReader(nil, storage.NewOsStorage(), 1, 0, nil, nil, nil, nil).Read(filePath, 4326, &mockTree{}); err == nil {
+		t.Fatalf("Expected an error reading the corrupted chunk without recovery")
+	}
+	tree, counts, err := readRecovering(t, filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if counts.Skipped != 100 || counts.Recovered != 200 {
+		t.Errorf("Expected 100 records skipped and 200 recovered, got %d and %d", counts.Skipped, counts.Recovered)
+	}
+	if len(tree.points) != 200 {
+		t.Errorf("Expected the 200 points of the other chunks to be read, got %d", len(tree.points))
+	}
+}
+
+func FuzzMalformedLasFilesAreRead(f *testing.F) {
+	folder := createTempFolder(f)
+	defer func() { _ = os.RemoveAll(folder) }()
+	records := createLazTestRecords(300)
+	writeBoundedLasTestFile(f, path.Join(folder, "cloud.las"), records)
+	lazPath, _ := writeLazTestFile(f, records, 100)
+	defer func() { _ = os.RemoveAll(path.Dir(lazPath)) }()
+	for _, seed := range []string{path.Join(folder, "cloud.las"), lazPath} {
+		content, err := ioutil.ReadFile(seed)
+		if err != nil {
+			f.Fatalf("Unexpected error: %s", err.Error())
+		}
+		f.Add(content)
+	}
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		filePath := path.Join(t.TempDir(), "fuzz.las")
+		if err := ioutil.WriteFile(filePath, content, 0666); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		// malformed files may be rejected, but never crash the reader
+		_, _, _ = readRecovering(t, filePath)
+	})
+}
@@ -0,0 +1,210 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/rosbag_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRosBagReaderReadsPointCloudWithoutPoses(t *testing.T) {
+	bagFile := writeTestBag(t, "none")
+	defer func() { _ = os.RemoveAll(path.Dir(bagFile)) }()
+
+	tree := &mockTree{}
+	err := rosbag_reader.NewRosBagReader("/cloud", "", nil, storage.NewOsStorage(), nil).Read(bagFile, 4978, tree)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(tree.points) != 1 {
+		t.Fatalf("Expected 1 point, got %d", len(tree.points))
+	}
+	assertPoint(t, tree, 1, 0, 0)
+	point := tree.points[0]
+	if point.R != 10 || point.G != 20 || point.B != 30 {
+		t.Errorf("Expected color (10, 20, 30), got (%d, %d, %d)", point.R, point.G, point.B)
+	}
+	if point.Intensity != 255 {
+		t.Errorf("Expected intensity clamped to 255, got %d", point.Intensity)
+	}
+	if tree.srids[0] != 4978 {
+		t.Errorf("Expected srid 4978, got %d", tree.srids[0])
+	}
+}
+
+func TestRosBagReaderAppliesInterpolatedPose(t *testing.T) {
+	bagFile := writeTestBag(t, "none")
+	defer func() { _ = os.RemoveAll(path.Dir(bagFile)) }()
+
+	tree := &mockTree{}
+	err := rosbag_reader.NewRosBagReader("/cloud", "/pose", nil, storage.NewOsStorage(), nil).Read(bagFile, 4978, tree)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(tree.points) != 1 {
+		t.Fatalf("Expected 1 point, got %d", len(tree.points))
+	}
+	// translation interpolated between (10,0,0) and (30,0,0), point rotated by 90 degrees around z
+	assertPoint(t, tree, 20, 1, 0)
+}
+
+func TestRosBagReaderSkipsOtherCloudTopics(t *testing.T) {
+	bagFile := writeTestBag(t, "none")
+	defer func() { _ = os.RemoveAll(path.Dir(bagFile)) }()
+
+	tree := &mockTree{}
+	err := rosbag_reader.NewRosBagReader("/other_cloud", "", nil, storage.NewOsStorage(), nil).Read(bagFile, 4978, tree)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if len(tree.points) != 0 {
+		t.Errorf("Expected no points, got %d", len(tree.points))
+	}
+}
+
+func TestRosBagReaderRejectsUnsupportedCompression(t *testing.T) {
+	bagFile := writeTestBag(t, "lz4")
+	defer func() { _ = os.RemoveAll(path.Dir(bagFile)) }()
+
+	err := rosbag_reader.NewRosBagReader("", "", nil, storage.NewOsStorage(), nil).Read(bagFile, 4978, &mockTree{})
+	if err == nil {
+		t.Errorf("Expected an error for lz4 compressed chunks")
+	}
+}
+
+func assertPoint(t *testing.T, tree *mockTree, x float64, y float64, z float64) {
+	point := tree.points[0]
+	if math.Abs(point.X-x) > 1e-6 || math.Abs(point.Y-y) > 1e-6 || math.Abs(point.Z-z) > 1e-6 {
+		t.Errorf("Expected point (%f, %f, %f), got (%f, %f, %f)", x, y, z, point.X, point.Y, point.Z)
+	}
+}
+
+// writes a bag with a single chunk holding a PointCloud2 message at t=2 on /cloud and two PoseStamped messages
+// at t=1 and t=3 on /pose, returning the path of the bag file
+func writeTestBag(t *testing.T, compression string) string {
+	folder, err := ioutil.TempDir("", "rosbag")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	yaw90 := [4]float64{0, 0, math.Sin(math.Pi / 4), math.Cos(math.Pi / 4)}
+	chunk := new(bytes.Buffer)
+	chunk.Write(bagConnection(0, "/cloud", "sensor_msgs/PointCloud2"))
+	chunk.Write(bagConnection(1, "/pose", "geometry_msgs/PoseStamped"))
+	chunk.Write(bagMessage(1, 1, poseStampedMessage(1, [3]float64{10, 0, 0}, yaw90)))
+	chunk.Write(bagMessage(0, 2, pointCloud2Message(2, [3]float32{1, 0, 0}, 10, 20, 30, 300)))
+	chunk.Write(bagMessage(1, 3, poseStampedMessage(3, [3]float64{30, 0, 0}, yaw90)))
+
+	bag := new(bytes.Buffer)
+	bag.WriteString("#ROSBAG V2.0\n")
+	bag.Write(bagRecord([][]byte{
+		bagField("op", []byte{0x05}),
+		bagField("compression", []byte(compression)),
+		bagField("size", uint32Bytes(uint32(chunk.Len()))),
+	}, chunk.Bytes()))
+
+	bagFile := path.Join(folder, "test.bag")
+	if err := ioutil.WriteFile(bagFile, bag.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return bagFile
+}
+
+func bagConnection(id uint32, topic string, msgType string) []byte {
+	data := new(bytes.Buffer)
+	data.Write(bagField("topic", []byte(topic)))
+	data.Write(bagField("type", []byte(msgType)))
+	return bagRecord([][]byte{
+		bagField("op", []byte{0x07}),
+		bagField("conn", uint32Bytes(id)),
+		bagField("topic", []byte(topic)),
+	}, data.Bytes())
+}
+
+func bagMessage(conn uint32, sec uint32, data []byte) []byte {
+	return bagRecord([][]byte{
+		bagField("op", []byte{0x02}),
+		bagField("conn", uint32Bytes(conn)),
+		bagField("time", append(uint32Bytes(sec), uint32Bytes(0)...)),
+	}, data)
+}
+
+func bagRecord(fields [][]byte, data []byte) []byte {
+	header := bytes.Join(fields, nil)
+	out := new(bytes.Buffer)
+	out.Write(uint32Bytes(uint32(len(header))))
+	out.Write(header)
+	out.Write(uint32Bytes(uint32(len(data))))
+	out.Write(data)
+	return out.Bytes()
+}
+
+func bagField(name string, value []byte) []byte {
+	field := append([]byte(name+"="), value...)
+	return append(uint32Bytes(uint32(len(field))), field...)
+}
+
+func rosHeader(out *bytes.Buffer, sec uint32) {
+	out.Write(uint32Bytes(0))
+	out.Write(uint32Bytes(sec))
+	out.Write(uint32Bytes(0))
+	out.Write(rosString("map"))
+}
+
+func poseStampedMessage(sec uint32, translation [3]float64, rotation [4]float64) []byte {
+	out := new(bytes.Buffer)
+	rosHeader(out, sec)
+	for _, v := range translation {
+		_ = binary.Write(out, binary.LittleEndian, v)
+	}
+	for _, v := range rotation {
+		_ = binary.Write(out, binary.LittleEndian, v)
+	}
+	return out.Bytes()
+}
+
+// builds a single point PointCloud2 message with x, y, z, rgb and intensity float32 fields
+func pointCloud2Message(sec uint32, xyz [3]float32, r uint8, g uint8, b uint8, intensity float32) []byte {
+	out := new(bytes.Buffer)
+	rosHeader(out, sec)
+	out.Write(uint32Bytes(1)) // height
+	out.Write(uint32Bytes(1)) // width
+	fields := []string{"x", "y", "z", "rgb", "intensity"}
+	out.Write(uint32Bytes(uint32(len(fields))))
+	for i, name := range fields {
+		out.Write(rosString(name))
+		out.Write(uint32Bytes(uint32(i * 4)))
+		out.WriteByte(7) // float32
+		out.Write(uint32Bytes(1))
+	}
+	out.WriteByte(0)           // little endian
+	out.Write(uint32Bytes(20)) // point step
+	out.Write(uint32Bytes(20)) // row step
+
+	point := new(bytes.Buffer)
+	_ = binary.Write(point, binary.LittleEndian, xyz)
+	point.Write(uint32Bytes(uint32(r)<<16 | uint32(g)<<8 | uint32(b)))
+	_ = binary.Write(point, binary.LittleEndian, intensity)
+	out.Write(uint32Bytes(uint32(point.Len())))
+	out.Write(point.Bytes())
+	out.WriteByte(1) // is dense
+	return out.Bytes()
+}
+
+func rosString(value string) []byte {
+	return append(uint32Bytes(uint32(len(value))), []byte(value)...)
+}
+
+func uint32Bytes(value uint32) []byte {
+	out := make([]byte, 4)
+	binary.LittleEndian.PutUint32(out, value)
+	return out
+}
@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/runinfo"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRunMetadataIsSaved(t *testing.T) {
+	tempdir := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	opts := &tiler.TilerOptions{Srid: 32633, Algorithm: tiler.Grid, CellMaxSize: 5, Cancellation: cancellation.NewToken()}
+	run := runinfo.NewRun("1.2.0", opts)
+	input := run.StartInput("cloud.las", "abc", "cloud")
+	input.Finish()
+	if err := run.Save(storage.NewOsStorage(), path.Join(tempdir, runinfo.FileName)); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	content, err := ioutil.ReadFile(path.Join(tempdir, runinfo.FileName))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var saved struct {
+		Version string                 `json:"version"`
+		Options map[string]interface{} `json:"options"`
+		Inputs  []runinfo.Input        `json:"inputs"`
+	}
+	if err := json.Unmarshal(content, &saved); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if saved.Version != "1.2.0" || saved.Options["Srid"] != float64(32633) || saved.Options["Algorithm"] != "GRID" {
+		t.Errorf("Expected the version and the options to be saved, got %s %v", saved.Version, saved.Options)
+	}
+	if _, ok := saved.Options["Cancellation"]; ok {
+		t.Errorf("Expected the cancellation token not to be saved")
+	}
+	if len(saved.Inputs) != 1 || saved.Inputs[0].Hash != "abc" || saved.Inputs[0].Tileset != "cloud" {
+		t.Errorf("Unexpected inputs %+v", saved.Inputs)
+	}
+}
+
+func TestConsumerEmbedsRunMetadataInRootTileset(t *testing.T) {
+	tempdir := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	opts := &tiler.TilerOptions{Srid: 4326}
+	run := runinfo.NewRun("1.2.0", opts)
+	opts.RunExtras = run.GetTilesetExtras(run.StartInput("cloud.las", "abc", "cloud"))
+	node := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points:              []*data.Point{data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		opts:                opts,
+	}
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: opts, BasePath: tempdir})
+
+	content, err := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var tileset struct {
+		Asset struct {
+			Extras struct {
+				Run struct {
+					Version string `json:"version"`
+					Input   struct {
+						Sha256 string `json:"sha256"`
+					} `json:"input"`
+				} `json:"run"`
+			} `json:"extras"`
+		} `json:"asset"`
+	}
+	_ = json.Unmarshal(content, &tileset)
+	if tileset.Asset.Extras.Run.Version != "1.2.0" || tileset.Asset.Extras.Run.Input.Sha256 != "abc" {
+		t.Errorf("Expected the run metadata in the root tileset extras, got %s", string(content))
+	}
+}
@@ -0,0 +1,99 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/scans"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestScanPosesAreLoaded(t *testing.T) {
+	filePath := path.Join(createTempFolder(t), "poses.csv")
+	content := "scan,x,y,z,qx,qy,qz,qw\n# registered scans\nstation_1,1,2,3,0,0,0,1\nstation_2.las, 4, 5, 6, 0, 0, 0.7071, 0.7071\n"
+	if err := ioutil.WriteFile(filePath, []byte(content), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	poses, err := scans.LoadPoses(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(poses) != 2 {
+		t.Fatalf("Expected 2 poses, got %d", len(poses))
+	}
+	pose := poses["station_2.las"]
+	if pose == nil || pose.Position != [3]float64{4, 5, 6} || pose.Orientation != [4]float64{0, 0, 0.7071, 0.7071} {
+		t.Errorf("Unexpected pose %+v", pose)
+	}
+}
+
+func TestInvalidScanPoseIsRejected(t *testing.T) {
+	filePath := path.Join(createTempFolder(t), "poses.csv")
+	if err := ioutil.WriteFile(filePath, []byte("station_1,1,2,3,0,0,0,1\nstation_2,a,2,3,0,0,0,1\n"), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if _, err := scans.LoadPoses(filePath); err == nil {
+		t.Errorf("Expected error loading invalid scan pose")
+	}
+}
+
+func TestScanRegistryMatchesPosesByFileName(t *testing.T) {
+	poses := map[string]*scans.Pose{
+		"station_1":     {Position: [3]float64{1, 2, 3}},
+		"station_2.laz": {Position: [3]float64{4, 5, 6}},
+	}
+	registry := scans.NewRegistry([]string{"/data/station_1.las", "/data/station_2.laz", "/data/station_3.las"}, poses)
+
+	found := registry.GetScans()
+	if len(found) != 3 {
+		t.Fatalf("Expected 3 scans, got %d", len(found))
+	}
+	if found[0].Id != 0 || found[0].Name != "station_1.las" || found[0].Pose != poses["station_1"] {
+		t.Errorf("Unexpected first scan %+v", found[0])
+	}
+	if found[1].Pose != poses["station_2.laz"] {
+		t.Errorf("Expected the second scan to match the pose keyed by its file name with extension")
+	}
+	if found[2].Id != 2 || found[2].Pose != nil {
+		t.Errorf("Expected the third scan to have no pose, got %+v", found[2])
+	}
+}
+
+func TestScanTreeTagsAndCountsPoints(t *testing.T) {
+	registry := scans.NewRegistry([]string{"station_1.las", "station_2.las"}, nil)
+	inner := &mockTree{}
+	first := registry.Track(inner, 0)
+	second := registry.Track(inner, 1)
+
+	// the attributes of the reader have spare capacity, which must not be written by the scan id
+	attributes := make([]float32, 1, 4)
+	attributes[0] = 7
+	first.AddPoint(&geometry.Coordinate{X: 1, Y: 2, Z: 3}, 10, 20, 30, 40, 50, 4326, attributes)
+	second.AddPoint(&geometry.Coordinate{X: 4, Y: 5, Z: 6}, 10, 20, 30, 40, 50, 4326, attributes)
+	second.AddPoint(&geometry.Coordinate{X: 7, Y: 8, Z: 9}, 10, 20, 30, 40, 50, 4326, nil)
+
+	if first := inner.points[0].Attributes; len(first) != 2 || first[0] != 7 || first[1] != 0 {
+		t.Errorf("Expected the attributes of the first point to be followed by scan id 0, got %v", first)
+	}
+	if second := inner.points[1].Attributes; len(second) != 2 || second[0] != 7 || second[1] != 1 {
+		t.Errorf("Expected the attributes of the second point to be followed by scan id 1, got %v", second)
+	}
+	if third := inner.points[2].Attributes; len(third) != 1 || third[0] != 1 {
+		t.Errorf("Expected the third point to hold only scan id 1, got %v", third)
+	}
+
+	extras := registry.GetTilesetExtras()
+	if extras[0]["points"] != int64(1) || extras[1]["points"] != int64(2) || extras[1]["name"] != "station_2.las" {
+		t.Errorf("Unexpected scan extras %v", extras)
+	}
+	if _, ok := extras[0]["pose"]; ok {
+		t.Errorf("Expected no pose in the extras of a scan without pose")
+	}
+
+	registry.ResetCounts()
+	if extras := registry.GetTilesetExtras(); extras[0]["points"] != int64(0) || extras[1]["points"] != int64(0) {
+		t.Errorf("Expected the point counts to be reset, got %v", extras)
+	}
+}
@@ -0,0 +1,68 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/sidecar"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSidecarTableJoinsAttributesByIndex(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	tablePath := path.Join(folder, "cloud.csv")
+	content := "index,segment,score\n# comment\n0,12,0.5\n2,7,0.25\n"
+	if err := ioutil.WriteFile(tablePath, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := sidecar.LoadTable(storage.NewOsStorage(), tablePath, tiler.SidecarKeyIndex)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(table.Names) != 2 || table.Names[0] != "segment" || table.Names[1] != "score" {
+		t.Errorf("Expected the attributes segment and score, got %v", table.Names)
+	}
+	if attributes := table.GetAttributes(2, 100); len(attributes) != 2 || attributes[0] != 7 || attributes[1] != 0.25 {
+		t.Errorf("Expected the attributes [7 0.25] for the point 2, got %v", attributes)
+	}
+	if attributes := table.GetAttributes(1, 0); attributes != nil {
+		t.Errorf("Expected no attributes for the point 1, got %v", attributes)
+	}
+}
+
+func TestSidecarTableJoinsAttributesByGpsTime(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	tablePath := path.Join(folder, "cloud.csv")
+	if err := ioutil.WriteFile(tablePath, []byte("gps_time,segment\n1234.5,3\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := sidecar.LoadTable(storage.NewOsStorage(), tablePath, tiler.SidecarKeyGpsTime)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if attributes := table.GetAttributes(0, 1234.5); len(attributes) != 1 || attributes[0] != 3 {
+		t.Errorf("Expected the attributes [3], got %v", attributes)
+	}
+	if attributes := table.GetAttributes(0, 1234); attributes != nil {
+		t.Errorf("Expected no attributes for a different GPS time, got %v", attributes)
+	}
+}
+
+func TestSidecarTableRejectsInvalidRecords(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	tablePath := path.Join(folder, "cloud.csv")
+	if err := ioutil.WriteFile(tablePath, []byte("index,segment\n0,abc\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sidecar.LoadTable(storage.NewOsStorage(), tablePath, tiler.SidecarKeyIndex); err == nil {
+		t.Errorf("Expected an error for a non numeric attribute")
+	}
+}
@@ -0,0 +1,50 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"testing"
+)
+
+func TestSourceColorTreeReplacesPointColors(t *testing.T) {
+	inner := &mockTree{}
+	tree := octree.NewSourceColorTree(inner, 3)
+	tree.AddPoint(&geometry.Coordinate{X: 1, Y: 2, Z: 3}, 10, 20, 30, 40, 50, 4326, nil)
+
+	r, g, b := octree.SourceColor(3)
+	point := inner.points[0]
+	if point.R != r || point.G != g || point.B != b {
+		t.Errorf("Expected color %d %d %d, got %d %d %d", r, g, b, point.R, point.G, point.B)
+	}
+	if point.X != 1 || point.Y != 2 || point.Z != 3 || point.Intensity != 40 || point.Classification != 50 || inner.srids[0] != 4326 {
+		t.Errorf("Expected the other point data to be unchanged, got %+v", point)
+	}
+}
+
+func TestSourceColorsAreDistinct(t *testing.T) {
+	colors := make(map[[3]uint8]int)
+	for i := 0; i < 64; i++ {
+		r, g, b := octree.SourceColor(i)
+		color := [3]uint8{r, g, b}
+		if previous, ok := colors[color]; ok {
+			t.Fatalf("Sources %d and %d have the same color %v", previous, i, color)
+		}
+		colors[color] = i
+	}
+
+	// consecutive sources should be easy to tell apart
+	for i := 0; i < 63; i++ {
+		r1, g1, b1 := octree.SourceColor(i)
+		r2, g2, b2 := octree.SourceColor(i + 1)
+		if distance := abs(int(r1)-int(r2)) + abs(int(g1)-int(g2)) + abs(int(b1)-int(b2)); distance < 96 {
+			t.Errorf("Expected consecutive sources %d and %d to have far apart colors, distance %d", i, i+1, distance)
+		}
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"testing"
+)
+
+func TestSplitTreeSplitsOversizedTiles(t *testing.T) {
+	tree := buildSplitTestTree(t, func(i int) *geometry.Coordinate {
+		return &geometry.Coordinate{X: float64(i%10) * 3, Y: float64((i/10)%10) * 3, Z: float64(i / 100)}
+	})
+	root := tree.GetRootNode()
+
+	if total := countStoredPoints(t, root); total != 1000 {
+		t.Errorf("Expected 1000 points stored in the split tree, got %d", total)
+	}
+	if split, _ := tree.GetSplitTiles(); split == 0 {
+		t.Fatalf("Expected oversized tiles to be split")
+	}
+	assertSplitTiles(t, root, 10)
+}
+
+func TestSplitTreeStopsSplittingCoincidentPoints(t *testing.T) {
+	tree := buildSplitTestTree(t, func(i int) *geometry.Coordinate {
+		return &geometry.Coordinate{X: 1, Y: 1, Z: 1}
+	})
+
+	if total := countStoredPoints(t, tree.GetRootNode()); total != 1000 {
+		t.Errorf("Expected 1000 points stored in the split tree, got %d", total)
+	}
+	// the empty child of the leaf holding the points receives them, followed by at most 16 levels of new tiles
+	if _, created := tree.GetSplitTiles(); created == 0 || created > 17 {
+		t.Errorf("Expected at most 17 tiles to be created for coincident points, got %d tiles", created)
+	}
+}
+
+func TestSplitTreeLeavesSmallTreesUntouched(t *testing.T) {
+	inner := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        20.0,
+		CellMinSize:        10.0,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+	// every tile can hold the 1000 points of the tree
+	tree := octree.NewSplitTree(inner, 100+1000*20, 20, 100)
+	for i := 0; i < 1000; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 1, Y: 1, Z: 1}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+
+	if tree.GetRootNode() != inner.GetRootNode() {
+		t.Errorf("Expected the root of a tree holding fewer points than a tile to be left untouched")
+	}
+	if split, created := tree.GetSplitTiles(); split != 0 || created != 0 {
+		t.Errorf("Expected no tile to be split, got %d split and %d created", split, created)
+	}
+}
+
+// Builds a shallow tree of 1000 points placed by the given function, whose tiles are split to hold at most 10 points,
+// estimating 20 bytes per point and 100 bytes per tile
+func buildSplitTestTree(t *testing.T, coordinate func(i int) *geometry.Coordinate) *octree.SplitTree {
+	tree := octree.NewSplitTree(grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        20.0,
+		CellMinSize:        10.0,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{}), 100+10*20, 20, 100)
+
+	for i := 0; i < 1000; i++ {
+		tree.AddPoint(coordinate(i), 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	return tree
+}
+
+// Checks that no tile holds more than the given number of points, that the points of the tiles lie in their bounding
+// boxes and that the geometric error of the new tiles is half the one of their parent
+func assertSplitTiles(t *testing.T, node octree.INode, maxPoints int) {
+	if len(node.GetPoints()) > maxPoints {
+		t.Errorf("Found tile with %d points", len(node.GetPoints()))
+	}
+	box := node.GetBoundingBox()
+	for _, point := range node.GetPoints() {
+		if point.X < box.Xmin || point.X > box.Xmax || point.Y < box.Ymin || point.Y > box.Ymax || point.Z < box.Zmin || point.Z > box.Zmax {
+			t.Errorf("Found point %v outside of the bounding box of its tile", *point)
+		}
+	}
+	for _, child := range node.GetChildren() {
+		if child != nil && child.TotalNumberOfPoints() > 0 {
+			if node.IsLeaf() || child.GetParent() != node || child.ComputeGeometricError() != node.ComputeGeometricError()/2 {
+				t.Errorf("Expected the children of a refined tile to reference it and halve its geometric error")
+			}
+			assertSplitTiles(t, child, maxPoints)
+		}
+	}
+}
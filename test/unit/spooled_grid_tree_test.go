@@ -0,0 +1,165 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpooledGridTreeMatchesInMemoryGridTree(t *testing.T) {
+	spoolFolder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(spoolFolder) }()
+
+	var coordinates []geometry.Coordinate
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		coordinates = append(coordinates, geometry.Coordinate{X: random.Float64() * 20, Y: random.Float64() * 20, Z: random.Float64() * 5})
+	}
+	memory := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5,
+		CellMinSize:        0.5,
+		RootGeometricError: 1,
+		InsertWorkers:      1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+	spooled := grid_tree.NewSpooledGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5,
+		CellMinSize:        0.5,
+		RootGeometricError: 1,
+		SpoolFolder:        spoolFolder,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{}).(octree.MultiPassTree)
+	for i := range coordinates {
+		memory.AddPoint(&coordinates[i], 1, 2, 3, 4, 5, 4326, []float32{float32(i)})
+	}
+	for pass := 1; ; pass++ {
+		for i := range coordinates {
+			spooled.AddPoint(&coordinates[i], 1, 2, 3, 4, 5, 4326, []float32{float32(i)})
+		}
+		again, err := spooled.EndPass()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if !again {
+			if pass != 2 {
+				t.Fatalf("Expected 2 passes, got %d", pass)
+			}
+			break
+		}
+	}
+	if err := memory.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := spooled.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	seen := make(map[float32]bool)
+	var compare func(expected octree.INode, actual octree.INode)
+	compare = func(expected octree.INode, actual octree.INode) {
+		points := actual.GetPoints()
+		if len(points) != int(actual.NumberOfPoints()) || actual.NumberOfPoints() != expected.NumberOfPoints() || actual.TotalNumberOfPoints() != expected.TotalNumberOfPoints() {
+			t.Errorf("Expected a node of %d points, got %d", expected.NumberOfPoints(), len(points))
+		}
+		for _, point := range points {
+			if seen[point.Attributes[0]] {
+				t.Errorf("Point %v spooled more than once", point)
+			}
+			seen[point.Attributes[0]] = true
+			if point.R != 1 || point.Classification != 5 || point.X != coordinates[int(point.Attributes[0])].X {
+				t.Errorf("Unexpected spooled point %v", point)
+			}
+		}
+		for i, child := range expected.GetChildren() {
+			if child != nil {
+				compare(child, actual.GetChildren()[i])
+			}
+		}
+	}
+	compare(memory.GetRootNode(), spooled.GetRootNode())
+	if len(seen) != len(coordinates) {
+		t.Errorf("Expected %d spooled points, got %d", len(coordinates), len(seen))
+	}
+
+	if err := spooled.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if entries, _ := ioutil.ReadDir(spoolFolder); len(entries) != 0 {
+		t.Errorf("Expected the spool files to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestSpooledGridTreeRecordsTheSpoolReadErrors(t *testing.T) {
+	spoolFolder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(spoolFolder) }()
+	tree := grid_tree.NewSpooledGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5,
+		CellMinSize:        0.5,
+		RootGeometricError: 1,
+		SpoolFolder:        spoolFolder,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{}).(octree.MultiPassTree)
+	random := rand.New(rand.NewSource(1))
+	coordinates := make([]geometry.Coordinate, 1000)
+	for i := range coordinates {
+		coordinates[i] = geometry.Coordinate{X: random.Float64() * 20, Y: random.Float64() * 20, Z: random.Float64() * 5}
+	}
+	for again := true; again; {
+		for i := range coordinates {
+			tree.AddPoint(&coordinates[i], 1, 2, 3, 4, 5, 4326, nil)
+		}
+		var err error
+		if again, err = tree.EndPass(); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := tree.Err(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// the spool files replaced by folders cannot be read back
+	files, _ := filepath.Glob(filepath.Join(spoolFolder, "*", "*.points"))
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if err := os.Mkdir(file, 0777); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	if len(files) == 0 {
+		t.Fatalf("Expected spool files to be written")
+	}
+	if points := tree.GetRootNode().GetPoints(); points != nil {
+		t.Errorf("Expected no points read from the broken spool file, got %d", len(points))
+	}
+	if err := tree.Err(); err == nil {
+		t.Errorf("Expected the spool read error to be recorded")
+	}
+	if err := tree.Close(); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+}
+
+func TestSpooledGridTreeWithoutPointsNeedsOnePass(t *testing.T) {
+	tree := grid_tree.NewSpooledGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5,
+		CellMinSize:        0.5,
+		RootGeometricError: 1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{}).(octree.MultiPassTree)
+	if again, err := tree.EndPass(); again || err != nil {
+		t.Fatalf("Expected no further pass, got %v, %v", again, err)
+	}
+	if err := tree.Build(); err != nil || tree.GetRootNode() != nil {
+		t.Errorf("Expected an empty tree, got %v", err)
+	}
+	if err := tree.Close(); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+}
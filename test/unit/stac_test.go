@@ -0,0 +1,75 @@
+package unit
+
+import (
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/stac"
+	"testing"
+	"time"
+)
+
+func TestStacItemDescribesTileset(t *testing.T) {
+	datetime := time.Date(2020, time.March, 4, 0, 0, 0, 0, time.UTC)
+	item := stac.NewItem("cloud", [6]float64{13, 42, 14, 43, 10, 50}, datetime, 1234)
+	item.AddAsset("coverage", stac.Asset{Href: "coverage.geojson", Roles: []string{"metadata"}})
+
+	content, err := item.ToJson()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var result map[string]interface{}
+	_ = json.Unmarshal(content, &result)
+
+	if result["type"] != "Feature" || result["id"] != "cloud" || result["stac_version"] != "1.0.0" {
+		t.Errorf("unexpected item header %v", result)
+	}
+	bbox := result["bbox"].([]interface{})
+	expectedBbox := []float64{13, 42, 10, 14, 43, 50}
+	for i, value := range expectedBbox {
+		if bbox[i] != value {
+			t.Errorf("expected bbox %v, got %v", expectedBbox, bbox)
+			break
+		}
+	}
+	properties := result["properties"].(map[string]interface{})
+	if properties["datetime"] != "2020-03-04T00:00:00Z" || properties["pointcloud:count"] != 1234.0 {
+		t.Errorf("unexpected item properties %v", properties)
+	}
+	assets := result["assets"].(map[string]interface{})
+	if assets["tileset"].(map[string]interface{})["href"] != "tileset.json" || assets["coverage"] == nil {
+		t.Errorf("expected tileset and coverage assets, got %v", assets)
+	}
+}
+
+func TestStacCollectionSpansItems(t *testing.T) {
+	first := stac.NewItem("a", [6]float64{13, 42, 14, 43, 0, 1}, time.Date(2020, time.March, 4, 0, 0, 0, 0, time.UTC), 1)
+	second := stac.NewItem("b", [6]float64{12, 42.5, 13.5, 44, 0, 1}, time.Date(2021, time.May, 1, 0, 0, 0, 0, time.UTC), 1)
+	first.SetCollection("survey")
+	second.SetCollection("survey")
+
+	collection := stac.NewCollection("survey", "test", []*stac.Item{first, second})
+
+	expectedBbox := []float64{12, 42, 14, 44}
+	for i, value := range expectedBbox {
+		if collection.Extent.Spatial.Bbox[0][i] != value {
+			t.Errorf("expected collection bbox %v, got %v", expectedBbox, collection.Extent.Spatial.Bbox[0])
+			break
+		}
+	}
+	interval := collection.Extent.Temporal.Interval[0]
+	if *interval[0] != "2020-03-04T00:00:00Z" || *interval[1] != "2021-05-01T00:00:00Z" {
+		t.Errorf("unexpected temporal interval %s - %s", *interval[0], *interval[1])
+	}
+
+	items := 0
+	for _, link := range collection.Links {
+		if link.Rel == "item" {
+			items++
+		}
+	}
+	if items != 2 {
+		t.Errorf("expected 2 item links, got %d", items)
+	}
+	if first.Collection != "survey" {
+		t.Errorf("expected the item to reference its collection, got %s", first.Collection)
+	}
+}
@@ -0,0 +1,141 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/stats"
+	"io/ioutil"
+	"math"
+	"path"
+	"testing"
+)
+
+// mock tree returning a fixed root node
+type mockBuiltTree struct {
+	mockTree
+	root octree.INode
+}
+
+func (mockBuiltTree *mockBuiltTree) GetRootNode() octree.INode {
+	return mockBuiltTree.root
+}
+
+func TestCountPointsCountsAddedPointsAndForwardsThem(t *testing.T) {
+	fileStats := stats.NewCollector().NewFileStats("test.las")
+	tree := &mockTree{}
+	countingTree := fileStats.CountPoints(tree)
+	for i := 0; i < 3; i++ {
+		countingTree.AddPoint(&geometry.Coordinate{X: float64(i)}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if fileStats.PointsRead != 3 {
+		t.Errorf("Expected 3 points read, got %d", fileStats.PointsRead)
+	}
+	if len(tree.points) != 3 {
+		t.Errorf("Expected 3 points forwarded to the tree, got %d", len(tree.points))
+	}
+}
+
+func TestCollectTreeStatsComputesRetentionRatesPerLevel(t *testing.T) {
+	root := &mockNode{globalChildrenCount: 100, localChildrenCount: 10}
+	root.children[0] = &mockNode{parent: root, globalChildrenCount: 60, localChildrenCount: 30}
+	root.children[3] = &mockNode{parent: root, globalChildrenCount: 30, localChildrenCount: 30}
+	root.children[5] = &mockNode{parent: root}
+	fileStats := stats.NewCollector().NewFileStats("test.las")
+	fileStats.PointsRead = 120
+
+	fileStats.CollectTreeStats(&mockBuiltTree{root: root})
+
+	if fileStats.PointsKept != 100 {
+		t.Errorf("Expected 100 points kept, got %d", fileStats.PointsKept)
+	}
+	if len(fileStats.Filters) != 1 || fileStats.Filters[0].Dropped != 20 {
+		t.Errorf("Expected a tree filter dropping 20 points, got %+v", fileStats.Filters)
+	}
+	if len(fileStats.Levels) != 2 {
+		t.Fatalf("Expected 2 levels, got %d", len(fileStats.Levels))
+	}
+	assertLevelStats(t, fileStats.Levels[0], 1, 100, 10, 0.1)
+	assertLevelStats(t, fileStats.Levels[1], 2, 90, 60, 60.0/90)
+}
+
+func TestCollectTreeStatsIgnoresTreesWithoutRoot(t *testing.T) {
+	fileStats := stats.NewCollector().NewFileStats("test.las")
+	fileStats.CollectTreeStats(&mockTree{})
+
+	if len(fileStats.Levels) != 0 || len(fileStats.Filters) != 0 {
+		t.Errorf("Expected no statistics for a tree without root")
+	}
+}
+
+func TestStartPhaseRecordsPhase(t *testing.T) {
+	fileStats := stats.NewCollector().NewFileStats("test.las")
+	endPhase := fileStats.StartPhase("read")
+	endPhase()
+
+	if len(fileStats.Phases) != 1 || fileStats.Phases[0].Name != "read" || fileStats.Phases[0].Seconds < 0 {
+		t.Errorf("Expected a read phase to be recorded, got %+v", fileStats.Phases)
+	}
+}
+
+func TestWriteSummaryWritesTotalsOfAllFiles(t *testing.T) {
+	collector := stats.NewCollector()
+	first := collector.NewFileStats("first.las")
+	first.PointsRead, first.PointsKept = 10, 8
+	second := collector.NewFileStats("second.las")
+	second.PointsRead, second.PointsKept = 5, 5
+
+	filePath := path.Join(createTempFolder(t), "stats.json")
+	if err := collector.WriteSummary(filePath); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var summary stats.Summary
+	if err := json.Unmarshal(content, &summary); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if summary.TotalPointsRead != 15 || summary.TotalPointsKept != 13 {
+		t.Errorf("Expected 15 points read and 13 kept, got %d and %d", summary.TotalPointsRead, summary.TotalPointsKept)
+	}
+	if len(summary.Files) != 2 || summary.Files[1].File != "second.las" {
+		t.Errorf("Expected statistics of 2 files, got %d", len(summary.Files))
+	}
+	if summary.PeakRssBytes == 0 {
+		t.Errorf("Expected peak memory to be sampled")
+	}
+}
+
+func assertLevelStats(t *testing.T, level stats.LevelStats, nodes int, input int64, retained int64, rate float64) {
+	if level.Nodes != nodes || level.InputPoints != input || level.RetainedPoints != retained {
+		t.Errorf("Expected level with %d nodes, %d input and %d retained points, got %+v", nodes, input, retained, level)
+	}
+	if math.Abs(level.RetentionRate-rate) > 1e-9 {
+		t.Errorf("Expected retention rate %f, got %f", rate, level.RetentionRate)
+	}
+}
+
+func TestFailFileRecordsErrorOfLatestFileStats(t *testing.T) {
+	collector := stats.NewCollector()
+	collector.NewFileStats("a.las")
+	failed := collector.NewFileStats("b.las")
+	collector.FailFile("b.las", errors.New("truncated point record"))
+	collector.FailFile("c.las", errors.New("file not found"))
+
+	summary := collector.GetSummary()
+	if failed.Error != "truncated point record" {
+		t.Errorf("Expected the error of b.las to be recorded, got %q", failed.Error)
+	}
+	if len(summary.Files) != 3 || summary.Files[2].File != "c.las" || summary.Files[2].Error != "file not found" {
+		t.Errorf("Expected the stats of c.las to be registered with its error")
+	}
+	if summary.FailedFiles != 2 {
+		t.Errorf("Expected 2 failed files, got %d", summary.FailedFiles)
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/offset_elevation_corrector"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"github.com/mfbonfigli/gocesiumtiler/pkg/algorithm_manager/std_algorithm_manager"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
 	"reflect"
 	"testing"
 )
@@ -157,3 +161,86 @@ func TestAlgorithmManagerReturnsGeoidElevationCorrector(t *testing.T) {
 	}
 
 }
+
+func TestAlgorithmManagerChainsElevationPipelineSteps(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	gridFile := path.Join(folder, "fix.asc")
+	if err := ioutil.WriteFile(gridFile, []byte("ncols 1\nnrows 1\nxllcorner 0\nyllcorner 0\ncellsize 90\n0.5\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	algorithmManager := std_algorithm_manager.NewAlgorithmManager(
+		&tiler.TilerOptions{
+			Algorithm: tiler.Grid,
+			ZOffset:   100,
+			ElevationPipeline: []tiler.ElevationStep{
+				{Kind: tiler.ElevationStepOffset, Offset: 2},
+				{Kind: tiler.ElevationStepRaster, File: gridFile},
+				{Kind: tiler.ElevationStepOffset, Offset: -0.25},
+			},
+		},
+	)
+
+	elevationCorrectionType := reflect.ValueOf(algorithmManager.GetElevationCorrectionAlgorithm()).Elem()
+	correctors := elevationCorrectionType.FieldByName("Correctors").Interface().([]converters.ElevationCorrector)
+	expected := []string{"OffsetElevationCorrector", "RasterElevationCorrector", "OffsetElevationCorrector"}
+	if len(correctors) != len(expected) {
+		t.Fatalf("%d nested correction algorithms expected but %d found", len(expected), len(correctors))
+	}
+	for i, corrector := range correctors {
+		if name := reflect.ValueOf(corrector).Elem().Type().Name(); name != expected[i] {
+			t.Errorf("Wrong elevation corrector at index %d, %s expected, but %s was returned", i, expected[i], name)
+		}
+	}
+
+	// the pipeline replaces the zoffset
+	if actual := algorithmManager.GetElevationCorrectionAlgorithm().CorrectElevation(45, 45, 10); actual != 12.25 {
+		t.Errorf("Expected Elevation = 12.25, got %f", actual)
+	}
+}
+
+func TestAlgorithmManagerReturnsTwoPassTree(t *testing.T) {
+	expected := "TwoPassTree"
+	algorithmManager := std_algorithm_manager.NewAlgorithmManager(
+		&tiler.TilerOptions{
+			Algorithm: tiler.TwoPass,
+		},
+	)
+
+	treeType := reflect.ValueOf(algorithmManager.GetTreeAlgorithm()).Elem().Type().Name()
+	if treeType != expected {
+		t.Errorf("Wrong tree algorithm returned, %s expected, but %s was returned", expected, treeType)
+	}
+}
+
+func TestAlgorithmManagerGeoidCorrectionDoesNotDependOnInputSrid(t *testing.T) {
+	geographic := std_algorithm_manager.NewAlgorithmManager(&tiler.TilerOptions{Algorithm: tiler.Grid, Srid: 4326, EnableGeoidZCorrection: true})
+	projected := std_algorithm_manager.NewAlgorithmManager(&tiler.TilerOptions{Algorithm: tiler.Grid, Srid: 32633, EnableGeoidZCorrection: true})
+
+	// the corrected coordinates are in EPSG:4326 whatever the input srid
+	expected := geographic.GetElevationCorrectionAlgorithm().CorrectElevation(15, 41.55, 0)
+	if actual := projected.GetElevationCorrectionAlgorithm().CorrectElevation(15, 41.55, 0); math.Abs(actual-expected) > 1e-9 {
+		t.Errorf("Expected Elevation = %f, got %f", expected, actual)
+	}
+}
+
+func TestAlgorithmManagerInterpolatesGeoidGridModel(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	gridFile := path.Join(folder, "geoid.gtx")
+	writeGtxTestFile(t, gridFile, 41, 14, 1, 1, 2, 2, []float32{40, 42, 44, 46})
+
+	algorithmManager := std_algorithm_manager.NewAlgorithmManager(
+		&tiler.TilerOptions{Algorithm: tiler.Grid, Srid: 32633, EnableGeoidZCorrection: true, GeoidModel: gridFile},
+	)
+	corrector := algorithmManager.GetElevationCorrectionAlgorithm()
+
+	// the grid models are interpolated at every point
+	if actual := corrector.CorrectElevation(14.5, 41.5, 10); math.Abs(actual-53) > 1e-9 {
+		t.Errorf("Expected Elevation = 53, got %f", actual)
+	}
+	if actual := corrector.CorrectElevation(14, 42, 10); math.Abs(actual-54) > 1e-9 {
+		t.Errorf("Expected Elevation = 54, got %f", actual)
+	}
+}
@@ -3,17 +3,23 @@ package unit
 import (
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/io"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
 	"github.com/mfbonfigli/gocesiumtiler/tools"
 	"io/ioutil"
 	"math"
+	"math/rand"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -30,7 +36,8 @@ func TestConsumerSinglePointNoChildrenEPSG4326(t *testing.T) {
 		globalChildrenCount: 2,
 		localChildrenCount:  1,
 		opts: &tiler.TilerOptions{
-			Srid: 4326,
+			Srid:         4326,
+			LegacyTables: true,
 		},
 	}
 
@@ -54,7 +61,7 @@ func TestConsumerSinglePointNoChildrenEPSG4326(t *testing.T) {
 	waitGroup.Add(1)
 
 	// start consumer
-	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage())
 	go consumer.Consume(workChannel, errorChannel, &waitGroup)
 
 	// inject work unit in channel
@@ -153,8 +160,8 @@ func TestConsumerSinglePointNoChildrenEPSG4326(t *testing.T) {
 
 	_, err = pntsFile.Read(buffer)
 	var length = binary.LittleEndian.Uint32(buffer)
-	if length != 175 {
-		t.Errorf("Expected len value: %d, got: %d", 175, length)
+	if length != 341 {
+		t.Errorf("Expected len value: %d, got: %d", 341, length)
 	}
 
 	_, err = pntsFile.Read(buffer)
@@ -266,7 +273,8 @@ func TestConsumerSinglePointNoChildrenEPSG32633(t *testing.T) {
 		globalChildrenCount: 2,
 		localChildrenCount:  1,
 		opts: &tiler.TilerOptions{
-			Srid: 32633,
+			Srid:         32633,
+			LegacyTables: true,
 		},
 	}
 
@@ -290,7 +298,7 @@ func TestConsumerSinglePointNoChildrenEPSG32633(t *testing.T) {
 	waitGroup.Add(1)
 
 	// start consumer
-	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage())
 	go consumer.Consume(workChannel, errorChannel, &waitGroup)
 
 	// inject work unit in channel
@@ -389,8 +397,8 @@ func TestConsumerSinglePointNoChildrenEPSG32633(t *testing.T) {
 
 	_, err = pntsFile.Read(buffer)
 	var length = binary.LittleEndian.Uint32(buffer)
-	if length != 175 {
-		t.Errorf("Expected len value: %d, got: %d", 175, length)
+	if length != 341 {
+		t.Errorf("Expected len value: %d, got: %d", 341, length)
 	}
 
 	_, err = pntsFile.Read(buffer)
@@ -502,7 +510,8 @@ func TestConsumerOneChild(t *testing.T) {
 		internalSrid:        4326,
 		localChildrenCount:  1,
 		opts: &tiler.TilerOptions{
-			Srid: 4326,
+			Srid:         4326,
+			LegacyTables: true,
 		},
 		children: [8]octree.INode{
 			&mockNode{
@@ -515,7 +524,8 @@ func TestConsumerOneChild(t *testing.T) {
 				globalChildrenCount: 1,
 				localChildrenCount:  1,
 				opts: &tiler.TilerOptions{
-					Srid: 4326,
+					Srid:         4326,
+					LegacyTables: true,
 				},
 			},
 		},
@@ -541,7 +551,7 @@ func TestConsumerOneChild(t *testing.T) {
 	waitGroup.Add(1)
 
 	// start consumer
-	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage())
 	go consumer.Consume(workChannel, errorChannel, &waitGroup)
 
 	// inject work unit in channel
@@ -667,8 +677,8 @@ func TestConsumerOneChild(t *testing.T) {
 
 	_, err = pntsFile.Read(buffer)
 	var length = binary.LittleEndian.Uint32(buffer)
-	if length != 175 {
-		t.Errorf("Expected len value: %d, got: %d", 175, length)
+	if length != 341 {
+		t.Errorf("Expected len value: %d, got: %d", 341, length)
 	}
 
 	_, err = pntsFile.Read(buffer)
@@ -780,7 +790,8 @@ func TestConsumerOneChildRefineModeReplace(t *testing.T) {
 		internalSrid:        4326,
 		localChildrenCount:  1,
 		opts: &tiler.TilerOptions{
-			Srid: 4326,
+			Srid:         4326,
+			LegacyTables: true,
 		},
 		children: [8]octree.INode{},
 	}
@@ -796,7 +807,8 @@ func TestConsumerOneChildRefineModeReplace(t *testing.T) {
 			globalChildrenCount: 1,
 			localChildrenCount:  1,
 			opts: &tiler.TilerOptions{
-				Srid: 4326,
+				Srid:         4326,
+				LegacyTables: true,
 			},
 		}
 
@@ -825,7 +837,7 @@ func TestConsumerOneChildRefineModeReplace(t *testing.T) {
 	waitGroup.Add(1)
 
 	// start consumer
-	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeReplace)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeReplace, storage.NewOsStorage())
 	go consumer.Consume(workChannel, errorChannel, &waitGroup)
 
 	// inject work unit in channel
@@ -952,8 +964,8 @@ func TestConsumerOneChildRefineModeReplace(t *testing.T) {
 
 	_, err = pntsFile.Read(buffer)
 	var length = binary.LittleEndian.Uint32(buffer)
-	if length != 175 {
-		t.Errorf("Expected len value: %d, got: %d", 175, length)
+	if length != 341 {
+		t.Errorf("Expected len value: %d, got: %d", 341, length)
 	}
 
 	_, err = pntsFile.Read(buffer)
@@ -1078,8 +1090,8 @@ func TestConsumerOneChildRefineModeReplace(t *testing.T) {
 
 	_, err = pntsFile2.Read(buffer)
 	length = binary.LittleEndian.Uint32(buffer)
-	if length != 190 {
-		t.Errorf("Expected len value: %d, got: %d", 190, length)
+	if length != 358 {
+		t.Errorf("Expected len value: %d, got: %d", 358, length)
 	}
 
 	_, err = pntsFile2.Read(buffer)
@@ -1234,3 +1246,1245 @@ func TestConsumerOneChildRefineModeReplace(t *testing.T) {
 		t.Errorf("Expected classification: %d, got: %d", 5, classification)
 	}
 }
+
+// runs a consumer over the given work units and fails the test if errors are raised
+func consumeWorkUnits(t *testing.T, refineMode tiler.RefineMode, workUnits ...*io.WorkUnit) {
+	workChannel := make(chan *io.WorkUnit, len(workUnits))
+	errorChannel := make(chan error)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), refineMode, storage.NewOsStorage())
+	go consumer.Consume(workChannel, errorChannel, &waitGroup)
+	for _, workUnit := range workUnits {
+		workChannel <- workUnit
+	}
+	close(workChannel)
+
+	go func() {
+		waitGroup.Wait()
+		close(errorChannel)
+	}()
+
+	for err := range errorChannel {
+		t.Errorf("Unexpected error found in error channel: %s", err.Error())
+	}
+}
+
+func TestConsumerLocalFrameWritesRootTransform(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		opts: &tiler.TilerOptions{
+			Srid:            4326,
+			CoordinateFrame: tiler.CoordinateFrameLocal,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	byteValue, err := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	if err != nil {
+		t.Fatalf("Error reading tileset.json: %s", err.Error())
+	}
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+
+	if len(result.Root.Transform) != 16 {
+		t.Fatalf("Expected root transform with %d elements, got %d", 16, len(result.Root.Transform))
+	}
+	if math.Abs(result.Root.Transform[12]-4586041.913203) > 1e-3 ||
+		math.Abs(result.Root.Transform[13]-1126398.746416) > 1e-3 ||
+		math.Abs(result.Root.Transform[14]-4272825.037997) > 1e-3 {
+		t.Errorf("Unexpected root transform translation %v", result.Root.Transform[12:15])
+	}
+
+	pnts, err := ioutil.ReadFile(path.Join(tempdir, "content.pnts"))
+	if err != nil {
+		t.Fatalf("Error reading content.pnts: %s", err.Error())
+	}
+	featureTableLength := binary.LittleEndian.Uint32(pnts[12:16])
+	var featureTable struct {
+		RtcCenter []float64 `json:"RTC_CENTER"`
+	}
+	_ = json.Unmarshal(pnts[28:28+featureTableLength], &featureTable)
+
+	if math.Abs(featureTable.RtcCenter[0]) > 1e-3 || math.Abs(featureTable.RtcCenter[1]) > 1e-3 || math.Abs(featureTable.RtcCenter[2]-1) > 1e-3 {
+		t.Errorf("Expected RTC_CENTER close to (0,0,1) in local frame, got %v", featureTable.RtcCenter)
+	}
+}
+
+func TestConsumerEcefFrameWritesNoTransform(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		opts: &tiler.TilerOptions{
+			Srid:            4326,
+			CoordinateFrame: tiler.CoordinateFrameEcef,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+
+	if result.Root.Transform != nil {
+		t.Errorf("Expected no root transform in ECEF frame, got %v", result.Root.Transform)
+	}
+}
+
+func TestConsumerCustomContentExtension(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.6, 13.7995147, 42.3, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 2,
+		localChildrenCount:  1,
+		opts: &tiler.TilerOptions{
+			Srid:             4326,
+			ContentExtension: "bin",
+		},
+	}
+	node.children[0] = &mockNode{
+		parent:              node,
+		boundingBox:         geometry.NewBoundingBox(13.6, 13.7, 42.3, 42.31, 0, 1),
+		points:              []*data.Point{data.NewPoint(13.6, 42.3, 1, 7, 8, 9, 10, 11)},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		leaf:                true,
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd,
+		&io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir},
+		&io.WorkUnit{Node: node.children[0], Opts: node.opts, BasePath: path.Join(tempdir, "0")},
+	)
+
+	for _, file := range []string{path.Join(tempdir, "content.bin"), path.Join(tempdir, "0", "content.bin")} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("Expected content file %s to exist", file)
+		}
+	}
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+
+	if result.Root.Content.Url != "content.bin" {
+		t.Errorf("Expected root content uri %s, got %s", "content.bin", result.Root.Content.Url)
+	}
+	if result.Root.Children[0].Content.Url != "0/content.bin" {
+		t.Errorf("Expected child content uri %s, got %s", "0/content.bin", result.Root.Children[0].Content.Url)
+	}
+	if result.Root.Content.Extras != nil {
+		t.Errorf("Expected no content extras, got %v", result.Root.Content.Extras)
+	}
+}
+
+func TestConsumerExtensionlessContent(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		opts: &tiler.TilerOptions{
+			Srid:                 4326,
+			ExtensionlessContent: true,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	if _, err := os.Stat(path.Join(tempdir, "content")); err != nil {
+		t.Errorf("Expected extensionless content file to exist")
+	}
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+
+	if result.Root.Content.Url != "content" {
+		t.Errorf("Expected root content uri %s, got %s", "content", result.Root.Content.Url)
+	}
+	if result.Root.Content.Extras["contentType"] != "application/octet-stream" {
+		t.Errorf("Expected content type %s, got %s", "application/octet-stream", result.Root.Content.Extras["contentType"])
+	}
+}
+
+func TestConsumerWritesTerrainOffsetInRootAsset(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		opts: &tiler.TilerOptions{
+			Srid:          4326,
+			TerrainFile:   "dem.asc",
+			TerrainOffset: -12.5,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+
+	if offset, ok := result.Asset.Extras["terrainOffset"]; !ok || offset != -12.5 {
+		t.Errorf("Expected terrainOffset -12.5 in asset extras, got %v", result.Asset.Extras)
+	}
+}
+
+func TestConsumerWritesTileMetadata(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995148, 42.3306312, 42.3306313, 1, 5),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 6),
+			data.NewPoint(13.7995147, 42.3306312, 3, 1, 2, 3, 4, 2),
+			data.NewPoint(13.7995148, 42.3306313, 5, 1, 2, 3, 4, 2),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 3,
+		localChildrenCount:  3,
+		opts: &tiler.TilerOptions{
+			Srid:         4326,
+			TileMetadata: true,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+
+	if result.Asset.Version != "1.1" {
+		t.Errorf("Expected asset version 1.1, got %s", result.Asset.Version)
+	}
+	if result.Schema == nil || len(result.Schema.Classes["tileStats"].Properties) != 6 {
+		t.Fatalf("Expected the tileStats class to be declared in the schema, got %v", result.Schema)
+	}
+	metadata := result.Root.Metadata
+	if metadata == nil || metadata.Class != "tileStats" {
+		t.Fatalf("Expected root tile metadata of class tileStats, got %v", metadata)
+	}
+
+	expected := map[string]interface{}{
+		"pointCount":    3.0,
+		"minElevation":  1.0,
+		"maxElevation":  5.0,
+		"meanElevation": 3.0,
+	}
+	for name, value := range expected {
+		if metadata.Properties[name] != value {
+			t.Errorf("Expected %s = %v, got %v", name, value, metadata.Properties[name])
+		}
+	}
+	classes := metadata.Properties["classifications"].([]interface{})
+	counts := metadata.Properties["classificationCounts"].([]interface{})
+	if len(classes) != 2 || classes[0] != 2.0 || classes[1] != 6.0 || counts[0] != 2.0 || counts[1] != 1.0 {
+		t.Errorf("Expected classes [2 6] with counts [2 1], got %v %v", classes, counts)
+	}
+}
+
+func TestConsumerWritesNoTileMetadataByDefault(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		opts:                &tiler.TilerOptions{Srid: 4326},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+
+	if result.Asset.Version != "1.0" || result.Schema != nil || result.Root.Metadata != nil {
+		t.Errorf("Expected a 1.0 tileset without metadata, got version %s schema %v metadata %v", result.Asset.Version, result.Schema, result.Root.Metadata)
+	}
+}
+
+func TestConsumerWritesThumbnail(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995148, 42.3306312, 42.3306313, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		opts:                &tiler.TilerOptions{Srid: 4326, ThumbnailSize: 16},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	thumbnailPath := path.Join(tempdir, "thumbnails")
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir, ThumbnailPath: thumbnailPath})
+
+	pngData, err := ioutil.ReadFile(path.Join(thumbnailPath, "thumbnail.png"))
+	if err != nil {
+		t.Fatalf("Error reading thumbnail.png: %s", err.Error())
+	}
+	if len(pngData) < 4 || string(pngData[1:4]) != "PNG" {
+		t.Errorf("Expected a PNG thumbnail")
+	}
+}
+
+func TestDeduplicatingConsumerSharesIdenticalContents(t *testing.T) {
+	opts := &tiler.TilerOptions{Srid: 4326}
+	root := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(13.7995147, 13.7995149, 42.3306312, 42.3306314, 0, 2),
+		points:              []*data.Point{data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)},
+		internalSrid:        4326,
+		globalChildrenCount: 3,
+		localChildrenCount:  1,
+		opts:                opts,
+	}
+	for i := 0; i < 2; i++ {
+		root.children[i] = &mockNode{
+			parent:              root,
+			boundingBox:         geometry.NewBoundingBox(13.7995148, 13.7995149, 42.3306313, 42.3306314, 1, 2),
+			points:              []*data.Point{data.NewPoint(13.7995148, 42.3306313, 2, 6, 7, 8, 9, 10)},
+			internalSrid:        4326,
+			globalChildrenCount: 1,
+			localChildrenCount:  1,
+			opts:                opts,
+			leaf:                true,
+			initialized:         true,
+		}
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	contentIndex := io.NewContentIndex(true, nil)
+	consumer := io.NewIndexedConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage(), contentIndex)
+	workChannel := make(chan *io.WorkUnit, 3)
+	errorChannel := make(chan error, 1)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	workChannel <- &io.WorkUnit{Node: root, Opts: opts, BasePath: tempdir}
+	workChannel <- &io.WorkUnit{Node: root.children[0], Opts: opts, BasePath: path.Join(tempdir, "0")}
+	workChannel <- &io.WorkUnit{Node: root.children[1], Opts: opts, BasePath: path.Join(tempdir, "1")}
+	close(workChannel)
+	consumer.Consume(workChannel, errorChannel, &waitGroup)
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Fatalf("Unexpected error found in error channel: %s", err.Error())
+	}
+	if err := consumer.WriteDeferredTilesets(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if contentIndex.Duplicates() != 1 {
+		t.Errorf("Expected 1 duplicate content, got %d", contentIndex.Duplicates())
+	}
+	if _, err := os.Stat(path.Join(tempdir, "1", "content.pnts")); !os.IsNotExist(err) {
+		t.Errorf("Expected the duplicate content not to be written")
+	}
+
+	byteValue, err := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	if err != nil {
+		t.Fatalf("Error reading tileset.json: %s", err.Error())
+	}
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+	if len(result.Root.Children) != 2 {
+		t.Fatalf("Expected 2 children, got %d", len(result.Root.Children))
+	}
+	if result.Root.Content.Url != "content.pnts" || result.Root.Children[0].Content.Url != "0/content.pnts" {
+		t.Errorf("Expected unique contents to keep their uris, got %s and %s", result.Root.Content.Url, result.Root.Children[0].Content.Url)
+	}
+	if result.Root.Children[1].Content.Url != "0/content.pnts" {
+		t.Errorf("Expected the duplicate tile to point to 0/content.pnts, got %s", result.Root.Children[1].Content.Url)
+	}
+}
+
+func TestIndexedConsumerNamesContentsAfterUriTemplate(t *testing.T) {
+	opts := &tiler.TilerOptions{Srid: 4326}
+	root := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(13.7995147, 13.7995149, 42.3306312, 42.3306314, 0, 2),
+		points:              []*data.Point{data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)},
+		internalSrid:        4326,
+		globalChildrenCount: 3,
+		localChildrenCount:  1,
+		opts:                opts,
+	}
+	for i, octant := range []int{3, 5} {
+		root.children[octant] = &mockNode{
+			parent:              root,
+			boundingBox:         geometry.NewBoundingBox(13.7995148, 13.7995149, 42.3306313, 42.3306314, 1, 2),
+			points:              []*data.Point{data.NewPoint(13.7995148, 42.3306313, float64(2+i), 6, 7, 8, 9, 10)},
+			internalSrid:        4326,
+			globalChildrenCount: 1,
+			localChildrenCount:  1,
+			opts:                opts,
+			leaf:                true,
+			initialized:         true,
+		}
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	template, err := io.ParseUriTemplate("{level}/{x}/{y}/{z}.pnts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	contentIndex := io.NewContentIndex(false, template)
+	consumer := io.NewIndexedConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage(), contentIndex)
+	workChannel := make(chan *io.WorkUnit, 3)
+	errorChannel := make(chan error, 1)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	workChannel <- &io.WorkUnit{Node: root, Opts: opts, BasePath: tempdir, RootPath: tempdir}
+	workChannel <- &io.WorkUnit{Node: root.children[3], Opts: opts, BasePath: path.Join(tempdir, "3"), RootPath: tempdir}
+	workChannel <- &io.WorkUnit{Node: root.children[5], Opts: opts, BasePath: path.Join(tempdir, "5"), RootPath: tempdir}
+	close(workChannel)
+	consumer.Consume(workChannel, errorChannel, &waitGroup)
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Fatalf("Unexpected error found in error channel: %s", err.Error())
+	}
+	if err := consumer.WriteDeferredTilesets(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	for _, expected := range []string{"0/0/0/0.pnts", "1/1/1/0.pnts", "1/1/0/1.pnts"} {
+		if _, err := os.Stat(path.Join(tempdir, expected)); err != nil {
+			t.Errorf("Expected content %s to be written", expected)
+		}
+		if !contentIndex.IsContent(path.Join(tempdir, expected)) {
+			t.Errorf("Expected %s to be indexed as a tile content", expected)
+		}
+	}
+	if _, err := os.Stat(path.Join(tempdir, "content.pnts")); !os.IsNotExist(err) {
+		t.Errorf("Expected no content to be written at the default path")
+	}
+
+	byteValue, err := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	if err != nil {
+		t.Fatalf("Error reading tileset.json: %s", err.Error())
+	}
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+	if len(result.Root.Children) != 2 {
+		t.Fatalf("Expected 2 children, got %d", len(result.Root.Children))
+	}
+	if result.Root.Content.Url != "0/0/0/0.pnts" {
+		t.Errorf("Expected root content uri 0/0/0/0.pnts, got %s", result.Root.Content.Url)
+	}
+	if result.Root.Children[0].Content.Url != "1/1/1/0.pnts" || result.Root.Children[1].Content.Url != "1/1/0/1.pnts" {
+		t.Errorf("Expected children content uris 1/1/1/0.pnts and 1/1/0/1.pnts, got %s and %s", result.Root.Children[0].Content.Url, result.Root.Children[1].Content.Url)
+	}
+}
+
+func TestParseUriTemplateRejectsAmbiguousTemplates(t *testing.T) {
+	for _, template := range []string{"", "/{hash}.pnts", "../{hash}.pnts", "{level}/{x}/{y}.pnts", "{name}/{hash}.pnts", "tiles\\{hash}.pnts"} {
+		if _, err := io.ParseUriTemplate(template); err == nil {
+			t.Errorf("Expected an error parsing uri template %q", template)
+		}
+	}
+	for _, template := range []string{"{hash}.pnts", "tiles/{path}/tile.pnts", "{level}-{x}-{y}-{z}.pnts"} {
+		if _, err := io.ParseUriTemplate(template); err != nil {
+			t.Errorf("Unexpected error parsing uri template %q: %s", template, err.Error())
+		}
+	}
+}
+
+func TestConsumerReferencesContentsWithAbsoluteUrlsUnderBaseUrl(t *testing.T) {
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	opts := &tiler.TilerOptions{Srid: 4326, Output: tempdir, ContentBaseUrl: "https://cdn.example.com/datasets/abc"}
+	root := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(13.7995147, 13.7995149, 42.3306312, 42.3306314, 0, 2),
+		points:              []*data.Point{data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)},
+		internalSrid:        4326,
+		globalChildrenCount: 3,
+		localChildrenCount:  1,
+		opts:                opts,
+	}
+	for i := 0; i < 2; i++ {
+		root.children[i] = &mockNode{
+			parent:              root,
+			boundingBox:         geometry.NewBoundingBox(13.7995148, 13.7995149, 42.3306313, 42.3306314, 1, 2),
+			points:              []*data.Point{data.NewPoint(13.7995148, 42.3306313, 2, 6, 7, 8, 9, 10)},
+			internalSrid:        4326,
+			globalChildrenCount: 1,
+			localChildrenCount:  1,
+			opts:                opts,
+			leaf:                i == 0,
+			initialized:         true,
+		}
+	}
+
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage())
+	workChannel := make(chan *io.WorkUnit, 1)
+	errorChannel := make(chan error, 1)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	workChannel <- &io.WorkUnit{Node: root, Opts: opts, BasePath: path.Join(tempdir, "cloud"), RootPath: path.Join(tempdir, "cloud")}
+	close(workChannel)
+	consumer.Consume(workChannel, errorChannel, &waitGroup)
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Fatalf("Unexpected error found in error channel: %s", err.Error())
+	}
+
+	byteValue, err := ioutil.ReadFile(path.Join(tempdir, "cloud", "tileset.json"))
+	if err != nil {
+		t.Fatalf("Error reading tileset.json: %s", err.Error())
+	}
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+	if len(result.Root.Children) != 2 {
+		t.Fatalf("Expected 2 children, got %d", len(result.Root.Children))
+	}
+	if result.Root.Content.Url != "https://cdn.example.com/datasets/abc/cloud/content.pnts" {
+		t.Errorf("Expected absolute root content url, got %s", result.Root.Content.Url)
+	}
+	if result.Root.Children[0].Content.Url != "https://cdn.example.com/datasets/abc/cloud/0/content.pnts" {
+		t.Errorf("Expected absolute child content url, got %s", result.Root.Children[0].Content.Url)
+	}
+	if result.Root.Children[1].Content.Url != "1/tileset.json" {
+		t.Errorf("Expected nested tileset to keep its relative uri, got %s", result.Root.Children[1].Content.Url)
+	}
+}
+
+func TestShardedContentIndexLimitsDirectoryEntries(t *testing.T) {
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	template, err := io.ParseUriTemplate("{hash}.pnts")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	contentIndex := io.NewShardedContentIndex(false, template, io.MinDirectoryEntries)
+	consumer := io.NewIndexedConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage(), contentIndex)
+
+	// enough tiles to fill the directly held entries, the first shard folder and part of the nested one
+	numTiles := 1200
+	opts := &tiler.TilerOptions{Srid: 4326}
+	root := &mockNode{internalSrid: 4326, opts: opts}
+	workChannel := make(chan *io.WorkUnit, numTiles)
+	for i := 0; i < numTiles; i++ {
+		node := &mockNode{
+			parent:              root,
+			boundingBox:         geometry.NewBoundingBox(13.7995148, 13.7995149, 42.3306313, 42.3306314, 1, 2),
+			points:              []*data.Point{data.NewPoint(13.7995148, 42.3306313, float64(i), 6, 7, 8, 9, 10)},
+			internalSrid:        4326,
+			globalChildrenCount: 1,
+			localChildrenCount:  1,
+			opts:                opts,
+			leaf:                true,
+			initialized:         true,
+		}
+		basePath := path.Join(tempdir, strconv.Itoa(i%8), strconv.Itoa(i))
+		workChannel <- &io.WorkUnit{Node: node, Opts: opts, BasePath: basePath, RootPath: tempdir}
+	}
+	close(workChannel)
+	errorChannel := make(chan error, 1)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	consumer.Consume(workChannel, errorChannel, &waitGroup)
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Fatalf("Unexpected error found in error channel: %s", err.Error())
+	}
+
+	contents := 0
+	err = filepath.Walk(tempdir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if !contentIndex.IsContent(filePath) {
+				t.Errorf("Expected %s to be indexed as a tile content", filePath)
+			}
+			contents++
+			return nil
+		}
+		entries, err := ioutil.ReadDir(filePath)
+		if err != nil {
+			return err
+		}
+		if len(entries) > io.MinDirectoryEntries {
+			t.Errorf("Expected at most %d entries in %s, got %d", io.MinDirectoryEntries, filePath, len(entries))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if contents != numTiles {
+		t.Errorf("Expected %d tile contents, got %d", numTiles, contents)
+	}
+	if _, err := os.Stat(path.Join(tempdir, "_shard", "_shard")); err != nil {
+		t.Errorf("Expected the contents to be spread in nested shard folders")
+	}
+}
+
+func TestConsumerWritesAlphaFromIntensity(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995148, 42.3306312, 42.3306313, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 0, 5),
+			data.NewPoint(13.7995148, 42.3306313, 1, 4, 5, 6, 255, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 2,
+		localChildrenCount:  2,
+		opts: &tiler.TilerOptions{
+			Srid:     4326,
+			Alpha:    tiler.AlphaIntensity,
+			AlphaMin: 0.2,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	pnts, err := ioutil.ReadFile(path.Join(tempdir, "content.pnts"))
+	if err != nil {
+		t.Fatalf("Error reading content.pnts: %s", err.Error())
+	}
+	featureTableLength := binary.LittleEndian.Uint32(pnts[12:16])
+	var featureTable struct {
+		Rgb  *struct{} `json:"RGB"`
+		Rgba *struct {
+			ByteOffset int `json:"byteOffset"`
+		} `json:"RGBA"`
+	}
+	_ = json.Unmarshal(pnts[28:28+featureTableLength], &featureTable)
+	if featureTable.Rgb != nil || featureTable.Rgba == nil {
+		t.Fatalf("Expected the colors written as RGBA only")
+	}
+
+	colors := pnts[28+int(featureTableLength)+featureTable.Rgba.ByteOffset:]
+	expected := []byte{1, 2, 3, 51, 4, 5, 6, 255}
+	for i, value := range expected {
+		if colors[i] != value {
+			t.Errorf("Expected RGBA colors %v, got %v", expected, colors[:len(expected)])
+			break
+		}
+	}
+}
+
+func TestConsumerWritesAlignedJsonBatchTable(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995148, 42.3306312, 42.3306313, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+			data.NewPoint(13.7995148, 42.3306313, 1, 4, 5, 6, 7, 8),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 2,
+		localChildrenCount:  2,
+		opts: &tiler.TilerOptions{
+			Srid:       4326,
+			BatchTable: tiler.BatchTableJson,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	pnts, err := ioutil.ReadFile(path.Join(tempdir, "content.pnts"))
+	if err != nil {
+		t.Fatalf("Error reading content.pnts: %s", err.Error())
+	}
+	var lengths [5]int
+	for i := range lengths {
+		lengths[i] = int(binary.LittleEndian.Uint32(pnts[8+i*4:]))
+	}
+	if lengths[0] != len(pnts) {
+		t.Errorf("Expected byte length %d, got %d", len(pnts), lengths[0])
+	}
+	offset := 28
+	for i, length := range lengths[1:] {
+		offset += length
+		if offset%8 != 0 {
+			t.Errorf("Expected section %d to end on an 8-byte boundary, ends at %d", i, offset)
+		}
+	}
+	if lengths[4] != 0 {
+		t.Errorf("Expected no batch table binary body, got %d bytes", lengths[4])
+	}
+
+	batchTableStart := 28 + lengths[1] + lengths[2]
+	var batchTable struct {
+		Intensity      []int `json:"INTENSITY"`
+		Classification []int `json:"CLASSIFICATION"`
+	}
+	if err := json.Unmarshal(pnts[batchTableStart:batchTableStart+lengths[3]], &batchTable); err != nil {
+		t.Fatalf("Unexpected error parsing the batch table: %s", err.Error())
+	}
+	if len(batchTable.Intensity) != 2 || batchTable.Intensity[0]+batchTable.Intensity[1] != 11 ||
+		len(batchTable.Classification) != 2 || batchTable.Classification[0]+batchTable.Classification[1] != 13 {
+		t.Errorf("Unexpected batch table properties %+v", batchTable)
+	}
+}
+
+func TestConsumerOmitsInvalidColors(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995148, 42.3306312, 42.3306313, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 0, 0, 0, 4, 5),
+			data.NewPoint(13.7995148, 42.3306313, 1, 0, 0, 0, 7, 8),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 2,
+		localChildrenCount:  2,
+		opts: &tiler.TilerOptions{
+			Srid:          4326,
+			InvalidColors: tiler.InvalidColorsOmit,
+			ColorsInvalid: true,
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	pnts, err := ioutil.ReadFile(path.Join(tempdir, "content.pnts"))
+	if err != nil {
+		t.Fatalf("Error reading content.pnts: %s", err.Error())
+	}
+	featureTableLength := binary.LittleEndian.Uint32(pnts[12:16])
+	var featureTable map[string]json.RawMessage
+	if err := json.Unmarshal(pnts[28:28+featureTableLength], &featureTable); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if _, ok := featureTable["RGB"]; ok {
+		t.Errorf("Expected the colors to be omitted")
+	}
+	if featureTableBinaryLength := binary.LittleEndian.Uint32(pnts[16:20]); featureTableBinaryLength != 24 {
+		t.Errorf("Expected a feature table body holding the positions only, got %d bytes", featureTableBinaryLength)
+	}
+}
+
+func TestConsumerWritesSidecarAttributesInBatchTable(t *testing.T) {
+	first := data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)
+	first.Attributes = []float32{12, 0.5}
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995148, 42.3306312, 42.3306313, 0, 1),
+		points: []*data.Point{
+			first,
+			data.NewPoint(13.7995148, 42.3306313, 1, 4, 5, 6, 7, 8),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 2,
+		localChildrenCount:  2,
+		opts: &tiler.TilerOptions{
+			Srid:           4326,
+			AttributeNames: []string{"segment", "score"},
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	pnts, err := ioutil.ReadFile(path.Join(tempdir, "content.pnts"))
+	if err != nil {
+		t.Fatalf("Error reading content.pnts: %s", err.Error())
+	}
+	var lengths [4]int
+	for i := range lengths {
+		lengths[i] = int(binary.LittleEndian.Uint32(pnts[12+i*4:]))
+	}
+	batchTableStart := 28 + lengths[0] + lengths[1]
+	var batchTable map[string]struct {
+		ByteOffset    int    `json:"byteOffset"`
+		ComponentType string `json:"componentType"`
+	}
+	if err := json.Unmarshal(pnts[batchTableStart:batchTableStart+lengths[2]], &batchTable); err != nil {
+		t.Fatalf("Unexpected error parsing the batch table: %s", err.Error())
+	}
+	segment, score := batchTable["segment"], batchTable["score"]
+	if segment.ComponentType != "FLOAT" || segment.ByteOffset != 4 || score.ByteOffset != 12 {
+		t.Fatalf("Unexpected attribute properties %v", batchTable)
+	}
+
+	body := pnts[batchTableStart+lengths[2]:]
+	// the 20 bytes of the body are padded to the next 8-byte boundary
+	if len(body) != lengths[3] || lengths[3] != 24 {
+		t.Fatalf("Expected a batch table body of 24 bytes, got %d", lengths[3])
+	}
+	values := make([]float32, 4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(body[4+i*4:]))
+	}
+	if values[0] != 12 || values[1] != 0 || values[2] != 0.5 || values[3] != 0 {
+		t.Errorf("Expected the attributes [12 0 0.5 0], got %v", values)
+	}
+}
+
+func TestConsumerWritesLicenseInRootAsset(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 1,
+		localChildrenCount:  1,
+		opts: &tiler.TilerOptions{
+			Srid:       4326,
+			License:    "CC-BY-4.0",
+			LicenseUrl: "https://creativecommons.org/licenses/by/4.0/",
+		},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+
+	license, ok := result.Asset.Extras["license"].(map[string]interface{})
+	if !ok || license["name"] != "CC-BY-4.0" || license["url"] != "https://creativecommons.org/licenses/by/4.0/" {
+		t.Errorf("Expected the license in asset extras, got %v", result.Asset.Extras)
+	}
+}
+
+func TestConsumerWritesGlbContentsForTilesetVersion11(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+			data.NewPoint(13.7995148, 42.3306313, 2, 6, 7, 8, 9, 10),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 2,
+		localChildrenCount:  2,
+		opts:                &tiler.TilerOptions{Srid: 4326, TilesetVersion: tiler.TilesetVersion11},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+	if result.Asset.Version != "1.1" || result.Root.Content.Url != "content.glb" {
+		t.Errorf("Expected a 1.1 tileset referencing content.glb, got version %s and content %s", result.Asset.Version, result.Root.Content.Url)
+	}
+
+	content, err := ioutil.ReadFile(path.Join(tempdir, "content.glb"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(content) < 20 || string(content[0:4]) != "glTF" || binary.LittleEndian.Uint32(content[4:8]) != 2 ||
+		int(binary.LittleEndian.Uint32(content[8:12])) != len(content) || len(content)%4 != 0 {
+		t.Fatalf("Expected a glb 2 container of %d bytes", len(content))
+	}
+	var document struct {
+		ExtensionsUsed []string `json:"extensionsUsed"`
+		Meshes         []struct {
+			Primitives []struct {
+				Attributes map[string]int `json:"attributes"`
+				Mode       int            `json:"mode"`
+			} `json:"primitives"`
+		} `json:"meshes"`
+		Accessors []struct {
+			Count int `json:"count"`
+		} `json:"accessors"`
+	}
+	if err := json.Unmarshal(content[20:20+binary.LittleEndian.Uint32(content[12:16])], &document); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(document.Meshes) != 1 || len(document.Meshes[0].Primitives) != 1 {
+		t.Fatalf("Expected a single primitive, got %v", document.Meshes)
+	}
+	primitive := document.Meshes[0].Primitives[0]
+	if primitive.Mode != 0 || document.Accessors[primitive.Attributes["POSITION"]].Count != 2 {
+		t.Errorf("Expected a point primitive of 2 points, got %v", primitive)
+	}
+	for _, attribute := range []string{"COLOR_0", "_FEATURE_ID_0"} {
+		if _, ok := primitive.Attributes[attribute]; !ok {
+			t.Errorf("Expected the %s attribute, got %v", attribute, primitive.Attributes)
+		}
+	}
+	if len(document.ExtensionsUsed) != 2 || document.ExtensionsUsed[0] != "EXT_mesh_features" || document.ExtensionsUsed[1] != "EXT_structural_metadata" {
+		t.Errorf("Expected the mesh features and structural metadata extensions, got %v", document.ExtensionsUsed)
+	}
+}
+
+func TestConsumerWritesDracoCompressedPnts(t *testing.T) {
+	node := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+			data.NewPoint(13.7995148, 42.3306313, 2, 6, 7, 8, 9, 10),
+		},
+		internalSrid:        4326,
+		globalChildrenCount: 2,
+		localChildrenCount:  2,
+		opts:                &tiler.TilerOptions{Srid: 4326, DracoCompression: true, DracoQuantization: map[string]int{"POSITION": 16}},
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: node.opts, BasePath: tempdir})
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+	if len(result.ExtensionsUsed) != 1 || result.ExtensionsUsed[0] != "3DTILES_draco_point_compression" ||
+		len(result.ExtensionsRequired) != 1 || result.ExtensionsRequired[0] != "3DTILES_draco_point_compression" {
+		t.Errorf("Expected the draco extension to be used and required, got %v and %v", result.ExtensionsUsed, result.ExtensionsRequired)
+	}
+
+	content, err := ioutil.ReadFile(path.Join(tempdir, "content.pnts"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	featureTableLength := binary.LittleEndian.Uint32(content[12:16])
+	featureTableBinaryLength := binary.LittleEndian.Uint32(content[16:20])
+	batchTableLength := binary.LittleEndian.Uint32(content[20:24])
+	if featureTableLength%4 != 0 || batchTableLength%4 != 0 || binary.LittleEndian.Uint32(content[24:28]) != 0 {
+		t.Errorf("Expected padded JSON headers and no batch table binary body")
+	}
+	var featureTable struct {
+		PointsLength int `json:"POINTS_LENGTH"`
+		Extensions   map[string]struct {
+			Properties map[string]int `json:"properties"`
+			ByteLength uint32         `json:"byteLength"`
+		} `json:"extensions"`
+	}
+	if err := json.Unmarshal(content[28:28+featureTableLength], &featureTable); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	extension, ok := featureTable.Extensions["3DTILES_draco_point_compression"]
+	if featureTable.PointsLength != 2 || !ok || extension.ByteLength > featureTableBinaryLength || featureTableBinaryLength-extension.ByteLength >= 8 {
+		t.Fatalf("Expected 2 draco compressed points filling the feature table binary body, got %v", featureTable)
+	}
+	if _, ok := extension.Properties["POSITION"]; !ok {
+		t.Errorf("Expected the positions to be compressed, got %v", extension.Properties)
+	}
+	if _, ok := extension.Properties["RGB"]; !ok {
+		t.Errorf("Expected the colors to be compressed, got %v", extension.Properties)
+	}
+	compressed := content[28+featureTableLength : 28+featureTableLength+featureTableBinaryLength]
+	if string(compressed[0:5]) != "DRACO" {
+		t.Errorf("Expected a draco bitstream in the feature table binary body")
+	}
+
+	var batchTable struct {
+		Extensions map[string]struct {
+			Properties map[string]int `json:"properties"`
+		} `json:"extensions"`
+	}
+	batchTableOffset := 28 + featureTableLength + featureTableBinaryLength
+	if err := json.Unmarshal(content[batchTableOffset:batchTableOffset+batchTableLength], &batchTable); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	properties := batchTable.Extensions["3DTILES_draco_point_compression"].Properties
+	if _, ok := properties["INTENSITY"]; !ok {
+		t.Errorf("Expected the intensities to be compressed, got %v", properties)
+	}
+	if _, ok := properties["CLASSIFICATION"]; !ok {
+		t.Errorf("Expected the classifications to be compressed, got %v", properties)
+	}
+}
+
+func TestConsumerWritesImplicitTileset(t *testing.T) {
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	writeImplicitTileset(t, tempdir, &tiler.TilerOptions{Srid: 4326, ImplicitTiling: true, SubtreeLevels: 2})
+
+	byteValue, _ := ioutil.ReadFile(path.Join(tempdir, "tileset.json"))
+	var result io.Tileset
+	_ = json.Unmarshal(byteValue, &result)
+	implicit := result.Root.ImplicitTiling
+	if result.Asset.Version != "1.1" || implicit == nil || len(result.Root.Children) != 0 {
+		t.Fatalf("Expected a 3D Tiles 1.1 tileset with an implicit root and no children, got %s", string(byteValue))
+	}
+	if implicit.SubdivisionScheme != "OCTREE" || implicit.SubtreeLevels != 2 || implicit.AvailableLevels != 3 ||
+		implicit.Subtrees.Url != "subtrees/{level}/{x}/{y}/{z}.subtree" || result.Root.Content.Url != "content/{level}/{x}/{y}/{z}/content.pnts" {
+		t.Errorf("Unexpected implicit tiling %v with content uri %s", *implicit, result.Root.Content.Url)
+	}
+	for _, tile := range []string{"0/0/0/0", "1/0/0/0", "1/1/0/0", "2/2/0/1"} {
+		if _, err := os.Stat(path.Join(tempdir, "content", tile, "content.pnts")); err != nil {
+			t.Errorf("Expected the content of the tile %s: %s", tile, err.Error())
+		}
+	}
+	if _, err := os.Stat(path.Join(tempdir, "1", "tileset.json")); err == nil {
+		t.Errorf("Expected no nested tileset.json files")
+	}
+
+	content, err := ioutil.ReadFile(path.Join(tempdir, "subtrees", "0", "0", "0", "0.subtree"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	jsonLength := binary.LittleEndian.Uint64(content[8:16])
+	binaryLength := binary.LittleEndian.Uint64(content[16:24])
+	if string(content[0:4]) != "subt" || jsonLength%8 != 0 || binaryLength%8 != 0 || uint64(len(content)) != 24+jsonLength+binaryLength {
+		t.Fatalf("Expected a subtree file with 8 bytes aligned chunks")
+	}
+	var subtree struct {
+		BufferViews      []struct{ ByteOffset int }
+		TileAvailability struct {
+			Bitstream *int
+			Constant  *int
+		}
+		ChildSubtreeAvailability struct {
+			Bitstream *int
+			Constant  *int
+		}
+	}
+	_ = json.Unmarshal(content[24:24+jsonLength], &subtree)
+	if subtree.TileAvailability.Bitstream == nil || subtree.ChildSubtreeAvailability.Bitstream == nil {
+		t.Fatalf("Expected bitstreams of the tiles and of the child subtrees, got %s", string(content[24:24+jsonLength]))
+	}
+	// the root and the octants 0 and 1 of the first level are available, then the subtree rooted at the tile 2/2/0/1,
+	// whose Morton index is 0b1100 at the third level
+	tiles := content[24+jsonLength+uint64(subtree.BufferViews[*subtree.TileAvailability.Bitstream].ByteOffset):]
+	if tiles[0] != 0b111 || tiles[1] != 0 {
+		t.Errorf("Expected the tiles 0, 1 and 2 to be available, got %08b %08b", tiles[0], tiles[1])
+	}
+	children := content[24+jsonLength+uint64(subtree.BufferViews[*subtree.ChildSubtreeAvailability.Bitstream].ByteOffset):]
+	if children[0] != 0 || children[1] != 0b10000 {
+		t.Errorf("Expected the child subtree 12 to be available, got %08b %08b", children[0], children[1])
+	}
+}
+
+// Checks the layout of the pnts and glb contents written for random combinations of the number of points, of the
+// colors and of the supplementary attributes, as the padding rules of the formats depend on the sizes of their sections
+func TestConsumerContentsAreAlignedForAllAttributeCombinations(t *testing.T) {
+	random := rand.New(rand.NewSource(5))
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+
+	for i := 0; i < 60; i++ {
+		opts := &tiler.TilerOptions{
+			Srid:          4326,
+			LegacyTables:  random.Intn(2) == 0,
+			BatchTable:    []tiler.BatchTableEncoding{tiler.BatchTableBinary, tiler.BatchTableJson}[random.Intn(2)],
+			Alpha:         []tiler.AlphaSource{tiler.AlphaNone, tiler.AlphaIntensity}[random.Intn(2)],
+			InvalidColors: tiler.InvalidColorsOmit,
+			ColorsInvalid: random.Intn(3) == 0,
+		}
+		if random.Intn(2) == 0 {
+			opts.TilesetVersion = tiler.TilesetVersion11
+		}
+		for j := random.Intn(3); j > 0; j-- {
+			opts.AttributeNames = append(opts.AttributeNames, strings.Repeat("a", j+random.Intn(4)))
+		}
+		node := &mockNode{
+			boundingBox:  geometry.NewBoundingBox(13.7995147, 13.7995148, 42.3306312, 42.3306313, 0, 1),
+			internalSrid: 4326,
+			opts:         opts,
+		}
+		for j := random.Intn(20) + 1; j > 0; j-- {
+			point := data.NewPoint(13.7995147+random.Float64()*1e-7, 42.3306312+random.Float64()*1e-7, random.Float64(), 1, 2, 3, uint8(j), 5)
+			for range opts.AttributeNames {
+				point.Attributes = append(point.Attributes, random.Float32())
+			}
+			node.points = append(node.points, point)
+		}
+		node.localChildrenCount, node.globalChildrenCount = int64(len(node.points)), int64(len(node.points))
+
+		basePath := path.Join(tempdir, strconv.Itoa(i))
+		consumeWorkUnits(t, tiler.RefineModeAdd, &io.WorkUnit{Node: node, Opts: opts, BasePath: basePath})
+		if opts.TilesetVersion == tiler.TilesetVersion11 {
+			checkGlbLayout(t, path.Join(basePath, "content.glb"), opts)
+		} else {
+			checkPntsLayout(t, path.Join(basePath, "content.pnts"), opts)
+		}
+	}
+}
+
+func checkPntsLayout(t *testing.T, filePath string, opts *tiler.TilerOptions) {
+	pnts, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Error reading content.pnts: %s", err.Error())
+	}
+	var lengths [5]int
+	for i := range lengths {
+		lengths[i] = int(binary.LittleEndian.Uint32(pnts[8+i*4:]))
+	}
+	if 28+lengths[1]+lengths[2]+lengths[3]+lengths[4] != len(pnts) || lengths[0] != len(pnts) {
+		t.Fatalf("Expected sections summing up to the %d bytes of the content with %s, got %v", len(pnts), describeContentOptions(opts), lengths)
+	}
+	offset := 28
+	for i, length := range lengths[1:] {
+		offset += length
+		if !opts.LegacyTables && offset%8 != 0 {
+			t.Errorf("Expected section %d to end on an 8-byte boundary with %s, ends at %d", i, describeContentOptions(opts), offset)
+		}
+		if i%2 == 0 && length%4 != 0 {
+			t.Errorf("Expected the JSON header %d to be padded to a multiple of 4 bytes with %s, got %d bytes", i, describeContentOptions(opts), length)
+		}
+	}
+
+	batchTableStart := 28 + lengths[1] + lengths[2]
+	var batchTable map[string]json.RawMessage
+	if err := json.Unmarshal(pnts[batchTableStart:batchTableStart+lengths[3]], &batchTable); err != nil {
+		t.Fatalf("Unexpected error parsing the batch table with %s: %s", describeContentOptions(opts), err.Error())
+	}
+	for _, name := range opts.AttributeNames {
+		var property struct {
+			ByteOffset *int `json:"byteOffset"`
+		}
+		_ = json.Unmarshal(batchTable[name], &property)
+		if property.ByteOffset != nil && (*property.ByteOffset%4 != 0 || *property.ByteOffset >= lengths[4]) {
+			t.Errorf("Expected the float attribute %s at a 4-byte boundary of the body with %s, got offset %d", name, describeContentOptions(opts), *property.ByteOffset)
+		}
+	}
+}
+
+func checkGlbLayout(t *testing.T, filePath string, opts *tiler.TilerOptions) {
+	glb, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("Error reading content.glb: %s", err.Error())
+	}
+	if int(binary.LittleEndian.Uint32(glb[8:12])) != len(glb) || len(glb)%4 != 0 {
+		t.Fatalf("Expected a glb of %d bytes ending on a 4-byte boundary with %s", len(glb), describeContentOptions(opts))
+	}
+	jsonLength := int(binary.LittleEndian.Uint32(glb[12:16]))
+	binaryStart := 20 + jsonLength
+	if jsonLength%4 != 0 || binaryStart+8 > len(glb) {
+		t.Fatalf("Expected a JSON chunk ending on a 4-byte boundary followed by a binary chunk, got %d bytes", jsonLength)
+	}
+	binaryLength := int(binary.LittleEndian.Uint32(glb[binaryStart:]))
+	if binaryLength%4 != 0 || binaryStart+8+binaryLength != len(glb) {
+		t.Fatalf("Expected a binary chunk of a multiple of 4 bytes ending the content with %s, got %d bytes", describeContentOptions(opts), binaryLength)
+	}
+
+	var document struct {
+		BufferViews []struct {
+			ByteOffset int `json:"byteOffset"`
+			ByteLength int `json:"byteLength"`
+			Target     int `json:"target"`
+		} `json:"bufferViews"`
+		Buffers []struct {
+			ByteLength int `json:"byteLength"`
+		} `json:"buffers"`
+	}
+	if err := json.Unmarshal(glb[20:binaryStart], &document); err != nil {
+		t.Fatalf("Unexpected error parsing the glTF document: %s", err.Error())
+	}
+	if len(document.Buffers) != 1 || document.Buffers[0].ByteLength > binaryLength {
+		t.Fatalf("Expected a buffer held by the binary chunk of %d bytes, got %+v", binaryLength, document.Buffers)
+	}
+	for i, view := range document.BufferViews {
+		if view.ByteOffset%8 != 0 || view.ByteOffset+view.ByteLength > document.Buffers[0].ByteLength {
+			t.Errorf("Expected buffer view %d at an 8-byte boundary of the buffer with %s, got %+v", i, describeContentOptions(opts), view)
+		}
+		if view.Target != 0 && view.ByteLength%4 != 0 {
+			t.Errorf("Expected the vertex attribute buffer view %d to end on a 4-byte boundary with %s, got %+v", i, describeContentOptions(opts), view)
+		}
+	}
+}
+
+func describeContentOptions(opts *tiler.TilerOptions) string {
+	return fmt.Sprintf("version %s, legacy tables %t, %s batch table, alpha %s, invalid colors %t and attributes %v",
+		opts.TilesetVersion, opts.LegacyTables, opts.BatchTable, opts.Alpha, opts.ColorsInvalid, opts.AttributeNames)
+}
+
+func TestConsumerRefineModeReplaceDoesNotAppendToTheNodePoints(t *testing.T) {
+	opts := &tiler.TilerOptions{Srid: 4326}
+	node := &mockNode{
+		boundingBox:  geometry.NewBoundingBox(13.6, 13.7995147, 42.3, 42.3306312, 0, 1),
+		points:       []*data.Point{data.NewPoint(13.7, 42.31, 0.75, 1, 2, 3, 4, 5)},
+		internalSrid: 4326,
+		opts:         opts,
+	}
+	// the spare capacity of the points of the child would receive the parent points if they were appended in place
+	childPoints := make([]*data.Point, 1, 4)
+	childPoints[0] = data.NewPoint(13.6, 42.3, 1, 7, 8, 9, 10, 11)
+	node.children[0] = &mockNode{
+		parent:       node,
+		boundingBox:  geometry.NewBoundingBox(13.6, 13.7995147, 42.3, 42.3306312, 0.5, 1),
+		points:       childPoints,
+		internalSrid: 4326,
+		leaf:         true,
+		opts:         opts,
+	}
+
+	tempdir, _ := ioutil.TempDir(tools.GetRootFolder(), "temp*")
+	defer func() { _ = os.RemoveAll(tempdir) }()
+	workChannel := make(chan *io.WorkUnit, 1)
+	errorChannel := make(chan error, 2)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeReplace, storage.NewOsStorage())
+	go consumer.Consume(workChannel, errorChannel, &waitGroup)
+	workChannel <- &io.WorkUnit{Node: node.children[0], Opts: opts, BasePath: path.Join(tempdir, "0")}
+	close(workChannel)
+	waitGroup.Wait()
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Errorf("Unexpected error found in error channel: %s", err.Error())
+	}
+
+	if spare := childPoints[:cap(childPoints)]; spare[1] != nil {
+		t.Errorf("Expected the parent points not to be written in the backing array of the node points")
+	}
+	if content, err := ioutil.ReadFile(path.Join(tempdir, "0", "content.pnts")); err != nil || binary.LittleEndian.Uint32(content[8:12]) == 0 {
+		t.Errorf("Expected the content of the child to be written, got %v", err)
+	}
+}
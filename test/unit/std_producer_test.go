@@ -1,11 +1,15 @@
 package unit
 
 import (
+	"encoding/json"
 	"github.com/mfbonfigli/gocesiumtiler/internal/data"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
 	"github.com/mfbonfigli/gocesiumtiler/internal/io"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
 	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"os"
 	"path"
 	"sync"
 	"testing"
@@ -81,3 +85,111 @@ func TestProducerInjectsWorkUnits(t *testing.T) {
 	}
 
 }
+
+func TestProducerMirrorsTilesetsInThumbnailsFolder(t *testing.T) {
+	var opts = tiler.TilerOptions{
+		Srid:          4326,
+		ThumbnailSize: 64,
+	}
+
+	rootNode := &mockNode{
+		boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+		points: []*data.Point{
+			data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5),
+		},
+		globalChildrenCount: 2,
+		localChildrenCount:  1,
+		initialized:         true,
+		opts:                &opts,
+		children: [8]octree.INode{
+			nil,
+			&mockNode{
+				boundingBox: geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0.5, 1),
+				points: []*data.Point{
+					data.NewPoint(13.7995147, 42.3306312, 1, 4, 5, 6, 4, 5),
+				},
+				globalChildrenCount: 1,
+				localChildrenCount:  1,
+				initialized:         true,
+				opts:                &opts,
+			},
+		},
+	}
+
+	workChannel := make(chan *io.WorkUnit, 3)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	producer := io.NewStandardProducer("basepath", "cloud", &opts)
+	producer.Produce(workChannel, &waitGroup, rootNode)
+	waitGroup.Wait()
+
+	rootWorkUnit := <-workChannel
+	if rootWorkUnit.ThumbnailPath != path.Join("basepath", "thumbnails", "cloud") {
+		t.Errorf("Expected root thumbnail path %s, got %s", path.Join("basepath", "thumbnails", "cloud"), rootWorkUnit.ThumbnailPath)
+	}
+	childWorkUnit := <-workChannel
+	if childWorkUnit.ThumbnailPath != path.Join("basepath", "thumbnails", "cloud", "1") {
+		t.Errorf("Expected child thumbnail path %s, got %s", path.Join("basepath", "thumbnails", "cloud", "1"), childWorkUnit.ThumbnailPath)
+	}
+}
+
+func TestCoarseFirstProducerSubmitsLevelsOnceWritten(t *testing.T) {
+	opts := tiler.TilerOptions{Srid: 4326, CoarseFirst: true}
+	newNode := func(children [8]octree.INode) *mockNode {
+		return &mockNode{
+			boundingBox:        geometry.NewBoundingBox(13.7995147, 13.7995147, 42.3306312, 42.3306312, 0, 1),
+			points:             []*data.Point{data.NewPoint(13.7995147, 42.3306312, 1, 1, 2, 3, 4, 5)},
+			localChildrenCount: 1,
+			initialized:        true,
+			opts:               &opts,
+			children:           children,
+		}
+	}
+	deep := newNode([8]octree.INode{})
+	first := newNode([8]octree.INode{2: deep})
+	second := newNode([8]octree.INode{})
+	root := newNode([8]octree.INode{0: first, 3: second})
+
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	manifest := io.NewWriteManifest(storage.NewOsStorage(), folder)
+	workChannel := make(chan *io.WorkUnit, 10)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	go io.NewCoarseFirstProducer(folder, "", &opts, manifest).Produce(workChannel, &waitGroup, root)
+
+	var order []string
+	for workUnit := range workChannel {
+		order = append(order, workUnit.BasePath)
+		if len(workChannel) > 0 && workUnit.Node == root {
+			t.Errorf("Expected the children to wait for the root to be written")
+		}
+		workUnit.Written.Done()
+	}
+	waitGroup.Wait()
+
+	expected := []string{folder, path.Join(folder, "0"), path.Join(folder, "3"), path.Join(folder, "0", "2")}
+	if len(order) != len(expected) {
+		t.Fatalf("Expected %d work units, got %v", len(expected), order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("Expected work unit %d to be %s, got %s", i, expected[i], order[i])
+		}
+	}
+
+	if err := manifest.MarkComplete(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	content, err := ioutil.ReadFile(path.Join(folder, io.WriteManifestFileName))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var written io.WriteManifest
+	if err := json.Unmarshal(content, &written); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if written.WrittenLevels != 3 || written.WrittenTiles != 4 || !written.Complete || written.Tileset != "tileset.json" {
+		t.Errorf("Expected a complete manifest of 3 levels and 4 tiles, got %d levels and %d tiles", written.WrittenLevels, written.WrittenTiles)
+	}
+}
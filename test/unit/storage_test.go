@@ -0,0 +1,87 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"io"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+func TestFaultyStorageFailsOperationsAtFullErrorRate(t *testing.T) {
+	faultyStorage := storage.NewFaultyStorage(storage.NewOsStorage(), storage.FaultConfig{ErrorRate: 1})
+	folder := createTempFolder(t)
+
+	if _, err := faultyStorage.Open(path.Join(folder, "file")); err != storage.ErrInjectedFault {
+		t.Errorf("Expected injected fault on open, got %v", err)
+	}
+	if err := faultyStorage.WriteFile(path.Join(folder, "file"), []byte("data"), 0666); err != storage.ErrInjectedFault {
+		t.Errorf("Expected injected fault on write, got %v", err)
+	}
+	if err := faultyStorage.MkdirAll(path.Join(folder, "sub"), 0777); err != storage.ErrInjectedFault {
+		t.Errorf("Expected injected fault on mkdir, got %v", err)
+	}
+}
+
+func TestFaultyStorageWithoutFaultsBehavesAsWrappedStorage(t *testing.T) {
+	faultyStorage := storage.NewFaultyStorage(storage.NewOsStorage(), storage.FaultConfig{})
+	folder := path.Join(createTempFolder(t), "sub")
+	filePath := path.Join(folder, "file")
+
+	if err := faultyStorage.MkdirAll(folder, 0777); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := faultyStorage.WriteFile(filePath, []byte("data"), 0666); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	file, err := faultyStorage.Open(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	defer func() { _ = file.Close() }()
+
+	content, err := ioutil.ReadAll(file)
+	if err != nil || string(content) != "data" {
+		t.Errorf("Expected to read 'data', got '%s' (%v)", string(content), err)
+	}
+}
+
+func TestFaultyStorageShortReadsAreReported(t *testing.T) {
+	filePath := path.Join(createTempFolder(t), "file")
+	if err := ioutil.WriteFile(filePath, []byte("0123456789"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	faultyStorage := storage.NewFaultyStorage(storage.NewOsStorage(), storage.FaultConfig{ShortReadRate: 1})
+	file, err := faultyStorage.Open(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	defer func() { _ = file.Close() }()
+
+	buffer := make([]byte, 10)
+	n, err := file.ReadAt(buffer, 0)
+	if n >= 10 || err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected a short ReadAt with unexpected EOF, got %d bytes and %v", n, err)
+	}
+
+	// short reads are transparent to callers reading until EOF
+	content, err := ioutil.ReadAll(file)
+	if err != nil || string(content) != "0123456789" {
+		t.Errorf("Expected to read the whole file, got '%s' (%v)", string(content), err)
+	}
+}
+
+func TestFaultyStorageFaultsAreReproducibleWithSameSeed(t *testing.T) {
+	folder := createTempFolder(t)
+	config := storage.FaultConfig{ErrorRate: 0.5, Seed: 42}
+	first := storage.NewFaultyStorage(storage.NewOsStorage(), config)
+	second := storage.NewFaultyStorage(storage.NewOsStorage(), config)
+
+	for i := 0; i < 20; i++ {
+		firstErr := first.MkdirAll(folder, 0777)
+		secondErr := second.MkdirAll(folder, 0777)
+		if firstErr != secondErr {
+			t.Fatalf("Expected the same faults with the same seed at operation %d", i)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package unit
+
+import (
+	"bytes"
+	"github.com/mfbonfigli/gocesiumtiler/internal/preflight"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers/las_reader"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/synthetic"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSyntheticDatasetIsReadable(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	filePath := path.Join(folder, "synthetic.las")
+	dataset := synthetic.Dataset{Srid: 32633, OriginX: 500000, OriginY: 4600000, Extent: 60, Density: 2, Buildings: 3, Noise: 0.01, Seed: 1}
+	count, err := dataset.WriteLas(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	tree := &mockTree{}
+	if err := las_reader.NewLasReader(nil, storage.NewOsStorage(), 1, 0, nil, nil, nil, nil).Read(filePath, 32633, tree); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if int64(len(tree.points)) != count {
+		t.Fatalf("Expected %d points to be read, got %d", count, len(tree.points))
+	}
+	if count < 60*60*2 {
+		t.Errorf("Expected at least the %d terrain and roof points, got %d", 60*60*2, count)
+	}
+	classes := map[uint8]int{}
+	for _, point := range tree.points {
+		classes[point.Classification]++
+		if point.X < 500000-30 || point.X > 500000+30 || point.Y < 4600000-30 || point.Y > 4600000+30 {
+			t.Fatalf("Expected the points to lie within the extent, got %f %f", point.X, point.Y)
+		}
+	}
+	for _, class := range []uint8{2, 6, 7} {
+		if classes[class] == 0 {
+			t.Errorf("Expected points of class %d", class)
+		}
+	}
+
+	info, err := preflight.ReadLasFileInfo(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if info.NumberOfPoints != int(count) || info.MinX < 500000-30 || info.MaxX > 500000+30 {
+		t.Errorf("Expected the header to declare the points and their bounds, got %+v", info)
+	}
+}
+
+func TestSyntheticDatasetRejectsPointCountsOverflowingTheHeader(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	filePath := path.Join(folder, "synthetic.las")
+	// 5 billion points do not fit the 32 bit point counts of the LAS 1.2 header
+	dataset := synthetic.Dataset{Srid: 32633, OriginX: 500000, OriginY: 4600000, Extent: 50000, Density: 2, Seed: 1}
+	if _, err := dataset.WriteLas(filePath); err == nil {
+		t.Errorf("Expected an error for a dataset overflowing the point counts of the header")
+	}
+	if _, err := os.Stat(filePath); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be written")
+	}
+}
+
+func TestSyntheticDatasetIsReproducible(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	write := func(name string, seed int64) []byte {
+		dataset := synthetic.Dataset{Srid: 4326, OriginX: 12.5, OriginY: 41.9, Extent: 40, Density: 1, Buildings: 2, Noise: 0.01, Seed: seed}
+		if _, err := dataset.WriteLas(path.Join(folder, name)); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		content, err := ioutil.ReadFile(path.Join(folder, name))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		return content
+	}
+
+	first, second, other := write("first.las", 7), write("second.las", 7), write("other.las", 8)
+	if !bytes.Equal(first, second) {
+		t.Errorf("Expected the same seed to produce the same file")
+	}
+	if bytes.Equal(first, other) {
+		t.Errorf("Expected different seeds to produce different files")
+	}
+}
@@ -0,0 +1,126 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/elevation/offset_elevation_corrector"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/terrain"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+const testAsciiGrid = `ncols 3
+nrows 2
+xllcorner 10
+yllcorner 40
+cellsize 1
+NODATA_value -9999
+100 110 -9999
+120 130 140
+`
+
+func TestAsciiGridHeights(t *testing.T) {
+	dem := loadTestDem(t, testAsciiGrid)
+
+	expectations := []struct {
+		x, y   float64
+		height float64
+		known  bool
+	}{
+		{10.5, 41.5, 100, true},
+		{11.5, 41.5, 110, true},
+		{12.5, 41.5, 0, false},
+		{10.5, 40.5, 120, true},
+		{12.9, 40.1, 140, true},
+		{9.5, 40.5, 0, false},
+		{10.5, 42.5, 0, false},
+	}
+	for _, e := range expectations {
+		height, known := dem.HeightAt(e.x, e.y)
+		if known != e.known || height != e.height {
+			t.Errorf("Expected height %f (known %t) at (%f, %f), got %f (known %t)", e.height, e.known, e.x, e.y, height, known)
+		}
+	}
+}
+
+func TestAsciiGridCellCenterOrigin(t *testing.T) {
+	dem := loadTestDem(t, "ncols 1\nnrows 1\nxllcenter 10.5\nyllcenter 40.5\ncellsize 1\n50\n")
+
+	if height, known := dem.HeightAt(10.1, 40.9); !known || height != 50 {
+		t.Errorf("Expected height 50, got %f (known %t)", height, known)
+	}
+}
+
+func TestAsciiGridRejectsWrongNumberOfHeights(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	demFile := path.Join(folder, "dem.asc")
+	_ = ioutil.WriteFile(demFile, []byte("ncols 2\nnrows 2\nxllcorner 0\nyllcorner 0\ncellsize 1\n1 2 3\n"), 0666)
+
+	if _, err := terrain.LoadAsciiGrid(demFile); err == nil {
+		t.Errorf("Expected an error for a grid with missing heights")
+	}
+}
+
+func TestOffsetSamplerUsesLowestPointOfEachCell(t *testing.T) {
+	dem := loadTestDem(t, testAsciiGrid)
+	sampler := terrain.NewOffsetSampler(dem, 4326, proj4_coordinate_converter.NewProj4CoordinateConverter(), offset_elevation_corrector.NewOffsetElevationCorrector(0))
+
+	// lowest points are 10m below the terrain, the tree over the second cell is ignored
+	sampler.AddPoint(&geometry.Coordinate{X: 10.5, Y: 41.5, Z: 90}, 0, 0, 0, 0, 0, 4326, nil)
+	sampler.AddPoint(&geometry.Coordinate{X: 10.6, Y: 41.6, Z: 95}, 0, 0, 0, 0, 0, 4326, nil)
+	sampler.AddPoint(&geometry.Coordinate{X: 11.5, Y: 41.5, Z: 100}, 0, 0, 0, 0, 0, 4326, nil)
+	sampler.AddPoint(&geometry.Coordinate{X: 11.5, Y: 41.5, Z: 120}, 0, 0, 0, 0, 0, 4326, nil)
+	sampler.AddPoint(&geometry.Coordinate{X: 10.5, Y: 40.5, Z: 112}, 0, 0, 0, 0, 0, 4326, nil)
+	// outside of the grid
+	sampler.AddPoint(&geometry.Coordinate{X: 20, Y: 20, Z: 0}, 0, 0, 0, 0, 0, 4326, nil)
+
+	offset, err := sampler.ComputeOffset()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if offset != 10 {
+		t.Errorf("Expected offset 10, got %f", offset)
+	}
+}
+
+func TestOffsetSamplerFailsWithoutOverlap(t *testing.T) {
+	dem := loadTestDem(t, testAsciiGrid)
+	sampler := terrain.NewOffsetSampler(dem, 4326, proj4_coordinate_converter.NewProj4CoordinateConverter(), offset_elevation_corrector.NewOffsetElevationCorrector(0))
+	sampler.AddPoint(&geometry.Coordinate{X: 20, Y: 20, Z: 0}, 0, 0, 0, 0, 0, 4326, nil)
+
+	if _, err := sampler.ComputeOffset(); err == nil {
+		t.Errorf("Expected an error when the point cloud does not overlap the terrain")
+	}
+}
+
+func TestOffsetTreeShiftsPoints(t *testing.T) {
+	tree := &mockTree{}
+	terrain.NewOffsetTree(tree, -5).AddPoint(&geometry.Coordinate{X: 1, Y: 2, Z: 3}, 1, 2, 3, 4, 5, 4326, nil)
+
+	if len(tree.points) != 1 {
+		t.Fatalf("Expected 1 point, got %d", len(tree.points))
+	}
+	point := tree.points[0]
+	if point.X != 1 || point.Y != 2 || point.Z != -2 {
+		t.Errorf("Expected point (1, 2, -2), got (%f, %f, %f)", point.X, point.Y, point.Z)
+	}
+}
+
+func loadTestDem(t *testing.T, content string) *terrain.Dem {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	demFile := path.Join(folder, "dem.asc")
+	if err := ioutil.WriteFile(demFile, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+	dem, err := terrain.LoadAsciiGrid(demFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return dem
+}
@@ -0,0 +1,324 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters/coordinate/proj4_coordinate_converter"
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"math"
+	"os"
+	"path"
+	"sync"
+	"testing"
+)
+
+// Writes with the standard consumer a tileset made of a root, a leaf in octant 0 and an inner node in octant 1,
+// declared by a nested tileset, with a leaf in octant 4, returning the written nodes
+func writeMountableTileset(t *testing.T, folder string, opts *tiler.TilerOptions) []*mockNode {
+	nodes := newMountableNodes(opts)
+	root, leaf, inner, nested := nodes[0], nodes[1], nodes[2], nodes[3]
+
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage())
+	workChannel := make(chan *io.WorkUnit, 4)
+	errorChannel := make(chan error, 1)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(1)
+	workChannel <- &io.WorkUnit{Node: root, Opts: opts, BasePath: folder}
+	workChannel <- &io.WorkUnit{Node: leaf, Opts: opts, BasePath: path.Join(folder, "0")}
+	workChannel <- &io.WorkUnit{Node: inner, Opts: opts, BasePath: path.Join(folder, "1")}
+	workChannel <- &io.WorkUnit{Node: nested, Opts: opts, BasePath: path.Join(folder, "1", "4")}
+	close(workChannel)
+	consumer.Consume(workChannel, errorChannel, &waitGroup)
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Fatalf("Unexpected error found in error channel: %s", err.Error())
+	}
+	return nodes
+}
+
+// Writes with the implicit producer and the standard consumer the tree of newMountableNodes as an implicit tileset,
+// followed by its subtree files, returning the written nodes
+func writeImplicitTileset(t *testing.T, folder string, opts *tiler.TilerOptions) []*mockNode {
+	nodes := newMountableNodes(opts)
+	consumer := io.NewStandardConsumer(proj4_coordinate_converter.NewProj4CoordinateConverter(), tiler.RefineModeAdd, storage.NewOsStorage())
+	workChannel := make(chan *io.WorkUnit, 4)
+	errorChannel := make(chan error, 1)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(2)
+	io.NewImplicitProducer(folder, "", opts).Produce(workChannel, &waitGroup, nodes[0])
+	consumer.Consume(workChannel, errorChannel, &waitGroup)
+	close(errorChannel)
+	for err := range errorChannel {
+		t.Fatalf("Unexpected error found in error channel: %s", err.Error())
+	}
+	if err := io.WriteSubtrees(storage.NewOsStorage(), folder, nodes[0], opts); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return nodes
+}
+
+// Returns a root, a leaf in octant 0 and an inner node in octant 1 with a leaf in octant 4
+func newMountableNodes(opts *tiler.TilerOptions) []*mockNode {
+	newNode := func(parent *mockNode, point *data.Point, total int64, leaf bool) *mockNode {
+		node := &mockNode{
+			boundingBox:         geometry.NewBoundingBox(point.X-0.0001, point.X+0.0001, point.Y-0.0001, point.Y+0.0001, point.Z-1, point.Z+1),
+			points:              []*data.Point{point},
+			internalSrid:        4326,
+			globalChildrenCount: total,
+			localChildrenCount:  1,
+			opts:                opts,
+			leaf:                leaf,
+			initialized:         true,
+		}
+		if parent != nil {
+			node.parent = parent
+		}
+		return node
+	}
+	root := newNode(nil, data.NewPoint(13.7995147, 42.3306312, 100, 1, 2, 3, 4, 5), 4, false)
+	leaf := newNode(root, data.NewPoint(13.7994147, 42.3305312, 101, 6, 7, 8, 9, 10), 1, true)
+	inner := newNode(root, data.NewPoint(13.7996147, 42.3305312, 102, 11, 12, 13, 14, 15), 2, false)
+	nested := newNode(inner, data.NewPoint(13.7996147, 42.3305312, 103, 16, 17, 18, 19, 20), 1, true)
+	root.children[0] = leaf
+	root.children[1] = inner
+	inner.children[4] = nested
+	return []*mockNode{root, leaf, inner, nested}
+}
+
+// Checks that the mounted node holds the point of the given written node
+func checkMountedPoint(t *testing.T, mounted octree.INode, written *mockNode) {
+	points := mounted.GetPoints()
+	if len(points) != 1 {
+		t.Fatalf("Expected 1 point, got %d", len(points))
+	}
+	expected := written.points[0]
+	if math.Abs(points[0].X-expected.X) > 1e-7 || math.Abs(points[0].Y-expected.Y) > 1e-7 || math.Abs(points[0].Z-expected.Z) > 0.01 {
+		t.Errorf("Expected point %v, got %v", *expected, *points[0])
+	}
+	if points[0].R != expected.R || points[0].G != expected.G || points[0].B != expected.B ||
+		points[0].Intensity != expected.Intensity || points[0].Classification != expected.Classification {
+		t.Errorf("Expected point attributes %v, got %v", *expected, *points[0])
+	}
+}
+
+func TestMountedTilesetTraversesNestedTilesets(t *testing.T) {
+	for _, frame := range []tiler.CoordinateFrame{tiler.CoordinateFrameEcef, tiler.CoordinateFrameLocal} {
+		folder := createTempFolder(t)
+		defer func() { _ = os.RemoveAll(folder) }()
+		written := writeMountableTileset(t, folder, &tiler.TilerOptions{Srid: 4326, CoordinateFrame: frame})
+
+		root, err := io.MountTileset(path.Join(folder, "tileset.json"), storage.NewOsStorage(), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if root.TotalNumberOfPoints() != 4 {
+			t.Errorf("Expected 4 points, got %d", root.TotalNumberOfPoints())
+		}
+		if !root.IsRoot() || root.IsLeaf() {
+			t.Errorf("Expected the mounted root to be an inner root node")
+		}
+		checkMountedPoint(t, root, written[0])
+
+		children := root.GetChildren()
+		if children[0] == nil || children[1] == nil {
+			t.Fatalf("Expected children in the octants 0 and 1")
+		}
+		if !children[0].IsLeaf() || children[1].IsLeaf() || children[1].GetParent() != octree.INode(root) {
+			t.Errorf("Expected a leaf in octant 0 and an inner node in octant 1")
+		}
+		checkMountedPoint(t, children[0], written[1])
+		checkMountedPoint(t, children[1], written[2])
+
+		nested := children[1].GetChildren()[4]
+		if nested == nil {
+			t.Fatalf("Expected the nested tileset to declare a child in octant 4")
+		}
+		checkMountedPoint(t, nested, written[3])
+
+		box := nested.GetBoundingBox()
+		point := nested.GetPoints()[0]
+		if point.X < box.Xmin || point.X > box.Xmax || point.Y < box.Ymin || point.Y > box.Ymax {
+			t.Errorf("Expected the point %v to be in the bounding box %v", *point, *box)
+		}
+		if err := root.Err(); err != nil {
+			t.Errorf("Unexpected loading error: %s", err.Error())
+		}
+	}
+}
+
+func TestMountedTilesetReportsMissingContents(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	writeMountableTileset(t, folder, &tiler.TilerOptions{Srid: 4326})
+
+	if _, err := io.MountTileset(path.Join(folder, "missing.json"), storage.NewOsStorage(), nil); err == nil {
+		t.Errorf("Expected an error mounting a missing tileset")
+	}
+
+	// contents truncated by the decoder cannot be read
+	root, err := io.MountTileset(path.Join(folder, "tileset.json"), storage.NewOsStorage(), func(content []byte) ([]byte, error) {
+		return content[:10], nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(root.GetPoints()) != 0 {
+		t.Errorf("Expected no points to be loaded from an invalid content")
+	}
+	if root.Err() == nil {
+		t.Errorf("Expected the loading error to be reported")
+	}
+}
+
+func TestMountedTilesetReadsDracoContents(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	written := writeMountableTileset(t, folder, &tiler.TilerOptions{Srid: 4326, DracoCompression: true, DracoQuantization: map[string]int{"POSITION": 14}})
+
+	root, err := io.MountTileset(path.Join(folder, "tileset.json"), storage.NewOsStorage(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	checkMountedPoint(t, root, written[0])
+	checkMountedPoint(t, root.GetChildren()[0], written[1])
+	checkMountedPoint(t, root.GetChildren()[1].GetChildren()[4], written[3])
+	if err := root.Err(); err != nil {
+		t.Errorf("Unexpected loading error: %s", err.Error())
+	}
+}
+
+func TestMountedTilesetReadsBundledContents(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	opts := &tiler.TilerOptions{Srid: 4326}
+	newLeaf := func(point *data.Point) *mockNode {
+		return &mockNode{
+			boundingBox:         geometry.NewBoundingBox(point.X-0.0001, point.X+0.0001, point.Y-0.0001, point.Y+0.0001, point.Z-1, point.Z+1),
+			points:              []*data.Point{point},
+			internalSrid:        4326,
+			globalChildrenCount: 1,
+			localChildrenCount:  1,
+			opts:                opts,
+			leaf:                true,
+			initialized:         true,
+		}
+	}
+	first := newLeaf(data.NewPoint(13.7994147, 42.3305312, 101, 6, 7, 8, 9, 10))
+	second := newLeaf(data.NewPoint(13.7996147, 42.3305312, 102, 11, 12, 13, 14, 15))
+	root := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(13.7994, 13.7997, 42.3305, 42.3307, 99, 103),
+		points:              []*data.Point{data.NewPoint(13.7995147, 42.3306312, 100, 1, 2, 3, 4, 5)},
+		internalSrid:        4326,
+		globalChildrenCount: 3,
+		localChildrenCount:  1,
+		opts:                opts,
+		initialized:         true,
+	}
+	bundle := &mockBundleNode{
+		mockNode: &mockNode{
+			parent:              root,
+			boundingBox:         geometry.NewBoundingBox(13.7993147, 13.7997147, 42.3304312, 42.3306312, 100, 103),
+			points:              append(first.GetPoints(), second.GetPoints()...),
+			internalSrid:        4326,
+			globalChildrenCount: 2,
+			localChildrenCount:  2,
+			opts:                opts,
+			leaf:                true,
+			initialized:         true,
+		},
+		nodes: []octree.INode{first, second},
+	}
+	root.children[0] = bundle
+	consumeWorkUnits(t, tiler.RefineModeAdd,
+		&io.WorkUnit{Node: root, Opts: opts, BasePath: folder},
+		&io.WorkUnit{Node: bundle, Opts: opts, BasePath: path.Join(folder, "0")},
+	)
+	if _, err := os.Stat(path.Join(folder, "0", "content.cmpt")); err != nil {
+		t.Fatalf("Expected a cmpt content: %s", err.Error())
+	}
+
+	mounted, err := io.MountTileset(path.Join(folder, "tileset.json"), storage.NewOsStorage(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	points := mounted.GetChildren()[0].GetPoints()
+	if len(points) != 2 {
+		t.Fatalf("Expected the 2 points of the bundled tiles, got %d", len(points))
+	}
+	for i, written := range []*mockNode{first, second} {
+		expected := written.points[0]
+		if math.Abs(points[i].X-expected.X) > 1e-7 || math.Abs(points[i].Y-expected.Y) > 1e-7 || points[i].R != expected.R {
+			t.Errorf("Expected point %v, got %v", *expected, *points[i])
+		}
+	}
+	if err := mounted.Err(); err != nil {
+		t.Errorf("Unexpected loading error: %s", err.Error())
+	}
+}
+
+func TestMountedTilesetReadsImplicitTilesets(t *testing.T) {
+	for _, subtreeLevels := range []int{1, 5} {
+		folder := createTempFolder(t)
+		defer func() { _ = os.RemoveAll(folder) }()
+		written := writeImplicitTileset(t, folder, &tiler.TilerOptions{Srid: 4326, ImplicitTiling: true, SubtreeLevels: subtreeLevels})
+
+		root, err := io.MountTileset(path.Join(folder, "tileset.json"), storage.NewOsStorage(), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if root.TotalNumberOfPoints() != 4 {
+			t.Errorf("Expected 4 points, got %d", root.TotalNumberOfPoints())
+		}
+		checkMountedPoint(t, root, written[0])
+		children := root.GetChildren()
+		if children[0] == nil || children[1] == nil || children[2] != nil {
+			t.Fatalf("Expected children in the octants 0 and 1 only")
+		}
+		if !children[0].IsLeaf() || children[1].IsLeaf() {
+			t.Errorf("Expected a leaf in octant 0 and an inner node in octant 1")
+		}
+		checkMountedPoint(t, children[0], written[1])
+		checkMountedPoint(t, children[1], written[2])
+		nested := children[1].GetChildren()[4]
+		if nested == nil {
+			t.Fatalf("Expected a child in the octant 4 of the inner node")
+		}
+		checkMountedPoint(t, nested, written[3])
+
+		rootRegion, _ := root.GetBoundingBoxRegion(nil)
+		region, _ := nested.GetBoundingBoxRegion(nil)
+		lonSize, heightSize := rootRegion.Ymin-rootRegion.Xmin, rootRegion.Zmax-rootRegion.Zmin
+		if math.Abs(region.Xmin-(rootRegion.Xmin+lonSize/2)) > 1e-12 || math.Abs(region.Ymin-(rootRegion.Xmin+lonSize*3/4)) > 1e-12 ||
+			math.Abs(region.Zmin-(rootRegion.Zmin+heightSize/4)) > 1e-9 || math.Abs(region.Zmax-(rootRegion.Zmin+heightSize/2)) > 1e-9 {
+			t.Errorf("Expected the region of the nested tile to be the octant 4 of the octant 1 of the root region, got %v", *region)
+		}
+		if err := root.Err(); err != nil {
+			t.Errorf("Unexpected loading error: %s", err.Error())
+		}
+	}
+}
+
+func TestMountedTilesetReadsGlbContents(t *testing.T) {
+	for _, frame := range []tiler.CoordinateFrame{tiler.CoordinateFrameEcef, tiler.CoordinateFrameLocal} {
+		folder := createTempFolder(t)
+		defer func() { _ = os.RemoveAll(folder) }()
+		written := writeMountableTileset(t, folder, &tiler.TilerOptions{Srid: 4326, CoordinateFrame: frame, TilesetVersion: tiler.TilesetVersion11})
+		if _, err := os.Stat(path.Join(folder, "0", "content.glb")); err != nil {
+			t.Fatalf("Expected a glb content: %s", err.Error())
+		}
+
+		root, err := io.MountTileset(path.Join(folder, "tileset.json"), storage.NewOsStorage(), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		checkMountedPoint(t, root, written[0])
+		checkMountedPoint(t, root.GetChildren()[0], written[1])
+		checkMountedPoint(t, root.GetChildren()[1].GetChildren()[4], written[3])
+		if err := root.Err(); err != nil {
+			t.Errorf("Unexpected loading error: %s", err.Error())
+		}
+	}
+}
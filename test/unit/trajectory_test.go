@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/trajectory"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+)
+
+func TestPoseCsvTrajectoryInterpolatesPoses(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	yaw := math.Sin(math.Pi / 4)
+	csvFile := path.Join(folder, "trajectory.csv")
+	content := "time,x,y,z,qx,qy,qz,qw\n" +
+		"# comment line\n" +
+		"3,30,0,5,0,0," + formatFloat(yaw) + "," + formatFloat(yaw) + "\n" +
+		"1,10,0,5,0,0," + formatFloat(yaw) + "," + formatFloat(yaw) + "\n"
+	if err := ioutil.WriteFile(csvFile, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	traj, err := trajectory.LoadTrajectory(csvFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if traj.Len() != 2 {
+		t.Errorf("Expected 2 poses, got %d", traj.Len())
+	}
+
+	coord, srid := traj.Transform(&geometry.Coordinate{X: 1, Y: 0, Z: 0}, 2, 32633)
+	assertCoordinate(t, coord, 20, 1, 5)
+	if srid != 32633 {
+		t.Errorf("Expected srid to be preserved, got %d", srid)
+	}
+
+	// times past the end of the trajectory are clamped to the last pose
+	coord, _ = traj.Transform(&geometry.Coordinate{X: 1, Y: 0, Z: 0}, 10, 32633)
+	assertCoordinate(t, coord, 30, 1, 5)
+}
+
+func TestPoseCsvTrajectoryRejectsInvalidRecords(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	csvFile := path.Join(folder, "trajectory.csv")
+	content := "1,10,0,5,0,0,0,1\n2,x,0,5,0,0,0,1\n"
+	if err := ioutil.WriteFile(csvFile, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := trajectory.LoadTrajectory(csvFile); err == nil {
+		t.Errorf("Expected an error for the invalid record")
+	}
+}
+
+func TestSbetTrajectoryProducesGeographicCoordinates(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+
+	sbetFile := path.Join(folder, "trajectory.out")
+	buffer := new(bytes.Buffer)
+	// a sensor at lat 0, lon 0, height 0 heading north, then at the same position heading east
+	_ = binary.Write(buffer, binary.LittleEndian, sbetRecord(100, 0))
+	_ = binary.Write(buffer, binary.LittleEndian, sbetRecord(200, math.Pi/2))
+	if err := ioutil.WriteFile(sbetFile, buffer.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	traj, err := trajectory.LoadTrajectory(sbetFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// one meter forward moves north by the meridian radius of curvature at the equator
+	coord, srid := traj.Transform(&geometry.Coordinate{X: 1, Y: 0, Z: 0}, 100, 32633)
+	assertCoordinate(t, coord, 0, 1/(6378137*(1-6.69437999014e-3))*180/math.Pi, 0)
+	if srid != 4326 {
+		t.Errorf("Expected srid 4326, got %d", srid)
+	}
+
+	// when heading east forward moves east
+	coord, _ = traj.Transform(&geometry.Coordinate{X: 1, Y: 0, Z: 0}, 200, 32633)
+	assertCoordinate(t, coord, math.Atan(1/6378137.0)*180/math.Pi, 0, 0)
+
+	// one meter down lowers the height
+	coord, _ = traj.Transform(&geometry.Coordinate{X: 0, Y: 0, Z: 1}, 100, 32633)
+	assertCoordinate(t, coord, 0, 0, -1)
+}
+
+func TestTrajectoryUnsupportedFormat(t *testing.T) {
+	if _, err := trajectory.LoadTrajectory("trajectory.kml"); err == nil {
+		t.Errorf("Expected an error for unsupported trajectory formats")
+	}
+}
+
+func sbetRecord(time float64, heading float64) [17]float64 {
+	var record [17]float64
+	record[0] = time
+	record[9] = heading
+	return record
+}
+
+func assertCoordinate(t *testing.T, coord *geometry.Coordinate, x float64, y float64, z float64) {
+	if math.Abs(coord.X-x) > 1e-9 || math.Abs(coord.Y-y) > 1e-9 || math.Abs(coord.Z-z) > 1e-6 {
+		t.Errorf("Expected coordinate (%f, %f, %f), got (%f, %f, %f)", x, y, z, coord.X, coord.Y, coord.Z)
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func createTempFolder(t testing.TB) string {
+	folder, err := ioutil.TempDir("", "trajectory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return folder
+}
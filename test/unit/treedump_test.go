@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"bytes"
+	"github.com/mfbonfigli/gocesiumtiler/internal/availability"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/grid_tree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"github.com/mfbonfigli/gocesiumtiler/internal/treedump"
+	"testing"
+)
+
+// Builds a grid tree whose root is an overflow node holding a stray point
+func buildTreeDumpTestTree(t *testing.T) octree.ITree {
+	tree := grid_tree.NewGridTree(&tiler.TilerOptions{
+		CellMaxSize:        5.0,
+		CellMinSize:        0.1,
+		RootGeometricError: 1,
+		RootPercentile:     0.1,
+	}, &mockCoordinateConverter{}, &mockElevationCorrector{})
+	for i := 0; i < 10000; i++ {
+		tree.AddPoint(&geometry.Coordinate{X: 1000 + float64(i%100), Y: 2000 + float64(i/100), Z: 10 + float64(i%7)}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+	tree.AddPoint(&geometry.Coordinate{X: 0, Y: 0, Z: 0}, 0, 0, 0, 0, 0, 4326, nil)
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error occurred while building the tree: %s", err)
+	}
+	return tree
+}
+
+func countNodes(node octree.INode) int {
+	nodes := 1
+	for _, child := range node.GetChildren() {
+		if child != nil {
+			nodes += countNodes(child)
+		}
+	}
+	return nodes
+}
+
+func TestTreeDumpCapturesTheNodesWithoutPoints(t *testing.T) {
+	root := buildTreeDumpTestTree(t).GetRootNode()
+	dump := treedump.Capture(root)
+
+	if dump.Nodes != countNodes(root) || dump.Srid != root.GetInternalSrid() {
+		t.Errorf("Expected %d nodes in srid %d, got %d in srid %d", countNodes(root), root.GetInternalSrid(), dump.Nodes, dump.Srid)
+	}
+	if dump.Root.Kind != treedump.KindOverflow || dump.Root.Points != 1 || dump.Root.TotalPoints != 10001 {
+		t.Errorf("Expected an overflow root with a point out of 10001, got %s with %d out of %d", dump.Root.Kind, dump.Root.Points, dump.Root.TotalPoints)
+	}
+	core := dump.Root.Children[0]
+	if core.Kind != "" || core.CellSize != 5 || core.Cells == 0 || int64(core.Cells) > core.Points {
+		t.Errorf("Expected a grid core node with cells of 5 m, got %s with %d cells of %f m", core.Kind, core.Cells, core.CellSize)
+	}
+	if box := root.GetBoundingBox(); dump.Root.Box != [6]float64{box.Xmin, box.Xmax, box.Ymin, box.Ymax, box.Zmin, box.Zmax} {
+		t.Errorf("Expected the bounds of the root, got %v", dump.Root.Box)
+	}
+
+	var points int64
+	for _, level := range dump.Levels() {
+		points += level.Points
+	}
+	if levels := dump.Levels(); levels[0].Nodes != 1 || levels[1].MaxCellSize != 5 || levels[2].MaxCellSize != 2.5 || points != 10001 {
+		t.Errorf("Unexpected levels %v", levels)
+	}
+}
+
+func TestTreeDumpRoundTripsThroughBothFormats(t *testing.T) {
+	root := buildTreeDumpTestTree(t).GetRootNode()
+	dump := treedump.Capture(root)
+	encoded := dump.ToBytes()
+	encodedJson, err := dump.ToJson()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	for _, data := range [][]byte{encoded, encodedJson} {
+		parsed, err := treedump.Parse(data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if !bytes.Equal(parsed.ToBytes(), encoded) {
+			t.Errorf("Expected the parsed dump to match the captured one")
+		}
+		// the reloaded nodes can be walked as the ones of the built tree
+		reloaded := parsed.Root
+		if !reloaded.IsRoot() || reloaded.GetChildren()[0].GetParent() != reloaded || reloaded.GetPoints() != nil {
+			t.Errorf("Expected the reloaded nodes to be linked to their parent without points")
+		}
+		if !bytes.Equal(availability.Compute(reloaded).ToBytes(), availability.Compute(root).ToBytes()) {
+			t.Errorf("Expected the availability of the reloaded tree to match the one of the built tree")
+		}
+	}
+
+	if _, err := treedump.Parse(encoded[:len(encoded)-1]); err == nil {
+		t.Errorf("Expected an error parsing a truncated dump")
+	}
+	invalid := []string{
+		`not a dump`,
+		`{"version":2,"srid":4326,"nodes":1,"root":{"box":[0,1,0,1,0,1]}}`,
+		`{"version":1,"srid":4326,"nodes":2,"root":{"box":[0,1,0,1,0,1]}}`,
+		`{"version":1,"srid":4326,"nodes":3,"root":{"children":[{"octant":2},{"octant":2}]}}`,
+	}
+	for _, data := range invalid {
+		if _, err := treedump.Parse([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %s", data)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"bytes"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tui"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardTrackCountsAndForwardsPoints(t *testing.T) {
+	dashboard := tui.NewDashboard(&bytes.Buffer{}, strings.NewReader(""))
+	dashboard.StartFile("test.las", 1, 1, nil)
+	tree := &mockTree{}
+	trackedTree := dashboard.Track(tree)
+	for i := 0; i < 5; i++ {
+		trackedTree.AddPoint(&geometry.Coordinate{X: float64(i)}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+
+	if dashboard.PointsRead() != 5 {
+		t.Errorf("Expected 5 points read, got %d", dashboard.PointsRead())
+	}
+	if len(tree.points) != 5 {
+		t.Errorf("Expected 5 points forwarded to the tree, got %d", len(tree.points))
+	}
+}
+
+func TestDashboardPauseBlocksLoadingUntilResumed(t *testing.T) {
+	dashboard := tui.NewDashboard(&bytes.Buffer{}, strings.NewReader(""))
+	tree := &mockTree{}
+	trackedTree := dashboard.Track(tree)
+	dashboard.TogglePause()
+
+	done := make(chan struct{})
+	go func() {
+		trackedTree.AddPoint(&geometry.Coordinate{}, 0, 0, 0, 0, 0, 4326, nil)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("Expected the point loading to be blocked while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	dashboard.TogglePause()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the point loading to resume")
+	}
+	if len(tree.points) != 1 {
+		t.Errorf("Expected 1 point forwarded to the tree, got %d", len(tree.points))
+	}
+}
+
+func TestDashboardAbortDiscardsPoints(t *testing.T) {
+	dashboard := tui.NewDashboard(&bytes.Buffer{}, strings.NewReader(""))
+	tree := &mockTree{}
+	trackedTree := dashboard.Track(tree)
+	dashboard.Abort()
+	trackedTree.AddPoint(&geometry.Coordinate{}, 0, 0, 0, 0, 0, 4326, nil)
+
+	if !dashboard.IsAborted() {
+		t.Errorf("Expected dashboard to be aborted")
+	}
+	if len(tree.points) != 0 {
+		t.Errorf("Expected no points forwarded to the tree after abort, got %d", len(tree.points))
+	}
+}
+
+func TestDashboardReadsAbortCommand(t *testing.T) {
+	dashboard := tui.NewDashboard(&bytes.Buffer{}, strings.NewReader("x\nq\n"))
+	dashboard.Start()
+	defer dashboard.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for !dashboard.IsAborted() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !dashboard.IsAborted() {
+		t.Errorf("Expected the q command to abort the job")
+	}
+}
+
+func TestDashboardDrawsDensityMapAndStatus(t *testing.T) {
+	out := &bytes.Buffer{}
+	dashboard := tui.NewDashboard(out, strings.NewReader(""))
+	dashboard.StartFile("test.las", 2, 3, geometry.NewBoundingBox(0, 10, 0, 10, 0, 0))
+	trackedTree := dashboard.Track(&mockTree{})
+	for i := 0; i < 10; i++ {
+		trackedTree.AddPoint(&geometry.Coordinate{X: 0, Y: 10}, 0, 0, 0, 0, 0, 4326, nil)
+	}
+	trackedTree.AddPoint(&geometry.Coordinate{X: 10, Y: 0}, 0, 0, 0, 0, 0, 4326, nil)
+	dashboard.Log("> reading data from file...")
+	dashboard.Draw()
+
+	output := out.String()
+	for _, expected := range []string{"file 2/3: test.las", "points loaded: 11", "status: > reading data from file...", "|@"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected dashboard to contain '%s', got\n%s", expected, output)
+		}
+	}
+	// the densest cell is the top left one, the sparsest the bottom right one
+	if !strings.Contains(output, ".|") {
+		t.Errorf("Expected the sparsest cell to be drawn in the bottom right corner, got\n%s", output)
+	}
+}
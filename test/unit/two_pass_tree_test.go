@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree/two_pass_tree"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTwoPassTreeSpoolsEveryPointOnce(t *testing.T) {
+	spoolFolder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(spoolFolder) }()
+
+	var coordinates []geometry.Coordinate
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		coordinates = append(coordinates, geometry.Coordinate{X: 14 + random.Float64()*0.01, Y: 41 + random.Float64()*0.01, Z: random.Float64() * 10})
+	}
+	tree := two_pass_tree.NewTwoPassTree(100, spoolFolder, &mockCoordinateConverter{}, &mockElevationCorrector{}).(octree.MultiPassTree)
+
+	for pass := 1; ; pass++ {
+		for i := range coordinates {
+			tree.AddPoint(&coordinates[i], 1, 2, 3, 4, 5, 4326, []float32{float32(i)})
+		}
+		again, err := tree.EndPass()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if !again {
+			if pass != 2 {
+				t.Fatalf("Expected 2 passes, got %d", pass)
+			}
+			break
+		}
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	root := tree.GetRootNode()
+	if root.TotalNumberOfPoints() != int64(len(coordinates)) || root.IsLeaf() {
+		t.Fatalf("Expected a root holding %d points in its branch and having children, got %d", len(coordinates), root.TotalNumberOfPoints())
+	}
+	seen := make(map[float32]bool)
+	var visit func(node octree.INode)
+	visit = func(node octree.INode) {
+		points := node.GetPoints()
+		if len(points) != int(node.NumberOfPoints()) || len(points) == 0 {
+			t.Errorf("Expected every node to hold its %d points, got %d", node.NumberOfPoints(), len(points))
+		}
+		for _, point := range points {
+			if seen[point.Attributes[0]] {
+				t.Errorf("Point %v spooled more than once", point)
+			}
+			seen[point.Attributes[0]] = true
+			if point.R != 1 || point.Classification != 5 || point.X != coordinates[int(point.Attributes[0])].X {
+				t.Errorf("Unexpected spooled point %v", point)
+			}
+		}
+		for _, child := range node.GetChildren() {
+			if child != nil {
+				visit(child)
+			}
+		}
+	}
+	visit(root)
+	if len(seen) != len(coordinates) {
+		t.Errorf("Expected %d spooled points, got %d", len(coordinates), len(seen))
+	}
+
+	if err := tree.Close(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if files, _ := ioutil.ReadDir(spoolFolder); len(files) != 0 {
+		t.Errorf("Expected the spool files to be removed, found %d", len(files))
+	}
+}
+
+func TestTwoPassTreeRequiresSecondPass(t *testing.T) {
+	tree := two_pass_tree.NewTwoPassTree(100, "", &mockCoordinateConverter{}, &mockElevationCorrector{})
+	tree.AddPoint(&geometry.Coordinate{X: 14, Y: 41, Z: 1}, 0, 0, 0, 0, 0, 4326, nil)
+	if err := tree.Build(); err == nil {
+		t.Errorf("Expected an error building a tree whose points have not been spooled")
+	}
+}
+
+func TestTwoPassTreeWithoutPointsNeedsNoSecondPass(t *testing.T) {
+	tree := two_pass_tree.NewTwoPassTree(100, "", &mockCoordinateConverter{}, &mockElevationCorrector{}).(octree.MultiPassTree)
+	if again, err := tree.EndPass(); again || err != nil {
+		t.Fatalf("Expected no second pass, got %t, %v", again, err)
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if tree.GetRootNode() != nil {
+		t.Errorf("Expected no root node")
+	}
+}
+
+func TestTwoPassTreeRecordsTheSpoolReadErrors(t *testing.T) {
+	spoolFolder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(spoolFolder) }()
+	tree := two_pass_tree.NewTwoPassTree(100, spoolFolder, &mockCoordinateConverter{}, &mockElevationCorrector{}).(octree.MultiPassTree)
+	random := rand.New(rand.NewSource(1))
+	coordinates := make([]geometry.Coordinate, 1000)
+	for i := range coordinates {
+		coordinates[i] = geometry.Coordinate{X: 14 + random.Float64()*0.01, Y: 41 + random.Float64()*0.01, Z: random.Float64() * 10}
+	}
+	for again := true; again; {
+		for i := range coordinates {
+			tree.AddPoint(&coordinates[i], 1, 2, 3, 4, 5, 4326, nil)
+		}
+		var err error
+		if again, err = tree.EndPass(); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	if err := tree.Build(); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// the spool files replaced by folders cannot be read back
+	files, _ := filepath.Glob(filepath.Join(spoolFolder, "*", "*.points"))
+	if len(files) == 0 {
+		t.Fatalf("Expected spool files to be written")
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if err := os.Mkdir(file, 0777); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	if points := tree.GetRootNode().GetPoints(); points != nil {
+		t.Errorf("Expected no points read from the broken spool file, got %d", len(points))
+	}
+	if err := tree.Err(); err == nil {
+		t.Errorf("Expected the spool read error to be recorded")
+	}
+	if err := tree.Close(); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+}
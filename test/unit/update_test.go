@@ -0,0 +1,142 @@
+package unit
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/update"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestIsNewerComparesVersionNumbers(t *testing.T) {
+	cases := []struct {
+		version string
+		current string
+		newer   bool
+	}{
+		{"v1.3.0", "1.2.0", true},
+		{"v1.10.0", "1.9.9", true},
+		{"1.2.1", "1.2", true},
+		{"v1.2.0", "1.2.0", false},
+		{"v1.2", "1.2.0", false},
+		{"v1.1.9", "1.2.0", false},
+		{"nightly", "1.2.0", false},
+	}
+	for _, c := range cases {
+		if newer := update.IsNewer(c.version, c.current); newer != c.newer {
+			t.Errorf("Expected IsNewer(%s, %s) = %t", c.version, c.current, c.newer)
+		}
+	}
+}
+
+func TestUpdaterDownloadsSignedBinary(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	binary := []byte("new binary")
+	server := newReleaseServer(t, binary, ed25519.Sign(privateKey, binary))
+	defer server.Close()
+
+	updater, err := update.NewUpdater(server.URL+"/latest", base64.StdEncoding.EncodeToString(publicKey), time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	release, err := updater.GetLatest()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if release.Version != "v9.0.0" {
+		t.Errorf("Expected version v9.0.0, got %s", release.Version)
+	}
+	downloaded, err := updater.Download(release)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if string(downloaded) != string(binary) {
+		t.Errorf("Unexpected binary %s", string(downloaded))
+	}
+}
+
+func TestUpdaterRejectsTamperedBinary(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	server := newReleaseServer(t, []byte("tampered binary"), ed25519.Sign(privateKey, []byte("new binary")))
+	defer server.Close()
+
+	updater, _ := update.NewUpdater(server.URL+"/latest", base64.StdEncoding.EncodeToString(publicKey), time.Second)
+	release, err := updater.GetLatest()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if _, err := updater.Download(release); err == nil {
+		t.Errorf("Expected an error for a binary not matching its signature")
+	}
+}
+
+func TestUpdaterWithoutPublicKeyDoesNotDownload(t *testing.T) {
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	server := newReleaseServer(t, []byte("new binary"), ed25519.Sign(privateKey, []byte("new binary")))
+	defer server.Close()
+
+	updater, _ := update.NewUpdater(server.URL+"/latest", "", time.Second)
+	release, err := updater.GetLatest()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if _, err := updater.Download(release); err == nil {
+		t.Errorf("Expected an error without a public key")
+	}
+	if _, err := update.NewUpdater(server.URL, "not a key", time.Second); err == nil {
+		t.Errorf("Expected an error for an invalid public key")
+	}
+}
+
+func TestReplaceOverwritesExecutable(t *testing.T) {
+	folder := createTempFolder(t)
+	defer func() { _ = os.RemoveAll(folder) }()
+	executable := path.Join(folder, "gocesiumtiler")
+	if err := ioutil.WriteFile(executable, []byte("old binary"), 0755); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if err := update.Replace(executable, []byte("new binary")); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	content, err := ioutil.ReadFile(executable)
+	if err != nil || string(content) != "new binary" {
+		t.Errorf("Expected the executable to be replaced, got %s", string(content))
+	}
+	if files, _ := ioutil.ReadDir(folder); len(files) != 1 {
+		t.Errorf("Expected no file left aside, got %d files", len(files))
+	}
+}
+
+// Serves a v9.0.0 release holding the given binary for the running OS and architecture and its given signature
+func newReleaseServer(t *testing.T, binary []byte, signature []byte) *httptest.Server {
+	name := update.GetAssetName(runtime.GOOS, runtime.GOARCH)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"tag_name": "v9.0.0",
+				"assets": []map[string]string{
+					{"name": name, "browser_download_url": server.URL + "/binary"},
+					{"name": name + update.SignatureExtension, "browser_download_url": server.URL + "/signature"},
+				},
+			})
+		case "/binary":
+			_, _ = w.Write(binary)
+		case "/signature":
+			_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(signature) + "\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return server
+}
@@ -0,0 +1,60 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/watchdog"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchdogDumpsStateWhenStalled(t *testing.T) {
+	dumps := make(chan string, 10)
+	w := watchdog.NewWatchdog(30*time.Millisecond, createTempFolder(t), func(dumpFile string) { dumps <- dumpFile })
+	w.Start()
+	defer w.Stop()
+	w.SetPhase("test.las: read")
+
+	var dumpFile string
+	select {
+	case dumpFile = <-dumps:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a stall to be detected")
+	}
+
+	content, err := ioutil.ReadFile(dumpFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	for _, expected := range []string{"phase: test.las: read", "goroutine"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected dump to contain '%s'", expected)
+		}
+	}
+
+	// the same stall is not reported twice
+	select {
+	case <-dumps:
+		t.Errorf("Expected the stall to be reported once")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatchdogDoesNotReportJobsMakingProgress(t *testing.T) {
+	var stalls int32
+	w := watchdog.NewWatchdog(50*time.Millisecond, createTempFolder(t), func(dumpFile string) { atomic.AddInt32(&stalls, 1) })
+	tree := w.TrackTree(&mockTree{})
+	w.Start()
+
+	for i := 0; i < 30; i++ {
+		tree.AddPoint(&geometry.Coordinate{}, 0, 0, 0, 0, 0, 4326, nil)
+		time.Sleep(5 * time.Millisecond)
+	}
+	w.Stop()
+
+	if atomic.LoadInt32(&stalls) != 0 {
+		t.Errorf("Expected no stalls, got %d", stalls)
+	}
+}
@@ -0,0 +1,46 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/watermark"
+	"math"
+	"testing"
+)
+
+func TestWatermarkIsInjectedAndVerified(t *testing.T) {
+	inner := &mockTree{}
+	tree := watermark.NewWatermarkTree(inner, &mockCoordinateConverter{}, "ACME-42")
+	for i := 0; i < 500; i++ {
+		for j := 0; j < 400; j++ {
+			tree.AddPoint(&geometry.Coordinate{X: 14 + float64(i)*1e-5, Y: 42 + float64(j)*1e-5, Z: 10}, 1, 2, 3, 4, 5, 4326, nil)
+		}
+	}
+
+	// one point every 10000 on average
+	injected := tree.GetCount()
+	if injected < 5 || injected > 60 || len(inner.points) != 200000+int(injected) {
+		t.Fatalf("Unexpected number of watermark points %d out of %d", injected, len(inner.points))
+	}
+	for _, point := range inner.points {
+		// the watermark points are at the center of their cell
+		if point.R == 1 && point.G == 2 && point.B == 3 {
+			continue
+		}
+		if cell := point.X / watermark.CellSize; math.Abs(cell-math.Floor(cell)-0.5) > 1e-3 {
+			t.Errorf("Expected the watermark point at the center of its cell, got longitude %v", point.X)
+		}
+	}
+
+	root := &mockNode{points: inner.points}
+	if verification := watermark.Verify(root, "ACME-42"); !verification.IsPresent() || verification.Matches != int(injected) || verification.Points != int64(len(inner.points)) {
+		t.Errorf("Expected the %d watermark points to be found, got %d", injected, verification.Matches)
+	}
+	if verification := watermark.Verify(root, "OTHER"); verification.IsPresent() {
+		t.Errorf("Expected no watermark of another owner id, got %d points", verification.Matches)
+	}
+
+	tree.Reset()
+	if tree.GetCount() != 0 {
+		t.Errorf("Expected the count to be reset")
+	}
+}
@@ -0,0 +1,83 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mfbonfigli/gocesiumtiler/internal/stats"
+	"github.com/mfbonfigli/gocesiumtiler/internal/webhook"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifierPostsSignedPayload(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	summary := &stats.Summary{Files: []*stats.FileStats{{File: "test.las", PointsRead: 10}}, TotalPointsRead: 10}
+	payload := webhook.NewPayload(nil, "out", summary, nil)
+	if err := webhook.NewNotifier(server.URL, "secret", time.Second).Notify(payload); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if signature := header.Get(webhook.SignatureHeader); signature != webhook.Sign(body, "secret") || !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("Unexpected signature %s", signature)
+	}
+	if event := header.Get(webhook.EventHeader); event != webhook.JobCompleted {
+		t.Errorf("Expected event %s, got %s", webhook.JobCompleted, event)
+	}
+	var received map[string]interface{}
+	if err := json.Unmarshal(body, &received); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if received["event"] != webhook.JobCompleted || received["output"] != "out" || received["error"] != nil || received["run"] != nil {
+		t.Errorf("Unexpected payload %s", string(body))
+	}
+	if !strings.Contains(received["text"].(string), "1 files, 10 points read") {
+		t.Errorf("Unexpected text %s", received["text"])
+	}
+}
+
+func TestNotifierDoesNotSignWithoutSecret(t *testing.T) {
+	signed := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signed = r.Header.Get(webhook.SignatureHeader) != ""
+	}))
+	defer server.Close()
+
+	payload := webhook.NewPayload(nil, "out", &stats.Summary{}, nil)
+	if err := webhook.NewNotifier(server.URL, "", time.Second).Notify(payload); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if signed {
+		t.Errorf("Expected the notification not to be signed")
+	}
+}
+
+func TestNotifierDoesNotRetryRejectedNotifications(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	payload := webhook.NewPayload(errors.New("disk full"), "out", &stats.Summary{}, nil)
+	if payload.Event != webhook.JobFailed || payload.Error != "disk full" {
+		t.Errorf("Unexpected payload of a failed job %v", payload)
+	}
+	if err := webhook.NewNotifier(server.URL, "secret", time.Second).Notify(payload); err == nil {
+		t.Errorf("Expected an error for a rejected notification")
+	}
+	if requests != 1 {
+		t.Errorf("Expected a single attempt, got %d", requests)
+	}
+}
@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/mfbonfigli/gocesiumtiler/internal/io"
+	"github.com/mfbonfigli/gocesiumtiler/internal/treedump"
+	"math"
+	"strconv"
+	"testing"
+)
+
+// Returns the JSON chunk of the given glb content
+func readGlbJsonChunk(t *testing.T, content []byte) []byte {
+	if len(content) < 20 || string(content[:4]) != "glTF" || int(binary.LittleEndian.Uint32(content[8:])) != len(content) {
+		t.Fatalf("Expected a glb content")
+	}
+	return content[20 : 20+binary.LittleEndian.Uint32(content[12:])]
+}
+
+func TestWireframeTilesetDrawsTheBoxesLevelByLevel(t *testing.T) {
+	root := buildTreeDumpTestTree(t).GetRootNode()
+	wireframe, err := io.GenerateWireframeTileset(root, &mockCoordinateConverter{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	levels := treedump.Capture(root).Levels()
+	if len(wireframe.Levels) != len(levels) {
+		t.Fatalf("Expected a content per level of the %d levels, got %d", len(levels), len(wireframe.Levels))
+	}
+
+	type tile struct {
+		Content struct {
+			Uri    string            `json:"uri"`
+			Extras map[string]string `json:"extras"`
+		} `json:"content"`
+		GeometricError float64 `json:"geometricError"`
+		Refine         string  `json:"refine"`
+		Children       []tile  `json:"children"`
+	}
+	var tileset struct {
+		Asset struct {
+			Version string `json:"version"`
+		} `json:"asset"`
+		Root tile `json:"root"`
+	}
+	if err := json.Unmarshal(wireframe.Tileset, &tileset); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if tileset.Asset.Version != "1.1" {
+		t.Errorf("Expected a 3D Tiles 1.1 tileset, got %s", tileset.Asset.Version)
+	}
+
+	// every level draws its nodes and the leaves of the previous levels
+	previousLeaves := 0
+	current := &tileset.Root
+	for depth, level := range levels {
+		if current == nil {
+			t.Fatalf("Expected a tile for the level %d", depth)
+		}
+		if current.Content.Uri != io.GetWireframeLevelFileName(depth) || current.Content.Extras["level"] != strconv.Itoa(depth) || current.Refine != "REPLACE" {
+			t.Errorf("Unexpected content %v of the tile of the level %d", current.Content, depth)
+		}
+		if current.GeometricError != level.GeometricError {
+			t.Errorf("Expected the geometric error %f of the level %d, got %f", level.GeometricError, depth, current.GeometricError)
+		}
+
+		var document struct {
+			Meshes []struct {
+				Primitives []struct {
+					Attributes map[string]int `json:"attributes"`
+					Indices    int            `json:"indices"`
+					Mode       int            `json:"mode"`
+				} `json:"primitives"`
+			} `json:"meshes"`
+			Accessors []struct {
+				Count int       `json:"count"`
+				Min   []float64 `json:"min"`
+				Max   []float64 `json:"max"`
+			} `json:"accessors"`
+		}
+		if err := json.Unmarshal(readGlbJsonChunk(t, wireframe.Levels[depth]), &document); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		primitive := document.Meshes[0].Primitives[0]
+		boxes := level.Nodes + previousLeaves
+		if primitive.Mode != 1 || document.Accessors[primitive.Indices].Count != 24*boxes || document.Accessors[primitive.Attributes["COLOR_0"]].Count != 8*boxes {
+			t.Errorf("Expected the 12 edges of %d boxes in the level %d, got %d indices", boxes, depth, document.Accessors[primitive.Indices].Count)
+		}
+		if depth == 0 {
+			// the corners are relative to the center of the root and y-up
+			box, positions := root.GetBoundingBox(), document.Accessors[primitive.Attributes["POSITION"]]
+			expectedMax := []float64{box.Xmax - box.Xmid, box.Zmax - box.Zmid, box.Ymid - box.Ymin}
+			for i := range expectedMax {
+				if math.Abs(positions.Max[i]-expectedMax[i]) > 1e-3 || math.Abs(positions.Min[i]+expectedMax[i]) > 1e-3 {
+					t.Errorf("Expected the root corners within +/-%v, got %v and %v", expectedMax, positions.Min, positions.Max)
+				}
+			}
+		}
+		previousLeaves += level.Leaves
+
+		// the tile of the next level is the only child of the current one
+		next := current
+		current = nil
+		if len(next.Children) == 1 {
+			current = &next.Children[0]
+		}
+	}
+	if current != nil {
+		t.Errorf("Expected no tile past the last level")
+	}
+}
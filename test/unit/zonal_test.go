@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"github.com/mfbonfigli/gocesiumtiler/internal/data"
+	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/zonal"
+	"math"
+	"strings"
+	"testing"
+)
+
+const zonalTestZones = `{"type": "FeatureCollection", "features": [
+	{"type": "Feature", "id": "square", "properties": {"name": "square"}, "geometry": {"type": "Polygon", "coordinates": [
+		[[0, 0], [0.01, 0], [0.01, 0.01], [0, 0.01], [0, 0]],
+		[[0.004, 0.004], [0.004, 0.006], [0.006, 0.006], [0.006, 0.004], [0.004, 0.004]]
+	]}},
+	{"type": "Feature", "properties": {}, "geometry": {"type": "MultiPolygon", "coordinates": [
+		[[[0.02, 0], [0.03, 0], [0.03, 0.01], [0.02, 0.01], [0.02, 0]]]
+	]}}
+]}`
+
+func TestReadZonesReadsPolygonsAndAreas(t *testing.T) {
+	zones, err := zonal.ReadZones([]byte(zonalTestZones))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(zones) != 2 || zones[0].Id != "square" || zones[1].Id != "2" {
+		t.Fatalf("Unexpected zones %v", zones)
+	}
+	// a square of 0.01 degrees at the equator is about 1113 m wide, the hole being a fifth of its side
+	side := 0.01 * math.Pi / 180 * 6378137
+	if expected := side * side * 0.96; math.Abs(zones[0].Area-expected) > expected*1e-3 {
+		t.Errorf("Expected an area of %f m2, got %f", expected, zones[0].Area)
+	}
+	if expected := side * side; math.Abs(zones[1].Area-expected) > expected*1e-3 {
+		t.Errorf("Expected an area of %f m2, got %f", expected, zones[1].Area)
+	}
+}
+
+func TestReadZonesRejectsOtherGeometries(t *testing.T) {
+	content := `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [0, 0]}}]}`
+	if _, err := zonal.ReadZones([]byte(content)); err == nil {
+		t.Errorf("Expected an error for a point zone")
+	}
+	if _, err := zonal.ReadZones([]byte(`{"type": "Feature"}`)); err == nil {
+		t.Errorf("Expected an error for a single feature")
+	}
+}
+
+func TestComputeSummarizesThePointsOfEveryZone(t *testing.T) {
+	zones, _ := zonal.ReadZones([]byte(zonalTestZones))
+	child := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(0, 0.01, 0, 0.01, 0, 100),
+		internalSrid:        4326,
+		points:              []*data.Point{data.NewPoint(0.001, 0.001, 30, 0, 0, 0, 0, 6), data.NewPoint(0.005, 0.005, 50, 0, 0, 0, 0, 2)},
+		globalChildrenCount: 2,
+	}
+	root := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(0, 0.04, 0, 0.01, 0, 100),
+		internalSrid:        4326,
+		points:              []*data.Point{data.NewPoint(0.009, 0.009, 10, 0, 0, 0, 0, 2), data.NewPoint(0.025, 0.005, 20, 0, 0, 0, 0, 2), data.NewPoint(0.035, 0.005, 90, 0, 0, 0, 0, 2)},
+		children:            [8]octree.INode{child},
+		globalChildrenCount: 5,
+	}
+
+	statistics, err := zonal.Compute(root, zones, nil, false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	square := statistics[0]
+	// the point in the hole is not counted
+	if square.Count != 2 || square.MeanElevation != 20 || square.MaxElevation != 30 || square.Classes[2] != 1 || square.Classes[6] != 1 {
+		t.Errorf("Unexpected statistics of the square %+v", square)
+	}
+	if density := square.GetDensity(zones[0]); math.Abs(density-2/zones[0].Area) > 1e-12 {
+		t.Errorf("Unexpected density %f", density)
+	}
+	if statistics[1].Count != 1 || statistics[1].MaxElevation != 20 {
+		t.Errorf("Unexpected statistics of the second zone %+v", statistics[1])
+	}
+
+	table, err := zonal.ToCsv(zones, statistics)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(string(table)), "\n")
+	if lines[0] != "id,points,area_m2,density,mean_elevation,max_elevation,class_2,class_6" || !strings.HasPrefix(lines[1], "square,2,") || !strings.HasSuffix(lines[2], ",1,0") {
+		t.Errorf("Unexpected table %s", string(table))
+	}
+}
+
+func TestComputeCountsTheRepeatedPointsOfReplaceTilesetsOnce(t *testing.T) {
+	zones, _ := zonal.ReadZones([]byte(zonalTestZones))
+	child := &mockNode{
+		boundingBox:  geometry.NewBoundingBox(0, 0.01, 0, 0.01, 0, 100),
+		internalSrid: 4326,
+		// the copy of the root point is quantized in the frame of the child content
+		points:              []*data.Point{data.NewPoint(0.009+1e-11, 0.009, 10+1e-5, 0, 0, 0, 0, 2), data.NewPoint(0.001, 0.001, 30, 0, 0, 0, 0, 6)},
+		globalChildrenCount: 2,
+	}
+	root := &mockNode{
+		boundingBox:         geometry.NewBoundingBox(0, 0.04, 0, 0.01, 0, 100),
+		internalSrid:        4326,
+		points:              []*data.Point{data.NewPoint(0.009, 0.009, 10, 0, 0, 0, 0, 2)},
+		children:            [8]octree.INode{child},
+		globalChildrenCount: 3,
+	}
+
+	statistics, err := zonal.Compute(root, zones, nil, true, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if statistics[0].Count != 2 {
+		t.Errorf("Expected the repeated point to be counted once, got %d points", statistics[0].Count)
+	}
+}
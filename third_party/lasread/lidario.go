@@ -22,7 +22,7 @@ var NoData = math.Inf(-1)
 type LasFile struct {
 	fileName               string
 	fileMode               string
-	f                      *os.File
+	f                      lasFileHandle
 	Header                 LasHeader
 	VlrData                []VLR
 	geokeys                GeoKeys
@@ -39,6 +39,13 @@ type LasFile struct {
 	sync.RWMutex
 }
 
+// lasFileHandle is the file backing a LasFile, os.File satisfies it.
+type lasFileHandle interface {
+	io.ReaderAt
+	io.Writer
+	io.Closer
+}
+
 // NewLasFile creates a new LasFile structure.
 func NewLasFile(fileName, fileMode string) (*LasFile, error) {
 	fileMode = strings.ToLower(fileMode)
@@ -385,8 +392,8 @@ func (las *LasFile) read() error {
 func (las *LasFile) readHeader() error {
 	las.Lock()
 	defer las.Unlock()
-	b := make([]byte, 243)
-	if _, err := las.f.ReadAt(b[0:243], 0); err != nil && err != io.EOF {
+	b := make([]byte, 375)
+	if _, err := las.f.ReadAt(b[0:375], 0); err != nil && err != io.EOF {
 		return err
 	}
 
@@ -444,7 +451,9 @@ func (las *LasFile) readHeader() error {
 	offset += 4
 	las.Header.NumberOfVLRs = int(binary.LittleEndian.Uint32(b[offset : offset+4]))
 	offset += 4
-	las.Header.PointFormatID = b[104]
+	// LASzip flags the compressed point data record formats setting their two high bits
+	las.Header.Compressed = b[104]&0xC0 != 0
+	las.Header.PointFormatID = b[104] & 0x3F
 	offset++
 	las.Header.PointRecordLength = int(binary.LittleEndian.Uint16(b[offset : offset+2]))
 	offset += 2
@@ -479,21 +488,46 @@ func (las *LasFile) readHeader() error {
 	offset += 8
 	las.Header.MinZ = math.Float64frombits(binary.LittleEndian.Uint64(b[offset : offset+8]))
 	offset += 8
-	if las.Header.VersionMajor == 1 && las.Header.VersionMinor == 3 {
+	if las.Header.VersionMajor == 1 && las.Header.VersionMinor >= 3 {
 		las.Header.WaveformDataStart = binary.LittleEndian.Uint64(b[offset : offset+8])
+		offset += 8
+	}
+	if las.Header.VersionMajor == 1 && las.Header.VersionMinor >= 4 {
+		las.Header.StartOfFirstEVLR = binary.LittleEndian.Uint64(b[offset : offset+8])
+		offset += 8
+		las.Header.NumberOfEVLRs = int(binary.LittleEndian.Uint32(b[offset : offset+4]))
+		offset += 4
+		// the legacy point counts are zero for point formats 6 and higher or if they do not fit 32 bits
+		if numberPoints := int(binary.LittleEndian.Uint64(b[offset : offset+8])); numberPoints > 0 {
+			las.Header.NumberPoints = numberPoints
+		}
+		offset += 8
+		for i := 0; i < 15; i++ {
+			las.Header.ExtendedNumberPointsByReturn[i] = int(binary.LittleEndian.Uint64(b[offset : offset+8]))
+			offset += 8
+		}
 	}
 
 	return nil
 }
 
+// Size of the header of the VLRs
+const vlrHeaderLength = 54
+
 func (las *LasFile) readVLRs() error {
 	las.Lock()
 	defer las.Unlock()
-	// Update the VLR slice
-	las.VlrData = make([]VLR, las.Header.NumberOfVLRs)
-
 	// Estimate how many bytes are used to store the VLRs
 	vlrLength := las.Header.OffsetToPoints - las.Header.HeaderSize
+	if vlrLength < 0 || las.Header.NumberOfVLRs > vlrLength/vlrHeaderLength {
+		return fmt.Errorf("the %v VLRs do not fit between the header and the points", las.Header.NumberOfVLRs)
+	}
+	if size, ok := getFileSize(las.f); ok && int64(las.Header.OffsetToPoints) > size {
+		return errors.New("the offset to the points exceeds the size of the file")
+	}
+
+	// Update the VLR slice
+	las.VlrData = make([]VLR, las.Header.NumberOfVLRs)
 	b := make([]byte, vlrLength)
 	// if _, err := las.r.ReadAt(b[0:vlrLength], int64(las.Header.HeaderSize)); err != nil && err != io.EOF {
 	if _, err := las.f.ReadAt(b, int64(las.Header.HeaderSize)); err != nil && err != io.EOF {
@@ -502,6 +536,9 @@ func (las *LasFile) readVLRs() error {
 
 	offset := 0
 	for i := 0; i < las.Header.NumberOfVLRs; i++ {
+		if offset+vlrHeaderLength > len(b) || offset+vlrHeaderLength+int(binary.LittleEndian.Uint16(b[offset+20:offset+22])) > len(b) {
+			return fmt.Errorf("VLR %v exceeds the offset to the points", i)
+		}
 		vlr := VLR{}
 		vlr.Reserved = int(binary.LittleEndian.Uint16(b[offset : offset+2]))
 		offset += 2
@@ -536,6 +573,40 @@ func (las *LasFile) readVLRs() error {
 		las.VlrData[i] = vlr
 	}
 
+	return las.readEVLRs()
+}
+
+// Reads the extended VLRs stored after the point records of LAS 1.4 files, appending them to the VLRs
+func (las *LasFile) readEVLRs() error {
+	if las.Header.NumberOfEVLRs == 0 || las.Header.StartOfFirstEVLR == 0 {
+		return nil
+	}
+
+	offset := int64(las.Header.StartOfFirstEVLR)
+	header := make([]byte, 60)
+	for i := 0; i < las.Header.NumberOfEVLRs; i++ {
+		if _, err := las.f.ReadAt(header, offset); err != nil {
+			return errors.New("cannot read extended VLR header: " + err.Error())
+		}
+		vlr := VLR{}
+		vlr.Reserved = int(binary.LittleEndian.Uint16(header[0:2]))
+		vlr.UserID = strings.Trim(strings.Trim(string(header[2:18]), " "), "\x00")
+		vlr.RecordID = int(binary.LittleEndian.Uint16(header[18:20]))
+		vlr.RecordLengthAfterHeader = int(binary.LittleEndian.Uint64(header[20:28]))
+		vlr.Description = strings.Trim(strings.Trim(string(header[28:60]), " "), "\x00")
+		offset += 60
+		if size, ok := getFileSize(las.f); vlr.RecordLengthAfterHeader < 0 || (ok && int64(vlr.RecordLengthAfterHeader) > size-offset) {
+			return fmt.Errorf("extended VLR %v exceeds the size of the file", i)
+		}
+
+		vlr.BinaryData = make([]uint8, vlr.RecordLengthAfterHeader)
+		if _, err := las.f.ReadAt(vlr.BinaryData, offset); err != nil && err != io.EOF {
+			return errors.New("cannot read extended VLR data: " + err.Error())
+		}
+		offset += int64(vlr.RecordLengthAfterHeader)
+		las.VlrData = append(las.VlrData, vlr)
+	}
+
 	return nil
 }
 
@@ -1443,7 +1514,13 @@ type LasHeader struct {
 	MaxZ                 float64
 	MinZ                 float64
 	WaveformDataStart    uint64
-	projectIDUsed        bool
+	StartOfFirstEVLR     uint64
+	NumberOfEVLRs        int
+	// LAS 1.4 number of points by return, for up to 15 returns
+	ExtendedNumberPointsByReturn [15]int
+	// True if the points are compressed by LASzip, i.e. the file is a LAZ one
+	Compressed    bool
+	projectIDUsed bool
 }
 
 func (h LasHeader) String() string {
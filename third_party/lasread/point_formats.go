@@ -0,0 +1,199 @@
+// Copyright 2019 Massimo Federico Bonfigli
+
+// This file contains the decoding of the point data record formats 0 to 10 defined up to the LAS 1.4 specification
+
+package lidario
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+)
+
+// Classification code marking overlap points in the point data record formats 0 to 5
+const legacyOverlapClass = 12
+
+// Attributes of a point record, decoded according to its point data record format
+type PointAttributes struct {
+	X, Y, Z         float64
+	Intensity       uint16
+	ReturnNumber    uint8
+	NumberOfReturns uint8
+	// Classification code, 0-31 for the point data record formats 0 to 5 and 0-255 for the formats 6 to 10
+	Classification uint8
+	Synthetic      bool
+	KeyPoint       bool
+	Withheld       bool
+	// True if the point is flagged as overlap, or has the overlap class in the formats 0 to 5
+	Overlap        bool
+	ScannerChannel uint8
+	GpsTime        float64
+	// True if the GPS time is the adjusted standard GPS time rather than the seconds of the GPS week
+	StandardGpsTime bool
+	R, G, B         uint16
+}
+
+// Offset of the adjusted standard GPS time from the standard GPS time and duration of a GPS week, in seconds
+const (
+	adjustedGpsTimeOffset = 1e9
+	gpsWeekSeconds        = 604800
+)
+
+// Returns the GPS time of the point as seconds of the GPS week, converting the adjusted standard GPS time
+func (point *PointAttributes) GetGpsWeekTime() float64 {
+	if !point.StandardGpsTime {
+		return point.GpsTime
+	}
+	return math.Mod(math.Mod(point.GpsTime+adjustedGpsTimeOffset, gpsWeekSeconds)+gpsWeekSeconds, gpsWeekSeconds)
+}
+
+// Returns true if the given point has to be loaded
+type PointFilter func(point *PointAttributes) bool
+
+// Returns the supplementary attributes of the given point, stored at the given index of the file, nil if it has none
+type AttributeSource func(index int, point *PointAttributes) []float32
+
+// Byte offsets of the fields of a point record, -1 if the field is not stored
+type pointLayout struct {
+	intensity      int
+	returns        int
+	flags          int
+	classification int
+	gpsTime        int
+	rgb            int
+	// true for the point data record formats 6 to 10 introduced by LAS 1.4
+	extended bool
+	// minimum record length, records may be longer if they store extra bytes
+	length int
+}
+
+// Returns the layout of the records of the given point data record format. Files with point formats 0 to 3 whose
+// records miss the intensity or the user data field, detected by their record length, are supported as well.
+func getPointLayout(formatId byte, recordLength int) (*pointLayout, error) {
+	var layout *pointLayout
+	switch {
+	case formatId <= 3:
+		// lengths of the records without intensity, without user data and without both
+		reducedLengths := [4][3]int{{18, 19, 17}, {26, 27, 25}, {24, 25, 23}, {32, 33, 31}}
+		switch recordLength {
+		case reducedLengths[formatId][0]:
+			layout = newLegacyLayout(formatId, false, true)
+		case reducedLengths[formatId][1]:
+			layout = newLegacyLayout(formatId, true, false)
+		case reducedLengths[formatId][2]:
+			layout = newLegacyLayout(formatId, false, false)
+		default:
+			layout = newLegacyLayout(formatId, true, true)
+		}
+	case formatId <= 5:
+		layout = newLegacyLayout(formatId, true, true)
+	case formatId <= 10:
+		layout = newExtendedLayout(formatId)
+	default:
+		return nil, errors.New("unsupported LAS point data record format " + strconv.Itoa(int(formatId)))
+	}
+
+	if recordLength < layout.length {
+		return nil, errors.New("point record length " + strconv.Itoa(recordLength) + " too short for point data record format " + strconv.Itoa(int(formatId)))
+	}
+	return layout, nil
+}
+
+func newLegacyLayout(formatId byte, hasIntensity bool, hasUserData bool) *pointLayout {
+	layout := &pointLayout{intensity: -1, gpsTime: -1, rgb: -1}
+	offset := 12
+	if hasIntensity {
+		layout.intensity = offset
+		offset += 2
+	}
+	layout.returns = offset
+	layout.flags = offset + 1
+	layout.classification = offset + 1
+	// return numbers, classification and scan angle rank
+	offset += 3
+	if hasUserData {
+		offset++
+	}
+	// point source id
+	offset += 2
+	if formatId == 1 || formatId >= 3 {
+		layout.gpsTime = offset
+		offset += 8
+	}
+	if formatId == 2 || formatId == 3 || formatId == 5 {
+		layout.rgb = offset
+		offset += 6
+	}
+	if formatId == 4 || formatId == 5 {
+		// wave packet descriptor
+		offset += 29
+	}
+	layout.length = offset
+	return layout
+}
+
+func newExtendedLayout(formatId byte) *pointLayout {
+	// x, y, z, intensity, return numbers, classification flags and scanner channel, classification, user data,
+	// scan angle, point source id and gps time
+	layout := &pointLayout{intensity: 12, returns: 14, flags: 15, classification: 16, gpsTime: 22, rgb: -1, extended: true, length: 30}
+	if formatId == 7 || formatId == 8 || formatId == 10 {
+		layout.rgb = layout.length
+		layout.length += 6
+	}
+	if formatId == 8 || formatId == 10 {
+		// near infrared
+		layout.length += 2
+	}
+	if formatId == 9 || formatId == 10 {
+		// wave packet descriptor
+		layout.length += 29
+	}
+	return layout
+}
+
+// Returns true if the records store the GPS time of the points
+func (l *pointLayout) hasGpsTime() bool {
+	return l.gpsTime >= 0
+}
+
+// Decodes the given point record into the given attributes
+func (l *pointLayout) decode(record []byte, header *LasHeader, point *PointAttributes) {
+	point.X = float64(int32(binary.LittleEndian.Uint32(record[0:4])))*header.XScaleFactor + header.XOffset
+	point.Y = float64(int32(binary.LittleEndian.Uint32(record[4:8])))*header.YScaleFactor + header.YOffset
+	point.Z = float64(int32(binary.LittleEndian.Uint32(record[8:12])))*header.ZScaleFactor + header.ZOffset
+
+	if l.intensity >= 0 {
+		point.Intensity = binary.LittleEndian.Uint16(record[l.intensity : l.intensity+2])
+	}
+
+	returns, flags, classification := record[l.returns], record[l.flags], record[l.classification]
+	if l.extended {
+		point.ReturnNumber = returns & 0x0F
+		point.NumberOfReturns = returns >> 4
+		point.Classification = classification
+		point.Synthetic = flags&0x01 != 0
+		point.KeyPoint = flags&0x02 != 0
+		point.Withheld = flags&0x04 != 0
+		point.Overlap = flags&0x08 != 0
+		point.ScannerChannel = (flags >> 4) & 0x03
+	} else {
+		point.ReturnNumber = returns & 0x07
+		point.NumberOfReturns = (returns >> 3) & 0x07
+		point.Classification = classification & 0x1F
+		point.Synthetic = classification&0x20 != 0
+		point.KeyPoint = classification&0x40 != 0
+		point.Withheld = classification&0x80 != 0
+		point.Overlap = point.Classification == legacyOverlapClass
+	}
+
+	if l.gpsTime >= 0 {
+		point.GpsTime = math.Float64frombits(binary.LittleEndian.Uint64(record[l.gpsTime : l.gpsTime+8]))
+		point.StandardGpsTime = header.GlobalEncoding.GpsTime() == SatelliteGpsTime
+	}
+	if l.rgb >= 0 {
+		point.R = binary.LittleEndian.Uint16(record[l.rgb : l.rgb+2])
+		point.G = binary.LittleEndian.Uint16(record[l.rgb+2 : l.rgb+4])
+		point.B = binary.LittleEndian.Uint16(record[l.rgb+4 : l.rgb+6])
+	}
+}
@@ -6,17 +6,84 @@
 package lidario
 
 import (
-	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/cancellation"
 	"github.com/mfbonfigli/gocesiumtiler/internal/geometry"
+	"github.com/mfbonfigli/gocesiumtiler/internal/laszip"
 	"github.com/mfbonfigli/gocesiumtiler/internal/octree"
+	"github.com/mfbonfigli/gocesiumtiler/internal/readers"
+	"github.com/mfbonfigli/gocesiumtiler/internal/storage"
 	"io"
-	"os"
+	"math"
 	"runtime"
+	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 type LasFileLoader struct {
 	Tree octree.ITree
+	// Optional transformer applied to every point according to its GPS time
+	PointTransformer readers.PointTransformer
+	// Storage the files are read from, the local file system if nil
+	Storage storage.Storage
+	// Number of goroutines decoding the points, one per CPU if not positive
+	Workers int
+	// Number of points read and decoded at a time, all the points of the file if not positive. LAZ files are read by
+	// whole compressed chunks, so that a batch can exceed it by up to a chunk.
+	ChunkSize int
+	// Optional filter deciding which of the decoded points are added to the tree
+	Filter PointFilter
+	// Optional cancel request polled while decoding the points
+	Cancellation *cancellation.Token
+	// Optional source of the supplementary attributes of the points added to the tree
+	Attributes AttributeSource
+	// If not nil the malformed point records are skipped rather than failing the read, and counted in it
+	Recovery *RecoveryCounts
+}
+
+// Number of the point records skipped while reading a file, i.e. the records whose coordinates fall outside the bounds
+// declared by the header, the ones missing from truncated files and the ones of the LAZ chunks that cannot be
+// decompressed, and number of the records of the LAZ chunks read after a skipped chunk, which would have been lost
+// without resynchronizing on the chunk table
+type RecoveryCounts struct {
+	Skipped   int64
+	Recovered int64
+}
+
+// Fraction of the extent declared by the header by which the coordinates of a record may exceed the bounds of the
+// header before the record is considered malformed, tolerating the bounds rounded by the writers
+const boundsTolerance = 0.01
+
+// Adapts a read only storage file to the file handle of a LasFile
+type readOnlyHandle struct {
+	storage.File
+}
+
+func (h readOnlyHandle) Write(p []byte) (int, error) {
+	return 0, errors.New("the LAS file is opened read only")
+}
+
+// Returns the size of the file backing a LasFile, false if it cannot be sought to find it
+func getFileSize(f lasFileHandle) (int64, bool) {
+	var file interface{} = f
+	if handle, ok := f.(readOnlyHandle); ok {
+		file = handle.File
+	}
+	seeker, ok := file.(io.Seeker)
+	if !ok {
+		return 0, false
+	}
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if _, seekErr := seeker.Seek(current, io.SeekStart); err != nil || seekErr != nil {
+		return 0, false
+	}
+	return size, true
 }
 
 func NewLasFileLoader(tree octree.ITree) *LasFileLoader {
@@ -39,144 +106,217 @@ func (lasFileLoader *LasFileLoader) LoadLasFile(fileName string, inSrid int) (*L
 
 // Reads the las file and produces a LasFile struct instance loading points data into its inner list of Point
 func (lasFileLoader *LasFileLoader) readForOctree(inSrid int, las *LasFile) error {
-	var err error
-	if las.f, err = os.Open(las.fileName); err != nil {
+	fileStorage := lasFileLoader.Storage
+	if fileStorage == nil {
+		fileStorage = storage.NewOsStorage()
+	}
+	file, err := fileStorage.Open(las.fileName)
+	if err != nil {
 		return err
 	}
+	las.f = readOnlyHandle{file}
 	if err = las.readHeader(); err != nil {
 		return err
 	}
+	if las.Header.NumberPoints < 0 {
+		return errors.New("the header declares a negative number of points")
+	}
 	if err := las.readVLRs(); err != nil {
 		return err
 	}
 	if las.fileMode != "rh" {
-		recLengths := [4][4]int{{20, 18, 19, 17}, {28, 26, 27, 25}, {26, 24, 25, 23}, {34, 32, 33, 31}}
-
-		if las.Header.PointRecordLength == recLengths[las.Header.PointFormatID][0] {
-			las.usePointIntensity = true
-			las.usePointUserdata = true
-		} else if las.Header.PointRecordLength == recLengths[las.Header.PointFormatID][1] {
-			las.usePointIntensity = false
-			las.usePointUserdata = true
-		} else if las.Header.PointRecordLength == recLengths[las.Header.PointFormatID][2] {
-			las.usePointIntensity = true
-			las.usePointUserdata = false
-		} else if las.Header.PointRecordLength == recLengths[las.Header.PointFormatID][3] {
-			las.usePointIntensity = false
-			las.usePointUserdata = false
-		}
-
-		if err := lasFileLoader.readPointsOctElem(inSrid, las); err != nil {
+		layout, err := getPointLayout(las.Header.PointFormatID, las.Header.PointRecordLength)
+		if err != nil {
+			return err
+		}
+
+		if lasFileLoader.PointTransformer != nil && !layout.hasGpsTime() {
+			return errors.New("points must have GPS time to be transformed, LAS point format " + strconv.Itoa(int(las.Header.PointFormatID)) + " does not store it")
+		}
+
+		if err := lasFileLoader.readPointsOctElem(inSrid, las, layout); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// Reads all the points of the given las file and parses them into a Point data structure which is then stored
-// in the given LasFile instance
-func (lasFileLoader *LasFileLoader) readPointsOctElem(inSrid int, las *LasFile) error {
+// Reads all the points of the given las file in batches of the chunk size, decoding them according to the given
+// record layout, and adds to the tree the ones accepted by the filter, if any. Only the records of a batch are held in
+// memory at a time.
+func (lasFileLoader *LasFileLoader) readPointsOctElem(inSrid int, las *LasFile, layout *pointLayout) error {
 	las.Lock()
 	defer las.Unlock()
-	// las.pointDataOctElement = make([]octree.OctElement, las.Header.NumberPoints)
-	if las.Header.PointFormatID == 1 || las.Header.PointFormatID == 3 {
-		// las.gpsData = make([]float64, las.Header.NumberPoints)
-	}
-	if las.Header.PointFormatID == 2 || las.Header.PointFormatID == 3 {
-		// las.rgbData = make([]RgbData, las.Header.NumberPoints)
-	}
 
-	// Estimate how many bytes are used to store the points
-	pointsLength := las.Header.NumberPoints * las.Header.PointRecordLength
-	b := make([]byte, pointsLength)
-	if _, err := las.f.ReadAt(b, int64(las.Header.OffsetToPoints)); err != nil && err != io.EOF {
-		// return err
+	if err := lasFileLoader.Cancellation.Err(); err != nil {
+		return err
 	}
 
-	// Intensity and userdata are both optional. Figure out if they need to be read.
-	// The only way to do this is to compare the data record length by data format
-	recLengths := [4][4]int{{20, 18, 19, 17}, {28, 26, 27, 25}, {26, 24, 25, 23}, {34, 32, 33, 31}}
-
-	if las.Header.PointRecordLength == recLengths[las.Header.PointFormatID][0] {
-		las.usePointIntensity = true
-		las.usePointUserdata = true
-	} else if las.Header.PointRecordLength == recLengths[las.Header.PointFormatID][1] {
-		las.usePointIntensity = false
-		las.usePointUserdata = true
-	} else if las.Header.PointRecordLength == recLengths[las.Header.PointFormatID][2] {
-		las.usePointIntensity = true
-		las.usePointUserdata = false
-	} else if las.Header.PointRecordLength == recLengths[las.Header.PointFormatID][3] {
-		las.usePointIntensity = false
-		las.usePointUserdata = false
+	numCPUs := lasFileLoader.Workers
+	if numCPUs <= 0 {
+		numCPUs = runtime.NumCPU()
 	}
+	err := lasFileLoader.readPointBatches(las, numCPUs, func(first int, b []byte) error {
+		lasFileLoader.decodePoints(inSrid, las, layout, first, b, numCPUs)
+		return lasFileLoader.Cancellation.Err()
+	})
+	if err != nil {
+		return err
+	}
+	return lasFileLoader.Cancellation.Err()
+}
 
-	numCPUs := runtime.NumCPU()
+// Decodes the given records, the first one being the point of the given index, with the given number of goroutines,
+// adding to the tree the points accepted by the filter, if any
+func (lasFileLoader *LasFileLoader) decodePoints(inSrid int, las *LasFile, layout *pointLayout, first int, b []byte, numCPUs int) {
 	var wg sync.WaitGroup
-	blockSize := las.Header.NumberPoints / numCPUs
+	numberOfPoints := len(b) / las.Header.PointRecordLength
+	isMalformed := lasFileLoader.getMalformedRecordCheck(las)
+	blockSize := numberOfPoints / numCPUs
 	var startingPoint int
-	for startingPoint < las.Header.NumberPoints {
+	for startingPoint < numberOfPoints {
 		endingPoint := startingPoint + blockSize
-		if endingPoint >= las.Header.NumberPoints {
-			endingPoint = las.Header.NumberPoints - 1
+		if endingPoint >= numberOfPoints {
+			endingPoint = numberOfPoints - 1
 		}
 		wg.Add(1)
 		go func(pointSt, pointEnd int) {
 			defer wg.Done()
 
-			var offset int
-			// var p PointRecord0
+			var point PointAttributes
 			for i := pointSt; i <= pointEnd; i++ {
-				offset = i * las.Header.PointRecordLength
-				// p := PointRecord0{}
-				X := float64(int32(binary.LittleEndian.Uint32(b[offset:offset+4])))*las.Header.XScaleFactor + las.Header.XOffset
-				offset += 4
-				Y := float64(int32(binary.LittleEndian.Uint32(b[offset:offset+4])))*las.Header.YScaleFactor + las.Header.YOffset
-				offset += 4
-				Z := float64(int32(binary.LittleEndian.Uint32(b[offset:offset+4])))*las.Header.ZScaleFactor + las.Header.ZOffset
-				offset += 4
-
-				var R, G, B, Intensity, Classification uint8
-				if las.usePointIntensity {
-					Intensity = uint8(binary.LittleEndian.Uint16(b[offset:offset+2]) / 256)
-					offset += 2
+				if (i-pointSt)%cancellation.CheckInterval == 0 && lasFileLoader.Cancellation.IsCancelled() {
+					return
 				}
-				//p.BitField = PointBitField{Value: b[offset]}
-				offset++
-				//p.ClassBitField = ClassificationBitField{Value: b[offset]}
-				Classification = b[offset]
-				offset++
-				// p.ScanAngle = int8(b[offset])
-				offset++
-				if las.usePointUserdata {
-					// p.UserData = b[offset]
-					offset++
+				offset := i * las.Header.PointRecordLength
+				layout.decode(b[offset:offset+las.Header.PointRecordLength], &las.Header, &point)
+				if isMalformed != nil && isMalformed(&point) {
+					atomic.AddInt64(&lasFileLoader.Recovery.Skipped, 1)
+					continue
+				}
+				if lasFileLoader.Filter != nil && !lasFileLoader.Filter(&point) {
+					continue
 				}
-				// p.PointSourceID = binary.LittleEndian.Uint16(b[offset : offset+2])
-				offset += 2
-
-				// las.pointData[i] = p
 
-				if las.Header.PointFormatID == 1 || las.Header.PointFormatID == 3 {
-					// las.gpsData[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[offset : offset+8]))
-					offset += 8
+				coordinate, srid := &geometry.Coordinate{X: point.X, Y: point.Y, Z: point.Z}, inSrid
+				if lasFileLoader.PointTransformer != nil {
+					coordinate, srid = lasFileLoader.PointTransformer.Transform(coordinate, point.GpsTime, inSrid)
 				}
-				if las.Header.PointFormatID == 2 || las.Header.PointFormatID == 3 {
-					//rgb := RgbData{}
-					R = uint8(binary.LittleEndian.Uint16(b[offset:offset+2]) / 256)
-					offset += 2
-					G = uint8(binary.LittleEndian.Uint16(b[offset:offset+2]) / 256)
-					offset += 2
-					B = uint8(binary.LittleEndian.Uint16(b[offset:offset+2]) / 256)
-					offset += 2
-					// las.rgbData[i] = rgb
+				var attributes []float32
+				if lasFileLoader.Attributes != nil {
+					attributes = lasFileLoader.Attributes(first+i, &point)
 				}
-				lasFileLoader.Tree.AddPoint(&geometry.Coordinate{X: X, Y: Y, Z: Z}, R, G, B, Intensity, Classification, inSrid)
-				// las.pointDataOctElement[i] = elem
+				lasFileLoader.Tree.AddPoint(
+					coordinate,
+					uint8(point.R/256),
+					uint8(point.G/256),
+					uint8(point.B/256),
+					uint8(point.Intensity/256),
+					point.Classification,
+					srid,
+					attributes,
+				)
 			}
 		}(startingPoint, endingPoint)
 		startingPoint = endingPoint + 1
 	}
 	wg.Wait()
+}
+
+// Returns the function telling the records whose coordinates are not finite or fall outside the bounds declared by the
+// header of the given file, nil if the malformed records are not skipped. The bounds of the axes whose extent is not
+// positive are not checked, as some writers leave them unset.
+func (lasFileLoader *LasFileLoader) getMalformedRecordCheck(las *LasFile) func(point *PointAttributes) bool {
+	if lasFileLoader.Recovery == nil {
+		return nil
+	}
+	header := las.Header
+	outside := func(value float64, min float64, max float64) bool {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return true
+		}
+		if !(max > min) || math.IsInf(max-min, 0) {
+			return false
+		}
+		margin := (max - min) * boundsTolerance
+		return value < min-margin || value > max+margin
+	}
+	return func(point *PointAttributes) bool {
+		return outside(point.X, header.MinX, header.MaxX) || outside(point.Y, header.MinY, header.MaxY) || outside(point.Z, header.MinZ, header.MaxZ)
+	}
+}
+
+// Passes in order the point records of the given las file to the given function in batches of the chunk size,
+// together with the index of their first point, decompressing them with the given number of goroutines if it is a
+// LAZ file. The records of a batch are overwritten by the next one once the function returns.
+func (lasFileLoader *LasFileLoader) readPointBatches(las *LasFile, workers int, consume func(first int, b []byte) error) error {
+	if las.Header.Compressed {
+		vlr, err := getLaszipVlr(las.VlrData)
+		if err != nil {
+			return err
+		}
+		// the chunk table position may be stored at the end of the file, which requires the file to be sought
+		var file io.ReaderAt = las.f
+		if handle, ok := las.f.(readOnlyHandle); ok {
+			file = handle.File
+		}
+		if lasFileLoader.Recovery == nil {
+			return laszip.DecompressBatches(file, int64(las.Header.OffsetToPoints), las.Header.NumberPoints, las.Header.PointRecordLength, vlr, workers, lasFileLoader.ChunkSize, lasFileLoader.Cancellation, consume)
+		}
+		skipped := false
+		recovering := func(first int, records []byte) error {
+			if skipped {
+				atomic.AddInt64(&lasFileLoader.Recovery.Recovered, int64(len(records)/las.Header.PointRecordLength))
+			}
+			return consume(first, records)
+		}
+		skip := func(first int, points int, err error) {
+			skipped = true
+			atomic.AddInt64(&lasFileLoader.Recovery.Skipped, int64(points))
+		}
+		return laszip.DecompressBatchesSkipping(file, int64(las.Header.OffsetToPoints), las.Header.NumberPoints, las.Header.PointRecordLength, vlr, workers, lasFileLoader.ChunkSize, lasFileLoader.Cancellation, recovering, skip)
+	}
+
+	chunkSize := lasFileLoader.ChunkSize
+	if chunkSize <= 0 || chunkSize > las.Header.NumberPoints {
+		chunkSize = las.Header.NumberPoints
+	}
+	// the batches of the files declaring more points than they hold are not larger than the file
+	if size, ok := getFileSize(las.f); ok && int64(chunkSize*las.Header.PointRecordLength) > size {
+		chunkSize = int(size)/las.Header.PointRecordLength + 1
+	}
+	b := make([]byte, chunkSize*las.Header.PointRecordLength)
+	for first := 0; first < las.Header.NumberPoints; first += chunkSize {
+		count := chunkSize
+		if first+count > las.Header.NumberPoints {
+			count = las.Header.NumberPoints - first
+		}
+		records := b[:count*las.Header.PointRecordLength]
+		n, err := las.f.ReadAt(records, int64(las.Header.OffsetToPoints)+int64(first*las.Header.PointRecordLength))
+		if err != nil && err != io.EOF {
+			return err
+		}
+		// the records missing from truncated files are skipped, along with the incomplete last one, if recovering
+		if n < len(records) {
+			if lasFileLoader.Recovery == nil {
+				return fmt.Errorf("truncated point data: the file holds %d of the %d points declared by the header", first+n/las.Header.PointRecordLength, las.Header.NumberPoints)
+			}
+			atomic.AddInt64(&lasFileLoader.Recovery.Skipped, int64(las.Header.NumberPoints-first-n/las.Header.PointRecordLength))
+			return consume(first, records[:n-n%las.Header.PointRecordLength])
+		}
+		if err := consume(first, records); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// Returns the LASzip VLR describing how the points of a LAZ file are compressed
+func getLaszipVlr(vlrs []VLR) (*laszip.Vlr, error) {
+	for _, vlr := range vlrs {
+		if vlr.UserID == laszip.VlrUserId && vlr.RecordID == laszip.VlrRecordId {
+			return laszip.ParseVlr(vlr.BinaryData)
+		}
+	}
+	return nil, errors.New("the points are compressed but the file has no LASzip VLR")
+}
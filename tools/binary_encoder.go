@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Byte order of the encoded values, either binary.LittleEndian or binary.BigEndian
+type ByteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// Encoder of binary contents in an explicit byte order, padding their sections to the alignment boundaries required by
+// the binary formats. The encoder records the first error, e.g. a length overflowing its 32 bit field or a section
+// that is not aligned as required, which is returned with the encoded bytes so that it is checked once per content.
+type BinaryEncoder struct {
+	order ByteOrder
+	bytes []byte
+	err   error
+}
+
+// Instantiates an encoder writing in the given byte order, preallocating the given number of bytes
+func NewBinaryEncoder(order ByteOrder, capacity int) *BinaryEncoder {
+	return &BinaryEncoder{order: order, bytes: make([]byte, 0, capacity)}
+}
+
+// Returns the number of bytes encoded so far, i.e. the offset of the next byte written
+func (e *BinaryEncoder) Len() int {
+	return len(e.bytes)
+}
+
+// Returns the encoded bytes and the first error occurred encoding them
+func (e *BinaryEncoder) Bytes() ([]byte, error) {
+	return e.bytes, e.err
+}
+
+func (e *BinaryEncoder) WriteBytes(bytes []byte) {
+	e.bytes = append(e.bytes, bytes...)
+}
+
+func (e *BinaryEncoder) WriteString(value string) {
+	e.bytes = append(e.bytes, value...)
+}
+
+func (e *BinaryEncoder) WriteUint32(value uint32) {
+	e.bytes = e.order.AppendUint32(e.bytes, value)
+}
+
+func (e *BinaryEncoder) WriteUint64(value uint64) {
+	e.bytes = e.order.AppendUint64(e.bytes, value)
+}
+
+// Writes the given length as an unsigned 32 bit integer, recording an error if it does not fit
+func (e *BinaryEncoder) WriteLength32(length int) {
+	e.WriteUint32(e.checkLength32(length))
+}
+
+func (e *BinaryEncoder) WriteFloat32s(values []float32) {
+	for _, value := range values {
+		e.bytes = e.order.AppendUint32(e.bytes, math.Float32bits(value))
+	}
+}
+
+// Writes the given values truncated to single precision floats
+func (e *BinaryEncoder) WriteFloat64sAsFloat32s(values []float64) {
+	for _, value := range values {
+		e.bytes = e.order.AppendUint32(e.bytes, math.Float32bits(float32(value)))
+	}
+}
+
+// Writes the given number of zero bytes, e.g. to hold a length known once the following sections are written,
+// returning their offset
+func (e *BinaryEncoder) Reserve(size int) int {
+	offset := len(e.bytes)
+	e.bytes = append(e.bytes, make([]byte, size)...)
+	return offset
+}
+
+// Overwrites the unsigned 32 bit integer at the given offset with the given length, recording an error if it does not
+// fit
+func (e *BinaryEncoder) PutLength32(offset int, length int) {
+	e.order.PutUint32(e.bytes[offset:], e.checkLength32(length))
+}
+
+// Overwrites the unsigned 64 bit integer at the given offset with the given value
+func (e *BinaryEncoder) PutUint64(offset int, value uint64) {
+	e.order.PutUint64(e.bytes[offset:], value)
+}
+
+// Appends the given padding byte until the encoded bytes end on a multiple of the given boundary
+func (e *BinaryEncoder) Pad(boundary int, padding byte) {
+	for len(e.bytes)%boundary != 0 {
+		e.bytes = append(e.bytes, padding)
+	}
+}
+
+// Records an error if the encoded bytes do not end on a multiple of the given boundary, where the section being
+// written has to start or end
+func (e *BinaryEncoder) RequireAlignment(boundary int, section string) {
+	if len(e.bytes)%boundary != 0 {
+		e.setError(fmt.Errorf("the %s is at offset %d, not aligned to %d bytes", section, len(e.bytes), boundary))
+	}
+}
+
+func (e *BinaryEncoder) checkLength32(length int) uint32 {
+	if length < 0 || int64(length) > math.MaxUint32 {
+		e.setError(fmt.Errorf("the length %d does not fit in 32 bits", length))
+		return 0
+	}
+	return uint32(length)
+}
+
+func (e *BinaryEncoder) setError(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
@@ -35,3 +35,11 @@ func ConvertTruncateFloat64ToFloat32ByteArray(inData []float64) []uint8 {
 	return outData
 }
 
+// Returns a byte array containing the little endian representation of the float32 values provided by the input slice
+func ConvertFloat32ToByteArray(inData []float32) []uint8 {
+	outData := make([]byte, len(inData)*4)
+	for i, value := range inData {
+		binary.LittleEndian.PutUint32(outData[i*4:], math.Float32bits(value))
+	}
+	return outData
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+)
+
+// A record of a comma separated file split in its leading text fields and the numeric values following them
+type CsvFloatRecord struct {
+	Text   []string
+	Values []float64
+}
+
+// Reads the records of a comma separated file holding the given number of columns, the first textColumns ones being
+// kept as text and the other ones parsed as numbers. Lines starting with # are ignored as well as a first line
+// containing the column names. The record name describes the records in the returned errors.
+func ReadCsvFloatRecords(filePath string, recordName string, columns int, textColumns int) ([]*CsvFloatRecord, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = columns
+
+	var records []*CsvFloatRecord
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values, err := parseFloats(record[textColumns:])
+		if err != nil {
+			if line == 1 {
+				// header line
+				continue
+			}
+			return nil, errors.New("invalid " + recordName + " record in " + filePath + ": " + err.Error())
+		}
+
+		records = append(records, &CsvFloatRecord{Text: record[:textColumns], Values: values})
+	}
+
+	return records, nil
+}
+
+func parseFloats(record []string) ([]float64, error) {
+	values := make([]float64, len(record))
+	for i, field := range record {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
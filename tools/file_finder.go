@@ -14,6 +14,9 @@ type FileFinder interface {
 
 type StandardFileFinder struct {}
 
+// extensions of the point cloud files the tiler is able to read
+var supportedInputExtensions = []string{".las", ".laz", ".bag", ".parquet", ".e57", ".ply", ".pcd"}
+
 func NewStandardFileFinder() FileFinder {
 	return &StandardFileFinder{}
 }
@@ -22,7 +25,7 @@ func (f *StandardFileFinder) GetLasFilesToProcess(opts *tiler.TilerOptions) []st
 	// If folder processing is not enabled then las file is given by -input flag, otherwise look for las in -input folder
 	// eventually excluding nested folders if Recursive flag is disabled
 	if !opts.FolderProcessing {
-		return []string{opts.Input}
+		return tiler.GetInputPaths(opts)
 	}
 
 	return f.getLasFilesFromInputFolder(opts)
@@ -38,7 +41,7 @@ func (f *StandardFileFinder) getLasFilesFromInputFolder(opts *tiler.TilerOptions
 			if info.IsDir() && !opts.Recursive && !os.SameFile(info, baseInfo) {
 				return filepath.SkipDir
 			} else {
-				if strings.ToLower(filepath.Ext(info.Name())) == ".las" {
+				if isSupportedInputFile(info.Name()) || tiler.GetReaderPlugin(opts.ReaderPlugins, info.Name()) != nil || (len(opts.BridgeCommand) > 0 && !info.IsDir()) {
 					lasFiles = append(lasFiles, path)
 				}
 			}
@@ -53,3 +56,12 @@ func (f *StandardFileFinder) getLasFilesFromInputFolder(opts *tiler.TilerOptions
 	return lasFiles
 }
 
+func isSupportedInputFile(fileName string) bool {
+	extension := strings.ToLower(filepath.Ext(fileName))
+	for _, supportedExtension := range supportedInputExtensions {
+		if extension == supportedExtension {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,24 @@ package tools
 
 import (
 	"flag"
+	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/tiler"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
 )
 
+// Prefix of the environment variables setting the flags not given on the command line, named after the flags in upper
+// case with underscores, e.g. GOCESIUMTILER_GRID_MIN_SIZE for grid-min-size
+const EnvironmentPrefix = "GOCESIUMTILER_"
+
+// Subcommand serving the folder given as argument over HTTP, e.g. gocesiumtiler serve output, instead of tiling
+const ServeCommand = "serve"
+
+// Address the serve subcommand listens on unless the serve flag gives another one
+const DefaultServeAddress = ":8080"
+
 type Flags struct {
 	Input                     *string
 	Output                    *string
@@ -22,28 +38,303 @@ type Flags struct {
 	Help                      *bool
 	Version                   *bool
 	RootGeometricError		  *float64
+	CoordinateFrame           *string
+	ContentExtension          *string
+	ExtensionlessContent      *bool
+	HostConfig                *bool
+	RosCloudTopic             *string
+	RosPoseTopic              *string
+	TrajectoryFile            *string
+	TerrainFile               *string
+	TerrainSrid               *int
+	OriginSnap                *string
+	StatsFinal                *bool
+	Tui                       *bool
+	MaxOpenFiles              *int
+	StallTimeout              *float64
+	StallAbort                *bool
+	ReadWorkers               *int
+	ConvertWorkers            *int
+	InsertWorkers             *int
+	WriteWorkers              *int
+	TileMetadata              *bool
+	ThumbnailSize             *int
+	Coverage                  *bool
+	Stac                      *bool
+	StacCollection            *bool
+	GeoVolumes                *bool
+	LevelRetention            *string
+	SkipDuplicates            *bool
+	ControlPointsFile         *string
+	ControlPointsSrid         *int
+	ControlPointsTolerance    *float64
+	ExcludeOverlap            *bool
+	Returns                   *string
+	ScannerChannel            *int
+	PruneScreenError          *float64
+	PruneDistance             *float64
+	Compression               *string
+	ZstdDictionary            *bool
+	Serve                     *string
+	DeduplicateTiles          *bool
+	UriTemplate               *string
+	ContentBaseUrl            *string
+	MaxDirectoryEntries       *int
+	Availability              *bool
+	SourceColors              *bool
+	ElevationPipeline         *string
+	ConverterCacheSize        *int
+	ConverterCacheQuantum     *float64
+	RootPercentile            *float64
+	LeafPointCap              *int
+	LeafCapPolicy             *string
+	WithheldPoints            *string
+	SyntheticPoints           *string
+	KeyPoints                 *string
+	Alpha                     *string
+	AlphaMin                  *float64
+	GhostFilter               *bool
+	GhostVoxelSize            *float64
+	GhostMaxDepth             *float64
+	BatchTable                *string
+	LegacyTables              *bool
+	RunMetadata               *bool
+	ClassLayers               *bool
+	InvalidColors             *string
+	SidecarFolder             *string
+	SidecarKey                *string
+	ParquetColumns            *string
+	ParquetExport             *bool
+	WebhookUrl                *string
+	WebhookSecret             *string
+	ReaderPlugins             *string
+	Bridge                    *string
+	SpoolFolder               *string
+	Quarantine                *bool
+	ValidityArea              *string
+	ErrorPolicy               *string
+	ReadChunkSize             *int
+	License                   *string
+	LicenseUrl                *string
+	Watermark                 *string
+	VerifyWatermark           *string
+	TilesetVersion            *string
+	SizeBudget                *string
+	Draco                     *bool
+	DracoQuantization         *string
+	CoarseFirst               *bool
+	BundleThreshold           *string
+	BundleMaxSize             *string
+	ImplicitTiling            *bool
+	SubtreeLevels             *int
+	SplitTileSize             *string
+	Merge                     *bool
+	Generate                  *string
+	GenerateExtent            *float64
+	GenerateDensity           *float64
+	GenerateBuildings         *int
+	GenerateNoise             *float64
+	GenerateSeed              *int
+	GenerateOrigin            *string
+	RecoverRecords            *bool
+	ColorSource               *string
+	ColorRamp                 *string
+	LasAttributes             *string
+	Resume                    *bool
+	SelfUpdate                *bool
+	CheckUpdate               *bool
+	Target                    *string
+	ZonalStats                *string
+	ZonalTileset              *string
+	Profile                   *string
+	ProfileBuffer             *float64
+	Crs                       *string
+	CrsBackend                *string
+	GeoidModel                *string
+	Nearest                   *string
+	NearestCount              *int
+	NearestLevel              *int
+	DensityTarget             *float64
+	DensityMode               *string
+	DensityCellSize           *float64
+	GridSpool                 *bool
+	GridSampling              *string
+	ScanIds                   *bool
+	ScanPoses                 *string
+	WriterConcurrency         *int
+	IntensityStretch          *string
+	GridSamplingAttribute     *string
+	TreeDump                  *string
+	InspectTree               *string
+	Wireframe                 *bool
 }
 
 func ParseFlags() Flags {
-	input := defineStringFlag("input", "i", "", "Specifies the input las file/folder.")
-	output := defineStringFlag("output", "o", "", "Specifies the output folder where to write the tileset data.")
+	input := defineStringFlag("input", "i", "", "Specifies the input las/laz file/folder.")
+	output := defineStringFlag("output", "o", "", "Specifies the output folder where to write the tileset data, or the url of the object store where to upload it, either s3://bucket/prefix, gs://bucket/prefix or az://container/prefix.")
 	srid := defineIntFlag("srid", "e", 4326, "EPSG srid code of input points.")
 	zOffset := defineFloat64Flag("zoffset", "z", 0, "Vertical offset to apply to points, in meters.")
-	maxNumPts := defineIntFlag("maxpts", "m", 50000, "Max number of points per tile for the Random and RandomBox algorithms.")
+	maxNumPts := defineIntFlag("maxpts", "m", 50000, "Max number of points per tile for the Random, RandomBox and TwoPass algorithms.")
 	zGeoidCorrection := defineBoolFlag("geoid", "g", false, "Enables Geoid to Ellipsoid elevation correction. Use this flag if your input LAS files have Z coordinates specified relative to the Earth geoid rather than to the standard ellipsoid.")
-	folderProcessing := defineBoolFlag("folder", "f", false, "Enables processing of all las files from input folder. Input must be a folder if specified")
-	recursiveFolderProcessing := defineBoolFlag("recursive", "r", false, "Enables recursive lookup for all .las files inside the subfolders")
+	folderProcessing := defineBoolFlag("folder", "f", false, "Enables processing of all las/laz files from input folder. Input must be a folder if specified")
+	recursiveFolderProcessing := defineBoolFlag("recursive", "r", false, "Enables recursive lookup for all .las/.laz files inside the subfolders")
 	silent := defineBoolFlag("silent", "s", false, "Use to suppress all the non-error messages.")
 	logTimestamp := defineBoolFlag("timestamp", "t", false, "Adds timestamp to log messages.")
-	algorithm := defineStringFlag("algorithm", "a", "grid", "Sets the algorithm to use. Must be one of Grid,Random,RandomBox. Grid algorithm is highly suggested, others are deprecated and will be removed in future versions.")
+	algorithm := defineStringFlag("algorithm", "a", "grid", "Sets the algorithm to use. Must be one of Grid,Random,RandomBox,TwoPass. TwoPass reads the input twice keeping the points on disk, for point clouds exceeding the memory. Grid algorithm is highly suggested, others are deprecated and will be removed in future versions.")
 	gridCellMaxSize := defineFloat64Flag("grid-max-size", "x", 5.0, "Max cell size in meters for the grid algorithm. It roughly represents the max spacing between any two samples. ")
 	gridCellMinSize := defineFloat64Flag("grid-min-size", "n", 0.15, "Min cell size in meters for the grid algorithm. It roughly represents the minimum possible size of a 3d tile. ")
 	refineMode := defineStringFlag("refine-mode", "", "ADD", "Type of refine mode, can be 'ADD' or 'REPLACE'. 'ADD' means that child tiles will not contain the parent tiles points. 'REPLACE' means that they will also contain the parent tiles points. ADD implies less disk space but more network overhead when fetching the data, REPLACE is the opposite.")
 	help := defineBoolFlag("help", "h", false, "Displays this help.")
 	version := defineBoolFlag("version", "v", false, "Displays the version of gocesiumtiler.")
 	rootGeometricError := defineFloat64Flag("root-geometric-error", "k", 1, "Multiplies the geometric error of the root by the given factor. Use this flag if you want to display the tiles in higher zoom levels") 
+	coordinateFrame := defineStringFlag("frame", "", "ECEF", "Reference frame of the points written in the tiles, can be 'ECEF' or 'LOCAL'. 'ECEF' bakes Earth-Centered Earth-Fixed coordinates into every tile. 'LOCAL' writes points in an East-North-Up frame centered on the point cloud and adds the transform matrix to the root tile, granting better precision and editability in several engines.")
+	contentExtension := defineStringFlag("content-extension", "", ".pnts", "Extension of the tile content files, .glb by default for 3D Tiles 1.1 tilesets. Use it for hosts that mishandle the .pnts extension.")
+	extensionlessContent := defineBoolFlag("extensionless", "", false, "Writes the tile content files without extension and declares their content type in the tileset.json file.")
+	rosCloudTopic := defineStringFlag("ros-cloud-topic", "", "", "Topic of the sensor_msgs/PointCloud2 messages to read from ROS bag inputs. If empty all PointCloud2 topics are read.")
+	rosPoseTopic := defineStringFlag("ros-pose-topic", "", "", "Topic of the geometry_msgs/PoseStamped, geometry_msgs/TransformStamped or nav_msgs/Odometry messages used to move ROS bag clouds from the sensor frame to the map frame. If empty clouds are assumed to be already in the map frame.")
+	trajectoryFile := defineStringFlag("trajectory", "", "", "Trajectory file used to georeference raw points using their GPS time. Either a pose CSV (.csv, .txt) with time,x,y,z,qx,qy,qz,qw records expressed in the input srid or an SBET file (.out, .sbet) producing EPSG:4326 coordinates. LAS inputs must use a point format storing the GPS time, i.e. any format but 0 and 2.")
+	terrainFile := defineStringFlag("terrain", "", "", "ESRI ASCII grid (.asc) DEM used to shift vertically the point cloud so that it sits on the terrain. DEM heights should share the vertical reference of the terrain used in Cesium. The applied offset is written in the root tileset.json asset extras.")
+	terrainSrid := defineIntFlag("terrain-srid", "", 4326, "EPSG srid code of the terrain DEM coordinates.")
+	originSnap := defineStringFlag("origin-snap", "", "NONE", "Sets how the root bounding box of the grid algorithm is placed. Must be one of NONE, GRID, CENTROID. GRID aligns the tree nodes to the grid cells, CENTROID centers the tree on the points centroid. Combine with -frame LOCAL to carry the origin in the root transform.")
+	statsFinal := defineBoolFlag("stats-final", "", false, "Prints the final statistics of the job, i.e. peak memory, points read and kept, per level retention rates and time per phase, and writes them in a stats.json file in the output folder.")
+	tui := defineBoolFlag("tui", "", false, "Shows a live terminal dashboard with per level node counts, points/s, memory and a density map of the loaded points instead of the log. Type p and enter to pause or resume the loading, q and enter to abort the job after the current file.")
+	maxOpenFiles := defineIntFlag("max-open-files", "", 0, "Maximum number of files read or written at the same time. If 0 half of the open file descriptors limit of the process is used. Lower it if tiling fails with too many open files errors.")
+	stallTimeout := defineFloat64Flag("stall-timeout", "", 0, "Minutes without progress, i.e. without points loaded or files read or written, after which the job is considered stalled and the stacks of all goroutines are dumped in a stall-<time>.txt file in the output folder. Should exceed the duration of the longest tree build. Disabled if 0.")
+	stallAbort := defineBoolFlag("stall-abort", "", false, "Aborts the job when a stall is detected. Requires -stall-timeout.")
+	readWorkers := defineIntFlag("read-workers", "", 0, "Number of goroutines decoding the points of LAS files. If 0 one per CPU is used.")
+	convertWorkers := defineIntFlag("convert-workers", "", 0, "Number of goroutines converting the coordinates of the loaded points. If 0 the conversion is done by the goroutines reading the points.")
+	insertWorkers := defineIntFlag("insert-workers", "", 0, "Number of goroutines inserting the points in the tree. If 0 one per CPU is used.")
+	writeWorkers := defineIntFlag("write-workers", "", 0, "Number of goroutines writing the tiles. If 0 one per CPU is used. Lower it on slow disks or network shares.")
+	tileMetadata := defineBoolFlag("tile-metadata", "", false, "Attaches to every tile the point count, min, max and mean elevation and classification histogram of its points as 3D Tiles 1.1 metadata, so that tiles can be styled or picked without loading their content.")
+	thumbnailSize := defineIntFlag("thumbnail-size", "", 0, "Size in pixels of the top-down PNG thumbnail rendered for every tile in the thumbnails folder of the output, mirroring the tilesets structure. Disabled if 0.")
+	coverage := defineBoolFlag("coverage", "", false, "Writes alongside every tileset a coverage.geojson and a coverage.kml file with the footprint of the points and the area covered by every tree level.")
+	stacItem := defineBoolFlag("stac", "", false, "Writes alongside every tileset a STAC item.json file with its spatial and temporal extent, point count and asset links.")
+	stacCollection := defineBoolFlag("stac-collection", "", false, "Writes in the output folder a STAC collection.json file listing the items of all the tilesets. Implies -stac.")
+	geoVolumes := defineBoolFlag("geovolumes", "", false, "Writes in the geovolumes folder of the output the landing page, conformance and collections documents of the OGC API 3D GeoVolumes, linking the tilesets as 3D containers.")
+	levelRetention := defineStringFlag("level-retention", "", "", "Comma separated fractions of the points to sample in each of the top levels of the grid tree, starting from the root, e.g. 0.001,0.005,0.02. The remaining points are distributed by the grid cells in the levels below.")
+	skipDuplicates := defineBoolFlag("skip-duplicates", "", false, "Skips the input files whose content is identical to the one of a file already processed in the output folder, even under a different name. Processed and skipped files are recorded in the ledger.json file of the output folder.")
+	controlPointsFile := defineStringFlag("control-points", "", "", "CSV file of control points with id,x,y,z,expected_x,expected_y,expected_z records, transformed before tiling to write the control_points.json report of their residuals in the output folder.")
+	controlPointsSrid := defineIntFlag("control-points-srid", "", 4326, "EPSG srid code of the expected coordinates of the control points, e.g. 4326 for WGS84 ellipsoidal heights or 4978 for ECEF.")
+	controlPointsTolerance := defineFloat64Flag("control-points-tolerance", "", 0, "Max residual in meters allowed for the control points, the job is aborted if exceeded. If 0 residuals are only reported.")
+	excludeOverlap := defineBoolFlag("exclude-overlap", "", false, "Discards the LAS points flagged as overlap, or classified as overlap (12) in point formats 0 to 5.")
+	returns := defineStringFlag("returns", "", "ALL", "Returns of every pulse to load from LAS files, can be 'ALL', 'FIRST' or 'LAST'.")
+	scannerChannel := defineIntFlag("scanner-channel", "", -1, "Scanner channel (0-3) of the points to load from LAS files with point formats 6 to 10. If negative all channels are loaded.")
+	pruneScreenError := defineFloat64Flag("prune-sse", "", 0, "Max screen-space error in pixels the tileset is viewed with, e.g. 16 for Cesium. If positive the leaf tiles that would never be requested, as their parent is never refined when viewed from prune-distance or farther, are merged into their parent. Disabled if 0.")
+	pruneDistance := defineFloat64Flag("prune-distance", "", 10, "Min distance in meters the tileset is viewed from, used by prune-sse. Lower it to keep the detail beyond the sensor precision.")
+	compression := defineStringFlag("compression", "", "NONE", "Compression of the tile content files, can be 'NONE' or 'ZSTD'. Compressed contents have to be decoded by the host serving the tilesets, e.g. with -serve.")
+	zstdDictionary := defineBoolFlag("zstd-dict", "", false, "Trains a zstd dictionary from a sample of the tiles of every tileset and compresses all its tiles with it, writing it in the tiles.dict file of the tileset folder. Small tiles compress far better. Requires -compression ZSTD.")
+	serve := defineStringFlag("serve", "", "", "Serves the output folder over HTTP on the given address, e.g. :8080, along with a CesiumJS viewer of its tilesets, decoding the zstd compressed tile contents with the dictionaries found in it, instead of tiling. Also available as the serve subcommand, e.g. gocesiumtiler serve <output folder>.")
+	deduplicateTiles := defineBoolFlag("dedup-tiles", "", false, "Writes byte-identical tile contents only once per tileset, rewriting the uris of the tileset.json files so that all their tiles share the same file.")
+	config := defineStringFlag("config", "", "", "Configuration file setting the flags given neither on the command line nor in the environment, one name=value line per flag, e.g. srid=32633, blank lines and lines starting with # being ignored.")
+	wireframe := defineBoolFlag("wireframe", "", false, "Writes alongside every tileset the wireframe/tileset.json companion tileset drawing the bounding boxes of the nodes of the tree as glTF lines colored by depth, a tile per level refining the previous one as the point tiles, to inspect the structure of the tree and the levels of detail in the viewers.")
+	inspectTree := defineStringFlag("inspect-tree", "", "", "Reloads the tree dump written by -tree-dump at the given path, either tree.json or tree.bin, and prints the number of nodes, points and cells of every level of the tree instead of tiling.")
+	treeDump := defineStringFlag("tree-dump", "", "NONE", "Dumps the structure of every built tree alongside its tileset, i.e. the bounds, geometric error and number of points of every node and the size and number of cells of the grid nodes, without the points. Can be 'NONE', 'JSON' for a tree.json file or 'BINARY' for a compact tree.bin file.")
+	gridSamplingAttribute := defineStringFlag("grid-sampling-attribute", "", "intensity", "Attribute of the points maximized by -grid-sampling MAX, either intensity, e.g. to keep the brightest returns in the coarse levels, or one of the -las-attributes.")
+	intensityStretch := defineStringFlag("intensity-stretch", "", "2,98", "Low and high percentiles of the intensities stretched over the color ramp when -color-source AUTO colors by intensity the files storing no RGB, computed in a first read of every file. The intensities below and above them get the colors of the ends of the ramp.")
+	writerConcurrency := defineIntFlag("writer-concurrency", "", 0, "Number of goroutines writing the tile files to the output, so that the file system writes overlap with the serialization of the next tiles by the write workers, which block when 4 files per goroutine are waiting to be written. If 0 the write workers write the files themselves. Raise it on fast NVMe disks or object storage mounts. Cannot be combined with -coarse-first.")
+	scanIds := defineBoolFlag("scan-ids", "", false, "Tiles the merged input files as registered scans, e.g. the per-scan LAS files of a terrestrial survey, writing the index of the file of every point in the scan_id property of the batch tables and listing the scans with their point counts in the extras of the root tileset, so that the viewers can toggle and check every scan. Requires -merge.")
+	scanPoses := defineStringFlag("scan-poses", "", "", "CSV file of the registration poses of the scans written along with them in the extras of the root tileset, whose records hold the name of the scan file, with or without extension, the position of the scanner and its orientation quaternion in the input srid: scan,x,y,z,qx,qy,qz,qw. Requires -scan-ids.")
+	gridSampling := defineStringFlag("grid-sampling", "", "CENTER", "Point kept by every cell of the grid algorithm, one of CENTER for the point closest to the center of the cell, RANDOM for a random point among the ones it receives, which avoids the regular patterns of the coarse levels of detail, POISSON to keep the points spaced at least a cell size apart, a Poisson-disk sampling of the coarse levels, FIRST for the first point it receives or MAX for the point of highest -grid-sampling-attribute.")
+	gridSpool := defineBoolFlag("grid-spool", "", false, "Tiles with the grid algorithm point clouds larger than the memory, reading every file twice and spooling the points of the tiles to temporary files in spool-folder, so that the memory taken depends on the extent of the cloud rather than on its number of points.")
+	densityTarget := defineFloat64Flag("density-target", "", 0, "Max density of the points tiled by the grid algorithm, in points per square meter, or per cubic meter if density-mode is VOLUME. The cells of density-cell-size meters holding more points keep a random subsample of them, so that merged clouds of uneven density, e.g. terrestrial and aerial scans, are tiled with a uniform density. No cap if 0.")
+	densityMode := defineStringFlag("density-mode", "", "AREA", "Unit of density-target, either AREA for points per square meter of ground, counted in columns spanning all the heights, or VOLUME for points per cubic meter, counted in cubes.")
+	densityCellSize := defineFloat64Flag("density-cell-size", "", 1, "Size in meters of the cells density-target is enforced in, each keeping at most density-target times its area or volume points.")
+	nearest := defineStringFlag("nearest", "", "", "Prints as CSV the -nearest-count points closest to the given position, a comma separated longitude, latitude and ellipsoidal height, of the tileset whose tileset.json file, or folder holding it, is given as input, instead of tiling.")
+	nearestCount := defineIntFlag("nearest-count", "", 1, "Number of points printed by -nearest.")
+	nearestLevel := defineIntFlag("nearest-level", "", -1, "Depth of the deepest tiles searched by -nearest, the root being at depth 0, i.e. the level of detail of the points picked. If negative all the tiles are searched.")
+	geoidModel := defineStringFlag("geoid-model", "", "EGM84", "Geoid model of the geoid correction, either EGM84, computed from the bundled coefficients, EGM96, EGM2008 or GEOID18, whose egm96_15.gtx, egm08_25.gtx and g2018u0.bin grids are looked for in the assets/geoids folder, or the path of a GTX (.gtx) or NGS binary (.bin) geoid grid.")
+	crs := defineStringFlag("crs", "", "", "Definition of the coordinate reference system of the input points, replacing the one of the srid code, e.g. a PROJ.4 string like +proj=tmerc +lat_0=0 +lon_0=9 +k=0.9996 +x_0=1500000 +y_0=0 +ellps=intl +units=m. The proj backend also accepts WKT, PROJJSON and compound codes like EPSG:32633+5773.")
+	crsBackend := defineStringFlag("crs-backend", "", "proj4", "Coordinate converter backend, either proj4 for the bundled PROJ.4 library or proj for the system PROJ library, available if the tiler is built with the proj tag.")
+	profile := defineStringFlag("profile", "", "", "Extracts the vertical profile of the points within -profile-buffer meters of the given line, a semicolon separated list of longitude,latitude pairs, from the tileset whose tileset.json file, or folder holding it, is given as input, writing it in the profile.csv and profile.svg files of the output folder instead of tiling.")
+	profileBuffer := defineFloat64Flag("profile-buffer", "", 1, "Max horizontal distance in meters from the -profile line of the points of the profile.")
+	zonalStats := defineStringFlag("zonal-stats", "", "", "GeoJSON file of polygons, in EPSG:4326 coordinates, whose point count, area, density, mean and max elevation and count of points per classification are written alongside every tileset in the zonal.geojson and zonal.csv files.")
+	zonalTileset := defineStringFlag("zonal-tileset", "", "", "Computes the -zonal-stats statistics over the points of the tileset at the given path, either its tileset.json file or its folder, instead of tiling, writing them in the output folder.")
+	target := defineStringFlag("target", "", "", "Client the tilesets are generated for, as <client>@<version>, e.g. cesium@1.115 or unreal-plugin@2.x, the client being cesium, unreal or unity. The features the client does not support are disabled with a warning, falling back to their 3D Tiles 1.0 equivalents, and the job fails if the client cannot render point clouds.")
+	selfUpdate := defineBoolFlag("self-update", "", false, "Replaces the executable with the binary of the latest release, if newer, verifying its Ed25519 signature with the release public key built into the tool. The builds without a release public key cannot update themselves.")
+	checkUpdate := defineBoolFlag("check-update", "", false, "Checks whether a newer release of the tool is available before running the job, logging a notice if so. The job runs anyway if the check fails.")
+	resume := defineBoolFlag("resume", "", false, "Records the progress of the job in the checkpoint.json file of the output folder and, if the file exists, resumes the interrupted job that wrote it, skipping the input files whose tilesets are complete. The file being tiled when the job was interrupted is tiled again from the start. The options must be the ones of the interrupted job, but for the number of goroutines.")
+	lasAttributes := defineStringFlag("las-attributes", "", "", "Comma separated list of LAS point attributes written as float properties in the batch tables, or in the property tables of the glb contents, so that the points can be styled by them, e.g. return_number,gps_time. Supports return_number, number_of_returns, gps_time, as seconds of the GPS week, scanner_channel and normal_x, normal_y and normal_z, read from the PLY and PCD files.")
+	colorSource := defineStringFlag("color-source", "", "AUTO", "Source of the colors of the points, the colors of the input files or their intensity mapped along the color ramp, e.g. for the clouds lacking RGB. AUTO uses the colors of the LAS files whose point format stores RGB and the intensity stretched by -intensity-stretch for the other ones. Must be one of RGB, INTENSITY, AUTO.")
+	colorRamp := defineStringFlag("color-ramp", "", "", "Comma separated list of at least two hex colors the intensity of the points is mapped to when they are colored by intensity, from the lowest to the highest intensity, e.g. #000080,#00ff00,#ff0000. The colors are interpolated and the ramp is grayscale if empty.")
+	recoverRecords := defineBoolFlag("recover-records", "", false, "Skips the malformed point records of LAS and LAZ files rather than failing the file: the records whose coordinates fall outside the bounds of the header, the records missing from truncated files and the records of the LAZ chunks that cannot be decompressed, the reading resuming at the next chunk. The numbers of skipped and recovered records are reported.")
+	generate := defineStringFlag("generate", "", "", "Writes a synthetic LAS file at the given path and exits, made of a rolling terrain, buildings and noise points in the coordinate system of the srid flag, for testing and benchmarking without real data. The same generate flags always produce the same file.")
+	generateExtent := defineFloat64Flag("generate-extent", "", 500, "Size in meters of the side of the square area covered by the synthetic LAS file.")
+	generateDensity := defineFloat64Flag("generate-density", "", 4, "Number of terrain and roof points per square meter of the synthetic LAS file.")
+	generateBuildings := defineIntFlag("generate-buildings", "", 20, "Number of buildings of the synthetic LAS file.")
+	generateNoise := defineFloat64Flag("generate-noise", "", 0.001, "Fraction of noise points added to the terrain and roof points of the synthetic LAS file.")
+	generateSeed := defineIntFlag("generate-seed", "", 1, "Seed of the random generator of the synthetic LAS file, different seeds producing different terrains and buildings.")
+	generateOrigin := defineStringFlag("generate-origin", "", "", "Coordinates of the center of the synthetic LAS file in the coordinate system of the srid flag, as x,y. If empty a point in central Italy for geographic coordinate systems, 500000,4600000 for projected ones.")
+	merge := defineBoolFlag("merge", "", false, "Merges the points of all the input files, i.e. the files of the input folder or the files of the input flag separated by the OS path list separator, e.g. a.las:b.las, into a single tileset named merged instead of writing a tileset per file.")
+	splitTileSize := defineStringFlag("split-tile-size", "", "", "Max estimated content size of the tiles, e.g. 4MB. Once the tree is built the larger tiles, e.g. the leaves of dense pockets reaching the min cell size, are split by pushing the points exceeding the size down to new child tiles, and the number of split tiles is reported. Only the tiles exceeding the 4 GiB that the tile formats can describe are split if empty.")
+	implicitTiling := defineBoolFlag("implicit-tiling", "", false, "Declares the tiles of every tileset with 3D Tiles 1.1 implicit tiling rather than with nested tileset.json files: a single tileset.json file holds the implicit root of the octree, the availability of the tiles is written in binary subtree files in the subtrees folder and the tile contents in the content folder, named after the level and the coordinates of the tiles. Only supported by the GRID algorithm.")
+	subtreeLevels := defineIntFlag("subtree-levels", "", 5, "Number of levels of the octree spanned by every subtree file of the implicit tilesets, from 1 to 8.")
+	bundleThreshold := defineStringFlag("bundle-threshold", "", "", "Max estimated content size of the leaf tiles bundled with their small siblings, e.g. 32KB. Bundles are written as cmpt composite tiles in 3D Tiles 1.0 tilesets and as single glb contents in 1.1 ones, so that the dense regions made of many tiny leaf tiles are loaded with fewer requests. No tiles are bundled if empty.")
+	bundleMaxSize := defineStringFlag("bundle-max-size", "", "1MB", "Max estimated content size of every bundle of leaf tiles, e.g. 1MB or 512KiB. Only applies with bundle-threshold.")
+	coarseFirst := defineBoolFlag("coarse-first", "", false, "Writes the tiles of every tileset level by level starting from the root, each level once the previous one is written, so that a partially written or uploaded tileset is already viewable at its coarse levels. The written levels are recorded in a manifest.json file in the tileset folder, marked complete once the tileset is.")
+	draco := defineBoolFlag("draco", "", false, "Compresses the points of the pnts tile contents with Draco, through the 3DTILES_draco_point_compression extension, declared as required in the tileset.json files. Only applies to 3D Tiles 1.0 tilesets with binary batch tables.")
+	dracoQuantization := defineStringFlag("draco-quantization", "", "", "Comma separated list of attribute=bits pairs giving the number of bits the attributes of the Draco compressed points are quantized to, e.g. POSITION=16,COLOR=6,INTENSITY=8,gps_time=24. POSITION accepts 1 to 30 bits and defaults to 14, COLOR and INTENSITY accept 1 to 8 bits, the supplementary attributes 1 to 30 bits, the attributes not listed being stored losslessly.")
+	sizeBudget := defineStringFlag("size-budget", "", "", "Max size of the tile contents and tileset.json files of every tileset, e.g. 20GB or 512MiB. The points of the levels of the tree are thinned to fit in it, keeping the coarse levels whole and decimating the most populated ones, and the achieved distribution of the points by level is reported. The size is estimated before compression.")
+	tilesetVersion := defineStringFlag("tileset-version", "", "1.0", "Version of the 3D Tiles specification of the tilesets, 1.0 or 1.1. 1.1 tilesets store the tile contents as glb files with point primitives, with the intensity, classification and supplementary attributes of the points in a property table of the EXT_structural_metadata extension referenced by the feature ids of the EXT_mesh_features extension, rather than as pnts files.")
+	license := defineStringFlag("license", "", "", "Name of the license of the tilesets, e.g. CC-BY-4.0 or Evaluation only, recorded in the extras of the asset of their root tileset.json.")
+	licenseUrl := defineStringFlag("license-url", "", "", "Url of the text of the license, recorded along with its name. Requires -license.")
+	watermark := defineStringFlag("watermark", "", "", "Owner id encoded by sparse watermark points injected in the tilesets, about one every 10000 points, at positions and with colors derived from a keyed hash of the owner id, so that the tilesets can be recognized with -verify-watermark.")
+	verifyWatermark := defineStringFlag("verify-watermark", "", "", "Searches the watermark points of the given owner id in the tileset whose tileset.json file, or folder holding it, is given as input, exiting with an error if they are not found.")
+	readChunkSize := defineIntFlag("read-chunk-size", "", 1000000, "Number of points of LAS and LAZ files read and decoded at a time, bounding the memory holding the point records, LAZ files being read by whole compressed chunks. If 0 all the points of a file are read at once.")
+	errorPolicy := defineStringFlag("error-policy", "", "FAIL", "Policy of the recoverable errors: FAIL aborts the run at the first one, CONTINUE logs it and skips what caused it, i.e. the input file that cannot be read or tiled or the point whose coordinates cannot be transformed, listing the failed files at the end of the run, which fails only if no file could be tiled.")
+	quarantine := defineBoolFlag("quarantine", "", false, "Checks that the transformed coordinates of every point are a position on the Earth, e.g. not NaN or beyond the poles because of a wrong srid, listing the points failing the check in the quarantine/<tileset>.csv report instead of tiling them.")
+	validityArea := defineStringFlag("validity-area", "", "", "Validity area of the srid as min longitude, min latitude, max longitude and max latitude in degrees, e.g. 12,46,18,48. The points whose transformed coordinates fall outside of it are quarantined. Implies -quarantine.")
+	spoolFolder := defineStringFlag("spool-folder", "", "", "Folder where the TwoPass algorithm and grid-spool write the temporary files holding the points of the tiles, removed once the tileset is exported. The system temporary folder is used if empty.")
+	bridge := defineStringFlag("bridge", "", "", "External program reading every input file whatever its format, e.g. python3 pdal_bridge.py {input} to read all the formats supported by PDAL. The file path replaces the {input} argument, or is appended, and the program writes the points on its standard output with the GCTP stream protocol.")
+	readerPlugins := defineStringFlag("reader-plugins", "", "", "External programs reading the point cloud files of other formats, e.g. built with a vendor SDK, as semicolon separated extension=command pairs, e.g. .rdbx=rdb2gctp --all. The command is run with the file path as {input} or last argument and writes the points on its standard output with the GCTP stream protocol.")
+	webhookUrl := defineStringFlag("webhook", "", "", "Url a JSON notification is posted to when the job completes or fails, holding its outcome, statistics and, with -run-metadata, run metadata. Its text field makes it displayable by Slack incoming webhooks.")
+	webhookSecret := defineStringFlag("webhook-secret", "", "", "Secret the webhook notifications are signed with, the X-Gocesiumtiler-Signature header holding sha256= followed by the hex HMAC-SHA256 of the body. Read from the GOCESIUMTILER_WEBHOOK_SECRET environment variable if empty.")
+	parquetExport := defineBoolFlag("parquet-export", "", false, "Also writes the points of every tileset as a Parquet dataset partitioned by tile in its parquet folder, one tile=<key>/points.parquet file per tile, with their EPSG:4326 coordinates, colors, intensity, classification, level and sidecar attributes, to be queried with DuckDB or Spark without reading the inputs again.")
+	parquetColumns := defineStringFlag("parquet-columns", "", "", "Columns of the Parquet inputs the point fields are read from, as comma separated field=column pairs, e.g. x=easting,y=northing,z=height. Fields are x, y, z, r, g, b, intensity, classification and gps_time, read by default from the columns of the same name, ignoring the case.")
+	sidecarFolder := defineStringFlag("sidecar", "", "", "Folder of the CSV tables of supplementary attributes, e.g. segment ids computed by external classifiers, named after the LAS input files with the csv extension. The first column of a table is the key of the points and the other ones, named in the header line, are written as float properties in the batch tables.")
+	sidecarKey := defineStringFlag("sidecar-key", "", "INDEX", "Key the sidecar records are joined to the points by, their zero based index in the input file or their GPS time. Must be one of INDEX, GPS_TIME.")
+	invalidColors := defineStringFlag("invalid-colors", "", "KEEP", "Handling of the colors of the input files detected as invalid, i.e. all black, constant or too dark to carry any information. KEEP writes them as they are, OMIT drops the colors from the tiles, saving 3 bytes per point, INTENSITY replaces them with the intensity as grayscale. Must be one of KEEP, OMIT, INTENSITY.")
+	classLayers := defineBoolFlag("class-layers", "", false, "Tiles the points of every classification in its own tileset, e.g. ground/tileset.json and buildings/tileset.json, written in the folder of the input file along with an overview tileset.json referencing all of them as external tilesets, so that viewers can load the layers selectively.")
+	runMetadata := defineBoolFlag("run-metadata", "", false, "Writes in the output folder a run.json file holding the tool version, the resolved options, the SHA-256 checksums of the inputs and the timing of the job, and embeds them in the extras of the root tilesets, so that they can be reproduced or audited.")
+	batchTable := defineStringFlag("batch-table", "", "BINARY", "Encoding of the intensity and classification batch table properties of the tile contents, can be 'BINARY' or 'JSON'. JSON arrays are easier to inspect but far larger.")
+	legacyTables := defineBoolFlag("legacy-tables", "", false, "Writes the feature and batch tables of the pnts tile contents unpadded, as the earlier versions of the tool, rather than with their JSON headers and binary bodies starting and ending on 8-byte boundaries as required by the 3D Tiles specification.")
+	ghostFilter := defineBoolFlag("ghost-filter", "", false, "Removes the ghost points that terrestrial scanners record behind windows and mirrors, detected as the sparser side of the point pairs symmetric about an opening of a fitted planar surface. Requires a projected input srid.")
+	ghostVoxelSize := defineFloat64Flag("ghost-voxel", "", 0.1, "Size of the voxels the mirrored ghost points are detected on, in units of the input srid. Used by ghost-filter.")
+	ghostMaxDepth := defineFloat64Flag("ghost-depth", "", 5, "Max distance of the mirrored ghost points behind the reflective surfaces, in units of the input srid. Used by ghost-filter.")
+	alpha := defineStringFlag("alpha", "", "NONE", "Attribute the alpha channel of the points is derived from, written with their colors as RGBA, e.g. to style uncertain points as translucent. Must be one of NONE, INTENSITY.")
+	alphaMin := defineFloat64Flag("alpha-min", "", 0, "Alpha, between 0 and 1, of the points having a null value of the alpha attribute. Alpha grows linearly up to 1 for the max value.")
+	withheldPoints := defineStringFlag("withheld", "", "DROP", "Handling of the LAS points flagged as withheld, which producers mark to be excluded. Must be one of KEEP, DROP, SPLIT. SPLIT tiles them in a separate <file>_flagged tileset.")
+	syntheticPoints := defineStringFlag("synthetic", "", "KEEP", "Handling of the LAS points flagged as synthetic, i.e. created by techniques other than the scan. Must be one of KEEP, DROP, SPLIT. SPLIT tiles them in a separate <file>_flagged tileset.")
+	keyPoints := defineStringFlag("key-points", "", "KEEP", "Handling of the LAS points flagged as model key-points. Must be one of KEEP, DROP, SPLIT. SPLIT tiles them in a separate <file>_flagged tileset.")
+	leafPointCap := defineIntFlag("leaf-cap", "", 0, "Max number of points of the grid leaves that reached grid-min-size and store all the points they receive, as for dense terrestrial scans. Leaves exceeding it are handled according to leaf-cap-policy. No limit if 0.")
+	leafCapPolicy := defineStringFlag("leaf-cap-policy", "", "KEEP_ALL", "Handling of the grid leaves exceeding leaf-cap. Must be one of KEEP_ALL, RANDOM, DENSEST. KEEP_ALL keeps all their points with a warning, RANDOM keeps a random subsample, DENSEST keeps the points of their densest cells, dropping isolated points first.")
+	rootPercentile := defineFloat64Flag("root-percentile", "", 0, "Percentage of the points ignored at both ends of every axis when computing the root bounding box of the grid algorithm, e.g. 0.001 for the 0.001-99.999 percentiles. The points outside of it are stored in an overflow tile above the root. Min and max are used if 0.")
+	converterCacheSize := defineIntFlag("converter-cache", "", 0, "Max number of coordinate conversions cached by quantized horizontal source position, so that points sharing the same position, e.g. on vertical structures, are converted once. Disabled if 0.")
+	converterCacheQuantum := defineFloat64Flag("converter-cache-quantum", "", 0.001, "Quantization step of the source coordinates keying the cached conversions, in units of the input srid, e.g. 1e-8 for geographic srids. Should not exceed the precision of the input.")
+	elevationPipeline := defineStringFlag("elevation-pipeline", "", "", "Comma separated elevation corrections applied in sequence, replacing zoffset and geoid, e.g. geoid,offset:-0.35,raster:fix.asc. Steps are geoid, offset:<meters> and raster:<ESRI ASCII grid of corrections in EPSG:4326>.")
+	sourceColors := defineBoolFlag("source-colors", "", false, "Colors the points of every input file with a distinct color instead of their own, e.g. to check the alignment of the seams between adjacent deliveries lacking RGB.")
+	availability := defineBoolFlag("availability", "", false, "Writes alongside every tileset an availability.bin file, a compact bitmap of the quadtree cells containing points at every tree level.")
+	maxDirectoryEntries := defineIntFlag("max-dir-entries", "", 0, "Max number of entries of the output directories, at least 32. Exceeding tile contents and folders are moved to nested _shard folders. 0 means no limit.")
+	contentBaseUrl := defineStringFlag("content-base-url", "", "", "Base url the output folder is hosted at, e.g. https://cdn.example.com/datasets/abc/. If set the tilesets reference the tile contents with absolute urls instead of relative uris, so that they can be hosted separately.")
+	uriTemplate := defineStringFlag("uri-template", "", "", "Template of the paths of the tile contents relative to the tileset folder, e.g. {level}/{x}/{y}/{z}.pnts. Supports the {level}, {x}, {y}, {z}, {path} and {hash} placeholders.")
+	hostConfig := defineBoolFlag("host-config", "", false, "Writes in the output folder nginx, Apache and IIS configuration snippets mapping the generated files to their content types.")
 
-	flag.Parse()
+	// the serve subcommand stands for the serve flag, serving the folder given as argument on the default address
+	serveCommand := len(os.Args) > 1 && os.Args[1] == ServeCommand
+	if serveCommand {
+		_ = flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
+	set := parseEnvironment()
+	parseConfigFile(*config, set)
+	if serveCommand {
+		if *serve == "" {
+			*serve = DefaultServeAddress
+		}
+		if flag.NArg() > 0 {
+			*output = flag.Arg(0)
+		}
+	}
 
 	return Flags{
 		Input:                     input,
@@ -63,13 +354,223 @@ func ParseFlags() Flags {
 		Help:                      help,
 		Version:                   version,
 		RootGeometricError:		   rootGeometricError,
+		CoordinateFrame:           coordinateFrame,
+		ContentExtension:          contentExtension,
+		ExtensionlessContent:      extensionlessContent,
+		HostConfig:                hostConfig,
+		RosCloudTopic:             rosCloudTopic,
+		RosPoseTopic:              rosPoseTopic,
+		TrajectoryFile:            trajectoryFile,
+		TerrainFile:               terrainFile,
+		TerrainSrid:               terrainSrid,
+		OriginSnap:                originSnap,
+		StatsFinal:                statsFinal,
+		Tui:                       tui,
+		MaxOpenFiles:              maxOpenFiles,
+		StallTimeout:              stallTimeout,
+		StallAbort:                stallAbort,
+		ReadWorkers:               readWorkers,
+		ConvertWorkers:            convertWorkers,
+		InsertWorkers:             insertWorkers,
+		WriteWorkers:              writeWorkers,
+		TileMetadata:              tileMetadata,
+		ThumbnailSize:             thumbnailSize,
+		Coverage:                  coverage,
+		Stac:                      stacItem,
+		StacCollection:            stacCollection,
+		GeoVolumes:                geoVolumes,
+		LevelRetention:            levelRetention,
+		SkipDuplicates:            skipDuplicates,
+		ControlPointsFile:         controlPointsFile,
+		ControlPointsSrid:         controlPointsSrid,
+		ControlPointsTolerance:    controlPointsTolerance,
+		ExcludeOverlap:            excludeOverlap,
+		Returns:                   returns,
+		ScannerChannel:            scannerChannel,
+		PruneScreenError:          pruneScreenError,
+		PruneDistance:             pruneDistance,
+		Compression:               compression,
+		ZstdDictionary:            zstdDictionary,
+		Serve:                     serve,
+		DeduplicateTiles:          deduplicateTiles,
+		UriTemplate:               uriTemplate,
+		ContentBaseUrl:            contentBaseUrl,
+		MaxDirectoryEntries:       maxDirectoryEntries,
+		Availability:              availability,
+		SourceColors:              sourceColors,
+		ElevationPipeline:         elevationPipeline,
+		ConverterCacheSize:        converterCacheSize,
+		ConverterCacheQuantum:     converterCacheQuantum,
+		RootPercentile:            rootPercentile,
+		LeafPointCap:              leafPointCap,
+		LeafCapPolicy:             leafCapPolicy,
+		WithheldPoints:            withheldPoints,
+		SyntheticPoints:           syntheticPoints,
+		KeyPoints:                 keyPoints,
+		Alpha:                     alpha,
+		AlphaMin:                  alphaMin,
+		GhostFilter:               ghostFilter,
+		GhostVoxelSize:            ghostVoxelSize,
+		GhostMaxDepth:             ghostMaxDepth,
+		BatchTable:                batchTable,
+		LegacyTables:              legacyTables,
+		RunMetadata:               runMetadata,
+		ClassLayers:               classLayers,
+		InvalidColors:             invalidColors,
+		SidecarFolder:             sidecarFolder,
+		SidecarKey:                sidecarKey,
+		ParquetColumns:            parquetColumns,
+		ParquetExport:             parquetExport,
+		WebhookUrl:                webhookUrl,
+		WebhookSecret:             webhookSecret,
+		ReaderPlugins:             readerPlugins,
+		Bridge:                    bridge,
+		SpoolFolder:               spoolFolder,
+		Quarantine:                quarantine,
+		ValidityArea:              validityArea,
+		ErrorPolicy:               errorPolicy,
+		ReadChunkSize:             readChunkSize,
+		License:                   license,
+		LicenseUrl:                licenseUrl,
+		Watermark:                 watermark,
+		VerifyWatermark:           verifyWatermark,
+		TilesetVersion:            tilesetVersion,
+		SizeBudget:                sizeBudget,
+		Draco:                     draco,
+		DracoQuantization:         dracoQuantization,
+		CoarseFirst:               coarseFirst,
+		BundleThreshold:           bundleThreshold,
+		BundleMaxSize:             bundleMaxSize,
+		ImplicitTiling:            implicitTiling,
+		SubtreeLevels:             subtreeLevels,
+		SplitTileSize:             splitTileSize,
+		Merge:                     merge,
+		Generate:                  generate,
+		GenerateExtent:            generateExtent,
+		GenerateDensity:           generateDensity,
+		GenerateBuildings:         generateBuildings,
+		GenerateNoise:             generateNoise,
+		GenerateSeed:              generateSeed,
+		GenerateOrigin:            generateOrigin,
+		RecoverRecords:            recoverRecords,
+		ColorSource:               colorSource,
+		ColorRamp:                 colorRamp,
+		LasAttributes:             lasAttributes,
+		Resume:                    resume,
+		SelfUpdate:                selfUpdate,
+		CheckUpdate:               checkUpdate,
+		Target:                    target,
+		ZonalStats:                zonalStats,
+		ZonalTileset:              zonalTileset,
+		Profile:                   profile,
+		ProfileBuffer:             profileBuffer,
+		Crs:                       crs,
+		CrsBackend:                crsBackend,
+		GeoidModel:                geoidModel,
+		Nearest:                   nearest,
+		NearestCount:              nearestCount,
+		NearestLevel:              nearestLevel,
+		DensityTarget:             densityTarget,
+		DensityMode:               densityMode,
+		DensityCellSize:           densityCellSize,
+		GridSpool:                 gridSpool,
+		GridSampling:              gridSampling,
+		ScanIds:                   scanIds,
+		ScanPoses:                 scanPoses,
+		WriterConcurrency:         writerConcurrency,
+		IntensityStretch:          intensityStretch,
+		GridSamplingAttribute:     gridSamplingAttribute,
+		TreeDump:                  treeDump,
+		InspectTree:               inspectTree,
+		Wireframe:                 wireframe,
 	}
 }
 
+// Sets the flags not given on the command line from their environment variables, if any, so that the command line
+// takes precedence over the environment and the environment over the defaults. Returns the names of the flags set
+// either way. Exits with the same status as the flag parser if a variable holds an invalid value.
+func parseEnvironment() map[string]bool {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+		if target, ok := shorthandTargets[f.Name]; ok {
+			set[target] = true
+		}
+	})
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, ok := shorthandTargets[f.Name]; ok || set[f.Name] {
+			return
+		}
+		variable := getEnvironmentVariable(f.Name)
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			exitWithInvalidValue(fmt.Sprintf("invalid value %q for environment variable %s: %v", value, variable, err))
+		}
+		set[f.Name] = true
+	})
+	return set
+}
+
+// Sets the flags not in the given set from the name=value lines of the given configuration file, if any, so that the
+// environment takes precedence over the configuration file and the configuration file over the defaults. Exits with
+// the same status as the flag parser if the file cannot be read or holds an invalid line.
+func parseConfigFile(filePath string, set map[string]bool) {
+	if filePath == "" {
+		return
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		exitWithInvalidValue(fmt.Sprintf("cannot read the configuration file: %v", err))
+	}
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		separator := strings.Index(line, "=")
+		if separator < 0 {
+			exitWithInvalidValue(fmt.Sprintf("invalid line %d of the configuration file %s: expected name=value", i+1, filePath))
+		}
+		name, value := strings.TrimSpace(line[:separator]), strings.TrimSpace(line[separator+1:])
+		if target, ok := shorthandTargets[name]; ok {
+			exitWithInvalidValue(fmt.Sprintf("shorthand %q at line %d of the configuration file %s, use %s instead", name, i+1, filePath, target))
+		}
+		f := flag.Lookup(name)
+		if f == nil || name == "config" {
+			exitWithInvalidValue(fmt.Sprintf("unknown flag %q at line %d of the configuration file %s", name, i+1, filePath))
+		}
+		if set[name] {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			exitWithInvalidValue(fmt.Sprintf("invalid value %q for flag %s at line %d of the configuration file %s: %v", value, name, i+1, filePath, err))
+		}
+	}
+}
+
+// Prints the given error and the usage, then exits with the same status as the flag parser
+func exitWithInvalidValue(message string) {
+	_, _ = fmt.Fprintln(flag.CommandLine.Output(), message)
+	flag.Usage()
+	os.Exit(2)
+}
+
+// Returns the name of the environment variable of the flag with the given name
+func getEnvironmentVariable(name string) string {
+	return EnvironmentPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// Names of the flags the defined shorthands stand for, by shorthand
+var shorthandTargets = make(map[string]string)
+
 func defineStringFlag(name string, shortHand string, defaultValue string, usage string) *string {
 	var output string
 	flag.StringVar(&output, name, defaultValue, usage)
 	if shortHand != name && shortHand != "" {
+		shorthandTargets[shortHand] = name
 		flag.StringVar(&output, shortHand, defaultValue, usage+" (shorthand for "+name+")")
 	}
 
@@ -79,7 +580,8 @@ func defineStringFlag(name string, shortHand string, defaultValue string, usage
 func defineIntFlag(name string, shortHand string, defaultValue int, usage string) *int {
 	var output int
 	flag.IntVar(&output, name, defaultValue, usage)
-	if shortHand != name {
+	if shortHand != name && shortHand != "" {
+		shorthandTargets[shortHand] = name
 		flag.IntVar(&output, shortHand, defaultValue, usage+" (shorthand for "+name+")")
 	}
 
@@ -87,18 +589,36 @@ func defineIntFlag(name string, shortHand string, defaultValue int, usage string
 }
 
 func defineFloat64Flag(name string, shortHand string, defaultValue float64, usage string) *float64 {
-	var output float64
-	flag.Float64Var(&output, name, defaultValue, usage)
-	if shortHand != name {
-		flag.Float64Var(&output, shortHand, defaultValue, usage+" (shorthand for "+name+")")
+	output := defaultValue
+	flag.Var((*decimalValue)(&output), name, usage)
+	if shortHand != name && shortHand != "" {
+		shorthandTargets[shortHand] = name
+		flag.Var((*decimalValue)(&output), shortHand, usage+" (shorthand for "+name+")")
 	}
 	return &output
 }
 
+// Value of the float flags, accepting both the dot and the comma as decimal separator
+type decimalValue float64
+
+func (v *decimalValue) Set(value string) error {
+	number, err := tiler.ParseDecimal(value)
+	if err != nil {
+		return err
+	}
+	*v = decimalValue(number)
+	return nil
+}
+
+func (v *decimalValue) String() string {
+	return strconv.FormatFloat(float64(*v), 'g', -1, 64)
+}
+
 func defineBoolFlag(name string, shortHand string, defaultValue bool, usage string) *bool {
 	var output bool
 	flag.BoolVar(&output, name, defaultValue, usage)
-	if shortHand != name {
+	if shortHand != name && shortHand != "" {
+		shorthandTargets[shortHand] = name
 		flag.BoolVar(&output, shortHand, defaultValue, usage+" (shorthand for "+name+")")
 	}
 	return &output
@@ -2,11 +2,19 @@ package tools
 
 import (
 	"fmt"
+	"github.com/mfbonfigli/gocesiumtiler/internal/converters"
+	"strings"
 	"time"
 )
 
 var isEnabled = true
 var printTimestamp = true
+var logListener func(message string)
+
+func init() {
+	// the packages that tools depends on cannot import it, they log through it once it is loaded
+	converters.SetLogOutput(func(message string) { LogOutput(message) })
+}
 
 func EnableLogger() {
 	isEnabled = true
@@ -24,7 +32,16 @@ func DisableLoggerTimestamp() {
 	printTimestamp = false
 }
 
+// Redirects the log messages to the given listener instead of the standard output, nil restores the standard output
+func SetLogListener(listener func(message string)) {
+	logListener = listener
+}
+
 func LogOutput(val ...interface{}) {
+	if isEnabled && logListener != nil {
+		logListener(strings.TrimSuffix(fmt.Sprintln(val...), "\n"))
+		return
+	}
 	if isEnabled {
 		if printTimestamp {
 			fmt.Print("[" + time.Now().Format("2006-01-02 15.04:05.000") + "] ")